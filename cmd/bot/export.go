@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/mixelka/emailresend/internal/config"
+	"github.com/mixelka/emailresend/internal/credexport"
+	"github.com/mixelka/emailresend/internal/database"
+	"github.com/mixelka/emailresend/pkg/models"
+)
+
+// runExportCredentials implements the export-credentials subcommand: it writes
+// all account credentials encrypted to an operator's age or PGP public key
+func runExportCredentials(args []string) int {
+	fs := flag.NewFlagSet("export-credentials", flag.ContinueOnError)
+	recipient := fs.String("recipient", "", "age recipient, or a file with age recipients or an armored PGP public key")
+	out := fs.String("out", "-", "output file (- for stdout)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *recipient == "" {
+		fmt.Fprintln(os.Stderr, "usage: bot export-credentials -recipient <age1...|key file> [-out file]")
+		return 2
+	}
+
+	key := *recipient
+	if data, err := os.ReadFile(key); err == nil {
+		key = string(data)
+	}
+	r, err := credexport.ParseRecipient(key)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to load config:", err)
+		return 1
+	}
+
+	db, err := database.Open(cfg.DatabaseDriver, cfg.DatabaseSource())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to connect to database:", err)
+		return 1
+	}
+	defer db.Close()
+
+	crypter, err := newCrypter(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to create secrets backend:", err)
+		return 1
+	}
+
+	ctx := context.Background()
+	accounts, err := db.GetAllAccounts(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	oauthToken := func(accountID int64) (*models.OAuthToken, error) {
+		token, err := db.GetOAuthToken(ctx, accountID)
+		if errors.Is(err, database.ErrNotFound) {
+			return nil, nil
+		}
+		return token, err
+	}
+	export, err := credexport.Build(accounts, oauthToken, crypter.Decrypt)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	ciphertext, err := credexport.Encrypt(ctx, r, export)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if *out == "-" {
+		os.Stdout.Write(ciphertext)
+	} else if err := os.WriteFile(*out, ciphertext, 0o600); err != nil {
+		fmt.Fprintln(os.Stderr, "failed to write export:", err)
+		return 1
+	}
+
+	fmt.Fprintf(os.Stderr, "exported %d accounts (%s)\n", len(export.Accounts), r.Kind)
+	return 0
+}
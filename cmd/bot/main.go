@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/lmittmann/tint"
+	"github.com/mixelka/emailresend/internal/archive"
 	"github.com/mixelka/emailresend/internal/config"
 	"github.com/mixelka/emailresend/internal/database"
 	"github.com/mixelka/emailresend/internal/email"
@@ -19,6 +20,19 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "export-credentials":
+			os.Exit(runExportCredentials(os.Args[2:]))
+		case "config":
+			os.Exit(runConfig(os.Args[2:]))
+		case "rotate-key":
+			os.Exit(runRotateKey(os.Args[2:]))
+		case "migrate-secrets":
+			os.Exit(runMigrateSecrets(os.Args[2:]))
+		}
+	}
+
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
@@ -31,7 +45,7 @@ func main() {
 	logger.Info("starting email-to-telegram bot")
 
 	// Connect to database
-	db, err := database.New(cfg.DatabasePath)
+	db, err := database.Open(cfg.DatabaseDriver, cfg.DatabaseSource())
 	if err != nil {
 		logger.Error("failed to connect to database", "error", err)
 		os.Exit(1)
@@ -50,6 +64,9 @@ func main() {
 	emailManager := email.NewManager(cfg, logger)
 	htmlParser := parser.NewHTMLParser()
 	codeDetector := parser.NewCodeDetector()
+	extractors := parser.NewDefaultRegistry()
+	orderDetector := parser.NewOrderDetector()
+	trackingDetector := parser.NewTrackingDetector()
 	tgFormatter := formatter.NewTelegramFormatter()
 
 	// Create Mailcow client (optional)
@@ -63,24 +80,60 @@ func main() {
 		logger.Info("mailcow integration enabled", "domain", cfg.MailcowDomain)
 	}
 
-	// Create bot
-	bot, err := telegram.NewBot(telegram.BotDeps{
-		Config:       cfg,
-		DB:           db,
-		EmailManager: emailManager,
-		Mailcow:      mailcowClient,
-		HTMLParser:   htmlParser,
-		CodeDetector: codeDetector,
-		Formatter:    tgFormatter,
-		Logger:       logger,
-	})
+	// Create raw message archive (optional)
+	var rawArchive *archive.Archive
+	if cfg.ArchiveBackend != "" {
+		rawArchive, err = archive.New(archive.Config{
+			Backend:     cfg.ArchiveBackend,
+			Dir:         cfg.ArchiveDir,
+			S3Endpoint:  cfg.ArchiveS3Endpoint,
+			S3Bucket:    cfg.ArchiveS3Bucket,
+			S3Region:    cfg.ArchiveS3Region,
+			S3AccessKey: cfg.ArchiveS3AccessKey,
+			S3SecretKey: cfg.ArchiveS3SecretKey,
+		})
+		if err != nil {
+			logger.Error("failed to create raw message archive", "error", err)
+			os.Exit(1)
+		}
+		logger.Info("raw message archive enabled", "backend", cfg.ArchiveBackend)
+	}
+
+	// Create bots (one per token, the first one is primary)
+	crypter, err := newCrypter(cfg)
 	if err != nil {
-		logger.Error("failed to create bot", "error", err)
+		logger.Error("failed to create secrets backend", "error", err)
 		os.Exit(1)
 	}
+	var bots []*telegram.Bot
+	for i, token := range cfg.BotTokens() {
+		bot, err := telegram.NewBot(telegram.BotDeps{
+			Token:            token,
+			Primary:          i == 0,
+			Config:           cfg,
+			Crypter:          crypter,
+			DB:               db,
+			EmailManager:     emailManager,
+			Mailcow:          mailcowClient,
+			Archive:          rawArchive,
+			HTMLParser:       htmlParser,
+			CodeDetector:     codeDetector,
+			Extractors:       extractors,
+			OrderDetector:    orderDetector,
+			TrackingDetector: trackingDetector,
+			Formatter:        tgFormatter,
+			Logger:           logger,
+		})
+		if err != nil {
+			logger.Error("failed to create bot", "error", err)
+			os.Exit(1)
+		}
+		bots = append(bots, bot)
+	}
+	router := telegram.NewRouter(bots)
 
 	// Setup email callbacks
-	bot.SetupEmailCallbacks()
+	router.SetupEmailCallbacks()
 
 	// Restore email connections from database
 	accounts, err := db.GetAllActiveAccounts(ctx)
@@ -89,6 +142,7 @@ func main() {
 		os.Exit(1)
 	}
 
+	accounts = router.ServedAccounts(accounts)
 	if len(accounts) > 0 {
 		logger.Info("restoring email connections", "count", len(accounts))
 		emailManager.RestoreAll(ctx, accounts)
@@ -105,12 +159,36 @@ func main() {
 		logger.Info("shutting down...")
 
 		emailManager.StopAll()
+
+		// Finish the emails being handled and post the queued ones; what is
+		// left stays in the database for the next start. A second signal
+		// skips the wait.
+		drainCtx, stopDrain := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+		go func() {
+			select {
+			case <-sigCh:
+				stopDrain()
+			case <-drainCtx.Done():
+			}
+		}()
+		if emailManager.Drain(drainCtx) && router.Drain(drainCtx) {
+			logger.Info("in-flight emails delivered")
+		}
+		stopDrain()
 		cancel()
 	}()
 
+	// Schedule database maintenance
+	if start, end, ok, _ := cfg.MaintenanceWindowBounds(); ok {
+		go db.RunMaintenance(ctx, start, end, logger)
+	}
+
+	// Alert about accounts whose fetch cycle stopped
+	go emailManager.RunWatchdog(ctx)
+
 	// Start bot
-	logger.Info("bot is running, press Ctrl+C to stop")
-	bot.Start(ctx)
+	logger.Info("bot is running, press Ctrl+C to stop", "bots", len(bots))
+	router.Start(ctx)
 
 	logger.Info("bot stopped")
 }
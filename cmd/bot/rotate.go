@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mixelka/emailresend/internal/config"
+	"github.com/mixelka/emailresend/internal/database"
+	"github.com/mixelka/emailresend/internal/secret"
+)
+
+// runRotateKey implements the rotate-key subcommand: it re-encrypts all stored
+// passwords and OAuth tokens from ENCRYPTION_KEY to a new key in one
+// transaction. The bot must be stopped, as it keeps using the old key.
+func runRotateKey(args []string) int {
+	fs := flag.NewFlagSet("rotate-key", flag.ContinueOnError)
+	keyFile := fs.String("new-key-file", "", "file with the new 32-byte key (default: NEW_ENCRYPTION_KEY)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	newKey := os.Getenv("NEW_ENCRYPTION_KEY")
+	if *keyFile != "" {
+		data, err := os.ReadFile(*keyFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "failed to read new key:", err)
+			return 1
+		}
+		newKey = strings.TrimRight(string(data), "\r\n")
+	}
+	if newKey == "" {
+		fmt.Fprintln(os.Stderr, "usage: NEW_ENCRYPTION_KEY=... bot rotate-key, or bot rotate-key -new-key-file <file>")
+		return 2
+	}
+	if len(newKey) != 32 {
+		fmt.Fprintf(os.Stderr, "the new key must be exactly 32 bytes, got %d\n", len(newKey))
+		return 1
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to load config:", err)
+		return 1
+	}
+	if cfg.SecretsBackend != "" && cfg.SecretsBackend != secret.BackendLocal {
+		fmt.Fprintf(os.Stderr, "the key is managed by the %s secrets backend, rotate it there\n", cfg.SecretsBackend)
+		return 1
+	}
+	if newKey == cfg.EncryptionKey {
+		fmt.Fprintln(os.Stderr, "the new key equals ENCRYPTION_KEY")
+		return 1
+	}
+
+	db, err := database.Open(cfg.DatabaseDriver, cfg.DatabaseSource())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to connect to database:", err)
+		return 1
+	}
+	defer db.Close()
+
+	stats, err := db.RotateSecrets(context.Background(), func(encrypted string) (string, error) {
+		return secret.Reencrypt(cfg.EncryptionKey, newKey, encrypted)
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, "nothing was changed")
+		return 1
+	}
+
+	fmt.Fprintf(os.Stderr, "re-encrypted %d passwords, %d OAuth tokens and %d S/MIME keys; set ENCRYPTION_KEY to the new key before starting the bot\n",
+		stats.Passwords, stats.OAuthTokens, stats.SMIMEKeys)
+	return 0
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/mixelka/emailresend/internal/config"
+	"github.com/mixelka/emailresend/internal/database"
+	"github.com/mixelka/emailresend/internal/secret"
+)
+
+// newCrypter creates the Crypter of SECRETS_BACKEND
+func newCrypter(cfg *config.Config) (secret.Crypter, error) {
+	return secret.New(secret.Config{
+		Backend:         cfg.SecretsBackend,
+		Key:             cfg.EncryptionKey,
+		VaultAddr:       cfg.VaultAddr,
+		VaultToken:      cfg.VaultToken,
+		VaultMount:      cfg.VaultTransitMount,
+		VaultKey:        cfg.VaultTransitKey,
+		KMSKeyID:        cfg.KMSKeyID,
+		KMSRegion:       cfg.AWSRegion,
+		KMSEndpoint:     cfg.KMSEndpoint,
+		KMSAccessKey:    cfg.AWSAccessKeyID,
+		KMSSecretKey:    cfg.AWSSecretKey,
+		KMSSessionToken: cfg.AWSSessionToken,
+	})
+}
+
+// runMigrateSecrets implements the migrate-secrets subcommand: after
+// switching SECRETS_BACKEND to vault or kms it re-encrypts all passwords and
+// OAuth tokens still stored with ENCRYPTION_KEY in one transaction, after
+// which ENCRYPTION_KEY can be removed
+func runMigrateSecrets(args []string) int {
+	if len(args) > 0 {
+		fmt.Fprintln(os.Stderr, "usage: bot migrate-secrets")
+		return 2
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to load config:", err)
+		return 1
+	}
+	if cfg.SecretsBackend == "" || cfg.SecretsBackend == secret.BackendLocal || cfg.EncryptionKey == "" {
+		fmt.Fprintln(os.Stderr, "set SECRETS_BACKEND to vault or kms and keep ENCRYPTION_KEY until the migration is done")
+		return 1
+	}
+
+	crypter, err := newCrypter(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to create secrets backend:", err)
+		return 1
+	}
+
+	db, err := database.Open(cfg.DatabaseDriver, cfg.DatabaseSource())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to connect to database:", err)
+		return 1
+	}
+	defer db.Close()
+
+	stats, err := db.RotateSecrets(context.Background(), func(encrypted string) (string, error) {
+		return secret.Upgrade(crypter, encrypted)
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, "nothing was changed")
+		return 1
+	}
+
+	fmt.Fprintf(os.Stderr, "moved %d passwords, %d OAuth tokens and %d S/MIME keys to %s; ENCRYPTION_KEY is no longer needed\n",
+		stats.Passwords, stats.OAuthTokens, stats.SMIMEKeys, cfg.SecretsBackend)
+	return 0
+}
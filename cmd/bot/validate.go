@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mixelka/emailresend/internal/config"
+	"github.com/mixelka/emailresend/internal/database"
+	"github.com/mixelka/emailresend/internal/mailcow"
+)
+
+// Statuses of a validation check
+const (
+	checkOK      = "ok"
+	checkFailed  = "error"
+	checkSkipped = "skipped"
+)
+
+// validationCheck is the result of a check that touches the environment
+type validationCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// validationReport is printed by config validate
+type validationReport struct {
+	Valid  bool              `json:"valid"`
+	Issues []config.Issue    `json:"issues"`
+	Checks []validationCheck `json:"checks"`
+}
+
+// runConfig implements the config subcommand
+func runConfig(args []string) int {
+	if len(args) == 0 || args[0] != "validate" {
+		fmt.Fprintln(os.Stderr, "usage: bot config validate [-env-file file] [-offline]")
+		return 2
+	}
+	return runConfigValidate(args[1:])
+}
+
+// runConfigValidate implements config validate: it checks the configuration
+// and its environment and prints a JSON report. Exits with 1 if any error
+// was found, so deployment pipelines can gate on it.
+func runConfigValidate(args []string) int {
+	flags := flag.NewFlagSet("config validate", flag.ContinueOnError)
+	envFile := flags.String("env-file", "", "env file to load instead of .env")
+	offline := flags.Bool("offline", false, "skip checks that need the network")
+	timeout := flags.Duration("timeout", 10*time.Second, "timeout of network checks")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	var envFiles []string
+	if *envFile != "" {
+		envFiles = append(envFiles, *envFile)
+	}
+
+	report := validationReport{Issues: []config.Issue{}, Checks: []validationCheck{}}
+	cfg, err := config.Parse(envFiles...)
+	if err != nil {
+		report.Issues = append(report.Issues, config.Issue{Severity: config.SeverityError, Message: err.Error()})
+	} else {
+		report.Issues = append(report.Issues, cfg.Validate()...)
+		if cfg.DatabaseDriver == database.DriverPostgres {
+			report.Checks = append(report.Checks, checkPostgres(cfg, *offline, *timeout))
+		} else {
+			report.Checks = append(report.Checks, checkDatabasePath(cfg.DatabasePath))
+		}
+		if cfg.ArchiveBackend == "disk" {
+			report.Checks = append(report.Checks, checkWritableDir("archive_dir", cfg.ArchiveDir))
+		}
+		report.Checks = append(report.Checks, checkMailcow(cfg, *offline, *timeout))
+	}
+
+	report.Valid = true
+	for _, issue := range report.Issues {
+		if issue.Severity == config.SeverityError {
+			report.Valid = false
+		}
+	}
+	for _, check := range report.Checks {
+		if check.Status == checkFailed {
+			report.Valid = false
+		}
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if !report.Valid {
+		return 1
+	}
+	return 0
+}
+
+// checkDatabasePath checks that the database file can be opened for writing,
+// or created if it does not exist yet
+func checkDatabasePath(path string) validationCheck {
+	check := validationCheck{Name: "database", Status: checkOK}
+
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	switch {
+	case err == nil:
+		f.Close()
+	case errors.Is(err, fs.ErrNotExist):
+		dir := checkWritableDir("database", filepath.Dir(path))
+		if dir.Status != checkOK {
+			return dir
+		}
+		check.Message = "database does not exist yet and will be created"
+	default:
+		check.Status = checkFailed
+		check.Message = fmt.Sprintf("cannot open %s for writing: %v", path, err)
+	}
+	return check
+}
+
+// checkPostgres checks that the Postgres database accepts connections
+func checkPostgres(cfg *config.Config, offline bool, timeout time.Duration) validationCheck {
+	check := validationCheck{Name: "database", Status: checkSkipped}
+	switch {
+	case cfg.DatabaseURL == "":
+		check.Message = "DATABASE_URL is not set"
+		return check
+	case offline:
+		check.Message = "offline"
+		return check
+	}
+
+	db, err := database.Open(cfg.DatabaseDriver, cfg.DatabaseURL)
+	if err != nil {
+		check.Status = checkFailed
+		check.Message = err.Error()
+		return check
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		check.Status = checkFailed
+		check.Message = fmt.Sprintf("database is unreachable: %v", err)
+		return check
+	}
+
+	check.Status = checkOK
+	return check
+}
+
+// checkWritableDir checks that files can be created in dir. A missing
+// directory is fine if it can be created, as the bot creates it on start.
+func checkWritableDir(name, dir string) validationCheck {
+	check := validationCheck{Name: name, Status: checkOK}
+
+	// Walk up to the first existing directory
+	existing := dir
+	for {
+		info, err := os.Stat(existing)
+		if err == nil {
+			if !info.IsDir() {
+				check.Status = checkFailed
+				check.Message = fmt.Sprintf("%s is not a directory", existing)
+				return check
+			}
+			break
+		}
+		if !errors.Is(err, fs.ErrNotExist) || filepath.Dir(existing) == existing {
+			check.Status = checkFailed
+			check.Message = fmt.Sprintf("cannot access %s: %v", existing, err)
+			return check
+		}
+		existing = filepath.Dir(existing)
+	}
+
+	f, err := os.CreateTemp(existing, ".validate-*")
+	if err != nil {
+		check.Status = checkFailed
+		check.Message = fmt.Sprintf("%s is not writable: %v", existing, err)
+		return check
+	}
+	f.Close()
+	os.Remove(f.Name())
+
+	if existing != dir {
+		check.Message = fmt.Sprintf("%s does not exist yet and will be created", dir)
+	}
+	return check
+}
+
+// checkMailcow checks that the Mailcow API is reachable and accepts the key
+func checkMailcow(cfg *config.Config, offline bool, timeout time.Duration) validationCheck {
+	check := validationCheck{Name: "mailcow", Status: checkSkipped}
+	switch {
+	case !cfg.MailcowEnabled():
+		check.Message = "Mailcow integration is not configured"
+		return check
+	case offline:
+		check.Message = "offline"
+		return check
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	client := mailcow.NewClient(mailcow.Config{
+		BaseURL: strings.TrimRight(cfg.MailcowURL, "/"),
+		APIKey:  cfg.MailcowAPIKey,
+		Domain:  cfg.MailcowDomain,
+	})
+	version, err := client.Version(ctx)
+	if err != nil {
+		check.Status = checkFailed
+		check.Message = err.Error()
+		return check
+	}
+
+	check.Status = checkOK
+	check.Message = "Mailcow " + version
+	return check
+}
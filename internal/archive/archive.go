@@ -0,0 +1,117 @@
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrNotFound is returned when no raw message is stored under the key
+var ErrNotFound = errors.New("raw message not found")
+
+// Store keeps compressed raw RFC822 messages
+type Store interface {
+	// Put stores data under key, replacing any previous value
+	Put(ctx context.Context, key string, data []byte) error
+	// Get returns the data stored under key or ErrNotFound
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Delete removes the data stored under key; missing keys are not an error
+	Delete(ctx context.Context, key string) error
+}
+
+// Config for the raw message archive
+type Config struct {
+	Backend string // "disk" or "s3"
+
+	// Disk backend
+	Dir string
+
+	// S3 backend
+	S3Endpoint  string // e.g., https://s3.eu-central-1.amazonaws.com
+	S3Bucket    string
+	S3Region    string
+	S3AccessKey string
+	S3SecretKey string
+}
+
+// Archive stores raw messages compressed with gzip
+type Archive struct {
+	store Store
+}
+
+// New creates an archive for the configured backend
+func New(cfg Config) (*Archive, error) {
+	var store Store
+	switch cfg.Backend {
+	case "disk":
+		disk, err := NewDiskStore(cfg.Dir)
+		if err != nil {
+			return nil, err
+		}
+		store = disk
+	case "s3":
+		s3, err := NewS3Store(cfg)
+		if err != nil {
+			return nil, err
+		}
+		store = s3
+	default:
+		return nil, fmt.Errorf("unknown archive backend %q", cfg.Backend)
+	}
+	return &Archive{store: store}, nil
+}
+
+// Key returns the storage key of a message
+func Key(accountID int64, uid uint32) string {
+	return fmt.Sprintf("%d/%d.eml.gz", accountID, uid)
+}
+
+// Save compresses and stores a raw message, returning its key and the
+// number of bytes stored
+func (a *Archive) Save(ctx context.Context, accountID int64, uid uint32, raw []byte) (string, int64, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(raw); err != nil {
+		return "", 0, fmt.Errorf("failed to compress message: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return "", 0, fmt.Errorf("failed to compress message: %w", err)
+	}
+
+	key := Key(accountID, uid)
+	if err := a.store.Put(ctx, key, buf.Bytes()); err != nil {
+		return "", 0, fmt.Errorf("failed to store message: %w", err)
+	}
+	return key, int64(buf.Len()), nil
+}
+
+// Load returns the decompressed raw message stored under key
+func (a *Archive) Load(ctx context.Context, key string) ([]byte, error) {
+	data, err := a.store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress message: %w", err)
+	}
+	defer zr.Close()
+
+	raw, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress message: %w", err)
+	}
+	return raw, nil
+}
+
+// Delete removes the raw message stored under key
+func (a *Archive) Delete(ctx context.Context, key string) error {
+	if err := a.store.Delete(ctx, key); err != nil {
+		return fmt.Errorf("failed to delete message: %w", err)
+	}
+	return nil
+}
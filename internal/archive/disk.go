@@ -0,0 +1,57 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// DiskStore keeps messages as files under a directory
+type DiskStore struct {
+	dir string
+}
+
+// NewDiskStore creates a disk store, creating the directory if needed
+func NewDiskStore(dir string) (*DiskStore, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("archive directory is not set")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	return &DiskStore{dir: dir}, nil
+}
+
+// Put writes the data atomically via a temporary file
+func (s *DiskStore) Put(ctx context.Context, key string, data []byte) error {
+	path := filepath.Join(s.dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Get reads the file stored under key
+func (s *DiskStore) Get(ctx context.Context, key string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, filepath.FromSlash(key)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+// Delete removes the file stored under key
+func (s *DiskStore) Delete(ctx context.Context, key string) error {
+	err := os.Remove(filepath.Join(s.dir, filepath.FromSlash(key)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
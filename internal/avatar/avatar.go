@@ -0,0 +1,109 @@
+// Package avatar finds pictures of email senders to show next to their emails
+package avatar
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// hitTTL is how long a found avatar is remembered
+	hitTTL = 24 * time.Hour
+	// missTTL is how long a sender without an avatar is not asked for again
+	missTTL = 6 * time.Hour
+	// maxCached bounds the cache; it is emptied when full
+	maxCached = 10000
+)
+
+// Source looks up Gravatar pictures of email addresses and caches the
+// answers, including addresses without one.
+//
+// BIMI logos are not used: they are SVG Tiny images, which Telegram does not
+// show as a link preview.
+type Source struct {
+	client *http.Client
+
+	mu    sync.Mutex
+	cache map[string]cachedAvatar
+}
+
+type cachedAvatar struct {
+	url     string // Empty if the address has no avatar
+	expires time.Time
+}
+
+// NewSource creates an avatar source
+func NewSource() *Source {
+	return &Source{
+		client: &http.Client{Timeout: 5 * time.Second},
+		cache:  make(map[string]cachedAvatar),
+	}
+}
+
+// URL returns the URL of the avatar of an email address, empty if it has
+// none or the lookup failed. Failed lookups are not cached.
+func (s *Source) URL(ctx context.Context, address string) string {
+	address = strings.ToLower(strings.TrimSpace(address))
+	if address == "" {
+		return ""
+	}
+
+	s.mu.Lock()
+	cached, ok := s.cache[address]
+	s.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.url
+	}
+
+	url := GravatarURL(address)
+	found, err := s.exists(ctx, url)
+	if err != nil {
+		return ""
+	}
+	cached = cachedAvatar{expires: time.Now().Add(missTTL)}
+	if found {
+		cached = cachedAvatar{url: url, expires: time.Now().Add(hitTTL)}
+	}
+
+	s.mu.Lock()
+	if len(s.cache) >= maxCached {
+		clear(s.cache)
+	}
+	s.cache[address] = cached
+	s.mu.Unlock()
+	return cached.url
+}
+
+// exists reports whether an image is served at url
+func (s *Source) exists(ctx context.Context, url string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to check avatar: %w", err)
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+	return false, fmt.Errorf("gravatar responded %s", resp.Status)
+}
+
+// GravatarURL returns the Gravatar picture URL of an email address. It
+// responds 404 instead of a default picture if the address has none.
+func GravatarURL(address string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(address))))
+	return "https://gravatar.com/avatar/" + hex.EncodeToString(sum[:]) + "?s=200&d=404"
+}
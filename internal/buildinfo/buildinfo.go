@@ -0,0 +1,60 @@
+// Package buildinfo exposes version information embedded at build time:
+//
+//	go build -ldflags "-X github.com/mixelka/emailresend/internal/buildinfo.Version=v1.2.3 \
+//	  -X github.com/mixelka/emailresend/internal/buildinfo.Commit=abc1234 \
+//	  -X github.com/mixelka/emailresend/internal/buildinfo.Date=2024-05-01T12:00:00Z"
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Set via -ldflags -X
+var (
+	Version = "dev"
+	Commit  = ""
+	Date    = ""
+)
+
+// Info describes the running binary
+type Info struct {
+	Version   string
+	Commit    string
+	Date      string
+	GoVersion string
+	Modified  bool // built from a tree with uncommitted changes
+}
+
+// Get returns the build info. Commit and date fall back to the VCS stamp
+// Go embeds when building from a git checkout.
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		Date:      Date,
+		GoVersion: runtime.Version(),
+	}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = s.Value
+				}
+			case "vcs.time":
+				if info.Date == "" {
+					info.Date = s.Value
+				}
+			case "vcs.modified":
+				info.Modified = s.Value == "true"
+			}
+		}
+	}
+
+	if len(info.Commit) > 12 {
+		info.Commit = info.Commit[:12]
+	}
+	return info
+}
@@ -0,0 +1,208 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Severity of a configuration issue
+type Severity string
+
+const (
+	SeverityError   Severity = "error"   // the bot refuses to start
+	SeverityWarning Severity = "warning" // the bot starts, but a feature will not work as expected
+)
+
+// Issue is a problem found in the configuration
+type Issue struct {
+	Field    string   `json:"field"` // environment variable
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+func (i Issue) Error() string {
+	return i.Message
+}
+
+var (
+	// botTokenPattern matches tokens issued by @BotFather
+	botTokenPattern = regexp.MustCompile(`^\d+:[A-Za-z0-9_-]{30,}$`)
+	// repoPattern matches a GitHub owner/name
+	repoPattern = regexp.MustCompile(`^[\w.-]+/[\w.-]+$`)
+	// webhookSecretPattern matches secret tokens accepted by setWebhook
+	webhookSecretPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,256}$`)
+)
+
+// Validate checks the configuration without touching the network or the
+// filesystem and returns every issue found
+func (c *Config) Validate() []Issue {
+	var issues []Issue
+	add := func(field string, severity Severity, format string, args ...any) {
+		issues = append(issues, Issue{Field: field, Severity: severity, Message: fmt.Sprintf(format, args...)})
+	}
+
+	// 32 bytes for AES-256; with a remote backend the key only decrypts
+	// passwords stored before the switch
+	switch c.SecretsBackend {
+	case "", "local":
+		if c.EncryptionKey == "" {
+			add("ENCRYPTION_KEY", SeverityError, "ENCRYPTION_KEY is required for the local secrets backend")
+		}
+	case "vault":
+		if c.VaultAddr == "" || c.VaultToken == "" || c.VaultTransitKey == "" {
+			add("VAULT_ADDR", SeverityError, "VAULT_ADDR, VAULT_TOKEN and VAULT_TRANSIT_KEY are required for the vault secrets backend")
+		} else if err := checkURL(c.VaultAddr); err != nil {
+			add("VAULT_ADDR", SeverityError, "VAULT_ADDR: %v", err)
+		}
+	case "kms":
+		if c.KMSKeyID == "" || c.AWSRegion == "" {
+			add("AWS_KMS_KEY_ID", SeverityError, "AWS_KMS_KEY_ID and AWS_REGION are required for the kms secrets backend")
+		}
+		if c.AWSAccessKeyID == "" || c.AWSSecretKey == "" {
+			add("AWS_ACCESS_KEY_ID", SeverityError, "AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required for the kms secrets backend")
+		}
+		if c.KMSEndpoint != "" {
+			if err := checkURL(c.KMSEndpoint); err != nil {
+				add("AWS_KMS_ENDPOINT", SeverityError, "AWS_KMS_ENDPOINT: %v", err)
+			}
+		}
+	default:
+		add("SECRETS_BACKEND", SeverityError, "SECRETS_BACKEND must be local, vault or kms, got %q", c.SecretsBackend)
+	}
+	if c.EncryptionKey != "" && len(c.EncryptionKey) != 32 {
+		add("ENCRYPTION_KEY", SeverityError, "ENCRYPTION_KEY must be exactly 32 bytes, got %d", len(c.EncryptionKey))
+	}
+
+	for i, token := range c.BotTokens() {
+		field := "TELEGRAM_BOT_TOKEN"
+		if i > 0 {
+			field = "TELEGRAM_BOT_TOKENS"
+		}
+		if !botTokenPattern.MatchString(token) {
+			add(field, SeverityWarning, "%s does not look like a bot token from @BotFather", field)
+		}
+	}
+
+	if c.WebhookEnabled() {
+		if err := checkURL(c.TelegramWebhookURL); err != nil {
+			add("TELEGRAM_WEBHOOK_URL", SeverityError, "TELEGRAM_WEBHOOK_URL: %v", err)
+		} else if !strings.HasPrefix(c.TelegramWebhookURL, "https://") {
+			add("TELEGRAM_WEBHOOK_URL", SeverityError, "TELEGRAM_WEBHOOK_URL must use https, Telegram does not send webhooks over plain http")
+		}
+		if c.TelegramWebhookSecret != "" && !webhookSecretPattern.MatchString(c.TelegramWebhookSecret) {
+			add("TELEGRAM_WEBHOOK_SECRET", SeverityError, "TELEGRAM_WEBHOOK_SECRET may contain only A-Z, a-z, 0-9, _ and -, up to 256 characters")
+		}
+		if c.ListenAddr == "" {
+			add("LISTEN_ADDR", SeverityError, "LISTEN_ADDR is required in webhook mode")
+		}
+	}
+
+	switch c.DatabaseDriver {
+	case "sqlite":
+	case "postgres":
+		if c.DatabaseURL == "" {
+			add("DATABASE_URL", SeverityError, "DATABASE_URL is required for the postgres driver")
+		}
+	default:
+		add("DATABASE_DRIVER", SeverityError, "DATABASE_DRIVER must be sqlite or postgres, got %q", c.DatabaseDriver)
+	}
+
+	if _, _, _, err := c.MaintenanceWindowBounds(); err != nil {
+		add("DB_MAINTENANCE_WINDOW", SeverityError, "%v", err)
+	}
+	if _, err := c.DigestTimeOfDay(); err != nil {
+		add("DIGEST_TIME", SeverityError, "%v", err)
+	}
+	if c.ReconnectDelay <= 0 {
+		add("IMAP_RECONNECT_DELAY", SeverityError, "IMAP_RECONNECT_DELAY must be positive, got %s", c.ReconnectDelay)
+	} else if c.ReconnectMaxDelay < c.ReconnectDelay {
+		add("IMAP_RECONNECT_MAX_DELAY", SeverityError, "IMAP_RECONNECT_MAX_DELAY must not be less than IMAP_RECONNECT_DELAY (%s)", c.ReconnectDelay)
+	}
+	if c.CircuitFailures < 0 {
+		add("IMAP_CIRCUIT_FAILURES", SeverityError, "IMAP_CIRCUIT_FAILURES must not be negative, got %d", c.CircuitFailures)
+	}
+	if c.CircuitFailures > 0 && c.CircuitCooldown <= 0 {
+		add("IMAP_CIRCUIT_COOLDOWN", SeverityError, "IMAP_CIRCUIT_COOLDOWN must be positive, got %s", c.CircuitCooldown)
+	}
+	if c.StateSyncInterval < 0 {
+		add("MAILBOX_SYNC_INTERVAL", SeverityError, "MAILBOX_SYNC_INTERVAL must not be negative, got %s", c.StateSyncInterval)
+	}
+
+	switch c.ArchiveBackend {
+	case "", "disk":
+	case "s3":
+		if c.ArchiveS3Endpoint == "" || c.ArchiveS3Bucket == "" {
+			add("ARCHIVE_S3_ENDPOINT", SeverityError, "ARCHIVE_S3_ENDPOINT and ARCHIVE_S3_BUCKET are required for the s3 archive")
+		} else if err := checkURL(c.ArchiveS3Endpoint); err != nil {
+			add("ARCHIVE_S3_ENDPOINT", SeverityError, "ARCHIVE_S3_ENDPOINT: %v", err)
+		}
+		if (c.ArchiveS3AccessKey == "") != (c.ArchiveS3SecretKey == "") {
+			add("ARCHIVE_S3_ACCESS_KEY", SeverityError, "ARCHIVE_S3_ACCESS_KEY and ARCHIVE_S3_SECRET_KEY must be set together")
+		}
+	default:
+		add("ARCHIVE_BACKEND", SeverityError, "ARCHIVE_BACKEND must be disk or s3, got %q", c.ArchiveBackend)
+	}
+
+	mailcowSet := 0
+	for _, v := range []string{c.MailcowURL, c.MailcowAPIKey, c.MailcowDomain} {
+		if v != "" {
+			mailcowSet++
+		}
+	}
+	if mailcowSet > 0 && mailcowSet < 3 {
+		add("MAILCOW_URL", SeverityWarning, "Mailcow integration is disabled: MAILCOW_URL, MAILCOW_API_KEY and MAILCOW_DOMAIN must all be set")
+	}
+	if c.MailcowURL != "" {
+		if err := checkURL(c.MailcowURL); err != nil {
+			add("MAILCOW_URL", SeverityError, "MAILCOW_URL: %v", err)
+		}
+	}
+
+	if c.InlineImages < 0 || c.InlineImages > 10 {
+		add("INLINE_IMAGES", SeverityError, "INLINE_IMAGES must be between 0 and 10, got %d", c.InlineImages)
+	}
+
+	if c.ShutdownTimeout < 0 {
+		add("SHUTDOWN_TIMEOUT", SeverityError, "SHUTDOWN_TIMEOUT must not be negative, got %s", c.ShutdownTimeout)
+	}
+
+	if c.ChatStorageQuota < 0 {
+		add("CHAT_STORAGE_QUOTA", SeverityError, "CHAT_STORAGE_QUOTA must not be negative, got %d", c.ChatStorageQuota)
+	}
+
+	if c.UpdateCheckInterval > 0 && !repoPattern.MatchString(c.UpdateCheckRepo) {
+		add("UPDATE_CHECK_REPO", SeverityError, "UPDATE_CHECK_REPO must look like owner/name, got %q", c.UpdateCheckRepo)
+	}
+
+	if c.UpdateCheckInterval > 0 && c.OwnerID == 0 {
+		add("OWNER_ID", SeverityWarning, "OWNER_ID is not set, so update notifications have no recipient")
+	}
+
+	switch c.LogLevel {
+	case "debug", "info", "warn", "error":
+	default:
+		add("LOG_LEVEL", SeverityWarning, "LOG_LEVEL must be debug, info, warn or error, got %q", c.LogLevel)
+	}
+	if c.LogFormat != "json" && c.LogFormat != "text" {
+		add("LOG_FORMAT", SeverityWarning, "LOG_FORMAT must be json or text, got %q", c.LogFormat)
+	}
+
+	return issues
+}
+
+// checkURL requires an absolute http(s) URL
+func checkURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("must start with http:// or https://, got %q", raw)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("has no host: %q", raw)
+	}
+	return nil
+}
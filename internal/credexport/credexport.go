@@ -0,0 +1,195 @@
+// Package credexport exports mailbox credentials encrypted to an operator's
+// public key. Encryption is delegated to the age or gpg command-line tools;
+// the plaintext is only ever piped to their stdin and never written to disk.
+package credexport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mixelka/emailresend/pkg/models"
+)
+
+// Kind of public key
+type Kind string
+
+const (
+	KindAge Kind = "age"
+	KindGPG Kind = "gpg"
+)
+
+// pgpKeyHeader starts an ASCII-armored OpenPGP public key
+const pgpKeyHeader = "-----BEGIN PGP PUBLIC KEY BLOCK-----"
+
+// Recipient is a public key the export is encrypted to
+type Recipient struct {
+	Kind Kind
+	Keys []string // age recipients, or a single armored OpenPGP key
+}
+
+// ParseRecipient parses age recipients (age1..., ssh-ed25519, ssh-rsa; one
+// per line) or an ASCII-armored OpenPGP public key
+func ParseRecipient(s string) (Recipient, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, pgpKeyHeader) {
+		return Recipient{Kind: KindGPG, Keys: []string{s}}, nil
+	}
+
+	var keys []string
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !strings.HasPrefix(line, "age1") && !strings.HasPrefix(line, "ssh-ed25519 ") && !strings.HasPrefix(line, "ssh-rsa ") {
+			return Recipient{}, fmt.Errorf("unsupported public key %q: expected an age recipient or an armored PGP public key", truncate(line, 24))
+		}
+		keys = append(keys, line)
+	}
+	if len(keys) == 0 {
+		return Recipient{}, errors.New("no public key given")
+	}
+	return Recipient{Kind: KindAge, Keys: keys}, nil
+}
+
+// Ext returns the file extension of exports encrypted to the recipient
+func (r Recipient) Ext() string {
+	if r.Kind == KindGPG {
+		return ".asc"
+	}
+	return ".age"
+}
+
+// Credential is a single exported account. Field names match the /import
+// JSON format so a decrypted export can be imported again.
+type Credential struct {
+	Email         string `json:"email"`
+	Password      string `json:"password"`
+	RefreshToken  string `json:"refresh_token,omitempty"`  // OAuth accounts
+	OAuthProvider string `json:"oauth_provider,omitempty"` // "google" or "microsoft"
+	IMAPServer    string `json:"imap_server"`
+	ChatID        int64  `json:"chat_id"`
+	TopicID       int    `json:"topic_id"`
+	BotID         int64  `json:"bot_id,omitempty"`
+	Active        bool   `json:"active"`
+}
+
+// Export is the plaintext document that gets encrypted
+type Export struct {
+	CreatedAt time.Time    `json:"created_at"`
+	Accounts  []Credential `json:"accounts"`
+}
+
+// Build decrypts the stored passwords of accounts, and the refresh tokens of
+// OAuth accounts, into an export. oauthToken returns the token of an account
+// or nil if it has none.
+func Build(accounts []*models.EmailAccount, oauthToken func(accountID int64) (*models.OAuthToken, error), decrypt func(string) (string, error)) (*Export, error) {
+	export := &Export{CreatedAt: time.Now().UTC(), Accounts: make([]Credential, 0, len(accounts))}
+	for _, account := range accounts {
+		password, err := decrypt(account.Password)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt password of %s: %w", account.Email, err)
+		}
+		cred := Credential{
+			Email:      account.Email,
+			Password:   password,
+			IMAPServer: account.IMAPServer,
+			ChatID:     account.ChatID,
+			TopicID:    account.TopicID,
+			BotID:      account.BotID,
+			Active:     account.IsActive,
+		}
+
+		token, err := oauthToken(account.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get OAuth token of %s: %w", account.Email, err)
+		}
+		if token != nil {
+			if cred.RefreshToken, err = decrypt(token.RefreshToken); err != nil {
+				return nil, fmt.Errorf("failed to decrypt refresh token of %s: %w", account.Email, err)
+			}
+			cred.OAuthProvider = token.Provider
+		}
+		export.Accounts = append(export.Accounts, cred)
+	}
+	return export, nil
+}
+
+// Encrypt encrypts the export to the recipient and returns the ASCII-armored
+// ciphertext
+func Encrypt(ctx context.Context, r Recipient, export *Export) ([]byte, error) {
+	plaintext, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode export: %w", err)
+	}
+	defer clear(plaintext)
+
+	switch r.Kind {
+	case KindAge:
+		args := []string{"--encrypt", "--armor"}
+		for _, key := range r.Keys {
+			args = append(args, "--recipient", key)
+		}
+		return run(ctx, "age", args, plaintext)
+	case KindGPG:
+		return encryptGPG(ctx, r.Keys[0], plaintext)
+	default:
+		return nil, fmt.Errorf("unknown recipient kind %q", r.Kind)
+	}
+}
+
+// encryptGPG encrypts with a throwaway GnuPG home so the host keyring is
+// neither used nor modified. Only the public key is written to it.
+func encryptGPG(ctx context.Context, key string, plaintext []byte) ([]byte, error) {
+	home, err := os.MkdirTemp("", "credexport-gnupg-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gnupg home: %w", err)
+	}
+	defer os.RemoveAll(home)
+
+	keyFile := filepath.Join(home, "recipient.asc")
+	if err := os.WriteFile(keyFile, []byte(key), 0o600); err != nil {
+		return nil, fmt.Errorf("failed to write public key: %w", err)
+	}
+
+	return run(ctx, "gpg", []string{
+		"--homedir", home,
+		"--batch", "--no-tty", "--quiet",
+		"--armor",
+		"--recipient-file", keyFile,
+		"--encrypt",
+	}, plaintext)
+}
+
+// run pipes stdin through an external command and returns its stdout
+func run(ctx context.Context, name string, args []string, stdin []byte) ([]byte, error) {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not installed: %w", name, err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s failed: %w: %s", name, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "…"
+}
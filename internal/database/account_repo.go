@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"math"
 	"time"
 
 	"github.com/mixelka/emailresend/pkg/models"
@@ -16,32 +17,39 @@ var ErrNotFound = errors.New("record not found")
 // ErrAlreadyExists is returned when trying to insert a duplicate record
 var ErrAlreadyExists = errors.New("record already exists")
 
-// CreateAccount creates a new email account
+// CreateAccount creates a new email account. Returns ErrAlreadyExists if the
+// topic has an account or the chat has one with the same email, whatever
+// bot serves it.
 func (db *DB) CreateAccount(ctx context.Context, account *models.EmailAccount) error {
 	query := `
-		INSERT INTO email_accounts (email, password, imap_server, chat_id, topic_id, is_active, last_uid, created_by, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO email_accounts (email, password, imap_server, smtp_server, chat_id, topic_id, is_active, last_uid, created_by, bot_id, provisioned, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT DO NOTHING
+		RETURNING id
 	`
 	now := time.Now()
-	result, err := db.ExecContext(ctx, query,
+	var id int64
+	err := db.GetContext(ctx, &id, query,
 		account.Email,
 		account.Password,
 		account.IMAPServer,
+		account.SMTPServer,
 		account.ChatID,
 		account.TopicID,
 		account.IsActive,
 		account.LastUID,
 		account.CreatedBy,
+		account.BotID,
+		account.Provisioned,
 		now,
 		now,
 	)
-	if err != nil {
-		return fmt.Errorf("failed to create account: %w", err)
+	// No row is returned if the insert was ignored as a duplicate
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrAlreadyExists
 	}
-
-	id, err := result.LastInsertId()
 	if err != nil {
-		return fmt.Errorf("failed to get last insert id: %w", err)
+		return fmt.Errorf("failed to create account: %w", err)
 	}
 
 	account.ID = id
@@ -64,8 +72,23 @@ func (db *DB) GetAccountByID(ctx context.Context, id int64) (*models.EmailAccoun
 	return &account, nil
 }
 
-// GetAccountByChatAndTopic returns an account by chat ID and topic ID
-func (db *DB) GetAccountByChatAndTopic(ctx context.Context, chatID int64, topicID int) (*models.EmailAccount, error) {
+// GetAccountByChatAndTopic returns the account of a bot by chat ID and topic ID
+func (db *DB) GetAccountByChatAndTopic(ctx context.Context, chatID int64, topicID int, botID int64) (*models.EmailAccount, error) {
+	var account models.EmailAccount
+	query := `SELECT * FROM email_accounts WHERE chat_id = ? AND topic_id = ? AND bot_id = ?`
+	err := db.GetContext(ctx, &account, query, chatID, topicID, botID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account: %w", err)
+	}
+	return &account, nil
+}
+
+// GetTopicAccount returns the account in a chat topic whatever bot serves
+// it: a topic holds a single account of all bots
+func (db *DB) GetTopicAccount(ctx context.Context, chatID int64, topicID int) (*models.EmailAccount, error) {
 	var account models.EmailAccount
 	query := `SELECT * FROM email_accounts WHERE chat_id = ? AND topic_id = ?`
 	err := db.GetContext(ctx, &account, query, chatID, topicID)
@@ -78,11 +101,33 @@ func (db *DB) GetAccountByChatAndTopic(ctx context.Context, chatID int64, topicI
 	return &account, nil
 }
 
-// GetAccountsByChatID returns all accounts for a chat
-func (db *DB) GetAccountsByChatID(ctx context.Context, chatID int64) ([]*models.EmailAccount, error) {
+// GetAccountsByChatID returns a page of accounts for a chat, newest first
+func (db *DB) GetAccountsByChatID(ctx context.Context, chatID int64, page Page) ([]*models.EmailAccount, error) {
+	page = page.normalize()
+	var accounts []*models.EmailAccount
+	query := `SELECT * FROM email_accounts WHERE chat_id = ? ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?`
+	err := db.SelectContext(ctx, &accounts, query, chatID, page.Limit, page.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get accounts: %w", err)
+	}
+	return accounts, nil
+}
+
+// CountAccountsByChatID returns the number of accounts in a chat
+func (db *DB) CountAccountsByChatID(ctx context.Context, chatID int64) (int, error) {
+	var count int
+	err := db.GetContext(ctx, &count, `SELECT COUNT(*) FROM email_accounts WHERE chat_id = ?`, chatID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count accounts: %w", err)
+	}
+	return count, nil
+}
+
+// GetAllAccounts returns all accounts, including inactive ones
+func (db *DB) GetAllAccounts(ctx context.Context) ([]*models.EmailAccount, error) {
 	var accounts []*models.EmailAccount
-	query := `SELECT * FROM email_accounts WHERE chat_id = ? ORDER BY created_at DESC`
-	err := db.SelectContext(ctx, &accounts, query, chatID)
+	query := `SELECT * FROM email_accounts ORDER BY chat_id, topic_id`
+	err := db.SelectContext(ctx, &accounts, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get accounts: %w", err)
 	}
@@ -100,16 +145,174 @@ func (db *DB) GetAllActiveAccounts(ctx context.Context) ([]*models.EmailAccount,
 	return accounts, nil
 }
 
-// UpdateAccountLastUID updates the last processed UID
-func (db *DB) UpdateAccountLastUID(ctx context.Context, id int64, uid uint32) error {
-	query := `UPDATE email_accounts SET last_uid = ?, updated_at = ? WHERE id = ?`
-	_, err := db.ExecContext(ctx, query, uid, time.Now(), id)
+// GetBroadcastTargets returns active accounts of a bot in chats that did not
+// opt out of announcements, ordered by chat
+func (db *DB) GetBroadcastTargets(ctx context.Context, botID int64) ([]*models.EmailAccount, error) {
+	var accounts []*models.EmailAccount
+	query := `
+		SELECT a.* FROM email_accounts a
+		LEFT JOIN chat_settings s ON s.chat_id = a.chat_id
+		WHERE a.is_active = true AND a.bot_id = ? AND COALESCE(s.broadcast_opt_out, false) = false
+		ORDER BY a.chat_id, a.topic_id
+	`
+	err := db.SelectContext(ctx, &accounts, query, botID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get broadcast targets: %w", err)
+	}
+	return accounts, nil
+}
+
+// retiredUID is the UID messages from before a UIDVALIDITY change are moved
+// to, minus their row ID: unique, and far above the UIDs a fresh mailbox
+// hands out
+const retiredUID = math.MaxUint32
+
+// GetAccountUIDState returns the last processed UID of an account and the
+// UIDVALIDITY it belongs to
+func (db *DB) GetAccountUIDState(ctx context.Context, id int64) (lastUID, uidValidity uint32, err error) {
+	var state struct {
+		LastUID     uint32 `db:"last_uid"`
+		UIDValidity uint32 `db:"uid_validity"`
+	}
+	query := `SELECT COALESCE(last_uid, 0) AS last_uid, uid_validity FROM email_accounts WHERE id = ?`
+	if err := db.GetContext(ctx, &state, query, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, 0, ErrNotFound
+		}
+		return 0, 0, fmt.Errorf("failed to get uid state: %w", err)
+	}
+	return state.LastUID, state.UIDValidity, nil
+}
+
+// UpdateAccountUIDState records the last processed UID of an account. Within
+// one UIDVALIDITY the UID only moves forward. When UIDVALIDITY changes, the
+// stored messages' UIDs no longer address anything on the server, so they are
+// retired in the same transaction to make room for the new ones.
+func (db *DB) UpdateAccountUIDState(ctx context.Context, id int64, lastUID, uidValidity uint32) error {
+	tx, err := db.BeginTxx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to update last uid: %w", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var stored uint32
+	if err := tx.GetContext(ctx, &stored, `SELECT uid_validity FROM email_accounts WHERE id = ?`, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to get uid validity: %w", err)
+	}
+
+	if stored != 0 && stored != uidValidity {
+		query := `UPDATE email_messages SET uid = ? - id WHERE account_id = ? AND uid <> ? - id`
+		if _, err := tx.ExecContext(ctx, query, int64(retiredUID), id, int64(retiredUID)); err != nil {
+			return fmt.Errorf("failed to retire message uids: %w", err)
+		}
+	}
+
+	query := `
+		UPDATE email_accounts SET last_uid = ?, uid_validity = ?, updated_at = ?
+		WHERE id = ? AND (uid_validity <> ? OR COALESCE(last_uid, 0) < ?)
+	`
+	if _, err := tx.ExecContext(ctx, query, lastUID, uidValidity, time.Now(), id, uidValidity, lastUID); err != nil {
+		return fmt.Errorf("failed to update uid state: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit uid state: %w", err)
 	}
 	return nil
 }
 
+// UpdateAccountPassword replaces the encrypted password of an account
+func (db *DB) UpdateAccountPassword(ctx context.Context, id int64, password string) error {
+	query := `UPDATE email_accounts SET password = ?, updated_at = ? WHERE id = ?`
+	_, err := db.ExecContext(ctx, query, password, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to update account password: %w", err)
+	}
+	return nil
+}
+
+// UpdateAccountSMTPServer sets the SMTP server replies are sent through
+func (db *DB) UpdateAccountSMTPServer(ctx context.Context, id int64, server string) error {
+	query := `UPDATE email_accounts SET smtp_server = ?, updated_at = ? WHERE id = ?`
+	_, err := db.ExecContext(ctx, query, server, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to update smtp server: %w", err)
+	}
+	return nil
+}
+
+// UpdateAccountSettings updates per-account delivery settings
+func (db *DB) UpdateAccountSettings(ctx context.Context, account *models.EmailAccount) error {
+	query := `
+		UPDATE email_accounts SET
+			silent = ?,
+			priority_pattern = ?,
+			protect_content = ?,
+			collapse_window = ?,
+			format_profile = ?,
+			message_template = ?,
+			idle_mode = ?,
+			poll_interval = ?,
+			idle_timeout = ?,
+			spam_topic_id = ?,
+			pin_codes = ?,
+			bulk_mode = ?,
+			bulk_topic_id = ?,
+			digest_mode = ?,
+			quiet_hours = ?,
+			quiet_tz = ?,
+			quiet_mode = ?,
+			avatars = ?,
+			unread_badge = ?,
+			topic_name = ?,
+			updated_at = ?
+		WHERE id = ?
+	`
+	_, err := db.ExecContext(ctx, query,
+		account.Silent,
+		account.PriorityPattern,
+		account.ProtectContent,
+		account.CollapseWindow,
+		account.FormatProfile,
+		account.MessageTemplate,
+		account.IdleMode,
+		account.PollInterval,
+		account.IdleTimeout,
+		account.SpamTopicID,
+		account.PinCodes,
+		account.BulkMode,
+		account.BulkTopicID,
+		account.DigestMode,
+		account.QuietHours,
+		account.QuietTimezone,
+		account.QuietMode,
+		account.Avatars,
+		account.UnreadBadge,
+		account.TopicName,
+		time.Now(),
+		account.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update account settings: %w", err)
+	}
+	return nil
+}
+
+// GetUnreadBadgeAccounts returns the accounts of a bot whose topic name
+// shows the unread count
+func (db *DB) GetUnreadBadgeAccounts(ctx context.Context, botID int64) ([]*models.EmailAccount, error) {
+	var accounts []*models.EmailAccount
+	query := `SELECT * FROM email_accounts WHERE unread_badge = true AND topic_id != 0 AND bot_id = ?`
+	err := db.SelectContext(ctx, &accounts, query, botID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get unread badge accounts: %w", err)
+	}
+	return accounts, nil
+}
+
 // SetAccountActive sets the active status of an account
 func (db *DB) SetAccountActive(ctx context.Context, id int64, active bool) error {
 	query := `UPDATE email_accounts SET is_active = ?, updated_at = ? WHERE id = ?`
@@ -129,3 +332,101 @@ func (db *DB) DeleteAccount(ctx context.Context, id int64) error {
 	}
 	return nil
 }
+
+// MigrateChat moves accounts, settings and chat-bound records of a group
+// to the supergroup it was upgraded to (Telegram gives it a new chat ID)
+func (db *DB) MigrateChat(ctx context.Context, fromChatID, toChatID int64) error {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{"email_accounts", "chat_settings", "message_codes", "orders", "posted_messages", "account_mirrors", "pinned_codes"} {
+		query := `UPDATE ` + table + ` SET chat_id = ? WHERE chat_id = ?`
+		if _, err := tx.ExecContext(ctx, query, toChatID, fromChatID); err != nil {
+			return fmt.Errorf("failed to migrate %s: %w", table, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit chat migration: %w", err)
+	}
+	return nil
+}
+
+// MoveAccount binds an account to another topic, possibly of another chat,
+// keeping its emails. Returns ErrAlreadyExists if the topic has an account
+// or the chat has another account with the same email.
+//
+// Emails posted in another chat stay there: on a move between chats their
+// Telegram message IDs are dropped, so they are no longer edited, and codes
+// and orders follow the account to the new chat. Spam and bulk topics of
+// the old chat are unset, bulk emails in the topic mode are posted again.
+func (db *DB) MoveAccount(ctx context.Context, account *models.EmailAccount, chatID int64, topicID int) error {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var conflicts int
+	query := `SELECT COUNT(*) FROM email_accounts WHERE id != ? AND chat_id = ? AND (topic_id = ? OR email = ?)`
+	if err := tx.GetContext(ctx, &conflicts, query, account.ID, chatID, topicID, account.Email); err != nil {
+		return fmt.Errorf("failed to check target topic: %w", err)
+	}
+	if conflicts > 0 {
+		return ErrAlreadyExists
+	}
+
+	now := time.Now()
+	query = `UPDATE email_accounts SET chat_id = ?, topic_id = ?, updated_at = ? WHERE id = ?`
+	if _, err := tx.ExecContext(ctx, query, chatID, topicID, now, account.ID); err != nil {
+		return fmt.Errorf("failed to move account: %w", err)
+	}
+
+	// The collapsed message stays in the old topic
+	if _, err := tx.ExecContext(ctx, `DELETE FROM collapsed_groups WHERE account_id = ?`, account.ID); err != nil {
+		return fmt.Errorf("failed to reset collapsed groups: %w", err)
+	}
+
+	if chatID != account.ChatID {
+		for _, table := range []string{"message_codes", "orders"} {
+			query := `UPDATE ` + table + ` SET chat_id = ? WHERE account_id = ?`
+			if _, err := tx.ExecContext(ctx, query, chatID, account.ID); err != nil {
+				return fmt.Errorf("failed to move %s: %w", table, err)
+			}
+		}
+		query = `UPDATE email_messages SET telegram_msg_id = 0 WHERE account_id = ? AND telegram_msg_id != 0`
+		if _, err := tx.ExecContext(ctx, query, account.ID); err != nil {
+			return fmt.Errorf("failed to reset telegram message ids: %w", err)
+		}
+		// A mirror to the chat the account is now in would post everything twice
+		query = `DELETE FROM account_mirrors WHERE account_id = ? AND chat_id = ?`
+		if _, err := tx.ExecContext(ctx, query, account.ID, chatID); err != nil {
+			return fmt.Errorf("failed to delete mirrors: %w", err)
+		}
+		// Spam and bulk topics are topics of the old chat
+		query = `UPDATE email_accounts SET spam_topic_id = 0, bulk_topic_id = 0,
+			bulk_mode = CASE WHEN bulk_mode = ? THEN ? ELSE bulk_mode END WHERE id = ?`
+		if _, err := tx.ExecContext(ctx, query, models.BulkTopic, models.BulkDeliver, account.ID); err != nil {
+			return fmt.Errorf("failed to reset spam and bulk topics: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit account move: %w", err)
+	}
+
+	if chatID != account.ChatID {
+		account.SpamTopicID = 0
+		account.BulkTopicID = 0
+		if account.BulkMode == models.BulkTopic {
+			account.BulkMode = models.BulkDeliver
+		}
+	}
+	account.ChatID = chatID
+	account.TopicID = topicID
+	account.UpdatedAt = now
+	return nil
+}
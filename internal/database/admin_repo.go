@@ -0,0 +1,58 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mixelka/emailresend/pkg/models"
+)
+
+// GrantBotAdmin lets a user manage the bot in a chat; granting again
+// updates the stored name
+func (db *DB) GrantBotAdmin(ctx context.Context, admin *models.BotAdmin) error {
+	query := `
+		INSERT INTO bot_admins (chat_id, user_id, name, granted_by, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (chat_id, user_id) DO UPDATE SET name = excluded.name
+	`
+	_, err := db.ExecContext(ctx, query, admin.ChatID, admin.UserID, admin.Name, admin.GrantedBy, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to grant bot admin: %w", err)
+	}
+	return nil
+}
+
+// RevokeBotAdmin takes bot management in a chat away from a user. Returns
+// ErrNotFound if it was not granted.
+func (db *DB) RevokeBotAdmin(ctx context.Context, chatID, userID int64) error {
+	res, err := db.ExecContext(ctx, `DELETE FROM bot_admins WHERE chat_id = ? AND user_id = ?`, chatID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke bot admin: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// IsBotAdmin reports whether a user was granted bot management in a chat
+func (db *DB) IsBotAdmin(ctx context.Context, chatID, userID int64) (bool, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM bot_admins WHERE chat_id = ? AND user_id = ?`
+	if err := db.GetContext(ctx, &count, query, chatID, userID); err != nil {
+		return false, fmt.Errorf("failed to check bot admin: %w", err)
+	}
+	return count > 0, nil
+}
+
+// GetBotAdmins returns the users granted bot management in a chat, oldest
+// grant first
+func (db *DB) GetBotAdmins(ctx context.Context, chatID int64) ([]models.BotAdmin, error) {
+	var admins []models.BotAdmin
+	query := `SELECT * FROM bot_admins WHERE chat_id = ? ORDER BY created_at, user_id`
+	if err := db.SelectContext(ctx, &admins, query, chatID); err != nil {
+		return nil, fmt.Errorf("failed to get bot admins: %w", err)
+	}
+	return admins, nil
+}
@@ -0,0 +1,104 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/mixelka/emailresend/pkg/models"
+)
+
+// SaveMessageCodes indexes the codes detected in a message for cross-message
+// lookups, replacing codes indexed for it before
+func (db *DB) SaveMessageCodes(ctx context.Context, msg *models.EmailMessage, chatID int64, codes []models.DetectedCode) error {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM message_codes WHERE message_id = ?`, msg.ID); err != nil {
+		return fmt.Errorf("failed to clear message codes: %w", err)
+	}
+
+	query := `
+		INSERT INTO message_codes (message_id, account_id, chat_id, value, type, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+	for _, code := range codes {
+		if _, err := tx.ExecContext(ctx, query, msg.ID, msg.AccountID, chatID, code.Value, code.Type, msg.CreatedAt); err != nil {
+			return fmt.Errorf("failed to save message code: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit message codes: %w", err)
+	}
+	return nil
+}
+
+// CountCodeReuse returns how many other messages in a chat contained any of
+// the given code values since the given time
+func (db *DB) CountCodeReuse(ctx context.Context, chatID, messageID int64, values []string, since time.Time) (int, error) {
+	if len(values) == 0 {
+		return 0, nil
+	}
+
+	query, args, err := sqlx.In(`
+		SELECT COUNT(DISTINCT message_id) FROM message_codes
+		WHERE chat_id = ? AND message_id != ? AND created_at >= ? AND value IN (?)
+	`, chatID, messageID, since, values)
+	if err != nil {
+		return 0, fmt.Errorf("failed to build code reuse query: %w", err)
+	}
+
+	var count int
+	if err := db.GetContext(ctx, &count, db.Rebind(query), args...); err != nil {
+		return 0, fmt.Errorf("failed to count code reuse: %w", err)
+	}
+	return count, nil
+}
+
+// CreateCodePattern adds a custom code pattern to an account
+func (db *DB) CreateCodePattern(ctx context.Context, pattern *models.CodePattern) error {
+	query := `
+		INSERT INTO code_patterns (account_id, pattern, created_by, created_at)
+		VALUES (?, ?, ?, ?)
+		RETURNING id
+	`
+	now := time.Now()
+	var id int64
+	if err := db.GetContext(ctx, &id, query, pattern.AccountID, pattern.Pattern, pattern.CreatedBy, now); err != nil {
+		return fmt.Errorf("failed to create code pattern: %w", err)
+	}
+
+	pattern.ID = id
+	pattern.CreatedAt = now
+	return nil
+}
+
+// GetCodePatterns returns the custom code patterns of an account in creation
+// order
+func (db *DB) GetCodePatterns(ctx context.Context, accountID int64) ([]*models.CodePattern, error) {
+	var patterns []*models.CodePattern
+	query := `SELECT * FROM code_patterns WHERE account_id = ? ORDER BY id`
+	if err := db.SelectContext(ctx, &patterns, query, accountID); err != nil {
+		return nil, fmt.Errorf("failed to get code patterns: %w", err)
+	}
+	return patterns, nil
+}
+
+// DeleteCodePattern removes a custom code pattern of an account
+func (db *DB) DeleteCodePattern(ctx context.Context, accountID, patternID int64) error {
+	query := `DELETE FROM code_patterns WHERE id = ? AND account_id = ?`
+	res, err := db.ExecContext(ctx, query, patternID, accountID)
+	if err != nil {
+		return fmt.Errorf("failed to delete code pattern: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
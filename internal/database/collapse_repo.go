@@ -0,0 +1,49 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/mixelka/emailresend/pkg/models"
+)
+
+// GetCollapsedGroup returns the collapse group for an account and subject key
+func (db *DB) GetCollapsedGroup(ctx context.Context, accountID int64, subjectKey string) (*models.CollapsedGroup, error) {
+	var group models.CollapsedGroup
+	query := `SELECT * FROM collapsed_groups WHERE account_id = ? AND subject_key = ?`
+	err := db.GetContext(ctx, &group, query, accountID, subjectKey)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get collapsed group: %w", err)
+	}
+	return &group, nil
+}
+
+// SaveCollapsedGroup creates or replaces a collapse group
+func (db *DB) SaveCollapsedGroup(ctx context.Context, group *models.CollapsedGroup) error {
+	query := `
+		INSERT INTO collapsed_groups (account_id, subject_key, telegram_msg_id, count, first_at, last_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(account_id, subject_key) DO UPDATE SET
+			telegram_msg_id = excluded.telegram_msg_id,
+			count = excluded.count,
+			first_at = excluded.first_at,
+			last_at = excluded.last_at
+	`
+	_, err := db.ExecContext(ctx, query,
+		group.AccountID,
+		group.SubjectKey,
+		group.TelegramMsgID,
+		group.Count,
+		group.FirstAt,
+		group.LastAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save collapsed group: %w", err)
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -10,12 +11,34 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 )
 
-// DB wraps sqlx.DB
+// Drivers supported by Open
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+)
+
+// DB wraps sqlx.DB. Queries are written with ? placeholders and rebound for
+// the driver; statements that differ between databases go through dialect.
 type DB struct {
 	*sqlx.DB
+	dialect  dialect
+	fullText bool // email_messages has a full-text index, set by Migrate
+}
+
+// Open connects to the database of the given driver. source is a file path
+// for SQLite and a connection URL for Postgres.
+func Open(driver, source string) (*DB, error) {
+	switch driver {
+	case DriverSQLite, "":
+		return New(source)
+	case DriverPostgres:
+		return newPostgres(source)
+	default:
+		return nil, fmt.Errorf("unknown database driver %q", driver)
+	}
 }
 
-// New creates a new database connection
+// New creates a new SQLite database connection
 func New(path string) (*DB, error) {
 	// Ensure directory exists
 	dir := filepath.Dir(path)
@@ -30,14 +53,104 @@ func New(path string) (*DB, error) {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
-	return &DB{db}, nil
+	return &DB{DB: db, dialect: sqliteDialect{path: path}}, nil
+}
+
+// Driver returns the driver the database was opened with
+func (db *DB) Driver() string {
+	return db.dialect.name()
+}
+
+// ExecContext executes a query after rebinding its placeholders
+func (db *DB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return db.DB.ExecContext(ctx, db.Rebind(query), args...)
+}
+
+// GetContext gets a single row after rebinding the query's placeholders
+func (db *DB) GetContext(ctx context.Context, dest any, query string, args ...any) error {
+	return db.DB.GetContext(ctx, dest, db.Rebind(query), args...)
+}
+
+// SelectContext selects rows after rebinding the query's placeholders
+func (db *DB) SelectContext(ctx context.Context, dest any, query string, args ...any) error {
+	return db.DB.SelectContext(ctx, dest, db.Rebind(query), args...)
+}
+
+// BeginTxx starts a transaction that rebinds placeholders like DB
+func (db *DB) BeginTxx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
+	tx, err := db.DB.BeginTxx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Tx: tx}, nil
+}
+
+// Tx wraps sqlx.Tx, rebinding placeholders like DB
+type Tx struct {
+	*sqlx.Tx
 }
 
-// Migrate runs database migrations
+// ExecContext executes a query after rebinding its placeholders
+func (tx *Tx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return tx.Tx.ExecContext(ctx, tx.Rebind(query), args...)
+}
+
+// GetContext gets a single row after rebinding the query's placeholders
+func (tx *Tx) GetContext(ctx context.Context, dest any, query string, args ...any) error {
+	return tx.Tx.GetContext(ctx, dest, tx.Rebind(query), args...)
+}
+
+// SelectContext selects rows after rebinding the query's placeholders
+func (tx *Tx) SelectContext(ctx context.Context, dest any, query string, args ...any) error {
+	return tx.Tx.SelectContext(ctx, dest, tx.Rebind(query), args...)
+}
+
+// Migrate runs database migrations. Concurrent instances sharing a database
+// wait for each other, so each migration runs once.
 func (db *DB) Migrate(ctx context.Context) error {
-	_, err := db.ExecContext(ctx, schema)
+	conn, err := db.Connx(ctx)
 	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	unlock, err := db.dialect.lockMigrations(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("failed to lock migrations: %w", err)
+	}
+	defer unlock()
+
+	if _, err := conn.ExecContext(ctx, db.dialect.ddl(schema)); err != nil {
 		return fmt.Errorf("failed to run migrations: %w", err)
 	}
+
+	// Apply incremental migrations tracked by the dialect's schema version
+	version, err := db.dialect.schemaVersion(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("failed to get schema version: %w", err)
+	}
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := conn.BeginTxx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("failed to begin migration %d: %w", i+1, err)
+		}
+		if _, err := tx.ExecContext(ctx, db.dialect.ddl(migrations[i])); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to run migration %d: %w", i+1, err)
+		}
+		if err := db.dialect.setSchemaVersion(ctx, tx, i+1); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to set schema version %d: %w", i+1, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit migration %d: %w", i+1, err)
+		}
+	}
+
+	db.fullText, err = db.dialect.setupSearch(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("failed to set up search index: %w", err)
+	}
 	return nil
 }
@@ -0,0 +1,84 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// dialect holds what differs between the databases the repository runs on.
+// Schema and migrations are written for SQLite and translated by ddl.
+type dialect interface {
+	// name is the driver name accepted by Open
+	name() string
+	// ddl translates a schema statement written for SQLite
+	ddl(stmt string) string
+
+	// lockMigrations serializes Migrate between instances sharing the database
+	lockMigrations(ctx context.Context, conn *sqlx.Conn) (unlock func(), err error)
+	// schemaVersion returns the number of applied migrations
+	schemaVersion(ctx context.Context, conn *sqlx.Conn) (int, error)
+	// setSchemaVersion records the number of applied migrations in tx
+	setSchemaVersion(ctx context.Context, tx *sqlx.Tx, version int) error
+
+	// jsonArrayLength returns an expression for the length of the JSON
+	// array in column, 0 if it does not hold one
+	jsonArrayLength(column string) string
+	// likeNoCase is the case-insensitive LIKE operator
+	likeNoCase() string
+	// byteLength returns an expression for the size of a text column in
+	// bytes, 0 if it is NULL
+	byteLength(column string) string
+
+	// setupSearch creates the full-text index of email_messages. Returns
+	// false if the database has no full-text search.
+	setupSearch(ctx context.Context, conn *sqlx.Conn) (bool, error)
+	// searchMessages returns a query for the messages of an account matching
+	// all terms, newest first, with a snippet marked by SnippetStart and
+	// SnippetEnd
+	searchMessages(accountID int64, terms []string, limit int) (string, []any)
+
+	// maintain reclaims space and refreshes planner statistics
+	maintain(ctx context.Context, conn *sqlx.Conn, stats *MaintenanceStats) error
+	// diskSize returns the size of the database in bytes, 0 if unknown
+	diskSize(ctx context.Context, db *sqlx.DB) int64
+}
+
+// sqliteDialect is the default, single-instance database in a local file
+type sqliteDialect struct {
+	path string
+}
+
+func (sqliteDialect) name() string { return DriverSQLite }
+
+func (sqliteDialect) ddl(stmt string) string { return stmt }
+
+// lockMigrations is a no-op: a SQLite file is used by a single instance
+func (sqliteDialect) lockMigrations(context.Context, *sqlx.Conn) (func(), error) {
+	return func() {}, nil
+}
+
+// schemaVersion is tracked by SQLite user_version
+func (sqliteDialect) schemaVersion(ctx context.Context, conn *sqlx.Conn) (int, error) {
+	var version int
+	err := conn.GetContext(ctx, &version, `PRAGMA user_version`)
+	return version, err
+}
+
+func (sqliteDialect) setSchemaVersion(ctx context.Context, tx *sqlx.Tx, version int) error {
+	_, err := tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", version))
+	return err
+}
+
+func (sqliteDialect) jsonArrayLength(column string) string {
+	return fmt.Sprintf("CASE WHEN json_valid(%[1]s) THEN json_array_length(%[1]s) ELSE 0 END", column)
+}
+
+// likeNoCase is plain LIKE, which ignores ASCII case in SQLite
+func (sqliteDialect) likeNoCase() string { return "LIKE" }
+
+// byteLength casts to BLOB since LENGTH counts characters of text
+func (sqliteDialect) byteLength(column string) string {
+	return fmt.Sprintf("COALESCE(LENGTH(CAST(%s AS BLOB)), 0)", column)
+}
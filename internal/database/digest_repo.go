@@ -0,0 +1,148 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mixelka/emailresend/pkg/models"
+)
+
+// CountSenderMessages returns the number of messages from a sender stored for
+// an account since the given time, up to and including messageID
+func (db *DB) CountSenderMessages(ctx context.Context, accountID int64, fromAddr string, since time.Time, messageID int64) (int, error) {
+	var count int
+	query := `
+		SELECT COUNT(*) FROM email_messages
+		WHERE account_id = ? AND from_addr = ? AND created_at >= ? AND id <= ? AND duplicate_of = 0
+	`
+	err := db.GetContext(ctx, &count, query, accountID, fromAddr, since, messageID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count sender messages: %w", err)
+	}
+	return count, nil
+}
+
+// AddToSenderDigest records a held-back email and returns the digest size
+func (db *DB) AddToSenderDigest(ctx context.Context, accountID int64, fromAddr string, hourStart time.Time) (int, error) {
+	var count int
+	query := `
+		INSERT INTO sender_digests (account_id, from_addr, hour_start, count, created_at)
+		VALUES (?, ?, ?, 1, ?)
+		ON CONFLICT(account_id, from_addr, hour_start) DO UPDATE SET count = sender_digests.count + 1
+		RETURNING count
+	`
+	err := db.GetContext(ctx, &count, query, accountID, fromAddr, hourStart, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to add to sender digest: %w", err)
+	}
+	return count, nil
+}
+
+// GetDueSenderDigests returns digests of accounts served by botID whose hour
+// started before the given time
+func (db *DB) GetDueSenderDigests(ctx context.Context, botID int64, before time.Time) ([]*models.SenderDigest, error) {
+	var digests []*models.SenderDigest
+	query := `
+		SELECT d.* FROM sender_digests d
+		JOIN email_accounts a ON a.id = d.account_id
+		WHERE d.hour_start < ? AND a.bot_id = ?
+		ORDER BY d.hour_start
+	`
+	err := db.SelectContext(ctx, &digests, query, before, botID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sender digests: %w", err)
+	}
+	return digests, nil
+}
+
+// GetUndeliveredSenderMessages returns emails from a sender stored in
+// [since, until) that were not sent to Telegram, oldest first
+func (db *DB) GetUndeliveredSenderMessages(ctx context.Context, accountID int64, fromAddr string, since, until time.Time, limit int) ([]*models.EmailMessage, error) {
+	var messages []*models.EmailMessage
+	query := `
+		SELECT * FROM email_messages
+		WHERE account_id = ? AND from_addr = ? AND created_at >= ? AND created_at < ?
+			AND COALESCE(telegram_msg_id, 0) = 0 AND duplicate_of = 0
+		ORDER BY id LIMIT ?
+	`
+	err := db.SelectContext(ctx, &messages, query, accountID, fromAddr, since, until, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get undelivered sender messages: %w", err)
+	}
+	return messages, nil
+}
+
+// DeleteSenderDigest removes a delivered digest
+func (db *DB) DeleteSenderDigest(ctx context.Context, digest *models.SenderDigest) error {
+	query := `DELETE FROM sender_digests WHERE account_id = ? AND from_addr = ? AND hour_start = ?`
+	_, err := db.ExecContext(ctx, query, digest.AccountID, digest.FromAddr, digest.HourStart)
+	if err != nil {
+		return fmt.Errorf("failed to delete sender digest: %w", err)
+	}
+	return nil
+}
+
+// AddDigestItem holds back an email for the next digest of its account
+func (db *DB) AddDigestItem(ctx context.Context, msg *models.EmailMessage) error {
+	query := `
+		INSERT INTO digest_items (message_id, account_id, created_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT DO NOTHING
+	`
+	_, err := db.ExecContext(ctx, query, msg.ID, msg.AccountID, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to add digest item: %w", err)
+	}
+	return nil
+}
+
+// GetDueDigestAccounts returns the accounts served by botID that have emails
+// held for a digest since before its due time: weekly before the weekly
+// time, all others before the daily time
+func (db *DB) GetDueDigestAccounts(ctx context.Context, botID int64, daily, weekly time.Time) ([]int64, error) {
+	var ids []int64
+	query := `
+		SELECT DISTINCT d.account_id FROM digest_items d
+		JOIN email_accounts a ON a.id = d.account_id
+		WHERE d.created_at < CASE WHEN a.digest_mode = ? THEN ? ELSE ? END
+			AND a.bot_id = ?
+	`
+	err := db.SelectContext(ctx, &ids, query, models.DigestWeekly, weekly, daily, botID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get digest accounts: %w", err)
+	}
+	return ids, nil
+}
+
+// GetDigestMessages returns the emails held for the digest of an account
+// before the given time, oldest first, and their total number
+func (db *DB) GetDigestMessages(ctx context.Context, accountID int64, before time.Time, limit int) ([]*models.EmailMessage, int, error) {
+	var total int
+	query := `SELECT COUNT(*) FROM digest_items WHERE account_id = ? AND created_at < ?`
+	if err := db.GetContext(ctx, &total, query, accountID, before); err != nil {
+		return nil, 0, fmt.Errorf("failed to count digest items: %w", err)
+	}
+
+	var messages []*models.EmailMessage
+	query = `
+		SELECT m.* FROM email_messages m
+		JOIN digest_items d ON d.message_id = m.id
+		WHERE d.account_id = ? AND d.created_at < ?
+		ORDER BY m.id LIMIT ?
+	`
+	if err := db.SelectContext(ctx, &messages, query, accountID, before, limit); err != nil {
+		return nil, 0, fmt.Errorf("failed to get digest messages: %w", err)
+	}
+	return messages, total, nil
+}
+
+// DeleteDigestItems removes the items of a sent digest
+func (db *DB) DeleteDigestItems(ctx context.Context, accountID int64, before time.Time) error {
+	query := `DELETE FROM digest_items WHERE account_id = ? AND created_at < ?`
+	_, err := db.ExecContext(ctx, query, accountID, before)
+	if err != nil {
+		return fmt.Errorf("failed to delete digest items: %w", err)
+	}
+	return nil
+}
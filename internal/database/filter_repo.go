@@ -0,0 +1,59 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mixelka/emailresend/pkg/models"
+)
+
+// CreateFilter adds a filter rule to an account
+func (db *DB) CreateFilter(ctx context.Context, filter *models.Filter) error {
+	query := `
+		INSERT INTO filters (account_id, action, field, pattern, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`
+	now := time.Now()
+	var id int64
+	err := db.GetContext(ctx, &id, query,
+		filter.AccountID,
+		filter.Action,
+		filter.Field,
+		filter.Pattern,
+		filter.CreatedBy,
+		now,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create filter: %w", err)
+	}
+
+	filter.ID = id
+	filter.CreatedAt = now
+	return nil
+}
+
+// GetFilters returns the filter rules of an account in creation order
+func (db *DB) GetFilters(ctx context.Context, accountID int64) ([]*models.Filter, error) {
+	var filters []*models.Filter
+	query := `SELECT * FROM filters WHERE account_id = ? ORDER BY id`
+	err := db.SelectContext(ctx, &filters, query, accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get filters: %w", err)
+	}
+	return filters, nil
+}
+
+// DeleteFilter removes a filter rule of an account
+func (db *DB) DeleteFilter(ctx context.Context, accountID, filterID int64) error {
+	query := `DELETE FROM filters WHERE id = ? AND account_id = ?`
+	res, err := db.ExecContext(ctx, query, filterID, accountID)
+	if err != nil {
+		return fmt.Errorf("failed to delete filter: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+// ForgetStats describes what was erased for a chat
+type ForgetStats struct {
+	Accounts    int
+	Messages    int
+	Codes       int
+	Attachments int // attachment references of erased messages
+	Orders      int
+	Settings    bool
+	RawKeys     []string // archived raw messages to delete from the archive
+}
+
+// GetAccountIDsByChatID returns the IDs of all accounts connected in a chat
+func (db *DB) GetAccountIDsByChatID(ctx context.Context, chatID int64) ([]int64, error) {
+	var ids []int64
+	err := db.SelectContext(ctx, &ids, `SELECT id FROM email_accounts WHERE chat_id = ?`, chatID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account ids: %w", err)
+	}
+	return ids, nil
+}
+
+// ForgetChat erases everything stored for a chat in a single transaction:
+// accounts with their messages, codes, orders, posted Message-IDs, mirrors,
+// queue entries, collapse and digest state, granted bot admins and the chat
+// settings
+func (db *DB) ForgetChat(ctx context.Context, chatID int64) (*ForgetStats, error) {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stats := &ForgetStats{}
+
+	counts := []struct {
+		dest  *int
+		query string
+	}{
+		{&stats.Accounts, `SELECT COUNT(*) FROM email_accounts WHERE chat_id = ?`},
+		{&stats.Messages, `
+			SELECT COUNT(*) FROM email_messages m
+			JOIN email_accounts a ON a.id = m.account_id
+			WHERE a.chat_id = ?`},
+		{&stats.Attachments, `
+			SELECT COALESCE(SUM(` + db.dialect.jsonArrayLength("m.attachments") + `), 0) FROM email_messages m
+			JOIN email_accounts a ON a.id = m.account_id
+			WHERE a.chat_id = ?`},
+		{&stats.Codes, `SELECT COUNT(*) FROM message_codes WHERE chat_id = ?`},
+		{&stats.Orders, `SELECT COUNT(*) FROM orders WHERE chat_id = ?`},
+	}
+	for _, c := range counts {
+		if err := tx.GetContext(ctx, c.dest, c.query, chatID); err != nil {
+			return nil, fmt.Errorf("failed to count chat data: %w", err)
+		}
+	}
+
+	query := `
+		SELECT m.raw_key FROM email_messages m
+		JOIN email_accounts a ON a.id = m.account_id
+		WHERE a.chat_id = ? AND m.raw_key != ''
+	`
+	if err := tx.SelectContext(ctx, &stats.RawKeys, query, chatID); err != nil {
+		return nil, fmt.Errorf("failed to get raw message keys: %w", err)
+	}
+
+	// Messages, queue entries, codes, orders, collapse groups, digests,
+	// filters, code patterns, tags, mirrors and sent emails cascade from the
+	// accounts; codes and orders are also removed by chat in case they
+	// outlived their account, posted Message-IDs and /grant admins always do.
+	// Mirrors of other chats' accounts shown in this chat are unlinked.
+	for _, q := range []string{
+		`DELETE FROM message_codes WHERE chat_id = ?`,
+		`DELETE FROM orders WHERE chat_id = ?`,
+		`DELETE FROM posted_messages WHERE chat_id = ?`,
+		`DELETE FROM account_mirrors WHERE chat_id = ?`,
+		`DELETE FROM bot_admins WHERE chat_id = ?`,
+		`DELETE FROM email_accounts WHERE chat_id = ?`,
+	} {
+		if _, err := tx.ExecContext(ctx, q, chatID); err != nil {
+			return nil, fmt.Errorf("failed to delete chat data: %w", err)
+		}
+	}
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM chat_settings WHERE chat_id = ?`, chatID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete chat settings: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil {
+		stats.Settings = n > 0
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit chat erasure: %w", err)
+	}
+	return stats, nil
+}
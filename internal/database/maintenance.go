@@ -0,0 +1,161 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// incrementalVacuumPages is the number of free pages released per step
+const incrementalVacuumPages = 1000
+
+// MaintenanceStats describes the result of a maintenance run
+type MaintenanceStats struct {
+	Duration     time.Duration
+	SizeBefore   int64 // database + WAL size in bytes
+	SizeAfter    int64
+	PagesFreed   int64
+	VacuumedFull bool // auto_vacuum was switched to incremental with a full VACUUM
+}
+
+// Reclaimed returns the number of bytes freed on disk
+func (s *MaintenanceStats) Reclaimed() int64 {
+	return s.SizeBefore - s.SizeAfter
+}
+
+// Maintain reclaims free space and refreshes query planner statistics as
+// the dialect allows. SQLite incremental vacuum stops when ctx is done.
+func (db *DB) Maintain(ctx context.Context) (*MaintenanceStats, error) {
+	started := time.Now()
+	stats := &MaintenanceStats{SizeBefore: db.dialect.diskSize(ctx, db.DB)}
+
+	// auto_vacuum changes only take effect on the connection running VACUUM
+	conn, err := db.Connx(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	if err := db.dialect.maintain(ctx, conn, stats); err != nil {
+		return nil, err
+	}
+
+	stats.SizeAfter = db.dialect.diskSize(context.WithoutCancel(ctx), db.DB)
+	stats.Duration = time.Since(started)
+	return stats, nil
+}
+
+// maintain checkpoints and truncates the WAL, refreshes query planner
+// statistics and releases free pages
+func (sqliteDialect) maintain(ctx context.Context, conn *sqlx.Conn, stats *MaintenanceStats) error {
+	if _, err := conn.ExecContext(ctx, `PRAGMA wal_checkpoint(TRUNCATE)`); err != nil {
+		return fmt.Errorf("failed to checkpoint wal: %w", err)
+	}
+
+	if _, err := conn.ExecContext(ctx, `ANALYZE`); err != nil {
+		return fmt.Errorf("failed to analyze: %w", err)
+	}
+
+	// Incremental vacuum only works once auto_vacuum is INCREMENTAL (2);
+	// switching an existing database requires a full VACUUM once
+	var autoVacuum int
+	if err := conn.GetContext(ctx, &autoVacuum, `PRAGMA auto_vacuum`); err != nil {
+		return fmt.Errorf("failed to get auto_vacuum mode: %w", err)
+	}
+	if autoVacuum != 2 {
+		if _, err := conn.ExecContext(ctx, `PRAGMA auto_vacuum = INCREMENTAL`); err != nil {
+			return fmt.Errorf("failed to set auto_vacuum mode: %w", err)
+		}
+		if _, err := conn.ExecContext(ctx, `VACUUM`); err != nil {
+			return fmt.Errorf("failed to vacuum: %w", err)
+		}
+		stats.VacuumedFull = true
+	}
+
+	for ctx.Err() == nil {
+		var free int64
+		if err := conn.GetContext(ctx, &free, `PRAGMA freelist_count`); err != nil {
+			return fmt.Errorf("failed to get freelist count: %w", err)
+		}
+		if free == 0 {
+			break
+		}
+
+		step := min(free, incrementalVacuumPages)
+		if _, err := conn.ExecContext(ctx, fmt.Sprintf("PRAGMA incremental_vacuum(%d)", step)); err != nil {
+			return fmt.Errorf("failed to run incremental vacuum: %w", err)
+		}
+		stats.PagesFreed += step
+	}
+
+	// Vacuum writes go through the WAL, truncate it again (even if the
+	// window has ended)
+	if _, err := conn.ExecContext(context.WithoutCancel(ctx), `PRAGMA wal_checkpoint(TRUNCATE)`); err != nil {
+		return fmt.Errorf("failed to checkpoint wal: %w", err)
+	}
+	return nil
+}
+
+// RunMaintenance runs Maintain once a day in the window [start, end), given
+// as offsets from local midnight, until ctx is cancelled
+func (db *DB) RunMaintenance(ctx context.Context, start, end time.Duration, logger *slog.Logger) {
+	logger = logger.With("component", "db_maintenance")
+
+	for {
+		next := nextWindowStart(time.Now(), start)
+		logger.Debug("next maintenance scheduled", "at", next)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(next)):
+		}
+
+		length := end - start
+		if length <= 0 {
+			length += 24 * time.Hour
+		}
+		runCtx, cancel := context.WithTimeout(ctx, length)
+		stats, err := db.Maintain(runCtx)
+		cancel()
+
+		if err != nil {
+			logger.Error("database maintenance failed", "error", err)
+			continue
+		}
+
+		logger.Info("database maintenance completed",
+			"duration", stats.Duration,
+			"size_before", stats.SizeBefore,
+			"size_after", stats.SizeAfter,
+			"reclaimed_bytes", stats.Reclaimed(),
+			"pages_freed", stats.PagesFreed,
+			"full_vacuum", stats.VacuumedFull,
+		)
+	}
+}
+
+// nextWindowStart returns the next moment after now at offset from local midnight
+func nextWindowStart(now time.Time, offset time.Duration) time.Time {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	next := midnight.Add(offset)
+	if !next.After(now) {
+		next = midnight.AddDate(0, 0, 1).Add(offset)
+	}
+	return next
+}
+
+// diskSize returns the size of the database file and its WAL
+func (d sqliteDialect) diskSize(context.Context, *sqlx.DB) int64 {
+	var total int64
+	for _, path := range []string{d.path, d.path + "-wal"} {
+		if info, err := os.Stat(path); err == nil {
+			total += info.Size()
+		}
+	}
+	return total
+}
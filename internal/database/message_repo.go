@@ -5,19 +5,33 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/jmoiron/sqlx"
+
 	"github.com/mixelka/emailresend/pkg/models"
 )
 
-// CreateMessage creates a new email message (ignores if already exists)
-func (db *DB) CreateMessage(ctx context.Context, msg *models.EmailMessage) error {
+// CreateMessage creates a new email message (ignores if already exists).
+// With enqueue the message is queued for delivery in the same transaction,
+// so a stored email is never left undelivered.
+func (db *DB) CreateMessage(ctx context.Context, msg *models.EmailMessage, enqueue bool) error {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
 	query := `
-		INSERT OR IGNORE INTO email_messages (account_id, uid, message_id, from_addr, from_name, subject, body_text, body_html, received_at, is_read, is_deleted, telegram_msg_id, detected_codes, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO email_messages (account_id, uid, message_id, from_addr, from_name, subject, body_text, body_html, received_at, internal_date, size, is_read, is_deleted, telegram_msg_id, detected_codes, attachments, parser_version, extracted, reply_to, references_header, content_hash, duplicate_of, bulk, unsubscribe, auth, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT DO NOTHING
+		RETURNING id
 	`
 	now := time.Now()
-	result, err := db.ExecContext(ctx, query,
+	var id int64
+	err = tx.GetContext(ctx, &id, query,
 		msg.AccountID,
 		msg.UID,
 		msg.MessageID,
@@ -27,28 +41,44 @@ func (db *DB) CreateMessage(ctx context.Context, msg *models.EmailMessage) error
 		msg.BodyText,
 		msg.BodyHTML,
 		msg.ReceivedAt,
+		msg.InternalDate,
+		msg.Size,
 		msg.IsRead,
 		msg.IsDeleted,
 		msg.TelegramMsgID,
 		msg.DetectedCodes,
+		msg.Attachments,
+		msg.ParserVersion,
+		msg.Extracted,
+		msg.ReplyTo,
+		msg.References,
+		msg.ContentHash,
+		msg.DuplicateOf,
+		msg.Bulk,
+		msg.Unsubscribe,
+		msg.Auth,
 		now,
 	)
+	// No row is returned if the insert was ignored as a duplicate
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrAlreadyExists
+	}
 	if err != nil {
 		return fmt.Errorf("failed to create message: %w", err)
 	}
 
-	// Check if row was actually inserted (not ignored due to duplicate)
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
-	}
-	if rowsAffected == 0 {
-		return ErrAlreadyExists
+	if enqueue {
+		query = `
+			INSERT INTO send_queue (message_id, attempts, last_error, next_attempt_at, created_at)
+			VALUES (?, 0, '', ?, ?)
+		`
+		if _, err := tx.ExecContext(ctx, query, id, now, now); err != nil {
+			return fmt.Errorf("failed to enqueue message: %w", err)
+		}
 	}
 
-	id, err := result.LastInsertId()
-	if err != nil {
-		return fmt.Errorf("failed to get last insert id: %w", err)
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit message: %w", err)
 	}
 
 	msg.ID = id
@@ -70,6 +100,26 @@ func (db *DB) GetMessageByID(ctx context.Context, id int64) (*models.EmailMessag
 	return &msg, nil
 }
 
+// FindDuplicateMessage returns the ID of an earlier message of the account
+// with the same content hash received since the given time
+func (db *DB) FindDuplicateMessage(ctx context.Context, accountID int64, hash string, since time.Time) (int64, error) {
+	var id int64
+	query := `
+		SELECT id FROM email_messages
+		WHERE account_id = ? AND content_hash = ? AND created_at >= ? AND duplicate_of = 0
+		ORDER BY id
+		LIMIT 1
+	`
+	err := db.GetContext(ctx, &id, query, accountID, hash, since)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to find duplicate message: %w", err)
+	}
+	return id, nil
+}
+
 // GetMessageByTelegramMsgID returns a message by Telegram message ID
 func (db *DB) GetMessageByTelegramMsgID(ctx context.Context, chatID int64, tgMsgID int) (*models.EmailMessage, error) {
 	var msg models.EmailMessage
@@ -77,6 +127,8 @@ func (db *DB) GetMessageByTelegramMsgID(ctx context.Context, chatID int64, tgMsg
 		SELECT m.* FROM email_messages m
 		JOIN email_accounts a ON m.account_id = a.id
 		WHERE a.chat_id = ? AND m.telegram_msg_id = ?
+		ORDER BY m.id DESC
+		LIMIT 1
 	`
 	err := db.GetContext(ctx, &msg, query, chatID, tgMsgID)
 	if errors.Is(err, sql.ErrNoRows) {
@@ -88,16 +140,177 @@ func (db *DB) GetMessageByTelegramMsgID(ctx context.Context, chatID int64, tgMsg
 	return &msg, nil
 }
 
-// UpdateMessageTelegramMsgID updates the Telegram message ID
+// GetDeliveredMessageByMessageID returns the latest email of an account with
+// a Message-ID that was posted to Telegram, excluding the email excludeID
+func (db *DB) GetDeliveredMessageByMessageID(ctx context.Context, accountID int64, messageID string, excludeID int64) (*models.EmailMessage, error) {
+	var msg models.EmailMessage
+	query := `
+		SELECT * FROM email_messages
+		WHERE account_id = ? AND message_id = ? AND id != ? AND telegram_msg_id != 0 AND is_deleted = false
+		ORDER BY id DESC
+		LIMIT 1
+	`
+	err := db.GetContext(ctx, &msg, query, accountID, messageID, excludeID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get message: %w", err)
+	}
+	return &msg, nil
+}
+
+// UpdateMessageTelegramMsgID updates the Telegram message ID and records the
+// Message-ID of the email as posted to the chat of its account
 func (db *DB) UpdateMessageTelegramMsgID(ctx context.Context, id int64, tgMsgID int) error {
-	query := `UPDATE email_messages SET telegram_msg_id = ? WHERE id = ?`
-	_, err := db.ExecContext(ctx, query, tgMsgID, id)
+	tx, err := db.BeginTxx(ctx, nil)
 	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE email_messages SET telegram_msg_id = ? WHERE id = ?`
+	if _, err := tx.ExecContext(ctx, query, tgMsgID, id); err != nil {
 		return fmt.Errorf("failed to update telegram msg id: %w", err)
 	}
+
+	query = `
+		INSERT INTO posted_messages (chat_id, mailbox, message_id, telegram_msg_id, created_at)
+		SELECT a.chat_id, LOWER(a.email), m.message_id, m.telegram_msg_id, ?
+		FROM email_messages m
+		JOIN email_accounts a ON a.id = m.account_id
+		WHERE m.id = ? AND m.message_id != ''
+		ON CONFLICT (chat_id, mailbox, message_id) DO UPDATE SET telegram_msg_id = excluded.telegram_msg_id
+	`
+	if _, err := tx.ExecContext(ctx, query, time.Now(), id); err != nil {
+		return fmt.Errorf("failed to record posted message: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit telegram msg id: %w", err)
+	}
 	return nil
 }
 
+// FindPostedMessage returns the Telegram message an email with the
+// Message-ID was posted as for the mailbox in the chat, including posts of
+// earlier connections of the mailbox
+func (db *DB) FindPostedMessage(ctx context.Context, chatID int64, mailbox, messageID string) (int, error) {
+	var tgMsgID int
+	query := `SELECT telegram_msg_id FROM posted_messages WHERE chat_id = ? AND mailbox = ? AND message_id = ?`
+	err := db.GetContext(ctx, &tgMsgID, query, chatID, strings.ToLower(mailbox), messageID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to find posted message: %w", err)
+	}
+	return tgMsgID, nil
+}
+
+// UpdateMessageRawKey stores the archive key and size of the raw message
+func (db *DB) UpdateMessageRawKey(ctx context.Context, id int64, key string, size int64) error {
+	query := `UPDATE email_messages SET raw_key = ?, raw_size = ? WHERE id = ?`
+	_, err := db.ExecContext(ctx, query, key, size, id)
+	if err != nil {
+		return fmt.Errorf("failed to update raw key: %w", err)
+	}
+	return nil
+}
+
+// UpdateMessageContent stores the re-parsed body, codes, attachments and extraction of a message
+func (db *DB) UpdateMessageContent(ctx context.Context, msg *models.EmailMessage) error {
+	query := `
+		UPDATE email_messages
+		SET body_text = ?, body_html = ?, detected_codes = ?, attachments = ?, parser_version = ?, extracted = ?
+		WHERE id = ?
+	`
+	_, err := db.ExecContext(ctx, query, msg.BodyText, msg.BodyHTML, msg.DetectedCodes, msg.Attachments, msg.ParserVersion, msg.Extracted, msg.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update message content: %w", err)
+	}
+	return nil
+}
+
+// GetOutdatedMessages returns up to limit messages of an account parsed with
+// an older parser version and with an ID greater than afterID, oldest first
+func (db *DB) GetOutdatedMessages(ctx context.Context, accountID int64, version int, afterID int64, limit int) ([]*models.EmailMessage, error) {
+	var messages []*models.EmailMessage
+	query := `
+		SELECT * FROM email_messages
+		WHERE account_id = ? AND parser_version < ? AND id > ? AND is_deleted = false
+		ORDER BY id
+		LIMIT ?
+	`
+	err := db.SelectContext(ctx, &messages, query, accountID, version, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get outdated messages: %w", err)
+	}
+	return messages, nil
+}
+
+// GetMessagesInRange returns up to limit messages of an account stored in
+// [from, to) with an ID greater than afterID, oldest first. Zero times leave
+// the range open.
+func (db *DB) GetMessagesInRange(ctx context.Context, accountID int64, from, to time.Time, afterID int64, limit int) ([]*models.EmailMessage, error) {
+	if to.IsZero() {
+		to = time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	var messages []*models.EmailMessage
+	query := `
+		SELECT * FROM email_messages
+		WHERE account_id = ? AND created_at >= ? AND created_at < ? AND id > ? AND is_deleted = false
+		ORDER BY id
+		LIMIT ?
+	`
+	err := db.SelectContext(ctx, &messages, query, accountID, from, to, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get messages in range: %w", err)
+	}
+	return messages, nil
+}
+
+// GetTrackedMessages returns up to limit emails of an account posted to
+// Telegram since the given time that are still in INBOX, newest first. Only
+// the fields needed to follow their mailbox state are loaded.
+func (db *DB) GetTrackedMessages(ctx context.Context, accountID int64, since time.Time, limit int) ([]*models.EmailMessage, error) {
+	var messages []*models.EmailMessage
+	query := `
+		SELECT id, account_id, uid, message_id, is_read, telegram_msg_id FROM email_messages
+		WHERE account_id = ? AND created_at >= ? AND uid != 0 AND telegram_msg_id != 0 AND is_deleted = false AND folder = ''
+		ORDER BY id DESC
+		LIMIT ?
+	`
+	err := db.SelectContext(ctx, &messages, query, accountID, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tracked messages: %w", err)
+	}
+	return messages, nil
+}
+
+// GetInboxMessagesByUIDs returns the emails of an account with the given
+// IMAP UIDs that are still in INBOX
+func (db *DB) GetInboxMessagesByUIDs(ctx context.Context, accountID int64, uids []uint32) ([]*models.EmailMessage, error) {
+	if len(uids) == 0 {
+		return nil, nil
+	}
+
+	query, args, err := sqlx.In(`
+		SELECT * FROM email_messages
+		WHERE account_id = ? AND uid IN (?) AND is_deleted = false AND folder = ''
+		ORDER BY id
+	`, accountID, uids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build messages by UID query: %w", err)
+	}
+
+	var messages []*models.EmailMessage
+	if err := db.SelectContext(ctx, &messages, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to get messages by UID: %w", err)
+	}
+	return messages, nil
+}
+
 // MarkMessageAsRead marks a message as read
 func (db *DB) MarkMessageAsRead(ctx context.Context, id int64) error {
 	query := `UPDATE email_messages SET is_read = true WHERE id = ?`
@@ -117,3 +330,114 @@ func (db *DB) MarkMessageAsDeleted(ctx context.Context, id int64) error {
 	}
 	return nil
 }
+
+// MarkMessageAsMoved records the IMAP folder an email was moved to
+func (db *DB) MarkMessageAsMoved(ctx context.Context, id int64, folder string) error {
+	query := `UPDATE email_messages SET folder = ? WHERE id = ?`
+	_, err := db.ExecContext(ctx, query, folder, id)
+	if err != nil {
+		return fmt.Errorf("failed to mark message as moved: %w", err)
+	}
+	return nil
+}
+
+// GetMessagesByAccount returns up to limit messages of an account older than
+// beforeID, newest first. Pass beforeID = 0 for the first page and the ID of
+// the last returned message for the next one (keyset pagination).
+func (db *DB) GetMessagesByAccount(ctx context.Context, accountID, beforeID int64, limit int) ([]*models.EmailMessage, error) {
+	page := Page{Limit: limit}.normalize()
+	var messages []*models.EmailMessage
+	query := `
+		SELECT * FROM email_messages
+		WHERE account_id = ? AND is_deleted = false AND (? = 0 OR id < ?)
+		ORDER BY id DESC LIMIT ?
+	`
+	err := db.SelectContext(ctx, &messages, query, accountID, beforeID, beforeID, page.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get messages: %w", err)
+	}
+	return messages, nil
+}
+
+// CountMessagesByAccount returns the number of stored, not deleted messages
+// of an account
+func (db *DB) CountMessagesByAccount(ctx context.Context, accountID int64) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM email_messages WHERE account_id = ? AND is_deleted = false`
+	if err := db.GetContext(ctx, &count, query, accountID); err != nil {
+		return 0, fmt.Errorf("failed to count messages: %w", err)
+	}
+	return count, nil
+}
+
+// CountUnreadMessages returns the number of unread emails of an account
+// still in INBOX that were stored since the given time
+func (db *DB) CountUnreadMessages(ctx context.Context, accountID int64, since time.Time) (int, error) {
+	var count int
+	query := `
+		SELECT COUNT(*) FROM email_messages
+		WHERE account_id = ? AND is_read = false AND is_deleted = false AND folder = ''
+			AND duplicate_of = 0 AND created_at >= ?
+	`
+	if err := db.GetContext(ctx, &count, query, accountID, since); err != nil {
+		return 0, fmt.Errorf("failed to count unread messages: %w", err)
+	}
+	return count, nil
+}
+
+// GetMessagesPage returns a page of the not deleted messages of an account,
+// newest first
+func (db *DB) GetMessagesPage(ctx context.Context, accountID int64, page Page) ([]*models.EmailMessage, error) {
+	page = page.normalize()
+	var messages []*models.EmailMessage
+	query := `
+		SELECT * FROM email_messages
+		WHERE account_id = ? AND is_deleted = false
+		ORDER BY id DESC LIMIT ? OFFSET ?
+	`
+	err := db.SelectContext(ctx, &messages, query, accountID, page.Limit, page.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get messages: %w", err)
+	}
+	return messages, nil
+}
+
+// MessageStats summarizes the emails of an account
+type MessageStats struct {
+	Total  int        // all stored emails
+	Recent int        // emails received since the given time
+	Codes  int        // emails with detected codes since the given time
+	LastAt *time.Time // when the newest email was stored, nil if none
+}
+
+// GetMessageStats returns the email counts of an account, counting Recent and
+// Codes since the given time
+func (db *DB) GetMessageStats(ctx context.Context, accountID int64, since time.Time) (*MessageStats, error) {
+	stats := &MessageStats{}
+
+	query := `SELECT COUNT(*) FROM email_messages WHERE account_id = ?`
+	if err := db.GetContext(ctx, &stats.Total, query, accountID); err != nil {
+		return nil, fmt.Errorf("failed to count messages: %w", err)
+	}
+
+	query = `SELECT COUNT(*) FROM email_messages WHERE account_id = ? AND created_at >= ?`
+	if err := db.GetContext(ctx, &stats.Recent, query, accountID, since); err != nil {
+		return nil, fmt.Errorf("failed to count recent messages: %w", err)
+	}
+
+	query = `SELECT COUNT(DISTINCT message_id) FROM message_codes WHERE account_id = ? AND created_at >= ?`
+	if err := db.GetContext(ctx, &stats.Codes, query, accountID, since); err != nil {
+		return nil, fmt.Errorf("failed to count messages with codes: %w", err)
+	}
+
+	var last time.Time
+	query = `SELECT created_at FROM email_messages WHERE account_id = ? ORDER BY id DESC LIMIT 1`
+	err := db.GetContext(ctx, &last, query, accountID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to get last message: %w", err)
+	}
+	if err == nil {
+		stats.LastAt = &last
+	}
+	return stats, nil
+}
@@ -36,8 +36,295 @@ CREATE TABLE IF NOT EXISTS email_messages (
     UNIQUE(account_id, uid)
 );
 
+CREATE TABLE IF NOT EXISTS send_queue (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    message_id INTEGER NOT NULL REFERENCES email_messages(id) ON DELETE CASCADE,
+    attempts INTEGER DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(message_id)
+);
+
+CREATE TABLE IF NOT EXISTS chat_settings (
+    chat_id INTEGER PRIMARY KEY,
+    parse_mode TEXT NOT NULL DEFAULT 'HTML',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS collapsed_groups (
+    account_id INTEGER NOT NULL REFERENCES email_accounts(id) ON DELETE CASCADE,
+    subject_key TEXT NOT NULL,
+    telegram_msg_id INTEGER NOT NULL,
+    count INTEGER NOT NULL DEFAULT 1,
+    first_at DATETIME NOT NULL,
+    last_at DATETIME NOT NULL,
+    PRIMARY KEY (account_id, subject_key)
+);
+
+CREATE TABLE IF NOT EXISTS message_codes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    message_id INTEGER NOT NULL REFERENCES email_messages(id) ON DELETE CASCADE,
+    account_id INTEGER NOT NULL REFERENCES email_accounts(id) ON DELETE CASCADE,
+    chat_id INTEGER NOT NULL,
+    value TEXT NOT NULL,
+    type TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS sender_digests (
+    account_id INTEGER NOT NULL REFERENCES email_accounts(id) ON DELETE CASCADE,
+    from_addr TEXT NOT NULL,
+    hour_start DATETIME NOT NULL,
+    count INTEGER NOT NULL DEFAULT 1,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (account_id, from_addr, hour_start)
+);
+
+CREATE TABLE IF NOT EXISTS orders (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    message_id INTEGER NOT NULL REFERENCES email_messages(id) ON DELETE CASCADE,
+    account_id INTEGER NOT NULL REFERENCES email_accounts(id) ON DELETE CASCADE,
+    chat_id INTEGER NOT NULL,
+    order_number TEXT NOT NULL,
+    amount INTEGER NOT NULL DEFAULT 0,
+    currency TEXT NOT NULL DEFAULT '',
+    merchant TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(message_id)
+);
+
+CREATE TABLE IF NOT EXISTS bot_state (
+    key TEXT PRIMARY KEY,
+    value TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS oauth_tokens (
+    account_id INTEGER PRIMARY KEY REFERENCES email_accounts(id) ON DELETE CASCADE,
+    provider TEXT NOT NULL,
+    access_token TEXT NOT NULL DEFAULT '',
+    refresh_token TEXT NOT NULL,
+    expires_at DATETIME NOT NULL,
+    revoked BOOLEAN NOT NULL DEFAULT false,
+    updated_at DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS filters (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_id INTEGER NOT NULL REFERENCES email_accounts(id) ON DELETE CASCADE,
+    action TEXT NOT NULL,
+    field TEXT NOT NULL,
+    pattern TEXT NOT NULL,
+    created_by INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS code_patterns (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_id INTEGER NOT NULL REFERENCES email_accounts(id) ON DELETE CASCADE,
+    pattern TEXT NOT NULL,
+    created_by INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS sent_messages (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_id INTEGER NOT NULL REFERENCES email_accounts(id) ON DELETE CASCADE,
+    reply_to INTEGER NOT NULL DEFAULT 0,
+    message_id TEXT NOT NULL DEFAULT '',
+    to_addr TEXT NOT NULL,
+    subject TEXT NOT NULL DEFAULT '',
+    body TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL,
+    error TEXT NOT NULL DEFAULT '',
+    created_by INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL,
+    sent_at DATETIME
+);
+
+CREATE TABLE IF NOT EXISTS account_tags (
+    account_id INTEGER NOT NULL REFERENCES email_accounts(id) ON DELETE CASCADE,
+    tag TEXT NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY(account_id, tag)
+);
+
+CREATE TABLE IF NOT EXISTS posted_messages (
+    chat_id INTEGER NOT NULL,
+    mailbox TEXT NOT NULL,
+    message_id TEXT NOT NULL,
+    telegram_msg_id INTEGER NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY(chat_id, mailbox, message_id)
+);
+
+CREATE TABLE IF NOT EXISTS account_mirrors (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    account_id INTEGER NOT NULL REFERENCES email_accounts(id) ON DELETE CASCADE,
+    chat_id INTEGER NOT NULL,
+    topic_id INTEGER NOT NULL,
+    created_by INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL,
+    UNIQUE(account_id, chat_id),
+    UNIQUE(chat_id, topic_id)
+);
+
+CREATE TABLE IF NOT EXISTS mirror_invites (
+    code TEXT PRIMARY KEY,
+    account_id INTEGER NOT NULL REFERENCES email_accounts(id) ON DELETE CASCADE,
+    created_by INTEGER NOT NULL DEFAULT 0,
+    expires_at DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS digest_items (
+    message_id INTEGER PRIMARY KEY REFERENCES email_messages(id) ON DELETE CASCADE,
+    account_id INTEGER NOT NULL REFERENCES email_accounts(id) ON DELETE CASCADE,
+    created_at DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS pinned_codes (
+    message_id INTEGER PRIMARY KEY REFERENCES email_messages(id) ON DELETE CASCADE,
+    account_id INTEGER NOT NULL REFERENCES email_accounts(id) ON DELETE CASCADE,
+    chat_id INTEGER NOT NULL,
+    telegram_msg_id INTEGER NOT NULL,
+    unpin_at DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS bot_admins (
+    chat_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    name TEXT NOT NULL DEFAULT '',
+    granted_by INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY(chat_id, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS smime_keys (
+    account_id INTEGER PRIMARY KEY REFERENCES email_accounts(id) ON DELETE CASCADE,
+    private_key TEXT NOT NULL,
+    certificate TEXT NOT NULL,
+    updated_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_digest_items_account ON digest_items(account_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_sent_account ON sent_messages(account_id);
+CREATE INDEX IF NOT EXISTS idx_account_tags_tag ON account_tags(tag);
+CREATE INDEX IF NOT EXISTS idx_filters_account ON filters(account_id);
+CREATE INDEX IF NOT EXISTS idx_code_patterns_account ON code_patterns(account_id);
 CREATE INDEX IF NOT EXISTS idx_accounts_chat ON email_accounts(chat_id);
+CREATE INDEX IF NOT EXISTS idx_mirrors_account ON account_mirrors(account_id);
 CREATE INDEX IF NOT EXISTS idx_accounts_active ON email_accounts(is_active);
 CREATE INDEX IF NOT EXISTS idx_messages_account ON email_messages(account_id);
 CREATE INDEX IF NOT EXISTS idx_messages_telegram ON email_messages(telegram_msg_id);
+CREATE INDEX IF NOT EXISTS idx_queue_next_attempt ON send_queue(next_attempt_at);
+CREATE INDEX IF NOT EXISTS idx_messages_sender ON email_messages(account_id, from_addr, created_at);
+CREATE INDEX IF NOT EXISTS idx_messages_message_id ON email_messages(account_id, message_id);
+CREATE INDEX IF NOT EXISTS idx_codes_chat_value ON message_codes(chat_id, value, created_at);
+CREATE INDEX IF NOT EXISTS idx_orders_chat_number ON orders(chat_id, order_number COLLATE NOCASE);
+CREATE INDEX IF NOT EXISTS idx_orders_chat_created ON orders(chat_id, created_at);
 `
+
+// migrations are applied in order after schema; each entry runs once.
+// Append new entries only, never edit or reorder existing ones. Like schema
+// they are written for SQLite and translated for Postgres by the dialect,
+// so stick to types and syntax both accept.
+var migrations = []string{
+	// 1: custom emoji per chat
+	`ALTER TABLE chat_settings ADD COLUMN custom_emoji TEXT NOT NULL DEFAULT '{}'`,
+	// 2-3: silent delivery per topic with priority override
+	`ALTER TABLE email_accounts ADD COLUMN silent BOOLEAN NOT NULL DEFAULT false`,
+	`ALTER TABLE email_accounts ADD COLUMN priority_pattern TEXT NOT NULL DEFAULT ''`,
+	// 4: protect forwarded emails from forwarding/copying
+	`ALTER TABLE email_accounts ADD COLUMN protect_content BOOLEAN NOT NULL DEFAULT false`,
+	// 5: bot that owns the account (0 = primary bot)
+	`ALTER TABLE email_accounts ADD COLUMN bot_id INTEGER NOT NULL DEFAULT 0`,
+	// 6-8: server receive time and size of messages
+	`ALTER TABLE email_messages ADD COLUMN internal_date DATETIME`,
+	`UPDATE email_messages SET internal_date = COALESCE(received_at, created_at)`,
+	`ALTER TABLE email_messages ADD COLUMN size INTEGER NOT NULL DEFAULT 0`,
+	// 9: attachment metadata
+	`ALTER TABLE email_messages ADD COLUMN attachments TEXT NOT NULL DEFAULT '[]'`,
+	// 10: collapse identical-subject emails per topic
+	`ALTER TABLE email_accounts ADD COLUMN collapse_window INTEGER NOT NULL DEFAULT 0`,
+	// 11-13: pinned status board message per chat
+	`ALTER TABLE chat_settings ADD COLUMN status_topic_id INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE chat_settings ADD COLUMN status_msg_id INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE chat_settings ADD COLUMN status_bot_id INTEGER NOT NULL DEFAULT 0`,
+	// 14: archive key of the raw message
+	`ALTER TABLE email_messages ADD COLUMN raw_key TEXT NOT NULL DEFAULT ''`,
+	// 15: parser version the body and codes were produced with
+	`ALTER TABLE email_messages ADD COLUMN parser_version INTEGER NOT NULL DEFAULT 0`,
+	// 16-17: sender-specific extractors
+	`ALTER TABLE email_messages ADD COLUMN extracted TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE chat_settings ADD COLUMN disabled_extractors TEXT NOT NULL DEFAULT ''`,
+	// 18: formatting profile per topic
+	`ALTER TABLE email_accounts ADD COLUMN format_profile TEXT NOT NULL DEFAULT ''`,
+	// 19: opt out of owner announcements per chat
+	`ALTER TABLE chat_settings ADD COLUMN broadcast_opt_out BOOLEAN NOT NULL DEFAULT false`,
+	// 20-22: replying to emails over SMTP
+	`ALTER TABLE email_accounts ADD COLUMN smtp_server TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE email_messages ADD COLUMN reply_to TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE email_messages ADD COLUMN references_header TEXT NOT NULL DEFAULT ''`,
+	// 23-25: content hash to suppress duplicate deliveries
+	`ALTER TABLE email_messages ADD COLUMN content_hash TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE email_messages ADD COLUMN duplicate_of INTEGER NOT NULL DEFAULT 0`,
+	`CREATE INDEX IF NOT EXISTS idx_messages_content_hash ON email_messages(account_id, content_hash, created_at)`,
+	// 26: IDLE or polling per account
+	`ALTER TABLE email_accounts ADD COLUMN idle_mode TEXT NOT NULL DEFAULT ''`,
+	// 27: topic for filtered emails
+	`ALTER TABLE email_accounts ADD COLUMN spam_topic_id INTEGER NOT NULL DEFAULT 0`,
+	// 28-29: per-chat storage quota
+	`ALTER TABLE email_messages ADD COLUMN raw_size INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE email_messages ADD COLUMN body_trimmed BOOLEAN NOT NULL DEFAULT false`,
+	// 30: per-command permissions per chat
+	`ALTER TABLE chat_settings ADD COLUMN permissions TEXT NOT NULL DEFAULT '{}'`,
+	// 31: Message-IDs already posted to a chat, kept after disconnecting
+	`INSERT INTO posted_messages (chat_id, mailbox, message_id, telegram_msg_id, created_at)
+	SELECT a.chat_id, LOWER(a.email), m.message_id, MAX(m.telegram_msg_id), MAX(m.created_at)
+	FROM email_messages m
+	JOIN email_accounts a ON a.id = m.account_id
+	WHERE m.message_id != '' AND m.telegram_msg_id != 0
+	GROUP BY a.chat_id, LOWER(a.email), m.message_id`,
+	// 32: mailboxes created in Mailcow by the bot
+	`ALTER TABLE email_accounts ADD COLUMN provisioned BOOLEAN NOT NULL DEFAULT false`,
+	// 33: language of bot messages per chat
+	`ALTER TABLE chat_settings ADD COLUMN language TEXT NOT NULL DEFAULT 'ru'`,
+	// 34: pinning emails with codes
+	`ALTER TABLE email_accounts ADD COLUMN pin_codes INTEGER NOT NULL DEFAULT 0`,
+	// 35-37: spam and newsletter handling
+	`ALTER TABLE email_messages ADD COLUMN bulk TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE email_accounts ADD COLUMN bulk_mode TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE email_accounts ADD COLUMN bulk_topic_id INTEGER NOT NULL DEFAULT 0`,
+	// 38: digest mode for non-urgent emails
+	`ALTER TABLE email_accounts ADD COLUMN digest_mode TEXT NOT NULL DEFAULT ''`,
+	// 39: UIDVALIDITY of the mailbox last_uid belongs to
+	`ALTER TABLE email_accounts ADD COLUMN uid_validity INTEGER NOT NULL DEFAULT 0`,
+	// 40: topics created for /connect and /create sent in General
+	`ALTER TABLE chat_settings ADD COLUMN auto_topics BOOLEAN NOT NULL DEFAULT false`,
+	// 41: IMAP folder an email was moved to from the inbox
+	`ALTER TABLE email_messages ADD COLUMN folder TEXT NOT NULL DEFAULT ''`,
+	// 42-44: quiet hours per account
+	`ALTER TABLE email_accounts ADD COLUMN quiet_hours TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE email_accounts ADD COLUMN quiet_tz TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE email_accounts ADD COLUMN quiet_mode TEXT NOT NULL DEFAULT ''`,
+	// 45-48: dead letters of the send queue
+	`ALTER TABLE email_messages ADD COLUMN delivery_status TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE email_messages ADD COLUMN delivery_error TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE email_messages ADD COLUMN delivery_retries INTEGER NOT NULL DEFAULT 0`,
+	`CREATE INDEX IF NOT EXISTS idx_messages_delivery_status ON email_messages(account_id, delivery_status)`,
+	// 49: message template per account
+	`ALTER TABLE email_accounts ADD COLUMN message_template TEXT NOT NULL DEFAULT ''`,
+	// 50: List-Unsubscribe of newsletters
+	`ALTER TABLE email_messages ADD COLUMN unsubscribe TEXT NOT NULL DEFAULT ''`,
+	// 51: sender pictures per account
+	`ALTER TABLE email_accounts ADD COLUMN avatars TEXT NOT NULL DEFAULT ''`,
+	// 52: DKIM/SPF verdict of the sender
+	`ALTER TABLE email_messages ADD COLUMN auth TEXT NOT NULL DEFAULT ''`,
+	// 53-54: mail check timings per account
+	`ALTER TABLE email_accounts ADD COLUMN poll_interval INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE email_accounts ADD COLUMN idle_timeout INTEGER NOT NULL DEFAULT 0`,
+	// 55-56: unread count in the topic name
+	`ALTER TABLE email_accounts ADD COLUMN unread_badge BOOLEAN NOT NULL DEFAULT false`,
+	`ALTER TABLE email_accounts ADD COLUMN topic_name TEXT NOT NULL DEFAULT ''`,
+}
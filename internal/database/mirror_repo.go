@@ -0,0 +1,115 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mixelka/emailresend/pkg/models"
+)
+
+// CreateMirrorInvite stores a one-time code for linking a mirror and removes
+// expired codes
+func (db *DB) CreateMirrorInvite(ctx context.Context, invite *models.MirrorInvite) error {
+	if _, err := db.ExecContext(ctx, `DELETE FROM mirror_invites WHERE expires_at < ?`, time.Now()); err != nil {
+		return fmt.Errorf("failed to delete expired mirror invites: %w", err)
+	}
+
+	query := `INSERT INTO mirror_invites (code, account_id, created_by, expires_at) VALUES (?, ?, ?, ?)`
+	_, err := db.ExecContext(ctx, query, invite.Code, invite.AccountID, invite.CreatedBy, invite.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to create mirror invite: %w", err)
+	}
+	return nil
+}
+
+// UseMirrorInvite consumes an unexpired invite code
+func (db *DB) UseMirrorInvite(ctx context.Context, code string) (*models.MirrorInvite, error) {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var invite models.MirrorInvite
+	query := `SELECT * FROM mirror_invites WHERE code = ? AND expires_at >= ?`
+	err = tx.GetContext(ctx, &invite, query, code, time.Now())
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mirror invite: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mirror_invites WHERE code = ?`, code); err != nil {
+		return nil, fmt.Errorf("failed to delete mirror invite: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit mirror invite: %w", err)
+	}
+	return &invite, nil
+}
+
+// CreateMirror links a topic as a mirror of an account. Returns
+// ErrAlreadyExists if the account is already mirrored to the chat or the
+// topic mirrors another account.
+func (db *DB) CreateMirror(ctx context.Context, mirror *models.AccountMirror) error {
+	query := `
+		INSERT INTO account_mirrors (account_id, chat_id, topic_id, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT DO NOTHING
+		RETURNING id
+	`
+	now := time.Now()
+	var id int64
+	err := db.GetContext(ctx, &id, query, mirror.AccountID, mirror.ChatID, mirror.TopicID, mirror.CreatedBy, now)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrAlreadyExists
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create mirror: %w", err)
+	}
+
+	mirror.ID = id
+	mirror.CreatedAt = now
+	return nil
+}
+
+// GetAccountMirrors returns the mirrors of an account in the order they were
+// linked
+func (db *DB) GetAccountMirrors(ctx context.Context, accountID int64) ([]*models.AccountMirror, error) {
+	var mirrors []*models.AccountMirror
+	query := `SELECT * FROM account_mirrors WHERE account_id = ? ORDER BY id`
+	if err := db.SelectContext(ctx, &mirrors, query, accountID); err != nil {
+		return nil, fmt.Errorf("failed to get mirrors: %w", err)
+	}
+	return mirrors, nil
+}
+
+// GetMirrorByChatAndTopic returns the mirror shown in a topic
+func (db *DB) GetMirrorByChatAndTopic(ctx context.Context, chatID int64, topicID int) (*models.AccountMirror, error) {
+	var mirror models.AccountMirror
+	query := `SELECT * FROM account_mirrors WHERE chat_id = ? AND topic_id = ?`
+	err := db.GetContext(ctx, &mirror, query, chatID, topicID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mirror: %w", err)
+	}
+	return &mirror, nil
+}
+
+// DeleteMirror unlinks a mirror
+func (db *DB) DeleteMirror(ctx context.Context, id int64) error {
+	res, err := db.ExecContext(ctx, `DELETE FROM account_mirrors WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete mirror: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mixelka/emailresend/pkg/models"
+)
+
+// SaveOrder stores the order extracted from a message, replacing a previous
+// one. A nil order removes it (e.g. after re-parsing).
+func (db *DB) SaveOrder(ctx context.Context, msg *models.EmailMessage, chatID int64, order *models.Order) error {
+	if order == nil {
+		_, err := db.ExecContext(ctx, `DELETE FROM orders WHERE message_id = ?`, msg.ID)
+		if err != nil {
+			return fmt.Errorf("failed to delete order: %w", err)
+		}
+		return nil
+	}
+
+	query := `
+		INSERT INTO orders (message_id, account_id, chat_id, order_number, amount, currency, merchant, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(message_id) DO UPDATE SET
+			order_number = excluded.order_number,
+			amount = excluded.amount,
+			currency = excluded.currency,
+			merchant = excluded.merchant
+	`
+	_, err := db.ExecContext(ctx, query,
+		msg.ID,
+		msg.AccountID,
+		chatID,
+		order.Number,
+		order.Amount,
+		order.Currency,
+		order.Merchant,
+		msg.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save order: %w", err)
+	}
+	return nil
+}
+
+// FindOrders returns orders of a chat whose number contains the query, newest first
+func (db *DB) FindOrders(ctx context.Context, chatID int64, number string, limit int) ([]*models.Order, error) {
+	var orders []*models.Order
+	query := `
+		SELECT * FROM orders
+		WHERE chat_id = ? AND order_number ` + db.dialect.likeNoCase() + ` ? ESCAPE '\'
+		ORDER BY created_at DESC
+		LIMIT ?
+	`
+	err := db.SelectContext(ctx, &orders, query, chatID, "%"+escapeLike(number)+"%", limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find orders: %w", err)
+	}
+	return orders, nil
+}
+
+// GetSpendTotals sums orders of a chat with a known amount per merchant and
+// currency for [from, to), largest first
+func (db *DB) GetSpendTotals(ctx context.Context, chatID int64, from, to time.Time) ([]*models.SpendTotal, error) {
+	var totals []*models.SpendTotal
+	query := `
+		SELECT merchant, currency, SUM(amount) AS total, COUNT(*) AS count
+		FROM orders
+		WHERE chat_id = ? AND created_at >= ? AND created_at < ? AND amount > 0
+		GROUP BY merchant, currency
+		ORDER BY total DESC
+	`
+	err := db.SelectContext(ctx, &totals, query, chatID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get spend totals: %w", err)
+	}
+	return totals, nil
+}
+
+// escapeLike escapes LIKE wildcards in s (used with ESCAPE '\')
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
@@ -0,0 +1,35 @@
+package database
+
+// DefaultPageSize is the page size used when none is given
+const DefaultPageSize = 20
+
+// MaxPageSize caps the page size of list queries
+const MaxPageSize = 100
+
+// Page selects a slice of an ordered result set
+type Page struct {
+	Limit  int
+	Offset int
+}
+
+// PageN returns the n-th page (0-based) of the given size
+func PageN(n, size int) Page {
+	if n < 0 {
+		n = 0
+	}
+	return Page{Limit: size, Offset: n * size}.normalize()
+}
+
+// normalize applies defaults and bounds to the page
+func (p Page) normalize() Page {
+	if p.Limit <= 0 {
+		p.Limit = DefaultPageSize
+	}
+	if p.Limit > MaxPageSize {
+		p.Limit = MaxPageSize
+	}
+	if p.Offset < 0 {
+		p.Offset = 0
+	}
+	return p
+}
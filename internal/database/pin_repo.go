@@ -0,0 +1,66 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mixelka/emailresend/pkg/models"
+)
+
+// SavePinnedCode records an email pinned in its topic
+func (db *DB) SavePinnedCode(ctx context.Context, pin *models.PinnedCode) error {
+	query := `
+		INSERT INTO pinned_codes (message_id, account_id, chat_id, telegram_msg_id, unpin_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(message_id) DO UPDATE SET
+			telegram_msg_id = excluded.telegram_msg_id,
+			unpin_at = excluded.unpin_at
+	`
+	_, err := db.ExecContext(ctx, query, pin.MessageID, pin.AccountID, pin.ChatID, pin.TelegramMsgID, pin.UnpinAt)
+	if err != nil {
+		return fmt.Errorf("failed to save pinned code: %w", err)
+	}
+	return nil
+}
+
+// GetPinnedCode returns the pin of an email
+func (db *DB) GetPinnedCode(ctx context.Context, messageID int64) (*models.PinnedCode, error) {
+	var pin models.PinnedCode
+	err := db.GetContext(ctx, &pin, `SELECT * FROM pinned_codes WHERE message_id = ?`, messageID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pinned code: %w", err)
+	}
+	return &pin, nil
+}
+
+// GetDuePinnedCodes returns pins of accounts served by botID that expire
+// before the given time
+func (db *DB) GetDuePinnedCodes(ctx context.Context, botID int64, before time.Time) ([]*models.PinnedCode, error) {
+	var pins []*models.PinnedCode
+	query := `
+		SELECT p.* FROM pinned_codes p
+		JOIN email_accounts a ON a.id = p.account_id
+		WHERE p.unpin_at < ? AND a.bot_id = ?
+		ORDER BY p.unpin_at
+	`
+	err := db.SelectContext(ctx, &pins, query, before, botID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get due pinned codes: %w", err)
+	}
+	return pins, nil
+}
+
+// DeletePinnedCode removes the pin record of an email
+func (db *DB) DeletePinnedCode(ctx context.Context, messageID int64) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM pinned_codes WHERE message_id = ?`, messageID)
+	if err != nil {
+		return fmt.Errorf("failed to delete pinned code: %w", err)
+	}
+	return nil
+}
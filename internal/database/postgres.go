@@ -0,0 +1,128 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// postgresDriver is the database/sql driver registered by pgx, linked in
+// with the postgres build tag
+const postgresDriver = "pgx"
+
+// migrationLockID is the Postgres advisory lock held while migrating
+const migrationLockID = 0x656d61696c626f74 // "emailbot"
+
+// postgresTypes translates SQLite column types
+var postgresTypes = strings.NewReplacer(
+	"INTEGER PRIMARY KEY AUTOINCREMENT", "BIGSERIAL PRIMARY KEY",
+	"INTEGER", "BIGINT", // chat IDs do not fit in 32 bits
+	"DATETIME", "TIMESTAMPTZ",
+	" COLLATE NOCASE", "",
+)
+
+// newPostgres connects to a Postgres database shared by several instances
+func newPostgres(url string) (*DB, error) {
+	if url == "" {
+		return nil, errors.New("DATABASE_URL is required for the postgres driver")
+	}
+	if !slices.Contains(sql.Drivers(), postgresDriver) {
+		return nil, errors.New("postgres support is not compiled in, rebuild with -tags postgres")
+	}
+
+	db, err := sqlx.Connect(postgresDriver, url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	return &DB{DB: db, dialect: postgresDialect{}}, nil
+}
+
+// postgresDialect runs the repository on Postgres
+type postgresDialect struct{}
+
+func (postgresDialect) name() string { return DriverPostgres }
+
+func (postgresDialect) ddl(stmt string) string {
+	return postgresTypes.Replace(stmt)
+}
+
+// lockMigrations holds a session advisory lock on conn, so instances started
+// together migrate one after another
+func (postgresDialect) lockMigrations(ctx context.Context, conn *sqlx.Conn) (func(), error) {
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		return nil, err
+	}
+	return func() {
+		conn.ExecContext(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, migrationLockID)
+	}, nil
+}
+
+// schemaVersion is tracked in a single-row table, Postgres has no user_version
+func (postgresDialect) schemaVersion(ctx context.Context, conn *sqlx.Conn) (int, error) {
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_version (version INTEGER NOT NULL)`); err != nil {
+		return 0, err
+	}
+	var version int
+	err := conn.GetContext(ctx, &version, `SELECT COALESCE(MAX(version), 0) FROM schema_version`)
+	return version, err
+}
+
+func (postgresDialect) setSchemaVersion(ctx context.Context, tx *sqlx.Tx, version int) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM schema_version`); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, `INSERT INTO schema_version (version) VALUES ($1)`, version)
+	return err
+}
+
+func (postgresDialect) jsonArrayLength(column string) string {
+	return fmt.Sprintf("CASE WHEN %[1]s LIKE '[%%' THEN json_array_length(%[1]s::json) ELSE 0 END", column)
+}
+
+func (postgresDialect) likeNoCase() string { return "ILIKE" }
+
+func (postgresDialect) byteLength(column string) string {
+	return fmt.Sprintf("COALESCE(OCTET_LENGTH(%s), 0)", column)
+}
+
+// searchDocument is the text of a message indexed for search
+const searchDocument = `to_tsvector('simple', COALESCE(subject, '') || ' ' || COALESCE(from_name, '') || ' ' || from_addr || ' ' || COALESCE(body_text, ''))`
+
+// setupSearch indexes the search document of messages
+func (postgresDialect) setupSearch(ctx context.Context, conn *sqlx.Conn) (bool, error) {
+	query := `CREATE INDEX IF NOT EXISTS idx_messages_search ON email_messages USING GIN (` + searchDocument + `)`
+	if _, err := conn.ExecContext(ctx, query); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (postgresDialect) searchMessages(accountID int64, terms []string, limit int) (string, []any) {
+	query := `
+		SELECT email_messages.*, ts_headline('simple', COALESCE(body_text, ''), q, ?) AS snippet
+		FROM email_messages, plainto_tsquery('simple', ?) q
+		WHERE account_id = ? AND is_deleted = false AND ` + searchDocument + ` @@ q
+		ORDER BY id DESC LIMIT ?
+	`
+	options := fmt.Sprintf(`StartSel="%s", StopSel="%s", MaxWords=20, MinWords=8, MaxFragments=1`, SnippetStart, SnippetEnd)
+	return query, []any{options, strings.Join(terms, " "), accountID, limit}
+}
+
+// maintain only refreshes planner statistics; autovacuum reclaims space
+func (postgresDialect) maintain(ctx context.Context, conn *sqlx.Conn, stats *MaintenanceStats) error {
+	if _, err := conn.ExecContext(ctx, `ANALYZE`); err != nil {
+		return fmt.Errorf("failed to analyze: %w", err)
+	}
+	return nil
+}
+
+func (postgresDialect) diskSize(ctx context.Context, db *sqlx.DB) int64 {
+	var size int64
+	db.GetContext(ctx, &size, `SELECT pg_database_size(current_database())`)
+	return size
+}
@@ -0,0 +1,6 @@
+//go:build postgres
+
+package database
+
+// The pgx driver is only linked into builds with -tags postgres
+import _ "github.com/jackc/pgx/v5/stdlib"
@@ -0,0 +1,173 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mixelka/emailresend/pkg/models"
+)
+
+// GetDueQueuedMessages returns queued messages of accounts served by botID
+// that are ready for delivery, oldest first
+func (db *DB) GetDueQueuedMessages(ctx context.Context, botID int64, limit int) ([]*models.QueuedMessage, error) {
+	var items []*models.QueuedMessage
+	query := `
+		SELECT q.* FROM send_queue q
+		JOIN email_messages m ON m.id = q.message_id
+		JOIN email_accounts a ON a.id = m.account_id
+		WHERE q.next_attempt_at <= ? AND a.bot_id = ?
+		ORDER BY q.id LIMIT ?
+	`
+	err := db.SelectContext(ctx, &items, query, time.Now(), botID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get queued messages: %w", err)
+	}
+	return items, nil
+}
+
+// RescheduleQueuedMessage records a failed attempt and postpones the next one
+func (db *DB) RescheduleQueuedMessage(ctx context.Context, id int64, attempts int, nextAttempt time.Time, lastErr string) error {
+	query := `UPDATE send_queue SET attempts = ?, next_attempt_at = ?, last_error = ? WHERE id = ?`
+	_, err := db.ExecContext(ctx, query, attempts, nextAttempt, lastErr, id)
+	if err != nil {
+		return fmt.Errorf("failed to reschedule queued message: %w", err)
+	}
+	return nil
+}
+
+// ReleaseHeldMessages makes the messages of an account held for quiet hours
+// due now. Held messages are the postponed ones without a send error.
+func (db *DB) ReleaseHeldMessages(ctx context.Context, accountID int64) error {
+	query := `
+		UPDATE send_queue SET next_attempt_at = ?
+		WHERE last_error = '' AND next_attempt_at > ?
+		AND message_id IN (SELECT id FROM email_messages WHERE account_id = ?)
+	`
+	now := time.Now()
+	_, err := db.ExecContext(ctx, query, now, now, accountID)
+	if err != nil {
+		return fmt.Errorf("failed to release held messages: %w", err)
+	}
+	return nil
+}
+
+// DeleteQueuedMessage removes a message from the send queue
+func (db *DB) DeleteQueuedMessage(ctx context.Context, id int64) error {
+	query := `DELETE FROM send_queue WHERE id = ?`
+	_, err := db.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete queued message: %w", err)
+	}
+	return nil
+}
+
+// CountQueuedMessages returns the number of messages waiting for delivery
+func (db *DB) CountQueuedMessages(ctx context.Context) (int, error) {
+	var count int
+	err := db.GetContext(ctx, &count, `SELECT COUNT(*) FROM send_queue`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count queued messages: %w", err)
+	}
+	return count, nil
+}
+
+// MarkDeliveryFailed records that the send queue gave up on a message
+func (db *DB) MarkDeliveryFailed(ctx context.Context, messageID int64, lastErr string) error {
+	query := `
+		UPDATE email_messages SET delivery_status = ?, delivery_error = ?, delivery_retries = delivery_retries + 1
+		WHERE id = ?
+	`
+	_, err := db.ExecContext(ctx, query, models.DeliveryFailed, lastErr, messageID)
+	if err != nil {
+		return fmt.Errorf("failed to mark delivery failed: %w", err)
+	}
+	return nil
+}
+
+// ClearDeliveryFailure marks a previously failed message as delivered
+func (db *DB) ClearDeliveryFailure(ctx context.Context, messageID int64) error {
+	query := `
+		UPDATE email_messages SET delivery_status = '', delivery_error = '', delivery_retries = 0
+		WHERE id = ? AND delivery_status != ''
+	`
+	_, err := db.ExecContext(ctx, query, messageID)
+	if err != nil {
+		return fmt.Errorf("failed to clear delivery failure: %w", err)
+	}
+	return nil
+}
+
+// ScheduleRedelivery queues a failed message again for the given time.
+// lastErr is kept on the queue item so that releasing quiet hours does not
+// make it due early.
+func (db *DB) ScheduleRedelivery(ctx context.Context, messageID int64, at time.Time, lastErr string) error {
+	query := `
+		INSERT INTO send_queue (message_id, attempts, last_error, next_attempt_at, created_at)
+		VALUES (?, 0, ?, ?, ?)
+		ON CONFLICT (message_id) DO UPDATE SET attempts = 0, last_error = excluded.last_error, next_attempt_at = excluded.next_attempt_at
+	`
+	_, err := db.ExecContext(ctx, query, messageID, lastErr, at, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to schedule redelivery: %w", err)
+	}
+	return nil
+}
+
+// RedeliverMessage queues a message for delivery now and resets its
+// automatic redeliveries
+func (db *DB) RedeliverMessage(ctx context.Context, messageID int64) error {
+	if err := db.ScheduleRedelivery(ctx, messageID, time.Now(), ""); err != nil {
+		return err
+	}
+	query := `UPDATE email_messages SET delivery_retries = 0 WHERE id = ?`
+	_, err := db.ExecContext(ctx, query, messageID)
+	if err != nil {
+		return fmt.Errorf("failed to reset redeliveries: %w", err)
+	}
+	return nil
+}
+
+// GetFailedMessages returns the messages of an account the send queue gave
+// up on, newest first
+func (db *DB) GetFailedMessages(ctx context.Context, accountID int64, limit int) ([]*models.EmailMessage, error) {
+	var messages []*models.EmailMessage
+	query := `
+		SELECT * FROM email_messages
+		WHERE account_id = ? AND delivery_status = ?
+		ORDER BY id DESC LIMIT ?
+	`
+	err := db.SelectContext(ctx, &messages, query, accountID, models.DeliveryFailed, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get failed messages: %w", err)
+	}
+	return messages, nil
+}
+
+// CountFailedMessages returns the number of undelivered messages of an account
+func (db *DB) CountFailedMessages(ctx context.Context, accountID int64) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM email_messages WHERE account_id = ? AND delivery_status = ?`
+	err := db.GetContext(ctx, &count, query, accountID, models.DeliveryFailed)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count failed messages: %w", err)
+	}
+	return count, nil
+}
+
+// RedeliverFailedMessages queues all undelivered messages of an account for
+// delivery now, oldest first. Returns the number of messages queued.
+func (db *DB) RedeliverFailedMessages(ctx context.Context, accountID int64) (int, error) {
+	var ids []int64
+	query := `SELECT id FROM email_messages WHERE account_id = ? AND delivery_status = ? ORDER BY id`
+	if err := db.SelectContext(ctx, &ids, query, accountID, models.DeliveryFailed); err != nil {
+		return 0, fmt.Errorf("failed to get failed messages: %w", err)
+	}
+
+	for _, id := range ids {
+		if err := db.RedeliverMessage(ctx, id); err != nil {
+			return 0, err
+		}
+	}
+	return len(ids), nil
+}
@@ -0,0 +1,212 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/mixelka/emailresend/pkg/models"
+)
+
+// Markers around the matched terms in SearchResult.Snippet
+const (
+	SnippetStart = "\x02"
+	SnippetEnd   = "\x03"
+)
+
+// snippetLength is the length in characters of snippets built without a
+// full-text index
+const snippetLength = 160
+
+// searchSchema is the FTS5 index of email_messages, kept in sync by triggers
+const searchSchema = `
+CREATE VIRTUAL TABLE IF NOT EXISTS email_messages_fts USING fts5(
+    subject, from_name, from_addr, body_text,
+    content='email_messages', content_rowid='id',
+    tokenize='unicode61 remove_diacritics 2'
+);
+
+CREATE TRIGGER IF NOT EXISTS email_messages_fts_insert AFTER INSERT ON email_messages BEGIN
+    INSERT INTO email_messages_fts (rowid, subject, from_name, from_addr, body_text)
+    VALUES (new.id, new.subject, new.from_name, new.from_addr, new.body_text);
+END;
+
+CREATE TRIGGER IF NOT EXISTS email_messages_fts_delete AFTER DELETE ON email_messages BEGIN
+    INSERT INTO email_messages_fts (email_messages_fts, rowid, subject, from_name, from_addr, body_text)
+    VALUES ('delete', old.id, old.subject, old.from_name, old.from_addr, old.body_text);
+END;
+
+CREATE TRIGGER IF NOT EXISTS email_messages_fts_update AFTER UPDATE OF subject, from_name, from_addr, body_text ON email_messages BEGIN
+    INSERT INTO email_messages_fts (email_messages_fts, rowid, subject, from_name, from_addr, body_text)
+    VALUES ('delete', old.id, old.subject, old.from_name, old.from_addr, old.body_text);
+    INSERT INTO email_messages_fts (rowid, subject, from_name, from_addr, body_text)
+    VALUES (new.id, new.subject, new.from_name, new.from_addr, new.body_text);
+END;
+`
+
+// searchTriggers are the triggers of searchSchema
+var searchTriggers = []string{"email_messages_fts_insert", "email_messages_fts_delete", "email_messages_fts_update"}
+
+// SearchResult is a message matching a search with a snippet of the match
+type SearchResult struct {
+	models.EmailMessage
+	Snippet string `db:"snippet"`
+}
+
+// SearchMessages returns the messages of an account containing all words of
+// query, newest first. Without a full-text index the words are matched as
+// substrings.
+func (db *DB) SearchMessages(ctx context.Context, accountID int64, query string, limit int) ([]*SearchResult, error) {
+	terms := strings.Fields(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	limit = Page{Limit: limit}.normalize().Limit
+
+	var sqlQuery string
+	var args []any
+	if db.fullText {
+		sqlQuery, args = db.dialect.searchMessages(accountID, terms, limit)
+	} else {
+		sqlQuery, args = db.likeSearch(accountID, terms, limit)
+	}
+
+	var results []*SearchResult
+	if err := db.SelectContext(ctx, &results, sqlQuery, args...); err != nil {
+		return nil, fmt.Errorf("failed to search messages: %w", err)
+	}
+	if !db.fullText {
+		for _, r := range results {
+			r.Snippet = makeSnippet(r.BodyText, terms)
+		}
+	}
+	return results, nil
+}
+
+// likeSearch returns a query matching every term as a substring of the
+// subject, sender or body
+func (db *DB) likeSearch(accountID int64, terms []string, limit int) (string, []any) {
+	like := db.dialect.likeNoCase()
+	var sb strings.Builder
+	sb.WriteString(`SELECT *, '' AS snippet FROM email_messages WHERE account_id = ? AND is_deleted = false`)
+	args := []any{accountID}
+	for _, term := range terms {
+		sb.WriteString(fmt.Sprintf(" AND (subject %[1]s ? OR from_name %[1]s ? OR from_addr %[1]s ? OR body_text %[1]s ?)", like))
+		pattern := "%" + term + "%"
+		args = append(args, pattern, pattern, pattern, pattern)
+	}
+	sb.WriteString(` ORDER BY id DESC LIMIT ?`)
+	return sb.String(), append(args, limit)
+}
+
+// makeSnippet cuts the text around the first term found and marks the terms
+func makeSnippet(text string, terms []string) string {
+	runes := []rune(strings.Join(strings.Fields(text), " "))
+	lower := []rune(strings.ToLower(string(runes)))
+	needles := make([][]rune, len(terms))
+	for i, term := range terms {
+		needles[i] = []rune(strings.ToLower(term))
+	}
+
+	// match returns the length of the term starting at i, 0 if none does
+	match := func(i int) int {
+		for _, needle := range needles {
+			if len(needle) > 0 && i+len(needle) <= len(lower) && string(lower[i:i+len(needle)]) == string(needle) {
+				return len(needle)
+			}
+		}
+		return 0
+	}
+
+	start := 0
+	for i := range lower {
+		if match(i) > 0 {
+			start = max(0, i-snippetLength/4)
+			break
+		}
+	}
+	end := min(len(runes), start+snippetLength)
+
+	var sb strings.Builder
+	if start > 0 {
+		sb.WriteString("…")
+	}
+	for i := start; i < end; {
+		if n := match(i); n > 0 {
+			n = min(n, end-i)
+			sb.WriteString(SnippetStart + string(runes[i:i+n]) + SnippetEnd)
+			i += n
+			continue
+		}
+		sb.WriteRune(runes[i])
+		i++
+	}
+	if end < len(runes) {
+		sb.WriteString("…")
+	}
+	return sb.String()
+}
+
+// setupSearch creates the FTS5 index if SQLite was built with it (the
+// sqlite_fts5 build tag) and fills it from the stored messages
+func (sqliteDialect) setupSearch(ctx context.Context, conn *sqlx.Conn) (bool, error) {
+	var enabled bool
+	if err := conn.GetContext(ctx, &enabled, `SELECT sqlite_compileoption_used('ENABLE_FTS5')`); err != nil {
+		return false, err
+	}
+	if !enabled {
+		// Triggers left by a build with FTS5 would make every insert fail
+		for _, trigger := range searchTriggers {
+			if _, err := conn.ExecContext(ctx, `DROP TRIGGER IF EXISTS `+trigger); err != nil {
+				return false, err
+			}
+		}
+		return false, nil
+	}
+
+	var triggers int
+	query := `SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name IN (?, ?, ?)`
+	if err := conn.GetContext(ctx, &triggers, query, searchTriggers[0], searchTriggers[1], searchTriggers[2]); err != nil {
+		return false, err
+	}
+	if triggers == len(searchTriggers) {
+		return true, nil
+	}
+
+	// The index is new or was not kept in sync by a build without FTS5
+	tx, err := conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, searchSchema); err != nil {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO email_messages_fts (email_messages_fts) VALUES ('rebuild')`); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func (sqliteDialect) searchMessages(accountID int64, terms []string, limit int) (string, []any) {
+	query := `
+		SELECT m.*, snippet(email_messages_fts, -1, ?, ?, '…', 16) AS snippet
+		FROM email_messages_fts
+		JOIN email_messages m ON m.id = email_messages_fts.rowid
+		WHERE email_messages_fts MATCH ? AND m.account_id = ? AND m.is_deleted = false
+		ORDER BY m.id DESC LIMIT ?
+	`
+	return query, []any{SnippetStart, SnippetEnd, ftsQuery(terms), accountID, limit}
+}
+
+// ftsQuery quotes the terms for FTS5, so none is read as query syntax, and
+// matches them as word prefixes
+func ftsQuery(terms []string) string {
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = `"` + strings.ReplaceAll(term, `"`, `""`) + `"*`
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,96 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+// RotationStats counts the values re-encrypted by RotateSecrets
+type RotationStats struct {
+	Passwords   int
+	OAuthTokens int
+	SMIMEKeys   int
+}
+
+// RotateSecrets replaces every stored account password, OAuth token and
+// S/MIME private key with the result of reencrypt in one transaction: if any value fails, none is
+// changed
+func (db *DB) RotateSecrets(ctx context.Context, reencrypt func(string) (string, error)) (*RotationStats, error) {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var accounts []struct {
+		ID       int64  `db:"id"`
+		Password string `db:"password"`
+	}
+	if err := tx.SelectContext(ctx, &accounts, `SELECT id, password FROM email_accounts WHERE password != ''`); err != nil {
+		return nil, fmt.Errorf("failed to get passwords: %w", err)
+	}
+
+	var tokens []struct {
+		AccountID    int64  `db:"account_id"`
+		AccessToken  string `db:"access_token"`
+		RefreshToken string `db:"refresh_token"`
+	}
+	if err := tx.SelectContext(ctx, &tokens, `SELECT account_id, access_token, refresh_token FROM oauth_tokens`); err != nil {
+		return nil, fmt.Errorf("failed to get oauth tokens: %w", err)
+	}
+
+	var keys []struct {
+		AccountID  int64  `db:"account_id"`
+		PrivateKey string `db:"private_key"`
+	}
+	if err := tx.SelectContext(ctx, &keys, `SELECT account_id, private_key FROM smime_keys`); err != nil {
+		return nil, fmt.Errorf("failed to get smime keys: %w", err)
+	}
+
+	stats := &RotationStats{}
+	for _, a := range accounts {
+		password, err := reencrypt(a.Password)
+		if err != nil {
+			return nil, fmt.Errorf("failed to re-encrypt password of account %d: %w", a.ID, err)
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE email_accounts SET password = ? WHERE id = ?`, password, a.ID); err != nil {
+			return nil, fmt.Errorf("failed to update password: %w", err)
+		}
+		stats.Passwords++
+	}
+
+	for _, t := range tokens {
+		// The access token is empty until the first refresh
+		access := t.AccessToken
+		if access != "" {
+			if access, err = reencrypt(access); err != nil {
+				return nil, fmt.Errorf("failed to re-encrypt access token of account %d: %w", t.AccountID, err)
+			}
+		}
+		refresh, err := reencrypt(t.RefreshToken)
+		if err != nil {
+			return nil, fmt.Errorf("failed to re-encrypt refresh token of account %d: %w", t.AccountID, err)
+		}
+		query := `UPDATE oauth_tokens SET access_token = ?, refresh_token = ? WHERE account_id = ?`
+		if _, err := tx.ExecContext(ctx, query, access, refresh, t.AccountID); err != nil {
+			return nil, fmt.Errorf("failed to update oauth token: %w", err)
+		}
+		stats.OAuthTokens++
+	}
+
+	for _, k := range keys {
+		key, err := reencrypt(k.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to re-encrypt smime key of account %d: %w", k.AccountID, err)
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE smime_keys SET private_key = ? WHERE account_id = ?`, key, k.AccountID); err != nil {
+			return nil, fmt.Errorf("failed to update smime key: %w", err)
+		}
+		stats.SMIMEKeys++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit key rotation: %w", err)
+	}
+	return stats, nil
+}
@@ -0,0 +1,81 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mixelka/emailresend/pkg/models"
+)
+
+// CreateSentMessage stores an outgoing email
+func (db *DB) CreateSentMessage(ctx context.Context, msg *models.SentMessage) error {
+	query := `
+		INSERT INTO sent_messages (account_id, reply_to, message_id, to_addr, subject, body, status, error, created_by, created_at, sent_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`
+	now := time.Now()
+	var id int64
+	err := db.GetContext(ctx, &id, query,
+		msg.AccountID,
+		msg.ReplyTo,
+		msg.MessageID,
+		msg.ToAddr,
+		msg.Subject,
+		msg.Body,
+		msg.Status,
+		msg.Error,
+		msg.CreatedBy,
+		now,
+		msg.SentAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create sent message: %w", err)
+	}
+
+	msg.ID = id
+	msg.CreatedAt = now
+	return nil
+}
+
+// GetSentMessage returns an outgoing email by ID
+func (db *DB) GetSentMessage(ctx context.Context, id int64) (*models.SentMessage, error) {
+	var msg models.SentMessage
+	err := db.GetContext(ctx, &msg, `SELECT * FROM sent_messages WHERE id = ?`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sent message: %w", err)
+	}
+	return &msg, nil
+}
+
+// ClaimSentDraft moves a draft to SentSending. Returns false if it is no
+// longer a draft, so a double-clicked confirmation sends only once.
+func (db *DB) ClaimSentDraft(ctx context.Context, id int64) (bool, error) {
+	query := `UPDATE sent_messages SET status = ? WHERE id = ? AND status = ?`
+	res, err := db.ExecContext(ctx, query, models.SentSending, id, models.SentDraft)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim draft: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to claim draft: %w", err)
+	}
+	return n == 1, nil
+}
+
+// UpdateSentMessageStatus saves the status, Message-ID, error and send time
+// of an outgoing email
+func (db *DB) UpdateSentMessageStatus(ctx context.Context, msg *models.SentMessage) error {
+	query := `UPDATE sent_messages SET status = ?, message_id = ?, error = ?, sent_at = ? WHERE id = ?`
+	_, err := db.ExecContext(ctx, query, msg.Status, msg.MessageID, msg.Error, msg.SentAt, msg.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update sent message: %w", err)
+	}
+	return nil
+}
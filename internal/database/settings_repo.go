@@ -0,0 +1,78 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mixelka/emailresend/pkg/models"
+)
+
+// GetChatSettings returns settings for a chat, or defaults if none are stored
+func (db *DB) GetChatSettings(ctx context.Context, chatID int64) (*models.ChatSettings, error) {
+	var settings models.ChatSettings
+	query := `SELECT * FROM chat_settings WHERE chat_id = ?`
+	err := db.GetContext(ctx, &settings, query, chatID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.DefaultChatSettings(chatID), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chat settings: %w", err)
+	}
+	return &settings, nil
+}
+
+// SaveChatSettings creates or updates settings for a chat
+func (db *DB) SaveChatSettings(ctx context.Context, settings *models.ChatSettings) error {
+	query := `
+		INSERT INTO chat_settings (chat_id, parse_mode, custom_emoji, status_topic_id, status_msg_id, status_bot_id, disabled_extractors, broadcast_opt_out, permissions, language, auto_topics, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(chat_id) DO UPDATE SET
+			parse_mode = excluded.parse_mode,
+			custom_emoji = excluded.custom_emoji,
+			status_topic_id = excluded.status_topic_id,
+			status_msg_id = excluded.status_msg_id,
+			status_bot_id = excluded.status_bot_id,
+			disabled_extractors = excluded.disabled_extractors,
+			broadcast_opt_out = excluded.broadcast_opt_out,
+			permissions = excluded.permissions,
+			language = excluded.language,
+			auto_topics = excluded.auto_topics,
+			updated_at = excluded.updated_at
+	`
+	now := time.Now()
+	_, err := db.ExecContext(ctx, query,
+		settings.ChatID,
+		settings.ParseMode,
+		settings.CustomEmoji,
+		settings.StatusTopicID,
+		settings.StatusMsgID,
+		settings.StatusBotID,
+		settings.DisabledExtractors,
+		settings.BroadcastOptOut,
+		settings.Permissions,
+		settings.Language,
+		settings.AutoTopics,
+		now,
+		now,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save chat settings: %w", err)
+	}
+
+	settings.UpdatedAt = now
+	return nil
+}
+
+// GetStatusBoardChats returns settings of chats with a status board owned by botID
+func (db *DB) GetStatusBoardChats(ctx context.Context, botID int64) ([]*models.ChatSettings, error) {
+	var settings []*models.ChatSettings
+	query := `SELECT * FROM chat_settings WHERE status_msg_id != 0 AND status_bot_id = ?`
+	err := db.SelectContext(ctx, &settings, query, botID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get status board chats: %w", err)
+	}
+	return settings, nil
+}
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mixelka/emailresend/pkg/models"
+)
+
+// SaveSMIMEKey creates or replaces the S/MIME key of an account
+func (db *DB) SaveSMIMEKey(ctx context.Context, key *models.SMIMEKey) error {
+	query := `
+		INSERT INTO smime_keys (account_id, private_key, certificate, updated_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(account_id) DO UPDATE SET
+			private_key = excluded.private_key,
+			certificate = excluded.certificate,
+			updated_at = excluded.updated_at
+	`
+	now := time.Now()
+	_, err := db.ExecContext(ctx, query, key.AccountID, key.PrivateKey, key.Certificate, now)
+	if err != nil {
+		return fmt.Errorf("failed to save smime key: %w", err)
+	}
+	key.UpdatedAt = now
+	return nil
+}
+
+// GetSMIMEKey returns the S/MIME key of an account
+func (db *DB) GetSMIMEKey(ctx context.Context, accountID int64) (*models.SMIMEKey, error) {
+	var key models.SMIMEKey
+	err := db.GetContext(ctx, &key, `SELECT * FROM smime_keys WHERE account_id = ?`, accountID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get smime key: %w", err)
+	}
+	return &key, nil
+}
+
+// DeleteSMIMEKey removes the S/MIME key of an account. Returns ErrNotFound if
+// it has none.
+func (db *DB) DeleteSMIMEKey(ctx context.Context, accountID int64) error {
+	res, err := db.ExecContext(ctx, `DELETE FROM smime_keys WHERE account_id = ?`, accountID)
+	if err != nil {
+		return fmt.Errorf("failed to delete smime key: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// GetState returns a persisted instance-wide value, or "" if it is not set
+func (db *DB) GetState(ctx context.Context, key string) (string, error) {
+	var value string
+	err := db.GetContext(ctx, &value, `SELECT value FROM bot_state WHERE key = ?`, key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get state %s: %w", key, err)
+	}
+	return value, nil
+}
+
+// SetState persists an instance-wide value
+func (db *DB) SetState(ctx context.Context, key, value string) error {
+	query := `
+		INSERT INTO bot_state (key, value) VALUES (?, ?)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value
+	`
+	if _, err := db.ExecContext(ctx, query, key, value); err != nil {
+		return fmt.Errorf("failed to set state %s: %w", key, err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mixelka/emailresend/pkg/models"
+)
+
+// messageBytes is the stored size of a message row: its bodies plus the
+// archived raw message (which includes the attachments)
+func (db *DB) messageBytes(alias string) string {
+	return fmt.Sprintf("(%s + %s + %s.raw_size)",
+		db.dialect.byteLength(alias+".body_text"),
+		db.dialect.byteLength(alias+".body_html"),
+		alias)
+}
+
+// GetChatStorageUsage returns the bytes stored for the emails of a chat
+func (db *DB) GetChatStorageUsage(ctx context.Context, chatID int64) (int64, error) {
+	var used int64
+	query := `
+		SELECT COALESCE(SUM(` + db.messageBytes("m") + `), 0)
+		FROM email_messages m
+		JOIN email_accounts a ON a.id = m.account_id
+		WHERE a.chat_id = ?
+	`
+	err := db.GetContext(ctx, &used, query, chatID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get chat storage usage: %w", err)
+	}
+	return used, nil
+}
+
+// GetStorageChats returns the chats with accounts of a bot
+func (db *DB) GetStorageChats(ctx context.Context, botID int64) ([]int64, error) {
+	var chats []int64
+	query := `SELECT DISTINCT chat_id FROM email_accounts WHERE bot_id = ? ORDER BY chat_id`
+	err := db.SelectContext(ctx, &chats, query, botID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get storage chats: %w", err)
+	}
+	return chats, nil
+}
+
+// GetTrimCandidates returns the oldest messages of a chat that still have a
+// body, skipping those waiting for delivery
+func (db *DB) GetTrimCandidates(ctx context.Context, chatID int64, limit int) ([]*models.EmailMessage, error) {
+	var messages []*models.EmailMessage
+	query := `
+		SELECT m.* FROM email_messages m
+		JOIN email_accounts a ON a.id = m.account_id
+		WHERE a.chat_id = ? AND m.body_trimmed = false
+		AND ` + db.messageBytes("m") + ` > 0
+		AND NOT EXISTS (SELECT 1 FROM send_queue q WHERE q.message_id = m.id)
+		ORDER BY m.id
+		LIMIT ?
+	`
+	err := db.SelectContext(ctx, &messages, query, chatID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trim candidates: %w", err)
+	}
+	return messages, nil
+}
+
+// TrimMessageBody removes the bodies and the archived raw message of a
+// message, keeping its metadata and detected codes
+func (db *DB) TrimMessageBody(ctx context.Context, id int64) error {
+	query := `
+		UPDATE email_messages
+		SET body_text = '', body_html = '', raw_key = '', raw_size = 0, body_trimmed = true
+		WHERE id = ?
+	`
+	_, err := db.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to trim message body: %w", err)
+	}
+	return nil
+}
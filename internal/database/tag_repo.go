@@ -0,0 +1,91 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mixelka/emailresend/pkg/models"
+)
+
+// AddAccountTags tags an account; tags it already has are ignored
+func (db *DB) AddAccountTags(ctx context.Context, accountID int64, tags []string) error {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO account_tags (account_id, tag, created_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT (account_id, tag) DO NOTHING
+	`
+	now := time.Now()
+	for _, tag := range tags {
+		if _, err := tx.ExecContext(ctx, query, accountID, tag, now); err != nil {
+			return fmt.Errorf("failed to add account tag: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit account tags: %w", err)
+	}
+	return nil
+}
+
+// RemoveAccountTag removes a tag from an account
+func (db *DB) RemoveAccountTag(ctx context.Context, accountID int64, tag string) error {
+	query := `DELETE FROM account_tags WHERE account_id = ? AND tag = ?`
+	res, err := db.ExecContext(ctx, query, accountID, tag)
+	if err != nil {
+		return fmt.Errorf("failed to remove account tag: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetAccountTags returns the tags of an account in alphabetical order
+func (db *DB) GetAccountTags(ctx context.Context, accountID int64) ([]string, error) {
+	var tags []string
+	query := `SELECT tag FROM account_tags WHERE account_id = ? ORDER BY tag`
+	err := db.SelectContext(ctx, &tags, query, accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account tags: %w", err)
+	}
+	return tags, nil
+}
+
+// GetChatTags returns the tags used in a chat in alphabetical order
+func (db *DB) GetChatTags(ctx context.Context, chatID int64) ([]string, error) {
+	var tags []string
+	query := `
+		SELECT DISTINCT t.tag FROM account_tags t
+		JOIN email_accounts a ON a.id = t.account_id
+		WHERE a.chat_id = ?
+		ORDER BY t.tag
+	`
+	err := db.SelectContext(ctx, &tags, query, chatID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chat tags: %w", err)
+	}
+	return tags, nil
+}
+
+// GetAccountsByTag returns the accounts of a chat with a tag, ordered by topic
+func (db *DB) GetAccountsByTag(ctx context.Context, chatID int64, tag string) ([]*models.EmailAccount, error) {
+	var accounts []*models.EmailAccount
+	query := `
+		SELECT a.* FROM email_accounts a
+		JOIN account_tags t ON t.account_id = a.id
+		WHERE a.chat_id = ? AND t.tag = ?
+		ORDER BY a.topic_id
+	`
+	err := db.SelectContext(ctx, &accounts, query, chatID, tag)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get accounts by tag: %w", err)
+	}
+	return accounts, nil
+}
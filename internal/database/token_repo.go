@@ -0,0 +1,80 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mixelka/emailresend/pkg/models"
+)
+
+// SaveOAuthToken creates or replaces the OAuth token of an account and
+// clears its revoked flag
+func (db *DB) SaveOAuthToken(ctx context.Context, token *models.OAuthToken) error {
+	query := `
+		INSERT INTO oauth_tokens (account_id, provider, access_token, refresh_token, expires_at, revoked, updated_at)
+		VALUES (?, ?, ?, ?, ?, false, ?)
+		ON CONFLICT(account_id) DO UPDATE SET
+			provider = excluded.provider,
+			access_token = excluded.access_token,
+			refresh_token = excluded.refresh_token,
+			expires_at = excluded.expires_at,
+			revoked = false,
+			updated_at = excluded.updated_at
+	`
+	now := time.Now()
+	_, err := db.ExecContext(ctx, query,
+		token.AccountID,
+		token.Provider,
+		token.AccessToken,
+		token.RefreshToken,
+		token.ExpiresAt,
+		now,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save oauth token: %w", err)
+	}
+	token.Revoked = false
+	token.UpdatedAt = now
+	return nil
+}
+
+// GetOAuthToken returns the OAuth token of an account
+func (db *DB) GetOAuthToken(ctx context.Context, accountID int64) (*models.OAuthToken, error) {
+	var token models.OAuthToken
+	err := db.GetContext(ctx, &token, `SELECT * FROM oauth_tokens WHERE account_id = ?`, accountID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get oauth token: %w", err)
+	}
+	return &token, nil
+}
+
+// GetExpiringOAuthTokens returns tokens of active accounts served by botID
+// whose access token expires before the given time
+func (db *DB) GetExpiringOAuthTokens(ctx context.Context, botID int64, before time.Time) ([]*models.OAuthToken, error) {
+	var tokens []*models.OAuthToken
+	query := `
+		SELECT t.* FROM oauth_tokens t
+		JOIN email_accounts a ON a.id = t.account_id
+		WHERE a.is_active = true AND a.bot_id = ? AND t.revoked = false AND t.expires_at < ?
+		ORDER BY t.expires_at
+	`
+	if err := db.SelectContext(ctx, &tokens, query, botID, before); err != nil {
+		return nil, fmt.Errorf("failed to get expiring oauth tokens: %w", err)
+	}
+	return tokens, nil
+}
+
+// MarkOAuthTokenRevoked flags the token of an account as no longer accepted
+func (db *DB) MarkOAuthTokenRevoked(ctx context.Context, accountID int64) error {
+	query := `UPDATE oauth_tokens SET revoked = true, updated_at = ? WHERE account_id = ?`
+	if _, err := db.ExecContext(ctx, query, time.Now(), accountID); err != nil {
+		return fmt.Errorf("failed to mark oauth token revoked: %w", err)
+	}
+	return nil
+}
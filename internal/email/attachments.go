@@ -0,0 +1,237 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-message/mail"
+
+	"github.com/mixelka/emailresend/pkg/models"
+)
+
+// AttachmentData is a downloaded attachment
+type AttachmentData struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// attachmentInfo reports whether a MIME part is an attachment (or an inline
+// non-text part such as an embedded image) and returns its name and type
+func attachmentInfo(header mail.PartHeader) (filename, contentType string, ok bool) {
+	switch h := header.(type) {
+	case *mail.AttachmentHeader:
+		contentType, _, _ = h.ContentType()
+		filename, _ = h.Filename()
+	case *mail.InlineHeader:
+		var params map[string]string
+		contentType, params, _ = h.ContentType()
+		if contentType == "" || strings.HasPrefix(contentType, "text/") {
+			return "", "", false
+		}
+		filename = params["name"]
+	default:
+		return "", "", false
+	}
+	// The version part of PGP/MIME holds only "Version: 1"
+	if contentType == "application/pgp-encrypted" {
+		return "", "", false
+	}
+
+	if filename == "" {
+		filename = "attachment"
+		if exts, _ := mime.ExtensionsByType(contentType); len(exts) > 0 {
+			filename += exts[0]
+		}
+	}
+	return filename, contentType, true
+}
+
+// readAttachment reads the attachment with the given index from a message body
+func readAttachment(body io.Reader, index int) (*AttachmentData, error) {
+	mr, err := mail.CreateReader(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create mail reader: %w", err)
+	}
+
+	current := 0
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read part: %w", err)
+		}
+
+		filename, contentType, ok := attachmentInfo(part.Header)
+		if !ok {
+			continue
+		}
+		if current != index {
+			current++
+			continue
+		}
+
+		var buf bytes.Buffer
+		if _, err := io.Copy(&buf, part.Body); err != nil {
+			return nil, fmt.Errorf("failed to read attachment: %w", err)
+		}
+		return &AttachmentData{
+			Filename:    filename,
+			ContentType: contentType,
+			Data:        buf.Bytes(),
+		}, nil
+	}
+
+	return nil, fmt.Errorf("attachment %d not found", index)
+}
+
+// maxInlineImagesSize limits the total size of the inline images read from
+// one message
+const maxInlineImagesSize = 10 << 20
+
+// readInlineImages reads the image parts with a Content-ID from a message
+// body. Images past maxInlineImagesSize are skipped.
+func readInlineImages(body io.Reader) (map[string]*AttachmentData, error) {
+	mr, err := mail.CreateReader(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create mail reader: %w", err)
+	}
+
+	images := make(map[string]*AttachmentData)
+	var total int64
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read part: %w", err)
+		}
+
+		id := strings.Trim(part.Header.Get("Content-Id"), "<> ")
+		filename, contentType, ok := attachmentInfo(part.Header)
+		if id == "" || !ok || !strings.HasPrefix(contentType, "image/") {
+			continue
+		}
+
+		var buf bytes.Buffer
+		if _, err := io.Copy(&buf, io.LimitReader(part.Body, maxInlineImagesSize-total+1)); err != nil {
+			return nil, fmt.Errorf("failed to read image: %w", err)
+		}
+		if total+int64(buf.Len()) > maxInlineImagesSize {
+			continue
+		}
+		total += int64(buf.Len())
+		images[id] = &AttachmentData{
+			Filename:    filename,
+			ContentType: contentType,
+			Data:        buf.Bytes(),
+		}
+	}
+	return images, nil
+}
+
+// FetchAttachment downloads the attachment with the given index of a message
+func (c *Client) FetchAttachment(ctx context.Context, uid uint32, index int) (*AttachmentData, error) {
+	var result *AttachmentData
+	err := c.fetchBody(uid, func(body io.Reader) (err error) {
+		result, err = readAttachment(body, index)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// FetchInlineImages downloads the images of a message referenced by
+// Content-ID, keyed by the ID without angle brackets
+func (c *Client) FetchInlineImages(ctx context.Context, uid uint32) (map[string]*AttachmentData, error) {
+	var result map[string]*AttachmentData
+	err := c.fetchBody(uid, func(body io.Reader) (err error) {
+		result, err = readInlineImages(body)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// FetchRaw downloads the complete RFC822 source of a message
+func (c *Client) FetchRaw(ctx context.Context, uid uint32) ([]byte, error) {
+	var raw []byte
+	err := c.fetchBody(uid, func(body io.Reader) (err error) {
+		raw, err = io.ReadAll(body)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+// fetchBody fetches the raw body of a message and passes it to read
+func (c *Client) fetchBody(uid uint32, read func(io.Reader) error) error {
+	c.lockCommand()
+	defer c.mu.Unlock()
+
+	if !c.connected || c.client == nil {
+		return errNotConnected
+	}
+
+	seqSet := new(imap.SeqSet)
+	seqSet.AddNum(uid)
+
+	section := &imap.BodySectionName{Peek: true}
+	items := []imap.FetchItem{imap.FetchUid, section.FetchItem()}
+
+	messages := make(chan *imap.Message, 1)
+	done := make(chan error, 1)
+
+	go func() {
+		done <- c.client.UidFetch(seqSet, items, messages)
+	}()
+
+	var (
+		found   bool
+		readErr = fmt.Errorf("message not found")
+	)
+	for msg := range messages {
+		if found {
+			continue
+		}
+		body := msg.GetBody(section)
+		if body == nil {
+			continue
+		}
+		found = true
+		readErr = read(body)
+	}
+
+	if err := <-done; err != nil {
+		return fmt.Errorf("failed to fetch: %w", classifyError(err))
+	}
+	return readErr
+}
+
+// attachmentMeta describes an attachment part, consuming its body to get the size
+func attachmentMeta(part *mail.Part) (models.Attachment, bool) {
+	filename, contentType, ok := attachmentInfo(part.Header)
+	if !ok {
+		return models.Attachment{}, false
+	}
+	size, _ := io.Copy(io.Discard, part.Body)
+	return models.Attachment{
+		Filename:    filename,
+		ContentType: contentType,
+		Size:        size,
+	}, true
+}
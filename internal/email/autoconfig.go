@@ -0,0 +1,255 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// discoveryTimeout bounds a single autoconfig or autodiscover request
+const discoveryTimeout = 5 * time.Second
+
+// discoveryMaxBody caps the size of an autoconfig or autodiscover response
+const discoveryMaxBody = 1 << 20
+
+// ispdbURL is the Thunderbird database of provider settings
+const ispdbURL = "https://autoconfig.thunderbird.net/v1.1/"
+
+var discoveryClient = &http.Client{Timeout: discoveryTimeout}
+
+// Servers are the mail servers of an account. IMAP is in the format of
+// ParseServer, SMTP is host:port (465 is implicit TLS, other ports STARTTLS)
+type Servers struct {
+	IMAP string
+	SMTP string // empty if not discovered
+}
+
+// lookupSRV resolves the servers published in RFC 6186 SRV records, IMAP over
+// implicit TLS preferred, and the submission server (RFC 8314 / RFC 6409)
+func lookupSRV(ctx context.Context, domain string) Servers {
+	var servers Servers
+	if target, port, ok := srvTarget(ctx, "imaps", domain); ok {
+		servers.IMAP = net.JoinHostPort(target, strconv.Itoa(port))
+	} else if target, port, ok := srvTarget(ctx, "imap", domain); ok {
+		servers.IMAP = net.JoinHostPort(target, strconv.Itoa(port)) + "/" + SecuritySTARTTLS
+	}
+	if target, port, ok := srvTarget(ctx, "submissions", domain); ok {
+		servers.SMTP = net.JoinHostPort(target, strconv.Itoa(port))
+	} else if target, port, ok := srvTarget(ctx, "submission", domain); ok {
+		servers.SMTP = net.JoinHostPort(target, strconv.Itoa(port))
+	}
+	return servers
+}
+
+// srvTarget returns the most preferred target of _service._tcp.domain; a
+// target of "." means the service is not offered (RFC 6186 section 3.4)
+func srvTarget(ctx context.Context, service, domain string) (string, int, bool) {
+	_, records, err := net.DefaultResolver.LookupSRV(ctx, service, "tcp", domain)
+	if err != nil || len(records) == 0 {
+		return "", 0, false
+	}
+	target := strings.TrimSuffix(records[0].Target, ".")
+	if target == "" || records[0].Port == 0 {
+		return "", 0, false
+	}
+	return target, int(records[0].Port), true
+}
+
+// autoconfigXML is a Mozilla autoconfig document (config-v1.1.xml)
+type autoconfigXML struct {
+	Providers []struct {
+		Incoming []autoconfigServer `xml:"incomingServer"`
+		Outgoing []autoconfigServer `xml:"outgoingServer"`
+	} `xml:"emailProvider"`
+}
+
+type autoconfigServer struct {
+	Type       string `xml:"type,attr"`
+	Hostname   string `xml:"hostname"`
+	Port       int    `xml:"port"`
+	SocketType string `xml:"socketType"` // SSL, STARTTLS or plain
+}
+
+// lookupAutoconfig fetches the Mozilla autoconfig of the domain: from the
+// provider itself, then from the Thunderbird ISP database
+func lookupAutoconfig(ctx context.Context, email, domain string) Servers {
+	urls := []string{
+		"https://autoconfig." + domain + "/mail/config-v1.1.xml?emailaddress=" + url.QueryEscape(email),
+		"https://" + domain + "/.well-known/autoconfig/mail/config-v1.1.xml",
+		ispdbURL + domain,
+	}
+	for _, u := range urls {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			continue
+		}
+		body, err := fetchDiscovery(req)
+		if err != nil {
+			continue
+		}
+		var doc autoconfigXML
+		if err := xml.Unmarshal(body, &doc); err != nil {
+			continue
+		}
+		if servers := doc.servers(email, domain); servers.IMAP != "" {
+			return servers
+		}
+	}
+	return Servers{}
+}
+
+// servers picks the first encrypted IMAP and SMTP servers of the document
+func (doc autoconfigXML) servers(email, domain string) Servers {
+	var servers Servers
+	for _, p := range doc.Providers {
+		for _, s := range p.Incoming {
+			if servers.IMAP != "" || s.Type != "imap" {
+				continue
+			}
+			if security, ok := autoconfigSecurity(s.SocketType); ok && s.Hostname != "" && s.Port > 0 {
+				servers.IMAP = net.JoinHostPort(expandAutoconfig(s.Hostname, email, domain), strconv.Itoa(s.Port))
+				if security != SecurityTLS {
+					servers.IMAP += "/" + security
+				}
+			}
+		}
+		for _, s := range p.Outgoing {
+			if servers.SMTP != "" || s.Type != "smtp" {
+				continue
+			}
+			if _, ok := autoconfigSecurity(s.SocketType); ok && s.Hostname != "" && s.Port > 0 {
+				servers.SMTP = net.JoinHostPort(expandAutoconfig(s.Hostname, email, domain), strconv.Itoa(s.Port))
+			}
+		}
+	}
+	return servers
+}
+
+// autoconfigSecurity maps an autoconfig socket type to the connection
+// security; unencrypted servers are skipped
+func autoconfigSecurity(socketType string) (string, bool) {
+	switch strings.ToUpper(socketType) {
+	case "SSL":
+		return SecurityTLS, true
+	case "STARTTLS":
+		return SecuritySTARTTLS, true
+	}
+	return "", false
+}
+
+// expandAutoconfig substitutes the placeholders autoconfig hostnames may have
+func expandAutoconfig(s, email, domain string) string {
+	local, _, _ := strings.Cut(email, "@")
+	return strings.NewReplacer(
+		"%EMAILADDRESS%", email,
+		"%EMAILLOCALPART%", local,
+		"%EMAILDOMAIN%", domain,
+	).Replace(s)
+}
+
+// autodiscoverRequest is the body of a Microsoft autodiscover POX request
+const autodiscoverRequest = `<?xml version="1.0" encoding="utf-8"?>
+<Autodiscover xmlns="http://schemas.microsoft.com/exchange/autodiscover/outlook/requestschema/2006">
+<Request>
+<EMailAddress>%s</EMailAddress>
+<AcceptableResponseSchema>http://schemas.microsoft.com/exchange/autodiscover/outlook/responseschema/2006a</AcceptableResponseSchema>
+</Request>
+</Autodiscover>`
+
+// autodiscoverXML is the response to autodiscoverRequest
+type autodiscoverXML struct {
+	Protocols []struct {
+		Type       string `xml:"Type"`
+		Server     string `xml:"Server"`
+		Port       int    `xml:"Port"`
+		SSL        string `xml:"SSL"`        // on (default) or off
+		Encryption string `xml:"Encryption"` // SSL, TLS, Auto or None; overrides SSL
+	} `xml:"Response>Account>Protocol"`
+}
+
+// lookupAutodiscover asks the Microsoft autodiscover endpoints of the domain
+func lookupAutodiscover(ctx context.Context, email, domain string) Servers {
+	var payload bytes.Buffer
+	if err := xml.EscapeText(&payload, []byte(email)); err != nil {
+		return Servers{}
+	}
+	body := fmt.Sprintf(autodiscoverRequest, payload.String())
+
+	urls := []string{
+		"https://autodiscover." + domain + "/autodiscover/autodiscover.xml",
+		"https://" + domain + "/autodiscover/autodiscover.xml",
+	}
+	for _, u := range urls {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, strings.NewReader(body))
+		if err != nil {
+			continue
+		}
+		req.Header.Set("Content-Type", "text/xml")
+		data, err := fetchDiscovery(req)
+		if err != nil {
+			continue
+		}
+		var doc autodiscoverXML
+		if err := xml.Unmarshal(data, &doc); err != nil {
+			continue
+		}
+		if servers := doc.servers(); servers.IMAP != "" {
+			return servers
+		}
+	}
+	return Servers{}
+}
+
+// servers picks the IMAP and SMTP servers of the response
+func (doc autodiscoverXML) servers() Servers {
+	var servers Servers
+	for _, p := range doc.Protocols {
+		if p.Server == "" || p.Port <= 0 {
+			continue
+		}
+		addr := net.JoinHostPort(p.Server, strconv.Itoa(p.Port))
+		security := SecurityTLS
+		switch {
+		case strings.EqualFold(p.Encryption, "TLS"):
+			security = SecuritySTARTTLS
+		case strings.EqualFold(p.Encryption, "None"), p.Encryption == "" && strings.EqualFold(p.SSL, "off"):
+			continue
+		}
+		switch strings.ToUpper(p.Type) {
+		case "IMAP":
+			if servers.IMAP == "" {
+				servers.IMAP = addr
+				if security != SecurityTLS {
+					servers.IMAP += "/" + security
+				}
+			}
+		case "SMTP":
+			if servers.SMTP == "" {
+				servers.SMTP = addr
+			}
+		}
+	}
+	return servers
+}
+
+// fetchDiscovery performs a discovery request and returns the body of a
+// successful response
+func fetchDiscovery(req *http.Request) ([]byte, error) {
+	resp, err := discoveryClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, discoveryMaxBody))
+}
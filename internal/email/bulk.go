@@ -0,0 +1,179 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/emersion/go-imap"
+
+	"github.com/mixelka/emailresend/pkg/models"
+)
+
+// bulkConcurrency is the number of accounts started in parallel by
+// StartAccounts
+const bulkConcurrency = 4
+
+// StopAccounts stops the connections of several accounts
+func (m *Manager) StopAccounts(accountIDs []int64) {
+	for _, id := range accountIDs {
+		m.RemoveAccount(id)
+	}
+}
+
+// StartAccounts starts the connections of several accounts in parallel and
+// returns the errors by account ID. Accounts already running are restarted.
+func (m *Manager) StartAccounts(ctx context.Context, accounts []*models.EmailAccount) map[int64]error {
+	errs := make(map[int64]error)
+	sem := make(chan struct{}, bulkConcurrency)
+
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	for _, account := range accounts {
+		wg.Add(1)
+		go func(acc *models.EmailAccount) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			if err := m.RestartAccount(ctx, acc); err != nil {
+				m.logger.Error("failed to start account", "email", acc.Email, "error", err)
+				mu.Lock()
+				errs[acc.ID] = err
+				mu.Unlock()
+			}
+		}(account)
+	}
+	wg.Wait()
+
+	return errs
+}
+
+// bulkBatchSize is the number of messages changed by one STORE of
+// MarkAllRead and Purge
+const bulkBatchSize = 200
+
+// BulkFilter selects INBOX messages for MarkAllRead and Purge
+type BulkFilter struct {
+	From   string    // Part of the From header, e.g. an address or a domain; empty matches any sender
+	Before time.Time // Received before this day; zero matches any date
+}
+
+// BulkProgress is called after each batch of MarkAllRead and Purge with the
+// UIDs it changed and the number of messages done out of total
+type BulkProgress func(uids []uint32, done, total int)
+
+// CountBulk returns the number of INBOX messages matching a filter
+func (m *Manager) CountBulk(accountID int64, filter BulkFilter) (int, error) {
+	m.mu.RLock()
+	wrapper, exists := m.clients[accountID]
+	m.mu.RUnlock()
+
+	if !exists {
+		return 0, errNotConnected
+	}
+
+	uids, err := wrapper.client.searchBulk(filter, false)
+	return len(uids), err
+}
+
+// MarkAllRead sets \Seen on the unread INBOX messages matching a filter, in
+// batches of bulkBatchSize. Returns the number of messages marked.
+func (m *Manager) MarkAllRead(ctx context.Context, accountID int64, filter BulkFilter, progress BulkProgress) (int, error) {
+	return m.bulkStore(ctx, accountID, filter, true, imap.SeenFlag, progress)
+}
+
+// Purge deletes and expunges the INBOX messages matching a filter, in
+// batches of bulkBatchSize. Returns the number of messages deleted.
+func (m *Manager) Purge(ctx context.Context, accountID int64, filter BulkFilter, progress BulkProgress) (int, error) {
+	return m.bulkStore(ctx, accountID, filter, false, imap.DeletedFlag, progress)
+}
+
+// bulkStore adds a flag to the messages matching a filter batch by batch.
+// The connection is released between batches, so new mail keeps arriving
+// during long runs; a cancelled ctx stops after the current batch.
+func (m *Manager) bulkStore(ctx context.Context, accountID int64, filter BulkFilter, unseen bool, flag string, progress BulkProgress) (int, error) {
+	m.mu.RLock()
+	wrapper, exists := m.clients[accountID]
+	m.mu.RUnlock()
+
+	if !exists {
+		return 0, errNotConnected
+	}
+
+	uids, err := wrapper.client.searchBulk(filter, unseen)
+	if err != nil {
+		return 0, err
+	}
+	slices.Sort(uids)
+
+	done := 0
+	for batch := range slices.Chunk(uids, bulkBatchSize) {
+		if err := ctx.Err(); err != nil {
+			return done, err
+		}
+		if err := wrapper.client.storeFlag(batch, flag, flag == imap.DeletedFlag); err != nil {
+			return done, err
+		}
+		done += len(batch)
+		if progress != nil {
+			progress(batch, done, len(uids))
+		}
+	}
+	return done, nil
+}
+
+// searchBulk returns the UIDs of INBOX messages matching a filter, only
+// unread ones if unseen is set
+func (c *Client) searchBulk(filter BulkFilter, unseen bool) ([]uint32, error) {
+	c.lockCommand()
+	defer c.mu.Unlock()
+
+	if !c.connected || c.client == nil {
+		return nil, errNotConnected
+	}
+
+	criteria := imap.NewSearchCriteria()
+	criteria.WithoutFlags = []string{imap.DeletedFlag}
+	if unseen {
+		criteria.WithoutFlags = append(criteria.WithoutFlags, imap.SeenFlag)
+	}
+	if filter.From != "" {
+		criteria.Header.Add("From", filter.From)
+	}
+	criteria.Before = filter.Before
+	uids, err := c.client.UidSearch(criteria)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search: %w", classifyError(err))
+	}
+	return uids, nil
+}
+
+// storeFlag adds a flag to a set of messages, expunging them afterwards if
+// asked
+func (c *Client) storeFlag(uids []uint32, flag string, expunge bool) error {
+	c.lockCommand()
+	defer c.mu.Unlock()
+
+	if !c.connected || c.client == nil {
+		return errNotConnected
+	}
+
+	seqSet := new(imap.SeqSet)
+	seqSet.AddNum(uids...)
+	item := imap.FormatFlagsOp(imap.AddFlags, true)
+	if err := c.client.UidStore(seqSet, item, []interface{}{flag}, nil); err != nil {
+		return fmt.Errorf("failed to store flags: %w", classifyError(err))
+	}
+
+	if expunge {
+		if err := c.client.Expunge(nil); err != nil {
+			return fmt.Errorf("failed to expunge: %w", classifyError(err))
+		}
+	}
+	return nil
+}
@@ -1,30 +1,56 @@
 package email
 
 import (
+	"bytes"
+	"cmp"
 	"context"
-	"crypto/tls"
 	"fmt"
 	"io"
 	"log/slog"
-	"net"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/emersion/go-imap"
 	"github.com/emersion/go-imap/client"
 	"github.com/emersion/go-message/mail"
+
+	"github.com/mixelka/emailresend/pkg/models"
 )
 
 // RawEmail represents a raw email message from IMAP
 type RawEmail struct {
-	UID       uint32
-	MessageID string
-	From      *Address
-	Subject   string
-	Date      time.Time
-	BodyHTML  string
-	BodyText  string
+	UID        uint32
+	MessageID  string
+	From       *Address
+	ReplyTo    string // Reply-To address, if different from From
+	References string // References header (empty if the body was skipped)
+	InReplyTo  string // In-Reply-To header: Message-ID of the answered email
+	Subject    string
+	Date       time.Time
+	BodyHTML   string
+	BodyText   string
+
+	InternalDate time.Time // Server receive time (INTERNALDATE)
+	Size         uint32    // RFC822.SIZE in bytes
+	BodySkipped  bool      // Body not fetched because Size exceeds MaxMessageSize
+	Encrypted    string    // EncryptedSMIME or EncryptedPGP if the body is encrypted
+
+	Attachments []models.Attachment
+
+	Raw []byte // Complete RFC822 message (nil if the body was skipped)
+}
+
+// ThreadReferences returns the References header with In-Reply-To appended
+// if it is missing, so the thread of an email is known even when its sender
+// set only In-Reply-To or the body was skipped
+func (e *RawEmail) ThreadReferences() string {
+	if e.InReplyTo == "" || strings.Contains(e.References, e.InReplyTo) {
+		return e.References
+	}
+	return strings.TrimSpace(e.References + " " + e.InReplyTo)
 }
 
 // Address represents an email address
@@ -37,9 +63,20 @@ type Address struct {
 type ClientConfig struct {
 	Email       string
 	Password    string
-	Server      string // host:port
+	Server      string // host:port[/security], see ParseServer
 	IdleTimeout time.Duration
 	DialTimeout time.Duration
+
+	MaxMessageSize uint32 // Bodies of larger messages are not downloaded (0 = no limit)
+
+	IdleMode     string        // IdleModeAuto or IdleModePoll
+	PollInterval time.Duration // wait between fetches when not using IDLE
+
+	Backoff Backoff // reconnects after network errors (zero = built-in defaults, no circuit)
+
+	// TokenSource returns an OAuth access token to log in with XOAUTH2
+	// instead of the password; "" falls back to the password
+	TokenSource func(ctx context.Context) (string, error)
 }
 
 // Client IMAP client for a single email account
@@ -51,6 +88,19 @@ type Client struct {
 	connected bool
 	stopCh    chan struct{}
 	stopped   bool
+
+	newMail chan struct{} // signalled on mailbox updates of the connection
+	seen    uint32        // mailbox size when the last fetch started
+	idling  *idleSession  // running wait for new mail, if any
+
+	stateChanged atomic.Bool // flags changed or messages expunged since the last state sync
+
+	modSeq  atomic.Uint64 // INBOX HIGHESTMODSEQ at the last SELECT, 0 without CONDSTORE
+	qresync atomic.Bool   // the server supports QRESYNC, expunges raise HIGHESTMODSEQ too
+
+	authFailed atomic.Bool                   // reconnects paused after repeated auth failures
+	usingIdle  atomic.Bool                   // waiting with IDLE rather than polling
+	retrying   atomic.Pointer[BackoffStatus] // reconnect attempts while the session is down, nil when connected
 }
 
 // NewClient creates a new IMAP client
@@ -73,30 +123,28 @@ func (c *Client) Connect(ctx context.Context) error {
 
 	c.logger.Info("connecting to IMAP server", "server", c.config.Server)
 
-	// Connect with TLS and timeout
+	// Connect with the security of the server address and timeout
 	timeout := c.config.DialTimeout
 	if timeout == 0 {
 		timeout = 30 * time.Second
 	}
 
-	dialer := &net.Dialer{Timeout: timeout}
-	conn, err := tls.DialWithDialer(dialer, "tcp", c.config.Server, nil)
-	if err != nil {
-		return fmt.Errorf("failed to connect: %w", err)
-	}
-
-	imapClient, err := client.New(conn)
+	imapClient, err := dialIMAP(ctx, c.config.Server, timeout)
 	if err != nil {
-		conn.Close()
-		return fmt.Errorf("failed to create IMAP client: %w", err)
+		return err
 	}
 
 	// Login
-	if err := imapClient.Login(c.config.Email, c.config.Password); err != nil {
+	if err := login(ctx, imapClient, c.config); err != nil {
 		imapClient.Logout()
-		return fmt.Errorf("failed to login: %w", err)
+		return err
 	}
 
+	updates := make(chan client.Update, 16)
+	imapClient.Updates = updates
+	c.newMail = make(chan struct{}, 1)
+	go watchMailbox(imapClient.LoggedOut(), updates, c.newMail, &c.stateChanged)
+
 	c.client = imapClient
 	c.connected = true
 	c.logger.Info("connected to IMAP server")
@@ -104,18 +152,66 @@ func (c *Client) Connect(ctx context.Context) error {
 	return nil
 }
 
+// login authenticates with XOAUTH2 if the account has an access token and
+// with LOGIN otherwise
+func login(ctx context.Context, imapClient *client.Client, cfg ClientConfig) error {
+	var token string
+	if cfg.TokenSource != nil {
+		var err error
+		if token, err = cfg.TokenSource(ctx); err != nil {
+			return fmt.Errorf("failed to get access token: %w", err)
+		}
+	}
+
+	if token == "" {
+		if err := imapClient.Login(cfg.Email, cfg.Password); err != nil {
+			return fmt.Errorf("failed to login: %w", classifyLoginError(err))
+		}
+		return nil
+	}
+
+	if err := imapClient.Authenticate(&xoauth2{username: cfg.Email, token: token}); err != nil {
+		return fmt.Errorf("failed to authenticate: %w", classifyLoginError(err))
+	}
+	return nil
+}
+
+// xoauth2 is the SASL XOAUTH2 mechanism used by Gmail and Outlook
+type xoauth2 struct {
+	username string
+	token    string
+}
+
+func (a *xoauth2) Start() (string, []byte, error) {
+	return "XOAUTH2", []byte("user=" + a.username + "\x01auth=Bearer " + a.token + "\x01\x01"), nil
+}
+
+// Next receives the server's error details after a rejected token
+func (a *xoauth2) Next(challenge []byte) ([]byte, error) {
+	return nil, fmt.Errorf("XOAUTH2 rejected: %s", challenge)
+}
+
 // SelectINBOX selects the INBOX mailbox
 func (c *Client) SelectINBOX(ctx context.Context) (*imap.MailboxStatus, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	if !c.connected || c.client == nil {
-		return nil, fmt.Errorf("not connected")
+		return nil, errNotConnected
 	}
 
+	// Selected again with CONDSTORE if the server supports it, see
+	// selectCondstore
+	if mbox := c.client.Mailbox(); mbox != nil && mbox.Name == "INBOX" {
+		if condstore, _ := c.client.Support("CONDSTORE"); condstore {
+			return c.selectCondstore()
+		}
+	}
+
+	c.modSeq.Store(0)
 	mbox, err := c.client.Select("INBOX", false)
 	if err != nil {
-		return nil, fmt.Errorf("failed to select INBOX: %w", err)
+		return nil, fmt.Errorf("failed to select INBOX: %w", classifyError(err))
 	}
 
 	return mbox, nil
@@ -127,18 +223,112 @@ func (c *Client) FetchNewMessages(ctx context.Context, sinceUID uint32) ([]*RawE
 	defer c.mu.Unlock()
 
 	if !c.connected || c.client == nil {
-		return nil, fmt.Errorf("not connected")
+		return nil, errNotConnected
+	}
+
+	if mbox := c.client.Mailbox(); mbox != nil {
+		c.seen = mbox.Messages
 	}
 
 	// Create UID sequence set for UIDs > sinceUID
 	seqSet := new(imap.SeqSet)
 	seqSet.AddRange(sinceUID+1, 0) // 0 means * (all)
 
-	// Fetch items
-	items := []imap.FetchItem{imap.FetchEnvelope, imap.FetchUid, imap.FetchBody}
+	return c.fetchMessages(seqSet)
+}
+
+// FetchRecent fetches the last count messages of the mailbox received on or
+// after the day of since (zero = any time), regardless of what was fetched
+// before
+func (c *Client) FetchRecent(ctx context.Context, count int, since time.Time) ([]*RawEmail, error) {
+	c.lockCommand()
+	defer c.mu.Unlock()
+
+	if !c.connected || c.client == nil {
+		return nil, errNotConnected
+	}
+
+	criteria := imap.NewSearchCriteria()
+	criteria.Since = since
+	uids, err := c.client.UidSearch(criteria)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search: %w", classifyError(err))
+	}
+	slices.Sort(uids)
+	if count > 0 && len(uids) > count {
+		uids = uids[len(uids)-count:]
+	}
+	if len(uids) == 0 {
+		return nil, nil
+	}
+
+	seqSet := new(imap.SeqSet)
+	seqSet.AddNum(uids...)
+	return c.fetchMessages(seqSet)
+}
+
+// fetchMessages fetches the messages of a UID set; bodies above
+// MaxMessageSize are skipped. Returns errIncompleteFetch with the messages
+// before one whose body was not returned. The caller holds c.mu.
+func (c *Client) fetchMessages(seqSet *imap.SeqSet) ([]*RawEmail, error) {
+	items := []imap.FetchItem{imap.FetchEnvelope, imap.FetchUid, imap.FetchInternalDate, imap.FetchRFC822Size}
 	section := &imap.BodySectionName{}
-	items = append(items, section.FetchItem())
 
+	if c.config.MaxMessageSize == 0 {
+		items = append(items, imap.FetchBody, section.FetchItem())
+		return c.fetch(seqSet, items, section)
+	}
+
+	// Fetch metadata first so that oversized bodies are never downloaded
+	emails, err := c.fetch(seqSet, items, section)
+	if err != nil {
+		return emails, err
+	}
+
+	bodySet := new(imap.SeqSet)
+	byUID := make(map[uint32]*RawEmail, len(emails))
+	for _, email := range emails {
+		if email.Size > c.config.MaxMessageSize {
+			email.BodySkipped = true
+			c.logger.Info("skipping body of oversized message", "uid", email.UID, "size", email.Size)
+			continue
+		}
+		bodySet.AddNum(email.UID)
+		byUID[email.UID] = email
+	}
+	if bodySet.Empty() {
+		return emails, nil
+	}
+
+	items = append(items, imap.FetchBody, section.FetchItem())
+	full, err := c.fetch(bodySet, items, section)
+	if err != nil {
+		return emails, err
+	}
+	for _, email := range full {
+		if meta, ok := byUID[email.UID]; ok {
+			*meta = *email
+			delete(byUID, email.UID)
+		}
+	}
+	if len(byUID) == 0 {
+		return emails, nil
+	}
+
+	// A message missing from the body fetch (e.g. expunged in between) must
+	// not be delivered empty: stop before it, the next cycle fetches it again
+	slices.SortFunc(emails, func(a, b *RawEmail) int { return cmp.Compare(a.UID, b.UID) })
+	for i, email := range emails {
+		if _, missing := byUID[email.UID]; missing {
+			c.logger.Warn("message body missing from fetch", "uid", email.UID)
+			return emails[:i], errIncompleteFetch
+		}
+	}
+	return emails, nil
+}
+
+// fetch runs a UID FETCH and parses the returned messages
+func (c *Client) fetch(seqSet *imap.SeqSet, items []imap.FetchItem, section *imap.BodySectionName) ([]*RawEmail, error) {
 	messages := make(chan *imap.Message, 100)
 	done := make(chan error, 1)
 
@@ -166,7 +356,9 @@ func (c *Client) FetchNewMessages(ctx context.Context, sinceUID uint32) ([]*RawE
 // parseMessage parses an IMAP message into RawEmail
 func (c *Client) parseMessage(msg *imap.Message, section *imap.BodySectionName) (*RawEmail, error) {
 	email := &RawEmail{
-		UID: msg.Uid,
+		UID:          msg.Uid,
+		InternalDate: msg.InternalDate,
+		Size:         msg.Size,
 	}
 
 	// Parse envelope
@@ -174,6 +366,7 @@ func (c *Client) parseMessage(msg *imap.Message, section *imap.BodySectionName)
 		email.Subject = msg.Envelope.Subject
 		email.Date = msg.Envelope.Date
 		email.MessageID = msg.Envelope.MessageId
+		email.InReplyTo = strings.TrimSpace(msg.Envelope.InReplyTo)
 
 		if len(msg.Envelope.From) > 0 {
 			from := msg.Envelope.From[0]
@@ -182,42 +375,21 @@ func (c *Client) parseMessage(msg *imap.Message, section *imap.BodySectionName)
 				Address: from.Address(),
 			}
 		}
+		if len(msg.Envelope.ReplyTo) > 0 {
+			if replyTo := msg.Envelope.ReplyTo[0].Address(); email.From == nil || replyTo != email.From.Address {
+				email.ReplyTo = replyTo
+			}
+		}
 	}
 
 	// Parse body
 	bodyReader := msg.GetBody(section)
 	if bodyReader != nil {
-		mr, err := mail.CreateReader(bodyReader)
+		raw, err := io.ReadAll(bodyReader)
 		if err != nil {
-			c.logger.Warn("failed to create mail reader", "error", err)
-		} else {
-			// Read parts
-			for {
-				part, err := mr.NextPart()
-				if err == io.EOF {
-					break
-				}
-				if err != nil {
-					c.logger.Warn("failed to read part", "error", err)
-					break
-				}
-
-				switch h := part.Header.(type) {
-				case *mail.InlineHeader:
-					ct, _, _ := h.ContentType()
-					body, err := io.ReadAll(part.Body)
-					if err != nil {
-						continue
-					}
-
-					if strings.HasPrefix(ct, "text/html") {
-						email.BodyHTML = string(body)
-					} else if strings.HasPrefix(ct, "text/plain") {
-						email.BodyText = string(body)
-					}
-				}
-			}
+			return nil, fmt.Errorf("failed to read body: %w", err)
 		}
+		parseBody(email, raw, c.logger)
 	}
 
 	// If no from address parsed, set empty
@@ -228,13 +400,95 @@ func (c *Client) parseMessage(msg *imap.Message, section *imap.BodySectionName)
 	return email, nil
 }
 
+// ParseRaw parses the body and attachments of a complete RFC822 message,
+// e.g. one loaded from the raw archive. Envelope fields are left empty.
+func ParseRaw(raw []byte, logger *slog.Logger) *RawEmail {
+	email := &RawEmail{Size: uint32(len(raw))}
+	parseBody(email, raw, logger)
+	return email
+}
+
+// ParseMessage parses a complete RFC822 message like ParseRaw and also fills
+// the envelope fields from its headers, e.g. for an uploaded .eml file
+func ParseMessage(raw []byte, logger *slog.Logger) *RawEmail {
+	email := ParseRaw(raw, logger)
+	email.From = &Address{}
+
+	mr, err := mail.CreateReader(bytes.NewReader(raw))
+	if err != nil {
+		return email
+	}
+	defer mr.Close()
+
+	email.Subject, _ = mr.Header.Subject()
+	email.Date, _ = mr.Header.Date()
+	email.MessageID = strings.TrimSpace(mr.Header.Get("Message-Id"))
+	email.InReplyTo = strings.TrimSpace(mr.Header.Get("In-Reply-To"))
+	if from, err := mr.Header.AddressList("From"); err == nil && len(from) > 0 {
+		email.From = &Address{Name: from[0].Name, Address: from[0].Address}
+	}
+	if replyTo, err := mr.Header.AddressList("Reply-To"); err == nil && len(replyTo) > 0 && replyTo[0].Address != email.From.Address {
+		email.ReplyTo = replyTo[0].Address
+	}
+	return email
+}
+
+// parseBody fills the text, HTML and attachments of an email from its raw message
+func parseBody(email *RawEmail, raw []byte, logger *slog.Logger) {
+	email.Raw = raw
+
+	mr, err := mail.CreateReader(bytes.NewReader(raw))
+	if err != nil {
+		logger.Warn("failed to create mail reader", "error", err)
+		return
+	}
+	email.References = strings.Join(strings.Fields(mr.Header.Get("References")), " ")
+	email.Encrypted = encryption(mr.Header)
+
+	// Read parts
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			logger.Warn("failed to read part", "error", err)
+			break
+		}
+
+		if attachment, ok := attachmentMeta(part); ok {
+			email.Attachments = append(email.Attachments, attachment)
+			continue
+		}
+
+		switch h := part.Header.(type) {
+		case *mail.InlineHeader:
+			ct, _, _ := h.ContentType()
+			body, err := io.ReadAll(part.Body)
+			if err != nil {
+				continue
+			}
+
+			if strings.HasPrefix(ct, "text/html") {
+				email.BodyHTML = string(body)
+			} else if strings.HasPrefix(ct, "text/plain") {
+				email.BodyText = string(body)
+			}
+		}
+	}
+
+	if email.Encrypted == "" && strings.Contains(email.BodyText, pgpMessageMarker) {
+		email.Encrypted = EncryptedPGP
+	}
+}
+
 // MarkAsRead marks a message as read (adds \Seen flag)
 func (c *Client) MarkAsRead(ctx context.Context, uid uint32) error {
-	c.mu.Lock()
+	c.lockCommand()
 	defer c.mu.Unlock()
 
 	if !c.connected || c.client == nil {
-		return fmt.Errorf("not connected")
+		return errNotConnected
 	}
 
 	seqSet := new(imap.SeqSet)
@@ -244,7 +498,7 @@ func (c *Client) MarkAsRead(ctx context.Context, uid uint32) error {
 	flags := []interface{}{imap.SeenFlag}
 
 	if err := c.client.UidStore(seqSet, item, flags, nil); err != nil {
-		return fmt.Errorf("failed to mark as read: %w", err)
+		return fmt.Errorf("failed to mark as read: %w", classifyError(err))
 	}
 
 	return nil
@@ -252,11 +506,11 @@ func (c *Client) MarkAsRead(ctx context.Context, uid uint32) error {
 
 // DeleteMessage deletes a message (adds \Deleted flag and expunges)
 func (c *Client) DeleteMessage(ctx context.Context, uid uint32) error {
-	c.mu.Lock()
+	c.lockCommand()
 	defer c.mu.Unlock()
 
 	if !c.connected || c.client == nil {
-		return fmt.Errorf("not connected")
+		return errNotConnected
 	}
 
 	seqSet := new(imap.SeqSet)
@@ -266,31 +520,50 @@ func (c *Client) DeleteMessage(ctx context.Context, uid uint32) error {
 	flags := []interface{}{imap.DeletedFlag}
 
 	if err := c.client.UidStore(seqSet, item, flags, nil); err != nil {
-		return fmt.Errorf("failed to mark as deleted: %w", err)
+		return fmt.Errorf("failed to mark as deleted: %w", classifyError(err))
 	}
 
 	// Expunge deleted messages
 	if err := c.client.Expunge(nil); err != nil {
-		return fmt.Errorf("failed to expunge: %w", err)
+		return fmt.Errorf("failed to expunge: %w", classifyError(err))
 	}
 
 	return nil
 }
 
+// SearchSince returns the UIDs of messages received on or after the day of
+// since (IMAP SEARCH has day granularity)
+func (c *Client) SearchSince(ctx context.Context, since time.Time) ([]uint32, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.connected || c.client == nil {
+		return nil, errNotConnected
+	}
+
+	criteria := imap.NewSearchCriteria()
+	criteria.Since = since
+	uids, err := c.client.UidSearch(criteria)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search: %w", classifyError(err))
+	}
+	return uids, nil
+}
+
 // GetHighestUID returns the highest UID in the mailbox
 func (c *Client) GetHighestUID(ctx context.Context) (uint32, error) {
-	c.mu.Lock()
+	c.lockCommand()
 	defer c.mu.Unlock()
 
 	if !c.connected || c.client == nil {
-		return 0, fmt.Errorf("not connected")
+		return 0, errNotConnected
 	}
 
 	// Search for all messages
 	criteria := imap.NewSearchCriteria()
 	uids, err := c.client.UidSearch(criteria)
 	if err != nil {
-		return 0, fmt.Errorf("failed to search: %w", err)
+		return 0, fmt.Errorf("failed to search: %w", classifyError(err))
 	}
 
 	if len(uids) == 0 {
@@ -308,10 +581,18 @@ func (c *Client) GetHighestUID(ctx context.Context) (uint32, error) {
 	return highest, nil
 }
 
-// StartIDLE starts IDLE mode for real-time notifications
-func (c *Client) StartIDLE(ctx context.Context, onNewMail func()) error {
+// StartIDLE starts IDLE mode for real-time notifications. Reconnects back
+// off with jitter according to the error category; onConnError is called
+// once per episode of refused reconnects (not for every retry and not for
+// plain network errors). Repeated authentication failures open a circuit
+// that only retries once per authCircuitCooldown; Backoff.CircuitFailures
+// failures of any other kind open one that retries once per
+// Backoff.CircuitCooldown and is reported as a *CircuitError.
+func (c *Client) StartIDLE(ctx context.Context, onNewMail func(), onConnError func(error)) error {
 	c.logger.Info("starting IDLE mode")
 
+	retry := reconnectState{backoff: c.config.Backoff}
+
 	for {
 		c.logger.Debug("IDLE loop iteration")
 		select {
@@ -325,21 +606,23 @@ func (c *Client) StartIDLE(ctx context.Context, onNewMail func()) error {
 		}
 
 		// Check if we need to reconnect
-		c.mu.Lock()
-		if !c.connected || c.client == nil {
-			c.mu.Unlock()
-			if err := c.Connect(ctx); err != nil {
-				c.logger.Error("failed to reconnect", "error", err)
-				time.Sleep(10 * time.Second)
-				continue
+		if !c.IsConnected() {
+			err := c.Connect(ctx)
+			if err == nil {
+				if _, err = c.SelectINBOX(ctx); err != nil {
+					c.handleDisconnect()
+				}
 			}
-			if _, err := c.SelectINBOX(ctx); err != nil {
-				c.logger.Error("failed to select INBOX after reconnect", "error", err)
-				time.Sleep(10 * time.Second)
+			if err != nil {
+				c.retryAfter(ctx, &retry, err, onConnError)
 				continue
 			}
+			if retry.total > 0 {
+				c.logger.Info("reconnected", "after_failures", retry.total)
+			}
+			c.authFailed.Store(false)
+			c.retrying.Store(nil)
 		}
-		c.mu.Unlock()
 
 		// Start IDLE with timeout
 		c.mu.Lock()
@@ -348,39 +631,49 @@ func (c *Client) StartIDLE(ctx context.Context, onNewMail func()) error {
 			continue
 		}
 
-		idleClient := NewIdleClient(c.client, c.logger)
+		idleClient := NewIdleClient(c.client, c.newMail, c.seen, c.logger)
+		session := newIdleSession()
+		c.idling = session
 		c.mu.Unlock()
 
-		// Create stop channel for this IDLE session
-		stopIdle := make(chan struct{})
-
 		// Run IDLE in goroutine
 		idleDone := make(chan error, 1)
 		go func() {
-			idleDone <- idleClient.IdleWithFallback(stopIdle, c.config.IdleTimeout)
+			err := idleClient.IdleWithFallback(session.stop, c.config.IdleMode,
+				c.config.IdleTimeout, c.config.PollInterval, c.usingIdle.Store)
+			c.mu.Lock()
+			if c.idling == session {
+				c.idling = nil
+			}
+			c.mu.Unlock()
+			close(session.done)
+			idleDone <- err
 		}()
 
 		// Wait for IDLE to complete, stop signal, or context done
-		c.logger.Info("waiting for IDLE response...")
+		c.logger.Debug("waiting for new mail", "idle", c.usingIdle.Load())
 		select {
 		case <-ctx.Done():
 			c.logger.Info("IDLE: context cancelled")
-			close(stopIdle)
+			session.stopOnce.Do(func() { close(session.stop) })
 			// Don't wait for idleDone - just return
 			return ctx.Err()
 		case <-c.stopCh:
 			c.logger.Info("IDLE: stop channel closed")
-			close(stopIdle)
+			session.stopOnce.Do(func() { close(session.stop) })
 			// Don't wait for idleDone - just return to avoid blocking
 			return nil
 		case err := <-idleDone:
 			c.logger.Info("IDLE returned", "error", err)
 			if err != nil {
+				// A session that keeps dropping counts as failing to
+				// reconnect, so it backs off too
 				c.logger.Warn("IDLE error", "error", err)
 				c.handleDisconnect()
-				time.Sleep(5 * time.Second)
+				c.retryAfter(ctx, &retry, classifyError(err), onConnError)
 				continue
 			}
+			retry = reconnectState{backoff: c.config.Backoff}
 		}
 
 		// Notify about potential new mail
@@ -389,6 +682,48 @@ func (c *Client) StartIDLE(ctx context.Context, onNewMail func()) error {
 	}
 }
 
+// retryAfter records a failed reconnect or a dropped session, reports it if
+// due and waits before the next attempt
+func (c *Client) retryAfter(ctx context.Context, retry *reconnectState, err error, onConnError func(error)) {
+	delay, notify := retry.failed(err)
+	c.authFailed.Store(retry.authCircuitOpen())
+	c.retrying.Store(retry.status(delay))
+	if notify {
+		if retry.circuitOpen() {
+			onConnError(&CircuitError{Failures: retry.total, Retry: delay, Err: err})
+		} else {
+			onConnError(err)
+		}
+	}
+	c.logger.Warn("failed to reconnect", "error", err, "category", Category(err),
+		"failures", retry.total, "delay", delay, "circuit_open", retry.circuitOpen())
+	c.wait(ctx, delay)
+}
+
+// lockCommand takes the client lock for a command issued outside the IDLE
+// loop, ending a running IDLE first. The loop fetches once it returns.
+func (c *Client) lockCommand() {
+	c.mu.Lock()
+	for c.idling != nil {
+		session := c.idling
+		c.mu.Unlock()
+		session.interrupt()
+		c.mu.Lock()
+	}
+}
+
+// wait sleeps for d unless the client is stopped or ctx is done first
+func (c *Client) wait(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+	case <-c.stopCh:
+	case <-timer.C:
+	}
+}
+
 // handleDisconnect handles a disconnect event
 func (c *Client) handleDisconnect() {
 	c.mu.Lock()
@@ -435,6 +770,36 @@ func (c *Client) Stop() {
 	}
 }
 
+// AuthFailed returns whether reconnects are paused because the server keeps
+// rejecting the credentials
+func (c *Client) AuthFailed() bool {
+	return c.authFailed.Load()
+}
+
+// Backoff returns the state of reconnect attempts, or nil if the client is
+// not retrying
+func (c *Client) Backoff() *BackoffStatus {
+	return c.retrying.Load()
+}
+
+// cycleInterval returns the longest expected wait for new mail: the IDLE
+// timeout with IDLE, the poll interval otherwise
+func (c *Client) cycleInterval() time.Duration {
+	if c.usingIdle.Load() {
+		return c.config.IdleTimeout
+	}
+	if c.config.PollInterval <= 0 {
+		return defaultPollInterval
+	}
+	return c.config.PollInterval
+}
+
+// UsingIdle returns whether the client waits for new mail with IDLE rather
+// than polling
+func (c *Client) UsingIdle() bool {
+	return c.usingIdle.Load()
+}
+
 // IsConnected returns whether the client is connected
 func (c *Client) IsConnected() bool {
 	c.mu.Lock()
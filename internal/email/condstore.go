@@ -0,0 +1,168 @@
+package email
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/commands"
+	"github.com/emersion/go-imap/responses"
+)
+
+// CONDSTORE (RFC 7162) gives every change in a mailbox a growing
+// mod-sequence. Comparing the HIGHESTMODSEQ of INBOX with the one of the
+// last cycle tells whether new mail arrived or flags changed without
+// fetching anything, and CHANGEDSINCE fetches only the messages modified
+// since then. go-imap v1 does not know the extension, so the commands are
+// built here.
+
+// condstoreSelect is SELECT with the CONDSTORE parameter, which makes the
+// server report HIGHESTMODSEQ
+type condstoreSelect struct {
+	mailbox string
+}
+
+func (cmd *condstoreSelect) Command() *imap.Command {
+	return &imap.Command{
+		Name:      "SELECT",
+		Arguments: []interface{}{imap.FormatMailboxName(cmd.mailbox), []interface{}{imap.RawString("CONDSTORE")}},
+	}
+}
+
+// condstoreSelectResp reads a SELECT response and its HIGHESTMODSEQ. The
+// mod-sequence stays 0 if the server answers NOMODSEQ.
+type condstoreSelectResp struct {
+	responses.Select
+	modSeq uint64
+}
+
+func (r *condstoreSelectResp) Handle(resp imap.Resp) error {
+	if status, ok := resp.(*imap.StatusResp); ok {
+		switch status.Code {
+		case "HIGHESTMODSEQ":
+			if len(status.Arguments) > 0 {
+				r.modSeq, _ = parseModSeq(status.Arguments[0])
+			}
+			return nil
+		case "NOMODSEQ":
+			return nil
+		}
+	}
+	return r.Select.Handle(resp)
+}
+
+// changedSinceFetch is FETCH with the CHANGEDSINCE modifier: only messages
+// modified after the mod-sequence are returned
+type changedSinceFetch struct {
+	commands.Fetch
+	since uint64
+}
+
+func (cmd *changedSinceFetch) Command() *imap.Command {
+	command := cmd.Fetch.Command()
+	command.Arguments = append(command.Arguments, []interface{}{
+		imap.RawString("CHANGEDSINCE"),
+		imap.RawString(strconv.FormatUint(cmd.since, 10)),
+	})
+	return command
+}
+
+// parseModSeq parses a mod-sequence, a number of up to 63 bits
+func parseModSeq(field interface{}) (uint64, error) {
+	switch v := field.(type) {
+	case uint32:
+		return uint64(v), nil
+	case string:
+		return strconv.ParseUint(v, 10, 64)
+	default:
+		return 0, fmt.Errorf("invalid mod-sequence %v", field)
+	}
+}
+
+// selectCondstore selects INBOX again with CONDSTORE and remembers its
+// HIGHESTMODSEQ. go-imap keeps the mailbox state of the earlier plain
+// SELECT, so INBOX must already be selected. The caller holds c.mu.
+func (c *Client) selectCondstore() (*imap.MailboxStatus, error) {
+	res := &condstoreSelectResp{Select: responses.Select{
+		Mailbox: &imap.MailboxStatus{Name: "INBOX", Items: make(map[imap.StatusItem]interface{})},
+	}}
+	status, err := c.client.Execute(&condstoreSelect{mailbox: "INBOX"}, res)
+	if err == nil {
+		err = status.Err()
+	}
+	if err != nil {
+		c.modSeq.Store(0)
+		return nil, fmt.Errorf("failed to select INBOX: %w", classifyError(err))
+	}
+
+	c.modSeq.Store(res.modSeq)
+	qresync, _ := c.client.Support("QRESYNC")
+	c.qresync.Store(qresync)
+
+	mbox := res.Mailbox
+	if current := c.client.Mailbox(); current != nil {
+		mbox.Messages = current.Messages
+	}
+	return mbox, nil
+}
+
+// fetchChangedStates is fetchStates for servers with CONDSTORE: flags and
+// Message-ID are fetched only for messages modified after the mod-sequence,
+// the others are returned as unchanged. UIDs no longer in INBOX are missing
+// from the result. The caller runs in the fetch cycle, while the connection
+// is not idling.
+func (c *Client) fetchChangedStates(uids []uint32, since uint64) (map[uint32]messageState, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.connected || c.client == nil {
+		return nil, errNotConnected
+	}
+
+	seqSet := new(imap.SeqSet)
+	seqSet.AddNum(uids...)
+
+	// Which of the messages are still there: a search of UIDs only
+	criteria := imap.NewSearchCriteria()
+	criteria.Uid = seqSet
+	present, err := c.client.UidSearch(criteria)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search: %w", classifyError(err))
+	}
+	states := make(map[uint32]messageState, len(present))
+	for _, uid := range present {
+		states[uid] = messageState{unchanged: true}
+	}
+
+	messages := make(chan *imap.Message, 100)
+	done := make(chan error, 1)
+	go func() {
+		defer close(messages)
+		cmd := &changedSinceFetch{
+			Fetch: commands.Fetch{SeqSet: seqSet, Items: []imap.FetchItem{imap.FetchUid, imap.FetchFlags, imap.FetchEnvelope}},
+			since: since,
+		}
+		status, err := c.client.Execute(&commands.Uid{Cmd: cmd}, &responses.Fetch{Messages: messages, SeqSet: seqSet, Uid: true})
+		if err == nil {
+			err = status.Err()
+		}
+		done <- err
+	}()
+
+	for msg := range messages {
+		state := messageState{
+			seen:    slices.Contains(msg.Flags, imap.SeenFlag),
+			deleted: slices.Contains(msg.Flags, imap.DeletedFlag),
+		}
+		if msg.Envelope != nil {
+			state.messageID = msg.Envelope.MessageId
+		}
+		states[msg.Uid] = state
+	}
+
+	if err := <-done; err != nil {
+		return nil, fmt.Errorf("failed to fetch flags: %w", classifyError(err))
+	}
+	return states, nil
+}
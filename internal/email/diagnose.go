@@ -0,0 +1,144 @@
+package email
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-imap/client"
+
+	"github.com/mixelka/emailresend/pkg/models"
+)
+
+// DiagnosedCapabilities are the extensions reported by Diagnose, in display order
+var DiagnosedCapabilities = []string{"IDLE", "MOVE", "CONDSTORE", "QRESYNC", "QUOTA", "UIDPLUS"}
+
+// Diagnosis describes what an IMAP server supports and how fast it responds.
+// Fields are filled in as far as the diagnosis got before an error.
+type Diagnosis struct {
+	Server         string
+	Security       string          // SecurityTLS, SecuritySTARTTLS or SecurityPlain
+	Capabilities   map[string]bool // after login (servers often advertise more once authenticated)
+	AuthMechanisms []string        // SASL mechanisms advertised before login
+	LoginDisabled  bool            // LOGINDISABLED: plain LOGIN is not accepted
+
+	TCPConnect   time.Duration
+	TLSHandshake time.Duration
+	Greeting     time.Duration
+	Login        time.Duration
+	Select       time.Duration
+	Noop         time.Duration // round trip of a no-op command
+
+	Messages uint32 // messages in INBOX
+}
+
+// Supports reports whether the server advertised a capability
+func (d *Diagnosis) Supports(capability string) bool {
+	return d.Capabilities[capability]
+}
+
+// Diagnose opens a separate connection to the account's server, measuring
+// each step and collecting advertised capabilities. The running connection of
+// the account is not touched.
+func Diagnose(ctx context.Context, cfg ClientConfig) (*Diagnosis, error) {
+	d := &Diagnosis{Server: cfg.Server}
+
+	timeout := cfg.DialTimeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+
+	spec, err := ParseServer(cfg.Server)
+	if err != nil {
+		return d, err
+	}
+	d.Security = spec.Security
+
+	started := time.Now()
+	dialer := &net.Dialer{Timeout: timeout}
+	rawConn, err := dialer.DialContext(ctx, "tcp", spec.Addr)
+	if err != nil {
+		return d, fmt.Errorf("failed to connect: %w: %w", ErrNetwork, err)
+	}
+	d.TCPConnect = time.Since(started)
+
+	// Bound the whole diagnosis, including slow servers that stall mid-command
+	rawConn.SetDeadline(time.Now().Add(2 * timeout))
+
+	conn := rawConn
+	if spec.Security == SecurityTLS {
+		started = time.Now()
+		tlsConn := tls.Client(rawConn, spec.tlsConfig())
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			rawConn.Close()
+			return d, fmt.Errorf("TLS handshake failed: %w: %w", ErrNetwork, err)
+		}
+		d.TLSHandshake = time.Since(started)
+		conn = tlsConn
+	}
+
+	started = time.Now()
+	c, err := client.New(conn)
+	if err != nil {
+		conn.Close()
+		return d, fmt.Errorf("failed to read greeting: %w", classifyError(err))
+	}
+	defer c.Logout()
+	d.Greeting = time.Since(started)
+
+	if spec.Security == SecuritySTARTTLS {
+		started = time.Now()
+		if err := startTLS(c, spec); err != nil {
+			return d, err
+		}
+		d.TLSHandshake = time.Since(started)
+	}
+
+	caps, err := c.Capability()
+	if err != nil {
+		return d, fmt.Errorf("failed to get capabilities: %w", classifyError(err))
+	}
+	d.Capabilities = caps
+	for capability := range caps {
+		if mech, ok := strings.CutPrefix(capability, "AUTH="); ok {
+			d.AuthMechanisms = append(d.AuthMechanisms, mech)
+		}
+	}
+	sort.Strings(d.AuthMechanisms)
+	d.LoginDisabled = caps["LOGINDISABLED"]
+
+	started = time.Now()
+	if err := login(ctx, c, cfg); err != nil {
+		return d, err
+	}
+	d.Login = time.Since(started)
+
+	if caps, err := c.Capability(); err == nil {
+		d.Capabilities = caps
+	}
+
+	started = time.Now()
+	mbox, err := c.Select("INBOX", true)
+	if err != nil {
+		return d, fmt.Errorf("failed to select INBOX: %w", classifyError(err))
+	}
+	d.Select = time.Since(started)
+	d.Messages = mbox.Messages
+
+	started = time.Now()
+	if err := c.Noop(); err != nil {
+		return d, fmt.Errorf("failed to send NOOP: %w", classifyError(err))
+	}
+	d.Noop = time.Since(started)
+
+	return d, nil
+}
+
+// Diagnose runs Diagnose for a stored account
+func (m *Manager) Diagnose(ctx context.Context, account *models.EmailAccount) (*Diagnosis, error) {
+	return Diagnose(ctx, m.clientConfig(account))
+}
@@ -0,0 +1,60 @@
+package email
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/emersion/go-message/mail"
+)
+
+// Encryption of an email, see RawEmail.Encrypted
+const (
+	EncryptedSMIME = "smime" // application/pkcs7-mime enveloped data
+	EncryptedPGP   = "pgp"   // PGP/MIME (multipart/encrypted) or an inline PGP message
+)
+
+// pgpMessageMarker starts an ASCII-armored PGP message
+const pgpMessageMarker = "-----BEGIN PGP MESSAGE-----"
+
+// encryption tells how a message is encrypted from its Content-Type, empty
+// if it is not. Opaque signed S/MIME messages (smime-type=signed-data) use
+// the same type but are not encrypted.
+func encryption(header mail.Header) string {
+	contentType, params, _ := header.ContentType()
+	switch contentType {
+	case "application/pkcs7-mime", "application/x-pkcs7-mime":
+		switch strings.ToLower(params["smime-type"]) {
+		case "enveloped-data", "authenveloped-data":
+			return EncryptedSMIME
+		case "":
+			// Old clients leave smime-type out; the file name tells
+			if strings.HasSuffix(strings.ToLower(params["name"]), ".p7m") {
+				return EncryptedSMIME
+			}
+		}
+	case "multipart/encrypted":
+		return EncryptedPGP
+	}
+	return ""
+}
+
+// EncryptedContent returns the decoded body of an S/MIME encrypted message,
+// the CMS structure to decrypt
+func EncryptedContent(raw []byte) ([]byte, error) {
+	mr, err := mail.CreateReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create mail reader: %w", err)
+	}
+	defer mr.Close()
+
+	if encryption(mr.Header) != EncryptedSMIME {
+		return nil, fmt.Errorf("not an S/MIME encrypted message")
+	}
+	part, err := mr.NextPart()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read encrypted part: %w", err)
+	}
+	return io.ReadAll(part.Body)
+}
@@ -0,0 +1,290 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-imap"
+)
+
+// Error categories. Errors returned by Client, Manager and Diagnose wrap one
+// of them when the cause is known; branch on them with errors.Is or Category.
+var (
+	ErrAuth            = errors.New("authentication failed")
+	ErrNetwork         = errors.New("network error")
+	ErrMailboxNotFound = errors.New("mailbox not found")
+	ErrRateLimited     = errors.New("rate limited by server")
+
+	// ErrConnectionLimit is returned when the server refuses a session
+	// because the account already has too many simultaneous IMAP
+	// connections (e.g. a desktop client is open at the same time). It is
+	// also an ErrRateLimited.
+	ErrConnectionLimit = fmt.Errorf("%w: too many simultaneous IMAP connections", ErrRateLimited)
+
+	// errNotConnected is returned by commands while the session is down
+	errNotConnected = fmt.Errorf("%w: not connected", ErrNetwork)
+
+	// errStopping is returned by message handling after StopAll
+	errStopping = errors.New("email manager is stopping")
+
+	// errIncompleteFetch is returned with the messages fetched before one
+	// whose body the server did not return
+	errIncompleteFetch = errors.New("message body missing from fetch")
+)
+
+// categories in the order they are matched (ErrConnectionLimit before the
+// more general ErrRateLimited)
+var categories = []error{ErrConnectionLimit, ErrRateLimited, ErrAuth, ErrMailboxNotFound, ErrNetwork}
+
+// Category returns the error category err belongs to, or nil if unknown
+func Category(err error) error {
+	for _, category := range categories {
+		if errors.Is(err, category) {
+			return category
+		}
+	}
+	return nil
+}
+
+// isTransient returns whether err is expected to go away by itself
+func isTransient(err error) bool {
+	return errors.Is(err, ErrNetwork) || errors.Is(err, ErrRateLimited)
+}
+
+// errorMarker maps a lowercase fragment of a server response to a category
+type errorMarker struct {
+	text     string
+	category error
+}
+
+// errorMarkers recognise server responses that carry no RFC 5530 code
+var errorMarkers = []errorMarker{
+	{"[limit]", ErrConnectionLimit},                           // RFC 5530 code in the text
+	{"too many simultaneous connections", ErrConnectionLimit}, // Gmail
+	{"too many connections", ErrConnectionLimit},              // Mail.ru, Yandex
+	{"maximum number of connections", ErrConnectionLimit},     // Dovecot mail_max_userip_connections
+	{"too many concurrent", ErrConnectionLimit},
+	{"connection limit", ErrConnectionLimit},
+	{"bandwidth limits", ErrRateLimited}, // Gmail
+	{"too many login", ErrRateLimited},
+	{"try again later", ErrRateLimited},
+	{"invalid credentials", ErrAuth},
+	{"authentication failed", ErrAuth},
+	{"login failed", ErrAuth},
+	{"invalid password", ErrAuth},
+	{"password incorrect", ErrAuth},
+	{"incorrect password", ErrAuth},
+	{"mailbox doesn't exist", ErrMailboxNotFound},
+	{"mailbox does not exist", ErrMailboxNotFound},
+	{"unknown mailbox", ErrMailboxNotFound},
+	{"no such mailbox", ErrMailboxNotFound},
+	{"use of closed network connection", ErrNetwork},
+	{"connection reset", ErrNetwork},
+	{"broken pipe", ErrNetwork},
+	{"connection closed", ErrNetwork},
+}
+
+// responseCodes maps RFC 5530 response codes to categories
+var responseCodes = map[imap.StatusRespCode]error{
+	"LIMIT":                ErrConnectionLimit,
+	"INUSE":                ErrRateLimited,
+	"UNAVAILABLE":          ErrRateLimited,
+	"AUTHENTICATIONFAILED": ErrAuth,
+	"AUTHORIZATIONFAILED":  ErrAuth,
+	"EXPIRED":              ErrAuth,
+	"NONEXISTENT":          ErrMailboxNotFound,
+}
+
+// classifyError wraps err with its category, if it can be recognised
+func classifyError(err error) error {
+	if err == nil || Category(err) != nil {
+		return err
+	}
+	if category := detectCategory(err); category != nil {
+		return fmt.Errorf("%w: %w", category, err)
+	}
+	return err
+}
+
+// classifyLoginError is classifyError for LOGIN: a rejection without a
+// recognisable reason is treated as bad credentials
+func classifyLoginError(err error) error {
+	err = classifyError(err)
+	var statusErr *imap.ErrStatusResp
+	if Category(err) == nil && errors.As(err, &statusErr) {
+		return fmt.Errorf("%w: %w", ErrAuth, err)
+	}
+	return err
+}
+
+// detectCategory inspects response codes, network errors and response texts
+func detectCategory(err error) error {
+	var statusErr *imap.ErrStatusResp
+	if errors.As(err, &statusErr) && statusErr.Resp != nil {
+		if category, ok := responseCodes[statusErr.Resp.Code]; ok {
+			return category
+		}
+	}
+
+	text := strings.ToLower(err.Error())
+	for _, marker := range errorMarkers {
+		if strings.Contains(text, marker.text) {
+			return marker.category
+		}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, context.DeadlineExceeded) {
+		return ErrNetwork
+	}
+	return nil
+}
+
+// reconnectPolicy is the backoff for reconnects failing with an error category
+type reconnectPolicy struct {
+	delay    time.Duration // first pause, doubled on every further failure
+	maxDelay time.Duration
+	notify   bool // report the first failure of an episode
+}
+
+// reconnectPolicies by category; unknown errors use ErrNetwork's, which
+// Backoff overrides
+var reconnectPolicies = map[error]reconnectPolicy{
+	ErrNetwork:         {delay: 10 * time.Second, maxDelay: 5 * time.Minute},
+	ErrConnectionLimit: {delay: 2 * time.Minute, maxDelay: 30 * time.Minute, notify: true},
+	ErrRateLimited:     {delay: time.Minute, maxDelay: 30 * time.Minute, notify: true},
+	ErrMailboxNotFound: {delay: 5 * time.Minute, maxDelay: time.Hour, notify: true},
+	ErrAuth:            {delay: time.Minute, maxDelay: time.Minute},
+}
+
+const (
+	// authFailureThreshold is the number of consecutive auth failures that
+	// opens the circuit; a single one may be a server hiccup
+	authFailureThreshold = 3
+	// authCircuitCooldown is the pause between attempts while the circuit is
+	// open, so a changed password cannot lock the account out by retrying
+	authCircuitCooldown = time.Hour
+	// jitterFraction is the share of a pause that is randomised, so accounts
+	// on the same server do not reconnect in lockstep
+	jitterFraction = 0.2
+)
+
+// Backoff configures reconnects after network errors and the circuit that
+// slows them down when they keep failing
+type Backoff struct {
+	Delay           time.Duration // first pause, doubled on every further failure
+	MaxDelay        time.Duration
+	CircuitFailures int           // consecutive failures of any kind that open the circuit (0 = never)
+	CircuitCooldown time.Duration // pause between attempts while the circuit is open
+}
+
+// CircuitError is reported when reconnects have failed CircuitFailures
+// times in a row and are slowed down to one per CircuitCooldown
+type CircuitError struct {
+	Failures int
+	Retry    time.Duration
+	Err      error // last reconnect error
+}
+
+func (e *CircuitError) Error() string {
+	return fmt.Sprintf("%d reconnects failed, retrying every %s: %v", e.Failures, e.Retry, e.Err)
+}
+
+func (e *CircuitError) Unwrap() error {
+	return e.Err
+}
+
+// BackoffStatus describes the reconnect attempts of a client that cannot
+// connect
+type BackoffStatus struct {
+	Failures    int       // consecutive failed attempts
+	Category    error     // category of the last error, nil if unknown
+	NextAttempt time.Time // when the client tries again
+	CircuitOpen bool      // attempts are slowed down after too many failures
+}
+
+// reconnectState tracks consecutive reconnect failures of a client
+type reconnectState struct {
+	backoff  Backoff
+	category error
+	failures int // consecutive failures of category
+	total    int // consecutive failures of any category
+	delay    time.Duration
+}
+
+// policy returns the backoff for an error category
+func (s *reconnectState) policy(category error) reconnectPolicy {
+	policy := reconnectPolicies[category]
+	if category == ErrNetwork && s.backoff.Delay > 0 {
+		policy.delay = s.backoff.Delay
+		policy.maxDelay = max(s.backoff.MaxDelay, s.backoff.Delay)
+	}
+	return policy
+}
+
+// failed records a failed reconnect and returns the pause before the next
+// attempt and whether the error should be reported
+func (s *reconnectState) failed(err error) (time.Duration, bool) {
+	category := Category(err)
+	if category == nil {
+		category = ErrNetwork
+	}
+	policy := s.policy(category)
+
+	if category != s.category {
+		s.category, s.failures, s.delay = category, 0, policy.delay
+	} else {
+		s.delay = min(2*s.delay, policy.maxDelay)
+	}
+	s.failures++
+	s.total++
+
+	if category == ErrAuth {
+		if s.failures >= authFailureThreshold {
+			return authCircuitCooldown, s.failures == authFailureThreshold
+		}
+		return s.delay, false
+	}
+	if s.circuitOpen() {
+		return jitter(max(s.backoff.CircuitCooldown, s.delay)), s.total == s.backoff.CircuitFailures
+	}
+	return jitter(s.delay), policy.notify && s.failures == 1
+}
+
+// authCircuitOpen returns whether reconnects are paused after repeated auth
+// failures
+func (s *reconnectState) authCircuitOpen() bool {
+	return s.category == ErrAuth && s.failures >= authFailureThreshold
+}
+
+// circuitOpen returns whether reconnects are slowed down after failing
+// CircuitFailures times in a row for reasons other than credentials
+func (s *reconnectState) circuitOpen() bool {
+	return s.category != ErrAuth && s.backoff.CircuitFailures > 0 && s.total >= s.backoff.CircuitFailures
+}
+
+// status describes the state for /status, the next attempt being after delay
+func (s *reconnectState) status(delay time.Duration) *BackoffStatus {
+	return &BackoffStatus{
+		Failures:    s.total,
+		Category:    s.category,
+		NextAttempt: time.Now().Add(delay),
+		CircuitOpen: s.circuitOpen() || s.authCircuitOpen(),
+	}
+}
+
+// jitter randomises d by up to jitterFraction in either direction
+func jitter(d time.Duration) time.Duration {
+	spread := time.Duration(float64(d) * jitterFraction)
+	if spread <= 0 {
+		return d
+	}
+	return d - spread + rand.N(2*spread)
+}
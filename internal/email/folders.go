@@ -0,0 +1,178 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-imap"
+)
+
+// foldersTTL is how long the folder list of an account is cached
+const foldersTTL = time.Hour
+
+// archiveFolderNames are tried in order when no folder carries the \Archive
+// special-use attribute (RFC 6154)
+var archiveFolderNames = []string{
+	"Archive",
+	"Archives",
+	"INBOX.Archive",
+	"Архив",
+}
+
+// Folder is a mailbox messages can be moved to
+type Folder struct {
+	Name    string
+	Special string // special-use attribute, e.g. imap.ArchiveAttr ("" if none)
+}
+
+// folderCache is the folder list of an account fetched with LIST
+type folderCache struct {
+	folders   []Folder
+	fetchedAt time.Time
+}
+
+// ListFolders returns the selectable mailboxes of the account, INBOX first
+func (c *Client) ListFolders(ctx context.Context) ([]Folder, error) {
+	c.lockCommand()
+	defer c.mu.Unlock()
+
+	if !c.connected || c.client == nil {
+		return nil, errNotConnected
+	}
+	return c.listFolders()
+}
+
+// listFolders runs LIST; the caller holds the client lock
+func (c *Client) listFolders() ([]Folder, error) {
+	mailboxes := make(chan *imap.MailboxInfo, 50)
+	done := make(chan error, 1)
+	go func() {
+		done <- c.client.List("", "*", mailboxes)
+	}()
+
+	var folders []Folder
+	for info := range mailboxes {
+		if slices.Contains(info.Attributes, imap.NoSelectAttr) {
+			continue
+		}
+		folder := Folder{Name: info.Name}
+		for _, attr := range info.Attributes {
+			switch attr {
+			case imap.ArchiveAttr, imap.AllAttr, imap.DraftsAttr, imap.JunkAttr, imap.SentAttr, imap.TrashAttr, imap.FlaggedAttr:
+				folder.Special = attr
+			}
+		}
+		folders = append(folders, folder)
+	}
+	if err := <-done; err != nil {
+		return nil, fmt.Errorf("failed to list mailboxes: %w", classifyError(err))
+	}
+
+	slices.SortStableFunc(folders, func(a, b Folder) int {
+		aInbox, bInbox := strings.EqualFold(a.Name, "INBOX"), strings.EqualFold(b.Name, "INBOX")
+		switch {
+		case aInbox && !bInbox:
+			return -1
+		case bInbox && !aInbox:
+			return 1
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	return folders, nil
+}
+
+// MoveMessage moves a message of INBOX to another mailbox with MOVE, or with
+// COPY, \Deleted and EXPUNGE if the server lacks the MOVE extension
+func (c *Client) MoveMessage(ctx context.Context, uid uint32, folder string) error {
+	c.lockCommand()
+	defer c.mu.Unlock()
+
+	if !c.connected || c.client == nil {
+		return errNotConnected
+	}
+
+	seqSet := new(imap.SeqSet)
+	seqSet.AddNum(uid)
+
+	if err := c.client.UidMove(seqSet, folder); err != nil {
+		return fmt.Errorf("failed to move to %s: %w", folder, classifyError(err))
+	}
+	return nil
+}
+
+// Folders returns the folders of an account, cached for an hour after the
+// first LIST; refresh fetches them again
+func (m *Manager) Folders(ctx context.Context, accountID int64, refresh bool) ([]Folder, error) {
+	m.mu.RLock()
+	wrapper, exists := m.clients[accountID]
+	m.mu.RUnlock()
+
+	if !exists {
+		return nil, errNotConnected
+	}
+
+	if cached := wrapper.folders.Load(); cached != nil && !refresh && time.Since(cached.fetchedAt) < foldersTTL {
+		return cached.folders, nil
+	}
+
+	folders, err := wrapper.client.ListFolders(ctx)
+	if err != nil {
+		return nil, err
+	}
+	wrapper.folders.Store(&folderCache{folders: folders, fetchedAt: time.Now()})
+	return folders, nil
+}
+
+// ArchiveFolder returns the archive folder of an account: the one with the
+// \Archive attribute, a folder with a common archive name or, as on Gmail,
+// the \All folder
+func (m *Manager) ArchiveFolder(ctx context.Context, accountID int64) (string, error) {
+	folders, err := m.Folders(ctx, accountID, false)
+	if err != nil {
+		return "", err
+	}
+
+	if name := specialFolder(folders, imap.ArchiveAttr); name != "" {
+		return name, nil
+	}
+	for _, want := range archiveFolderNames {
+		for _, folder := range folders {
+			if strings.EqualFold(folder.Name, want) {
+				return folder.Name, nil
+			}
+		}
+	}
+	if name := specialFolder(folders, imap.AllAttr); name != "" {
+		return name, nil
+	}
+	return "", fmt.Errorf("%w: no archive folder", ErrMailboxNotFound)
+}
+
+// MoveMessage moves a message of an account to a folder
+func (m *Manager) MoveMessage(accountID int64, uid uint32, folder string) error {
+	m.mu.RLock()
+	wrapper, exists := m.clients[accountID]
+	m.mu.RUnlock()
+
+	if !exists {
+		return errNotConnected
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return wrapper.client.MoveMessage(ctx, uid, folder)
+}
+
+// specialFolder returns the name of the folder with a special-use attribute
+func specialFolder(folders []Folder, attr string) string {
+	for _, folder := range folders {
+		if folder.Special == attr {
+			return folder.Name
+		}
+	}
+	return ""
+}
@@ -2,38 +2,158 @@ package email
 
 import (
 	"log/slog"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/emersion/go-imap/client"
 )
 
-// IdleClient wraps IMAP client with IDLE support
+// IDLE modes of an account
+const (
+	IdleModeAuto = ""     // IDLE if the server supports it, polling otherwise
+	IdleModePoll = "poll" // always poll
+)
+
+// defaultPollInterval is used when ClientConfig.PollInterval is not set
+const defaultPollInterval = time.Minute
+
+// IdleClient waits for mailbox changes on a selected INBOX
 type IdleClient struct {
-	client *client.Client
-	logger *slog.Logger
+	client  *client.Client
+	newMail <-chan struct{}
+	seen    uint32 // mailbox size when the last fetch started
+	logger  *slog.Logger
+}
+
+// NewIdleClient creates a new IDLE client. newMail is signalled by
+// watchMailbox; seen is the mailbox size the last fetch started with.
+func NewIdleClient(c *client.Client, newMail <-chan struct{}, seen uint32, logger *slog.Logger) *IdleClient {
+	return &IdleClient{client: c, newMail: newMail, seen: seen, logger: logger}
 }
 
-// NewIdleClient creates a new IDLE client
-func NewIdleClient(c *client.Client, logger *slog.Logger) *IdleClient {
-	return &IdleClient{client: c, logger: logger}
+// IdleWithFallback returns when new mail may have arrived: on an EXISTS
+// update during IDLE (RFC 2177) if the server supports it and mode allows,
+// or after pollInterval otherwise. IDLE is also ended after timeout, before
+// servers drop idle connections (29 minutes), and the caller fetches as a
+// safety net. usingIdle reports which of the two was used.
+func (ic *IdleClient) IdleWithFallback(stop <-chan struct{}, mode string, timeout, pollInterval time.Duration, usingIdle func(bool)) error {
+	if mode != IdleModePoll {
+		supported, err := ic.client.Support("IDLE")
+		if err != nil {
+			return classifyError(err)
+		}
+		usingIdle(supported)
+		if supported {
+			return ic.idle(stop, timeout)
+		}
+		ic.logger.Debug("server does not support IDLE, polling", "interval", pollInterval)
+	} else {
+		usingIdle(false)
+	}
+
+	if pollInterval <= 0 {
+		pollInterval = defaultPollInterval
+	}
+	return ic.pollFallback(stop, pollInterval)
 }
 
-// IdleWithFallback just uses polling - IDLE library is unreliable
-func (ic *IdleClient) IdleWithFallback(stop <-chan struct{}, timeout time.Duration) error {
-	// Just use polling - it's more reliable
-	ic.logger.Info("using polling", "interval", "15s")
-	return ic.pollFallback(stop, 15*time.Second)
+// idle runs IDLE until the server reports a mailbox change, timeout passes
+// or stop is closed
+func (ic *IdleClient) idle(stop <-chan struct{}, timeout time.Duration) error {
+	// Signals from before were covered by the last fetch, unless the
+	// mailbox grew after it started
+	select {
+	case <-ic.newMail:
+	default:
+	}
+	if mbox := ic.client.Mailbox(); mbox != nil && mbox.Messages > ic.seen {
+		return nil
+	}
+
+	idleStop := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		// Restarting is handled here via timeout, not by go-imap
+		done <- ic.client.Idle(idleStop, &client.IdleOptions{LogoutTimeout: -1})
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-ic.newMail:
+		ic.logger.Debug("IDLE: mailbox changed")
+	case <-timer.C:
+	case <-stop:
+	case err := <-done:
+		// The server ended IDLE, usually by closing the connection
+		if err != nil {
+			return classifyError(err)
+		}
+		return nil
+	}
+
+	close(idleStop)
+	if err := <-done; err != nil {
+		return classifyError(err)
+	}
+	return nil
 }
 
-// pollFallback polls for new messages when IDLE is not supported
-func (ic *IdleClient) pollFallback(stop <-chan struct{}, timeout time.Duration) error {
-	ticker := time.NewTicker(timeout)
-	defer ticker.Stop()
+// pollFallback waits for the poll interval when IDLE is not used
+func (ic *IdleClient) pollFallback(stop <-chan struct{}, interval time.Duration) error {
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
 
 	select {
 	case <-stop:
 		return nil
-	case <-ticker.C:
+	case <-timer.C:
 		return nil
 	}
 }
+
+// watchMailbox drains the unsolicited updates of a connection until it is
+// logged out, turning mailbox changes (EXISTS, RECENT) into a signal on
+// newMail. Flag changes and expunged messages also set stateChanged, so the
+// next fetch cycle syncs read and deleted state. go-imap blocks if Updates is
+// not read.
+func watchMailbox(loggedOut <-chan struct{}, updates <-chan client.Update, newMail chan<- struct{}, stateChanged *atomic.Bool) {
+	for {
+		select {
+		case <-loggedOut:
+			return
+		case update := <-updates:
+			switch update.(type) {
+			case *client.MailboxUpdate:
+			case *client.MessageUpdate, *client.ExpungeUpdate:
+				stateChanged.Store(true)
+			default:
+				continue
+			}
+			select {
+			case newMail <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// idleSession is a running wait for new mail. Other commands cannot be sent
+// while the connection is idling, so they interrupt it first.
+type idleSession struct {
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+func newIdleSession() *idleSession {
+	return &idleSession{stop: make(chan struct{}), done: make(chan struct{})}
+}
+
+// interrupt ends the session and waits until the connection is usable
+func (s *idleSession) interrupt() {
+	s.stopOnce.Do(func() { close(s.stop) })
+	<-s.done
+}
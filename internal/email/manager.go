@@ -2,20 +2,56 @@ package email
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"runtime/debug"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/mixelka/emailresend/internal/config"
 	"github.com/mixelka/emailresend/pkg/models"
 )
 
-// MessageHandler handles new email messages
-type MessageHandler func(accountID int64, msg *RawEmail)
+// MessageHandler handles new email messages. An error means the message was
+// not stored and should be fetched again.
+type MessageHandler func(accountID int64, msg *RawEmail) error
 
 // ErrorHandler handles email errors
 type ErrorHandler func(accountID int64, err error)
 
+// TokenSource returns the current OAuth access token of an account, or "" if
+// the account logs in with a password
+type TokenSource func(ctx context.Context, accountID int64) (string, error)
+
+// UIDLoader returns the last processed UID of an account and the INBOX
+// UIDVALIDITY it belongs to
+type UIDLoader func(ctx context.Context, accountID int64) (lastUID, uidValidity uint32, err error)
+
+// UIDSaver persists the last processed UID of an account together with the
+// UIDVALIDITY it belongs to
+type UIDSaver func(ctx context.Context, accountID int64, lastUID, uidValidity uint32) error
+
+// PanicHandler is notified when an account worker or the message handler
+// panicked and was recovered
+type PanicHandler func(accountID int64, recovered any)
+
+// uidResyncWindow is how far back messages are fetched again when INBOX
+// UIDVALIDITY changes; messages already posted are recognised by Message-ID
+const uidResyncWindow = 24 * time.Hour
+
+const (
+	// workerRestartDelay is the first pause before restarting a panicked
+	// worker; it doubles up to workerRestartMaxDelay
+	workerRestartDelay    = 10 * time.Second
+	workerRestartMaxDelay = 10 * time.Minute
+)
+
+// drainPollInterval is how often Drain checks for running handlers
+const drainPollInterval = 50 * time.Millisecond
+
 // Manager manages all email connections
 type Manager struct {
 	clients      map[int64]*clientWrapper
@@ -24,7 +60,17 @@ type Manager struct {
 	logger       *slog.Logger
 	onMessage    MessageHandler
 	onError      ErrorHandler
+	onPanic      PanicHandler
+	onStall      StallHandler
 	decryptFunc  func(string) string
+	tokenSource  TokenSource
+	loadUIDs     UIDLoader
+	saveUIDs     UIDSaver
+	loadTracked  TrackedLoader
+	onStateSync  StateHandler
+	stalls       atomic.Uint64 // accounts that stalled since startup
+	stopping     atomic.Bool   // StopAll was called; no new messages are handed over
+	handling     atomic.Int64  // message handlers in progress, see Drain
 }
 
 type clientWrapper struct {
@@ -32,6 +78,16 @@ type clientWrapper struct {
 	account   *models.EmailAccount
 	ctx       context.Context
 	cancel    context.CancelFunc
+	degraded  atomic.Bool // worker panicked and waits for a restart
+	lastCycle atomic.Int64 // unix nanoseconds of the last completed fetch cycle
+	lastSync  atomic.Int64 // unix nanoseconds of the last state sync, see syncStates
+	stalled   atomic.Bool  // no fetch cycle completed in time, see RunWatchdog
+	folders   atomic.Pointer[folderCache]
+
+	// INBOX HIGHESTMODSEQ (CONDSTORE) at the last complete fetch and state
+	// sync; only the worker goroutine uses them
+	fetchedModSeq uint64
+	syncedModSeq  uint64
 }
 
 // NewManager creates a new email manager
@@ -53,11 +109,71 @@ func (m *Manager) SetErrorHandler(handler ErrorHandler) {
 	m.onError = handler
 }
 
+// SetPanicHandler sets the handler for recovered panics
+func (m *Manager) SetPanicHandler(handler PanicHandler) {
+	m.onPanic = handler
+}
+
 // SetDecryptFunc sets the password decryption function
 func (m *Manager) SetDecryptFunc(fn func(string) string) {
 	m.decryptFunc = fn
 }
 
+// SetTokenSource sets the source of OAuth access tokens
+func (m *Manager) SetTokenSource(source TokenSource) {
+	m.tokenSource = source
+}
+
+// SetUIDStore sets where the last processed UIDs of accounts are loaded
+// from and saved to. Without it they are taken from the account and kept in
+// memory only.
+func (m *Manager) SetUIDStore(load UIDLoader, save UIDSaver) {
+	m.loadUIDs = load
+	m.saveUIDs = save
+}
+
+// clientConfig returns the client configuration of a stored account
+func (m *Manager) clientConfig(account *models.EmailAccount) ClientConfig {
+	password := account.Password
+	if m.decryptFunc != nil {
+		password = m.decryptFunc(password)
+	}
+
+	cfg := ClientConfig{
+		Email:       account.Email,
+		Password:    password,
+		Server:      account.IMAPServer,
+		IdleTimeout: m.config.IMAPIdleTimeout,
+		DialTimeout: m.config.IMAPDialTimeout,
+
+		IdleMode:     account.IdleMode,
+		PollInterval: m.config.EmailPollInterval,
+
+		MaxMessageSize: m.config.EmailMaxSize,
+
+		Backoff: Backoff{
+			Delay:           m.config.ReconnectDelay,
+			MaxDelay:        m.config.ReconnectMaxDelay,
+			CircuitFailures: m.config.CircuitFailures,
+			CircuitCooldown: m.config.CircuitCooldown,
+		},
+	}
+	// Accounts may check mail faster or slower than the global settings
+	if account.PollInterval > 0 {
+		cfg.PollInterval = time.Duration(account.PollInterval) * time.Second
+	}
+	if account.IdleTimeout > 0 {
+		cfg.IdleTimeout = time.Duration(account.IdleTimeout) * time.Second
+	}
+	if m.tokenSource != nil {
+		accountID := account.ID
+		cfg.TokenSource = func(ctx context.Context) (string, error) {
+			return m.tokenSource(ctx, accountID)
+		}
+	}
+	return cfg
+}
+
 // TestConnection tests an IMAP connection
 func (m *Manager) TestConnection(ctx context.Context, email, password, server string) error {
 	client := NewClient(ClientConfig{
@@ -81,8 +197,40 @@ func (m *Manager) TestConnection(ctx context.Context, email, password, server st
 	return nil
 }
 
+// TestOAuthConnection tests logging in with an OAuth access token
+func (m *Manager) TestOAuthConnection(ctx context.Context, email, accessToken, server string) error {
+	client := NewClient(ClientConfig{
+		Email:       email,
+		Server:      server,
+		IdleTimeout: m.config.IMAPIdleTimeout,
+		DialTimeout: m.config.IMAPDialTimeout,
+		TokenSource: func(context.Context) (string, error) {
+			return accessToken, nil
+		},
+	}, m.logger)
+
+	if err := client.Connect(ctx); err != nil {
+		return err
+	}
+
+	if _, err := client.SelectINBOX(ctx); err != nil {
+		client.Stop()
+		return err
+	}
+
+	client.Stop()
+	return nil
+}
+
 // AddAccount adds and starts an email connection
 func (m *Manager) AddAccount(ctx context.Context, account *models.EmailAccount) error {
+	return m.addAccount(ctx, account, false)
+}
+
+// addAccount adds and starts an email connection. With retryTransient set,
+// an account whose server is unreachable or rate limits the session is still
+// started and keeps reconnecting with backoff.
+func (m *Manager) addAccount(ctx context.Context, account *models.EmailAccount, retryTransient bool) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -91,28 +239,16 @@ func (m *Manager) AddAccount(ctx context.Context, account *models.EmailAccount)
 		return nil
 	}
 
-	// Decrypt password
-	password := account.Password
-	if m.decryptFunc != nil {
-		password = m.decryptFunc(password)
-	}
-
 	// Create client
-	client := NewClient(ClientConfig{
-		Email:       account.Email,
-		Password:    password,
-		Server:      account.IMAPServer,
-		IdleTimeout: m.config.IMAPIdleTimeout,
-		DialTimeout: m.config.IMAPDialTimeout,
-	}, m.logger)
+	client := NewClient(m.clientConfig(account), m.logger)
 
-	// Connect
+	// Connect and select INBOX
 	if err := client.Connect(ctx); err != nil {
-		return err
-	}
-
-	// Select INBOX
-	if _, err := client.SelectINBOX(ctx); err != nil {
+		if !retryTransient || !isTransient(err) {
+			return err
+		}
+		m.logger.Warn("server unavailable, starting disconnected", "email", account.Email, "error", err)
+	} else if _, err := client.SelectINBOX(ctx); err != nil {
 		client.Stop()
 		return err
 	}
@@ -125,6 +261,7 @@ func (m *Manager) AddAccount(ctx context.Context, account *models.EmailAccount)
 		ctx:     clientCtx,
 		cancel:  cancel,
 	}
+	wrapper.lastCycle.Store(time.Now().UnixNano())
 
 	m.clients[account.ID] = wrapper
 
@@ -135,54 +272,256 @@ func (m *Manager) AddAccount(ctx context.Context, account *models.EmailAccount)
 	return nil
 }
 
-// runClient runs the email client
+// uidState is the position of an account worker in its INBOX
+type uidState struct {
+	lastUID  uint32
+	validity uint32 // UIDVALIDITY lastUID belongs to, 0 if not seen yet
+}
+
+// runClient runs the email client, restarting it with backoff if it panics.
+// Every start continues from the stored UID state.
 func (m *Manager) runClient(wrapper *clientWrapper) {
-	lastUID := wrapper.account.LastUID
+	delay := workerRestartDelay
+
+	for {
+		started := time.Now()
+		state := m.loadUIDState(wrapper)
+		if !m.runClientOnce(wrapper, &state) {
+			return
+		}
+
+		// A worker that ran for a while before panicking starts over with
+		// the short delay
+		if time.Since(started) > workerRestartMaxDelay {
+			delay = workerRestartDelay
+		}
+		m.logger.Info("restarting email worker", "account_id", wrapper.account.ID, "delay", delay)
 
-	// Initial fetch of new messages
-	m.fetchNewMessages(wrapper, &lastUID)
+		select {
+		case <-wrapper.ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, workerRestartMaxDelay)
+		wrapper.degraded.Store(false)
+	}
+}
+
+// runClientOnce fetches new messages and runs IDLE until the client stops.
+// Returns true if it panicked.
+func (m *Manager) runClientOnce(wrapper *clientWrapper, state *uidState) (panicked bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			panicked = true
+			wrapper.degraded.Store(true)
+			m.reportPanic(wrapper.account.ID, r)
+			// Start over with a fresh session
+			wrapper.client.handleDisconnect()
+		}
+	}()
+
+	// Initial fetch of new messages (restored accounts may start
+	// disconnected and catch up after the first reconnect)
+	if wrapper.client.IsConnected() {
+		m.fetchNewMessages(wrapper, state)
+	}
 
 	// Start IDLE
 	wrapper.client.StartIDLE(wrapper.ctx, func() {
-		m.fetchNewMessages(wrapper, &lastUID)
+		m.fetchNewMessages(wrapper, state)
+	}, func(err error) {
+		if m.onError != nil {
+			m.onError(wrapper.account.ID, err)
+		}
 	})
+	return false
+}
+
+// Drain waits until the messages being handed over when StopAll was called
+// are handled. It returns false if ctx ends first.
+func (m *Manager) Drain(ctx context.Context) bool {
+	for m.handling.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			m.logger.Warn("email handlers still running at shutdown", "count", m.handling.Load())
+			return false
+		case <-time.After(drainPollInterval):
+		}
+	}
+	return true
+}
+
+// handleMessage passes a message to the message handler. A panic is
+// recovered and counts as handled, so that one bad email cannot stop the
+// account.
+func (m *Manager) handleMessage(accountID int64, msg *RawEmail) (err error) {
+	// Counted before checking stopping, so Drain sees every handler that
+	// got past the check
+	m.handling.Add(1)
+	defer m.handling.Add(-1)
+	if m.stopping.Load() {
+		return errStopping
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			m.reportPanic(accountID, r)
+			err = nil
+		}
+	}()
+
+	if m.onMessage != nil {
+		return m.onMessage(accountID, msg)
+	}
+	return nil
+}
+
+// reportPanic logs a recovered panic with its stack trace and notifies the
+// panic handler
+func (m *Manager) reportPanic(accountID int64, recovered any) {
+	m.logger.Error("email worker panic", "account_id", accountID, "panic", recovered, "stack", string(debug.Stack()))
+	if m.onPanic != nil {
+		m.onPanic(accountID, recovered)
+	}
 }
 
 // fetchNewMessages fetches and processes new messages
-func (m *Manager) fetchNewMessages(wrapper *clientWrapper, lastUID *uint32) {
+func (m *Manager) fetchNewMessages(wrapper *clientWrapper, state *uidState) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
+	defer func() { wrapper.lastCycle.Store(time.Now().UnixNano()) }()
 
 	// Select INBOX (in case of reconnect)
-	if _, err := wrapper.client.SelectINBOX(ctx); err != nil {
-		m.logger.Error("failed to select INBOX", "error", err, "account_id", wrapper.account.ID)
-		if m.onError != nil {
-			m.onError(wrapper.account.ID, err)
-		}
+	mbox, err := wrapper.client.SelectINBOX(ctx)
+	if err != nil {
+		m.handleFetchError(wrapper, "failed to select INBOX", err)
+		return
+	}
+
+	if err := m.checkUIDValidity(ctx, wrapper, state, mbox.UidValidity); err != nil {
+		m.handleFetchError(wrapper, "failed to resync after UIDVALIDITY change", err)
+		return
+	}
+
+	// Nothing arrived or changed in INBOX since the last cycle
+	modSeq := wrapper.client.modSeq.Load()
+	if modSeq != 0 && modSeq == wrapper.fetchedModSeq {
+		m.syncStates(ctx, wrapper, modSeq)
 		return
 	}
 
 	// Fetch new messages
-	messages, err := wrapper.client.FetchNewMessages(ctx, *lastUID)
-	if err != nil {
-		m.logger.Error("failed to fetch messages", "error", err, "account_id", wrapper.account.ID)
-		if m.onError != nil {
-			m.onError(wrapper.account.ID, err)
-		}
+	messages, err := wrapper.client.FetchNewMessages(ctx, state.lastUID)
+	if err != nil && !errors.Is(err, errIncompleteFetch) {
+		m.handleFetchError(wrapper, "failed to fetch messages", err)
 		return
 	}
 
-	// Process messages
+	// Process messages, saving the position after each one so that a
+	// restart neither repeats nor skips any
 	for _, msg := range messages {
-		if m.onMessage != nil {
-			m.onMessage(wrapper.account.ID, msg)
+		if err := m.handleMessage(wrapper.account.ID, msg); err != nil {
+			// The rest is fetched again after a restart
+			if errors.Is(err, errStopping) {
+				return
+			}
+			// Stop here and fetch the message again in the next cycle
+			m.logger.Error("failed to handle message", "error", err, "account_id", wrapper.account.ID, "uid", msg.UID)
+			return
 		}
 
-		// Update last UID
-		if msg.UID > *lastUID {
-			*lastUID = msg.UID
+		if msg.UID > state.lastUID {
+			state.lastUID = msg.UID
+			m.saveUIDState(wrapper, state)
 		}
 	}
+	// The rest is fetched again in the next cycle
+	if err != nil {
+		return
+	}
+	wrapper.fetchedModSeq = modSeq
+
+	m.syncStates(ctx, wrapper, modSeq)
+}
+
+// checkUIDValidity compares the INBOX UIDVALIDITY with the one the stored
+// UID belongs to. If the server renumbered the mailbox, the stored UID means
+// nothing: the worker starts over from the messages of the last
+// uidResyncWindow.
+func (m *Manager) checkUIDValidity(ctx context.Context, wrapper *clientWrapper, state *uidState, validity uint32) error {
+	if validity == 0 || validity == state.validity {
+		return nil
+	}
+
+	// First fetch since UIDVALIDITY is tracked
+	if state.validity == 0 {
+		state.validity = validity
+		m.saveUIDState(wrapper, state)
+		return nil
+	}
+
+	m.logger.Warn("INBOX UIDVALIDITY changed, resyncing",
+		"account_id", wrapper.account.ID, "old", state.validity, "new", validity)
+	uids, err := wrapper.client.SearchSince(ctx, time.Now().Add(-uidResyncWindow))
+	if err != nil {
+		return err
+	}
+
+	var lastUID uint32
+	if len(uids) > 0 {
+		lastUID = slices.Min(uids) - 1
+	} else if lastUID, err = wrapper.client.GetHighestUID(ctx); err != nil {
+		return err
+	}
+	*state = uidState{lastUID: lastUID, validity: validity}
+	m.saveUIDState(wrapper, state)
+	// Mod-sequences of the old mailbox mean nothing either
+	wrapper.fetchedModSeq, wrapper.syncedModSeq = 0, 0
+	return nil
+}
+
+// loadUIDState returns the stored UID state of an account
+func (m *Manager) loadUIDState(wrapper *clientWrapper) uidState {
+	state := uidState{lastUID: wrapper.account.LastUID, validity: wrapper.account.UIDValidity}
+	if m.loadUIDs == nil {
+		return state
+	}
+
+	ctx, cancel := context.WithTimeout(wrapper.ctx, 10*time.Second)
+	defer cancel()
+	lastUID, validity, err := m.loadUIDs(ctx, wrapper.account.ID)
+	if err != nil {
+		m.logger.Error("failed to load uid state", "error", err, "account_id", wrapper.account.ID)
+		return state
+	}
+	return uidState{lastUID: lastUID, validity: validity}
+}
+
+// saveUIDState persists the UID state of an account
+func (m *Manager) saveUIDState(wrapper *clientWrapper, state *uidState) {
+	if m.saveUIDs == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := m.saveUIDs(ctx, wrapper.account.ID, state.lastUID, state.validity); err != nil {
+		m.logger.Error("failed to save uid state", "error", err, "account_id", wrapper.account.ID)
+	}
+}
+
+// handleFetchError reports a failed fetch. A dropped connection is only
+// logged: the session is reset and IDLE reconnects with backoff, reporting
+// the error itself if reconnecting keeps failing.
+func (m *Manager) handleFetchError(wrapper *clientWrapper, msg string, err error) {
+	m.logger.Error(msg, "error", err, "account_id", wrapper.account.ID, "category", Category(err))
+	if errors.Is(err, ErrNetwork) {
+		wrapper.client.handleDisconnect()
+		return
+	}
+	if m.onError != nil {
+		m.onError(wrapper.account.ID, err)
+	}
 }
 
 // RemoveAccount stops and removes an email connection
@@ -203,6 +542,15 @@ func (m *Manager) RemoveAccount(accountID int64) error {
 	return nil
 }
 
+// RestartAccount stops the account's connection and starts it again with
+// the given account data (e.g. after a password change)
+func (m *Manager) RestartAccount(ctx context.Context, account *models.EmailAccount) error {
+	if err := m.RemoveAccount(account.ID); err != nil {
+		return err
+	}
+	return m.AddAccount(ctx, account)
+}
+
 // GetStatus returns the status of an account
 func (m *Manager) GetStatus(accountID int64) string {
 	m.mu.RLock()
@@ -213,12 +561,44 @@ func (m *Manager) GetStatus(accountID int64) string {
 		return "disconnected"
 	}
 
+	if wrapper.degraded.Load() {
+		return "degraded"
+	}
+	if wrapper.client.AuthFailed() {
+		return "auth_failed"
+	}
+	if wrapper.stalled.Load() {
+		return "stalled"
+	}
 	if wrapper.client.IsConnected() {
 		return "connected"
 	}
 	return "reconnecting"
 }
 
+// Backoff returns the state of reconnect attempts of an account, or nil if
+// it is not retrying
+func (m *Manager) Backoff(accountID int64) *BackoffStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	wrapper, exists := m.clients[accountID]
+	if !exists {
+		return nil
+	}
+	return wrapper.client.Backoff()
+}
+
+// UsingIdle returns whether the account waits for new mail with IDLE
+// rather than polling
+func (m *Manager) UsingIdle(accountID int64) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	wrapper, exists := m.clients[accountID]
+	return exists && wrapper.client.UsingIdle()
+}
+
 // MarkAsRead marks a message as read
 func (m *Manager) MarkAsRead(accountID int64, uid uint32) error {
 	m.mu.RLock()
@@ -235,6 +615,31 @@ func (m *Manager) MarkAsRead(accountID int64, uid uint32) error {
 	return wrapper.client.MarkAsRead(ctx, uid)
 }
 
+// Refetch fetches the last count messages of an account received since the
+// given day, regardless of the last processed UID, and passes them to the
+// message handler. Messages stored before are skipped by the handler.
+// Returns the number of messages fetched.
+func (m *Manager) Refetch(ctx context.Context, accountID int64, count int, since time.Time) (int, error) {
+	m.mu.RLock()
+	wrapper, exists := m.clients[accountID]
+	m.mu.RUnlock()
+
+	if !exists {
+		return 0, errNotConnected
+	}
+
+	messages, err := wrapper.client.FetchRecent(ctx, count, since)
+	if err != nil {
+		return 0, err
+	}
+	for _, msg := range messages {
+		if err := m.handleMessage(accountID, msg); err != nil {
+			return 0, err
+		}
+	}
+	return len(messages), nil
+}
+
 // DeleteMessage deletes a message
 func (m *Manager) DeleteMessage(accountID int64, uid uint32) error {
 	m.mu.RLock()
@@ -251,6 +656,54 @@ func (m *Manager) DeleteMessage(accountID int64, uid uint32) error {
 	return wrapper.client.DeleteMessage(ctx, uid)
 }
 
+// FetchAttachment downloads an attachment of a message
+func (m *Manager) FetchAttachment(accountID int64, uid uint32, index int) (*AttachmentData, error) {
+	m.mu.RLock()
+	wrapper, exists := m.clients[accountID]
+	m.mu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("account is not connected")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	return wrapper.client.FetchAttachment(ctx, uid, index)
+}
+
+// FetchInlineImages downloads the images a message references by Content-ID
+func (m *Manager) FetchInlineImages(accountID int64, uid uint32) (map[string]*AttachmentData, error) {
+	m.mu.RLock()
+	wrapper, exists := m.clients[accountID]
+	m.mu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("account is not connected")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	return wrapper.client.FetchInlineImages(ctx, uid)
+}
+
+// FetchRaw downloads the complete RFC822 source of a message in INBOX
+func (m *Manager) FetchRaw(accountID int64, uid uint32) ([]byte, error) {
+	m.mu.RLock()
+	wrapper, exists := m.clients[accountID]
+	m.mu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("account is not connected")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	return wrapper.client.FetchRaw(ctx, uid)
+}
+
 // RestoreAll restores all email connections from database
 func (m *Manager) RestoreAll(ctx context.Context, accounts []*models.EmailAccount) {
 	m.logger.Info("restoring email accounts", "count", len(accounts))
@@ -260,7 +713,7 @@ func (m *Manager) RestoreAll(ctx context.Context, accounts []*models.EmailAccoun
 		wg.Add(1)
 		go func(acc *models.EmailAccount) {
 			defer wg.Done()
-			if err := m.AddAccount(ctx, acc); err != nil {
+			if err := m.addAccount(ctx, acc, true); err != nil {
 				m.logger.Error("failed to restore account", "email", acc.Email, "error", err)
 			}
 		}(account)
@@ -276,6 +729,7 @@ func (m *Manager) StopAll() {
 	defer m.mu.Unlock()
 
 	m.logger.Info("stopping all email clients")
+	m.stopping.Store(true)
 
 	for id, wrapper := range m.clients {
 		wrapper.cancel()
@@ -1,6 +1,7 @@
 package email
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"strings"
@@ -28,8 +29,8 @@ var knownIMAPServers = map[string]string{
 	"mac.com":         "imap.mail.me.com:993",
 	"aol.com":         "imap.aol.com:993",
 	"zoho.com":        "imap.zoho.com:993",
-	"protonmail.com":  "127.0.0.1:1143", // ProtonMail Bridge
-	"proton.me":       "127.0.0.1:1143",
+	"protonmail.com":  "127.0.0.1:1143/starttls/insecure", // ProtonMail Bridge, self-signed certificate
+	"proton.me":       "127.0.0.1:1143/starttls/insecure",
 	"fastmail.com":    "imap.fastmail.com:993",
 	"gmx.com":         "imap.gmx.com:993",
 	"gmx.de":          "imap.gmx.net:993",
@@ -40,16 +41,43 @@ var knownIMAPServers = map[string]string{
 
 // ResolveIMAPServer determines the IMAP server for an email address
 func ResolveIMAPServer(email string) (string, error) {
+	servers, err := ResolveServers(context.Background(), email)
+	if err != nil {
+		return "", err
+	}
+	return servers.IMAP, nil
+}
+
+// ResolveServers determines the IMAP and SMTP submission servers for an
+// email address: known providers, RFC 6186 SRV records, Mozilla autoconfig,
+// Microsoft autodiscover, then common host name patterns and MX records.
+// SMTP is empty unless published by the domain
+func ResolveServers(ctx context.Context, email string) (Servers, error) {
 	parts := strings.Split(email, "@")
 	if len(parts) != 2 {
-		return "", fmt.Errorf("invalid email format")
+		return Servers{}, fmt.Errorf("invalid email format")
 	}
 
 	domain := strings.ToLower(parts[1])
 
 	// Check known providers first
 	if server, ok := knownIMAPServers[domain]; ok {
-		return server, nil
+		return Servers{IMAP: server}, nil
+	}
+
+	// Servers published by the domain itself
+	srv := lookupSRV(ctx, domain)
+	if srv.IMAP != "" {
+		if srv.SMTP == "" {
+			srv.SMTP = lookupAutoconfig(ctx, email, domain).SMTP
+		}
+		return srv, nil
+	}
+	if servers := lookupAutoconfig(ctx, email, domain); servers.IMAP != "" {
+		return servers, nil
+	}
+	if servers := lookupAutodiscover(ctx, email, domain); servers.IMAP != "" {
+		return servers, nil
 	}
 
 	// Try common IMAP server patterns
@@ -62,18 +90,18 @@ func ResolveIMAPServer(email string) (string, error) {
 	for _, pattern := range patterns {
 		host := strings.TrimSuffix(pattern, ":993")
 		if checkIMAPServer(host, 993) {
-			return pattern, nil
+			return Servers{IMAP: pattern, SMTP: srv.SMTP}, nil
 		}
 	}
 
 	// Try to resolve via MX records
 	mxServer, err := resolveViaMX(domain)
 	if err == nil && mxServer != "" {
-		return mxServer, nil
+		return Servers{IMAP: mxServer, SMTP: srv.SMTP}, nil
 	}
 
 	// Default fallback - try imap.domain:993
-	return "imap." + domain + ":993", nil
+	return Servers{IMAP: "imap." + domain + ":993", SMTP: srv.SMTP}, nil
 }
 
 // checkIMAPServer checks if an IMAP server is reachable
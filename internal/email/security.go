@@ -0,0 +1,121 @@
+package email
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-imap/client"
+)
+
+// Connection security of an IMAP server, given as a suffix of its address,
+// e.g. "imap.example.com:143/starttls"
+const (
+	SecurityTLS      = "tls"      // implicit TLS (default)
+	SecuritySTARTTLS = "starttls" // plaintext connection upgraded with STARTTLS
+	SecurityPlain    = "plain"    // no encryption, for servers on a trusted network
+)
+
+// securityInsecure is the address option that skips certificate
+// verification, e.g. for the self-signed certificate of ProtonMail Bridge
+const securityInsecure = "insecure"
+
+// ServerSpec is a parsed IMAP server address
+type ServerSpec struct {
+	Addr     string // host:port
+	Host     string
+	Security string // SecurityTLS, SecuritySTARTTLS or SecurityPlain
+	Insecure bool   // accept any TLS certificate
+}
+
+// ParseServer parses an IMAP server address of the form
+// host:port[/tls|/starttls|/plain][/insecure]
+func ParseServer(server string) (ServerSpec, error) {
+	parts := strings.Split(strings.TrimSpace(server), "/")
+	spec := ServerSpec{Addr: parts[0], Security: SecurityTLS}
+
+	host, _, err := net.SplitHostPort(spec.Addr)
+	if err != nil || host == "" {
+		return spec, fmt.Errorf("invalid server address %q: expected host:port", server)
+	}
+	spec.Host = host
+
+	for _, opt := range parts[1:] {
+		switch opt = strings.ToLower(opt); opt {
+		case SecurityTLS, SecuritySTARTTLS, SecurityPlain:
+			spec.Security = opt
+		case securityInsecure:
+			spec.Insecure = true
+		default:
+			return spec, fmt.Errorf("unknown server option %q: expected tls, starttls, plain or insecure", opt)
+		}
+	}
+	return spec, nil
+}
+
+// ServerHost returns the host of an IMAP server address, or the address
+// itself if it cannot be parsed
+func ServerHost(server string) string {
+	if spec, err := ParseServer(server); err == nil {
+		return spec.Host
+	}
+	return server
+}
+
+// tlsConfig returns the TLS configuration for the server
+func (s ServerSpec) tlsConfig() *tls.Config {
+	return &tls.Config{ServerName: s.Host, InsecureSkipVerify: s.Insecure}
+}
+
+// dialIMAP connects to an IMAP server with the security of its address and
+// reads the greeting
+func dialIMAP(ctx context.Context, server string, timeout time.Duration) (*client.Client, error) {
+	spec, err := ParseServer(server)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	if spec.Security == SecurityTLS {
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: spec.tlsConfig()}
+		conn, err = tlsDialer.DialContext(ctx, "tcp", spec.Addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", spec.Addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w: %w", ErrNetwork, err)
+	}
+
+	c, err := client.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create IMAP client: %w", classifyError(err))
+	}
+
+	if spec.Security == SecuritySTARTTLS {
+		if err := startTLS(c, spec); err != nil {
+			c.Logout()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// startTLS upgrades a plaintext connection with STARTTLS
+func startTLS(c *client.Client, spec ServerSpec) error {
+	ok, err := c.SupportStartTLS()
+	if err != nil {
+		return fmt.Errorf("failed to get capabilities: %w", classifyError(err))
+	}
+	if !ok {
+		return fmt.Errorf("server does not support STARTTLS")
+	}
+	if err := c.StartTLS(spec.tlsConfig()); err != nil {
+		return fmt.Errorf("failed to start TLS: %w: %w", ErrNetwork, err)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-imap"
+
+	"github.com/mixelka/emailresend/pkg/models"
+)
+
+// sentMailboxNames are tried in order when no mailbox carries the \Sent
+// special-use attribute (RFC 6154)
+var sentMailboxNames = []string{
+	"Sent",
+	"Sent Items",
+	"Sent Messages",
+	"Sent Mail",
+	"INBOX.Sent",
+	"Отправленные",
+}
+
+// serversSavingSent store messages sent through their SMTP server in the
+// Sent folder themselves; appending would create a second copy
+var serversSavingSent = map[string]bool{
+	"imap.gmail.com":        true,
+	"outlook.office365.com": true,
+}
+
+// SavesSentItself returns whether the provider of an IMAP server copies
+// messages sent over SMTP to the Sent folder on its own
+func SavesSentItself(imapServer string) bool {
+	return serversSavingSent[strings.ToLower(ServerHost(imapServer))]
+}
+
+// AppendToSent stores a sent message in the Sent folder, marked as read, and
+// returns the mailbox name
+func (c *Client) AppendToSent(ctx context.Context, raw []byte) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.connected || c.client == nil {
+		return "", errNotConnected
+	}
+
+	mailbox, err := c.sentMailbox()
+	if err != nil {
+		return "", err
+	}
+
+	if err := c.client.Append(mailbox, []string{imap.SeenFlag}, time.Now(), bytes.NewBuffer(raw)); err != nil {
+		return "", fmt.Errorf("failed to append to %s: %w", mailbox, classifyError(err))
+	}
+	return mailbox, nil
+}
+
+// sentMailbox finds the Sent folder by its special-use attribute or name
+func (c *Client) sentMailbox() (string, error) {
+	folders, err := c.listFolders()
+	if err != nil {
+		return "", err
+	}
+	if special := specialFolder(folders, imap.SentAttr); special != "" {
+		return special, nil
+	}
+
+	for _, want := range sentMailboxNames {
+		for _, folder := range folders {
+			if strings.EqualFold(folder.Name, want) {
+				return folder.Name, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("%w: no Sent folder", ErrMailboxNotFound)
+}
+
+// AppendSent stores a message sent from an account in its Sent folder over
+// a separate short-lived session, so the account's IDLE session is not
+// interrupted. Returns the mailbox name.
+func (m *Manager) AppendSent(ctx context.Context, account *models.EmailAccount, raw []byte) (string, error) {
+	client := NewClient(m.clientConfig(account), m.logger)
+	defer client.Stop()
+
+	if err := client.Connect(ctx); err != nil {
+		return "", err
+	}
+	return client.AppendToSent(ctx, raw)
+}
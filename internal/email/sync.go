@@ -0,0 +1,198 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/emersion/go-imap"
+)
+
+// TrackedMessage is a posted email whose mailbox state is followed
+type TrackedMessage struct {
+	ID        int64  // Database ID
+	UID       uint32 // IMAP UID in INBOX
+	MessageID string // Message-ID header, tells a renumbered UID apart
+	Read      bool   // Already shown as read
+}
+
+// StateChange reports that a tracked email was read or deleted in the
+// mailbox by another client
+type StateChange struct {
+	ID      int64 // Database ID
+	UID     uint32
+	Read    bool // \Seen was set
+	Deleted bool // Deleted, expunged or moved out of INBOX
+}
+
+// TrackedLoader returns the posted emails of an account whose mailbox state
+// is followed
+type TrackedLoader func(ctx context.Context, accountID int64) ([]TrackedMessage, error)
+
+// StateHandler handles emails read or deleted in the mailbox
+type StateHandler func(accountID int64, changes []StateChange)
+
+// messageState is the state of a message in INBOX
+type messageState struct {
+	messageID string
+	seen      bool
+	deleted   bool
+	unchanged bool // not modified since the last sync (CONDSTORE), nothing else is known
+}
+
+// SetStateSync sets where the tracked emails of accounts are loaded from and
+// who is told about emails read or deleted in the mailbox. Without it the
+// state is not synced.
+func (m *Manager) SetStateSync(load TrackedLoader, handle StateHandler) {
+	m.loadTracked = load
+	m.onStateSync = handle
+}
+
+// syncStates checks the tracked emails of an account for being read or
+// deleted by another client. It runs in the fetch cycle when the connection
+// reported flag changes or expunges, and every StateSyncInterval otherwise.
+//
+// modSeq is the INBOX HIGHESTMODSEQ of the cycle if the server supports
+// CONDSTORE. The sync is then skipped while it stays the same, except that
+// servers without QRESYNC do not count expunges in it, and only messages
+// modified since the last sync are fetched.
+func (m *Manager) syncStates(ctx context.Context, wrapper *clientWrapper, modSeq uint64) {
+	interval := m.config.StateSyncInterval
+	if m.loadTracked == nil || m.onStateSync == nil || interval <= 0 {
+		return
+	}
+	changed := wrapper.client.stateChanged.Swap(false)
+	due := changed || time.Since(time.Unix(0, wrapper.lastSync.Load())) >= interval
+	if modSeq != 0 && modSeq == wrapper.syncedModSeq && (wrapper.client.qresync.Load() || !due) {
+		return
+	}
+	if modSeq == 0 && !due {
+		return
+	}
+	wrapper.lastSync.Store(time.Now().UnixNano())
+
+	tracked, err := m.loadTracked(ctx, wrapper.account.ID)
+	if err != nil {
+		m.logger.Error("failed to load tracked messages", "error", err, "account_id", wrapper.account.ID)
+		return
+	}
+	if len(tracked) == 0 {
+		wrapper.syncedModSeq = modSeq
+		return
+	}
+
+	uids := make([]uint32, 0, len(tracked))
+	for _, t := range tracked {
+		uids = append(uids, t.UID)
+	}
+	var states map[uint32]messageState
+	if modSeq != 0 {
+		states, err = wrapper.client.fetchChangedStates(uids, wrapper.syncedModSeq)
+	} else {
+		states, err = wrapper.client.fetchStates(uids)
+	}
+	if err != nil {
+		m.handleFetchError(wrapper, "failed to sync message states", err)
+		return
+	}
+
+	var changes []StateChange
+	for _, t := range tracked {
+		state, ok := states[t.UID]
+		if ok && state.unchanged {
+			continue
+		}
+		if ok && t.MessageID != "" && state.messageID != t.MessageID {
+			// The UID belongs to another message since INBOX was renumbered
+			ok = false
+		}
+		if ok && state.deleted {
+			changes = append(changes, StateChange{ID: t.ID, UID: t.UID, Deleted: true})
+			continue
+		}
+		if !ok {
+			// Gone from its UID; it may still be in INBOX under a new one
+			found, err := wrapper.client.searchMessageID(t.MessageID)
+			if err != nil {
+				m.handleFetchError(wrapper, "failed to sync message states", err)
+				return
+			}
+			if !found {
+				changes = append(changes, StateChange{ID: t.ID, UID: t.UID, Deleted: true})
+			}
+			continue
+		}
+		if state.seen && !t.Read {
+			changes = append(changes, StateChange{ID: t.ID, UID: t.UID, Read: true})
+		}
+	}
+	wrapper.syncedModSeq = modSeq
+	if len(changes) == 0 {
+		return
+	}
+
+	m.logger.Info("emails changed in the mailbox", "account_id", wrapper.account.ID, "changes", len(changes))
+	m.onStateSync(wrapper.account.ID, changes)
+}
+
+// fetchStates returns the flags and Message-ID of messages by UID; UIDs no
+// longer in INBOX are missing from the result. The caller runs in the fetch
+// cycle, while the connection is not idling.
+func (c *Client) fetchStates(uids []uint32) (map[uint32]messageState, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.connected || c.client == nil {
+		return nil, errNotConnected
+	}
+
+	seqSet := new(imap.SeqSet)
+	seqSet.AddNum(uids...)
+	messages := make(chan *imap.Message, 100)
+	done := make(chan error, 1)
+	go func() {
+		done <- c.client.UidFetch(seqSet, []imap.FetchItem{imap.FetchUid, imap.FetchFlags, imap.FetchEnvelope}, messages)
+	}()
+
+	states := make(map[uint32]messageState, len(uids))
+	for msg := range messages {
+		state := messageState{
+			seen:    slices.Contains(msg.Flags, imap.SeenFlag),
+			deleted: slices.Contains(msg.Flags, imap.DeletedFlag),
+		}
+		if msg.Envelope != nil {
+			state.messageID = msg.Envelope.MessageId
+		}
+		states[msg.Uid] = state
+	}
+
+	if err := <-done; err != nil {
+		return nil, fmt.Errorf("failed to fetch flags: %w", classifyError(err))
+	}
+	return states, nil
+}
+
+// searchMessageID reports whether a message with the Message-ID is in INBOX
+// and not flagged \Deleted. Without a Message-ID nothing can be found.
+func (c *Client) searchMessageID(messageID string) (bool, error) {
+	if messageID == "" {
+		return false, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.connected || c.client == nil {
+		return false, errNotConnected
+	}
+
+	criteria := imap.NewSearchCriteria()
+	criteria.Header.Set("Message-Id", messageID)
+	criteria.WithoutFlags = []string{imap.DeletedFlag}
+	uids, err := c.client.UidSearch(criteria)
+	if err != nil {
+		return false, fmt.Errorf("failed to search: %w", classifyError(err))
+	}
+	return len(uids) > 0, nil
+}
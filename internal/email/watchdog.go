@@ -0,0 +1,85 @@
+package email
+
+import (
+	"context"
+	"time"
+)
+
+// StallHandler is notified when an account has not completed a fetch cycle
+// for stallFactor times its expected interval, and again once it recovers
+// (stalled false)
+type StallHandler func(accountID int64, stalled bool, since time.Duration)
+
+const (
+	// watchdogInterval is how often the watchdog checks the accounts
+	watchdogInterval = time.Minute
+
+	// stallFactor is how many expected intervals may pass without a
+	// completed fetch cycle before an account counts as stalled
+	stallFactor = 2
+)
+
+// SetStallHandler sets the handler for stalled and recovered accounts
+func (m *Manager) SetStallHandler(handler StallHandler) {
+	m.onStall = handler
+}
+
+// Stalls returns the number of times an account stalled since startup
+func (m *Manager) Stalls() uint64 {
+	return m.stalls.Load()
+}
+
+// RunWatchdog checks every watchdogInterval that each connected account
+// completes a fetch cycle within stallFactor times its expected interval (the
+// IDLE timeout or the poll interval). This catches a wait for new mail that
+// hangs without an error, which otherwise looks like emails just stopped.
+func (m *Manager) RunWatchdog(ctx context.Context) {
+	ticker := time.NewTicker(watchdogInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.checkStalls(time.Now())
+		}
+	}
+}
+
+// checkStalls marks accounts stalled or recovered and notifies the handler
+func (m *Manager) checkStalls(now time.Time) {
+	m.mu.RLock()
+	wrappers := make([]*clientWrapper, 0, len(m.clients))
+	for _, wrapper := range m.clients {
+		wrappers = append(wrappers, wrapper)
+	}
+	m.mu.RUnlock()
+
+	for _, wrapper := range wrappers {
+		// Reconnecting and degraded accounts are reported on their own; the
+		// clock starts over once they are back
+		if !wrapper.client.IsConnected() || wrapper.client.AuthFailed() || wrapper.degraded.Load() {
+			wrapper.lastCycle.Store(now.UnixNano())
+			continue
+		}
+
+		since := now.Sub(time.Unix(0, wrapper.lastCycle.Load()))
+		stalled := since > stallFactor*wrapper.client.cycleInterval()
+		if wrapper.stalled.Swap(stalled) == stalled {
+			continue
+		}
+
+		accountID := wrapper.account.ID
+		if stalled {
+			m.stalls.Add(1)
+			m.logger.Warn("email worker stalled", "account_id", accountID,
+				"since_last_cycle", since.Round(time.Second), "idle", wrapper.client.UsingIdle())
+		} else {
+			m.logger.Info("email worker recovered", "account_id", accountID)
+		}
+		if m.onStall != nil {
+			m.onStall(accountID, stalled, since)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package formatter
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+
+	appmodels "github.com/mixelka/emailresend/pkg/models"
+)
+
+// callbackVersion is the current callback data encoding version.
+//
+// Layout (base64url, no padding):
+//
+//	[version][action len][action][uvarint message id][uvarint code index][arg...]
+const callbackVersion byte = 1
+
+// EncodeCallback encodes callback data to string
+func EncodeCallback(data appmodels.CallbackData) string {
+	buf := make([]byte, 0, 32)
+	buf = append(buf, callbackVersion, byte(len(data.Action)))
+	buf = append(buf, data.Action...)
+	buf = binary.AppendUvarint(buf, uint64(data.MessageID))
+	buf = binary.AppendUvarint(buf, uint64(data.CodeIndex))
+	buf = append(buf, data.Arg...)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+// DecodeCallback decodes callback data from string.
+// Legacy JSON payloads from messages sent before the compact encoding are still accepted.
+func DecodeCallback(data string) (appmodels.CallbackData, error) {
+	var cb appmodels.CallbackData
+
+	if strings.HasPrefix(data, "{") {
+		err := json.Unmarshal([]byte(data), &cb)
+		return cb, err
+	}
+
+	buf, err := base64.RawURLEncoding.DecodeString(data)
+	if err != nil {
+		return cb, fmt.Errorf("invalid callback data: %w", err)
+	}
+
+	if len(buf) < 2 {
+		return cb, fmt.Errorf("callback data too short")
+	}
+	if buf[0] != callbackVersion {
+		return cb, fmt.Errorf("unsupported callback version %d", buf[0])
+	}
+
+	actionLen := int(buf[1])
+	buf = buf[2:]
+	if len(buf) < actionLen {
+		return cb, fmt.Errorf("callback data truncated")
+	}
+	cb.Action = appmodels.CallbackAction(buf[:actionLen])
+	buf = buf[actionLen:]
+
+	msgID, n := binary.Uvarint(buf)
+	if n <= 0 {
+		return cb, fmt.Errorf("invalid message id in callback data")
+	}
+	cb.MessageID = int64(msgID)
+	buf = buf[n:]
+
+	codeIndex, n := binary.Uvarint(buf)
+	if n <= 0 || codeIndex > math.MaxInt32 {
+		return cb, fmt.Errorf("invalid code index in callback data")
+	}
+	cb.CodeIndex = int(codeIndex)
+
+	cb.Arg = string(buf[n:])
+	return cb, nil
+}
@@ -0,0 +1,48 @@
+package formatter
+
+import "github.com/mixelka/emailresend/internal/i18n"
+
+// English translations of the email layout and buttons, keyed by the Russian
+// originals
+func init() {
+	i18n.Register(i18n.English, map[string]string{
+		// keyboard
+		"📦 Отследить · %s":  "📦 Track · %s",
+		"📄 Полный текст":    "📄 Full text",
+		"✅ Подтвердить":     "✅ Confirm",
+		"🔐 Войти":           "🔐 Sign in",
+		"🔑 Сбросить пароль": "🔑 Reset password",
+		"🌐 Оригинал":        "🌐 Original",
+		"📥 Скачать .eml":    "📥 Download .eml",
+		"Прочитано":         "Read",
+		"Удалить":           "Delete",
+		"📦 В архив":         "📦 Archive",
+		"📁 В папку":         "📁 Move to…",
+		"🚫 Отписаться":      "🚫 Unsubscribe",
+		"◀️ Назад":          "◀️ Back",
+		"Вперёд ▶️":         "Next ▶️",
+		"Отмена":            "Cancel",
+
+		// profiles
+		"все поля письма, разделы и кнопки":                "all email fields, sections and buttons",
+		"отправитель и тема в одну строку, короткий текст": "sender and subject on one line, short text",
+		"только коды, без текста письма, если код найден":  "codes only, without the email text if a code is found",
+
+		// telegram
+		"От:":   "From:",
+		"Тема:": "Subject:",
+		"Дата:": "Date:",
+		"⚠️ Получено сервером:": "⚠️ Received by server:",
+		"Коды:": "Codes:",
+		"⚠️ Отправитель не подтверждён (DKIM/SPF): письмо может быть поддельным. Не вводите коды и не открывайте ссылки из него, если не ждали его.": "⚠️ The sender is not verified (DKIM/SPF): the email may be forged. Do not enter codes or open links from it unless you expected it.",
+		"⚠️ Этот код уже приходил в другом письме совсем недавно. Возможна атака с повторной отправкой — не вводите его, если не запрашивали.":       "⚠️ This code has just arrived in another email. It may be a replay attack — do not enter it unless you requested it.",
+		"Сообщение:":               "Message:",
+		"... (сообщение обрезано)": "... (message truncated)",
+		", последнее в %s":         ", last at %s",
+		"🛒 Заказ №":                "🛒 Order #",
+		"%.1f МБ":                  "%.1f MB",
+		"%.1f КБ":                  "%.1f KB",
+		"%d Б":                     "%d B",
+		"🗑 Удалено из «Входящих» в почтовом ящике": "🗑 Deleted from the mailbox inbox",
+	})
+}
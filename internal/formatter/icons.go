@@ -0,0 +1,115 @@
+package formatter
+
+import (
+	"hash/fnv"
+	"strings"
+
+	tgmodels "github.com/go-telegram/bot/models"
+
+	"github.com/mixelka/emailresend/pkg/models"
+)
+
+// Icon names that can be replaced with custom emoji per chat
+const (
+	IconEmail        = "email"        // Regular email
+	IconCode         = "code"         // Email with detected codes
+	IconNoReply      = "noreply"      // Automated sender (noreply@...)
+	IconConnected    = "connected"    // Account status: connected
+	IconReconnecting = "reconnecting" // Account status: reconnecting
+	IconDisconnected = "disconnected" // Account status: disconnected
+)
+
+// DefaultIcons are Unicode fallbacks for every icon name
+var DefaultIcons = map[string]string{
+	IconEmail:        "📧",
+	IconCode:         "🔑",
+	IconNoReply:      "🤖",
+	IconConnected:    "🟢",
+	IconReconnecting: "🟡",
+	IconDisconnected: "🔴",
+}
+
+// RenderIcon renders an icon in the given parse mode, as a custom emoji
+// entity if one is configured and as the Unicode fallback otherwise
+func RenderIcon(mode tgmodels.ParseMode, customEmoji map[string]string, name string) string {
+	fallback := DefaultIcons[name]
+	if id := customEmoji[name]; id != "" {
+		return markupFor(mode).Emoji(fallback, id)
+	}
+	return fallback
+}
+
+// SenderCategory returns the icon name describing an email's sender
+func SenderCategory(msg *models.EmailMessage, codes []models.DetectedCode) string {
+	if len(codes) > 0 {
+		return IconCode
+	}
+
+	local := strings.ToLower(msg.FromAddr)
+	if i := strings.Index(local, "@"); i >= 0 {
+		local = local[:i]
+	}
+	for _, marker := range []string{"noreply", "no-reply", "donotreply", "do-not-reply", "mailer-daemon", "notifications"} {
+		if strings.Contains(local, marker) {
+			return IconNoReply
+		}
+	}
+
+	return IconEmail
+}
+
+// domainEmoji is the palette of DomainEmoji
+var domainEmoji = []string{
+	"🍎", "🍊", "🍋", "🍉", "🍇", "🍓", "🫐", "🍒", "🥝", "🍍",
+	"🌵", "🌲", "🌻", "🌷", "🍄", "🌙", "⭐", "🔥", "🌊", "❄️",
+	"🐝", "🐢", "🐙", "🦊", "🐼", "🦉", "🐳", "🦋", "🐞", "🦜",
+	"🎈", "🎲", "🎸", "🚀", "⚓", "💎",
+}
+
+// DomainEmoji returns an emoji derived from the domain of an email address,
+// the same for every sender of the domain, so emails of one company are easy
+// to spot in a topic. Subdomains share the emoji of their registered domain.
+func DomainEmoji(address string) string {
+	_, domain, ok := strings.Cut(strings.ToLower(strings.TrimSpace(address)), "@")
+	if !ok || domain == "" {
+		return ""
+	}
+	labels := strings.Split(domain, ".")
+	keep := 2
+	if n := len(labels); n > 2 && len(labels[n-1]) == 2 && len(labels[n-2]) <= 3 {
+		// Second-level registries like co.uk or com.tr
+		keep = 3
+	}
+	if len(labels) > keep {
+		domain = strings.Join(labels[len(labels)-keep:], ".")
+	}
+
+	// FNV-1a keeps the emoji of a domain stable across releases
+	h := fnv.New32a()
+	h.Write([]byte(domain))
+	return domainEmoji[h.Sum32()%uint32(len(domainEmoji))]
+}
+
+// senderIcon renders the sender category icon of an email, followed by the
+// domain emoji if FormatOptions.DomainEmoji is set
+func senderIcon(msg *models.EmailMessage, codes []models.DetectedCode, opts FormatOptions) string {
+	icon := RenderIcon(opts.ParseMode, opts.CustomEmoji, SenderCategory(msg, codes))
+	if opts.DomainEmoji {
+		if emoji := DomainEmoji(msg.FromAddr); emoji != "" {
+			icon += emoji
+		}
+	}
+	return icon
+}
+
+// authBadge returns the mark of the DKIM/SPF verdict on an email's sender,
+// empty if the email carried nothing to check
+func authBadge(msg *models.EmailMessage) string {
+	switch msg.Auth {
+	case models.AuthPass:
+		return "✅"
+	case models.AuthFail:
+		return "⚠️"
+	}
+	return ""
+}
@@ -1,20 +1,56 @@
 package formatter
 
 import (
-	"encoding/json"
 	"fmt"
+	"strconv"
 
 	"github.com/go-telegram/bot/models"
 
+	"github.com/mixelka/emailresend/internal/i18n"
 	appmodels "github.com/mixelka/emailresend/pkg/models"
 )
 
-// BuildEmailKeyboard creates an inline keyboard for an email message
-func BuildEmailKeyboard(msgID int64, codes []appmodels.DetectedCode, isRead bool) *models.InlineKeyboardMarkup {
+// maxAttachmentButtons limits the number of attachment buttons per email
+const maxAttachmentButtons = 5
+
+// maxFolderButtons limits the folders offered by the move keyboard
+const maxFolderButtons = 30
+
+// actionLinkLabels are the button texts of action links by kind
+var actionLinkLabels = map[string]string{
+	appmodels.ActionConfirm: "✅ Подтвердить",
+	appmodels.ActionLogin:   "🔐 Войти",
+	appmodels.ActionReset:   "🔑 Сбросить пароль",
+}
+
+// EmailKeyboard describes the inline keyboard of a forwarded email
+type EmailKeyboard struct {
+	MsgID       int64
+	Codes       []appmodels.DetectedCode
+	Attachments []appmodels.Attachment
+	Tracking    []appmodels.TrackingNumber
+	Actions     []appmodels.ActionLink
+	IsRead      bool
+	HasHTML     bool                   // Email has an HTML body that can be opened as a file ("Original")
+	Truncated   bool                   // The body was cut to fit the message
+	Folder      string                 // IMAP folder the email was moved to; buttons acting on the inbox copy are hidden
+	Deleted     bool                   // The email was deleted from INBOX by another mail client; hides the same buttons as Folder
+	Unsubscribe *appmodels.Unsubscribe // List-Unsubscribe of the email, adds the unsubscribe button
+	Profile     string                 // Formatting profile, decides which button groups are shown
+	Language    string                 // Language of button labels (i18n code)
+}
+
+// BuildEmailKeyboard creates an inline keyboard for an email message.
+// Returns nil if the profile leaves no buttons.
+func BuildEmailKeyboard(k EmailKeyboard) *models.InlineKeyboardMarkup {
 	var rows [][]models.InlineKeyboardButton
+	p := GetProfile(k.Profile)
+	msgID, codes := k.MsgID, k.Codes
+	inInbox := k.Folder == "" && !k.Deleted
+	tr := func(msg string) string { return i18n.Translate(k.Language, msg) }
 
 	// Code buttons (copy on click)
-	if len(codes) > 0 {
+	if p.CodeButtons && len(codes) > 0 {
 		var codeButtons []models.InlineKeyboardButton
 		for i, code := range codes {
 			data := EncodeCallback(appmodels.CallbackData{
@@ -37,12 +73,109 @@ func BuildEmailKeyboard(msgID int64, codes []appmodels.DetectedCode, isRead bool
 		}
 	}
 
+	// Attachment buttons (fetch from IMAP on click)
+	attachments := k.Attachments
+	if !p.AttachmentButtons || !inInbox {
+		attachments = nil
+	}
+	for i, att := range attachments {
+		if i == maxAttachmentButtons {
+			break
+		}
+		rows = append(rows, []models.InlineKeyboardButton{{
+			Text: fmt.Sprintf("📎 %s (%s)", att.Filename, FormatSize(k.Language, att.Size)),
+			CallbackData: EncodeCallback(appmodels.CallbackData{
+				Action:    appmodels.CallbackFetchAtt,
+				MessageID: msgID,
+				CodeIndex: i,
+			}),
+		}})
+	}
+
+	// Tracking buttons (open the carrier's page)
+	tracking := k.Tracking
+	if !p.TrackingButtons {
+		tracking = nil
+	}
+	for _, t := range tracking {
+		rows = append(rows, []models.InlineKeyboardButton{{
+			Text: i18n.Translatef(k.Language, "📦 Отследить · %s", t.Carrier),
+			URL:  t.URL,
+		}})
+	}
+
+	// Action link buttons (confirm, sign in, reset password)
+	if p.LinkButtons {
+		for _, link := range k.Actions {
+			rows = append(rows, []models.InlineKeyboardButton{{
+				Text: tr(actionLinkLabels[link.Kind]),
+				URL:  link.URL,
+			}})
+		}
+	}
+
+	// Unsubscribe button: the bot unsubscribes after a confirmation, pages
+	// without one-click support are opened by the user
+	if p.ActionButtons && k.Unsubscribe != nil {
+		button := models.InlineKeyboardButton{
+			Text:         tr("🚫 Отписаться"),
+			CallbackData: EncodeCallback(appmodels.CallbackData{Action: appmodels.CallbackUnsub, MessageID: msgID}),
+		}
+		if !k.Unsubscribe.OneClick && k.Unsubscribe.Mailto == "" {
+			button = models.InlineKeyboardButton{Text: tr("🚫 Отписаться"), URL: k.Unsubscribe.URL}
+		}
+		rows = append(rows, []models.InlineKeyboardButton{button})
+	}
+
+	// Full text button (sends the body cut by the formatter)
+	if k.Truncated {
+		rows = append(rows, []models.InlineKeyboardButton{{
+			Text: tr("📄 Полный текст"),
+			CallbackData: EncodeCallback(appmodels.CallbackData{
+				Action:    appmodels.CallbackFullText,
+				MessageID: msgID,
+			}),
+		}})
+	}
+
 	// Action buttons
+	if !p.ActionButtons {
+		if len(rows) == 0 {
+			return nil
+		}
+		return &models.InlineKeyboardMarkup{InlineKeyboard: rows}
+	}
+
+	// File buttons: the HTML version and the raw message as .eml, available
+	// wherever the email was moved
+	fileRow := []models.InlineKeyboardButton{}
+	if k.HasHTML {
+		fileRow = append(fileRow, models.InlineKeyboardButton{
+			Text: tr("🌐 Оригинал"),
+			CallbackData: EncodeCallback(appmodels.CallbackData{
+				Action:    appmodels.CallbackOpenHTML,
+				MessageID: msgID,
+			}),
+		})
+	}
+	fileRow = append(fileRow, models.InlineKeyboardButton{
+		Text: tr("📥 Скачать .eml"),
+		CallbackData: EncodeCallback(appmodels.CallbackData{
+			Action:    appmodels.CallbackEML,
+			MessageID: msgID,
+		}),
+	})
+	rows = append(rows, fileRow)
+
+	if !inInbox {
+		return &models.InlineKeyboardMarkup{InlineKeyboard: rows}
+	}
+
 	actionRow := []models.InlineKeyboardButton{}
 
-	if !isRead {
+	if !k.IsRead {
 		actionRow = append(actionRow, models.InlineKeyboardButton{
-			Text: "Прочитано",
+			Text: tr("Прочитано"),
 			CallbackData: EncodeCallback(appmodels.CallbackData{
 				Action:    appmodels.CallbackMarkRead,
 				MessageID: msgID,
@@ -51,7 +184,7 @@ func BuildEmailKeyboard(msgID int64, codes []appmodels.DetectedCode, isRead bool
 	}
 
 	actionRow = append(actionRow, models.InlineKeyboardButton{
-		Text: "Удалить",
+		Text: tr("Удалить"),
 		CallbackData: EncodeCallback(appmodels.CallbackData{
 			Action:    appmodels.CallbackDelete,
 			MessageID: msgID,
@@ -60,20 +193,123 @@ func BuildEmailKeyboard(msgID int64, codes []appmodels.DetectedCode, isRead bool
 
 	rows = append(rows, actionRow)
 
+	// Move buttons: Arg "a" moves to the archive folder, no Arg opens the
+	// folder list
+	rows = append(rows, []models.InlineKeyboardButton{
+		{
+			Text: tr("📦 В архив"),
+			CallbackData: EncodeCallback(appmodels.CallbackData{
+				Action:    appmodels.CallbackMove,
+				MessageID: msgID,
+				Arg:       appmodels.MoveArchive,
+			}),
+		},
+		{
+			Text: tr("📁 В папку"),
+			CallbackData: EncodeCallback(appmodels.CallbackData{
+				Action:    appmodels.CallbackMove,
+				MessageID: msgID,
+			}),
+		},
+	})
+
 	return &models.InlineKeyboardMarkup{
 		InlineKeyboard: rows,
 	}
 }
 
-// EncodeCallback encodes callback data to string
-func EncodeCallback(data appmodels.CallbackData) string {
-	b, _ := json.Marshal(data)
-	return string(b)
+// BuildFolderKeyboard creates the folder list of the move keyboard. A button
+// carries the index of its folder in the callback argument, the cancel
+// button appmodels.MoveCancel. The keyboard replaces the email's own, so
+// whether its body was truncated is kept in the code index (1 = truncated).
+func BuildFolderKeyboard(msgID int64, folders []string, truncated bool, language string) *models.InlineKeyboardMarkup {
+	flag := 0
+	if truncated {
+		flag = 1
+	}
+
+	var rows [][]models.InlineKeyboardButton
+	var row []models.InlineKeyboardButton
+	for i, folder := range folders {
+		if i == maxFolderButtons {
+			break
+		}
+		row = append(row, models.InlineKeyboardButton{
+			Text: "📁 " + folder,
+			CallbackData: EncodeCallback(appmodels.CallbackData{
+				Action:    appmodels.CallbackMove,
+				MessageID: msgID,
+				CodeIndex: flag,
+				Arg:       strconv.Itoa(i),
+			}),
+		})
+		if len(row) == 2 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+
+	rows = append(rows, []models.InlineKeyboardButton{{
+		Text: i18n.Translate(language, "Отмена"),
+		CallbackData: EncodeCallback(appmodels.CallbackData{
+			Action:    appmodels.CallbackMove,
+			MessageID: msgID,
+			CodeIndex: flag,
+			Arg:       appmodels.MoveCancel,
+		}),
+	}})
+	return &models.InlineKeyboardMarkup{InlineKeyboard: rows}
 }
 
-// DecodeCallback decodes callback data from string
-func DecodeCallback(data string) (appmodels.CallbackData, error) {
-	var cb appmodels.CallbackData
-	err := json.Unmarshal([]byte(data), &cb)
-	return cb, err
+// BuildPageKeyboard creates previous/next buttons for a paginated list.
+// The target page is passed in the callback argument.
+func BuildPageKeyboard(action appmodels.CallbackAction, msgID int64, page, pages int) *models.InlineKeyboardMarkup {
+	var row []models.InlineKeyboardButton
+
+	if page > 0 {
+		row = append(row, models.InlineKeyboardButton{
+			Text: "◀️ Назад",
+			CallbackData: EncodeCallback(appmodels.CallbackData{
+				Action:    action,
+				MessageID: msgID,
+				Arg:       strconv.Itoa(page - 1),
+			}),
+		})
+	}
+
+	if page < pages-1 {
+		row = append(row, models.InlineKeyboardButton{
+			Text: "Вперёд ▶️",
+			CallbackData: EncodeCallback(appmodels.CallbackData{
+				Action:    action,
+				MessageID: msgID,
+				Arg:       strconv.Itoa(page + 1),
+			}),
+		})
+	}
+
+	return &models.InlineKeyboardMarkup{
+		InlineKeyboard: [][]models.InlineKeyboardButton{row},
+	}
+}
+
+// BuildConfirmKeyboard creates a confirm/cancel keyboard for an action on the
+// record id (0 if none). The confirm button carries Arg "yes", the cancel
+// button "no".
+func BuildConfirmKeyboard(action appmodels.CallbackAction, id int64, confirmText string) *models.InlineKeyboardMarkup {
+	return &models.InlineKeyboardMarkup{
+		InlineKeyboard: [][]models.InlineKeyboardButton{{
+			{
+				Text:         confirmText,
+				CallbackData: EncodeCallback(appmodels.CallbackData{Action: action, MessageID: id, Arg: "yes"}),
+			},
+			{
+				Text:         "Отмена",
+				CallbackData: EncodeCallback(appmodels.CallbackData{Action: action, MessageID: id, Arg: "no"}),
+			},
+		}},
+	}
 }
@@ -0,0 +1,72 @@
+package formatter
+
+import (
+	"strings"
+
+	"github.com/go-telegram/bot/models"
+)
+
+// markup renders text fragments for a Telegram parse mode.
+// Bold, Italic, Strike and Link expect already escaped text, Code escapes raw text itself.
+type markup interface {
+	Escape(s string) string
+	Bold(s string) string
+	Italic(s string) string
+	Strike(s string) string
+	Code(s string) string
+	Emoji(fallback, customEmojiID string) string
+	Link(text, url string) string
+}
+
+// markupFor returns the markup for a parse mode (HTML by default)
+func markupFor(mode models.ParseMode) markup {
+	if mode == models.ParseModeMarkdown {
+		return markdownV2Markup{}
+	}
+	return htmlMarkup{}
+}
+
+// htmlMarkup renders Telegram HTML
+type htmlMarkup struct{}
+
+var htmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func (htmlMarkup) Escape(s string) string { return htmlEscaper.Replace(s) }
+func (htmlMarkup) Bold(s string) string   { return "<b>" + s + "</b>" }
+func (htmlMarkup) Italic(s string) string { return "<i>" + s + "</i>" }
+func (htmlMarkup) Strike(s string) string { return "<s>" + s + "</s>" }
+func (htmlMarkup) Code(s string) string   { return "<code>" + htmlEscaper.Replace(s) + "</code>" }
+func (htmlMarkup) Emoji(fallback, id string) string {
+	return `<tg-emoji emoji-id="` + htmlEscaper.Replace(id) + `">` + fallback + "</tg-emoji>"
+}
+
+func (htmlMarkup) Link(text, url string) string {
+	return `<a href="` + strings.ReplaceAll(htmlEscaper.Replace(url), `"`, "&quot;") + `">` + text + "</a>"
+}
+
+// markdownV2Markup renders Telegram MarkdownV2
+type markdownV2Markup struct{}
+
+var (
+	markdownV2Escaper = strings.NewReplacer(
+		`\`, `\\`, "_", `\_`, "*", `\*`, "[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`,
+		"~", `\~`, "`", "\\`", ">", `\>`, "#", `\#`, "+", `\+`, "-", `\-`, "=", `\=`,
+		"|", `\|`, "{", `\{`, "}", `\}`, ".", `\.`, "!", `\!`,
+	)
+	markdownV2CodeEscaper = strings.NewReplacer(`\`, `\\`, "`", "\\`")
+	markdownV2LinkEscaper = strings.NewReplacer(`\`, `\\`, ")", `\)`)
+)
+
+func (markdownV2Markup) Escape(s string) string { return markdownV2Escaper.Replace(s) }
+func (markdownV2Markup) Bold(s string) string   { return "*" + s + "*" }
+func (markdownV2Markup) Italic(s string) string { return "_" + s + "_" }
+func (markdownV2Markup) Strike(s string) string { return "~" + s + "~" }
+func (markdownV2Markup) Code(s string) string {
+	return "`" + markdownV2CodeEscaper.Replace(s) + "`"
+}
+func (markdownV2Markup) Emoji(fallback, id string) string {
+	return "![" + fallback + "](tg://emoji?id=" + id + ")"
+}
+func (markdownV2Markup) Link(text, url string) string {
+	return "[" + text + "](" + markdownV2LinkEscaper.Replace(url) + ")"
+}
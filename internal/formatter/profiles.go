@@ -0,0 +1,94 @@
+package formatter
+
+// Built-in formatting profiles selectable per topic
+const (
+	ProfileDetailed = "detailed"
+	ProfileCompact  = "compact"
+	ProfileMinimal  = "minimal"
+)
+
+// Profile controls which parts of an email the formatter and keyboard produce
+type Profile struct {
+	Name        string
+	Description string
+
+	InlineHeader      bool   // Sender, subject and time on one line instead of labeled lines
+	ShowDate          bool   // Include the Date header
+	Separator         string // Written after the header and each section
+	InlineCodes       bool   // Codes on one line without a label
+	ShowExtras        bool   // Order card, extracted fields and links
+	BodyLabel         bool   // "Сообщение:" label before the body
+	BodyLimit         int    // Max body characters (0 = as much as fits)
+	HideBodyWithCodes bool   // Skip the body when codes were found
+
+	CodeButtons       bool
+	AttachmentButtons bool
+	TrackingButtons   bool
+	LinkButtons       bool // Confirmation, sign-in and password reset links
+	ActionButtons     bool // Mark read and delete
+}
+
+var profiles = []Profile{
+	{
+		Name:              ProfileDetailed,
+		Description:       "все поля письма, разделы и кнопки",
+		ShowDate:          true,
+		Separator:         "\n",
+		ShowExtras:        true,
+		BodyLabel:         true,
+		CodeButtons:       true,
+		AttachmentButtons: true,
+		TrackingButtons:   true,
+		LinkButtons:       true,
+		ActionButtons:     true,
+	},
+	{
+		Name:              ProfileCompact,
+		Description:       "отправитель и тема в одну строку, короткий текст",
+		InlineHeader:      true,
+		ShowDate:          true,
+		InlineCodes:       true,
+		ShowExtras:        true,
+		BodyLimit:         700,
+		CodeButtons:       true,
+		AttachmentButtons: true,
+		TrackingButtons:   true,
+		LinkButtons:       true,
+		ActionButtons:     true,
+	},
+	{
+		Name:              ProfileMinimal,
+		Description:       "только коды, без текста письма, если код найден",
+		InlineHeader:      true,
+		InlineCodes:       true,
+		BodyLimit:         200,
+		HideBodyWithCodes: true,
+		CodeButtons:       true,
+		LinkButtons:       true,
+	},
+}
+
+// Profiles returns the built-in profiles
+func Profiles() []Profile {
+	return profiles
+}
+
+// GetProfile returns the profile by name, the detailed one for unknown names
+func GetProfile(name string) Profile {
+	for _, p := range profiles {
+		if p.Name == name {
+			return p
+		}
+	}
+	return profiles[0]
+}
+
+// IsProfile reports whether a built-in profile has the name
+func IsProfile(name string) bool {
+	for _, p := range profiles {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
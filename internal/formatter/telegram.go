@@ -2,11 +2,26 @@ package formatter
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
 
+	tgmodels "github.com/go-telegram/bot/models"
+
+	"github.com/mixelka/emailresend/internal/i18n"
 	"github.com/mixelka/emailresend/pkg/models"
 )
 
+const (
+	// maxFutureDateSkew is how far the Date header may be ahead of the
+	// server receive time before it is flagged
+	maxFutureDateSkew = 15 * time.Minute
+	// maxPastDateSkew is how far the Date header may lag behind the server
+	// receive time (slow relays, retries) before it is flagged
+	maxPastDateSkew = 24 * time.Hour
+)
+
 // TelegramFormatter formats emails for Telegram
 type TelegramFormatter struct {
 	maxLength int
@@ -19,56 +34,246 @@ func NewTelegramFormatter() *TelegramFormatter {
 	}
 }
 
-// FormatEmail formats an email message for Telegram
-func (f *TelegramFormatter) FormatEmail(msg *models.EmailMessage, codes []models.DetectedCode) string {
+// FormatOptions per-chat options for formatting an email
+type FormatOptions struct {
+	ParseMode   tgmodels.ParseMode // Defaults to HTML
+	CustomEmoji map[string]string  // Icon name -> custom emoji ID
+	CodeReused  bool               // A detected code recently appeared in another email of the chat
+	Profile     string             // Formatting profile name, detailed by default
+	Template    string             // Message template replacing the profile's layout (empty = none), see ParseTemplate
+	Deleted     bool               // The email was deleted from INBOX by another mail client; the text is struck through
+	DomainEmoji bool               // Follow the sender icon with an emoji of the sender's domain, see DomainEmoji
+	Language    string             // Language of labels (i18n code), Russian by default
+}
+
+// FormatEmail formats an email message for Telegram. Reports whether the
+// body was cut to fit the message.
+func (f *TelegramFormatter) FormatEmail(msg *models.EmailMessage, codes []models.DetectedCode, opts FormatOptions) (string, bool) {
+	text, truncated := f.formatEmail(msg, codes, opts)
+	if opts.Deleted {
+		// The note fits in the room maxLength leaves below Telegram's limit
+		m := markupFor(opts.ParseMode)
+		text = m.Italic(m.Escape(i18n.Translate(opts.Language, "🗑 Удалено из «Входящих» в почтовом ящике"))) + "\n" + m.Strike(text)
+	}
+	return text, truncated
+}
+
+// formatEmail formats an email with its template or profile
+func (f *TelegramFormatter) formatEmail(msg *models.EmailMessage, codes []models.DetectedCode, opts FormatOptions) (string, bool) {
+	if opts.Template != "" {
+		// Templates are validated when saved; a broken one falls back to the profile
+		if t, err := ParseTemplate(opts.Template); err == nil {
+			return f.formatTemplate(t, msg, codes, opts)
+		}
+	}
+
+	m := markupFor(opts.ParseMode)
+	p := GetProfile(opts.Profile)
+	tr := func(msg string) string { return i18n.Translate(opts.Language, msg) }
 	var sb strings.Builder
 
 	// Header
-	from := msg.FromAddr
+	from := m.Escape(msg.FromAddr)
 	if msg.FromName != "" {
-		from = fmt.Sprintf("%s &lt;%s&gt;", f.escapeHTML(msg.FromName), f.escapeHTML(msg.FromAddr))
-	} else {
-		from = f.escapeHTML(from)
+		from = m.Escape(fmt.Sprintf("%s <%s>", msg.FromName, msg.FromAddr))
 	}
 
-	sb.WriteString(fmt.Sprintf("<b>От:</b> %s\n", from))
-	sb.WriteString(fmt.Sprintf("<b>Тема:</b> %s\n", f.escapeHTML(msg.Subject)))
-	sb.WriteString(fmt.Sprintf("<b>Дата:</b> %s\n", msg.ReceivedAt.Format("02.01.2006 15:04")))
-	sb.WriteString("\n")
+	icon := senderIcon(msg, codes, opts)
+	badge := ""
+	if mark := authBadge(msg); mark != "" {
+		badge = " " + mark
+	}
+	if p.InlineHeader {
+		sender := msg.FromName
+		if sender == "" {
+			sender = msg.FromAddr
+		}
+		line := fmt.Sprintf("%s %s%s %s %s", icon, m.Bold(m.Escape(sender)), badge, m.Escape("·"), m.Escape(msg.Subject))
+		if p.ShowDate {
+			line += m.Escape(" · " + msg.ReceivedAt.Format("15:04"))
+		}
+		sb.WriteString(line + "\n")
+	} else {
+		sb.WriteString(fmt.Sprintf("%s %s %s%s\n", icon, m.Bold(m.Escape(tr("От:"))), from, badge))
+		sb.WriteString(fmt.Sprintf("%s %s\n", m.Bold(m.Escape(tr("Тема:"))), m.Escape(msg.Subject)))
+		if p.ShowDate {
+			sb.WriteString(fmt.Sprintf("%s %s\n", m.Bold(m.Escape(tr("Дата:"))), m.Escape(msg.ReceivedAt.Format("02.01.2006 15:04"))))
+		}
+	}
+	if suspiciousDate(msg) {
+		sb.WriteString(fmt.Sprintf("%s %s\n", m.Bold(m.Escape(tr("⚠️ Получено сервером:"))), m.Escape(msg.InternalDate.Format("02.01.2006 15:04"))))
+	}
+	if msg.Auth == models.AuthFail {
+		sb.WriteString(m.Bold(m.Escape(tr(authWarning))) + "\n")
+	}
+	sb.WriteString(p.Separator)
 
 	// Detected codes section
 	if len(codes) > 0 {
-		sb.WriteString("<b>Коды:</b>\n")
+		if p.InlineCodes {
+			sb.WriteString("🔑 ")
+		} else {
+			sb.WriteString(m.Bold(m.Escape(tr("Коды:"))) + "\n")
+		}
 		for _, code := range codes {
-			sb.WriteString(fmt.Sprintf("<code>%s</code> ", code.Value))
+			sb.WriteString(m.Code(code.Value) + " ")
 		}
-		sb.WriteString("\n\n")
+		sb.WriteString("\n")
+		if opts.CodeReused {
+			sb.WriteString(m.Bold(m.Escape(tr("⚠️ Этот код уже приходил в другом письме совсем недавно. Возможна атака с повторной отправкой — не вводите его, если не запрашивали."))) + "\n")
+		}
+		sb.WriteString(p.Separator)
+	}
+
+	// Structured data from extractors
+	extraction := msg.ExtractedData()
+	if p.ShowExtras && extraction != nil && extraction.Order != nil {
+		sb.WriteString(FormatOrderCard(opts.ParseMode, opts.Language, extraction.Order) + "\n" + p.Separator)
+	}
+	if p.ShowExtras && extraction != nil && (len(extraction.Fields) > 0 || len(extraction.Links) > 0) {
+		for _, field := range extraction.Fields {
+			sb.WriteString(fmt.Sprintf("%s %s\n", m.Bold(m.Escape(field.Name+":")), m.Escape(field.Value)))
+		}
+		for _, link := range extraction.Links {
+			sb.WriteString("🔗 " + m.Link(m.Escape(link.Title), link.URL) + "\n")
+		}
+		sb.WriteString(p.Separator)
 	}
 
 	// Body
-	sb.WriteString("<b>Сообщение:</b>\n")
-	body := f.truncate(msg.BodyText, f.maxLength-sb.Len()-50)
-	sb.WriteString(f.escapeHTML(body))
+	if p.HideBodyWithCodes && len(codes) > 0 {
+		return strings.TrimRight(sb.String(), "\n"), false
+	}
+	if p.BodyLabel {
+		sb.WriteString(m.Bold(m.Escape(tr("Сообщение:"))) + "\n")
+	}
+	limit := f.maxLength - sb.Len() - 50
+	if p.BodyLimit > 0 && p.BodyLimit < limit {
+		limit = p.BodyLimit
+	}
+	body, truncated := f.truncate(msg.BodyText, limit)
+	sb.WriteString(m.Escape(body))
+	if truncated {
+		sb.WriteString("\n\n" + m.Italic(m.Escape(tr("... (сообщение обрезано)"))))
+	}
+
+	return sb.String(), truncated
+}
+
+// FormatCollapseHeader formats the counter line of a collapsed message
+func FormatCollapseHeader(mode tgmodels.ParseMode, lang, subject string, count int, last time.Time) string {
+	m := markupFor(mode)
+	title := fmt.Sprintf("⚠️ %s ×%d", subject, count)
+	return fmt.Sprintf("%s%s", m.Bold(m.Escape(title)), m.Escape(i18n.Translatef(lang, ", последнее в %s", last.Format("15:04"))))
+}
 
+// FormatOrderCard formats the one-line purchase card of a commerce email
+func FormatOrderCard(mode tgmodels.ParseMode, lang string, order *models.Order) string {
+	m := markupFor(mode)
+	parts := []string{m.Bold(m.Escape(i18n.Translate(lang, "🛒 Заказ №"))) + m.Code(order.Number)}
+	if order.Amount > 0 {
+		parts = append(parts, m.Escape(FormatMoney(order.Amount, order.Currency)))
+	}
+	if order.Merchant != "" {
+		parts = append(parts, m.Escape(order.Merchant))
+	}
+	return strings.Join(parts, m.Escape(" · "))
+}
+
+// FormatMoney formats an amount in minor units, e.g. "1 234,56 ₽"
+func FormatMoney(amount int64, currency string) string {
+	whole := strconv.FormatInt(amount/100, 10)
+	var sb strings.Builder
+	for i, r := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			sb.WriteRune('\u00A0')
+		}
+		sb.WriteRune(r)
+	}
+	if cents := amount % 100; cents != 0 {
+		sb.WriteString(fmt.Sprintf(",%02d", cents))
+	}
+
+	switch currency {
+	case "RUB":
+		sb.WriteString("\u00A0₽")
+	case "USD":
+		sb.WriteString("\u00A0$")
+	case "EUR":
+		sb.WriteString("\u00A0€")
+	case "":
+	default:
+		sb.WriteString("\u00A0" + currency)
+	}
 	return sb.String()
 }
 
-// escapeHTML escapes HTML special characters for Telegram
-func (f *TelegramFormatter) escapeHTML(s string) string {
-	s = strings.ReplaceAll(s, "&", "&amp;")
-	s = strings.ReplaceAll(s, "<", "&lt;")
-	s = strings.ReplaceAll(s, ">", "&gt;")
-	return s
+// authWarning is shown on emails whose sender failed the DKIM/SPF checks
+const authWarning = "⚠️ Отправитель не подтверждён (DKIM/SPF): письмо может быть поддельным. Не вводите коды и не открывайте ссылки из него, если не ждали его."
+
+// suspiciousDate reports whether the sender-controlled Date header differs
+// from the server receive time more than delivery delays can explain
+func suspiciousDate(msg *models.EmailMessage) bool {
+	if msg.InternalDate.IsZero() || msg.ReceivedAt.IsZero() {
+		return false
+	}
+	skew := msg.ReceivedAt.Sub(msg.InternalDate)
+	return skew > maxFutureDateSkew || skew < -maxPastDateSkew
+}
+
+// FormatSize formats a size in bytes for display
+func FormatSize(lang string, bytes int64) string {
+	switch {
+	case bytes >= 1<<20:
+		return i18n.Translatef(lang, "%.1f МБ", float64(bytes)/(1<<20))
+	case bytes >= 1<<10:
+		return i18n.Translatef(lang, "%.1f КБ", float64(bytes)/(1<<10))
+	default:
+		return i18n.Translatef(lang, "%d Б", bytes)
+	}
 }
 
 // truncate truncates text to maxLen characters
-func (f *TelegramFormatter) truncate(s string, maxLen int) string {
+func (f *TelegramFormatter) truncate(s string, maxLen int) (string, bool) {
 	if maxLen <= 0 {
 		maxLen = 100
 	}
 	runes := []rune(s)
 	if len(runes) <= maxLen {
-		return s
+		return s, false
+	}
+	return string(runes[:maxLen]), true
+}
+
+// SplitText splits text into chunks of at most maxLen characters, breaking
+// at the last line break or space of a chunk where possible
+func SplitText(s string, maxLen int) []string {
+	var chunks []string
+	runes := []rune(strings.TrimSpace(s))
+	for len(runes) > maxLen {
+		cut := maxLen
+		if i := lastIndexRune(runes[:maxLen], '\n'); i > maxLen/2 {
+			cut = i + 1
+		} else if i := lastIndexRune(runes[:maxLen], ' '); i > maxLen/2 {
+			cut = i + 1
+		}
+		if chunk := strings.TrimSpace(string(runes[:cut])); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		runes = []rune(strings.TrimLeftFunc(string(runes[cut:]), unicode.IsSpace))
+	}
+	if len(runes) > 0 {
+		chunks = append(chunks, string(runes))
+	}
+	return chunks
+}
+
+// lastIndexRune returns the index of the last r in runes, or -1
+func lastIndexRune(runes []rune, r rune) int {
+	for i := len(runes) - 1; i >= 0; i-- {
+		if runes[i] == r {
+			return i
+		}
 	}
-	return string(runes[:maxLen]) + "\n\n<i>... (сообщение обрезано)</i>"
+	return -1
 }
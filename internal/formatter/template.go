@@ -0,0 +1,301 @@
+package formatter
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mixelka/emailresend/internal/i18n"
+	"github.com/mixelka/emailresend/pkg/models"
+)
+
+// MaxTemplateLength limits the size of a message template
+const MaxTemplateLength = 1000
+
+// Template placeholders
+const (
+	FieldIcon        = "icon"        // Sender category icon
+	FieldAuth        = "auth"        // ✅ or ⚠️ by the DKIM/SPF checks of the sender
+	FieldFrom        = "from"        // "Name <address>"
+	FieldSender      = "sender"      // Name, the address if there is none
+	FieldEmail       = "email"       // Sender address
+	FieldSubject     = "subject"     // Subject
+	FieldDate        = "date"        // Date header, {date:DD.MM HH:mm} for another format
+	FieldCodes       = "codes"       // Detected codes
+	FieldAttachments = "attachments" // Attachment file names
+	FieldExtras      = "extras"      // Order card, extracted fields and links
+	FieldBody        = "body"        // Body, {body:300} for a preview of 300 characters
+)
+
+// defaultDateLayout is the layout of {date}, as in the detailed profile
+const defaultDateLayout = "02.01.2006 15:04"
+
+// dateLayout converts the DD.MM.YYYY HH:mm notation of templates to a Go layout
+var dateLayout = strings.NewReplacer("YYYY", "2006", "YY", "06", "MM", "01", "DD", "02", "HH", "15", "mm", "04", "ss", "05")
+
+// Template is a parsed message template: a line per template line, each a
+// sequence of literal text, placeholders and bold toggles (**)
+type Template struct {
+	lines [][]templateToken
+}
+
+type templateToken struct {
+	text  string // Literal text
+	field string // Placeholder name, empty for literal text
+	arg   string // Placeholder argument after the colon
+	bold  bool   // Toggles bold
+}
+
+// ParseTemplate parses a message template. Placeholders are written in braces,
+// e.g. {subject} or {date:DD.MM HH:mm}; ** toggles bold and {{ is a literal
+// brace. Lines whose placeholders are all empty are left out.
+func ParseTemplate(s string) (*Template, error) {
+	if len([]rune(s)) > MaxTemplateLength {
+		return nil, fmt.Errorf("template is longer than %d characters", MaxTemplateLength)
+	}
+
+	t := &Template{}
+	bodies := 0
+	for _, line := range strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n") {
+		var tokens []templateToken
+		var text strings.Builder
+		flush := func() {
+			if text.Len() > 0 {
+				tokens = append(tokens, templateToken{text: text.String()})
+				text.Reset()
+			}
+		}
+
+		for i := 0; i < len(line); i++ {
+			switch {
+			case strings.HasPrefix(line[i:], "{{"):
+				text.WriteByte('{')
+				i++
+			case strings.HasPrefix(line[i:], "}}"):
+				text.WriteByte('}')
+				i++
+			case strings.HasPrefix(line[i:], "**"):
+				flush()
+				tokens = append(tokens, templateToken{bold: true})
+				i++
+			case line[i] == '{':
+				end := strings.IndexByte(line[i:], '}')
+				if end < 0 {
+					return nil, fmt.Errorf("unclosed { in %q", line[i:])
+				}
+				token, err := parsePlaceholder(line[i+1 : i+end])
+				if err != nil {
+					return nil, err
+				}
+				if token.field == FieldBody {
+					bodies++
+				}
+				flush()
+				tokens = append(tokens, token)
+				i += end
+			default:
+				text.WriteByte(line[i])
+			}
+		}
+		flush()
+		t.lines = append(t.lines, tokens)
+	}
+
+	if bodies > 1 {
+		return nil, fmt.Errorf("{%s} can be used only once", FieldBody)
+	}
+	return t, nil
+}
+
+// parsePlaceholder parses the inside of {name} or {name:arg}
+func parsePlaceholder(s string) (templateToken, error) {
+	name, arg, hasArg := strings.Cut(s, ":")
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	switch name {
+	case FieldDate:
+		if hasArg && strings.TrimSpace(arg) == "" {
+			return templateToken{}, fmt.Errorf("empty date format in {%s}", s)
+		}
+	case FieldBody:
+		if hasArg {
+			n, err := strconv.Atoi(arg)
+			if err != nil || n <= 0 {
+				return templateToken{}, fmt.Errorf("{%s:N} needs a positive number of characters", FieldBody)
+			}
+		}
+	case FieldIcon, FieldAuth, FieldFrom, FieldSender, FieldEmail, FieldSubject, FieldCodes, FieldAttachments, FieldExtras:
+		if hasArg {
+			return templateToken{}, fmt.Errorf("{%s} takes no argument", name)
+		}
+	default:
+		return templateToken{}, fmt.Errorf("unknown placeholder {%s}", s)
+	}
+	return templateToken{field: name, arg: arg}, nil
+}
+
+// bodyMarker stands for the body while the rest of a template is rendered,
+// so the body can take the remaining space of the message
+const bodyMarker = "\x00body\x00"
+
+// formatTemplate formats an email with a message template
+func (f *TelegramFormatter) formatTemplate(t *Template, msg *models.EmailMessage, codes []models.DetectedCode, opts FormatOptions) (string, bool) {
+	m := markupFor(opts.ParseMode)
+	tr := func(s string) string { return i18n.Translate(opts.Language, s) }
+	var sb strings.Builder
+
+	// Warnings come first whatever the template shows
+	if suspiciousDate(msg) {
+		sb.WriteString(fmt.Sprintf("%s %s\n", m.Bold(m.Escape(tr("⚠️ Получено сервером:"))), m.Escape(msg.InternalDate.Format(defaultDateLayout))))
+	}
+	if opts.CodeReused && len(codes) > 0 {
+		sb.WriteString(m.Bold(m.Escape(tr("⚠️ Этот код уже приходил в другом письме совсем недавно. Возможна атака с повторной отправкой — не вводите его, если не запрашивали."))) + "\n")
+	}
+	if msg.Auth == models.AuthFail {
+		sb.WriteString(m.Bold(m.Escape(tr(authWarning))) + "\n")
+	}
+
+	var bodyLimit int
+	for _, line := range t.lines {
+		var out, bold strings.Builder
+		inBold, placeholders, filled := false, 0, 0
+		write := func(s string) {
+			if inBold {
+				bold.WriteString(s)
+			} else {
+				out.WriteString(s)
+			}
+		}
+
+		for _, token := range line {
+			switch {
+			case token.bold:
+				if inBold && bold.Len() > 0 {
+					out.WriteString(m.Bold(bold.String()))
+				}
+				bold.Reset()
+				inBold = !inBold
+			case token.field == "":
+				write(m.Escape(token.text))
+			case token.field == FieldIcon || token.field == FieldAuth:
+				// Decoration, does not keep a line of empty fields
+				write(f.templateValue(token, msg, codes, opts))
+			case token.field == FieldBody:
+				placeholders++
+				if msg.BodyText != "" {
+					filled++
+				}
+				bodyLimit, _ = strconv.Atoi(token.arg)
+				write(bodyMarker)
+			default:
+				placeholders++
+				value := f.templateValue(token, msg, codes, opts)
+				if value != "" {
+					filled++
+				}
+				write(value)
+			}
+		}
+		if inBold && bold.Len() > 0 {
+			out.WriteString(m.Bold(bold.String()))
+		}
+
+		if placeholders > 0 && filled == 0 {
+			continue
+		}
+		sb.WriteString(out.String() + "\n")
+	}
+
+	text := strings.TrimRight(sb.String(), "\n")
+	if !strings.Contains(text, bodyMarker) {
+		return text, false
+	}
+
+	limit := f.maxLength - len(text) - 50
+	preview := bodyLimit > 0 && bodyLimit < limit
+	if preview {
+		limit = bodyLimit
+	}
+	body, truncated := f.truncate(msg.BodyText, limit)
+	body = m.Escape(body)
+	if truncated && preview {
+		body += m.Escape("…")
+	} else if truncated {
+		body += "\n\n" + m.Italic(m.Escape(tr("... (сообщение обрезано)")))
+	}
+	return strings.Replace(text, bodyMarker, body, 1), truncated
+}
+
+// templateValue renders a placeholder other than the body, escaped
+func (f *TelegramFormatter) templateValue(token templateToken, msg *models.EmailMessage, codes []models.DetectedCode, opts FormatOptions) string {
+	m := markupFor(opts.ParseMode)
+
+	switch token.field {
+	case FieldIcon:
+		return senderIcon(msg, codes, opts)
+	case FieldAuth:
+		return authBadge(msg)
+	case FieldFrom:
+		if msg.FromName != "" {
+			return m.Escape(fmt.Sprintf("%s <%s>", msg.FromName, msg.FromAddr))
+		}
+		return m.Escape(msg.FromAddr)
+	case FieldSender:
+		if msg.FromName != "" {
+			return m.Escape(msg.FromName)
+		}
+		return m.Escape(msg.FromAddr)
+	case FieldEmail:
+		return m.Escape(msg.FromAddr)
+	case FieldSubject:
+		return m.Escape(msg.Subject)
+	case FieldDate:
+		if msg.ReceivedAt.IsZero() {
+			return ""
+		}
+		layout := defaultDateLayout
+		if token.arg != "" {
+			layout = dateLayout.Replace(token.arg)
+		}
+		return m.Escape(msg.ReceivedAt.Format(layout))
+	case FieldCodes:
+		values := make([]string, 0, len(codes))
+		for _, code := range codes {
+			values = append(values, m.Code(code.Value))
+		}
+		return strings.Join(values, " ")
+	case FieldAttachments:
+		var attachments []models.Attachment
+		json.Unmarshal([]byte(msg.Attachments), &attachments)
+		var names []string
+		for _, att := range attachments {
+			names = append(names, att.Filename)
+		}
+		return m.Escape(strings.Join(names, ", "))
+	case FieldExtras:
+		return f.formatExtras(msg, opts)
+	}
+	return ""
+}
+
+// formatExtras renders the order card, extracted fields and links of an email
+func (f *TelegramFormatter) formatExtras(msg *models.EmailMessage, opts FormatOptions) string {
+	m := markupFor(opts.ParseMode)
+	extraction := msg.ExtractedData()
+	if extraction == nil {
+		return ""
+	}
+
+	var lines []string
+	if extraction.Order != nil {
+		lines = append(lines, FormatOrderCard(opts.ParseMode, opts.Language, extraction.Order))
+	}
+	for _, field := range extraction.Fields {
+		lines = append(lines, fmt.Sprintf("%s %s", m.Bold(m.Escape(field.Name+":")), m.Escape(field.Value)))
+	}
+	for _, link := range extraction.Links {
+		lines = append(lines, "🔗 "+m.Link(m.Escape(link.Title), link.URL))
+	}
+	return strings.Join(lines, "\n")
+}
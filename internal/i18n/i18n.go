@@ -0,0 +1,97 @@
+// Package i18n translates bot messages. Messages are written in Russian in
+// the code and serve as keys of the other languages' catalogs, so a message
+// without a translation is shown in Russian.
+package i18n
+
+import (
+	"context"
+	"fmt"
+)
+
+// Supported languages
+const (
+	Russian = "ru"
+	English = "en"
+)
+
+// Default is the language of chats that have not chosen one
+const Default = Russian
+
+// Languages lists the supported languages in the order they are shown
+var Languages = []string{Russian, English}
+
+// names are the native names of the languages
+var names = map[string]string{
+	Russian: "Русский",
+	English: "English",
+}
+
+// catalogs map a Russian message to its translation, by language
+var catalogs = map[string]map[string]string{}
+
+// Register adds translations of Russian messages to a language's catalog.
+// Packages call it from init, catalogs are read-only afterwards.
+func Register(lang string, messages map[string]string) {
+	catalog := catalogs[lang]
+	if catalog == nil {
+		catalog = make(map[string]string, len(messages))
+		catalogs[lang] = catalog
+	}
+	for msg, translation := range messages {
+		catalog[msg] = translation
+	}
+}
+
+// Supported reports whether lang is a supported language code
+func Supported(lang string) bool {
+	_, ok := names[lang]
+	return ok
+}
+
+// Name returns the native name of a language
+func Name(lang string) string {
+	if name, ok := names[lang]; ok {
+		return name
+	}
+	return lang
+}
+
+// Translate returns the translation of a Russian message, or the message
+// itself if it has none
+func Translate(lang, msg string) string {
+	if translation, ok := catalogs[lang][msg]; ok {
+		return translation
+	}
+	return msg
+}
+
+// Translatef translates a format string and formats it with args
+func Translatef(lang, format string, args ...any) string {
+	return fmt.Sprintf(Translate(lang, format), args...)
+}
+
+type langKey struct{}
+
+// WithLang returns a context carrying the language of the chat being served
+func WithLang(ctx context.Context, lang string) context.Context {
+	return context.WithValue(ctx, langKey{}, lang)
+}
+
+// Lang returns the language carried by ctx, Default if there is none
+func Lang(ctx context.Context) string {
+	if lang, ok := ctx.Value(langKey{}).(string); ok && lang != "" {
+		return lang
+	}
+	return Default
+}
+
+// T translates a message into the language carried by ctx
+func T(ctx context.Context, msg string) string {
+	return Translate(Lang(ctx), msg)
+}
+
+// Tf translates a format string into the language carried by ctx and
+// formats it with args
+func Tf(ctx context.Context, format string, args ...any) string {
+	return Translatef(Lang(ctx), format, args...)
+}
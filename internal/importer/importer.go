@@ -0,0 +1,197 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Record is a single mailbox entry to import
+type Record struct {
+	Line       int // Source line (CSV) or array index + 1 (JSON)
+	Email      string
+	Password   string
+	IMAPServer string // Optional, auto-detected when empty
+	TopicID    int    // Telegram topic to bind the account to
+
+	// OAuth accounts log in with XOAUTH2 instead of a password
+	OAuthProvider string // "google" or "microsoft"
+	RefreshToken  string
+}
+
+// Column name aliases used by password managers and other forwarding tools
+var (
+	emailColumns    = []string{"email", "login_username", "username", "login", "user", "address"}
+	passwordColumns = []string{"password", "login_password", "pass", "app_password"}
+	serverColumns   = []string{"imap_server", "imap", "server", "host"}
+	topicColumns    = []string{"topic_id", "topic", "thread_id", "message_thread_id"}
+	providerColumns = []string{"oauth_provider", "provider"}
+	refreshColumns  = []string{"refresh_token", "oauth_refresh_token"}
+)
+
+// Parse parses an import file. The format is chosen by file extension,
+// falling back to content sniffing (JSON starts with '[' or '{').
+func Parse(filename string, data []byte) ([]Record, error) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	trimmed := bytes.TrimSpace(data)
+
+	switch {
+	case ext == ".json":
+		return parseJSON(trimmed)
+	case ext == ".csv":
+		return parseCSV(trimmed)
+	case len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{'):
+		return parseJSON(trimmed)
+	default:
+		return parseCSV(trimmed)
+	}
+}
+
+// parseJSON parses an array of objects (or an object with an "accounts" array)
+func parseJSON(data []byte) ([]Record, error) {
+	var rows []map[string]any
+	if err := json.Unmarshal(data, &rows); err != nil {
+		var wrapped struct {
+			Accounts []map[string]any `json:"accounts"`
+		}
+		if errWrapped := json.Unmarshal(data, &wrapped); errWrapped != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+		rows = wrapped.Accounts
+	}
+
+	records := make([]Record, 0, len(rows))
+	for i, row := range rows {
+		fields := make(map[string]string, len(row))
+		for k, v := range row {
+			fields[strings.ToLower(k)] = strings.TrimSpace(fmt.Sprint(v))
+		}
+		rec, err := buildRecord(i+1, fields)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+// parseCSV parses a CSV file. A header row is detected by an email-like
+// column name; without one the column order is email,password,topic_id[,imap_server].
+func parseCSV(data []byte) ([]Record, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var header []string
+	var records []Record
+	line := 0
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+		line++
+
+		if line == 1 && isHeader(row) {
+			header = make([]string, len(row))
+			for i, col := range row {
+				header[i] = strings.ToLower(strings.TrimSpace(col))
+			}
+			continue
+		}
+
+		fields := make(map[string]string)
+		if header != nil {
+			for i, col := range header {
+				if i < len(row) {
+					fields[col] = strings.TrimSpace(row[i])
+				}
+			}
+		} else {
+			for i, col := range []string{"email", "password", "topic_id", "imap_server"} {
+				if i < len(row) {
+					fields[col] = strings.TrimSpace(row[i])
+				}
+			}
+		}
+
+		rec, err := buildRecord(line, fields)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+// isHeader reports whether a CSV row looks like a header
+func isHeader(row []string) bool {
+	for _, col := range row {
+		col = strings.ToLower(strings.TrimSpace(col))
+		for _, name := range emailColumns {
+			if col == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// buildRecord maps aliased fields to a Record
+func buildRecord(line int, fields map[string]string) (Record, error) {
+	rec := Record{
+		Line:       line,
+		Email:      pick(fields, emailColumns),
+		Password:   pick(fields, passwordColumns),
+		IMAPServer: pick(fields, serverColumns),
+
+		OAuthProvider: strings.ToLower(pick(fields, providerColumns)),
+		RefreshToken:  pick(fields, refreshColumns),
+	}
+
+	if topic := pick(fields, topicColumns); topic != "" {
+		id, err := strconv.Atoi(topic)
+		if err != nil {
+			return rec, fmt.Errorf("line %d: invalid topic id %q", line, topic)
+		}
+		rec.TopicID = id
+	}
+
+	return rec, nil
+}
+
+// pick returns the first non-empty value among aliased columns
+func pick(fields map[string]string, names []string) string {
+	for _, name := range names {
+		if v := fields[name]; v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// Validate checks that a record has the required fields
+func (r Record) Validate() error {
+	if !strings.Contains(r.Email, "@") {
+		return fmt.Errorf("invalid email %q", r.Email)
+	}
+	if r.RefreshToken != "" {
+		if r.OAuthProvider != "google" && r.OAuthProvider != "microsoft" {
+			return fmt.Errorf("unsupported OAuth provider %q", r.OAuthProvider)
+		}
+	} else if r.Password == "" {
+		return fmt.Errorf("missing password")
+	}
+	if r.TopicID == 0 {
+		return fmt.Errorf("missing topic id")
+	}
+	return nil
+}
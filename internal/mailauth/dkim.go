@@ -0,0 +1,342 @@
+package mailauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	// Hashes of DKIM signatures
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+)
+
+// maxSignatures bounds the DKIM signatures verified per email, each needing
+// a DNS lookup
+const maxSignatures = 5
+
+// errKeyUnavailable wraps DNS failures that may go away, so the signature is
+// neither valid nor failed
+var errKeyUnavailable = errors.New("key lookup failed")
+
+// signature is the result of verifying one DKIM-Signature
+type signature struct {
+	domain string // d= tag
+	err    error  // nil if the signature is valid
+}
+
+// temporary reports whether the signature could not be checked for now
+func (s signature) temporary() bool {
+	return errors.Is(s.err, errKeyUnavailable)
+}
+
+// verifyDKIM verifies the DKIM signatures of a message
+func (c *Checker) verifyDKIM(ctx context.Context, msg *message) []signature {
+	var signatures []signature
+	for _, field := range msg.fields {
+		if !strings.EqualFold(field.name, "DKIM-Signature") {
+			continue
+		}
+		if len(signatures) == maxSignatures {
+			break
+		}
+		signatures = append(signatures, c.verifySignature(ctx, msg, field))
+	}
+	return signatures
+}
+
+// verifySignature verifies a DKIM-Signature field (RFC 6376, section 6)
+func (c *Checker) verifySignature(ctx context.Context, msg *message, field headerField) signature {
+	tags, err := parseTags(field.value())
+	if err != nil {
+		return signature{err: err}
+	}
+	sig := signature{domain: strings.ToLower(tags["d"])}
+	fail := func(err error) signature {
+		sig.err = err
+		return sig
+	}
+
+	if tags["v"] != "1" {
+		return fail(fmt.Errorf("unsupported version %q", tags["v"]))
+	}
+	for _, tag := range []string{"a", "b", "bh", "d", "h", "s"} {
+		if tags[tag] == "" {
+			return fail(fmt.Errorf("missing %s= tag", tag))
+		}
+	}
+
+	keyType, hashName, _ := strings.Cut(strings.ToLower(tags["a"]), "-")
+	var hashAlg crypto.Hash
+	switch hashName {
+	case "sha256":
+		hashAlg = crypto.SHA256
+	case "sha1":
+		hashAlg = crypto.SHA1
+	default:
+		return fail(fmt.Errorf("unsupported algorithm %s", tags["a"]))
+	}
+
+	headerCanon, bodyCanon, _ := strings.Cut(strings.ToLower(tags["c"]), "/")
+	if headerCanon == "" {
+		headerCanon = "simple"
+	}
+	if bodyCanon == "" {
+		bodyCanon = "simple"
+	}
+	if (headerCanon != "simple" && headerCanon != "relaxed") || (bodyCanon != "simple" && bodyCanon != "relaxed") {
+		return fail(fmt.Errorf("unsupported canonicalization %s", tags["c"]))
+	}
+
+	var signed []string
+	for _, name := range strings.Split(tags["h"], ":") {
+		signed = append(signed, strings.TrimSpace(name))
+	}
+	if !containsFold(signed, "from") {
+		return fail(errors.New("From is not signed"))
+	}
+	if identity := tags["i"]; identity != "" {
+		if domain := addressDomain(identity); domain != sig.domain && !strings.HasSuffix(domain, "."+sig.domain) {
+			return fail(errors.New("i= is not within d="))
+		}
+	}
+	if expires := tags["x"]; expires != "" {
+		if x, err := strconv.ParseInt(expires, 10, 64); err == nil && time.Unix(x, 0).Before(time.Now()) {
+			return fail(errors.New("signature expired"))
+		}
+	}
+
+	bodyHash, err := base64.StdEncoding.DecodeString(tags["bh"])
+	if err != nil {
+		return fail(errors.New("invalid bh= tag"))
+	}
+	sigValue, err := base64.StdEncoding.DecodeString(tags["b"])
+	if err != nil {
+		return fail(errors.New("invalid b= tag"))
+	}
+
+	body := canonicalBody(msg.body, bodyCanon)
+	if limit := tags["l"]; limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil || l < 0 {
+			return fail(errors.New("invalid l= tag"))
+		}
+		if l < len(body) {
+			body = body[:l]
+		}
+	}
+	h := hashAlg.New()
+	h.Write(body)
+	if subtle.ConstantTimeCompare(h.Sum(nil), bodyHash) != 1 {
+		return fail(errors.New("body hash mismatch"))
+	}
+
+	key, err := c.publicKey(ctx, strings.ToLower(tags["s"]), sig.domain)
+	if err != nil {
+		return fail(err)
+	}
+
+	h = hashAlg.New()
+	writeSignedHeaders(h, msg.fields, signed, headerCanon)
+	sigField := headerField{name: field.name, raw: signatureValue.ReplaceAllString(field.raw, "$1")}
+	h.Write([]byte(strings.TrimSuffix(canonicalHeader(sigField, headerCanon), "\r\n")))
+	digest := h.Sum(nil)
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if keyType != "rsa" {
+			return fail(errors.New("key type mismatch"))
+		}
+		if err := rsa.VerifyPKCS1v15(key, hashAlg, digest, sigValue); err != nil {
+			return fail(errors.New("signature mismatch"))
+		}
+	case ed25519.PublicKey:
+		if keyType != "ed25519" {
+			return fail(errors.New("key type mismatch"))
+		}
+		if !ed25519.Verify(key, digest, sigValue) {
+			return fail(errors.New("signature mismatch"))
+		}
+	}
+	return sig
+}
+
+// signatureValue matches the b= tag value, which is left out when hashing
+// the signature field itself
+var signatureValue = regexp.MustCompile(`((?:^|[;:\s])b[ \t\r\n]*=)[^;]*`)
+
+// writeSignedHeaders hashes the fields listed in h=. A name listed several
+// times takes the next instance from the bottom; names without one more
+// instance contribute nothing.
+func writeSignedHeaders(h hash.Hash, fields []headerField, signed []string, canon string) {
+	used := make(map[int]bool)
+	for _, name := range signed {
+		for i := len(fields) - 1; i >= 0; i-- {
+			if !used[i] && strings.EqualFold(fields[i].name, name) {
+				used[i] = true
+				h.Write([]byte(canonicalHeader(fields[i], canon)))
+				break
+			}
+		}
+	}
+}
+
+// canonicalHeader returns a header field in simple or relaxed
+// canonicalization, with the final CRLF
+func canonicalHeader(field headerField, canon string) string {
+	if canon == "simple" {
+		return field.raw
+	}
+	_, value, _ := strings.Cut(field.raw, ":")
+	value = strings.NewReplacer("\r\n", "").Replace(value)
+	return strings.ToLower(strings.TrimSpace(field.name)) + ":" + strings.Join(strings.Fields(value), " ") + "\r\n"
+}
+
+// canonicalBody returns a body in simple or relaxed canonicalization
+func canonicalBody(body []byte, canon string) []byte {
+	text := string(body)
+	if canon == "relaxed" {
+		lines := strings.Split(text, "\r\n")
+		for i, line := range lines {
+			// Runs of whitespace become one space, trailing whitespace goes
+			line = strings.TrimRight(line, " \t")
+			var sb strings.Builder
+			space := false
+			for _, r := range line {
+				if r == ' ' || r == '\t' {
+					space = true
+					continue
+				}
+				if space {
+					sb.WriteByte(' ')
+					space = false
+				}
+				sb.WriteRune(r)
+			}
+			lines[i] = sb.String()
+		}
+		text = strings.Join(lines, "\r\n")
+	}
+
+	text = strings.TrimRight(text, "\r\n")
+	if text == "" && canon == "relaxed" {
+		return nil
+	}
+	return []byte(text + "\r\n")
+}
+
+// parseTags parses a tag list (RFC 6376, section 3.2). Whitespace inside
+// values is removed, which keeps folded base64 and header lists intact.
+func parseTags(s string) (map[string]string, error) {
+	tags := make(map[string]string)
+	for _, part := range strings.Split(s, ";") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("malformed tag %q", strings.TrimSpace(part))
+		}
+		name = strings.TrimSpace(name)
+		if _, dup := tags[name]; dup {
+			return nil, fmt.Errorf("duplicate %s= tag", name)
+		}
+		tags[name] = strings.Join(strings.Fields(value), "")
+	}
+	return tags, nil
+}
+
+// publicKey returns the DKIM key of a selector from DNS (RFC 6376, section
+// 3.6.2). Keys and missing keys are cached; failed lookups are not.
+func (c *Checker) publicKey(ctx context.Context, selector, domain string) (crypto.PublicKey, error) {
+	name := selector + "._domainkey." + domain
+
+	c.mu.Lock()
+	cached, ok := c.keys[name]
+	c.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.key, cached.err
+	}
+
+	records, err := c.lookupTXT(ctx, name)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+			return nil, fmt.Errorf("%w: %v", errKeyUnavailable, err)
+		}
+		err = fmt.Errorf("no key at %s", name)
+	}
+	var key crypto.PublicKey
+	if err == nil {
+		key, err = parseKey(strings.Join(records, ""))
+	}
+
+	c.mu.Lock()
+	if len(c.keys) >= maxCachedKeys {
+		clear(c.keys)
+	}
+	c.keys[name] = cachedKey{key: key, err: err, expires: time.Now().Add(keyTTL)}
+	c.mu.Unlock()
+	return key, err
+}
+
+// parseKey parses a DKIM key record
+func parseKey(record string) (crypto.PublicKey, error) {
+	tags, err := parseTags(record)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key record: %w", err)
+	}
+	if v, ok := tags["v"]; ok && v != "DKIM1" {
+		return nil, fmt.Errorf("unsupported key version %q", v)
+	}
+	if tags["p"] == "" {
+		return nil, errors.New("key revoked")
+	}
+	data, err := base64.StdEncoding.DecodeString(tags["p"])
+	if err != nil {
+		return nil, errors.New("invalid key data")
+	}
+
+	switch strings.ToLower(tags["k"]) {
+	case "", "rsa":
+		if key, err := x509.ParsePKIXPublicKey(data); err == nil {
+			if rsaKey, ok := key.(*rsa.PublicKey); ok {
+				return rsaKey, nil
+			}
+			return nil, errors.New("key is not RSA")
+		}
+		// Some records hold a bare PKCS #1 key
+		key, err := x509.ParsePKCS1PublicKey(data)
+		if err != nil {
+			return nil, errors.New("invalid RSA key")
+		}
+		return key, nil
+	case "ed25519":
+		if len(data) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(data), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %s", tags["k"])
+}
+
+// containsFold reports whether list contains s, ignoring case
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,213 @@
+// Package mailauth checks whether an email comes from the domain of its From
+// address: it verifies DKIM signatures (RFC 6376) and reads the SPF, DKIM and
+// DMARC verdicts the receiving server recorded in Authentication-Results and
+// Received-SPF, so spoofed code and phishing emails can be flagged
+package mailauth
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mixelka/emailresend/pkg/models"
+)
+
+const (
+	// keyTTL is how long DKIM keys and missing keys are remembered
+	keyTTL = time.Hour
+	// maxCachedKeys bounds the key cache; it is emptied when full
+	maxCachedKeys = 1000
+	// checkTimeout bounds the DNS lookups of one email
+	checkTimeout = 10 * time.Second
+)
+
+// Result is the verdict on the sender of an email
+type Result struct {
+	Verdict string   // models.AuthPass, models.AuthFail or "" if the email carries nothing to check
+	Reasons []string // Checks that contributed to the verdict
+}
+
+// receivingDomains are the domains of the servers that receive mail for
+// providers whose IMAP host is under another domain
+var receivingDomains = map[string][]string{
+	"gmail.com":  {"google.com"},
+	"yandex.com": {"yandex.net"},
+	"yandex.ru":  {"yandex.net"},
+}
+
+// TrustedServers returns the server names trusted to add
+// Authentication-Results to the mail of a mailbox on imapServer ("host:port"):
+// the domain of the IMAP host, the receiving servers of its provider and the
+// configured extra names
+func TrustedServers(imapServer string, extra []string) []string {
+	host, _, err := net.SplitHostPort(imapServer)
+	if err != nil {
+		host = imapServer
+	}
+	trusted := append([]string{}, extra...)
+	if host = addressDomain(host); host != "" {
+		trusted = append(trusted, host)
+		trusted = append(trusted, receivingDomains[orgDomain(host)]...)
+	}
+	return trusted
+}
+
+// Checker checks emails and caches the DKIM keys it looked up
+type Checker struct {
+	lookupTXT func(ctx context.Context, name string) ([]string, error)
+
+	mu   sync.Mutex
+	keys map[string]cachedKey
+}
+
+type cachedKey struct {
+	key     crypto.PublicKey
+	err     error // Permanent lookup error, e.g. no such record
+	expires time.Time
+}
+
+// NewChecker creates a checker using the system resolver
+func NewChecker() *Checker {
+	return &Checker{
+		lookupTXT: net.DefaultResolver.LookupTXT,
+		keys:      make(map[string]cachedKey),
+	}
+}
+
+// Check checks the sender of a raw RFC822 message against the domain of
+// from, its From address. The email passes if a DKIM signature or SPF of
+// the From domain (or another domain under the same organizational domain)
+// is valid or the server reports a DMARC pass for the From domain. It fails
+// if it carries signatures or server verdicts but none of them confirms the
+// From domain.
+//
+// Signatures verified here take precedence over Authentication-Results.
+// Only the topmost field added by a trusted receiving server is read, one
+// whose authserv-id (or the receiver of Received-SPF) is under the domain
+// of one of trusted (RFC 8601, section 5): the others may have been added
+// by the sender.
+func (c *Checker) Check(ctx context.Context, raw []byte, from string, trusted []string) Result {
+	var r Result
+	fromDomain := addressDomain(from)
+	if len(raw) == 0 || fromDomain == "" {
+		return r
+	}
+	msg := parseMessage(raw)
+
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	var dkimPass, dkimFail, evidence bool
+	for _, sig := range c.verifyDKIM(ctx, msg) {
+		switch {
+		case sig.err == nil:
+			evidence = true
+			r.Reasons = append(r.Reasons, fmt.Sprintf("DKIM-Signature d=%s: pass", sig.domain))
+			if aligned(sig.domain, fromDomain) {
+				dkimPass = true
+			}
+		case sig.temporary():
+			r.Reasons = append(r.Reasons, fmt.Sprintf("DKIM-Signature d=%s: temperror", sig.domain))
+		default:
+			evidence = true
+			r.Reasons = append(r.Reasons, fmt.Sprintf("DKIM-Signature d=%s: fail (%v)", sig.domain, sig.err))
+			if aligned(sig.domain, fromDomain) {
+				dkimFail = true
+			}
+		}
+	}
+
+	var serverPass, dmarcFail bool
+	if results, ok := trustedAuthResults(msg, trusted); ok {
+		for _, res := range results {
+			var domain string
+			switch res.method {
+			case "dkim":
+				domain = res.props["header.d"]
+				if domain == "" {
+					domain = addressDomain(res.props["header.i"])
+				}
+			case "spf":
+				domain = addressDomain(res.props["smtp.mailfrom"])
+				if domain == "" {
+					domain = addressDomain(res.props["smtp.helo"])
+				}
+			case "dmarc":
+				domain = addressDomain(res.props["header.from"])
+			default:
+				continue
+			}
+			if res.result == "none" && domain == "" {
+				continue
+			}
+			evidence = true
+			reason := fmt.Sprintf("Authentication-Results: %s=%s", res.method, res.result)
+			if domain != "" {
+				reason += " (" + domain + ")"
+			}
+			r.Reasons = append(r.Reasons, reason)
+
+			switch {
+			case res.method == "dmarc" && res.result == "pass" && aligned(domain, fromDomain):
+				serverPass = true
+			case res.method == "dmarc" && res.result == "fail":
+				dmarcFail = true
+			case res.result == "pass" && aligned(domain, fromDomain):
+				serverPass = true
+			}
+		}
+	} else if field, ok := msg.topmost("Received-SPF"); ok {
+		result, domain, receiver := parseReceivedSPF(field.value())
+		if result != "" && result != "none" && isTrusted(receiver, trusted) {
+			evidence = true
+			r.Reasons = append(r.Reasons, "Received-SPF: "+result)
+			if result == "pass" && aligned(domain, fromDomain) {
+				serverPass = true
+			}
+		}
+	}
+
+	switch {
+	case dkimPass:
+		r.Verdict = models.AuthPass
+	case dmarcFail, dkimFail:
+		r.Verdict = models.AuthFail
+	case serverPass:
+		r.Verdict = models.AuthPass
+	case evidence:
+		r.Verdict = models.AuthFail
+	}
+	return r
+}
+
+// trustedAuthResults returns the results of the topmost
+// Authentication-Results field added by a trusted server
+func trustedAuthResults(msg *message, trusted []string) ([]authResult, bool) {
+	for _, f := range msg.fields {
+		if !strings.EqualFold(f.name, "Authentication-Results") {
+			continue
+		}
+		if authServID, results := parseAuthResults(f.value()); isTrusted(authServID, trusted) {
+			return results, true
+		}
+	}
+	return nil, false
+}
+
+// isTrusted reports whether a server name is under the organizational
+// domain of one of the trusted names
+func isTrusted(name string, trusted []string) bool {
+	if name == "" {
+		return false
+	}
+	for _, t := range trusted {
+		if aligned(t, name) {
+			return true
+		}
+	}
+	return false
+}
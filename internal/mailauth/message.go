@@ -0,0 +1,103 @@
+package mailauth
+
+import (
+	"bytes"
+	"strings"
+)
+
+// message is a raw email split into header fields and body, with CRLF line
+// endings as signed
+type message struct {
+	fields []headerField // In message order, topmost first
+	body   []byte
+}
+
+// headerField is a header field as it appears in the message
+type headerField struct {
+	name string
+	raw  string // "Name: value" with folding and the final CRLF
+}
+
+// value returns the field value unfolded, without the name
+func (f headerField) value() string {
+	_, value, _ := strings.Cut(f.raw, ":")
+	return strings.TrimSpace(strings.NewReplacer("\r\n", "", "\n", "").Replace(value))
+}
+
+// parseMessage splits a raw email. Messages pasted or stored with bare LF
+// line endings are converted to CRLF first.
+func parseMessage(raw []byte) *message {
+	if bytes.Contains(raw, []byte("\n")) && !bytes.Contains(raw, []byte("\r\n")) {
+		raw = bytes.ReplaceAll(raw, []byte("\n"), []byte("\r\n"))
+	}
+
+	msg := &message{}
+	rest := raw
+	for len(rest) > 0 {
+		end := bytes.Index(rest, []byte("\r\n"))
+		if end < 0 {
+			end = len(rest)
+		} else {
+			end += 2
+		}
+		line := string(rest[:end])
+		rest = rest[end:]
+
+		if line == "\r\n" {
+			msg.body = rest
+			return msg
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(msg.fields) > 0 {
+			msg.fields[len(msg.fields)-1].raw += line
+			continue
+		}
+		name, _, ok := strings.Cut(line, ":")
+		if !ok {
+			// Not a header line: the header section ended without a blank line
+			return msg
+		}
+		msg.fields = append(msg.fields, headerField{name: strings.TrimSpace(name), raw: line})
+	}
+	return msg
+}
+
+// topmost returns the first field with the given name, the one added last
+func (m *message) topmost(name string) (headerField, bool) {
+	for _, f := range m.fields {
+		if strings.EqualFold(f.name, name) {
+			return f, true
+		}
+	}
+	return headerField{}, false
+}
+
+// orgDomain approximates the organizational domain (RFC 7489) of a domain
+// without the public suffix list: the last two labels, three under
+// second-level registries like co.uk
+func orgDomain(domain string) string {
+	labels := strings.Split(strings.Trim(strings.ToLower(domain), "."), ".")
+	keep := 2
+	if n := len(labels); n > 2 && len(labels[n-1]) == 2 && len(labels[n-2]) <= 3 {
+		keep = 3
+	}
+	if len(labels) > keep {
+		labels = labels[len(labels)-keep:]
+	}
+	return strings.Join(labels, ".")
+}
+
+// aligned reports whether an authenticated domain matches the From domain
+// with relaxed alignment: both under the same organizational domain
+func aligned(domain, fromDomain string) bool {
+	return domain != "" && orgDomain(domain) == orgDomain(fromDomain)
+}
+
+// addressDomain returns the domain of an address, the whole value if it has
+// no @, lowercased and without angle brackets or quotes
+func addressDomain(address string) string {
+	address = strings.Trim(strings.TrimSpace(address), `<>"`)
+	if i := strings.LastIndex(address, "@"); i >= 0 {
+		address = address[i+1:]
+	}
+	return strings.ToLower(strings.TrimSuffix(address, "."))
+}
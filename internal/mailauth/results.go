@@ -0,0 +1,103 @@
+package mailauth
+
+import (
+	"strings"
+)
+
+// authResult is one method result of an Authentication-Results field
+// (RFC 8601), e.g. "dkim=pass header.d=example.com"
+type authResult struct {
+	method string // dkim, spf, dmarc...
+	result string // pass, fail, softfail, none...
+	props  map[string]string
+}
+
+// parseAuthResults parses the value of an Authentication-Results field into
+// the authserv-id of the server that added it and the method results
+func parseAuthResults(value string) (string, []authResult) {
+	parts := strings.Split(stripComments(value), ";")
+	// The authserv-id may be followed by a version
+	var authServID string
+	if fields := strings.Fields(parts[0]); len(fields) > 0 && !strings.Contains(fields[0], "=") {
+		authServID = strings.ToLower(fields[0])
+	}
+	var results []authResult
+	for _, part := range parts[1:] {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			continue
+		}
+		method, result, ok := strings.Cut(fields[0], "=")
+		if !ok {
+			continue
+		}
+		method, _, _ = strings.Cut(method, "/")
+		r := authResult{
+			method: strings.ToLower(method),
+			result: strings.ToLower(result),
+			props:  make(map[string]string),
+		}
+		for _, field := range fields[1:] {
+			if name, value, ok := strings.Cut(field, "="); ok {
+				r.props[strings.ToLower(name)] = strings.Trim(value, `"`)
+			}
+		}
+		results = append(results, r)
+	}
+	return authServID, results
+}
+
+// parseReceivedSPF parses the value of a Received-SPF field (RFC 7208,
+// section 9.1) into its result, envelope sender domain and the receiver
+// that added it
+func parseReceivedSPF(value string) (result, domain, receiver string) {
+	value = stripComments(value)
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return "", "", ""
+	}
+	result = strings.ToLower(fields[0])
+	for _, part := range strings.Split(strings.TrimPrefix(value, fields[0]), ";") {
+		name, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		switch {
+		case !ok:
+		case strings.EqualFold(name, "envelope-from"):
+			domain = addressDomain(v)
+		case strings.EqualFold(name, "receiver"):
+			receiver = addressDomain(v)
+		}
+	}
+	return result, domain, receiver
+}
+
+// stripComments removes (comments) from a structured header value
+func stripComments(s string) string {
+	var sb strings.Builder
+	depth := 0
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s):
+			if depth == 0 {
+				sb.WriteByte(c)
+				sb.WriteByte(s[i+1])
+			}
+			i++
+			continue
+		case c == '"' && depth == 0:
+			quoted = !quoted
+		case c == '(' && !quoted:
+			depth++
+			continue
+		case c == ')' && !quoted && depth > 0:
+			depth--
+			sb.WriteByte(' ')
+			continue
+		}
+		if depth == 0 {
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
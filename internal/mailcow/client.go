@@ -232,6 +232,42 @@ func (c *Client) DeleteMailbox(ctx context.Context, email string) error {
 	return nil
 }
 
+// Version returns the Mailcow version, checking that the API is reachable
+// and accepts the API key
+func (c *Client) Version(ctx context.Context) (string, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/api/v1/get/status/version", nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("X-API-Key", c.apiKey)
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return "", fmt.Errorf("API key rejected (status %d)", resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("API error: %s (status %d)", string(respBody), resp.StatusCode)
+	}
+
+	var status struct {
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal(respBody, &status); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+	return status.Version, nil
+}
+
 // GenerateSecurePassword generates a cryptographically secure password
 func GenerateSecurePassword(length int) (string, error) {
 	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789!@#$%^&*"
@@ -0,0 +1,58 @@
+package mailcow
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// rangePattern matches a numeric range like {1..10} or {01..10}
+var rangePattern = regexp.MustCompile(`\{(\d+)\.\.(\d+)\}`)
+
+// localPartPattern matches local parts accepted for new mailboxes
+var localPartPattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+
+// ExpandPattern expands a local part pattern with one numeric range, e.g.
+// "team{1..3}" into team1, team2, team3. A start with leading zeros pads all
+// numbers to its width ("qa{01..10}" gives qa01 ... qa10). At most max names
+// are returned.
+func ExpandPattern(pattern string, max int) ([]string, error) {
+	loc := rangePattern.FindAllStringSubmatchIndex(pattern, -1)
+	if len(loc) != 1 {
+		return nil, fmt.Errorf("pattern must contain exactly one range like {1..10}")
+	}
+	m := loc[0]
+	startText, endText := pattern[m[2]:m[3]], pattern[m[4]:m[5]]
+
+	start, err := strconv.Atoi(startText)
+	if err != nil {
+		return nil, fmt.Errorf("invalid range start: %w", err)
+	}
+	end, err := strconv.Atoi(endText)
+	if err != nil {
+		return nil, fmt.Errorf("invalid range end: %w", err)
+	}
+	if end < start {
+		return nil, fmt.Errorf("range end %d is before start %d", end, start)
+	}
+	if end-start+1 > max {
+		return nil, fmt.Errorf("range has %d mailboxes, at most %d are allowed", end-start+1, max)
+	}
+
+	width := 0
+	if len(startText) > 1 && strings.HasPrefix(startText, "0") {
+		width = len(startText)
+	}
+
+	prefix, suffix := pattern[:m[0]], pattern[m[1]:]
+	names := make([]string, 0, end-start+1)
+	for i := start; i <= end; i++ {
+		name := fmt.Sprintf("%s%0*d%s", prefix, width, i, suffix)
+		if !localPartPattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid mailbox name %q", name)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
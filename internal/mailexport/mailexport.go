@@ -0,0 +1,253 @@
+// Package mailexport writes stored emails of an account to CSV, JSON or
+// mbox files for audits and migrations to other tools
+package mailexport
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mixelka/emailresend/pkg/models"
+)
+
+// Format of an export file
+type Format string
+
+const (
+	FormatCSV  Format = "csv"
+	FormatJSON Format = "json"
+	FormatMbox Format = "mbox"
+)
+
+// ParseFormat returns the format with the given name
+func ParseFormat(name string) (Format, bool) {
+	switch f := Format(strings.ToLower(name)); f {
+	case FormatCSV, FormatJSON, FormatMbox:
+		return f, true
+	}
+	return "", false
+}
+
+// Extension returns the file extension of the format
+func (f Format) Extension() string {
+	return "." + string(f)
+}
+
+// Writer writes emails one at a time
+type Writer interface {
+	// Write adds an email; raw is its original message if archived, nil
+	// otherwise (mbox then rebuilds a plain-text message)
+	Write(msg *models.EmailMessage, raw []byte) error
+	// Close finishes the file; it does not close the underlying writer
+	Close() error
+}
+
+// NewWriter creates a Writer of the format
+func NewWriter(w io.Writer, format Format) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		return &csvWriter{w: cw}, cw.Write(csvHeader)
+	case FormatJSON:
+		return &jsonWriter{w: w}, nil
+	case FormatMbox:
+		return &mboxWriter{w: bufio.NewWriter(w)}, nil
+	}
+	return nil, fmt.Errorf("unknown export format %q", format)
+}
+
+// Record is an email as exported to CSV and JSON
+type Record struct {
+	ID          int64                 `json:"id"`
+	MessageID   string                `json:"message_id"`
+	Date        time.Time             `json:"date"`
+	StoredAt    time.Time             `json:"stored_at"`
+	FromAddr    string                `json:"from"`
+	FromName    string                `json:"from_name,omitempty"`
+	ReplyTo     string                `json:"reply_to,omitempty"`
+	Subject     string                `json:"subject"`
+	Codes       []models.DetectedCode `json:"codes,omitempty"`
+	Attachments []models.Attachment   `json:"attachments,omitempty"`
+	Read        bool                  `json:"read"`
+	Folder      string                `json:"folder,omitempty"`
+	Bulk        string                `json:"bulk,omitempty"`
+	Body        string                `json:"body"`
+}
+
+// NewRecord converts a stored email
+func NewRecord(msg *models.EmailMessage) Record {
+	r := Record{
+		ID:        msg.ID,
+		MessageID: msg.MessageID,
+		Date:      msg.ReceivedAt,
+		StoredAt:  msg.CreatedAt,
+		FromAddr:  msg.FromAddr,
+		FromName:  msg.FromName,
+		ReplyTo:   msg.ReplyTo,
+		Subject:   msg.Subject,
+		Read:      msg.IsRead,
+		Folder:    msg.Folder,
+		Bulk:      msg.Bulk,
+		Body:      msg.BodyText,
+	}
+	if msg.DetectedCodes != "" {
+		json.Unmarshal([]byte(msg.DetectedCodes), &r.Codes)
+	}
+	if msg.Attachments != "" {
+		json.Unmarshal([]byte(msg.Attachments), &r.Attachments)
+	}
+	return r
+}
+
+var csvHeader = []string{"id", "message_id", "date", "stored_at", "from", "from_name", "reply_to", "subject", "codes", "attachments", "read", "folder", "bulk", "body"}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvWriter) Write(msg *models.EmailMessage, raw []byte) error {
+	r := NewRecord(msg)
+
+	codes := make([]string, 0, len(r.Codes))
+	for _, code := range r.Codes {
+		codes = append(codes, code.Value)
+	}
+	attachments := make([]string, 0, len(r.Attachments))
+	for _, att := range r.Attachments {
+		attachments = append(attachments, att.Filename)
+	}
+
+	return c.w.Write([]string{
+		strconv.FormatInt(r.ID, 10),
+		r.MessageID,
+		formatTime(r.Date),
+		formatTime(r.StoredAt),
+		r.FromAddr,
+		r.FromName,
+		r.ReplyTo,
+		r.Subject,
+		strings.Join(codes, " "),
+		strings.Join(attachments, "; "),
+		strconv.FormatBool(r.Read),
+		r.Folder,
+		r.Bulk,
+		r.Body,
+	})
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// jsonWriter streams a JSON array, one record per line
+type jsonWriter struct {
+	w     io.Writer
+	count int
+}
+
+func (j *jsonWriter) Write(msg *models.EmailMessage, raw []byte) error {
+	data, err := json.Marshal(NewRecord(msg))
+	if err != nil {
+		return err
+	}
+	sep := ",\n"
+	if j.count == 0 {
+		sep = "[\n"
+	}
+	j.count++
+	_, err = io.WriteString(j.w, sep+string(data))
+	return err
+}
+
+func (j *jsonWriter) Close() error {
+	end := "\n]\n"
+	if j.count == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(j.w, end)
+	return err
+}
+
+// mboxWriter writes the mboxrd format: lines starting with any number of
+// ">" followed by "From " get one more ">"
+type mboxWriter struct {
+	w *bufio.Writer
+}
+
+func (m *mboxWriter) Write(msg *models.EmailMessage, raw []byte) error {
+	if len(raw) == 0 {
+		raw = rebuild(msg)
+	}
+
+	sender := msg.FromAddr
+	if sender == "" || strings.ContainsAny(sender, " \t") {
+		sender = "MAILER-DAEMON"
+	}
+	date := msg.ReceivedAt
+	if date.IsZero() {
+		date = msg.CreatedAt
+	}
+	fmt.Fprintf(m.w, "From %s %s\n", sender, date.UTC().Format(time.ANSIC))
+
+	raw = bytes.ReplaceAll(raw, []byte("\r\n"), []byte("\n"))
+	for _, line := range bytes.SplitAfter(raw, []byte("\n")) {
+		if bytes.HasPrefix(bytes.TrimLeft(line, ">"), []byte("From ")) {
+			m.w.WriteByte('>')
+		}
+		m.w.Write(line)
+	}
+	if !bytes.HasSuffix(raw, []byte("\n")) {
+		m.w.WriteByte('\n')
+	}
+	return m.w.WriteByte('\n')
+}
+
+func (m *mboxWriter) Close() error {
+	return m.w.Flush()
+}
+
+// rebuild makes a plain-text message from the stored fields of an email
+// whose original is not archived
+func rebuild(msg *models.EmailMessage) []byte {
+	var buf bytes.Buffer
+
+	from := (&mail.Address{Name: msg.FromName, Address: msg.FromAddr}).String()
+	date := msg.ReceivedAt
+	if date.IsZero() {
+		date = msg.CreatedAt
+	}
+
+	fmt.Fprintf(&buf, "From: %s\n", from)
+	if msg.ReplyTo != "" {
+		fmt.Fprintf(&buf, "Reply-To: %s\n", msg.ReplyTo)
+	}
+	fmt.Fprintf(&buf, "Subject: %s\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\n", date.Format(time.RFC1123Z))
+	if msg.MessageID != "" {
+		fmt.Fprintf(&buf, "Message-ID: %s\n", msg.MessageID)
+	}
+	if msg.References != "" {
+		fmt.Fprintf(&buf, "References: %s\n", msg.References)
+	}
+	buf.WriteString("MIME-Version: 1.0\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\n\n")
+	buf.WriteString(msg.BodyText)
+	return buf.Bytes()
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,102 @@
+// Package oauth renews OAuth 2.0 access tokens of mail providers with the
+// refresh token grant (RFC 6749 section 6)
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Token endpoints of supported providers
+const (
+	GoogleTokenURL    = "https://oauth2.googleapis.com/token"
+	MicrosoftTokenURL = "https://login.microsoftonline.com/common/oauth2/v2.0/token"
+)
+
+// ErrRevoked is returned when the provider no longer accepts a refresh token
+// (revoked by the user, expired or the password was changed)
+var ErrRevoked = errors.New("refresh token revoked or expired")
+
+// Provider is an OAuth 2.0 client registered with a mail provider
+type Provider struct {
+	Name         string // "google" or "microsoft"
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+}
+
+// Token is a renewed access token
+type Token struct {
+	AccessToken  string
+	RefreshToken string // set if the provider rotated the refresh token
+	ExpiresAt    time.Time
+}
+
+// tokenResponse is the token endpoint response, including errors (RFC 6749 5.2)
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	RefreshToken     string `json:"refresh_token"`
+	ExpiresIn        int64  `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// Refresh exchanges a refresh token for a new access token
+func (p Provider) Refresh(ctx context.Context, refreshToken string) (*Token, error) {
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+		"client_id":     {p.ClientID},
+	}
+	if p.ClientSecret != "" {
+		form.Set("client_secret", p.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var tr tokenResponse
+	if err := json.Unmarshal(body, &tr); err != nil {
+		return nil, fmt.Errorf("failed to parse response (status %d): %w", resp.StatusCode, err)
+	}
+	if tr.Error != "" {
+		err := fmt.Errorf("%s: %s", tr.Error, tr.ErrorDescription)
+		if tr.Error == "invalid_grant" {
+			return nil, fmt.Errorf("%w: %w", ErrRevoked, err)
+		}
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK || tr.AccessToken == "" {
+		return nil, fmt.Errorf("unexpected response (status %d)", resp.StatusCode)
+	}
+
+	return &Token{
+		AccessToken:  tr.AccessToken,
+		RefreshToken: tr.RefreshToken,
+		ExpiresAt:    time.Now().Add(time.Duration(tr.ExpiresIn) * time.Second),
+	}, nil
+}
@@ -0,0 +1,84 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"net/textproto"
+	"strconv"
+	"strings"
+
+	"github.com/mixelka/emailresend/pkg/models"
+)
+
+const (
+	// bulkThreshold is the score from which an email is treated as bulk mail
+	bulkThreshold = 2
+	// spamLevelThreshold is the number of stars in X-Spam-Level from which
+	// an email is treated as spam, SpamAssassin's default
+	spamLevelThreshold = 5
+)
+
+// BulkResult is the classification of an email by its headers
+type BulkResult struct {
+	Class   string   // models.BulkSpam, models.BulkNewsletter or "" for personal mail
+	Score   int      // sum of the bulk signals found
+	Reasons []string // headers that contributed to the result
+}
+
+// DetectBulk classifies a raw RFC822 message as spam, bulk mail (newsletters,
+// mailing lists) or personal mail from its headers. Spam verdicts of the mail
+// server win; otherwise each bulk header adds to the score.
+func DetectBulk(raw []byte) BulkResult {
+	var result BulkResult
+	if len(raw) == 0 {
+		return result
+	}
+	header, err := textproto.NewReader(bufio.NewReader(bytes.NewReader(raw))).ReadMIMEHeader()
+	if err != nil && len(header) == 0 {
+		return result
+	}
+
+	if isYes(header.Get("X-Spam-Flag")) {
+		result.Reasons = append(result.Reasons, "X-Spam-Flag")
+	}
+	if status := header.Get("X-Spam-Status"); isYes(strings.SplitN(status, ",", 2)[0]) {
+		result.Reasons = append(result.Reasons, "X-Spam-Status")
+	}
+	if strings.Count(header.Get("X-Spam-Level"), "*") >= spamLevelThreshold {
+		result.Reasons = append(result.Reasons, "X-Spam-Level")
+	}
+	precedence := strings.ToLower(strings.TrimSpace(header.Get("Precedence")))
+	if precedence == "junk" {
+		result.Reasons = append(result.Reasons, "Precedence: junk")
+	}
+	if len(result.Reasons) > 0 {
+		result.Class = models.BulkSpam
+		return result
+	}
+
+	if header.Get("List-Unsubscribe") != "" {
+		result.Score++
+		result.Reasons = append(result.Reasons, "List-Unsubscribe")
+	}
+	if header.Get("List-Id") != "" {
+		result.Score++
+		result.Reasons = append(result.Reasons, "List-Id")
+	}
+	if precedence == "bulk" || precedence == "list" {
+		result.Score += 2
+		result.Reasons = append(result.Reasons, "Precedence: "+precedence)
+	}
+	if result.Score >= bulkThreshold {
+		result.Class = models.BulkNewsletter
+	}
+	return result
+}
+
+// isYes reports whether a spam header value is a positive verdict
+func isYes(value string) bool {
+	value = strings.TrimSpace(value)
+	if yes, err := strconv.ParseBool(value); err == nil {
+		return yes
+	}
+	return strings.EqualFold(value, "yes")
+}
@@ -1,12 +1,20 @@
 package parser
 
 import (
+	"errors"
+	"fmt"
 	"regexp"
 	"strings"
 
 	"github.com/mixelka/emailresend/pkg/models"
 )
 
+// CodeTypeCustom is the type of codes found by per-account patterns
+const CodeTypeCustom = "custom"
+
+// MaxCustomPatternLen caps the length of a per-account code pattern
+const MaxCustomPatternLen = 200
+
 // CodeDetector detects verification codes in text
 type CodeDetector struct {
 	patterns []*codePattern
@@ -57,9 +65,29 @@ func NewCodeDetector() *CodeDetector {
 
 // DetectCodes finds all verification codes in text
 func (d *CodeDetector) DetectCodes(text string) []models.DetectedCode {
+	return d.DetectCodesWith(text, nil)
+}
+
+// DetectCodesWith is DetectCodes with per-account patterns, which are tried
+// before the built-in ones
+func (d *CodeDetector) DetectCodesWith(text string, custom []*regexp.Regexp) []models.DetectedCode {
 	var codes []models.DetectedCode
 	seen := make(map[string]bool)
 
+	// Custom patterns are explicit, their codes may be of any length
+	for _, re := range custom {
+		for _, code := range MatchCustomPattern(re, text) {
+			if seen[code] {
+				continue
+			}
+			seen[code] = true
+			codes = append(codes, models.DetectedCode{
+				Type:  CodeTypeCustom,
+				Value: code,
+			})
+		}
+	}
+
 	for _, pattern := range d.patterns {
 		matches := pattern.Regex.FindAllStringSubmatch(text, -1)
 		for _, match := range matches {
@@ -80,3 +108,37 @@ func (d *CodeDetector) DetectCodes(text string) []models.DetectedCode {
 
 	return codes
 }
+
+// CompileCustomPattern validates a per-account code pattern. The code is the
+// first capture group, or the whole match if the pattern has none.
+func CompileCustomPattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, errors.New("empty pattern")
+	}
+	if len(pattern) > MaxCustomPatternLen {
+		return nil, fmt.Errorf("pattern is longer than %d characters", MaxCustomPatternLen)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if re.MatchString("") {
+		return nil, errors.New("pattern matches empty text")
+	}
+	return re, nil
+}
+
+// MatchCustomPattern returns the codes a per-account pattern finds in text
+func MatchCustomPattern(re *regexp.Regexp, text string) []string {
+	var codes []string
+	for _, match := range re.FindAllStringSubmatch(text, -1) {
+		code := match[0]
+		if len(match) > 1 {
+			code = match[1]
+		}
+		if code = strings.TrimSpace(code); code != "" {
+			codes = append(codes, code)
+		}
+	}
+	return codes
+}
@@ -0,0 +1,154 @@
+package parser
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/mixelka/emailresend/pkg/models"
+)
+
+// ExtractInput is the email an extractor works on
+type ExtractInput struct {
+	FromAddr string
+	FromName string
+	Subject  string
+	Text     string // Parsed body text
+	HTML     string // Original HTML body (may be empty)
+}
+
+// Extractor pulls codes, links and structured fields out of emails of
+// specific senders, where the generic CodeDetector patterns are unreliable
+type Extractor interface {
+	// Name is the stable identifier used in per-chat settings
+	Name() string
+	// Domains lists sender domains handled by the extractor; subdomains match too
+	Domains() []string
+	// Extract returns nil if the email is not recognized
+	Extract(in ExtractInput) *models.Extraction
+}
+
+// Registry maps sender domains to extractors
+type Registry struct {
+	extractors []Extractor
+	byDomain   map[string]Extractor
+}
+
+// NewRegistry creates a registry with the given extractors
+func NewRegistry(extractors ...Extractor) *Registry {
+	r := &Registry{byDomain: make(map[string]Extractor)}
+	for _, e := range extractors {
+		r.Register(e)
+	}
+	return r
+}
+
+// NewDefaultRegistry creates a registry with the built-in extractors
+func NewDefaultRegistry() *Registry {
+	return NewRegistry(
+		newSteamExtractor(),
+		newGoogleExtractor(),
+		newBankExtractor(),
+	)
+}
+
+// Register adds an extractor. Domains already claimed by another extractor
+// are taken over by the new one.
+func (r *Registry) Register(e Extractor) {
+	r.extractors = append(r.extractors, e)
+	for _, domain := range e.Domains() {
+		r.byDomain[strings.ToLower(domain)] = e
+	}
+}
+
+// List returns all registered extractors sorted by name
+func (r *Registry) List() []Extractor {
+	list := slices.Clone(r.extractors)
+	slices.SortFunc(list, func(a, b Extractor) int { return strings.Compare(a.Name(), b.Name()) })
+	return list
+}
+
+// Has reports whether an extractor with the name is registered
+func (r *Registry) Has(name string) bool {
+	for _, e := range r.extractors {
+		if e.Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Find returns the extractor for a sender address, matching the domain and
+// its parent domains (noreply@accounts.google.com matches google.com)
+func (r *Registry) Find(fromAddr string) Extractor {
+	_, domain, found := strings.Cut(strings.ToLower(fromAddr), "@")
+	if !found {
+		return nil
+	}
+
+	for domain != "" {
+		if e, ok := r.byDomain[domain]; ok {
+			return e
+		}
+		_, parent, found := strings.Cut(domain, ".")
+		if !found {
+			break
+		}
+		domain = parent
+	}
+	return nil
+}
+
+// Extract runs the extractor for the sender unless it is disabled.
+// Returns nil if no extractor recognized the email.
+func (r *Registry) Extract(in ExtractInput, enabled func(name string) bool) *models.Extraction {
+	e := r.Find(in.FromAddr)
+	if e == nil || (enabled != nil && !enabled(e.Name())) {
+		return nil
+	}
+
+	result := e.Extract(in)
+	if result == nil {
+		return nil
+	}
+	result.Extractor = e.Name()
+	return result
+}
+
+var linkRegex = regexp.MustCompile(`(?is)<a\s[^>]*href\s*=\s*["']([^"']+)["'][^>]*>(.*?)</a>`)
+var tagRegex = regexp.MustCompile(`(?s)<[^>]*>`)
+
+// findLinks returns HTML links whose URL contains one of the markers, with
+// the anchor text as the title
+func findLinks(html string, markers ...string) []models.ExtractedLink {
+	var links []models.ExtractedLink
+	seen := make(map[string]bool)
+
+	for _, match := range linkRegex.FindAllStringSubmatch(html, -1) {
+		href := strings.ReplaceAll(strings.TrimSpace(match[1]), "&amp;", "&")
+		if seen[href] || !strings.HasPrefix(href, "https://") {
+			continue
+		}
+
+		for _, marker := range markers {
+			if strings.Contains(href, marker) {
+				title := strings.Join(strings.Fields(tagRegex.ReplaceAllString(match[2], "")), " ")
+				if title == "" {
+					title = "Ссылка"
+				}
+				seen[href] = true
+				links = append(links, models.ExtractedLink{Title: title, URL: href})
+				break
+			}
+		}
+	}
+	return links
+}
+
+// firstMatch returns the first capture group of re in text
+func firstMatch(re *regexp.Regexp, text string) string {
+	if match := re.FindStringSubmatch(text); len(match) > 1 {
+		return strings.TrimSpace(match[1])
+	}
+	return ""
+}
@@ -0,0 +1,122 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/mixelka/emailresend/pkg/models"
+)
+
+// steamExtractor handles Steam Guard emails
+type steamExtractor struct {
+	code    *regexp.Regexp
+	account *regexp.Regexp
+}
+
+func newSteamExtractor() *steamExtractor {
+	return &steamExtractor{
+		// Steam Guard codes are 5 characters on their own line
+		code:    regexp.MustCompile(`(?m)^\s*([A-Z0-9]{5})\s*$`),
+		account: regexp.MustCompile(`(?i)(?:dear|уважаемый|здравствуйте,?)\s+([A-Za-z0-9_\-]{3,32})`),
+	}
+}
+
+func (e *steamExtractor) Name() string { return "steam" }
+func (e *steamExtractor) Domains() []string {
+	return []string{"steampowered.com", "steamcommunity.com"}
+}
+
+func (e *steamExtractor) Extract(in ExtractInput) *models.Extraction {
+	result := &models.Extraction{}
+	if code := firstMatch(e.code, in.Text); code != "" {
+		result.Codes = []models.DetectedCode{{Type: "steam_guard", Value: code}}
+	}
+	if account := firstMatch(e.account, in.Text); account != "" {
+		result.Fields = append(result.Fields, models.ExtractedField{Name: "Аккаунт", Value: account})
+	}
+	result.Links = findLinks(in.HTML, "steampowered.com/login", "steampowered.com/account", "steamcommunity.com/login")
+
+	if result.Empty() {
+		return nil
+	}
+	return result
+}
+
+// googleExtractor handles Google account verification and security alerts
+type googleExtractor struct {
+	codes []*regexp.Regexp
+	email *regexp.Regexp
+}
+
+func newGoogleExtractor() *googleExtractor {
+	return &googleExtractor{
+		codes: []*regexp.Regexp{
+			regexp.MustCompile(`\bG-(\d{6})\b`),
+			regexp.MustCompile(`(?m)^\s*(\d{6})\s*$`),
+		},
+		email: regexp.MustCompile(`([A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,})`),
+	}
+}
+
+func (e *googleExtractor) Name() string      { return "google" }
+func (e *googleExtractor) Domains() []string { return []string{"google.com"} }
+
+func (e *googleExtractor) Extract(in ExtractInput) *models.Extraction {
+	result := &models.Extraction{}
+	for _, re := range e.codes {
+		if code := firstMatch(re, in.Text); code != "" {
+			result.Codes = []models.DetectedCode{{Type: "google", Value: code}}
+			break
+		}
+	}
+	for _, addr := range e.email.FindAllString(in.Text, -1) {
+		if !strings.HasSuffix(strings.ToLower(addr), "google.com") {
+			result.Fields = append(result.Fields, models.ExtractedField{Name: "Аккаунт", Value: addr})
+			break
+		}
+	}
+	result.Links = findLinks(in.HTML, "myaccount.google.com", "accounts.google.com")
+
+	if result.Empty() {
+		return nil
+	}
+	return result
+}
+
+// bankExtractor handles transaction and confirmation emails of Russian banks
+type bankExtractor struct {
+	code   *regexp.Regexp
+	amount *regexp.Regexp
+	card   *regexp.Regexp
+}
+
+func newBankExtractor() *bankExtractor {
+	return &bankExtractor{
+		code:   regexp.MustCompile(`(?i)(?:код|code)[^\d\n]{0,30}(\d{4,6})\b`),
+		amount: regexp.MustCompile(`(?i)(\d[\d\s\x{00A0}]*(?:[.,]\d{1,2})?)\s?(?:₽|руб\.?|rub)`),
+		card:   regexp.MustCompile(`(?:\*|•|карт[аеуы]\s+)(\d{4})\b`),
+	}
+}
+
+func (e *bankExtractor) Name() string { return "bank" }
+func (e *bankExtractor) Domains() []string {
+	return []string{"tinkoff.ru", "tbank.ru", "sberbank.ru", "vtb.ru", "alfabank.ru", "raiffeisen.ru", "gazprombank.ru"}
+}
+
+func (e *bankExtractor) Extract(in ExtractInput) *models.Extraction {
+	result := &models.Extraction{}
+	if code := firstMatch(e.code, in.Text); code != "" {
+		result.Codes = []models.DetectedCode{{Type: "bank", Value: code}}
+	}
+	if amount := firstMatch(e.amount, in.Text); amount != "" {
+		result.Fields = append(result.Fields, models.ExtractedField{Name: "Сумма", Value: amount + " ₽"})
+	}
+	if card := firstMatch(e.card, in.Text); card != "" {
+		result.Fields = append(result.Fields, models.ExtractedField{Name: "Карта", Value: "*" + card})
+	}
+
+	if result.Empty() {
+		return nil
+	}
+	return result
+}
@@ -0,0 +1,77 @@
+package parser
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// minImageSize is the smallest declared width or height of an image worth
+// showing; smaller ones are spacers and tracking pixels
+const minImageSize = 16
+
+// Image is an image referenced by an HTML body
+type Image struct {
+	ContentID string // set for cid: sources
+	URL       string // set for http(s) sources
+}
+
+// Images returns the images of an HTML body in document order, without
+// duplicates, tracking pixels and data: URIs
+func Images(body string) []Image {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(body))
+	if err != nil {
+		return nil
+	}
+
+	var images []Image
+	seen := make(map[string]bool)
+	doc.Find("img[src]").Each(func(i int, s *goquery.Selection) {
+		if tinyImage(s) {
+			return
+		}
+
+		src, _ := s.Attr("src")
+		src = strings.TrimSpace(src)
+		if seen[src] {
+			return
+		}
+
+		var image Image
+		if id, ok := strings.CutPrefix(src, "cid:"); ok {
+			// RFC 2392 allows URL-encoded IDs
+			if unescaped, err := url.PathUnescape(id); err == nil {
+				id = unescaped
+			}
+			image.ContentID = id
+		} else if u, err := url.Parse(src); err == nil && (u.Scheme == "https" || u.Scheme == "http") && u.Host != "" {
+			image.URL = u.String()
+		} else {
+			return
+		}
+
+		seen[src] = true
+		images = append(images, image)
+	})
+	return images
+}
+
+// tinyImage reports whether an img is declared smaller than minImageSize or
+// hidden with inline styles
+func tinyImage(s *goquery.Selection) bool {
+	for _, attr := range []string{"width", "height"} {
+		value, ok := s.Attr(attr)
+		if !ok {
+			continue
+		}
+		if n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(value), "px")); err == nil && n < minImageSize {
+			return true
+		}
+	}
+
+	style, _ := s.Attr("style")
+	style = strings.ReplaceAll(strings.ToLower(style), " ", "")
+	return strings.Contains(style, "display:none") || strings.Contains(style, "visibility:hidden")
+}
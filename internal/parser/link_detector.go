@@ -0,0 +1,87 @@
+package parser
+
+import (
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/mixelka/emailresend/pkg/models"
+)
+
+// maxActionLinks limits the action buttons taken from one email
+const maxActionLinks = 2
+
+// actionKind is a kind of action link with the patterns recognizing it in the
+// anchor text and in the URL
+type actionKind struct {
+	Kind string
+	Text *regexp.Regexp
+	Href *regexp.Regexp
+}
+
+// actionKinds are checked in order, so "confirm password reset" is a reset
+var actionKinds = []actionKind{
+	{
+		Kind: models.ActionReset,
+		Text: regexp.MustCompile(`(?i)reset|recover|new password|сбро[сш]|восстанов|нов\S* пароль`),
+		Href: regexp.MustCompile(`(?i)reset|recover|forgot`),
+	},
+	{
+		Kind: models.ActionLogin,
+		Text: regexp.MustCompile(`(?i)magic link|sign\s?in|log\s?in|войти|вход`),
+		Href: regexp.MustCompile(`(?i)magic|/sign-?in|/log-?in|/auth/|login[_-]?token`),
+	},
+	{
+		Kind: models.ActionConfirm,
+		Text: regexp.MustCompile(`(?i)confirm|verif|activat|validat|подтвер|активир`),
+		Href: regexp.MustCompile(`(?i)confirm|verif|activat|validat`),
+	},
+}
+
+// notActionRegex rejects links that only look like actions, e.g. confirming
+// an unsubscription
+var notActionRegex = regexp.MustCompile(`(?i)unsubscribe|opt-?out|отпис|preferences|privacy|конфиденц`)
+
+// DetectActionLinks finds confirmation, magic login and password reset links
+// in an HTML body: anchors whose text or URL matches an action. Matches by
+// anchor text come before matches by URL alone.
+func DetectActionLinks(body string) []models.ActionLink {
+	var byText, byHref []models.ActionLink
+	seen := make(map[string]bool)
+
+	for _, match := range linkRegex.FindAllStringSubmatch(body, -1) {
+		href := html.UnescapeString(strings.TrimSpace(match[1]))
+		if seen[href] || !strings.HasPrefix(href, "https://") {
+			continue
+		}
+		text := strings.Join(strings.Fields(html.UnescapeString(tagRegex.ReplaceAllString(match[2], ""))), " ")
+		if notActionRegex.MatchString(text) || notActionRegex.MatchString(href) {
+			continue
+		}
+
+		if kind := matchActionKind(text, func(k actionKind) *regexp.Regexp { return k.Text }); kind != "" {
+			seen[href] = true
+			byText = append(byText, models.ActionLink{Kind: kind, Title: text, URL: href})
+		} else if kind := matchActionKind(href, func(k actionKind) *regexp.Regexp { return k.Href }); kind != "" {
+			seen[href] = true
+			byHref = append(byHref, models.ActionLink{Kind: kind, Title: text, URL: href})
+		}
+	}
+
+	links := append(byText, byHref...)
+	if len(links) > maxActionLinks {
+		links = links[:maxActionLinks]
+	}
+	return links
+}
+
+// matchActionKind returns the first action kind whose pattern, picked by
+// field, matches s, "" if none
+func matchActionKind(s string, field func(actionKind) *regexp.Regexp) string {
+	for _, k := range actionKinds {
+		if field(k).MatchString(s) {
+			return k.Kind
+		}
+	}
+	return ""
+}
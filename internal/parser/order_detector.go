@@ -0,0 +1,112 @@
+package parser
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mixelka/emailresend/pkg/models"
+)
+
+// OrderDetector extracts order details from commerce emails
+type OrderDetector struct {
+	number       *regexp.Regexp
+	amount       *regexp.Regexp
+	amountPrefix *regexp.Regexp
+}
+
+// NewOrderDetector creates a new order detector
+func NewOrderDetector() *OrderDetector {
+	return &OrderDetector{
+		// "Заказ №12345-678", "Order #A1B2C3", "номер заказа: 12345"
+		number: regexp.MustCompile(`(?i)(?:заказ[аеу]?|order)\s*(?:№|#|no\.?|number|номер)?\s*[:\-]?\s*#?\s*([A-Z0-9][A-Z0-9\-]{3,24})\b`),
+		// "Итого: 1 234,56 ₽", "Total 12.99 USD"
+		amount: regexp.MustCompile(`(?i)(?:итого|сумма|к оплате|оплачено|стоимость|total|amount|charged)[^\d\n]{0,20}(\d[\d \x{00A0},]*(?:\.\d{1,2})?)\s?(₽|руб\.?|р\.|rub|usd|\$|eur|€|kzt|₸)?`),
+		// "Total: $12.99"
+		amountPrefix: regexp.MustCompile(`(?i)(?:итого|сумма|total|amount|charged)[^\d\n$€]{0,20}(\$|€|usd|eur)\s?(\d[\d,]*(?:\.\d{1,2})?)`),
+	}
+}
+
+// DetectOrder returns the order described by an email or nil if it does not
+// look like an order confirmation. The merchant is taken from the sender name
+// or, if absent, the sender domain.
+func (d *OrderDetector) DetectOrder(in ExtractInput) *models.Order {
+	number := ""
+	for _, text := range []string{in.Subject, in.Text} {
+		for _, match := range d.number.FindAllStringSubmatch(text, -1) {
+			if strings.ContainsAny(match[1], "0123456789") {
+				number = strings.ToUpper(match[1])
+				break
+			}
+		}
+		if number != "" {
+			break
+		}
+	}
+	if number == "" {
+		return nil
+	}
+
+	order := &models.Order{
+		Number:   number,
+		Merchant: merchantName(in.FromName, in.FromAddr),
+	}
+
+	if match := d.amountPrefix.FindStringSubmatch(in.Text); match != nil {
+		order.Amount, _ = parseAmount(match[2])
+		order.Currency = currencyCode(match[1])
+	} else if match := d.amount.FindStringSubmatch(in.Text); match != nil {
+		order.Amount, _ = parseAmount(match[1])
+		order.Currency = currencyCode(match[2])
+	}
+	return order
+}
+
+// merchantName picks a display name for the seller
+func merchantName(fromName, fromAddr string) string {
+	if name := strings.TrimSpace(fromName); name != "" {
+		return name
+	}
+	_, domain, _ := strings.Cut(strings.ToLower(fromAddr), "@")
+	parts := strings.Split(domain, ".")
+	if len(parts) >= 2 {
+		return parts[len(parts)-2]
+	}
+	return domain
+}
+
+// parseAmount converts "1 234,56" or "1,234.56" to minor units
+func parseAmount(s string) (int64, bool) {
+	s = strings.NewReplacer(" ", "", " ", "").Replace(s)
+
+	// A comma is the decimal separator only if followed by 1-2 digits at the end
+	if i := strings.LastIndex(s, ","); i >= 0 {
+		if !strings.Contains(s, ".") && len(s)-i-1 <= 2 {
+			s = s[:i] + "." + s[i+1:]
+		}
+		s = strings.ReplaceAll(s, ",", "")
+	}
+
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil || value <= 0 {
+		return 0, false
+	}
+	return int64(math.Round(value * 100)), true
+}
+
+// currencyCode normalizes a currency symbol or name to an ISO code
+func currencyCode(s string) string {
+	switch strings.ToLower(strings.TrimSuffix(s, ".")) {
+	case "₽", "руб", "р", "rub":
+		return "RUB"
+	case "$", "usd":
+		return "USD"
+	case "€", "eur":
+		return "EUR"
+	case "₸", "kzt":
+		return "KZT"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,159 @@
+package parser
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// sanitizePolicy is a Content-Security-Policy added to sanitized documents as
+// a second line of defense when they are opened in a browser
+const sanitizePolicy = "default-src 'none'; img-src https: data: cid:; style-src 'unsafe-inline'; form-action 'none'; base-uri 'none'"
+
+// removedElements are dropped with their content
+const removedElements = "script, noscript, iframe, frame, frameset, object, embed, applet, base, meta, link, svg, math, template, portal, input, button, select, textarea, option"
+
+// urlAttributes hold URLs that are checked against the allowed schemes
+var urlAttributes = map[string]bool{
+	"href":       true,
+	"src":        true,
+	"action":     true,
+	"formaction": true,
+	"background": true,
+	"poster":     true,
+	"cite":       true,
+	"longdesc":   true,
+	"lowsrc":     true,
+	"dynsrc":     true,
+	"xlink:href": true,
+}
+
+// removedAttributes are dropped from every element
+var removedAttributes = map[string]bool{
+	"srcdoc":     true,
+	"srcset":     true,
+	"formaction": true,
+	"action":     true,
+	"ping":       true,
+}
+
+// SanitizeHTML returns a standalone document with everything that can run
+// code, submit data or load another page on its own removed: scripts and
+// embedded objects, forms and their fields, event handler attributes, meta
+// refreshes and URLs with schemes other than http, https, mailto, tel and
+// cid (plus data: images). Links open in a new tab without a referrer.
+func SanitizeHTML(body string) (string, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+
+	doc.Find(removedElements).Remove()
+
+	// Keep the content of forms, but nothing is submitted anywhere
+	doc.Find("form").Each(func(i int, s *goquery.Selection) {
+		s.Contents().Unwrap()
+	})
+
+	// Style sheets may import or call out to other resources
+	doc.Find("style").Each(func(i int, s *goquery.Selection) {
+		if unsafeCSS(s.Text()) {
+			s.Remove()
+		}
+	})
+
+	doc.Find("*").Each(func(i int, s *goquery.Selection) {
+		for _, node := range s.Nodes {
+			kept := node.Attr[:0]
+			for _, attr := range node.Attr {
+				key := strings.ToLower(attr.Key)
+				switch {
+				case strings.HasPrefix(key, "on"), removedAttributes[key]:
+					continue
+				case urlAttributes[key] && !safeURL(attr.Val, node.Data == "img"):
+					continue
+				case key == "style" && unsafeCSS(attr.Val):
+					continue
+				}
+				kept = append(kept, attr)
+			}
+			node.Attr = kept
+		}
+	})
+
+	doc.Find("a[href]").SetAttr("target", "_blank")
+	doc.Find("a[href]").SetAttr("rel", "noopener noreferrer nofollow")
+
+	head := doc.Find("head")
+	head.PrependHtml(`<meta charset="utf-8"><meta http-equiv="Content-Security-Policy" content="` + sanitizePolicy + `"><meta name="referrer" content="no-referrer">`)
+
+	return doc.Html()
+}
+
+// EmbedImages replaces cid: image sources with the data: URIs returned by
+// image for their Content-ID, so the document renders without the email's
+// other parts. Sources image knows nothing about are left as they are.
+func EmbedImages(body string, image func(contentID string) (string, bool)) (string, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+
+	doc.Find("img[src]").Each(func(i int, s *goquery.Selection) {
+		src, _ := s.Attr("src")
+		id, ok := strings.CutPrefix(strings.TrimSpace(src), "cid:")
+		if !ok {
+			return
+		}
+		// RFC 2392 allows URL-encoded IDs
+		if unescaped, err := url.PathUnescape(id); err == nil {
+			id = unescaped
+		}
+		if uri, ok := image(id); ok {
+			s.SetAttr("src", uri)
+		}
+	})
+
+	return doc.Html()
+}
+
+// safeURL reports whether a URL uses an allowed scheme. Relative URLs are
+// allowed since the document has no base to resolve them against.
+func safeURL(raw string, image bool) bool {
+	// Browsers ignore whitespace and control characters inside the scheme
+	u := strings.Map(func(r rune) rune {
+		if r <= ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(raw))
+
+	scheme, _, ok := strings.Cut(u, ":")
+	if !ok || strings.ContainsAny(scheme, "/?#") {
+		return true
+	}
+	switch scheme {
+	case "http", "https", "mailto", "tel", "cid":
+		return true
+	case "data":
+		return image && strings.HasPrefix(u, "data:image/") && !strings.HasPrefix(u, "data:image/svg")
+	default:
+		return false
+	}
+}
+
+// cssComment matches comments, which may split keywords ("expr/**/ession")
+var cssComment = regexp.MustCompile(`(?s)/\*.*?\*/`)
+
+// unsafeCSS reports whether CSS could run script or load other resources
+func unsafeCSS(css string) bool {
+	css = strings.ToLower(cssComment.ReplaceAllString(strings.ReplaceAll(css, "\\", ""), ""))
+	for _, s := range []string{"javascript:", "expression(", "@import", "behavior:", "-moz-binding", "vbscript:"} {
+		if strings.Contains(css, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package parser
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/mixelka/emailresend/pkg/models"
+)
+
+// maxTrackingNumbers limits tracking numbers taken from one email
+const maxTrackingNumbers = 3
+
+// TrackingDetector detects parcel tracking numbers of known carriers
+type TrackingDetector struct {
+	carriers []*carrier
+}
+
+type carrier struct {
+	Name  string
+	Regex *regexp.Regexp // first capture group is the tracking number
+	URL   string         // tracking page, %s is replaced with the number
+}
+
+// NewTrackingDetector creates a new tracking number detector
+func NewTrackingDetector() *TrackingDetector {
+	return &TrackingDetector{
+		carriers: []*carrier{
+			{
+				Name:  "UPS",
+				Regex: regexp.MustCompile(`\b(1Z[0-9A-Z]{16})\b`),
+				URL:   "https://www.ups.com/track?tracknum=%s",
+			},
+			// Russian Post: international S10 numbers and 14-digit domestic ones near a keyword
+			{
+				Name:  "Почта России",
+				Regex: regexp.MustCompile(`\b([A-Z]{2}\d{9}RU)\b`),
+				URL:   "https://www.pochta.ru/tracking?barcode=%s",
+			},
+			{
+				Name:  "Почта России",
+				Regex: regexp.MustCompile(`(?i)(?:почт[аыу] росси|трек|рпо)[^\n]{0,40}?\b(\d{14})\b`),
+				URL:   "https://www.pochta.ru/tracking?barcode=%s",
+			},
+			{
+				Name:  "USPS",
+				Regex: regexp.MustCompile(`\b(9[2-5]\d{18,20}|[A-Z]{2}\d{9}US)\b`),
+				URL:   "https://tools.usps.com/go/TrackConfirmAction?tLabels=%s",
+			},
+			// CDEK and DHL Express numbers are plain 10 digits, so require the carrier name nearby
+			{
+				Name:  "СДЭК",
+				Regex: regexp.MustCompile(`(?i)(?:сдэк|cdek)[^\n]{0,60}?\b(\d{10})\b`),
+				URL:   "https://www.cdek.ru/ru/tracking?order_id=%s",
+			},
+			{
+				Name:  "DHL",
+				Regex: regexp.MustCompile(`(?i)dhl[^\n]{0,60}?\b(\d{10}|JJD\d{18}|JVGL\d{8,16})\b`),
+				URL:   "https://www.dhl.com/global-en/home/tracking.html?tracking-id=%s",
+			},
+		},
+	}
+}
+
+// DetectTracking finds tracking numbers in text
+func (d *TrackingDetector) DetectTracking(text string) []models.TrackingNumber {
+	var numbers []models.TrackingNumber
+	seen := make(map[string]bool)
+
+	for _, c := range d.carriers {
+		for _, match := range c.Regex.FindAllStringSubmatch(text, -1) {
+			number := strings.ToUpper(match[1])
+			if seen[number] {
+				continue
+			}
+			seen[number] = true
+			numbers = append(numbers, models.TrackingNumber{
+				Carrier: c.Name,
+				Number:  number,
+				URL:     strings.Replace(c.URL, "%s", url.QueryEscape(number), 1),
+			})
+			if len(numbers) == maxTrackingNumbers {
+				return numbers
+			}
+		}
+	}
+	return numbers
+}
@@ -0,0 +1,88 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"net/textproto"
+	"net/url"
+	"strings"
+
+	"github.com/mixelka/emailresend/pkg/models"
+)
+
+// ParseListUnsubscribe returns the mailto: and https: unsubscribe URIs of a
+// raw RFC822 message from its List-Unsubscribe header, nil if there are none.
+// The URL supports one-click unsubscription if List-Unsubscribe-Post says so
+// (RFC 8058).
+func ParseListUnsubscribe(raw []byte) *models.Unsubscribe {
+	if len(raw) == 0 {
+		return nil
+	}
+	header, err := textproto.NewReader(bufio.NewReader(bytes.NewReader(raw))).ReadMIMEHeader()
+	if err != nil && len(header) == 0 {
+		return nil
+	}
+
+	var u models.Unsubscribe
+	for _, value := range header.Values("List-Unsubscribe") {
+		// URIs are in angle brackets, separated by commas
+		for _, part := range strings.Split(value, ",") {
+			start, end := strings.IndexByte(part, '<'), strings.LastIndexByte(part, '>')
+			if start < 0 || end <= start {
+				continue
+			}
+			uri := strings.Join(strings.Fields(part[start+1:end]), "")
+			parsed, err := url.Parse(uri)
+			if err != nil {
+				continue
+			}
+			switch strings.ToLower(parsed.Scheme) {
+			case "mailto":
+				if u.Mailto == "" && parsed.Opaque != "" {
+					u.Mailto = uri
+				}
+			case "https":
+				if u.URL == "" && parsed.Host != "" {
+					u.URL = uri
+				}
+			}
+		}
+	}
+	if u.Mailto == "" && u.URL == "" {
+		return nil
+	}
+
+	post := strings.ToLower(strings.Join(strings.Fields(header.Get("List-Unsubscribe-Post")), ""))
+	u.OneClick = u.URL != "" && post == "list-unsubscribe=one-click"
+	return &u
+}
+
+// MailtoUnsubscribe splits a mailto: URI into the address, subject and body
+// of the unsubscribe email. Missing subject and body default to "unsubscribe".
+func MailtoUnsubscribe(uri string) (to, subject, body string, ok bool) {
+	parsed, err := url.Parse(uri)
+	if err != nil || !strings.EqualFold(parsed.Scheme, "mailto") || parsed.Opaque == "" {
+		return "", "", "", false
+	}
+	to, err = url.PathUnescape(parsed.Opaque)
+	if err != nil {
+		return "", "", "", false
+	}
+
+	// Header names of mailto: URIs are case-insensitive (RFC 6068)
+	for key, values := range parsed.Query() {
+		switch strings.ToLower(key) {
+		case "subject":
+			subject = values[0]
+		case "body":
+			body = values[0]
+		}
+	}
+	if subject == "" {
+		subject = "unsubscribe"
+	}
+	if body == "" {
+		body = "unsubscribe"
+	}
+	return to, subject, body, true
+}
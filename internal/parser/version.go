@@ -0,0 +1,6 @@
+package parser
+
+// Version identifies the current HTML parsing and code detection rules. It is
+// stored with every message; bump it whenever the output of HTMLParser or
+// CodeDetector changes so that outdated messages can be re-parsed.
+const Version = 5
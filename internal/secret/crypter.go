@@ -0,0 +1,123 @@
+package secret
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// requestTimeout bounds a request to a remote key service
+const requestTimeout = 10 * time.Second
+
+// Crypter encrypts stored mailbox passwords and OAuth tokens
+type Crypter interface {
+	Encrypt(plaintext string) (string, error)
+	Decrypt(encrypted string) (string, error)
+}
+
+// remote is a Crypter whose key lives in an external service. Its values
+// carry a prefix, so they can be told apart from values of a local key.
+type remote interface {
+	Crypter
+	Owns(encrypted string) bool
+}
+
+// Backends of Config
+const (
+	BackendLocal = "local" // AES-256-GCM with Config.Key
+	BackendVault = "vault" // HashiCorp Vault transit engine
+	BackendKMS   = "kms"   // AWS KMS
+)
+
+// Config selects where the encryption key lives
+type Config struct {
+	Backend string // BackendLocal (default), BackendVault or BackendKMS
+
+	// Key is the local 32-byte key. With a remote backend it still decrypts
+	// values stored before the switch, see Upgrade.
+	Key string
+
+	// Vault transit backend
+	VaultAddr  string // e.g., https://vault.example.com:8200
+	VaultToken string
+	VaultMount string // mount path of the transit engine (default transit)
+	VaultKey   string // name of the transit key
+
+	// AWS KMS backend
+	KMSKeyID        string // key ID, ARN or alias/...
+	KMSRegion       string
+	KMSEndpoint     string // default https://kms.<region>.amazonaws.com
+	KMSAccessKey    string
+	KMSSecretKey    string
+	KMSSessionToken string // for temporary credentials
+}
+
+// New creates the Crypter of the configured backend
+func New(cfg Config) (Crypter, error) {
+	var backend remote
+	switch cfg.Backend {
+	case "", BackendLocal:
+		if _, err := newGCM(cfg.Key); err != nil {
+			return nil, err
+		}
+		return NewKey(cfg.Key), nil
+	case BackendVault:
+		vault, err := NewVault(cfg)
+		if err != nil {
+			return nil, err
+		}
+		backend = vault
+	case BackendKMS:
+		kms, err := NewKMS(cfg)
+		if err != nil {
+			return nil, err
+		}
+		backend = kms
+	default:
+		return nil, fmt.Errorf("unknown secrets backend %q", cfg.Backend)
+	}
+
+	if cfg.Key == "" {
+		return backend, nil
+	}
+	if _, err := newGCM(cfg.Key); err != nil {
+		return nil, err
+	}
+	return &withLocalKey{remote: backend, key: cfg.Key}, nil
+}
+
+// withLocalKey is a remote Crypter that still decrypts values encrypted with
+// the local key before the backend was switched
+type withLocalKey struct {
+	remote
+	key string
+}
+
+// Decrypt decrypts a value of the backend or of the local key
+func (c *withLocalKey) Decrypt(encrypted string) (string, error) {
+	if c.Owns(encrypted) {
+		return c.remote.Decrypt(encrypted)
+	}
+	return Decrypt(c.key, encrypted)
+}
+
+// Upgrade re-encrypts a value stored with the local key with the remote
+// backend of c; values already encrypted by the backend are returned as is
+func Upgrade(c Crypter, encrypted string) (string, error) {
+	r, ok := c.(remote)
+	if !ok {
+		return "", fmt.Errorf("secrets are stored with the local key, nothing to migrate")
+	}
+	if r.Owns(encrypted) {
+		return encrypted, nil
+	}
+	plaintext, err := r.Decrypt(encrypted)
+	if err != nil {
+		return "", err
+	}
+	return r.Encrypt(plaintext)
+}
+
+func newHTTPClient() *http.Client {
+	return &http.Client{Timeout: requestTimeout}
+}
@@ -0,0 +1,196 @@
+package secret
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// kmsPrefix marks values encrypted by KMS, followed by the base64 blob
+const kmsPrefix = "kms:"
+
+// KMS encrypts secrets with an AWS KMS key through the JSON API (signature
+// V4); the key never leaves KMS
+type KMS struct {
+	endpoint     *url.URL
+	keyID        string
+	region       string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	httpClient   *http.Client
+}
+
+// NewKMS creates an AWS KMS Crypter
+func NewKMS(cfg Config) (*KMS, error) {
+	if cfg.KMSKeyID == "" || cfg.KMSRegion == "" || cfg.KMSAccessKey == "" || cfg.KMSSecretKey == "" {
+		return nil, fmt.Errorf("KMS key ID, region and credentials are required")
+	}
+
+	raw := cfg.KMSEndpoint
+	if raw == "" {
+		raw = "https://kms." + cfg.KMSRegion + ".amazonaws.com"
+	}
+	endpoint, err := url.Parse(strings.TrimRight(raw, "/"))
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid KMS endpoint %q", raw)
+	}
+
+	return &KMS{
+		endpoint:     endpoint,
+		keyID:        cfg.KMSKeyID,
+		region:       cfg.KMSRegion,
+		accessKey:    cfg.KMSAccessKey,
+		secretKey:    cfg.KMSSecretKey,
+		sessionToken: cfg.KMSSessionToken,
+		httpClient:   newHTTPClient(),
+	}, nil
+}
+
+// Encrypt encrypts a value with the KMS key
+func (k *KMS) Encrypt(plaintext string) (string, error) {
+	var resp struct {
+		CiphertextBlob string `json:"CiphertextBlob"`
+	}
+	body := map[string]string{
+		"KeyId":     k.keyID,
+		"Plaintext": base64.StdEncoding.EncodeToString([]byte(plaintext)),
+	}
+	if err := k.do("Encrypt", body, &resp); err != nil {
+		return "", err
+	}
+	return kmsPrefix + resp.CiphertextBlob, nil
+}
+
+// Decrypt decrypts a value produced by Encrypt
+func (k *KMS) Decrypt(encrypted string) (string, error) {
+	blob, ok := strings.CutPrefix(encrypted, kmsPrefix)
+	if !ok {
+		return "", fmt.Errorf("not a KMS ciphertext")
+	}
+
+	var resp struct {
+		Plaintext string `json:"Plaintext"`
+	}
+	if err := k.do("Decrypt", map[string]string{"KeyId": k.keyID, "CiphertextBlob": blob}, &resp); err != nil {
+		return "", err
+	}
+	plaintext, err := base64.StdEncoding.DecodeString(resp.Plaintext)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// Owns reports whether a value was encrypted by KMS
+func (k *KMS) Owns(encrypted string) bool {
+	return strings.HasPrefix(encrypted, kmsPrefix)
+}
+
+// do calls a KMS action
+func (k *KMS) do(action string, body, out any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, k.endpoint.String()+"/", bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "TrentService."+action)
+	k.sign(req, data, time.Now().UTC())
+
+	resp, err := k.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach KMS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var kmsErr struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		if json.Unmarshal(msg, &kmsErr) == nil && kmsErr.Type != "" {
+			msg = []byte(strings.TrimSpace(kmsErr.Type + " " + kmsErr.Message))
+		}
+		return fmt.Errorf("KMS %s returned %d: %s", action, resp.StatusCode, msg)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode KMS response: %w", err)
+	}
+	return nil
+}
+
+// sign adds an AWS signature V4 Authorization header
+func (k *KMS) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if k.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", k.sessionToken)
+	}
+
+	signedHeaders := "content-type;host;x-amz-date"
+	canonicalHeaders := "content-type:" + req.Header.Get("Content-Type") + "\n" +
+		"host:" + req.URL.Host + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+	if k.sessionToken != "" {
+		signedHeaders += ";x-amz-security-token"
+		canonicalHeaders += "x-amz-security-token:" + k.sessionToken + "\n"
+	}
+	signedHeaders += ";x-amz-target"
+	canonicalHeaders += "x-amz-target:" + req.Header.Get("X-Amz-Target") + "\n"
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		"/",
+		"",
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + k.region + "/kms/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+k.secretKey), date)
+	signingKey = hmacSHA256(signingKey, k.region)
+	signingKey = hmacSHA256(signingKey, "kms")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		k.accessKey, scope, signedHeaders, signature))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,128 @@
+// Package secret encrypts stored mailbox passwords with AES-256-GCM
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Encrypt encrypts a value with a 32-byte key, returning base64(nonce|ciphertext)
+func Encrypt(key, plaintext string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	ciphertext := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// Decrypt decrypts a value produced by Encrypt
+func Decrypt(key, encrypted string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode: %w", err)
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	if len(data) < gcm.NonceSize() {
+		return "", fmt.Errorf("ciphertext too short")
+	}
+
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt: %w", err)
+	}
+
+	return string(plaintext), nil
+}
+
+func newGCM(key string) (cipher.AEAD, error) {
+	block, err := aes.NewCipher([]byte(key))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+	return gcm, nil
+}
+
+// Key is the encryption key of a running process, replaced by Rotate
+type Key struct {
+	mu    sync.RWMutex
+	value string
+}
+
+// NewKey returns a Key holding value
+func NewKey(value string) *Key {
+	return &Key{value: value}
+}
+
+// Encrypt encrypts a value with the current key
+func (k *Key) Encrypt(plaintext string) (string, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return Encrypt(k.value, plaintext)
+}
+
+// Decrypt decrypts a value with the current key
+func (k *Key) Decrypt(encrypted string) (string, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return Decrypt(k.value, encrypted)
+}
+
+// Is reports whether value is the current key
+func (k *Key) Is(value string) bool {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.value == value
+}
+
+// Rotate runs store with a function re-encrypting values from the current
+// key to newKey and switches to newKey if store succeeds. Encryption and
+// decryption wait until it is done.
+func (k *Key) Rotate(newKey string, store func(reencrypt func(string) (string, error)) error) error {
+	if _, err := newGCM(newKey); err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	err := store(func(encrypted string) (string, error) {
+		return Reencrypt(k.value, newKey, encrypted)
+	})
+	if err != nil {
+		return err
+	}
+	k.value = newKey
+	return nil
+}
+
+// Reencrypt decrypts a value with oldKey and encrypts it with newKey
+func Reencrypt(oldKey, newKey, encrypted string) (string, error) {
+	plaintext, err := Decrypt(oldKey, encrypted)
+	if err != nil {
+		return "", err
+	}
+	return Encrypt(newKey, plaintext)
+}
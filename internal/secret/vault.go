@@ -0,0 +1,122 @@
+package secret
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// vaultPrefix starts every ciphertext of the transit engine ("vault:v1:...")
+const vaultPrefix = "vault:"
+
+// Vault encrypts secrets with a key of the HashiCorp Vault transit engine;
+// the key never leaves Vault
+type Vault struct {
+	endpoint   string // .../v1/<mount>
+	token      string
+	key        string
+	httpClient *http.Client
+}
+
+// NewVault creates a Vault transit Crypter
+func NewVault(cfg Config) (*Vault, error) {
+	if cfg.VaultAddr == "" || cfg.VaultToken == "" || cfg.VaultKey == "" {
+		return nil, fmt.Errorf("Vault address, token and transit key are required")
+	}
+	addr, err := url.Parse(strings.TrimRight(cfg.VaultAddr, "/"))
+	if err != nil || addr.Host == "" {
+		return nil, fmt.Errorf("invalid Vault address %q", cfg.VaultAddr)
+	}
+
+	mount := strings.Trim(cfg.VaultMount, "/")
+	if mount == "" {
+		mount = "transit"
+	}
+
+	return &Vault{
+		endpoint:   addr.String() + "/v1/" + mount,
+		token:      cfg.VaultToken,
+		key:        cfg.VaultKey,
+		httpClient: newHTTPClient(),
+	}, nil
+}
+
+// Encrypt encrypts a value with the transit key
+func (v *Vault) Encrypt(plaintext string) (string, error) {
+	var resp struct {
+		Data struct {
+			Ciphertext string `json:"ciphertext"`
+		} `json:"data"`
+	}
+	body := map[string]string{"plaintext": base64.StdEncoding.EncodeToString([]byte(plaintext))}
+	if err := v.do("encrypt", body, &resp); err != nil {
+		return "", err
+	}
+	if !v.Owns(resp.Data.Ciphertext) {
+		return "", fmt.Errorf("unexpected Vault ciphertext")
+	}
+	return resp.Data.Ciphertext, nil
+}
+
+// Decrypt decrypts a value produced by Encrypt
+func (v *Vault) Decrypt(encrypted string) (string, error) {
+	var resp struct {
+		Data struct {
+			Plaintext string `json:"plaintext"`
+		} `json:"data"`
+	}
+	if err := v.do("decrypt", map[string]string{"ciphertext": encrypted}, &resp); err != nil {
+		return "", err
+	}
+	plaintext, err := base64.StdEncoding.DecodeString(resp.Data.Plaintext)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// Owns reports whether a value was encrypted by Vault
+func (v *Vault) Owns(encrypted string) bool {
+	return strings.HasPrefix(encrypted, vaultPrefix)
+}
+
+// do calls an operation of the transit key
+func (v *Vault) do(op string, body, out any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, v.endpoint+"/"+op+"/"+url.PathEscape(v.key), bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := v.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach Vault: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var vaultErr struct {
+			Errors []string `json:"errors"`
+		}
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		if json.Unmarshal(msg, &vaultErr) == nil && len(vaultErr.Errors) > 0 {
+			msg = []byte(strings.Join(vaultErr.Errors, "; "))
+		}
+		return fmt.Errorf("Vault %s returned %d: %s", op, resp.StatusCode, msg)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode Vault response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package smime
+
+import "errors"
+
+// maxDepth bounds the nesting of BER values
+const maxDepth = 32
+
+// berToDER re-encodes BER data with definite lengths, which encoding/asn1
+// requires. Mail clients such as Thunderbird write S/MIME messages with
+// indefinite lengths. Other DER rules (e.g. the order of SET elements) do not
+// matter for reading and are left as they are.
+func berToDER(data []byte) ([]byte, error) {
+	out, rest, err := convertValue(data, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 && !allZero(rest) {
+		return nil, errors.New("trailing data after the message")
+	}
+	return out, nil
+}
+
+// convertValue re-encodes the first value of data and returns the rest
+func convertValue(data []byte, depth int) (out, rest []byte, err error) {
+	if depth > maxDepth {
+		return nil, nil, errors.New("values nested too deeply")
+	}
+
+	// Identifier octets, including high tag numbers
+	if len(data) < 2 {
+		return nil, nil, errors.New("truncated value")
+	}
+	constructed := data[0]&0x20 != 0
+	i := 1
+	if data[0]&0x1f == 0x1f {
+		for i < len(data) && data[i]&0x80 != 0 {
+			i++
+		}
+		i++
+	}
+	if i >= len(data) {
+		return nil, nil, errors.New("truncated tag")
+	}
+	identifier := data[:i]
+
+	// Length octets
+	var content []byte
+	lengthByte := data[i]
+	i++
+	switch {
+	case lengthByte == 0x80:
+		if !constructed {
+			return nil, nil, errors.New("indefinite length of a primitive value")
+		}
+		// Children until the end-of-contents octets
+		var children []byte
+		rest = data[i:]
+		for {
+			if len(rest) < 2 {
+				return nil, nil, errors.New("missing end of contents")
+			}
+			if rest[0] == 0 && rest[1] == 0 {
+				rest = rest[2:]
+				break
+			}
+			var child []byte
+			child, rest, err = convertValue(rest, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			children = append(children, child...)
+		}
+		return encode(identifier, children), rest, nil
+	case lengthByte&0x80 == 0:
+		length := int(lengthByte)
+		if len(data)-i < length {
+			return nil, nil, errors.New("truncated value")
+		}
+		content, rest = data[i:i+length], data[i+length:]
+	default:
+		n := int(lengthByte & 0x7f)
+		if n > 4 || len(data)-i < n {
+			return nil, nil, errors.New("invalid length")
+		}
+		length := 0
+		for _, b := range data[i : i+n] {
+			length = length<<8 | int(b)
+		}
+		i += n
+		if length < 0 || len(data)-i < length {
+			return nil, nil, errors.New("truncated value")
+		}
+		content, rest = data[i:i+length], data[i+length:]
+	}
+
+	if !constructed {
+		return encode(identifier, content), rest, nil
+	}
+	var children []byte
+	for len(content) > 0 {
+		var child []byte
+		child, content, err = convertValue(content, depth+1)
+		if err != nil {
+			return nil, nil, err
+		}
+		children = append(children, child...)
+	}
+	return encode(identifier, children), rest, nil
+}
+
+// encode writes a value with a definite length in its shortest form
+func encode(identifier, content []byte) []byte {
+	out := append([]byte{}, identifier...)
+	length := len(content)
+	switch {
+	case length < 0x80:
+		out = append(out, byte(length))
+	default:
+		var octets []byte
+		for l := length; l > 0; l >>= 8 {
+			octets = append([]byte{byte(l)}, octets...)
+		}
+		out = append(out, 0x80|byte(len(octets)))
+		out = append(out, octets...)
+	}
+	return append(out, content...)
+}
+
+// allZero reports whether data is padding of zero bytes
+func allZero(data []byte) bool {
+	for _, b := range data {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,334 @@
+// Package smime decrypts S/MIME encrypted emails (CMS EnvelopedData, RFC 5652)
+// with the private key and certificate of the recipient
+package smime
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"math/big"
+	"time"
+)
+
+// ErrNotRecipient is returned for emails encrypted for other certificates
+var ErrNotRecipient = errors.New("the email is not encrypted for this certificate")
+
+var (
+	oidData          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidEnvelopedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 3}
+
+	oidRSAEncryption = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidRSAESOAEP     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 7}
+
+	oidSHA1   = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidSHA256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+
+	oidAES128CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidDESEDE3CBC = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+)
+
+// Key is the RSA private key and certificate emails are encrypted for
+type Key struct {
+	Certificate *x509.Certificate
+	private     *rsa.PrivateKey
+}
+
+// Parse reads a private key and its certificate from PEM data, e.g.
+// "openssl pkcs12 -in cert.p12 -nodes" output. Other certificates of the
+// chain are ignored. Password-protected keys are not supported.
+func Parse(data []byte) (*Key, error) {
+	var private *rsa.PrivateKey
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		switch block.Type {
+		case "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("invalid certificate: %w", err)
+			}
+			certs = append(certs, cert)
+		case "PRIVATE KEY":
+			key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("invalid private key: %w", err)
+			}
+			rsaKey, ok := key.(*rsa.PrivateKey)
+			if !ok {
+				return nil, errors.New("only RSA keys are supported")
+			}
+			private = rsaKey
+		case "RSA PRIVATE KEY":
+			if _, encrypted := block.Headers["DEK-Info"]; encrypted {
+				return nil, errors.New("the private key is password-protected, export it without a password")
+			}
+			key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("invalid private key: %w", err)
+			}
+			private = key
+		case "ENCRYPTED PRIVATE KEY":
+			return nil, errors.New("the private key is password-protected, export it without a password")
+		case "EC PRIVATE KEY":
+			return nil, errors.New("only RSA keys are supported")
+		}
+	}
+
+	if private == nil {
+		return nil, errors.New("no private key found")
+	}
+	for _, cert := range certs {
+		if public, ok := cert.PublicKey.(*rsa.PublicKey); ok && public.Equal(&private.PublicKey) {
+			return &Key{Certificate: cert, private: private}, nil
+		}
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no certificate found")
+	}
+	return nil, errors.New("no certificate matches the private key")
+}
+
+// KeyPEM returns the private key in PKCS #8 PEM form
+func (k *Key) KeyPEM() []byte {
+	der, _ := x509.MarshalPKCS8PrivateKey(k.private)
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+// CertificatePEM returns the certificate in PEM form
+func (k *Key) CertificatePEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: k.Certificate.Raw})
+}
+
+// Expired reports whether the certificate is no longer valid at t. Emails
+// encrypted before it expired can still be decrypted.
+func (k *Key) Expired(t time.Time) bool {
+	return t.After(k.Certificate.NotAfter)
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,tag:0"`
+}
+
+type envelopedData struct {
+	Version              int
+	OriginatorInfo       asn1.RawValue   `asn1:"optional,tag:0"`
+	RecipientInfos       []asn1.RawValue `asn1:"set"`
+	EncryptedContentInfo encryptedContentInfo
+	UnprotectedAttrs     asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type encryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm algorithmIdentifier
+	EncryptedContent           asn1.RawValue `asn1:"optional,tag:0"`
+}
+
+type algorithmIdentifier struct {
+	Algorithm  asn1.ObjectIdentifier
+	Parameters asn1.RawValue `asn1:"optional"`
+}
+
+type keyTransRecipientInfo struct {
+	Version                int
+	RecipientIdentifier    asn1.RawValue
+	KeyEncryptionAlgorithm algorithmIdentifier
+	EncryptedKey           []byte
+}
+
+type issuerAndSerialNumber struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type oaepParams struct {
+	HashAlgorithm algorithmIdentifier `asn1:"optional,explicit,tag:0"`
+}
+
+// Decrypt decrypts the content of an application/pkcs7-mime message, the
+// MIME entity that was encrypted
+func (k *Key) Decrypt(data []byte) ([]byte, error) {
+	der, err := berToDER(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid S/MIME message: %w", err)
+	}
+
+	var info contentInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, fmt.Errorf("invalid S/MIME message: %w", err)
+	}
+	if !info.ContentType.Equal(oidEnvelopedData) {
+		return nil, fmt.Errorf("unsupported S/MIME content type %s", info.ContentType)
+	}
+	var enveloped envelopedData
+	if _, err := asn1.Unmarshal(info.Content.Bytes, &enveloped); err != nil {
+		return nil, fmt.Errorf("invalid enveloped data: %w", err)
+	}
+
+	contentKey, err := k.contentKey(enveloped.RecipientInfos)
+	if err != nil {
+		return nil, err
+	}
+
+	content := enveloped.EncryptedContentInfo
+	if !content.ContentType.Equal(oidData) {
+		return nil, fmt.Errorf("unsupported encrypted content type %s", content.ContentType)
+	}
+	ciphertext := content.EncryptedContent.Bytes
+	if content.EncryptedContent.IsCompound {
+		// Constructed octet strings split the content into segments
+		if ciphertext, err = joinSegments(ciphertext); err != nil {
+			return nil, fmt.Errorf("invalid encrypted content: %w", err)
+		}
+	}
+	return decryptContent(content.ContentEncryptionAlgorithm, contentKey, ciphertext)
+}
+
+// contentKey decrypts the content-encryption key of the recipient matching
+// the certificate. Only key transport recipients (RSA) are supported.
+func (k *Key) contentKey(recipients []asn1.RawValue) ([]byte, error) {
+	for _, raw := range recipients {
+		if raw.Class != asn1.ClassUniversal || raw.Tag != asn1.TagSequence {
+			continue
+		}
+		var recipient keyTransRecipientInfo
+		if _, err := asn1.Unmarshal(raw.FullBytes, &recipient); err != nil {
+			continue
+		}
+		if !k.identifies(recipient.RecipientIdentifier) {
+			continue
+		}
+		return k.decryptKey(recipient)
+	}
+	return nil, ErrNotRecipient
+}
+
+// identifies reports whether a recipient identifier names the certificate,
+// by issuer and serial number or by subject key identifier
+func (k *Key) identifies(rid asn1.RawValue) bool {
+	if rid.Class == asn1.ClassContextSpecific && rid.Tag == 0 {
+		return len(k.Certificate.SubjectKeyId) > 0 && bytes.Equal(rid.Bytes, k.Certificate.SubjectKeyId)
+	}
+	var ias issuerAndSerialNumber
+	if _, err := asn1.Unmarshal(rid.FullBytes, &ias); err != nil {
+		return false
+	}
+	return bytes.Equal(ias.Issuer.FullBytes, k.Certificate.RawIssuer) && ias.SerialNumber.Cmp(k.Certificate.SerialNumber) == 0
+}
+
+// decryptKey decrypts the content-encryption key with RSA PKCS #1 v1.5 or
+// RSAES-OAEP
+func (k *Key) decryptKey(recipient keyTransRecipientInfo) ([]byte, error) {
+	algorithm := recipient.KeyEncryptionAlgorithm
+	switch {
+	case algorithm.Algorithm.Equal(oidRSAEncryption):
+		return rsa.DecryptPKCS1v15(rand.Reader, k.private, recipient.EncryptedKey)
+	case algorithm.Algorithm.Equal(oidRSAESOAEP):
+		newHash := sha1.New
+		var params oaepParams
+		if len(algorithm.Parameters.FullBytes) > 0 {
+			if _, err := asn1.Unmarshal(algorithm.Parameters.FullBytes, &params); err != nil {
+				return nil, fmt.Errorf("invalid OAEP parameters: %w", err)
+			}
+			if params.HashAlgorithm.Algorithm != nil {
+				var ok bool
+				if newHash, ok = hashes[params.HashAlgorithm.Algorithm.String()]; !ok {
+					return nil, fmt.Errorf("unsupported OAEP hash %s", params.HashAlgorithm.Algorithm)
+				}
+			}
+		}
+		return rsa.DecryptOAEP(newHash(), rand.Reader, k.private, recipient.EncryptedKey, nil)
+	}
+	return nil, fmt.Errorf("unsupported key encryption algorithm %s", algorithm.Algorithm)
+}
+
+// hashes of RSAES-OAEP by OID
+var hashes = map[string]func() hash.Hash{
+	oidSHA1.String():   sha1.New,
+	oidSHA256.String(): sha256.New,
+	oidSHA384.String(): sha512.New384,
+	oidSHA512.String(): sha512.New,
+}
+
+// decryptContent decrypts CBC-encrypted content and removes its padding
+func decryptContent(algorithm algorithmIdentifier, key, ciphertext []byte) ([]byte, error) {
+	var block cipher.Block
+	var err error
+	switch {
+	case algorithm.Algorithm.Equal(oidAES128CBC), algorithm.Algorithm.Equal(oidAES192CBC), algorithm.Algorithm.Equal(oidAES256CBC):
+		block, err = aes.NewCipher(key)
+	case algorithm.Algorithm.Equal(oidDESEDE3CBC):
+		block, err = des.NewTripleDESCipher(key)
+	default:
+		return nil, fmt.Errorf("unsupported content encryption algorithm %s", algorithm.Algorithm)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid content key: %w", err)
+	}
+
+	var iv []byte
+	if _, err := asn1.Unmarshal(algorithm.Parameters.FullBytes, &iv); err != nil || len(iv) != block.BlockSize() {
+		return nil, errors.New("invalid initialization vector")
+	}
+	if len(ciphertext) == 0 || len(ciphertext)%block.BlockSize() != 0 {
+		return nil, errors.New("invalid encrypted content length")
+	}
+
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, ciphertext)
+
+	// PKCS #7 padding
+	pad := int(plaintext[len(plaintext)-1])
+	if pad == 0 || pad > block.BlockSize() || pad > len(plaintext) {
+		return nil, errors.New("invalid padding, the key does not match")
+	}
+	for _, b := range plaintext[len(plaintext)-pad:] {
+		if int(b) != pad {
+			return nil, errors.New("invalid padding, the key does not match")
+		}
+	}
+	return plaintext[:len(plaintext)-pad], nil
+}
+
+// joinSegments concatenates the primitive octet strings of a constructed one
+func joinSegments(data []byte) ([]byte, error) {
+	var out []byte
+	for len(data) > 0 {
+		var segment asn1.RawValue
+		rest, err := asn1.Unmarshal(data, &segment)
+		if err != nil {
+			return nil, err
+		}
+		if segment.IsCompound {
+			inner, err := joinSegments(segment.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, inner...)
+		} else {
+			out = append(out, segment.Bytes...)
+		}
+		data = rest
+	}
+	return out, nil
+}
@@ -0,0 +1,251 @@
+// Package smtp sends email replies from connected accounts
+package smtp
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	netsmtp "net/smtp"
+	"strings"
+	"time"
+)
+
+// Known SMTP servers of popular email providers
+var knownSMTPServers = map[string]string{
+	"gmail.com":      "smtp.gmail.com:465",
+	"googlemail.com": "smtp.gmail.com:465",
+	"outlook.com":    "smtp.office365.com:587",
+	"hotmail.com":    "smtp.office365.com:587",
+	"live.com":       "smtp.office365.com:587",
+	"msn.com":        "smtp.office365.com:587",
+	"yahoo.com":      "smtp.mail.yahoo.com:465",
+	"yahoo.co.uk":    "smtp.mail.yahoo.com:465",
+	"yandex.ru":      "smtp.yandex.ru:465",
+	"yandex.com":     "smtp.yandex.com:465",
+	"mail.ru":        "smtp.mail.ru:465",
+	"bk.ru":          "smtp.mail.ru:465",
+	"list.ru":        "smtp.mail.ru:465",
+	"inbox.ru":       "smtp.mail.ru:465",
+	"icloud.com":     "smtp.mail.me.com:587",
+	"me.com":         "smtp.mail.me.com:587",
+	"mac.com":        "smtp.mail.me.com:587",
+	"aol.com":        "smtp.aol.com:465",
+	"zoho.com":       "smtp.zoho.com:465",
+	"protonmail.com": "127.0.0.1:1025", // ProtonMail Bridge
+	"proton.me":      "127.0.0.1:1025",
+	"fastmail.com":   "smtp.fastmail.com:465",
+	"gmx.com":        "mail.gmx.com:465",
+	"gmx.de":         "mail.gmx.net:465",
+	"web.de":         "smtp.web.de:587",
+	"t-online.de":    "securesmtp.t-online.de:465",
+	"rambler.ru":     "smtp.rambler.ru:465",
+}
+
+// ResolveServer determines the SMTP server of an account from its address
+// and IMAP server: known providers first, then the IMAP host with "imap."
+// replaced by "smtp.", then smtp.<domain>
+func ResolveServer(email, imapServer string) (string, error) {
+	_, domain, ok := strings.Cut(email, "@")
+	if !ok || domain == "" {
+		return "", fmt.Errorf("invalid email format")
+	}
+	domain = strings.ToLower(domain)
+
+	if server, ok := knownSMTPServers[domain]; ok {
+		return server, nil
+	}
+
+	// Drop the security options of the IMAP address ("host:143/starttls")
+	host, _, _ := strings.Cut(imapServer, "/")
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if rest, ok := strings.CutPrefix(host, "imap."); ok {
+		return "smtp." + rest + ":465", nil
+	}
+	if strings.HasPrefix(host, "mail.") {
+		return host + ":465", nil
+	}
+	return "smtp." + domain + ":465", nil
+}
+
+// Config of an SMTP session
+type Config struct {
+	Server      string // host:port; port 465 uses implicit TLS, others STARTTLS
+	Username    string
+	Password    string
+	DialTimeout time.Duration
+}
+
+// Message is a plain-text email
+type Message struct {
+	From       string
+	To         string
+	Subject    string
+	Body       string
+	InReplyTo  string // Message-ID of the answered email, with angle brackets
+	References string // space-separated Message-IDs of the thread
+}
+
+// ReplySubject prefixes subject with "Re: " unless it already is a reply
+func ReplySubject(subject string) string {
+	if strings.HasPrefix(strings.ToLower(strings.TrimSpace(subject)), "re:") {
+		return subject
+	}
+	return "Re: " + subject
+}
+
+// ReplyReferences returns the References header of a reply to an email with
+// the given Message-ID and References (RFC 5322 section 3.6.4)
+func ReplyReferences(messageID, references string) string {
+	refs := strings.Fields(references)
+	if messageID != "" {
+		refs = append(refs, messageID)
+	}
+	return strings.Join(refs, " ")
+}
+
+// Sent is a delivered message
+type Sent struct {
+	MessageID string
+	Raw       []byte // the message as sent, e.g. for the Sent folder
+}
+
+// Send delivers msg
+func Send(ctx context.Context, cfg Config, msg Message) (*Sent, error) {
+	from, err := mail.ParseAddress(msg.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender: %w", err)
+	}
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipient: %w", err)
+	}
+
+	messageID, err := newMessageID(from.Address)
+	if err != nil {
+		return nil, err
+	}
+	data := build(msg, from, to, messageID)
+
+	host, port, err := net.SplitHostPort(cfg.Server)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SMTP server %q: %w", cfg.Server, err)
+	}
+
+	timeout := cfg.DialTimeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+	dialer := &net.Dialer{Timeout: timeout}
+
+	var conn net.Conn
+	if port == "465" {
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: host}}
+		conn, err = tlsDialer.DialContext(ctx, "tcp", cfg.Server)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", cfg.Server)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	defer conn.Close()
+
+	// Bound the whole session, not only the dial
+	deadline := time.Now().Add(2 * timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	c, err := netsmtp.NewClient(conn, host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start SMTP session: %w", err)
+	}
+	defer c.Close()
+
+	if port != "465" {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return nil, fmt.Errorf("server does not support STARTTLS")
+		}
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return nil, fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+
+	if err := c.Auth(netsmtp.PlainAuth("", cfg.Username, cfg.Password, host)); err != nil {
+		return nil, fmt.Errorf("failed to authenticate: %w", err)
+	}
+	if err := c.Mail(from.Address); err != nil {
+		return nil, fmt.Errorf("sender rejected: %w", err)
+	}
+	if err := c.Rcpt(to.Address); err != nil {
+		return nil, fmt.Errorf("recipient rejected: %w", err)
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start message: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to write message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("message rejected: %w", err)
+	}
+
+	// The message is already accepted, a failed QUIT does not matter
+	c.Quit()
+	return &Sent{MessageID: messageID, Raw: data}, nil
+}
+
+// build renders msg as an RFC 5322 message with a quoted-printable body
+func build(msg Message, from, to *mail.Address, messageID string) []byte {
+	var buf bytes.Buffer
+	header := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(&buf, "%s: %s\r\n", name, value)
+		}
+	}
+
+	header("From", from.String())
+	header("To", to.String())
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", messageID)
+	header("In-Reply-To", msg.InReplyTo)
+	header("References", msg.References)
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=utf-8")
+	header("Content-Transfer-Encoding", "quoted-printable")
+	buf.WriteString("\r\n")
+
+	body := strings.ReplaceAll(msg.Body, "\r\n", "\n")
+	body = strings.ReplaceAll(body, "\n", "\r\n")
+	qp := quotedprintable.NewWriter(&buf)
+	qp.Write([]byte(body))
+	qp.Close()
+	buf.WriteString("\r\n")
+
+	return buf.Bytes()
+}
+
+// newMessageID generates a Message-ID in the sender's domain
+func newMessageID(from string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate message id: %w", err)
+	}
+	domain := "localhost"
+	if _, d, ok := strings.Cut(from, "@"); ok && d != "" {
+		domain = d
+	}
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(b), domain), nil
+}
@@ -0,0 +1,52 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"html"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/mixelka/emailresend/internal/i18n"
+)
+
+// autoTopicEnabled reports whether a /connect or /create command gets a new
+// topic for the account: it was sent in General of a chat with /autotopics on
+func (b *Bot) autoTopicEnabled(ctx context.Context, msg *models.Message) bool {
+	if !msg.Chat.IsForum || msg.MessageThreadID != 0 {
+		return false
+	}
+	settings, err := b.db.GetChatSettings(ctx, msg.Chat.ID)
+	if err != nil {
+		b.logger.Warn("failed to get chat settings", "error", err)
+		return false
+	}
+	return settings.AutoTopics
+}
+
+// createAccountTopic creates a topic named after a mailbox
+func (b *Bot) createAccountTopic(ctx context.Context, chatID int64, emailAddr string) (int, error) {
+	topic, err := b.bot.CreateForumTopic(ctx, &bot.CreateForumTopicParams{
+		ChatID: chatID,
+		Name:   emailAddr,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to create topic: %w", err)
+	}
+	return topic.MessageThreadID, nil
+}
+
+// deleteTopic removes a topic created for an account that was not connected
+func (b *Bot) deleteTopic(ctx context.Context, chatID int64, topicID int) {
+	if _, err := b.bot.DeleteForumTopic(ctx, &bot.DeleteForumTopicParams{ChatID: chatID, MessageThreadID: topicID}); err != nil {
+		b.logger.Warn("failed to delete topic", "error", err, "topic_id", topicID)
+	}
+}
+
+// announceAccountTopic tells General where the mailbox connected by a
+// command sent there went
+func (b *Bot) announceAccountTopic(ctx context.Context, chatID int64, topicID int, emailAddr string) {
+	b.sendMessage(ctx, chatID, 0, i18n.Tf(ctx, "Почта <b>%s</b> подключена к новому топику: %s",
+		html.EscapeString(emailAddr), messageLink(chatID, topicID)))
+}
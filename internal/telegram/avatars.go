@@ -0,0 +1,21 @@
+package telegram
+
+import (
+	"context"
+
+	appmodels "github.com/mixelka/emailresend/pkg/models"
+)
+
+// senderPicture returns how an email shows its sender as set by /avatars:
+// the avatar URL to show above the text, or whether to add the emoji of the
+// sender's domain. Senders without an avatar get the emoji instead.
+func (b *Bot) senderPicture(ctx context.Context, account *appmodels.EmailAccount, msg *appmodels.EmailMessage) (avatarURL string, domainEmoji bool) {
+	switch account.Avatars {
+	case appmodels.AvatarsEmoji:
+		return "", true
+	case appmodels.AvatarsPhoto:
+		avatarURL = b.avatars.URL(ctx, msg.FromAddr)
+		return avatarURL, avatarURL == ""
+	}
+	return "", false
+}
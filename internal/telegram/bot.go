@@ -4,66 +4,199 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
 
+	"github.com/mixelka/emailresend/internal/archive"
+	"github.com/mixelka/emailresend/internal/avatar"
 	"github.com/mixelka/emailresend/internal/config"
 	"github.com/mixelka/emailresend/internal/database"
 	"github.com/mixelka/emailresend/internal/email"
 	"github.com/mixelka/emailresend/internal/formatter"
+	"github.com/mixelka/emailresend/internal/i18n"
+	"github.com/mixelka/emailresend/internal/mailauth"
 	"github.com/mixelka/emailresend/internal/mailcow"
 	"github.com/mixelka/emailresend/internal/parser"
+	"github.com/mixelka/emailresend/internal/secret"
+	"github.com/mixelka/emailresend/internal/updates"
 )
 
 // Bot represents the Telegram bot
 type Bot struct {
-	bot          *bot.Bot
-	db           *database.DB
-	emailManager *email.Manager
-	mailcow      *mailcow.Client
-	htmlParser   *parser.HTMLParser
-	codeDetector *parser.CodeDetector
-	formatter    *formatter.TelegramFormatter
-	logger       *slog.Logger
-	config       *config.Config
+	bot              *bot.Bot
+	id               int64  // Telegram user ID of the bot
+	username         string // Telegram username of the bot, used for /command@username
+	primary          bool
+	db               *database.DB
+	emailManager     *email.Manager
+	mailcow          *mailcow.Client
+	archive          *archive.Archive
+	htmlParser       *parser.HTMLParser
+	codeDetector     *parser.CodeDetector
+	extractors       *parser.Registry
+	orderDetector    *parser.OrderDetector
+	trackingDetector *parser.TrackingDetector
+	formatter        *formatter.TelegramFormatter
+	logger           *slog.Logger
+	config           *config.Config
+	crypter          secret.Crypter
+	deliveryWake     chan struct{}
+	deliveryCtx      context.Context    // context of the delivery worker, outlives shutdown until Drain
+	stopDelivery     context.CancelFunc // ends the delivery worker
+	sends            *sendThrottle      // spaces out messages per chat, see throttle.go
+	avatars          *avatar.Source     // sender pictures of /avatars photo
+	mailAuth         *mailauth.Checker  // DKIM/SPF verdicts of senders
+
+	// Names of the registered commands, for /permissions
+	commands []string
+
+	// Secret token of webhook requests (TELEGRAM_WEBHOOK_URL)
+	webhookSecret string
+
+	// Key checking the initData of the dashboard (WEBAPP_URL) and admin
+	// rights checked for it by chat and user
+	webAppKey    []byte
+	webAppMu     sync.Mutex
+	webAppAdmins map[webAppMember]webAppAdmin
+
+	// Pending /setpassword requests by user ID
+	passwordMu       sync.Mutex
+	passwordSessions map[int64]passwordSession
+
+	// Pending /test probes by account ID
+	probesMu sync.Mutex
+	probes   map[int64]*probe
+
+	// Interactive /send by chat and user
+	composeMu       sync.Mutex
+	composeSessions map[composeKey]*composeSession
+
+	// Serializes OAuth token refreshes
+	oauthMu sync.Mutex
+
+	// Pinned status boards: last rendered state by chat ID
+	statusWake   chan struct{}
+	statusMu     sync.Mutex
+	statusBoards map[int64]string
+
+	// Unread counts in topic names: last set name by account ID
+	badgeWake  chan struct{}
+	badgeMu    sync.Mutex
+	badgeNames map[int64]string
+
+	// Running /broadcast
+	broadcastMu  sync.Mutex
+	broadcasting bool
+
+	// Accounts with a running /reparse all
+	reparseMu sync.Mutex
+	reparsing map[int64]bool
+
+	// New encryption keys of /rotate_key waiting for confirmation by user ID
+	rotateMu   sync.Mutex
+	rotateKeys map[int64]pendingKey
+
+	// Latest GitHub release seen by the update check
+	releaseMu     sync.Mutex
+	latestRelease *updates.Release
 }
 
 // BotDeps dependencies for creating a bot
 type BotDeps struct {
-	Config       *config.Config
-	DB           *database.DB
-	EmailManager *email.Manager
-	Mailcow      *mailcow.Client
-	HTMLParser   *parser.HTMLParser
-	CodeDetector *parser.CodeDetector
-	Formatter    *formatter.TelegramFormatter
-	Logger       *slog.Logger
+	Token            string // defaults to Config.TelegramToken
+	Primary          bool   // serves accounts not bound to a specific bot
+	Config           *config.Config
+	Crypter          secret.Crypter // shared by all bots (defaults to Config.EncryptionKey)
+	DB               *database.DB
+	EmailManager     *email.Manager
+	Mailcow          *mailcow.Client
+	Archive          *archive.Archive // optional raw message storage
+	HTMLParser       *parser.HTMLParser
+	CodeDetector     *parser.CodeDetector
+	Extractors       *parser.Registry         // sender-specific extractors (optional)
+	OrderDetector    *parser.OrderDetector    // commerce emails (optional)
+	TrackingDetector *parser.TrackingDetector // parcel tracking numbers (optional)
+	Formatter        *formatter.TelegramFormatter
+	Logger           *slog.Logger
 }
 
 // NewBot creates a new Telegram bot
 func NewBot(deps BotDeps) (*Bot, error) {
+	token := deps.Token
+	if token == "" {
+		token = deps.Config.TelegramToken
+	}
+
+	id, err := botIDFromToken(token)
+	if err != nil {
+		return nil, err
+	}
+
 	b := &Bot{
-		db:           deps.DB,
-		emailManager: deps.EmailManager,
-		mailcow:      deps.Mailcow,
-		htmlParser:   deps.HTMLParser,
-		codeDetector: deps.CodeDetector,
-		formatter:    deps.Formatter,
-		logger:       deps.Logger.With("component", "telegram_bot"),
-		config:       deps.Config,
+		id:               id,
+		primary:          deps.Primary,
+		db:               deps.DB,
+		emailManager:     deps.EmailManager,
+		mailcow:          deps.Mailcow,
+		archive:          deps.Archive,
+		htmlParser:       deps.HTMLParser,
+		codeDetector:     deps.CodeDetector,
+		extractors:       deps.Extractors,
+		orderDetector:    deps.OrderDetector,
+		trackingDetector: deps.TrackingDetector,
+		formatter:        deps.Formatter,
+		logger:           deps.Logger.With("component", "telegram_bot", "bot_id", id),
+		config:           deps.Config,
+		crypter:          deps.Crypter,
+		deliveryWake:     make(chan struct{}, 1),
+		sends:            newSendThrottle(),
+		avatars:          avatar.NewSource(),
+		mailAuth:         mailauth.NewChecker(),
+
+		passwordSessions: make(map[int64]passwordSession),
+		probes:           make(map[int64]*probe),
+		composeSessions:  make(map[composeKey]*composeSession),
+		statusWake:       make(chan struct{}, 1),
+		statusBoards:     make(map[int64]string),
+		badgeWake:        make(chan struct{}, 1),
+		badgeNames:       make(map[int64]string),
+		reparsing:        make(map[int64]bool),
+		rotateKeys:       make(map[int64]pendingKey),
+		webAppKey:        webAppKey(token),
+		webAppAdmins:     make(map[webAppMember]webAppAdmin),
+	}
+	if b.crypter == nil {
+		b.crypter = secret.NewKey(deps.Config.EncryptionKey)
 	}
+	b.deliveryCtx, b.stopDelivery = context.WithCancel(context.Background())
 
 	opts := []bot.Option{
 		bot.WithDefaultHandler(b.defaultHandler),
+		bot.WithErrorsHandler(b.onClientError),
+		bot.WithMiddlewares(b.withoutReplyThreads, b.withLanguage, b.recoverPanic),
+	}
+	if deps.Config.WebhookEnabled() {
+		b.webhookSecret = webhookSecret(deps.Config.TelegramWebhookSecret, token)
+		opts = append(opts, bot.WithWebhookSecretToken(b.webhookSecret))
 	}
 
-	tgBot, err := bot.New(deps.Config.TelegramToken, opts...)
+	tgBot, err := bot.New(token, opts...)
 	if err != nil {
 		return nil, err
 	}
 
+	me, err := tgBot.GetMe(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bot info: %w", err)
+	}
+
 	b.bot = tgBot
+	b.username = me.Username
 	b.registerHandlers()
 
 	return b, nil
@@ -71,19 +204,158 @@ func NewBot(deps BotDeps) (*Bot, error) {
 
 // registerHandlers registers command handlers
 func (b *Bot) registerHandlers() {
-	b.bot.RegisterHandler(bot.HandlerTypeMessageText, "/connect", bot.MatchTypePrefix, b.handleConnect)
-	b.bot.RegisterHandler(bot.HandlerTypeMessageText, "/create", bot.MatchTypePrefix, b.handleCreate)
-	b.bot.RegisterHandler(bot.HandlerTypeMessageText, "/disconnect", bot.MatchTypePrefix, b.handleDisconnect)
-	b.bot.RegisterHandler(bot.HandlerTypeMessageText, "/status", bot.MatchTypePrefix, b.handleStatus)
-	b.bot.RegisterHandler(bot.HandlerTypeMessageText, "/start", bot.MatchTypePrefix, b.handleStart)
-	b.bot.RegisterHandler(bot.HandlerTypeMessageText, "/help", bot.MatchTypePrefix, b.handleHelp)
+	// Private replies of the /setpassword flow take precedence over commands
+	b.bot.RegisterHandlerMatchFunc(b.matchPasswordReply, b.handlePasswordReply)
+	b.bot.RegisterHandlerMatchFunc(b.matchComposeReply, b.handleComposeReply)
+	b.registerCommand("connect", b.handleConnect,
+		b.requireAdmin("Только администраторы могут подключать почтовые аккаунты"), b.rateLimit(5, time.Minute))
+	b.registerCommand("create", b.handleCreate,
+		b.requireAdmin("Только администраторы могут создавать почтовые ящики"), b.rateLimit(5, time.Minute))
+	b.registerCommand("createbatch", b.handleCreateBatch,
+		b.requireForum, b.requireAdmin("Только администраторы могут создавать почтовые ящики"), b.rateLimit(2, 10*time.Minute))
+	b.registerCommand("disconnect", b.handleDisconnect,
+		b.requireAdmin("Только администраторы могут отключать почтовые аккаунты"))
+	b.registerCommand("move_account", b.handleMoveAccount,
+		b.requireAdmin("Только администраторы могут переносить почтовые аккаунты"), b.rateLimit(5, time.Minute))
+	b.registerCommand("setpassword", b.handleSetPassword)
+	b.registerCommand("smime", b.handleSMIME)
+	b.registerCommand("send", b.handleSend, b.rateLimit(10, time.Minute))
+	b.registerCommand("status", b.handleStatus)
+	b.registerCommand("dashboard", b.handleDashboard)
+	b.registerCommand("statusboard", b.handleStatusBoard, b.requireForum)
+	b.registerCommand("diagnose", b.handleDiagnose, b.rateLimit(3, 10*time.Minute))
+	b.registerCommand("test", b.handleTest, b.rateLimit(3, 10*time.Minute))
+	b.registerCommand("export", b.handleMailExport, b.rateLimit(3, 10*time.Minute))
+	b.registerCommand("redeliver", b.handleRedeliver)
+	b.registerCommand("parsemode", b.handleParseMode)
+	b.registerCommand("language", b.handleLanguage)
+	b.registerCommand("autotopics", b.handleAutoTopics, b.requireForum)
+	b.registerCommand("emoji", b.handleEmoji)
+	b.registerCommand("silent", b.handleSilent)
+	b.registerCommand("priority", b.handlePriority)
+	b.registerCommand("protect", b.handleProtect)
+	b.registerCommand("collapse", b.handleCollapse)
+	b.registerCommand("pincodes", b.handlePinCodes)
+	b.registerCommand("bulk", b.handleBulk)
+	b.registerCommand("digest", b.handleDigest)
+	b.registerCommand("quiet", b.handleQuiet)
+	b.registerCommand("profile", b.handleProfile)
+	b.registerCommand("template", b.handleTemplate)
+	b.registerCommand("avatars", b.handleAvatars)
+	b.registerCommand("settings", b.handleSettings)
+	b.registerCommand("badge", b.handleUnreadBadge)
+	b.registerCommand("idle", b.handleIdle)
+	b.registerCommand("filter", b.handleFilter)
+	b.registerCommand("codes", b.handleCodes)
+	b.registerCommand("storage", b.handleStorage)
+	b.registerCommand("tag", b.handleTag)
+	b.registerCommand("untag", b.handleUntag)
+	b.registerCommand("pause", b.handlePause, b.requireAdmin("Только администраторы могут приостанавливать пересылку"))
+	b.registerCommand("resume", b.handleResume, b.requireAdmin("Только администраторы могут возобновлять пересылку"), b.rateLimit(5, time.Minute))
+	b.registerCommand("stats", b.handleStats, b.rateLimit(10, time.Minute))
+	b.registerCommand("history", b.handleHistory, b.rateLimit(20, time.Minute))
+	b.registerCommand("move", b.handleMove, b.rateLimit(20, time.Minute))
+	b.registerCommand("mirror", b.handleMirror,
+		b.requireForum, b.requireAdmin("Только администраторы могут настраивать трансляцию писем"), b.rateLimit(5, time.Minute))
+	b.registerCommand("unmirror", b.handleUnmirror,
+		b.requireForum, b.requireAdmin("Только администраторы могут настраивать трансляцию писем"))
+	b.registerCommand("reparse", b.handleReparse)
+	b.registerCommand("refetch", b.handleRefetch)
+	b.registerCommand("markallread", b.handleMarkAllRead, b.rateLimit(3, 10*time.Minute))
+	b.registerCommand("purge", b.handlePurgeMail, b.rateLimit(3, 10*time.Minute))
+	b.registerCommand("dryrun", b.handleDryRun,
+		b.requireAdmin("Только администраторы могут проверять разбор писем"), b.rateLimit(10, time.Minute))
+	b.registerCommand("extractors", b.handleExtractors)
+	b.registerCommand("permissions", b.handlePermissions, b.requireAdmin("Только администраторы могут менять права на команды"))
+	b.registerCommand("announcements", b.handleAnnouncements)
+	b.registerCommand("grant", b.handleGrant)
+	b.registerCommand("revoke", b.handleRevoke)
+	b.registerCommand("forgetme", b.handleForgetMe)
+	b.registerCommand("broadcast", b.handleBroadcast)
+	b.registerCommand("exportcreds", b.handleExportCredentials)
+	b.registerCommand("rotate_key", b.handleRotateKey)
+	b.registerCommand("search", b.handleSearch, b.rateLimit(20, time.Minute))
+	b.registerCommand("report", b.handleReport, b.rateLimit(10, time.Minute))
+	b.registerCommand("version", b.handleVersion)
+	b.registerCommand("start", b.handleStart)
+	b.registerCommand("help", b.handleHelp)
+	b.bot.RegisterHandlerMatchFunc(b.matchImport, chain(b.handleImport,
+		b.auditLog, b.requireForum, b.requireAdmin("Только администраторы могут импортировать почтовые аккаунты")))
+	b.bot.RegisterHandlerMatchFunc(b.matchDryRun, chain(b.handleDryRun, b.auditLog, b.checkPermission("dryrun"),
+		b.requireAdmin("Только администраторы могут проверять разбор писем"), b.rateLimit(10, time.Minute)))
+	b.bot.RegisterHandlerMatchFunc(b.matchEmailReply, b.handleEmailReply)
 	b.bot.RegisterHandler(bot.HandlerTypeCallbackQueryData, "", bot.MatchTypePrefix, b.handleCallback)
 }
 
-// Start starts the bot
+// ID returns the Telegram user ID of the bot
+func (b *Bot) ID() int64 {
+	return b.id
+}
+
+// accountBotID returns the bot_id stored on accounts served by this bot.
+// The primary bot uses 0 so that existing accounts keep working when the
+// token changes.
+func (b *Bot) accountBotID() int64 {
+	if b.primary {
+		return 0
+	}
+	return b.id
+}
+
+// botIDFromToken extracts the bot user ID from a token ("<id>:<secret>")
+func botIDFromToken(token string) (int64, error) {
+	idPart, _, ok := strings.Cut(token, ":")
+	if !ok {
+		return 0, fmt.Errorf("invalid bot token format")
+	}
+	id, err := strconv.ParseInt(idPart, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid bot token format: %w", err)
+	}
+	return id, nil
+}
+
+// Start starts the bot and blocks until ctx is cancelled. Polling is
+// restarted if it stops unexpectedly, so email processing keeps running.
 func (b *Bot) Start(ctx context.Context) {
 	b.logger.Info("starting telegram bot")
-	b.bot.Start(ctx)
+	// The delivery worker ends with ctx at the latest; Drain lets it
+	// finish the queue before
+	context.AfterFunc(ctx, b.stopDelivery)
+	go b.runDelivery(b.deliveryCtx)
+	go b.runStatusBoards(ctx)
+	go b.runUnreadBadges(ctx)
+	go b.runSenderDigests(ctx)
+	go b.runCodeUnpins(ctx)
+	go b.runDigests(ctx)
+	if b.config.ChatStorageQuota > 0 {
+		go b.runStorageQuotas(ctx)
+	}
+	if b.oauthEnabled() {
+		go b.runOAuthRefresher(ctx)
+	}
+	if b.primary && b.config.UpdateCheckInterval > 0 {
+		go b.runUpdateCheck(ctx)
+	}
+	if b.config.WebAppEnabled() {
+		b.setWebAppMenuButton(ctx)
+	}
+	if b.config.WebhookEnabled() {
+		b.runWebhook(ctx)
+		return
+	}
+	b.deleteWebhook(ctx)
+	b.runSupervised(ctx)
+}
+
+// migrateChat moves the mailboxes of a group to the supergroup it became.
+// Supergroups start without topics, so the accounts keep topic 0.
+func (b *Bot) migrateChat(ctx context.Context, fromChatID, toChatID int64) {
+	if err := b.db.MigrateChat(ctx, fromChatID, toChatID); err != nil {
+		b.logger.Error("failed to migrate chat", "error", err, "from_chat_id", fromChatID, "to_chat_id", toChatID)
+		return
+	}
+	b.logger.Info("chat migrated to supergroup", "from_chat_id", fromChatID, "to_chat_id", toChatID)
 }
 
 // defaultHandler handles unknown messages
@@ -93,6 +365,12 @@ func (b *Bot) defaultHandler(ctx context.Context, tgBot *bot.Bot, update *models
 		return
 	}
 
+	// A group upgraded to a supergroup continues under a new chat ID
+	if to := update.Message.MigrateToChatID; to != 0 {
+		b.migrateChat(ctx, update.Message.Chat.ID, to)
+		return
+	}
+
 	// Log unknown commands
 	if update.Message.Text != "" && update.Message.Text[0] == '/' {
 		b.logger.Debug("unknown command", "text", update.Message.Text)
@@ -101,79 +379,143 @@ func (b *Bot) defaultHandler(ctx context.Context, tgBot *bot.Bot, update *models
 
 // handleStart handles /start command
 func (b *Bot) handleStart(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	if b.handleStartPayload(ctx, update.Message) {
+		return
+	}
 	b.handleHelp(ctx, tgBot, update)
 }
 
-// handleHelp handles /help command
-func (b *Bot) handleHelp(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
-	msg := update.Message
-
-	// Check if it's a private chat
-	if msg.Chat.Type == "private" {
-		text := `<b>Email to Telegram Bot</b>
+// Help texts, translated with i18n before command names are applied
+const (
+	helpPrivate = `<b>Email to Telegram Bot</b>
 
 Бот для пересылки email сообщений в Telegram.
 
-<b>Как использовать:</b>
-1. Создайте супергруппу
-2. Включите топики в настройках группы
-3. Добавьте бота в группу
-4. Сделайте бота администратором
-5. В нужном топике используйте /connect
-
-<b>Почему нужны топики?</b>
-Каждый email-аккаунт привязывается к отдельному топику. Это позволяет удобно разделять письма от разных аккаунтов.
-
-<b>Как включить топики:</b>
-Настройки группы → Темы → Включить`
+<b>Одна почта — прямо здесь:</b>
+/connect email password — подключить почту к этому чату
+Новые письма будут приходить сюда, для неё работают /status, /send, /setpassword, /silent, /quiet, /filter, /history, /export и другие команды.
 
-		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, text)
-		return
-	}
+<b>Несколько почт — в группе с топиками:</b>
+1. Создайте супергруппу
+2. Включите топики: Настройки группы → Темы → Включить
+3. Добавьте бота в группу и сделайте его администратором
+4. В нужном топике используйте /connect
 
-	// Check if it's a group without topics
-	if msg.Chat.Type == "group" || (msg.Chat.Type == "supergroup" && !msg.Chat.IsForum) {
-		text := `<b>Требуются топики!</b>
+Каждый email-аккаунт привязывается к отдельному топику, так письма разных аккаунтов не смешиваются.`
 
-Этот бот работает только в супергруппах с включёнными топиками.
+	helpNoTopics = `<b>Чат без топиков</b>
 
-<b>Как включить:</b>
-1. Откройте настройки группы
-2. Найдите раздел "Темы" (Topics)
-3. Включите топики
+К этому чату можно подключить одну почту: /connect email password. Новые письма будут приходить сюда, для неё работают /status, /send, /silent, /quiet, /filter, /history, /export и другие команды.
 
-После этого каждый email можно будет привязать к отдельному топику.`
+Сделайте бота администратором, чтобы он удалял сообщение с паролем.
 
-		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, text)
-		return
-	}
+<b>Нужно несколько почт?</b>
+Включите топики: Настройки группы → Темы (Topics) → Включить. Тогда каждую почту можно будет привязать к отдельному топику.`
 
-	// Normal help for supergroups with topics
-	text := `<b>Email to Telegram Bot</b>
+	helpCommands = `<b>Email to Telegram Bot</b>
 
 Пересылка email сообщений в этот топик.
 
 <b>Команды:</b>
 /connect email password — подключить почту
-/disconnect — отключить почту
-/status — статус подключений`
-
-	// Add /create command info if Mailcow is configured
-	if b.mailcow != nil && b.mailcow.IsConfigured() {
-		text += fmt.Sprintf(`
-/create username — создать ящик на %s`, b.mailcow.GetDomain())
-	}
-
-	text += `
+/disconnect [--purge] — отключить почту (--purge: и удалить созданный ботом ящик)
+/move_account топик|ссылка|чат — перенести почту в другой топик или группу
+/setpassword — сменить пароль почты (через личные сообщения)
+/smime [off] — ключ S/MIME для расшифровки писем (загрузка через личные сообщения)
+/send адрес Тема | текст — написать письмо с почты топика (или просто /send)
+/status — статус подключений
+/dashboard — панель управления: аккаунты, письма и статистика
+/statusboard on|off — закреплённая панель статуса в этом топике
+/badge on [название]|off — число непрочитанных писем в названии топика
+/diagnose — возможности и задержки почтового сервера
+/test — отправить тестовое письмо в ящик и замерить, за сколько оно дойдёт
+/parsemode html|markdown — формат пересылаемых писем
+/language ru|en — язык бота в этом чате
+/autotopics on|off — /connect и /create в General создают топик для ящика
+/emoji — кастомные эмодзи для иконок
+/silent on|off — тихий режим топика
+/priority regex — письма, всегда приходящие со звуком
+/protect on|off — запрет пересылки и копирования писем
+/collapse 30m|off — группировка писем с одинаковой темой
+/pincodes 10m|off — закреплять письма с кодами на время
+/bulk deliver|drop|digest|topic ID — спам и рассылки: публиковать, пропускать, сводка или отдельный топик
+/digest daily|weekly|off — письма без кодов приходят одной сводкой
+/quiet 23:00-08:00 [пояс] [hold]|off — тихие часы: без звука или отложить до утра
+/profile detailed|compact|minimal — оформление писем в топике
+/template текст|off — свой шаблон писем с плейсхолдерами {subject}, {body:300} и др.
+/avatars photo|emoji|off — аватар или эмодзи домена отправителя у писем
+/idle auto|poll — получение почты через IMAP IDLE или опросом
+/settings poll 30s|idle 10m — частота опроса почты и перезапуск IDLE топика
+/filter allow|deny from|domain|subject шаблон — фильтры писем топика
+/codes add|test|del шаблон — свои шаблоны кодов топика
+/storage — сколько места занимают письма чата
+/tag qa prod — теги аккаунта топика (/untag qa — убрать)
+/pause, /resume [tag:qa] — приостановить или возобновить пересылку топика или всех аккаунтов тега
+/stats [tag:qa] — статистика писем за сутки
+/history — последние письма аккаунта топика
+/export csv|json|mbox [с] [по] — выгрузить сохранённые письма ящика файлом
+/redeliver [all|ID] — письма, которые не удалось отправить в Telegram, и повторная отправка
+/move archive|папка — перенести письмо, на которое ответили, в архив или папку
+/mirror [invite] — трансляция писем в топик другой группы, /unmirror — отключить
+/search номер — поиск заказа по номеру; в топике почты — поиск по письмам
+/report 2024-05 — расходы за месяц по письмам о заказах
+/extractors — обработчики писем Steam, Google, банков
+/announcements on|off — объявления владельца бота в этом чате
+/permissions команда роли — кто может выполнять команду (owner, admin, operator, member)
+/grant, /revoke — выдать или забрать права на управление ботом (ответом на сообщение пользователя, только владелец чата)
+/forgetme — удалить все данные чата (только владелец чата)
+/reparse ссылка|id|all — заново разобрать письмо (или все устаревшие) текущим парсером
+/refetch 20|2024-05-01 — загрузить последние письма ящика заново
+/markallread [from:домен] [older_than:30d] — пометить письма на сервере прочитанными
+/purge from:домен older_than:30d — удалить подходящие письма с сервера
+/dryrun письмо — показать, как было бы опубликовано письмо (текст или файл .eml)
+/import — импорт аккаунтов из CSV/JSON (отправьте файл с этой подписью)
+/version — версия бота`
+
+	// Mailcow commands, formatted with the domain
+	helpCreate = `
+/create username — создать ящик на %s
+/createbatch team{1..10} — создать несколько ящиков, каждый в своём топике`
+
+	helpFooter = `
 
 <b>Примеры:</b>
 <code>/connect user@gmail.com app_password</code>
 <code>/connect user@mail.ru pass imap.mail.ru:993</code>
+<code>/connect user@example.com pass imap.example.com:993 smtp.example.com:465</code>
 
 <b>Важно:</b>
 • Только администраторы могут управлять почтами
 • Для Gmail/Yandex нужен пароль приложения
-• IMAP сервер определяется автоматически`
+• IMAP и SMTP серверы определяются автоматически
+• Ответ администратора на письмо в топике отправляется отправителю письма`
+)
+
+// handleHelp handles /help command
+func (b *Bot) handleHelp(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	// Check if it's a private chat
+	if msg.Chat.Type == "private" {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, b.withCommandNames(i18n.T(ctx, helpPrivate)))
+		return
+	}
+
+	// Check if it's a group without topics
+	if msg.Chat.Type == "group" || (msg.Chat.Type == "supergroup" && !msg.Chat.IsForum) {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, b.withCommandNames(i18n.T(ctx, helpNoTopics)))
+		return
+	}
+
+	// Normal help for supergroups with topics
+	text := i18n.T(ctx, helpCommands)
+
+	// Add /create command info if Mailcow is configured
+	if b.mailcow != nil && b.mailcow.IsConfigured() {
+		text += i18n.Tf(ctx, helpCreate, b.mailcow.GetDomain())
+	}
+
+	text += i18n.T(ctx, helpFooter)
 
-	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, text)
+	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, b.withCommandNames(text))
 }
@@ -0,0 +1,136 @@
+package telegram
+
+import (
+	"context"
+	"html"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/mixelka/emailresend/internal/i18n"
+)
+
+// broadcastDelay spaces announcement messages to stay well within Telegram's
+// global and per-chat rate limits
+const broadcastDelay = 1500 * time.Millisecond
+
+// handleBroadcast handles /broadcast command
+// Usage: /broadcast <text>
+func (b *Bot) handleBroadcast(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	if !b.isOwner(msg.From.ID) {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Эта команда доступна только владельцу бота")
+		return
+	}
+
+	text := ""
+	if i := strings.IndexFunc(msg.Text, unicode.IsSpace); i >= 0 {
+		text = strings.TrimSpace(msg.Text[i:])
+	}
+	if text == "" {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+			"Использование: <code>/broadcast текст объявления</code>\n\nОбъявление получат все топики с подключённой почтой, кроме чатов, отключивших объявления командой /announcements off.")
+		return
+	}
+
+	b.broadcastMu.Lock()
+	if b.broadcasting {
+		b.broadcastMu.Unlock()
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Предыдущая рассылка ещё не завершена")
+		return
+	}
+	b.broadcasting = true
+	b.broadcastMu.Unlock()
+
+	accounts, err := b.db.GetBroadcastTargets(ctx, b.accountBotID())
+	if err != nil {
+		b.broadcastMu.Lock()
+		b.broadcasting = false
+		b.broadcastMu.Unlock()
+		b.logger.Error("failed to get broadcast targets", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка получения списка чатов")
+		return
+	}
+
+	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.Tf(ctx, "Рассылка запущена: %d топиков", len(accounts)))
+
+	announcement := i18n.T(ctx, "📢 <b>Объявление</b>\n\n") + html.EscapeString(text)
+	go func() {
+		defer func() {
+			b.broadcastMu.Lock()
+			b.broadcasting = false
+			b.broadcastMu.Unlock()
+		}()
+
+		var sent, failed int
+		for i, account := range accounts {
+			if i > 0 && !sleepCtx(ctx, broadcastDelay) {
+				return
+			}
+			if _, err := b.sendMessage(ctx, account.ChatID, account.TopicID, announcement); err != nil {
+				b.logger.Warn("failed to send announcement", "error", err, "chat_id", account.ChatID, "topic_id", account.TopicID)
+				failed++
+				continue
+			}
+			sent++
+		}
+
+		b.logger.Info("broadcast finished", "sent", sent, "failed", failed)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+			i18n.Tf(ctx, "Рассылка завершена. Доставлено: %d, ошибок: %d", sent, failed))
+	}()
+}
+
+// handleAnnouncements handles /announcements command
+// Usage: /announcements [on|off]
+func (b *Bot) handleAnnouncements(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	settings, err := b.db.GetChatSettings(ctx, msg.Chat.ID)
+	if err != nil {
+		b.logger.Error("failed to get chat settings", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка получения настроек")
+		return
+	}
+
+	parts := strings.Fields(msg.Text)
+	if len(parts) < 2 {
+		state := i18n.T(ctx, "включены")
+		if settings.BroadcastOptOut {
+			state = i18n.T(ctx, "выключены")
+		}
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+			i18n.Tf(ctx, "Объявления владельца бота: <b>%s</b>\n\nОбъявления о плановых работах и смене ключей приходят в каждый топик с почтой.\n\nИспользование: <code>/announcements on</code> или <code>/announcements off</code>", state))
+		return
+	}
+
+	if !b.checkAdmin(ctx, msg, "Только администраторы могут менять настройки") {
+		return
+	}
+
+	switch strings.ToLower(parts[1]) {
+	case "on":
+		settings.BroadcastOptOut = false
+	case "off":
+		settings.BroadcastOptOut = true
+	default:
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Использование: <code>/announcements on</code> или <code>/announcements off</code>")
+		return
+	}
+
+	if err := b.db.SaveChatSettings(ctx, settings); err != nil {
+		b.logger.Error("failed to save chat settings", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка сохранения настроек")
+		return
+	}
+
+	if settings.BroadcastOptOut {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Объявления владельца бота выключены для этого чата")
+	} else {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Объявления владельца бота включены")
+	}
+}
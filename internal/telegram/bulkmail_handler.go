@@ -0,0 +1,330 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"html"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/mixelka/emailresend/internal/email"
+	"github.com/mixelka/emailresend/internal/formatter"
+	"github.com/mixelka/emailresend/internal/i18n"
+	appmodels "github.com/mixelka/emailresend/pkg/models"
+)
+
+const (
+	// bulkMailTimeout bounds a /markallread or /purge run
+	bulkMailTimeout = 30 * time.Minute
+	// bulkProgressInterval limits how often the progress message is edited
+	bulkProgressInterval = 3 * time.Second
+	// purgeMailConfirmTTL is how long a /purge confirmation stays valid
+	purgeMailConfirmTTL = 5 * time.Minute
+)
+
+// bulkMailUsage explains the filters of /markallread and /purge
+const bulkMailUsage = `Использование:
+<code>/markallread</code> — пометить прочитанными все письма во «Входящих»
+<code>/purge from:spam.com older_than:30d</code> — удалить письма с сервера
+
+Фильтры:
+<code>from:</code> — адрес или домен отправителя
+<code>older_than:</code> — старше N дней, недель, месяцев или лет: <code>30d</code>, <code>2w</code>, <code>6m</code>, <code>1y</code>
+
+Для /purge нужен хотя бы один фильтр.`
+
+// bulkMailOp is a bulk operation on the mailbox of a topic
+type bulkMailOp int
+
+const (
+	bulkMarkRead bulkMailOp = iota
+	bulkPurge
+)
+
+// handleMarkAllRead handles /markallread command: marks the unread INBOX
+// emails of the topic's mailbox as read on the server
+// Usage: /markallread [from:domain.com] [older_than:30d]
+func (b *Bot) handleMarkAllRead(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	account, ok := b.getTopicAccount(ctx, msg)
+	if !ok {
+		return
+	}
+
+	if !b.checkAdmin(ctx, msg, "Только администраторы могут помечать письма прочитанными") {
+		return
+	}
+
+	filter, ok := parseBulkFilter(strings.Fields(msg.Text)[1:], time.Now())
+	if !ok {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.T(ctx, bulkMailUsage))
+		return
+	}
+
+	b.startBulkMail(ctx, account, msg.MessageThreadID, bulkMarkRead, filter)
+}
+
+// handlePurgeMail handles /purge command: after a confirmation, deletes the
+// INBOX emails of the topic's mailbox matching the filters from the server
+// Usage: /purge [from:domain.com] [older_than:30d]
+func (b *Bot) handlePurgeMail(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	account, ok := b.getTopicAccount(ctx, msg)
+	if !ok {
+		return
+	}
+
+	if !b.checkAdmin(ctx, msg, "Только администраторы могут удалять письма с сервера") {
+		return
+	}
+
+	filter, ok := parseBulkFilter(strings.Fields(msg.Text)[1:], time.Now())
+	if !ok || filter.From == "" && filter.Before.IsZero() {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.T(ctx, bulkMailUsage))
+		return
+	}
+
+	count, err := b.emailManager.CountBulk(account.ID, filter)
+	if err != nil {
+		b.logger.Error("failed to search emails", "error", err, "account_id", account.ID)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+			i18n.Tf(ctx, "Не удалось найти письма: <code>%s</code>", html.EscapeString(err.Error())))
+		return
+	}
+	if count == 0 {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Подходящих писем во «Входящих» нет")
+		return
+	}
+
+	// The confirmation replies to the command, whose filters it applies
+	text := i18n.Tf(ctx, "⚠️ <b>Удалить с сервера писем: %d?</b>\n\n%s\n\n"+
+		"Письма будут удалены из «Входящих» ящика %s без корзины, их сообщения в топике тоже. "+
+		"Подтвердить может администратор в течение 5 минут.",
+		count, describeBulkFilter(ctx, filter), html.EscapeString(account.Email))
+	keyboard := formatter.BuildConfirmKeyboard(appmodels.CallbackPurgeMail, account.ID, "🗑 Удалить")
+	if _, err := b.sendMessageWithKeyboard(ctx, msg.Chat.ID, msg.MessageThreadID, text, keyboard, messageOptions{ReplyTo: msg.ID}); err != nil {
+		b.logger.Error("failed to send purge confirmation", "error", err)
+	}
+}
+
+// handlePurgeMailConfirm handles the /purge confirmation buttons
+func (b *Bot) handlePurgeMailConfirm(ctx context.Context, callback *models.CallbackQuery, data appmodels.CallbackData) {
+	prompt := callback.Message.Message
+	if prompt == nil {
+		b.answerCallback(ctx, callback.ID, "Сообщение недоступно", false)
+		return
+	}
+	chatID := prompt.Chat.ID
+
+	if !b.isOperator(callback.From.ID) {
+		isAdmin, err := b.isUserAdmin(ctx, chatID, callback.From.ID)
+		if err != nil {
+			b.logger.Error("failed to check admin status", "error", err)
+			b.answerCallback(ctx, callback.ID, "Ошибка проверки прав", false)
+			return
+		}
+		if !isAdmin {
+			b.answerCallback(ctx, callback.ID, "Подтвердить удаление может только администратор", true)
+			return
+		}
+	}
+
+	if data.Arg != "yes" {
+		b.editMessageText(ctx, chatID, prompt.ID, "Удаление писем отменено")
+		b.answerCallback(ctx, callback.ID, "Отменено", false)
+		return
+	}
+
+	if time.Since(time.Unix(int64(prompt.Date), 0)) > purgeMailConfirmTTL {
+		b.editMessageText(ctx, chatID, prompt.ID, "Подтверждение устарело, отправьте /purge ещё раз")
+		b.answerCallback(ctx, callback.ID, "Подтверждение устарело", true)
+		return
+	}
+
+	// older_than counts from the command, not from the confirmation
+	var (
+		filter email.BulkFilter
+		ok     bool
+	)
+	if command := prompt.ReplyToMessage; command != nil {
+		if args := strings.Fields(command.Text); len(args) > 0 && strings.HasPrefix(args[0], "/purge") {
+			filter, ok = parseBulkFilter(args[1:], time.Unix(int64(command.Date), 0))
+		}
+	}
+	if !ok || filter.From == "" && filter.Before.IsZero() {
+		b.editMessageText(ctx, chatID, prompt.ID, "Команда /purge не найдена, отправьте её ещё раз")
+		b.answerCallback(ctx, callback.ID, "Команда не найдена", true)
+		return
+	}
+
+	account, err := b.db.GetAccountByID(ctx, data.MessageID)
+	if err != nil || account.ChatID != chatID {
+		b.editMessageText(ctx, chatID, prompt.ID, "Почта уже отключена")
+		b.answerCallback(ctx, callback.ID, "Аккаунт не найден", false)
+		return
+	}
+
+	b.logger.Info("purging emails", "account_id", account.ID, "from", filter.From, "before", filter.Before, "user_id", callback.From.ID)
+	b.editMessageText(ctx, chatID, prompt.ID, i18n.Tf(ctx, "🗑 Удаление писем подтверждено\n\n%s", describeBulkFilter(ctx, filter)))
+	b.answerCallback(ctx, callback.ID, "Удаляю письма", false)
+	b.startBulkMail(ctx, account, prompt.MessageThreadID, bulkPurge, filter)
+}
+
+// startBulkMail posts the progress message of a bulk operation and runs it
+// in the background: large mailboxes take minutes
+func (b *Bot) startBulkMail(ctx context.Context, account *appmodels.EmailAccount, topicID int, op bulkMailOp, filter email.BulkFilter) {
+	progress, err := b.sendMessage(ctx, account.ChatID, topicID, "⏳ Ищу письма на сервере...")
+	if err != nil {
+		b.logger.Error("failed to send progress message", "error", err)
+		return
+	}
+	go b.runBulkMail(b.chatContext(context.Background(), account.ChatID), account, progress.ID, op, filter)
+}
+
+// runBulkMail marks as read or purges the emails matching a filter on the
+// server, batch by batch, and updates the stored emails and their Telegram
+// messages: read ones lose the read button, purged ones are deleted
+func (b *Bot) runBulkMail(ctx context.Context, account *appmodels.EmailAccount, progressID int, op bulkMailOp, filter email.BulkFilter) {
+	ctx, cancel := context.WithTimeout(ctx, bulkMailTimeout)
+	defer cancel()
+
+	var (
+		changed      []*appmodels.EmailMessage
+		lastProgress time.Time
+	)
+	report := func(uids []uint32, done, total int) {
+		messages, err := b.db.GetInboxMessagesByUIDs(ctx, account.ID, uids)
+		if err != nil {
+			b.logger.Error("failed to get messages by UID", "error", err, "account_id", account.ID)
+		}
+		for _, msg := range messages {
+			mark := b.db.MarkMessageAsRead
+			if op == bulkPurge {
+				mark = b.db.MarkMessageAsDeleted
+			} else if msg.IsRead {
+				continue
+			}
+			if err := mark(ctx, msg.ID); err != nil {
+				b.logger.Error("failed to update message", "error", err, "message_id", msg.ID)
+				continue
+			}
+			if msg.TelegramMsgID != 0 {
+				changed = append(changed, msg)
+			}
+		}
+
+		if done < total && time.Since(lastProgress) < bulkProgressInterval {
+			return
+		}
+		lastProgress = time.Now()
+		b.editMessageText(ctx, account.ChatID, progressID, i18n.Tf(ctx, "⏳ Обработано писем: %d из %d", done, total))
+	}
+
+	var (
+		done int
+		err  error
+	)
+	if op == bulkPurge {
+		done, err = b.emailManager.Purge(ctx, account.ID, filter, report)
+	} else {
+		done, err = b.emailManager.MarkAllRead(ctx, account.ID, filter, report)
+	}
+	b.logger.Info("bulk mail operation finished", "account_id", account.ID, "purge", op == bulkPurge, "messages", done, "error", err)
+	b.wakeUnreadBadges()
+
+	// Telegram messages follow once the server is done, edits are rate limited
+	for _, msg := range changed {
+		b.unpinCode(ctx, msg.ID)
+		if op == bulkPurge {
+			b.deleteMessage(ctx, account.ChatID, msg.TelegramMsgID)
+			continue
+		}
+		msg.IsRead = true
+		if err := b.refreshEmailMessage(ctx, account, msg, decodeCodes(msg.DetectedCodes)); err != nil {
+			b.logger.Warn("failed to update email message", "error", err, "message_id", msg.ID)
+		}
+	}
+
+	text := i18n.Tf(ctx, "✅ Помечено прочитанными: %d", done)
+	if op == bulkPurge {
+		text = i18n.Tf(ctx, "✅ Удалено с сервера писем: %d", done)
+	}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		text += "\n" + i18n.T(ctx, "⚠️ Время вышло, обработаны не все письма — повторите команду")
+	case err != nil:
+		text += "\n" + i18n.Tf(ctx, "⚠️ Остановлено из-за ошибки: <code>%s</code>", html.EscapeString(err.Error()))
+	}
+	b.editMessageText(ctx, account.ChatID, progressID, text)
+}
+
+// parseBulkFilter parses the from: and older_than: filters of /markallread
+// and /purge; older_than counts back from now
+func parseBulkFilter(args []string, now time.Time) (email.BulkFilter, bool) {
+	var filter email.BulkFilter
+	for _, arg := range args {
+		key, value, _ := strings.Cut(arg, ":")
+		if value == "" {
+			return filter, false
+		}
+		switch strings.ToLower(key) {
+		case "from":
+			filter.From = value
+		case "older_than":
+			before, ok := olderThan(value, now)
+			if !ok {
+				return filter, false
+			}
+			filter.Before = before
+		default:
+			return filter, false
+		}
+	}
+	return filter, true
+}
+
+// olderThan turns an age like 30d, 2w, 6m or 1y into the day before which
+// messages are older than it
+func olderThan(age string, now time.Time) (time.Time, bool) {
+	if len(age) < 2 {
+		return time.Time{}, false
+	}
+	n, err := strconv.Atoi(age[:len(age)-1])
+	if err != nil || n <= 0 {
+		return time.Time{}, false
+	}
+
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch strings.ToLower(age[len(age)-1:]) {
+	case "d":
+		return day.AddDate(0, 0, -n), true
+	case "w":
+		return day.AddDate(0, 0, -7*n), true
+	case "m":
+		return day.AddDate(0, -n, 0), true
+	case "y":
+		return day.AddDate(-n, 0, 0), true
+	}
+	return time.Time{}, false
+}
+
+// describeBulkFilter lists the filters of a bulk operation for its messages
+func describeBulkFilter(ctx context.Context, filter email.BulkFilter) string {
+	var lines []string
+	if filter.From != "" {
+		lines = append(lines, i18n.Tf(ctx, "Отправитель: <code>%s</code>", html.EscapeString(filter.From)))
+	}
+	if !filter.Before.IsZero() {
+		lines = append(lines, i18n.Tf(ctx, "Получены до %s", filter.Before.Format(time.DateOnly)))
+	}
+	if len(lines) == 0 {
+		return i18n.T(ctx, "Все письма во «Входящих»")
+	}
+	return strings.Join(lines, "\n")
+}
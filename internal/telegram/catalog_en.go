@@ -0,0 +1,858 @@
+package telegram
+
+import "github.com/mixelka/emailresend/internal/i18n"
+
+// English translations of the bot messages, keyed by the Russian originals
+func init() {
+	i18n.Register(i18n.English, map[string]string{
+		// auto_topics
+		"Почта <b>%s</b> подключена к новому топику: %s": "Mailbox <b>%s</b> is connected to a new topic: %s",
+
+		// bot
+		"Только администраторы могут подключать почтовые аккаунты":    "Only administrators can connect email accounts",
+		"Только администраторы могут создавать почтовые ящики":        "Only administrators can create mailboxes",
+		"Только администраторы могут отключать почтовые аккаунты":     "Only administrators can disconnect email accounts",
+		"Только администраторы могут переносить почтовые аккаунты":    "Only administrators can move email accounts",
+		"Только администраторы могут приостанавливать пересылку":      "Only administrators can pause forwarding",
+		"Только администраторы могут возобновлять пересылку":          "Only administrators can resume forwarding",
+		"Только администраторы могут настраивать трансляцию писем":    "Only administrators can set up email mirroring",
+		"Только администраторы могут проверять разбор писем":          "Only administrators can check email parsing",
+		"Только администраторы могут менять права на команды":         "Only administrators can change command permissions",
+		"Только администраторы могут импортировать почтовые аккаунты": "Only administrators can import email accounts",
+		helpPrivate:  "<b>Email to Telegram Bot</b>\n\nA bot that forwards email messages to Telegram.\n\n<b>One mailbox — right here:</b>\n/connect email password — connect a mailbox to this chat\nNew emails will arrive here; /status, /send, /setpassword, /silent, /quiet, /filter, /history, /export and other commands work for it.\n\n<b>Several mailboxes — in a group with topics:</b>\n1. Create a supergroup\n2. Enable topics: Group settings → Topics → Enable\n3. Add the bot to the group and make it an administrator\n4. Use /connect in the topic you need\n\nEach email account is bound to its own topic, so emails from different accounts do not mix.",
+		helpNoTopics: "<b>Chat without topics</b>\n\nOne mailbox can be connected to this chat: /connect email password. New emails will arrive here; /status, /send, /silent, /quiet, /filter, /history, /export and other commands work for it.\n\nMake the bot an administrator so it can delete the message with the password.\n\n<b>Need several mailboxes?</b>\nEnable topics: Group settings → Topics → Enable. Then each mailbox can be bound to its own topic.",
+		helpCommands: "<b>Email to Telegram Bot</b>\n\nForwarding email messages to this topic.\n\n<b>Commands:</b>\n/connect email password — connect a mailbox\n/disconnect [--purge] — disconnect the mailbox (--purge: also delete a mailbox created by the bot)\n/move_account topic|link|chat — move the mailbox to another topic or group\n/setpassword — change the mailbox password (via private messages)\n/smime [off] — S/MIME key to decrypt emails (uploaded via private messages)\n/send address Subject | text — send an email from the topic's mailbox (or just /send)\n/status — connection status\n/dashboard — dashboard: accounts, emails and stats\n/statusboard on|off — pinned status board in this topic\n/badge on [name]|off — number of unread emails in the topic name\n/diagnose — mail server capabilities and latency\n/test — send a test email to the mailbox and measure how long it takes to arrive\n/parsemode html|markdown — format of forwarded emails\n/language ru|en — bot language in this chat\n/autotopics on|off — /connect and /create in General create a topic for the mailbox\n/emoji — custom emoji for icons\n/silent on|off — silent mode for the topic\n/priority regex — emails that always arrive with sound\n/protect on|off — forbid forwarding and copying emails\n/collapse 30m|off — group emails with the same subject\n/pincodes 10m|off — pin emails with codes for a while\n/bulk deliver|drop|digest|topic ID — spam and newsletters: post, skip, digest or a separate topic\n/digest daily|weekly|off — emails without codes arrive as one digest\n/quiet 23:00-08:00 [zone] [hold]|off — quiet hours: silent or held until morning\n/profile detailed|compact|minimal — email layout in the topic\n/template text|off — custom email template with placeholders {subject}, {body:300} etc.\n/avatars photo|emoji|off — sender avatar or domain emoji on emails\n/idle auto|poll — receive mail via IMAP IDLE or polling\n/settings poll 30s|idle 10m — mail polling interval and IDLE restart of the topic\n/filter allow|deny from|domain|subject pattern — email filters of the topic\n/codes add|test|del pattern — custom code patterns of the topic\n/storage — how much space the chat's emails take\n/tag qa prod — tags of the topic's account (/untag qa — remove)\n/pause, /resume [tag:qa] — pause or resume forwarding of the topic or of all accounts with the tag\n/stats [tag:qa] — email statistics for the last day\n/history — latest emails of the topic's account\n/export csv|json|mbox [from] [to] — download the stored emails of the mailbox as a file\n/redeliver [all|ID] — emails that could not be posted to Telegram, and sending them again\n/move archive|folder — move the replied email to the archive or a folder\n/mirror [invite] — mirror emails to a topic of another group, /unmirror — stop\n/search number — search an order by number; in a mail topic — search emails\n/report 2024-05 — monthly spending from order emails\n/extractors — Steam, Google and bank email handlers\n/announcements on|off — bot owner announcements in this chat\n/permissions command roles — who can run a command (owner, admin, operator, member)\n/grant, /revoke — grant or take away bot management (in reply to the user's message, chat owner only)\n/forgetme — delete all chat data (chat owner only)\n/reparse link|id|all — parse an email (or all outdated ones) again with the current parser\n/refetch 20|2024-05-01 — load the latest emails of the mailbox again\n/markallread [from:domain] [older_than:30d] — mark emails as read on the server\n/purge from:domain older_than:30d — delete matching emails from the server\n/dryrun email — show how an email would be posted (text or .eml file)\n/import — import accounts from CSV/JSON (send a file with this caption)\n/version — bot version",
+		helpCreate:   "\n/create username — create a mailbox on %s\n/createbatch team{1..10} — create several mailboxes, each in its own topic",
+		helpFooter:   "\n\n<b>Examples:</b>\n<code>/connect user@gmail.com app_password</code>\n<code>/connect user@mail.ru pass imap.mail.ru:993</code>\n<code>/connect user@example.com pass imap.example.com:993 smtp.example.com:465</code>\n\n<b>Important:</b>\n• Only administrators can manage mailboxes\n• Gmail/Yandex need an app password\n• IMAP and SMTP servers are detected automatically\n• An administrator's reply to an email in the topic is sent to the email's sender",
+
+		// broadcast
+		"Эта команда доступна только владельцу бота": "This command is only available to the bot owner",
+		"Использование: <code>/broadcast текст объявления</code>\n\nОбъявление получат все топики с подключённой почтой, кроме чатов, отключивших объявления командой /announcements off.": "Usage: <code>/broadcast announcement text</code>\n\nThe announcement is sent to every topic with a connected mailbox, except chats that turned announcements off with /announcements off.",
+		"Предыдущая рассылка ещё не завершена":           "The previous broadcast has not finished yet",
+		"Ошибка получения списка чатов":                  "Failed to get the list of chats",
+		"Рассылка запущена: %d топиков":                  "Broadcast started: %d topics",
+		"📢 <b>Объявление</b>\n\n":                        "📢 <b>Announcement</b>\n\n",
+		"Рассылка завершена. Доставлено: %d, ошибок: %d": "Broadcast finished. Delivered: %d, errors: %d",
+		"Ошибка получения настроек":                      "Failed to get settings",
+		"включены":  "on",
+		"выключены": "off",
+		"Объявления владельца бота: <b>%s</b>\n\nОбъявления о плановых работах и смене ключей приходят в каждый топик с почтой.\n\nИспользование: <code>/announcements on</code> или <code>/announcements off</code>": "Bot owner announcements: <b>%s</b>\n\nAnnouncements about maintenance and key changes are sent to every topic with a mailbox.\n\nUsage: <code>/announcements on</code> or <code>/announcements off</code>",
+		"Только администраторы могут менять настройки":                                      "Only administrators can change settings",
+		"Использование: <code>/announcements on</code> или <code>/announcements off</code>": "Usage: <code>/announcements on</code> or <code>/announcements off</code>",
+		"Ошибка сохранения настроек":                                                        "Failed to save settings",
+		"Объявления владельца бота выключены для этого чата":                                "Bot owner announcements are turned off for this chat",
+		"Объявления владельца бота включены":                                                "Bot owner announcements are turned on",
+
+		// bulkmail_handler
+		"Использование:\n<code>/markallread</code> — пометить прочитанными все письма во «Входящих»\n<code>/purge from:spam.com older_than:30d</code> — удалить письма с сервера\n\nФильтры:\n<code>from:</code> — адрес или домен отправителя\n<code>older_than:</code> — старше N дней, недель, месяцев или лет: <code>30d</code>, <code>2w</code>, <code>6m</code>, <code>1y</code>\n\nДля /purge нужен хотя бы один фильтр.": "Usage:\n<code>/markallread</code> — mark all emails in the inbox as read\n<code>/purge from:spam.com older_than:30d</code> — delete emails from the server\n\nFilters:\n<code>from:</code> — sender address or domain\n<code>older_than:</code> — older than N days, weeks, months or years: <code>30d</code>, <code>2w</code>, <code>6m</code>, <code>1y</code>\n\n/purge needs at least one filter.",
+		"Только администраторы могут помечать письма прочитанными": "Only admins can mark emails as read",
+		"Только администраторы могут удалять письма с сервера":     "Only admins can delete emails from the server",
+		"Не удалось найти письма: <code>%s</code>":                 "Failed to search emails: <code>%s</code>",
+		"Подходящих писем во «Входящих» нет":                       "No matching emails in the inbox",
+		"⚠️ <b>Удалить с сервера писем: %d?</b>\n\n%s\n\nПисьма будут удалены из «Входящих» ящика %s без корзины, их сообщения в топике тоже. Подтвердить может администратор в течение 5 минут.": "⚠️ <b>Delete %d emails from the server?</b>\n\n%s\n\nThe emails will be deleted from the inbox of %s without going to the trash, and so will their messages in the topic. An admin can confirm within 5 minutes.",
+		"🗑 Удалить":               "🗑 Delete",
+		"Удаление писем отменено": "Deleting emails cancelled",
+		"Подтверждение устарело, отправьте /purge ещё раз":             "The confirmation has expired, send /purge again",
+		"Команда /purge не найдена, отправьте её ещё раз":              "The /purge command was not found, send it again",
+		"Команда не найдена":                                           "Command not found",
+		"🗑 Удаление писем подтверждено\n\n%s":                          "🗑 Deleting emails confirmed\n\n%s",
+		"Удаляю письма":                                                "Deleting emails",
+		"⏳ Ищу письма на сервере...":                                   "⏳ Searching emails on the server...",
+		"⏳ Обработано писем: %d из %d":                                 "⏳ Emails processed: %d of %d",
+		"✅ Помечено прочитанными: %d":                                  "✅ Marked as read: %d",
+		"✅ Удалено с сервера писем: %d":                                "✅ Emails deleted from the server: %d",
+		"⚠️ Время вышло, обработаны не все письма — повторите команду": "⚠️ Time is up, not all emails were processed — run the command again",
+		"⚠️ Остановлено из-за ошибки: <code>%s</code>":                 "⚠️ Stopped by an error: <code>%s</code>",
+		"Отправитель: <code>%s</code>":                                 "Sender: <code>%s</code>",
+		"Получены до %s":                                               "Received before %s",
+		"Все письма во «Входящих»":                                     "All emails in the inbox",
+
+		// codes_handler
+		"Использование:\n<code>/codes add \"Ваш промокод[:\\s]+([A-Z0-9]{6})\"</code> — добавить шаблон; код — первая группа в скобках, без групп — всё совпадение\n<code>/codes test \"шаблон\" текст</code> — проверить шаблон на примере текста (его можно дать следующими строками или ответом на сообщение)\n<code>/codes del 3</code> — удалить шаблон": "Usage:\n<code>/codes add \"Your promo code[:\\s]+([A-Z0-9]{6})\"</code> — add a pattern; the code is the first group in parentheses, without groups the whole match\n<code>/codes test \"pattern\" text</code> — try a pattern on sample text (it can also go on the next lines or be a reply to a message)\n<code>/codes del 3</code> — delete a pattern",
+		"Только администраторы могут менять шаблоны кодов":                          "Only administrators can change code patterns",
+		"Ошибка получения шаблонов кодов":                                           "Failed to get code patterns",
+		"<b>Свои шаблоны кодов этого топика:</b>\n\n":                               "<b>Custom code patterns of this topic:</b>\n\n",
+		"Шаблонов нет, коды ищутся только общими шаблонами\n":                       "No patterns, codes are found by the common patterns only\n",
+		"У топика уже %d шаблонов, удалите ненужные: <code>/codes del номер</code>": "The topic already has %d patterns, delete unused ones: <code>/codes del number</code>",
+		"Такой шаблон уже есть под номером <code>%d</code>":                         "This pattern already exists as number <code>%d</code>",
+		"Ошибка сохранения шаблона":                                                 "Failed to save the pattern",
+		"Шаблон <code>%d</code> добавлен: <code>%s</code>\nОн применяется к новым письмам, старые можно разобрать заново через /reparse": "Pattern <code>%d</code> added: <code>%s</code>\nIt applies to new emails, older ones can be parsed again with /reparse",
+		"Использование: <code>/codes del номер_шаблона</code>": "Usage: <code>/codes del pattern_number</code>",
+		"Некорректный номер шаблона":                           "Invalid pattern number",
+		"Шаблон не найден":                                     "Pattern not found",
+		"Ошибка удаления шаблона":                              "Failed to delete the pattern",
+		"Шаблон <code>%d</code> удалён":                        "Pattern <code>%d</code> deleted",
+		"🧪 <b>Проверка шаблона</b>\n":                          "🧪 <b>Pattern test</b>\n",
+		"Шаблон нашёл: %s\n":                                   "The pattern found: %s\n",
+		"Шаблон ничего не нашёл в тексте\n":                    "The pattern found nothing in the text\n",
+		"Общие шаблоны нашли: %s\n":                            "The common patterns found: %s\n",
+		"Общие шаблоны ничего не нашли\n":                      "The common patterns found nothing\n",
+
+		// createbatch_handler
+		"Интеграция с Mailcow не настроена": "Mailcow integration is not configured",
+		"Использование: <code>/createbatch team{1..10}</code>\n\nБудут созданы ящики team1@%s ... team10@%s, для каждого — отдельный топик. В конце придёт файл с паролями в формате /import.": "Usage: <code>/createbatch team{1..10}</code>\n\nMailboxes team1@%s ... team10@%s will be created, each with its own topic. A file with the passwords in /import format is sent at the end.",
+		"Ящики создаются только на домене %s":                                            "Mailboxes can only be created on the %s domain",
+		"Некорректный шаблон: <code>%s</code>":                                           "Invalid pattern: <code>%s</code>",
+		"Создание ящиков: 0/%d...":                                                       "Creating mailboxes: 0/%d...",
+		"Создание ящиков: %d/%d...":                                                      "Creating mailboxes: %d/%d...",
+		"Почтовый ящик <b>%s</b> создан и подключён к этому топику.":                     "Mailbox <b>%s</b> has been created and connected to this topic.",
+		"🔐 Учётные данные %d ящиков (SMTP: %s). Сохраните файл и удалите это сообщение.": "🔐 Credentials of %d mailboxes (SMTP: %s). Save the file and delete this message.",
+
+		// diagnose_handler
+		"без него новые письма можно получать только периодическим опросом":                            "without it new emails can only be received by periodic polling",
+		"без него перемещение писем выполняется копированием и удалением":                              "without it emails are moved by copying and deleting",
+		"без него каждая проверка запрашивает состояние писем целиком, а не только изменения":          "without it every check fetches the state of all emails rather than only the changes",
+		"без него удалённые в ящике письма ищутся периодической проверкой, а не по счётчику изменений": "without it emails deleted in the mailbox are looked for by periodic checks rather than by the change counter",
+		"без него нельзя узнать заполненность ящика":                                                   "without it the mailbox usage cannot be determined",
+		"без него «Удалить» стирает из ящика все письма с пометкой на удаление, а не только выбранное": "without it «Delete» expunges every email marked for deletion, not only the selected one",
+		"⚠️ нет, пароль и письма передаются открытым текстом":                                          "⚠️ none, the password and emails are sent in plain text",
+		"⏳ Проверяю сервер <b>%s</b>...":  "⏳ Checking server <b>%s</b>...",
+		"🩺 <b>Диагностика %s</b>\n":       "🩺 <b>Diagnostics of %s</b>\n",
+		"Сервер: <code>%s</code>\n":       "Server: <code>%s</code>\n",
+		"Шифрование: %s\n":                "Encryption: %s\n",
+		"\n<b>Возможности сервера:</b>\n": "\n<b>Server capabilities:</b>\n",
+		"\n<b>Аутентификация:</b> ":       "\n<b>Authentication:</b> ",
+		"только LOGIN":                    "LOGIN only",
+		"⚠️ Сервер запрещает LOGIN, который использует бот\n": "⚠️ The server disables LOGIN, which the bot uses\n",
+		"\n<b>Задержки:</b>\n":      "\n<b>Latency:</b>\n",
+		"TCP-соединение":            "TCP connection",
+		"TLS-рукопожатие":           "TLS handshake",
+		"Приветствие сервера":       "Server greeting",
+		"Вход":                      "Login",
+		"Открытие INBOX":            "Opening INBOX",
+		"\nПисем во входящих: %d\n": "\nEmails in the inbox: %d\n",
+		"\n❌ Ошибка: %s":            "\n❌ Error: %s",
+
+		// digest
+		"по понедельникам в %s":                 "on Mondays at %s",
+		"ежедневно в %s":                        "daily at %s",
+		"Сводка за сутки":                       "Daily digest",
+		"Сводка за неделю":                      "Weekly digest",
+		"📰 <b>%s: %d писем</b>\n\n":             "📰 <b>%s: %d emails</b>\n\n",
+		"… и ещё %d, все письма — в /history\n": "… and %d more, all emails are in /history\n",
+
+		// dryrun_handler
+		"свой шаблон (/codes)": "custom pattern (/codes)",
+		dryRunUsage:            "Send a whole email with headers after the <code>/dryrun</code> command, an .eml file with the <code>/dryrun</code> caption, or reply with the command to a message with a file.\n\nThe bot parses the email as if it had arrived to this topic's mailbox and shows what would be posted and why. Nothing is saved.",
+		"Не удалось разобрать письмо: нет ни заголовков, ни текста\n\n": "Failed to parse the email: it has neither headers nor text\n\n",
+		"🧪 <b>Пробный разбор письма</b>\n\n":                            "🧪 <b>Email dry run</b>\n\n",
+		"\n<b>Оформление:</b> профиль %s, разметка %s\n":                "\n<b>Layout:</b> profile %s, markup %s\n",
+		"Кнопки: ": "Buttons: ",
+		"\nСообщение ниже — так письмо выглядело бы в топике (без кнопок).": "\nThe message below is how the email would look in the topic (without buttons).",
+		"Telegram не принял сообщение письма: <code>%s</code>":              "Telegram rejected the email message: <code>%s</code>",
+		"Ошибка загрузки файла: %v":                                         "Failed to download the file: %v",
+		"<b>Разбор:</b>\n":                     "<b>Parsing:</b>\n",
+		"От: %s\n":                             "From: %s\n",
+		"Тема: %s\n":                           "Subject: %s\n",
+		"нет текста":                           "no text",
+		"из HTML-части":                        "from the HTML part",
+		"из текстовой части":                   "from the text part",
+		"Текст: %d симв., %s":                  "Text: %d chars, %s",
+		", вложений: %d":                       ", attachments: %d",
+		"\n<b>Распознано:</b>\n":               "\n<b>Detected:</b>\n",
+		"общие шаблоны кодов":                  "generic code patterns",
+		"экстрактор ":                          "extractor ",
+		"Кодов нет\n":                          "No codes\n",
+		"Код <code>%s</code> (%s) — %s\n":      "Code <code>%s</code> (%s) — %s\n",
+		"Экстрактор %s: %d полей, %d ссылок\n": "Extractor %s: %d fields, %d links\n",
+		"Заказ <code>%s</code> — будет в /search и отчётах\n":                                    "Order <code>%s</code> — will be in /search and reports\n",
+		"Кнопка-ссылка %s: %s\n":                                                                 "Link button %s: %s\n",
+		"Трек-номер %s <code>%s</code>\n":                                                        "Tracking number %s <code>%s</code>\n",
+		"\n<b>Доставка:</b>\n":                                                                   "\n<b>Delivery:</b>\n",
+		"⏸ Пересылка почты приостановлена (/resume)\n":                                           "⏸ Mail forwarding is paused (/resume)\n",
+		"⛔ Не будет опубликовано: такое же письмо уже пришло недавно (#%d)\n":                    "⛔ Will not be posted: the same email arrived recently (#%d)\n",
+		"⛔ Не будет опубликовано: письмо с этим Message-ID <a href=\"%s\">уже было в чате</a>\n": "⛔ Will not be posted: an email with this Message-ID <a href=\"%s\">is already in the chat</a>\n",
+		"Фильтр <code>%d</code>: %s %s <code>%s</code>\n":                                        "Filter <code>%d</code>: %s %s <code>%s</code>\n",
+		"Фильтр: письмо не подходит ни под одно правило allow\n":                                 "Filter: the email matches no allow rule\n",
+		"Фильтр: ни одно правило не подошло\n":                                                   "Filter: no rule matched\n",
+		"⛔ Не будет опубликовано: отфильтровано\n":                                               "⛔ Will not be posted: filtered out\n",
+		"🗂 Будет опубликовано без звука в топике отфильтрованных писем <code>%d</code>\n":        "🗂 Will be posted silently to the filtered emails topic <code>%d</code>\n",
+		"📦 Попадёт в часовую сводку: от отправителя уже %d писем за этот час при лимите %d\n":    "📦 Will go to the hourly digest: the sender has already sent %d emails this hour, the limit is %d\n",
+		"✅ Будет объединено с недавним сообщением с той же темой (/collapse)\n":                  "✅ Will be merged with a recent message with the same subject (/collapse)\n",
+		"✅ Будет опубликовано в этом топике\n":                                                   "✅ Will be posted in this topic\n",
+		"🔔 Со звуком: в письме есть код или оно подходит под /priority\n":                        "🔔 With sound: the email has a code or matches /priority\n",
+		"🔕 Без звука (/silent)\n":                                                                "🔕 Silent (/silent)\n",
+		"🌙 Будет отложено до конца тихих часов, %s (/quiet)\n":                                   "🌙 Will be held until quiet hours end, %s (/quiet)\n",
+		"🔕 Без звука: тихие часы (/quiet)\n":                                                     "🔕 Silent: quiet hours (/quiet)\n",
+		"⛔ Не будет опубликовано: письмо с этим Message-ID уже было в чате\n":                    "⛔ Will not be posted: an email with this Message-ID was already in the chat\n",
+		"Отправитель: ✅ подтверждён":                                                             "Sender: ✅ verified",
+		"Отправитель: ⚠️ не подтверждён":                                                         "Sender: ⚠️ not verified",
+		"Отправитель: нет подписи DKIM и результатов SPF\n":                                      "Sender: no DKIM signature or SPF results\n",
+
+		"рассылка":             "newsletter",
+		"спам":                 "spam",
+		"Заголовки: %s (%s)\n": "Headers: %s (%s)\n",
+		"⛔ Не будет опубликовано: спам и рассылки отключены (/bulk)\n":       "⛔ Will not be posted: spam and newsletters are skipped (/bulk)\n",
+		"📰 Попадёт в сводку, она приходит %s (/bulk)\n":                      "📰 Will go to the digest, sent %s (/bulk)\n",
+		"📰 Попадёт в сводку, она приходит %s (/digest)\n":                    "📰 Will go to the digest, sent %s (/digest)\n",
+		"🗂 Будет опубликовано без звука в топике рассылок <code>%d</code>\n": "🗂 Will be posted silently to the newsletters topic <code>%d</code>\n",
+
+		// email_handler
+		connectionLimitHint: "This usually happens when the mailbox is open in mail apps on a computer or phone at the same time (especially with Mail.ru). What you can do:\n• close extra mail apps or switch them to POP3\n• end other sessions in the mailbox security settings\n• connect a separate mailbox with forwarding to the bot",
+		authHint:            "Many mail services (Gmail, Yandex, Mail.ru, iCloud) do not allow IMAP access with the main password: you need an app password, and IMAP access must be enabled in the mailbox settings.",
+		"Сервер отклонил подключение: превышено число одновременных IMAP-соединений.\n\n": "The server refused the connection: too many simultaneous IMAP connections.\n\n",
+		"Сервер временно ограничил подключения. Попробуйте через несколько минут.":        "The server has temporarily limited connections. Try again in a few minutes.",
+		"Неверный адрес или пароль.\n\n":     "Wrong address or password.\n\n",
+		"На сервере не найдена папка INBOX.": "The INBOX folder was not found on the server.",
+		"Не удалось связаться с IMAP-сервером. Проверьте его адрес или попробуйте позже.": "Failed to reach the IMAP server. Check its address or try again later.",
+		"Ошибка подключения.": "Connection error.",
+		"⚠️ Сервер отклоняет подключение к почте <b>%s</b>: превышено число одновременных IMAP-соединений.\n\nБот будет переподключаться реже, пока лимит не освободится.\n\n%s": "⚠️ The server refuses connections to mailbox <b>%s</b>: too many simultaneous IMAP connections.\n\nThe bot will reconnect less often until the limit is freed.\n\n%s",
+		"⚠️ Сервер временно ограничил подключения к почте <b>%s</b>.\n\nБот будет переподключаться реже, пока ограничение не снимут.":                                            "⚠️ The server has temporarily limited connections to mailbox <b>%s</b>.\n\nThe bot will reconnect less often until the limit is lifted.",
+		"⚠️ На сервере почты <b>%s</b> не найдена папка INBOX.\n\nПроверьте ящик в веб-интерфейсе почты. Бот будет проверять его реже.":                                          "⚠️ The INBOX folder of mailbox <b>%s</b> was not found on the server.\n\nCheck the mailbox in the webmail. The bot will check it less often.",
+		"Ошибка подключения к почте <b>%s</b>:\n<code>%s</code>\n\nПопытка переподключения...":                                                                                   "Connection error for mailbox <b>%s</b>:\n<code>%s</code>\n\nReconnecting...",
+		"⚠️ Не удаётся подключиться к почте <b>%s</b>: %d неудачных попыток подряд.\n<code>%s</code>\n\nБот будет пробовать раз в %s. Состояние — в /status.":                    "⚠️ Cannot connect to mailbox <b>%s</b>: %d failed attempts in a row.\n<code>%s</code>\n\nThe bot will retry once every %s. See /status for details.",
+
+		// export_handler
+		"Экспорт доступен только в личном чате с ботом": "Export is only available in a private chat with the bot",
+		"Использование: <code>/exportcreds age1...</code> или <code>/exportcreds</code> с публичным PGP-ключем (ASCII armor) в том же сообщении\n\nПароли всех аккаунтов будут зашифрованы этим ключом; расшифрованный файл можно снова загрузить через /import.": "Usage: <code>/exportcreds age1...</code> or <code>/exportcreds</code> with a public PGP key (ASCII armor) in the same message\n\nThe passwords of all accounts will be encrypted with this key; the decrypted file can be uploaded again via /import.",
+		"Ошибка получения аккаунтов":                               "Failed to get accounts",
+		"Ошибка расшифровки паролей":                               "Failed to decrypt passwords",
+		"Ошибка шифрования: %v":                                    "Encryption error: %v",
+		"🔐 Экспорт учётных данных: %d аккаунтов, зашифровано (%s)": "🔐 Credentials export: %d accounts, encrypted (%s)",
+
+		// filter_handler
+		filterUsage: "Usage:\n<code>/filter deny from spam@example.com</code> — sender address\n<code>/filter deny domain example.com</code> — sender domain and its subdomains\n<code>/filter deny subject (?i)sale|discount</code> — regular expression on the subject\n<code>/filter allow ...</code> — always let through; if there are allow rules, all other emails are filtered out\n<code>/filter del 3</code> — delete a rule\n<code>/filter spam 42</code> — send filtered emails to the topic with ID 42 (<code>/filter spam off</code> — drop them)",
+		"Только администраторы могут менять фильтры":                                           "Only administrators can change filters",
+		"Ошибка получения фильтров":                                                            "Failed to get filters",
+		"<b>Фильтры писем этого топика:</b>\n\n":                                               "<b>Email filters of this topic:</b>\n\n",
+		"Правил нет, пересылаются все письма\n":                                                "No rules, all emails are forwarded\n",
+		"\nОтфильтрованные письма: в топик <code>%d</code>\n":                                  "\nFiltered emails: to topic <code>%d</code>\n",
+		"\nОтфильтрованные письма: пропускаются\n":                                             "\nFiltered emails: dropped\n",
+		"Укажите адрес отправителя, например <code>spam@example.com</code>":                    "Specify the sender address, e.g. <code>spam@example.com</code>",
+		"Некорректное регулярное выражение: <code>%s</code>":                                   "Invalid regular expression: <code>%s</code>",
+		"Поле фильтра: <code>from</code>, <code>domain</code> или <code>subject</code>":        "Filter field: <code>from</code>, <code>domain</code> or <code>subject</code>",
+		"Ошибка сохранения фильтра":                                                            "Failed to save the filter",
+		"Правило <code>%d</code> добавлено: %s %s <code>%s</code>":                             "Rule <code>%d</code> added: %s %s <code>%s</code>",
+		"Использование: <code>/filter del номер_правила</code>":                                "Usage: <code>/filter del rule_number</code>",
+		"Некорректный номер правила":                                                           "Invalid rule number",
+		"Правило не найдено":                                                                   "Rule not found",
+		"Ошибка удаления фильтра":                                                              "Failed to delete the filter",
+		"Правило <code>%d</code> удалено":                                                      "Rule <code>%d</code> deleted",
+		"Использование: <code>/filter spam ID_топика</code> или <code>/filter spam off</code>": "Usage: <code>/filter spam topic_ID</code> or <code>/filter spam off</code>",
+		"Некорректный ID топика (его можно узнать в /status)":                                  "Invalid topic ID (you can find it in /status)",
+		"Топик для спама должен отличаться от топика почты":                                    "The spam topic must differ from the mailbox topic",
+		"Отфильтрованные письма будут пропускаться":                                            "Filtered emails will be dropped",
+		"Отфильтрованные письма будут приходить без звука в топик <code>%d</code>":             "Filtered emails will arrive silently in topic <code>%d</code>",
+
+		// forget_handler
+		"Ошибка проверки прав":                          "Failed to check permissions",
+		"Удалить данные чата может только его владелец": "Only the chat owner can delete the chat's data",
+		"⚠️ <b>Удаление всех данных чата</b>\n\nБудут отключены все почтовые аккаунты этого чата и удалены сохранённые письма, коды, вложения, заказы и настройки. Сообщения, уже отправленные в чат, останутся.\n\nДействие необратимо. Подтвердить может только владелец чата в течение 5 минут.": "⚠️ <b>Deleting all chat data</b>\n\nAll email accounts of this chat will be disconnected, and saved emails, codes, attachments, orders and settings will be deleted. Messages already sent to the chat will stay.\n\nThis cannot be undone. Only the chat owner can confirm, within 5 minutes.",
+		"🗑 Удалить всё":        "🗑 Delete everything",
+		"Сообщение недоступно": "Message unavailable",
+		"Подтвердить удаление может только владелец чата": "Only the chat owner can confirm the deletion",
+		"Удаление данных отменено":                        "Data deletion cancelled",
+		"Отменено": "Cancelled",
+		"Подтверждение устарело, отправьте /forgetme ещё раз": "The confirmation has expired, send /forgetme again",
+		"Подтверждение устарело":                              "The confirmation has expired",
+		"Ошибка удаления данных":                              "Failed to delete the data",
+		"🗑 <b>Данные чата удалены</b>\n\n":                    "🗑 <b>Chat data deleted</b>\n\n",
+		"Отключено аккаунтов: %d\n":                           "Accounts disconnected: %d\n",
+		"Писем: %d\n":                           "Emails: %d\n",
+		"Кодов: %d\n":                           "Codes: %d\n",
+		"Вложений: %d\n":                        "Attachments: %d\n",
+		"Заказов: %d\n":                         "Orders: %d\n",
+		"Исходных писем в архиве: %d\n":         "Raw emails in the archive: %d\n",
+		"Настройки чата: удалены\n":             "Chat settings: deleted\n",
+		"Настройки чата: не были сохранены\n":   "Chat settings: none were saved\n",
+		"\n⚠️ Не удалось удалить из архива: %d": "\n⚠️ Failed to delete from the archive: %d",
+		"Данные удалены":                        "Data deleted",
+
+		// fulltext_handler
+		"У письма нет текста": "The email has no text",
+		"Аккаунт не найден":   "Account not found",
+		"Полный текст письма": "Full email text",
+
+		// grant_handler
+		"Использование: ответьте командой <code>/grant</code> на сообщение пользователя или укажите его ID: <code>/grant 123456789</code>. <code>/revoke</code> — забрать права.": "Usage: reply to the user's message with <code>/grant</code> or give their ID: <code>/grant 123456789</code>. <code>/revoke</code> — take the rights away.",
+		"Права можно выдать только пользователю, не боту": "Rights can be granted to users only, not bots",
+		"Ошибка сохранения прав":                          "Failed to save the rights",
+		"✅ <b>%s</b> может управлять ботом в этом чате, как администратор. Забрать права: <code>/revoke %d</code>": "✅ <b>%s</b> can manage the bot in this chat like an admin. To take the rights away: <code>/revoke %d</code>",
+		"Этому пользователю права не выдавались":                                                                   "This user was not granted any rights",
+		"Права на управление ботом отозваны у <b>%s</b>":                                                           "Bot management rights taken away from <b>%s</b>",
+		"\nПользователь остаётся администратором группы и по-прежнему может управлять ботом.":                      "\nThe user is still a group admin and can still manage the bot.",
+		"Выдавать и забирать права на управление ботом может только владелец чата":                                 "Only the chat owner can grant and take away bot management rights",
+		"Пользователь <code>%d</code> не найден в этом чате":                                                       "User <code>%d</code> is not in this chat",
+		"Права на управление ботом никому не выданы — ботом управляют администраторы группы.\n":                    "Bot management is not granted to anyone — the bot is managed by the group admins.\n",
+		"<b>Управляют ботом, не будучи администраторами:</b>\n":                                                    "<b>Managing the bot without being admins:</b>\n",
+		"Эта команда работает только в группах":                                                                    "This command works in groups only",
+		"Ошибка получения списка":                                                                                  "Failed to get the list",
+
+		// handlers
+		"Не удалось создать топик для почты: %v": "Failed to create a topic for the mailbox: %v",
+		"Использование: <code>/connect email@example.com password</code>\nИли: <code>/connect email@example.com password imap.server.com:993 [smtp.server.com:465]</code>\n\nБез TLS на порту 993 укажите шифрование после адреса: <code>imap.server.com:143/starttls</code>, <code>127.0.0.1:1143/starttls/insecure</code> (ProtonMail Bridge, сертификат не проверяется) или <code>imap.local:143/plain</code> (без шифрования)": "Usage: <code>/connect email@example.com password</code>\nOr: <code>/connect email@example.com password imap.server.com:993 [smtp.server.com:465]</code>\n\nWithout TLS on port 993, add the security after the address: <code>imap.server.com:143/starttls</code>, <code>127.0.0.1:1143/starttls/insecure</code> (ProtonMail Bridge, the certificate is not verified) or <code>imap.local:143/plain</code> (no encryption)",
+		"Неверный адрес IMAP сервера: %s": "Invalid IMAP server address: %s",
+		"Определяю IMAP сервер...":        "Detecting the IMAP server...",
+		"Не удалось определить IMAP сервер для %s\nПопробуйте указать вручную: <code>/connect email password imap.server.com:993</code>": "Failed to detect the IMAP server for %s\nTry specifying it manually: <code>/connect email password imap.server.com:993</code>",
+		"Ошибка проверки существующего подключения":                                                                                      "Failed to check the existing connection",
+		"В этом топике уже подключена почта: %s\nИспользуйте /disconnect для отключения":                                                 "A mailbox is already connected in this topic: %s\nUse /disconnect to disconnect it",
+		"Проверяю подключение к %s...":                                                                                                   "Checking the connection to %s...",
+		"Ошибка шифрования пароля":                                                                                                       "Failed to encrypt the password",
+		"Ошибка сохранения аккаунта в базу данных":                                                                                       "Failed to save the account to the database",
+		"Ошибка запуска подключения: %v":                                                                                                 "Failed to start the connection: %v",
+		"Почта <b>%s</b> успешно подключена к этому топику!\nСервер: %s\nSMTP для ответов: %s\n\nНовые письма будут автоматически пересылаться сюда, а ответ на письмо администратором будет отправлен отправителю.": "Mailbox <b>%s</b> has been connected to this topic!\nServer: %s\nSMTP for replies: %s\n\nNew emails will be forwarded here automatically, and an administrator's reply to an email will be sent to its sender.",
+		"Использование: <code>/create username</code>\nИли: <code>/create username password</code>\nИли: <code>/create username password Имя</code>\n\nБудет создан ящик: username@%s":                               "Usage: <code>/create username</code>\nOr: <code>/create username password</code>\nOr: <code>/create username password Name</code>\n\nThe mailbox will be created: username@%s",
+		"Создаю почтовый ящик...":             "Creating the mailbox...",
+		"Ошибка создания почтового ящика: %v": "Failed to create the mailbox: %v",
+		"Почтовый ящик успешно создан!\n\n<b>Email:</b> <code>%s</code>\n<b>Пароль:</b> <code>%s</code>\n<b>IMAP:</b> %s\n<b>SMTP:</b> %s\n\nНовые письма будут автоматически пересылаться в этот топик.": "The mailbox has been created!\n\n<b>Email:</b> <code>%s</code>\n<b>Password:</b> <code>%s</code>\n<b>IMAP:</b> %s\n<b>SMTP:</b> %s\n\nNew emails will be forwarded to this topic automatically.",
+		"В этом топике нет подключенной почты":                        "No mailbox is connected in this topic",
+		"Ошибка получения информации об аккаунте":                     "Failed to get account information",
+		"Ошибка удаления аккаунта":                                    "Failed to delete the account",
+		"Почта <b>%s</b> отключена от этого топика":                   "Mailbox <b>%s</b> has been disconnected from this topic",
+		"Ошибка получения списка аккаунтов":                           "Failed to get the list of accounts",
+		"В этой группе нет подключенных почтовых аккаунтов":           "There are no connected email accounts in this group",
+		"<b>Подключенные почтовые аккаунты:</b>":                      "<b>Connected email accounts:</b>",
+		" (%d из %d, стр. %d/%d)":                                     " (%d of %d, page %d/%d)",
+		"   Топик ID: %d\n":                                           "   Topic ID: %d\n",
+		"   Статус: %s\n":                                             "   Status: %s\n",
+		"   Неудачных попыток: %d, следующая через %s\n":              "   Failed attempts: %d, next in %s\n",
+		"   ⏸ Переподключения замедлены из-за повторяющихся ошибок\n": "   ⏸ Reconnects slowed down after repeated errors\n",
+		"Ошибка": "Error",
+		"Это действие доступно только администраторам группы и операторам бота": "This action is only available to group administrators and bot operators",
+		"Неизвестное действие":                                  "Unknown action",
+		"Письмо перенесено в архив и больше не хранится в боте": "The email has been archived and is no longer stored by the bot",
+		"Сообщение не найдено":                                  "Message not found",
+		"Ошибка: ":                                              "Error: ",
+		"Помечено как прочитанное":                              "Marked as read",
+		"Письмо удалено":                                        "Email deleted",
+		"Код не найден":                                         "Code not found",
+		"Код: %s":                                               "Code: %s",
+		"Вложение не найдено":                                   "Attachment not found",
+		"Вложение слишком большое для Telegram (%s)":            "The attachment is too large for Telegram (%s)",
+		"Загружаю вложение...":                                  "Downloading the attachment...",
+		"Не удалось загрузить вложение %s: %v":                  "Failed to download attachment %s: %v",
+		"У письма нет HTML-версии":                              "The email has no HTML version",
+		"Не удалось подготовить оригинал письма":                "Failed to prepare the original email",
+		"Письмо слишком большое для Telegram (%s)":              "The email is too large for Telegram (%s)",
+		"Оригинал письма. Скрипты, формы и опасные ссылки удалены": "Original email. Scripts, forms and dangerous links removed",
+		"В этом чате нет подключенной почты":                       "No mailbox is connected in this chat",
+		"Почта <b>%s</b> успешно подключена к этому чату!\nСервер: %s\nSMTP для ответов: %s\n\nНовые письма будут автоматически пересылаться сюда, а ответ на письмо администратором будет отправлен отправителю.": "Mailbox <b>%s</b> has been connected to this chat!\nServer: %s\nSMTP for replies: %s\n\nNew emails will be forwarded here automatically, and an administrator's reply to an email will be sent to its sender.",
+		"В этом чате уже подключена почта: %s\nИспользуйте /disconnect для отключения": "A mailbox is already connected in this chat: %s\nUse /disconnect to disconnect it",
+		"Загружаю письмо...":                        "Downloading the email...",
+		"Не удалось загрузить письмо с сервера: %v": "Could not download the email from the server: %v",
+		"Почта %s уже подключена в этом чате":       "%s is already connected in this chat",
+		"Здесь уже подключена почта %s другого бота этого чата, отключите её через него": "%s of another bot of this chat is already connected here, disconnect it through that bot",
+
+		// history_handler
+		"Ошибка получения писем":       "Failed to get emails",
+		"Для %s нет сохранённых писем": "There are no saved emails for %s",
+		"<b>Письма %s:</b>":            "<b>Emails of %s:</b>",
+		" (%d, стр. %d/%d)":            " (%d, page %d/%d)",
+		"\n🔵 — не прочитано. Нажмите номер, чтобы открыть письмо целиком.": "\n🔵 — unread. Press a number to open the whole email.",
+		"Ошибка поиска":                      "Search error",
+		"Письма не найдены":                  "No emails found",
+		"<b>Письма по запросу «%s»:</b>\n\n": "<b>Emails matching «%s»:</b>\n\n",
+		"\nПоказаны последние %d совпадений, уточните запрос": "\nShowing the latest %d matches, refine the query",
+		"(без темы)": "(no subject)",
+		"Текст письма удалён из-за лимита хранилища чата": "The email text was deleted because of the chat storage limit",
+
+		// images
+		"Изображения из письма": "Images from the email",
+
+		// import_handler
+		"Ошибка разбора файла: %s": "Failed to parse the file: %s",
+		"Файл не содержит аккаунтов\n\nФормат CSV: <code>email,password,topic_id,imap_server</code>\nФормат JSON: <code>[{\"email\": \"...\", \"password\": \"...\", \"topic_id\": 2}]</code>": "The file contains no accounts\n\nCSV format: <code>email,password,topic_id,imap_server</code>\nJSON format: <code>[{\"email\": \"...\", \"password\": \"...\", \"topic_id\": 2}]</code>",
+		"Импорт: 0/%d...":  "Import: 0/%d...",
+		"Импорт: %d/%d...": "Import: %d/%d...",
+		"🟢 %s → топик %d":  "🟢 %s → topic %d",
+		"строка %d":        "line %d",
+		"<b>Импорт завершён:</b> %d успешно, %d с ошибками\n": "<b>Import finished:</b> %d succeeded, %d failed\n",
+		"…и ещё %d с ошибками\n":                              "…and %d more failed\n",
+		"\nПолный отчёт — в файле ниже":                       "\nThe full report is in the file below",
+		"Полный отчёт":                                        "Full report",
+
+		// mailexport_handler
+		"Только администраторы могут выгружать письма": "Only administrators can export emails",
+		"Использование: <code>/export [csv|json|mbox] [с ГГГГ-ММ-ДД] [по ГГГГ-ММ-ДД]</code>\n\nНапример: <code>/export mbox 2026-01-01 2026-03-31</code>. По умолчанию — CSV со всеми сохранёнными письмами.": "Usage: <code>/export [csv|json|mbox] [from YYYY-MM-DD] [to YYYY-MM-DD]</code>\n\nFor example: <code>/export mbox 2026-01-01 2026-03-31</code>. By default — CSV with all stored emails.",
+		"⏳ Готовлю выгрузку писем...":          "⏳ Preparing the email export...",
+		"Ошибка записи выгрузки":               "Failed to write the export",
+		"Нет сохранённых писем за этот период": "No stored emails for this period",
+		"📦 Выгрузка <b>%s</b>: %d писем":       "📦 Export of <b>%s</b>: %d emails",
+		"⚠️ Файл ограничен %d МБ, выгружены не все письма — укажите более узкий период": "⚠️ The file is limited to %d MB, not all emails were exported — specify a narrower period",
+		"Не удалось отправить файл выгрузки":                                            "Failed to send the export file",
+		"с %s":  "from %s",
+		"по %s": "to %s",
+
+		// middleware
+		"Внутренняя ошибка, попробуйте позже":                   "Internal error, try again later",
+		"Внутренняя ошибка":                                     "Internal error",
+		"Эта команда работает только в супергруппах с топиками": "This command only works in supergroups with topics",
+		"Слишком часто. Повторите через %s":                     "Too often. Try again in %s",
+
+		// mirror_handler
+		"Чтобы получать в этом топике копии писем почты из другой группы, выполните там в топике почты <code>/mirror invite</code> и отправьте сюда полученную команду": "To receive copies of another group's emails in this topic, run <code>/mirror invite</code> in that group's mail topic and send the resulting command here",
+		"Ошибка создания кода": "Failed to create a code",
+		"Чтобы транслировать письма <b>%s</b> в другую группу, отправьте в её топике в течение часа:\n\n<code>/mirror %s</code>\n\nБот должен быть участником группы. Код одноразовый; пароль и настройки почты остаются в этом чате.": "To mirror emails of <b>%s</b> to another group, send this in its topic within an hour:\n\n<code>/mirror %s</code>\n\nThe bot must be a member of the group. The code is single-use; the password and mailbox settings stay in this chat.",
+		"Этот топик уже получает письма другой почты, сначала выполните /unmirror": "This topic already receives emails of another mailbox, run /unmirror first",
+		"Код не найден или истёк": "The code was not found or has expired",
+		"Ошибка проверки кода":    "Failed to check the code",
+		"Код выдан другим ботом: добавьте в группу его и отправьте команду ему": "The code was issued by another bot: add it to the group and send the command to it",
+		"Эта почта уже подключена к этой группе":                                "This mailbox is already connected to this group",
+		"Письма этой почты уже транслируются в эту группу":                      "Emails of this mailbox are already mirrored to this group",
+		"Ошибка сохранения": "Failed to save",
+		"Сюда будут приходить копии писем <b>%s</b>. Управление почтой остаётся у группы-владельца, отключить трансляцию: /unmirror": "Copies of <b>%s</b> emails will arrive here. The mailbox is managed by the owner group, to stop mirroring: /unmirror",
+		"Письма транслируются в группу «%s»": "Emails are mirrored to group «%s»",
+		"Ошибка получения трансляций":        "Failed to get mirrors",
+		"<b>Трансляции писем %s:</b>\n\n":    "<b>Mirrors of %s emails:</b>\n\n",
+		"нет\n":          "none\n",
+		"группа %d":      "group %d",
+		"%d. %s, с %s\n": "%d. %s, since %s\n",
+		"\n<code>/mirror invite</code> — код для трансляции в другую группу\n<code>/unmirror N</code> — отключить трансляцию": "\n<code>/mirror invite</code> — code for mirroring to another group\n<code>/unmirror N</code> — stop mirroring",
+		"Ошибка отключения трансляции": "Failed to stop mirroring",
+		"Трансляция писем отключена":   "Email mirroring stopped",
+
+		// move_account_handler
+		"Использование:\n<code>/move_account 123</code> — перенести почту этого топика в топик 123 этой группы\n<code>/move_account https://t.me/c/1234567890/123</code> — в топик по ссылке, в том числе другой группы\n<code>/move_account -1001234567890 [123]</code> — в другую группу (и её топик)\n\nПисьма, настройки и фильтры почты сохраняются. Бот должен быть участником группы, а вы — её администратором.": "Usage:\n<code>/move_account 123</code> — move the email of this topic to topic 123 of this group\n<code>/move_account https://t.me/c/1234567890/123</code> — to the topic of a link, also in another group\n<code>/move_account -1001234567890 [123]</code> — to another group (and its topic)\n\nEmails, settings and filters of the mailbox are kept. The bot must be a member of the group, and you its administrator.",
+		"Почта уже подключена к этому топику":                                          "The email is already connected to this topic",
+		"Этот топик получает трансляцию другой почты, сначала выполните там /unmirror": "This topic mirrors another mailbox, run /unmirror there first",
+		"📬 Сюда перенесена почта <b>%s</b>":                                            "📬 The email <b>%s</b> was moved here",
+		"Бот не может писать в этот топик: %v\n\nПроверьте, что топик существует, а бот состоит в группе и может отправлять сообщения.": "The bot cannot post to this topic: %v\n\nCheck that the topic exists and the bot is a member of the group allowed to send messages.",
+		"В этом топике уже есть почта, или эта почта уже подключена к той группе":                                                       "This topic already has an email, or this email is already connected to that group",
+		"Ошибка переноса почты": "Failed to move the email",
+		"другую группу":         "another group",
+		"другой топик":          "another topic",
+		"Почта <b>%s</b> перенесена в %s. Письма и настройки сохранены.": "The email <b>%s</b> was moved to %s. Emails and settings are kept.",
+		"Бот не состоит в этой группе: добавьте его и повторите команду": "The bot is not a member of this group: add it and repeat the command",
+		"Боту запрещено писать в этой группе":                            "The bot is not allowed to post in this group",
+		"Переносить почту можно только в группы, где вы администратор":   "You can only move an email to groups you are an administrator of",
+
+		// move_handler
+		"Ошибка: %v": "Error: %v",
+		"Использование (ответом на письмо):\n<code>/move</code> — выбрать папку кнопками\n<code>/move archive</code> — перенести в архив\n<code>/move Папка</code> — перенести в папку\n\nБез ответа на письмо <code>/move</code> показывает папки ящика этого топика.": "Usage (in reply to an email):\n<code>/move</code> — pick a folder with buttons\n<code>/move archive</code> — move to the archive\n<code>/move Folder</code> — move to a folder\n\nWithout a reply <code>/move</code> shows the folders of this topic's mailbox.",
+		"Не удалось получить список папок: %v":                                  "Failed to get the folder list: %v",
+		"В ящике нет других папок":                                              "The mailbox has no other folders",
+		"Выберите папку":                                                        "Choose a folder",
+		"В ящике нет папки архива, выберите папку кнопкой «В папку»":            "The mailbox has no archive folder, choose one with the \"Move to…\" button",
+		"Список папок изменился, откройте его заново":                           "The folder list has changed, open it again",
+		"Перемещено в %s":                                                       "Moved to %s",
+		"Перемещать письма могут только администраторы группы и операторы бота": "Only group admins and bot operators can move emails",
+		"Письмо уже перемещено в папку %s":                                      "The email has already been moved to %s",
+		"Выберите папку кнопкой под письмом":                                    "Choose a folder with the buttons under the email",
+		"В ящике нет папки архива, укажите папку: <code>/move Папка</code>":     "The mailbox has no archive folder, specify one: <code>/move Folder</code>",
+		"Папка <b>%s</b> не найдена. Папки ящика:\n%s":                          "Folder <b>%s</b> not found. Folders of the mailbox:\n%s",
+		"Письмо перемещено в папку %s":                                          "The email has been moved to %s",
+		"📁 <b>Папки %s</b>\n%s\n\n":                                             "📁 <b>Folders of %s</b>\n%s\n\n",
+		"Письмо удалено из почтового ящика":                                     "The email was deleted from the mailbox",
+
+		// oauth
+		"⚠️ Доступ к <b>%s</b> отозван или истёк. Пересылка приостановлена.\n\nАдминистратор может восстановить доступ, отправив боту новый refresh token.": "⚠️ Access to <b>%s</b> has been revoked or has expired. Forwarding is paused.\n\nAn administrator can restore access by sending the bot a new refresh token.",
+		"Авторизовать заново": "Authorize again",
+		"Авторизовать аккаунт может только администратор его чата":                           "Only an administrator of the account's chat can authorize it",
+		"Отправьте новый refresh token для <b>%s</b> следующим сообщением.\nОтмена: /cancel": "Send the new refresh token for <b>%s</b> in the next message.\nCancel: /cancel",
+		"Токен аккаунта не найден, авторизация отменена":                                     "The account's token was not found, authorization cancelled",
+		"OAuth-провайдер %s не настроен":                                                     "OAuth provider %s is not configured",
+		"Токен не принят провайдером. Отправьте его ещё раз или /cancel":                     "The provider did not accept the token. Send it again or /cancel",
+		"\n\nОтправьте токен ещё раз или /cancel":                                            "\n\nSend the token again or /cancel",
+		"Ошибка сохранения токена":                                                           "Failed to save the token",
+		"Токен сохранён, но не удалось возобновить пересылку":                                "The token is saved, but forwarding could not be resumed",
+		"Токен сохранён, но не удалось перезапустить подключение":                            "The token is saved, but the connection could not be restarted",
+		"Токен сохранён, но подключение не запущено: %v":                                     "The token is saved, but the connection was not started: %v",
+		"Доступ к <b>%s</b> восстановлен, пересылка возобновлена":                            "Access to <b>%s</b> is restored, forwarding resumed",
+
+		// order_handlers
+		"Использование: <code>/search номер_заказа</code>":                     "Usage: <code>/search order_number</code>",
+		"Использование: <code>/search слова из письма</code>":                  "Usage: <code>/search words from the email</code>",
+		"Заказы не найдены":                                                    "No orders found",
+		"<b>Заказы по запросу «%s»:</b>\n\n":                                   "<b>Orders matching «%s»:</b>\n\n",
+		" — <a href=\"%s\">письмо</a>":                                         " — <a href=\"%s\">email</a>",
+		"Использование: <code>/report</code> или <code>/report 2024-05</code>": "Usage: <code>/report</code> or <code>/report 2024-05</code>",
+		"Ошибка построения отчёта":                                             "Failed to build the report",
+		"<b>Отчёт за %s</b>\n\n":                                               "<b>Report for %s</b>\n\n",
+		"Покупок с известной суммой не найдено":                                "No purchases with a known amount found",
+		"<b>Расходы по магазинам:</b>\n":                                       "<b>Spending by shop:</b>\n",
+		"\n<b>Итого:</b> ":                                                     "\n<b>Total:</b> ",
+
+		// password_handler
+		"Используйте /setpassword в топике, к которому подключена почта": "Use /setpassword in the topic the mailbox is connected to",
+		"Только администраторы могут менять пароль":                      "Only administrators can change the password",
+		"Ввести новый пароль":                                            "Enter a new password",
+		"Смена пароля для <b>%s</b>\n\nОтправьте новый пароль боту в личные сообщения в течение 10 минут.": "Changing the password of <b>%s</b>\n\nSend the new password to the bot in private messages within 10 minutes.",
+		"Аккаунт не найден, смена пароля отменена":                                                         "Account not found, password change cancelled",
+		"Отправьте новый пароль для <b>%s</b> следующим сообщением.\nОтмена: /cancel":                      "Send the new password for <b>%s</b> in the next message.\nCancel: /cancel",
+		"Авторизация отменена":                                     "Authorization cancelled",
+		"Смена пароля отменена":                                    "Password change cancelled",
+		"\n\nОтправьте пароль ещё раз или /cancel":                 "\n\nSend the password again or /cancel",
+		"Ошибка сохранения пароля":                                 "Failed to save the password",
+		"Пароль сохранён, но не удалось перезапустить подключение": "The password is saved, but the connection could not be restarted",
+		"Пароль сохранён, но подключение не запущено: %v":          "The password is saved, but the connection was not started: %v",
+		"Пароль для <b>%s</b> обновлён, подключение перезапущено":  "The password of <b>%s</b> is updated, the connection restarted",
+		"Пароль для <b>%s</b> обновлён":                            "The password of <b>%s</b> is updated",
+		"Пароль сохранён, но не удалось возобновить пересылку":     "The password was saved, but forwarding could not be resumed",
+		"⚠️ Сервер несколько раз подряд не принял пароль почты <b>%s</b>. Пересылка приостановлена, чтобы ящик не заблокировали за попытки входа.\n\nАдминистратор может переподключить почту с новым паролем кнопкой ниже. %s": "⚠️ The server rejected the password of <b>%s</b> several times in a row. Forwarding is paused so the mailbox does not get locked for failed logins.\n\nAn admin can reconnect the mailbox with a new password using the button below. %s",
+		"Переподключить с новым паролем": "Reconnect with new password",
+		"Смена пароля для <b>%s</b>\n\nОтправьте новый пароль следующим сообщением в течение 10 минут.": "Password change for <b>%s</b>\n\nSend the new password as your next message within 10 minutes.",
+
+		// permissions
+		"В этом чате команда доступна только: %s": "In this chat the command is only available to: %s",
+		"владелец чата":                                  "the chat owner",
+		"администраторы":                                 "administrators",
+		"операторы бота":                                 "bot operators",
+		"все участники":                                  "all members",
+		"Неизвестная команда <code>%s</code>":            "Unknown command <code>%s</code>",
+		"Права на %s изменить нельзя":                    "Permissions of %s cannot be changed",
+		"Неизвестная роль <code>%s</code>\n\n%s":         "Unknown role <code>%s</code>\n\n%s",
+		"Для %s восстановлены права по умолчанию":        "Default permissions restored for %s",
+		"%s теперь доступна: %s":                         "%s is now available to: %s",
+		permissionsUsage:                                 "Usage:\n<code>/permissions status member</code> — open the command to all members\n<code>/permissions disconnect owner,operator</code> — only the chat owner and bot operators\n<code>/permissions status reset</code> — default permissions\n\nRoles: <code>owner</code>, <code>admin</code>, <code>operator</code>, <code>member</code>",
+		"<b>Права на команды в этом чате:</b>\n\n":       "<b>Command permissions in this chat:</b>\n\n",
+		"Для всех команд действуют права по умолчанию\n": "All commands use the default permissions\n",
+
+		// purge_handler
+		"Этот ящик не создавался ботом, удалить его с сервера нельзя. Чтобы только отключить почту, используйте /disconnect": "This mailbox was not created by the bot and cannot be deleted from the server. To only disconnect it, use /disconnect",
+		"Mailcow не настроен, удалить ящик с сервера нельзя":                                                                 "Mailcow is not configured, the mailbox cannot be deleted from the server",
+		"⚠️ <b>Удаление ящика %s</b>\n\nПочта будет отключена от топика, а ящик удалён с сервера Mailcow вместе со всеми письмами. Сообщения, уже отправленные в чат, останутся.\n\nДействие необратимо. Подтвердить может администратор в течение 5 минут.": "⚠️ <b>Deleting mailbox %s</b>\n\nThe mailbox will be disconnected from the topic and deleted from the Mailcow server with all its emails. Messages already sent to the chat will stay.\n\nThis cannot be undone. An administrator can confirm within 5 minutes.",
+		"🗑 Удалить ящик": "🗑 Delete mailbox",
+		"Подтвердить удаление может только администратор":                                     "Only an administrator can confirm the deletion",
+		"Удаление ящика отменено":                                                             "Mailbox deletion cancelled",
+		"Подтверждение устарело, отправьте /disconnect --purge ещё раз":                       "The confirmation has expired, send /disconnect --purge again",
+		"Почта уже отключена":                                                                 "The mailbox is already disconnected",
+		"Ошибка удаления ящика на сервере":                                                    "Failed to delete the mailbox on the server",
+		"Ящик <b>%s</b> удалён с сервера, но отключить его не удалось, выполните /disconnect": "Mailbox <b>%s</b> is deleted from the server, but could not be disconnected, run /disconnect",
+		"Ящик <b>%s</b> удалён с сервера и отключён от этого топика":                          "Mailbox <b>%s</b> is deleted from the server and disconnected from this topic",
+		"Ящик удалён": "Mailbox deleted",
+
+		// redeliver_handler
+		"Только администраторы могут повторять доставку писем":   "Only administrators can redeliver emails",
+		"Использование: <code>/redeliver [all|ID письма]</code>": "Usage: <code>/redeliver [all|email ID]</code>",
+		"✅ Все письма доставлены в Telegram":                     "✅ All emails have been delivered to Telegram",
+		"⚠️ <b>Не доставлено в Telegram: %d</b>\n\n":             "⚠️ <b>Not delivered to Telegram: %d</b>\n\n",
+		"...и ещё %d\n": "...and %d more\n",
+		"\n<code>/redeliver ID</code> — отправить письмо снова, <code>/redeliver all</code> — отправить все": "\n<code>/redeliver ID</code> — send an email again, <code>/redeliver all</code> — send all of them",
+		"Ошибка постановки писем в очередь":                                                                  "Failed to queue the emails",
+		"🔁 Писем поставлено в очередь: %d":                                                                   "🔁 Emails queued: %d",
+		"🔁 Письмо поставлено в очередь":                                                                      "🔁 The email has been queued",
+		"   ⚠️ Не доставлено в Telegram: %d (/redeliver)\n":                                                  "   ⚠️ Not delivered to Telegram: %d (/redeliver)\n",
+
+		// refetch_handler
+		"Использование:\n<code>/refetch 20</code> — последние 20 писем ящика\n<code>/refetch 2024-05-01</code> — письма начиная с этой даты (не больше 100)\n\nПисьма, которые уже есть в боте, повторно не публикуются.": "Usage:\n<code>/refetch 20</code> — the latest 20 emails of the mailbox\n<code>/refetch 2024-05-01</code> — emails since this date (at most 100)\n\nEmails the bot already has are not posted again.",
+		"Только администраторы могут загружать письма заново":                       "Only administrators can load emails again",
+		"Укажите от 1 до %d писем":                                                  "Specify from 1 to %d emails",
+		"Дата не может быть в будущем":                                              "The date cannot be in the future",
+		"Пересылка почты приостановлена, сначала выполните /resume":                 "Email forwarding is paused, run /resume first",
+		"Загружаю письма с сервера...":                                              "Loading emails from the server...",
+		"Не удалось загрузить письма: %v":                                           "Failed to load emails: %v",
+		"Загружено писем с сервера: %d, новых: %d. Новые письма появятся в топике.": "Emails loaded from the server: %d, new: %d. New emails will appear in the topic.",
+
+		// reparse_handler
+		"Ошибка повторного разбора письма":             "Failed to parse the email again",
+		"Письмо разобрано заново, сообщение обновлено": "The email has been parsed again, the message is updated",
+		"Письмо разобрано заново, изменений нет":       "The email has been parsed again, nothing changed",
+		"Использование: <code>/reparse ссылка_на_сообщение</code>, <code>/reparse id</code>, ответ на письмо командой <code>/reparse</code> или <code>/reparse all</code>": "Usage: <code>/reparse message_link</code>, <code>/reparse id</code>, a reply to an email with <code>/reparse</code> or <code>/reparse all</code>",
+		"Ссылка не ведёт на сообщение этого чата":                            "The link does not point to a message of this chat",
+		"Письмо не найдено":                                                  "Email not found",
+		"Ошибка получения письма":                                            "Failed to get the email",
+		"Только администраторы могут запускать массовый разбор":              "Only administrators can start bulk parsing",
+		"Разбор писем этого аккаунта уже идёт":                               "This account's emails are already being parsed",
+		"Запущен повторный разбор писем, разобранных старой версией парсера": "Parsing again the emails parsed by an old parser version",
+		"Разбор прерван из-за ошибки. Обработано писем: %d, обновлено: %d":   "Parsing stopped because of an error. Emails processed: %d, updated: %d",
+		"Разбор завершён. Обработано писем: %d, обновлено: %d":               "Parsing finished. Emails processed: %d, updated: %d",
+
+		// reply_handler
+		"Почта, на которую пришло это письмо, больше не подключена":   "The mailbox this email arrived to is no longer connected",
+		"У письма нет адреса отправителя, ответить нельзя":            "The email has no sender address, it cannot be replied to",
+		"Не удалось определить SMTP сервер":                           "Failed to detect the SMTP server",
+		"Ошибка расшифровки пароля":                                   "Failed to decrypt the password",
+		"Не удалось отправить ответ через %s:\n<code>%s</code>\n\n%s": "Failed to send the reply via %s:\n<code>%s</code>\n\n%s",
+		"✉️ Ответ отправлен: %s":                                      "✉️ Reply sent: %s",
+		"\n⚠️ Не удалось сохранить копию в папку «Отправленные»":      "\n⚠️ Failed to save a copy to the «Sent» folder",
+
+		// rotate_handler
+		"Ключ шифрования хранится в Vault или AWS KMS: ротируйте его там, перешифровывать пароли не нужно":                                                                                          "The encryption key is kept in Vault or AWS KMS: rotate it there, passwords need no re-encryption",
+		"Смена ключа доступна только в личном чате с ботом":                                                                                                                                         "The key can only be changed in a private chat with the bot",
+		"Использование: <code>/rotate_key новый_ключ</code>\n\nКлюч — ровно 32 символа, например из <code>openssl rand -hex 16</code>. Все пароли и OAuth-токены будут перешифрованы новым ключом.": "Usage: <code>/rotate_key new_key</code>\n\nThe key is exactly 32 characters, e.g. from <code>openssl rand -hex 16</code>. All passwords and OAuth tokens will be re-encrypted with the new key.",
+		"Ключ должен быть ровно 32 байта, получено %d":                                                                                                                                              "The key must be exactly 32 bytes, got %d",
+		"Новый ключ совпадает с текущим":                                                                                                                                                            "The new key equals the current one",
+		"🔑 <b>Смена ключа шифрования</b>\n\nПароли всех почтовых аккаунтов и OAuth-токены будут перешифрованы новым ключом в одной транзакции, бот сразу начнёт использовать его.\n\nПосле этого обязательно замените <code>ENCRYPTION_KEY</code> в конфигурации на новый ключ: со старым после перезапуска бот не сможет расшифровать пароли.\n\nПодтвердите в течение 5 минут.": "🔑 <b>Changing the encryption key</b>\n\nThe passwords of all email accounts and OAuth tokens will be re-encrypted with the new key in one transaction, and the bot will start using it right away.\n\nAfter that, be sure to replace <code>ENCRYPTION_KEY</code> in the configuration with the new key: with the old one the bot will not be able to decrypt the passwords after a restart.\n\nConfirm within 5 minutes.",
+		"🔑 Сменить ключ":                                                "🔑 Change key",
+		"Подтвердить может только владелец бота":                        "Only the bot owner can confirm",
+		"Смена ключа отменена":                                          "Key change cancelled",
+		"Подтверждение устарело, отправьте /rotate_key ещё раз":         "The confirmation has expired, send /rotate_key again",
+		"Перешифровываю...":                                             "Re-encrypting...",
+		"Не удалось сменить ключ, данные не изменены:\n<code>%s</code>": "Failed to change the key, no data was changed:\n<code>%s</code>",
+		"✅ Ключ сменён: перешифровано паролей — %d, OAuth-токенов — %d, ключей S/MIME — %d.\n\n⚠️ Замените <code>ENCRYPTION_KEY</code> в конфигурации на новый ключ до следующего перезапуска бота.": "✅ The key has been changed: re-encrypted passwords — %d, OAuth tokens — %d, S/MIME keys — %d.\n\n⚠️ Replace <code>ENCRYPTION_KEY</code> in the configuration with the new key before the next bot restart.",
+
+		// router
+		"⚠️ <b>Сбой обработчика почты %s</b>\n\n<code>%s</code>\n\nСбой перехвачен: письмо пропущено или подключение будет перезапущено автоматически. Подробности в логах.":                                "⚠️ <b>Mail handler of %s failed</b>\n\n<code>%s</code>\n\nThe failure was caught: the email is skipped or the connection will be restarted automatically. See the logs for details.",
+		"⏳ <b>Почта %s не проверялась %s</b>\n\nПодключение есть, но цикл получения писем не завершился вдвое дольше ожидаемого — письма могут не приходить. Зависаний с запуска: %d. Подробности в логах.": "⏳ <b>Mailbox %s has not been checked for %s</b>\n\nThe connection is up, but the fetch loop has not finished for twice the expected time — emails may not arrive. Stalls since start: %d. See the logs for details.",
+		"✅ Получение почты %s возобновилось": "✅ Fetching mail of %s has resumed",
+
+		// send_handler
+		"Только администраторы могут отправлять письма":                                                                              "Only administrators can send emails",
+		"Новое письмо от <b>%s</b>\n\nКому отправить? Напишите адрес следующим сообщением.\nОтмена: /cancel":                         "New email from <b>%s</b>\n\nWho should receive it? Write the address in the next message.\nCancel: /cancel",
+		"Использование: <code>/send адрес Тема | текст письма</code>\nИли просто /send — бот спросит адрес, тему и текст по очереди": "Usage: <code>/send address Subject | email text</code>\nOr just /send — the bot will ask for the address, subject and text one by one",
+		"Отправка письма отменена":                                 "Sending the email cancelled",
+		"Некорректный адрес, попробуйте ещё раз.\nОтмена: /cancel": "Invalid address, try again.\nCancel: /cancel",
+		"Тема письма?\nОтмена: /cancel":                            "Email subject?\nCancel: /cancel",
+		"Текст письма?\nОтмена: /cancel":                           "Email text?\nCancel: /cancel",
+		"Почта этого топика больше не подключена":                  "The mailbox of this topic is no longer connected",
+		"Некорректный адрес: <code>%s</code>":                      "Invalid address: <code>%s</code>",
+		"Ошибка сохранения письма":                                 "Failed to save the email",
+		"✉️ <b>Отправить письмо?</b>\n\nОт: %s\nКому: %s\nТема: %s\n\n%s\n\n<i>Подтвердить может только автор в течение 10 минут</i>": "✉️ <b>Send the email?</b>\n\nFrom: %s\nTo: %s\nSubject: %s\n\n%s\n\n<i>Only the author can confirm, within 10 minutes</i>",
+		"✉️ Отправить": "✉️ Send",
+		"Подтвердить отправку может только автор письма":         "Only the author of the email can confirm sending it",
+		"Почта этого письма больше не подключена":                "The mailbox of this email is no longer connected",
+		"Подтверждение устарело, составьте письмо заново: /send": "The confirmation has expired, compose the email again: /send",
+		"Ошибка отправки":                    "Sending error",
+		"Письмо уже отправлено или отменено": "The email has already been sent or cancelled",
+		"Отправляю...":                       "Sending...",
+		"Не удалось отправить письмо для %s через %s:\n<code>%s</code>\n\n%s": "Failed to send the email to %s via %s:\n<code>%s</code>\n\n%s",
+		"✉️ Письмо отправлено: %s\nТема: %s":                                  "✉️ Email sent: %s\nSubject: %s",
+
+		// sender_digest
+		"Отправитель <b>%s</b> прислал больше %d писем за час. Остальные письма от него до %s придут одной сводкой.": "Sender <b>%s</b> has sent more than %d emails in an hour. Further emails from them until %s will arrive as one digest.",
+		"<b>Сводка: %s</b>\n":                     "<b>Digest: %s</b>\n",
+		"Не отправлено отдельно за %s–%s: %d\n\n": "Not sent separately during %s–%s: %d\n\n",
+		"… и ещё %d\n":                            "… and %d more\n",
+
+		// settings
+		"Укажите интервал опроса от %s до %s, например <code>/settings poll 30s</code>": "Specify a polling interval from %s to %s, e.g. <code>/settings poll 30s</code>",
+		"Укажите время IDLE от %s до %s, например <code>/settings idle 10m</code>":      "Specify an IDLE time from %s to %s, e.g. <code>/settings idle 10m</code>",
+		"Настройки сохранены, но подключение не перезапущено: %v":                       "Settings saved, but the connection was not restarted: %v",
+		"✅ Настройки сохранены\n\n":                                                     "✅ Settings saved\n\n",
+		"Использование:\n<code>/settings poll 30s</code> — как часто проверять почту, когда IDLE не используется (от 10s до 1h)\n<code>/settings idle 10m</code> — через сколько перезапускать IDLE (от 1m до 29m)\n<code>/settings poll default</code> — вернуть значение по умолчанию": "Usage:\n<code>/settings poll 30s</code> — how often to check mail when IDLE is not used (10s to 1h)\n<code>/settings idle 10m</code> — how long before IDLE is restarted (1m to 29m)\n<code>/settings poll default</code> — restore the default value",
+		" (по умолчанию)":                " (default)",
+		"<b>Получение почты %s</b>\n":    "<b>Mail checks of %s</b>\n",
+		"Опрос: раз в %s%s\n":            "Polling: every %s%s\n",
+		"IDLE: перезапуск каждые %s%s\n": "IDLE: restarted every %s%s\n",
+		"опрос":              "polling",
+		"Сейчас: %s (/idle)": "Now: %s (/idle)",
+
+		// settings_handlers
+		"Топики для новых ящиков: <b>%s</b>\n\nКогда включено, /connect и /create в General создают топик с именем ящика и подключают почту к нему.\n\nИспользование: <code>/autotopics on</code> или <code>/autotopics off</code>": "Topics for new mailboxes: <b>%s</b>\n\nWhen on, /connect and /create in General create a topic named after the mailbox and connect it there.\n\nUsage: <code>/autotopics on</code> or <code>/autotopics off</code>",
+		"Использование: <code>/autotopics on</code> или <code>/autotopics off</code>":                                                 "Usage: <code>/autotopics on</code> or <code>/autotopics off</code>",
+		"/connect и /create в General будут создавать топик для каждого ящика":                                                        "/connect and /create in General will create a topic for each mailbox",
+		"/connect и /create будут подключать почту к топику, где отправлена команда":                                                  "/connect and /create will connect the mailbox to the topic the command was sent in",
+		"Текущий режим форматирования: <b>%s</b>\n\nИспользование: <code>/parsemode html</code> или <code>/parsemode markdown</code>": "Current formatting mode: <b>%s</b>\n\nUsage: <code>/parsemode html</code> or <code>/parsemode markdown</code>",
+		"Неизвестный режим. Доступно: <code>html</code>, <code>markdown</code>":                                                       "Unknown mode. Available: <code>html</code>, <code>markdown</code>",
+		"Режим форматирования писем: <b>%s</b>":                                                                                       "Email formatting mode: <b>%s</b>",
+		"Язык бота: <b>%s</b>\n\nДоступно:\n%s\n\nИспользование: <code>/language en</code>":                                           "Bot language: <b>%s</b>\n\nAvailable:\n%s\n\nUsage: <code>/language ru</code>",
+		"Неизвестный язык. Доступно: <code>%s</code>":                                                                                 "Unknown language. Available: <code>%s</code>",
+		"Язык бота: <b>%s</b>": "Bot language: <b>%s</b>",
+		"<b>Иконки:</b>\n\n":   "<b>Icons:</b>\n\n",
+		"\nИспользование: <code>/emoji code 5368324170671202286</code>\nИли отправьте <code>/emoji code</code> вместе с премиум-эмодзи\nСброс: <code>/emoji code reset</code>": "\nUsage: <code>/emoji code 5368324170671202286</code>\nOr send <code>/emoji code</code> together with a premium emoji\nReset: <code>/emoji code reset</code>",
+		"Неизвестная иконка: <code>%s</code>":                   "Unknown icon: <code>%s</code>",
+		"Укажите ID кастомного эмодзи или отправьте сам эмодзи": "Specify a custom emoji ID or send the emoji itself",
+		"ID кастомного эмодзи должен состоять из цифр":          "A custom emoji ID must consist of digits",
+		"Иконка <code>%s</code>: %s":                            "Icon <code>%s</code>: %s",
+		"выключен":                                              "off",
+		"включён":                                               "on",
+		"Тихий режим: <b>%s</b>\n\nИспользование: <code>/silent on</code> или <code>/silent off</code>\nПисьма с кодами и приоритетные письма (/priority) всегда приходят со звуком.": "Silent mode: <b>%s</b>\n\nUsage: <code>/silent on</code> or <code>/silent off</code>\nEmails with codes and priority emails (/priority) always arrive with sound.",
+		"Использование: <code>/silent on</code> или <code>/silent off</code>": "Usage: <code>/silent on</code> or <code>/silent off</code>",
+		"Тихий режим включён: письма будут приходить без звука":               "Silent mode is on: emails will arrive without sound",
+		"Тихий режим выключен":                                                "Silent mode is off",
+		"не задан":                                                            "not set",
+		"Приоритетный шаблон: %s\n\nПисьма, тема или отправитель которых совпадает с шаблоном, приходят со звуком даже в тихом режиме.\n\nИспользование: <code>/priority (?i)alert|critical</code>\nОтключить: <code>/priority off</code>": "Priority pattern: %s\n\nEmails whose subject or sender matches the pattern arrive with sound even in silent mode.\n\nUsage: <code>/priority (?i)alert|critical</code>\nTurn off: <code>/priority off</code>",
+		"Приоритетный шаблон отключён":         "Priority pattern turned off",
+		"Приоритетный шаблон: <code>%s</code>": "Priority pattern: <code>%s</code>",
+		"выключена": "off",
+		"включена":  "on",
+		"Защита содержимого: <b>%s</b>\n\nИспользование: <code>/protect on</code> или <code>/protect off</code>\nЗащищённые письма нельзя переслать, скопировать или сохранить.": "Content protection: <b>%s</b>\n\nUsage: <code>/protect on</code> or <code>/protect off</code>\nProtected emails cannot be forwarded, copied or saved.",
+		"Использование: <code>/protect on</code> или <code>/protect off</code>":            "Usage: <code>/protect on</code> or <code>/protect off</code>",
+		"Защита содержимого включена: новые письма нельзя будет переслать или скопировать": "Content protection is on: new emails cannot be forwarded or copied",
+		"Защита содержимого выключена":                                                     "Content protection is off",
+		"окно ": "window ",
+		"Группировка одинаковых писем: <b>%s</b>\n\nПисьма с одинаковой темой (без учёта чисел), пришедшие в пределах окна, обновляют одно сообщение со счётчиком вместо новых сообщений.\n\nИспользование: <code>/collapse 30m</code>\nОтключить: <code>/collapse off</code>": "Grouping of identical emails: <b>%s</b>\n\nEmails with the same subject (ignoring numbers) that arrive within the window update one message with a counter instead of posting new messages.\n\nUsage: <code>/collapse 30m</code>\nTurn off: <code>/collapse off</code>",
+		"Укажите окно от 1m до 168h, например <code>/collapse 30m</code>": "Specify a window from 1m to 168h, e.g. <code>/collapse 30m</code>",
+		"Группировка включена: окно %s":                                   "Grouping is on: window %s",
+		"Группировка одинаковых писем отключена":                          "Grouping of identical emails is off",
+		"выключено": "off",
+		"на %s":     "for %s",
+		"Закрепление писем с кодами: <b>%s</b>\n\nПисьмо с найденным кодом закрепляется в топике и открепляется по истечении времени или после нажатия «Прочитано».\n\nИспользование: <code>/pincodes 10m</code>\nОтключить: <code>/pincodes off</code>": "Pinning emails with codes: <b>%s</b>\n\nAn email with a detected code is pinned in the topic and unpinned when the time is over or after «Read» is pressed.\n\nUsage: <code>/pincodes 10m</code>\nTurn off: <code>/pincodes off</code>",
+		"Укажите время от 1m до 24h, например <code>/pincodes 10m</code>":                                  "Specify a time from 1m to 24h, e.g. <code>/pincodes 10m</code>",
+		"Письма с кодами будут закрепляться на %s. Дайте боту право закреплять сообщения":                  "Emails with codes will be pinned for %s. Give the bot the right to pin messages",
+		"Закрепление писем с кодами отключено":                                                             "Pinning emails with codes is off",
+		"<b>Оформление писем в этом топике:</b>\n\n":                                                       "<b>Email layout in this topic:</b>\n\n",
+		"\nИспользование: <code>/profile compact</code>":                                                   "\nUsage: <code>/profile compact</code>",
+		"Неизвестный профиль. Доступно: <code>detailed</code>, <code>compact</code>, <code>minimal</code>": "Unknown profile. Available: <code>detailed</code>, <code>compact</code>, <code>minimal</code>",
+		"Профиль оформления: <b>%s</b> — %s":                                                               "Layout profile: <b>%s</b> — %s",
+		"опрос раз в %s":                   "polling every %s",
+		"IMAP IDLE, письма приходят сразу": "IMAP IDLE, emails arrive immediately",
+		"Режим получения: <b>%s</b>\nСейчас: %s\n\nИспользование: <code>/idle auto</code> (IDLE, если сервер поддерживает) или <code>/idle poll</code> (только опрос)": "Fetch mode: <b>%s</b>\nNow: %s\n\nUsage: <code>/idle auto</code> (IDLE if the server supports it) or <code>/idle poll</code> (polling only)",
+		"Использование: <code>/idle auto</code> или <code>/idle poll</code>":                      "Usage: <code>/idle auto</code> or <code>/idle poll</code>",
+		"Режим сохранён, но подключение не перезапущено: %v":                                      "The mode is saved, but the connection was not restarted: %v",
+		"Почта будет проверяться раз в %s":                                                        "Mail will be checked every %s",
+		"Почта будет получаться через IMAP IDLE, если сервер его поддерживает":                    "Mail will be received via IMAP IDLE if the server supports it",
+		"Специальные обработчики отправителей не настроены":                                       "No special sender handlers are configured",
+		"<b>Обработчики отправителей:</b>\n\n":                                                    "<b>Sender handlers:</b>\n\n",
+		"\nОни точнее находят коды, ссылки и данные в письмах этих отправителей.\n":               "\nThey find codes, links and data in emails of these senders more accurately.\n",
+		"Использование: <code>/extractors off steam</code> или <code>/extractors on steam</code>": "Usage: <code>/extractors off steam</code> or <code>/extractors on steam</code>",
+		"Неизвестный обработчик: <code>%s</code>":                                                 "Unknown handler: <code>%s</code>",
+		"Использование: <code>/extractors on|off имя</code>":                                      "Usage: <code>/extractors on|off name</code>",
+		"Обработчик <code>%s</code> включён":                                                      "Handler <code>%s</code> is on",
+		"Обработчик <code>%s</code> выключен, для этих писем используется общий поиск кодов":      "Handler <code>%s</code> is off, generic code detection is used for these emails",
+		"Тихие часы: <b>%s</b>\n\nВ тихие часы письма приходят без звука (<code>silent</code>) или откладываются до их конца (<code>hold</code>). Письма с кодами и подходящие под /priority приходят как обычно.\n\nИспользование: <code>/quiet 23:00-08:00 Europe/Moscow hold</code>\nОтключить: <code>/quiet off</code>": "Quiet hours: <b>%s</b>\n\nDuring quiet hours emails arrive without sound (<code>silent</code>) or are held until the hours end (<code>hold</code>). Emails with codes or matching /priority arrive as usual.\n\nUsage: <code>/quiet 23:00-08:00 Europe/Moscow hold</code>\nTurn off: <code>/quiet off</code>",
+		"Использование: <code>/quiet 23:00-08:00 [часовой пояс] [silent|hold]</code> или <code>/quiet off</code>":   "Usage: <code>/quiet 23:00-08:00 [time zone] [silent|hold]</code> or <code>/quiet off</code>",
+		"Неизвестный часовой пояс <code>%s</code>. Укажите его как <code>Europe/Moscow</code> или <code>UTC</code>": "Unknown time zone <code>%s</code>. Give it as <code>Europe/Moscow</code> or <code>UTC</code>",
+		"Тихие часы: %s": "Quiet hours: %s",
+		"Тихие часы отключены, отложенные письма будут опубликованы": "Quiet hours are off, held emails will be posted",
+		"время сервера":                                                 "server time",
+		"%s (%s), письма откладываются до конца":                        "%s (%s), emails are held until the end",
+		"%s (%s), письма приходят без звука":                            "%s (%s), emails arrive without sound",
+		"Шаблон не задан, письма оформляются по профилю <b>%s</b>.\n\n": "No template is set, emails use the <b>%s</b> profile.\n\n",
+		"<b>Шаблон писем в этом топике:</b>\n":                          "<b>Email template of this topic:</b>\n",
+		"Плейсхолдеры:\n<code>{icon}</code> — значок отправителя, <code>{auth}</code> — ✅ или ⚠️ по проверке DKIM/SPF\n<code>{from}</code> — имя и адрес, <code>{sender}</code> — имя или адрес, <code>{email}</code> — адрес\n<code>{subject}</code> — тема\n<code>{date}</code> — дата, <code>{date:DD.MM HH:mm}</code> — в своём формате\n<code>{codes}</code> — коды\n<code>{attachments}</code> — имена вложений\n<code>{extras}</code> — заказ, поля и ссылки из письма\n<code>{body}</code> — текст письма, <code>{body:300}</code> — первые 300 символов\n\n<code>**текст**</code> — жирный. Строки, в которых все плейсхолдеры пустые, не выводятся; кнопки остаются как в профиле.\n\nНапример:\n<code>/template {icon} **{sender}**: {subject}\n🔑 {codes}\n{body:500}</code>\n\n<code>/template off</code> — вернуть оформление профиля": "Placeholders:\n<code>{icon}</code> — sender icon, <code>{auth}</code> — ✅ or ⚠️ by the DKIM/SPF check\n<code>{from}</code> — name and address, <code>{sender}</code> — name or address, <code>{email}</code> — address\n<code>{subject}</code> — subject\n<code>{date}</code> — date, <code>{date:DD.MM HH:mm}</code> — in your own format\n<code>{codes}</code> — codes\n<code>{attachments}</code> — attachment names\n<code>{extras}</code> — order, fields and links from the email\n<code>{body}</code> — email text, <code>{body:300}</code> — the first 300 characters\n\n<code>**text**</code> — bold. Lines whose placeholders are all empty are skipped; buttons stay as in the profile.\n\nFor example:\n<code>/template {icon} **{sender}**: {subject}\n🔑 {codes}\n{body:500}</code>\n\n<code>/template off</code> — back to the profile layout",
+		"Шаблон отключён, письма оформляются по профилю <b>%s</b>": "Template disabled, emails use the <b>%s</b> profile",
+		"✅ Шаблон сохранён":                     "✅ Template saved",
+		"Так будет выглядеть последнее письмо:": "This is how the latest email will look:",
+		"Шаблон: свой (/template)\n":            "Template: custom (/template)\n",
+		"Значки отправителей: <b>%s</b>\n\n<code>/avatars photo</code> — аватар отправителя из Gravatar над письмом, если его нет — эмодзи домена\n<code>/avatars emoji</code> — эмодзи домена отправителя рядом со значком\n<code>/avatars off</code> — выключить": "Sender pictures: <b>%s</b>\n\n<code>/avatars photo</code> — the sender's Gravatar above the email, the domain emoji if there is none\n<code>/avatars emoji</code> — an emoji of the sender's domain next to the icon\n<code>/avatars off</code> — turn off",
+		"Использование: <code>/avatars photo</code>, <code>/avatars emoji</code> или <code>/avatars off</code>": "Usage: <code>/avatars photo</code>, <code>/avatars emoji</code> or <code>/avatars off</code>",
+		"Значки отправителей: <b>%s</b>. Применяется к новым письмам.":                                          "Sender pictures: <b>%s</b>. Applies to new emails.",
+		"аватар отправителя": "sender avatar",
+		"эмодзи домена":      "domain emoji",
+
+		"Спам и рассылки: <b>%s</b>\n\nСпамом считаются письма, помеченные почтовым сервером (X-Spam-Flag, X-Spam-Status), рассылками — письма с List-Unsubscribe, List-Id или Precedence: bulk. Письма с кодами приходят всегда.\n\n<code>/bulk deliver</code> — публиковать как обычно\n<code>/bulk drop</code> — не публиковать\n<code>/bulk digest</code> — собирать в сводку, она приходит %s\n<code>/bulk topic ID_топика</code> — публиковать без звука в отдельный топик": "Spam and newsletters: <b>%s</b>\n\nSpam is email flagged by the mail server (X-Spam-Flag, X-Spam-Status), newsletters are emails with List-Unsubscribe, List-Id or Precedence: bulk. Emails with codes always arrive.\n\n<code>/bulk deliver</code> — post as usual\n<code>/bulk drop</code> — do not post\n<code>/bulk digest</code> — collect into a digest, sent %s\n<code>/bulk topic topic_ID</code> — post silently to a separate topic",
+		"Использование: <code>/bulk topic ID_топика</code>":                     "Usage: <code>/bulk topic topic_ID</code>",
+		"Топик для рассылок должен отличаться от топика почты":                  "The newsletters topic must differ from the mail topic",
+		"Использование: <code>/bulk deliver|drop|digest|topic ID_топика</code>": "Usage: <code>/bulk deliver|drop|digest|topic topic_ID</code>",
+		"Спам и рассылки: <b>%s</b>":                                            "Spam and newsletters: <b>%s</b>",
+		"не публикуются":                                                        "skipped",
+		"в сводку, она приходит %s":                                             "to the digest, sent %s",
+		"без звука в топик <code>%d</code>":                                     "silently to topic <code>%d</code>",
+		"публикуются как обычно":                                                "posted as usual",
+
+		"Сводка писем: <b>%s</b>\n\nВ режиме сводки сразу приходят только письма с кодами и подходящие под /priority, остальные собираются в одно сообщение.\n\nИспользование: <code>/digest daily</code> или <code>/digest weekly</code>\nОтключить: <code>/digest off</code>": "Email digest: <b>%s</b>\n\nIn digest mode only emails with codes or matching /priority arrive right away, the rest are collected into one message.\n\nUsage: <code>/digest daily</code> or <code>/digest weekly</code>\nTurn off: <code>/digest off</code>",
+		"Использование: <code>/digest daily|weekly|off</code>":           "Usage: <code>/digest daily|weekly|off</code>",
+		"Письма без кодов будут приходить сводкой %s":                    "Emails without codes will arrive as a digest %s",
+		"Сводка отключена, накопленные письма придут в ближайшей сводке": "Digest is off, emails already collected will arrive in the next digest",
+
+		// smime_handler
+		"Используйте /smime в топике, к которому подключена почта":                                                 "Use /smime in the topic the mailbox is connected to",
+		"Использование: <code>/smime</code> — сертификат и загрузка ключа, <code>/smime off</code> — удалить ключ": "Usage: <code>/smime</code> — certificate and key upload, <code>/smime off</code> — delete the key",
+		"Ключ S/MIME не загружен": "No S/MIME key has been uploaded",
+		"Ошибка удаления ключа":   "Failed to delete the key",
+		"Ключ S/MIME для <b>%s</b> удалён: зашифрованные письма будут приходить уведомлением с вложением": "The S/MIME key for <b>%s</b> has been deleted: encrypted emails will arrive as a notice with an attachment",
+		"🔐 <b>S/MIME для %s</b>\n\n": "🔐 <b>S/MIME for %s</b>\n\n",
+		"Ключ не загружен: письма, зашифрованные S/MIME, приходят уведомлением с зашифрованным вложением .p7m.\n\n": "No key uploaded: S/MIME encrypted emails arrive as a notice with the encrypted .p7m attachment.\n\n",
+		"Не удалось прочитать сохранённый ключ: %s\n\n":                                                             "Failed to read the stored key: %s\n\n",
+		"Сертификат: <b>%s</b>, действует до %s":                                                                    "Certificate: <b>%s</b>, valid until %s",
+		" — истёк, но письма, зашифрованные для него, расшифровываются":                                             " — expired, but emails encrypted for it are still decrypted",
+		"Удалить ключ: <code>/smime off</code>":                                                                     "Delete the key: <code>/smime off</code>",
+		"Чтобы загрузить ключ, отправьте боту в личные сообщения в течение 10 минут PEM-файл с закрытым ключом RSA и сертификатом, без пароля. Из .p12 его можно получить командой <code>openssl pkcs12 -in cert.p12 -nodes -out key.pem</code>.": "To upload a key, send the bot a PEM file with the RSA private key and the certificate, without a password, in private messages within 10 minutes. It can be made from a .p12 with <code>openssl pkcs12 -in cert.p12 -nodes -out key.pem</code>.",
+		"Загрузить ключ": "Upload key",
+		"Отправьте PEM-файл с закрытым ключом и сертификатом S/MIME для <b>%s</b> следующим сообщением.\nОтмена: /cancel": "Send the PEM file with the S/MIME private key and certificate for <b>%s</b> in the next message.\nCancel: /cancel",
+		"Загрузка ключа отменена":                                            "Key upload cancelled",
+		"Не удалось загрузить файл. Отправьте его ещё раз или /cancel":       "Failed to download the file. Send it again or /cancel",
+		"Не удалось прочитать ключ: %s\n\nОтправьте PEM ещё раз или /cancel": "Failed to read the key: %s\n\nSend the PEM again or /cancel",
+		"Ошибка шифрования ключа":                                            "Failed to encrypt the key",
+		"Ошибка сохранения ключа":                                            "Failed to save the key",
+		"Ключ S/MIME для <b>%s</b> сохранён, сертификат <b>%s</b> до %s":     "The S/MIME key for <b>%s</b> has been saved, certificate <b>%s</b> until %s",
+		"\n⚠️ Сертификат выдан для другого адреса: бот расшифрует только письма, зашифрованные для него.":                                                 "\n⚠️ The certificate was issued for another address: the bot will only decrypt emails encrypted for it.",
+		"🔐 Загружен ключ S/MIME для <b>%s</b>: зашифрованные письма будут приходить расшифрованными":                                                      "🔐 An S/MIME key for <b>%s</b> has been uploaded: encrypted emails will arrive decrypted",
+		"🔒 Письмо зашифровано PGP. Бот не расшифровывает PGP: откройте письмо в почтовой программе с вашим ключом.":                                       "🔒 The email is PGP encrypted. The bot does not decrypt PGP: open the email in a mail client with your key.",
+		"🔒 Письмо зашифровано S/MIME, зашифрованное содержимое — во вложении .p7m. Чтобы бот расшифровывал такие письма, загрузите ключ командой /smime.": "🔒 The email is S/MIME encrypted, the encrypted content is in the .p7m attachment. To have the bot decrypt such emails, upload a key with /smime.",
+		"🔒 Письмо зашифровано S/MIME, но сохранённый ключ не удалось прочитать. Зашифрованное содержимое — во вложении .p7m.":                             "🔒 The email is S/MIME encrypted, but the stored key could not be read. The encrypted content is in the .p7m attachment.",
+		"🔒 Не удалось расшифровать письмо S/MIME: %v. Зашифрованное содержимое — во вложении .p7m.":                                                       "🔒 Failed to decrypt the S/MIME email: %v. The encrypted content is in the .p7m attachment.",
+		"🔓 Письмо было зашифровано S/MIME и расшифровано ботом":                                                                                           "🔓 The email was S/MIME encrypted and has been decrypted by the bot",
+		"\nВложения внутри зашифрованного письма (откройте его в почтовой программе): %s":                                                                 "\nAttachments inside the encrypted email (open it in a mail client): %s",
+
+		// status_board
+		"\n\n<i>Обновлено: ":                     "\n\n<i>Updated: ",
+		"<b>Статус почтовых подключений</b>\n\n": "<b>Mail connection status</b>\n\n",
+		"%s Подключено: <b>%d</b>\n":             "%s Connected: <b>%d</b>\n",
+		"%s Переподключение: <b>%d</b>\n":        "%s Reconnecting: <b>%d</b>\n",
+		"%s Ошибка: <b>%d</b>":                   "%s Error: <b>%d</b>",
+		"\n\n<b>Проблемы:</b>\n":                 "\n\n<b>Problems:</b>\n",
+		"включена (топик ID %d)":                 "on (topic ID %d)",
+		"Панель статуса: <b>%s</b>\n\nЗакреплённое сообщение с числом подключённых, переподключающихся и сломанных почт, обновляется автоматически.\n\nИспользование: <code>/statusboard on</code> в нужном топике\nОтключить: <code>/statusboard off</code>": "Status board: <b>%s</b>\n\nA pinned message with the number of connected, reconnecting and broken mailboxes, updated automatically.\n\nUsage: <code>/statusboard on</code> in the topic you need\nTurn off: <code>/statusboard off</code>",
+		"Использование: <code>/statusboard on</code> или <code>/statusboard off</code>": "Usage: <code>/statusboard on</code> or <code>/statusboard off</code>",
+		"Не удалось закрепить сообщение: дайте боту право закреплять сообщения":         "Failed to pin the message: give the bot the right to pin messages",
+		"Панель статуса не включена":                                                    "The status board is not enabled",
+		"Панель статуса отключена":                                                      "The status board is turned off",
+
+		// storage_quota
+		"🗄 Хранилище писем чата превысило лимит (%s из %s).\n\nУ %d самых старых писем удалены тексты и исходники (освобождено %s). Отправитель, тема и коды сохранены, сообщения в топиках не изменены.": "🗄 The chat's email storage has exceeded the limit (%s of %s).\n\nThe texts and sources of the %d oldest emails have been deleted (%s freed). The sender, subject and codes are kept, the messages in the topics are unchanged.",
+		"Ошибка получения данных":   "Failed to get data",
+		"🗄 Письма чата занимают %s": "🗄 The chat's emails take %s",
+		" из %s (%d%%).\nПри превышении лимита у самых старых писем удаляются тексты, отправитель, тема и коды сохраняются.": " of %s (%d%%).\nWhen the limit is exceeded, the texts of the oldest emails are deleted, the sender, subject and codes are kept.",
+		", лимит не задан.": ", no limit is set.",
+		"%d Б":              "%d B",
+		"КБ":                "KB",
+		"МБ":                "MB",
+		"ГБ":                "GB",
+		"ТБ":                "TB",
+
+		// tag_handler
+		"Только администраторы могут менять теги":                                      "Only administrators can change tags",
+		"Некорректный тег <code>%s</code>: только буквы, цифры, _ и -, до %d символов": "Invalid tag <code>%s</code>: only letters, digits, _ and -, up to %d characters",
+		"Ошибка получения тегов":                                                       "Failed to get tags",
+		"У аккаунта может быть не больше %d тегов":                                     "An account can have at most %d tags",
+		"Ошибка сохранения тегов":                                                      "Failed to save tags",
+		"Использование: <code>/untag тег</code>":                                       "Usage: <code>/untag tag</code>",
+		"У аккаунта нет такого тега":                                                   "The account has no such tag",
+		"Ошибка удаления тега":                                                         "Failed to delete the tag",
+		"<b>Теги %s:</b> %s\n":                                                         "<b>Tags of %s:</b> %s\n",
+		"Теги чата: %s\n\n":                                                            "Chat tags: %s\n\n",
+		"<code>/tag qa prod</code> — добавить, <code>/untag qa</code> — убрать\n":      "<code>/tag qa prod</code> — add, <code>/untag qa</code> — remove\n",
+		"<code>/pause tag:qa</code>, <code>/resume tag:qa</code>, <code>/stats tag:qa</code> — действия со всеми аккаунтами тега": "<code>/pause tag:qa</code>, <code>/resume tag:qa</code>, <code>/stats tag:qa</code> — actions on all accounts with the tag",
+		"нет": "none",
+		"Укажите тег в виде <code>tag:qa</code> или отправьте команду в топике с почтой": "Specify a tag as <code>tag:qa</code> or send the command in a topic with a mailbox",
+		"Нет аккаунтов с тегом <code>%s</code>":                                          "No accounts with tag <code>%s</code>",
+		"🔴 %s: ошибка сохранения":                                                        "🔴 %s: failed to save",
+		"<b>Пересылка приостановлена:</b> %d из %d":                                      "<b>Forwarding paused:</b> %d of %d",
+		"<b>Пересылка возобновлена:</b> %d из %d":                                        "<b>Forwarding resumed:</b> %d of %d",
+		"<b>Статистика за 24 часа</b>\n\n":                                               "<b>Statistics for 24 hours</b>\n\n",
+		"Ошибка получения статистики":                                                    "Failed to get statistics",
+		"писем нет":  "no emails",
+		"последнее ": "last ",
+		"<b>%s</b> (%s)\n   %d писем, %d с кодами · всего %d · %s\n": "<b>%s</b> (%s)\n   %d emails, %d with codes · %d total · %s\n",
+		"... и ещё %d\n": "... and %d more\n",
+		"\n<b>Итого:</b> %d аккаунтов, %d писем, %d с кодами": "\n<b>Total:</b> %d accounts, %d emails, %d with codes",
+
+		// test_handler
+		"Только администраторы могут отправлять тестовые письма":                                                                                         "Only admins can send test emails",
+		"Пересылка для этого ящика приостановлена, тестовое письмо не дойдёт до топика":                                                                  "Forwarding of this mailbox is paused, a test email would not reach the topic",
+		"Тестовое письмо уже отправлено, дождитесь результата":                                                                                           "A test email was already sent, wait for the result",
+		"⏳ Отправляю тестовое письмо на <b>%s</b>...":                                                                                                    "⏳ Sending a test email to <b>%s</b>...",
+		"Это тестовое письмо отправлено командой /test, чтобы проверить пересылку почты в Telegram.":                                                     "This test email was sent with the /test command to check forwarding of emails to Telegram.",
+		"❌ Не удалось отправить тестовое письмо через <code>%s</code>:\n<code>%s</code>":                                                                 "❌ Failed to send the test email through <code>%s</code>:\n<code>%s</code>",
+		"⏳ Тестовое письмо отправлено за %s, жду его в топике (до %s)...":                                                                                "⏳ The test email was sent in %s, waiting for it in the topic (up to %s)...",
+		"❌ Тестовое письмо не пришло в ящик за %s.\n\nОно могло попасть в спам или задержаться на сервере. Состояние подключения — в /status.":           "❌ The test email did not arrive in the mailbox within %s.\n\nIt may have gone to spam or been delayed by the server. See /status for the connection state.",
+		"⚠️ Тестовое письмо пришло в ящик через %s, но не опубликовано за %s.\n\nВозможно, его задержали /filter, /digest, /quiet или очередь отправки.": "⚠️ The test email arrived in the mailbox after %s, but was not posted within %s.\n\nIt may have been held by /filter, /digest, /quiet or the send queue.",
+		"✅ <b>Пересылка %s работает</b>\n\n":                                                                                                             "✅ <b>Forwarding of %s works</b>\n\n",
+		"Отправка по SMTP: %s\n":    "SMTP send: %s\n",
+		"Получение ящиком: %s\n":    "Received by the mailbox: %s\n",
+		"Публикация в топике: %s\n": "Posted to the topic: %s\n",
+		"\nВсего: <b>%s</b>":        "\nTotal: <b>%s</b>",
+
+		// unread_badge
+		"включён, название <b>%s</b>": "on, name <b>%s</b>",
+		"Счётчик непрочитанных в названии топика: %s\n\nИспользование: <code>/badge on</code> — название с числом непрочитанных писем за неделю, например «%s (3)»; <code>/badge on Поддержка</code> — своё название; <code>/badge off</code> — выключить": "Unread count in the topic name: %s\n\nUsage: <code>/badge on</code> — name with the number of unread emails of the last week, e.g. “%s (3)”; <code>/badge on Support</code> — a custom name; <code>/badge off</code> — turn off",
+		"Счётчик непрочитанных работает только в топиках форума":                       "The unread count works only in forum topics",
+		"Название слишком длинное":                                                     "The name is too long",
+		"Использование: <code>/badge on [название]</code> или <code>/badge off</code>": "Usage: <code>/badge on [name]</code> or <code>/badge off</code>",
+		"Счётчик выключен, но не удалось переименовать топик: %v":                      "The count is off, but the topic could not be renamed: %v",
+		"Счётчик непрочитанных выключен":                                               "Unread count turned off",
+		"Счётчик непрочитанных включён: топик будет называться <b>%s</b> с числом непрочитанных писем. Боту нужно право управлять топиками.": "Unread count turned on: the topic will be named <b>%s</b> with the number of unread emails. The bot needs the right to manage topics.",
+
+		// unsubscribe_handler
+		"В письме нет способа отписаться": "The email has no way to unsubscribe",
+		"Отписка отменена":                "Unsubscribing cancelled",
+		"Подтверждение устарело, нажмите «Отписаться» под письмом ещё раз": "The confirmation has expired, press \"Unsubscribe\" under the email again",
+		"Не удалось отписаться:\n<code>%s</code>":                          "Failed to unsubscribe:\n<code>%s</code>",
+		"Ошибка отписки": "Unsubscribe error",
+		"✅ Запрос на отписку от <b>%s</b> отправлен. Письма рассылки могут приходить ещё несколько дней.": "✅ The unsubscribe request to <b>%s</b> has been sent. Newsletter emails may keep arriving for a few more days.",
+		"Запрос отправлен":                                                                "Request sent",
+		"Бот отправит письмо об отписке с адреса %s.":                                     "The bot will send an unsubscribe email from %s.",
+		"Бот отправит запрос на отписку на сайт %s.":                                      "The bot will send an unsubscribe request to %s.",
+		"🚫 <b>Отписаться от рассылки %s?</b>\n\n%s\nПодтвердить можно в течение 5 минут.": "🚫 <b>Unsubscribe from %s?</b>\n\n%s\nYou can confirm within 5 minutes.",
+		"🚫 Отписаться": "🚫 Unsubscribe",
+
+		// version
+		"Версия: <code>%s</code>\n":                  "Version: <code>%s</code>\n",
+		" (изменён)":                                 " (modified)",
+		"Коммит: <code>%s</code>\n":                  "Commit: <code>%s</code>\n",
+		"Сборка: %s\n":                               "Built: %s\n",
+		"\n⬆️ Доступна версия <a href=\"%s\">%s</a>": "\n⬆️ Version <a href=\"%s\">%s</a> is available",
+		" с исправлениями безопасности":              " with security fixes",
+		"🔒 <b>Доступно обновление безопасности</b>\n\nТекущая версия: <code>%s</code>\nНовая версия: <a href=\"%s\">%s</a>": "🔒 <b>Security update available</b>\n\nCurrent version: <code>%s</code>\nNew version: <a href=\"%s\">%s</a>",
+
+		// webapp
+		"⏸ Пересылка приостановлена из панели управления (%s)":                    "⏸ Forwarding paused from the dashboard (%s)",
+		"▶️ Пересылка возобновлена из панели управления (%s)":                     "▶️ Forwarding resumed from the dashboard (%s)",
+		"Панель управления не настроена: владельцу бота нужно указать WEBAPP_URL": "The dashboard is not set up: the bot owner needs to set WEBAPP_URL",
+		"Открыть панель": "Open dashboard",
+		"Панель управления открывается в личных сообщениях с ботом: аккаунты чатов, где вы администратор, последние письма и статистика доставки": "The dashboard opens in a private chat with the bot: accounts of chats you are an admin of, latest emails and delivery stats",
+		"Панель управления: аккаунты чатов, где вы администратор, последние письма, статистика доставки, пауза и переподключение":                 "Dashboard: accounts of chats you are an admin of, latest emails, delivery stats, pause and reconnect",
+	})
+}
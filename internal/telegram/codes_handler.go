@@ -0,0 +1,257 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/mixelka/emailresend/internal/database"
+	"github.com/mixelka/emailresend/internal/i18n"
+	"github.com/mixelka/emailresend/internal/parser"
+	appmodels "github.com/mixelka/emailresend/pkg/models"
+)
+
+// maxCodePatterns caps the custom code patterns of an account
+const maxCodePatterns = 20
+
+// codesUsage explains the /codes command
+const codesUsage = `Использование:
+<code>/codes add "Ваш промокод[:\s]+([A-Z0-9]{6})"</code> — добавить шаблон; код — первая группа в скобках, без групп — всё совпадение
+<code>/codes test "шаблон" текст</code> — проверить шаблон на примере текста (его можно дать следующими строками или ответом на сообщение)
+<code>/codes del 3</code> — удалить шаблон`
+
+// patternQuotes maps opening quotes around a /codes pattern to the closing
+// ones; phone keyboards often replace straight quotes
+var patternQuotes = map[rune]rune{'"': '"', '“': '”', '«': '»'}
+
+// handleCodes handles /codes command: custom code patterns of the topic's
+// account, used together with the built-in ones
+// Usage: /codes [add pattern | test pattern text | del id]
+func (b *Bot) handleCodes(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	parts := strings.Fields(msg.Text)
+	if len(parts) >= 2 && strings.ToLower(parts[1]) == "test" {
+		// Testing needs no account and changes nothing
+		b.testCodePattern(ctx, msg)
+		return
+	}
+
+	account, ok := b.getTopicAccount(ctx, msg)
+	if !ok {
+		return
+	}
+
+	if len(parts) < 2 {
+		b.sendCodePatternList(ctx, msg, account)
+		return
+	}
+
+	if !b.checkAdmin(ctx, msg, "Только администраторы могут менять шаблоны кодов") {
+		return
+	}
+
+	switch strings.ToLower(parts[1]) {
+	case "add":
+		b.addCodePattern(ctx, msg, account)
+	case "del":
+		b.deleteCodePattern(ctx, msg, account, parts)
+	default:
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, codesUsage)
+	}
+}
+
+// sendCodePatternList shows the custom code patterns of an account
+func (b *Bot) sendCodePatternList(ctx context.Context, msg *models.Message, account *appmodels.EmailAccount) {
+	patterns, err := b.db.GetCodePatterns(ctx, account.ID)
+	if err != nil {
+		b.logger.Error("failed to get code patterns", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка получения шаблонов кодов")
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(i18n.T(ctx, "<b>Свои шаблоны кодов этого топика:</b>\n\n"))
+	if len(patterns) == 0 {
+		sb.WriteString(i18n.T(ctx, "Шаблонов нет, коды ищутся только общими шаблонами\n"))
+	}
+	for _, p := range patterns {
+		sb.WriteString(fmt.Sprintf("<code>%d</code> <code>%s</code>\n", p.ID, html.EscapeString(p.Pattern)))
+	}
+	sb.WriteString("\n" + i18n.T(ctx, codesUsage))
+	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, sb.String())
+}
+
+// addCodePattern adds a pattern from /codes add pattern
+func (b *Bot) addCodePattern(ctx context.Context, msg *models.Message, account *appmodels.EmailAccount) {
+	pattern, sample := codePatternArgs(msg.Text)
+	if pattern == "" {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, codesUsage)
+		return
+	}
+	re, err := parser.CompileCustomPattern(pattern)
+	if err != nil {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.Tf(ctx, "Некорректный шаблон: <code>%s</code>", html.EscapeString(err.Error())))
+		return
+	}
+
+	existing, err := b.db.GetCodePatterns(ctx, account.ID)
+	if err != nil {
+		b.logger.Error("failed to get code patterns", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка получения шаблонов кодов")
+		return
+	}
+	if len(existing) >= maxCodePatterns {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+			i18n.Tf(ctx, "У топика уже %d шаблонов, удалите ненужные: <code>/codes del номер</code>", len(existing)))
+		return
+	}
+	for _, p := range existing {
+		if p.Pattern == pattern {
+			b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.Tf(ctx, "Такой шаблон уже есть под номером <code>%d</code>", p.ID))
+			return
+		}
+	}
+
+	codePattern := &appmodels.CodePattern{
+		AccountID: account.ID,
+		Pattern:   pattern,
+		CreatedBy: msg.From.ID,
+	}
+	if err := b.db.CreateCodePattern(ctx, codePattern); err != nil {
+		b.logger.Error("failed to create code pattern", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка сохранения шаблона")
+		return
+	}
+
+	b.logger.Info("code pattern added", "account_id", account.ID, "pattern_id", codePattern.ID, "user_id", msg.From.ID)
+	text := i18n.Tf(ctx, "Шаблон <code>%d</code> добавлен: <code>%s</code>\nОн применяется к новым письмам, старые можно разобрать заново через /reparse",
+		codePattern.ID, html.EscapeString(pattern))
+	if sample != "" {
+		text += "\n\n" + b.codeTestReport(ctx, re, sample)
+	}
+	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, text)
+}
+
+// deleteCodePattern removes a pattern from /codes del id
+func (b *Bot) deleteCodePattern(ctx context.Context, msg *models.Message, account *appmodels.EmailAccount, parts []string) {
+	if len(parts) < 3 {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Использование: <code>/codes del номер_шаблона</code>")
+		return
+	}
+	id, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Некорректный номер шаблона")
+		return
+	}
+
+	err = b.db.DeleteCodePattern(ctx, account.ID, id)
+	if errors.Is(err, database.ErrNotFound) {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Шаблон не найден")
+		return
+	}
+	if err != nil {
+		b.logger.Error("failed to delete code pattern", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка удаления шаблона")
+		return
+	}
+
+	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.Tf(ctx, "Шаблон <code>%d</code> удалён", id))
+}
+
+// testCodePattern runs a pattern against sample text from /codes test
+func (b *Bot) testCodePattern(ctx context.Context, msg *models.Message) {
+	pattern, sample := codePatternArgs(msg.Text)
+	if sample == "" && msg.ReplyToMessage != nil {
+		sample = msg.ReplyToMessage.Text
+		if sample == "" {
+			sample = msg.ReplyToMessage.Caption
+		}
+	}
+	if pattern == "" || sample == "" {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, codesUsage)
+		return
+	}
+
+	re, err := parser.CompileCustomPattern(pattern)
+	if err != nil {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.Tf(ctx, "Некорректный шаблон: <code>%s</code>", html.EscapeString(err.Error())))
+		return
+	}
+	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, b.codeTestReport(ctx, re, sample))
+}
+
+// codeTestReport lists the codes a pattern and the built-in patterns find in
+// sample text
+func (b *Bot) codeTestReport(ctx context.Context, re *regexp.Regexp, sample string) string {
+	var sb strings.Builder
+	sb.WriteString(i18n.T(ctx, "🧪 <b>Проверка шаблона</b>\n"))
+
+	if codes := parser.MatchCustomPattern(re, sample); len(codes) > 0 {
+		sb.WriteString(i18n.Tf(ctx, "Шаблон нашёл: %s\n", formatCodeValues(codes)))
+	} else {
+		sb.WriteString(i18n.T(ctx, "Шаблон ничего не нашёл в тексте\n"))
+	}
+
+	var builtin []string
+	for _, code := range b.codeDetector.DetectCodes(sample) {
+		builtin = append(builtin, code.Value)
+	}
+	if len(builtin) > 0 {
+		sb.WriteString(i18n.Tf(ctx, "Общие шаблоны нашли: %s\n", formatCodeValues(builtin)))
+	} else {
+		sb.WriteString(i18n.T(ctx, "Общие шаблоны ничего не нашли\n"))
+	}
+	return sb.String()
+}
+
+// formatCodeValues joins codes for a message
+func formatCodeValues(codes []string) string {
+	escaped := make([]string, len(codes))
+	for i, code := range codes {
+		escaped[i] = "<code>" + html.EscapeString(code) + "</code>"
+	}
+	return strings.Join(escaped, ", ")
+}
+
+// codePatternArgs splits "/codes add|test ..." into the pattern and the text
+// after it. A quoted pattern may contain spaces and be followed by text on the
+// same line; otherwise the pattern is the rest of the first line.
+func codePatternArgs(text string) (pattern, rest string) {
+	args := text
+	for range 2 {
+		args = strings.TrimLeftFunc(args, unicode.IsSpace)
+		i := strings.IndexFunc(args, unicode.IsSpace)
+		if i < 0 {
+			return "", ""
+		}
+		args = args[i:]
+	}
+	args = strings.TrimLeftFunc(args, unicode.IsSpace)
+
+	open, size := utf8.DecodeRuneInString(args)
+	if closing, ok := patternQuotes[open]; ok {
+		body := args[size:]
+		for i, r := range body {
+			if r != closing {
+				continue
+			}
+			after := body[i+utf8.RuneLen(r):]
+			if next, _ := utf8.DecodeRuneInString(after); after == "" || unicode.IsSpace(next) {
+				return body[:i], strings.TrimSpace(after)
+			}
+		}
+	}
+
+	line, rest, _ := strings.Cut(args, "\n")
+	return strings.TrimSpace(line), strings.TrimSpace(rest)
+}
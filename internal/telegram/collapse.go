@@ -0,0 +1,105 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot/models"
+
+	"github.com/mixelka/emailresend/internal/database"
+	"github.com/mixelka/emailresend/internal/formatter"
+	"github.com/mixelka/emailresend/internal/i18n"
+	appmodels "github.com/mixelka/emailresend/pkg/models"
+)
+
+var (
+	// collapseReplyPrefixRe matches reply/forward prefixes of a subject
+	collapseReplyPrefixRe = regexp.MustCompile(`^(?i)((re|fwd?|aw|wg)(\[\d+\])?:\s*)+`)
+	// collapseDigitsRe matches numbers that vary between otherwise identical alerts
+	collapseDigitsRe = regexp.MustCompile(`\d+`)
+)
+
+// collapseKey normalizes a subject so that repeated alerts share one key
+func collapseKey(subject string) string {
+	key := strings.ToLower(strings.TrimSpace(subject))
+	key = collapseReplyPrefixRe.ReplaceAllString(key, "")
+	key = collapseDigitsRe.ReplaceAllString(key, "#")
+	return strings.Join(strings.Fields(key), " ")
+}
+
+// activeCollapseGroup returns the collapse group an email should be merged
+// into, or nil if collapsing is off or the window has expired
+func (b *Bot) activeCollapseGroup(ctx context.Context, account *appmodels.EmailAccount, key string) *appmodels.CollapsedGroup {
+	if account.CollapseWindow <= 0 || key == "" {
+		return nil
+	}
+
+	group, err := b.db.GetCollapsedGroup(ctx, account.ID, key)
+	if err != nil {
+		if !errors.Is(err, database.ErrNotFound) {
+			b.logger.Error("failed to get collapsed group", "error", err)
+		}
+		return nil
+	}
+
+	window := time.Duration(account.CollapseWindow) * time.Second
+	if time.Since(group.LastAt) > window {
+		return nil
+	}
+	return group
+}
+
+// deliverCollapsed updates the group's Telegram message with the new email
+// and counter. Returns false if the email must be sent as a new message.
+func (b *Bot) deliverCollapsed(ctx context.Context, group *appmodels.CollapsedGroup, account *appmodels.EmailAccount, msg *appmodels.EmailMessage, text string, keyboard *models.InlineKeyboardMarkup, parseMode models.ParseMode) (bool, error) {
+	group.Count++
+	group.LastAt = time.Now()
+
+	header := formatter.FormatCollapseHeader(parseMode, i18n.Lang(ctx), msg.Subject, group.Count, group.LastAt)
+	if err := b.editMessageWithKeyboard(ctx, account.ChatID, group.TelegramMsgID, header+"\n\n"+text, keyboard, parseMode); err != nil {
+		if isTelegramUnavailable(err) {
+			return false, errors.Join(errTelegramUnavailable, err)
+		}
+		// The collapsed message was probably deleted, start a new group
+		b.logger.Warn("failed to update collapsed message", "error", err, "telegram_msg_id", group.TelegramMsgID)
+		return false, nil
+	}
+
+	if err := b.db.SaveCollapsedGroup(ctx, group); err != nil {
+		b.logger.Error("failed to save collapsed group", "error", err)
+	}
+	if err := b.db.UpdateMessageTelegramMsgID(ctx, msg.ID, group.TelegramMsgID); err != nil {
+		b.logger.Error("failed to update telegram msg id", "error", err)
+	}
+
+	b.logger.Info("email collapsed into existing message",
+		"account_id", account.ID,
+		"telegram_msg_id", group.TelegramMsgID,
+		"count", group.Count,
+	)
+	return true, nil
+}
+
+// startCollapseGroup remembers a freshly sent message as the target for
+// following emails with the same subject
+func (b *Bot) startCollapseGroup(ctx context.Context, account *appmodels.EmailAccount, key string, tgMsgID int) {
+	if account.CollapseWindow <= 0 || key == "" {
+		return
+	}
+
+	now := time.Now()
+	group := &appmodels.CollapsedGroup{
+		AccountID:     account.ID,
+		SubjectKey:    key,
+		TelegramMsgID: tgMsgID,
+		Count:         1,
+		FirstAt:       now,
+		LastAt:        now,
+	}
+	if err := b.db.SaveCollapsedGroup(ctx, group); err != nil {
+		b.logger.Error("failed to save collapsed group", "error", err)
+	}
+}
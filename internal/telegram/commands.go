@@ -0,0 +1,74 @@
+package telegram
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+// helpCommandRe matches command mentions in help texts
+var helpCommandRe = regexp.MustCompile(`(^|[\s>])/([a-z]+)`)
+
+// registerCommand registers a handler for a bot command, honouring the
+// configured command prefix and @username addressing. Every command is
+// audit-logged and checked against the chat's /permissions; middlewares run
+// in the given order before the handler.
+func (b *Bot) registerCommand(name string, handler bot.HandlerFunc, middlewares ...bot.Middleware) {
+	b.commands = append(b.commands, name)
+	b.bot.RegisterHandlerMatchFunc(func(update *models.Update) bool {
+		if update.Message == nil {
+			return false
+		}
+		cmd, ok := b.parseCommand(update.Message, update.Message.Text)
+		return ok && cmd == name
+	}, chain(handler, append([]bot.Middleware{b.auditLog, b.checkPermission(name)}, middlewares...)...))
+}
+
+// parseCommand extracts the command name from text without the slash, the
+// configured prefix and the @username suffix. Returns false if text is not a
+// command addressed to this bot.
+func (b *Bot) parseCommand(msg *models.Message, text string) (string, bool) {
+	if !strings.HasPrefix(text, "/") {
+		return "", false
+	}
+
+	token := strings.Fields(text)[0][1:]
+	name, mention, hasMention := strings.Cut(token, "@")
+	if hasMention {
+		if !strings.EqualFold(mention, b.username) {
+			return "", false
+		}
+	} else if b.config.CommandRequireMention && msg.Chat.Type != "private" {
+		return "", false
+	}
+
+	prefix := b.config.CommandPrefix
+	if !strings.HasPrefix(name, prefix) || len(name) == len(prefix) {
+		return "", false
+	}
+
+	return name[len(prefix):], true
+}
+
+// command returns how users should type the given command in this deployment
+func (b *Bot) command(name string) string {
+	cmd := "/" + b.config.CommandPrefix + name
+	if b.config.CommandRequireMention && b.username != "" {
+		cmd += "@" + b.username
+	}
+	return cmd
+}
+
+// withCommandNames rewrites command mentions in a help text to match the
+// configured prefix and addressing
+func (b *Bot) withCommandNames(text string) string {
+	if b.config.CommandPrefix == "" && !b.config.CommandRequireMention {
+		return text
+	}
+	return helpCommandRe.ReplaceAllStringFunc(text, func(m string) string {
+		sub := helpCommandRe.FindStringSubmatch(m)
+		return sub[1] + b.command(sub[2])
+	})
+}
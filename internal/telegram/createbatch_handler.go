@@ -0,0 +1,176 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/mixelka/emailresend/internal/i18n"
+	"github.com/mixelka/emailresend/internal/importer"
+	"github.com/mixelka/emailresend/internal/mailcow"
+	appmodels "github.com/mixelka/emailresend/pkg/models"
+)
+
+// createBatchMax is the largest number of mailboxes created by one command
+const createBatchMax = 50
+
+// createdMailbox is a mailbox provisioned by /createbatch
+type createdMailbox struct {
+	email    string
+	password string
+	topicID  int
+}
+
+// handleCreateBatch handles /createbatch command
+// Usage: /createbatch team{1..10}[@domain]
+func (b *Bot) handleCreateBatch(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	if b.mailcow == nil || !b.mailcow.IsConfigured() {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Интеграция с Mailcow не настроена")
+		return
+	}
+
+	domain := b.mailcow.GetDomain()
+	parts := strings.Fields(msg.Text)
+	if len(parts) != 2 {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.Tf(ctx,
+			"Использование: <code>/createbatch team{1..10}</code>\n\nБудут созданы ящики team1@%s ... team10@%s, для каждого — отдельный топик. В конце придёт файл с паролями в формате /import.",
+			domain, domain))
+		return
+	}
+
+	pattern, patternDomain, hasDomain := strings.Cut(parts[1], "@")
+	if hasDomain && !strings.EqualFold(patternDomain, domain) {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+			i18n.Tf(ctx, "Ящики создаются только на домене %s", domain))
+		return
+	}
+
+	names, err := mailcow.ExpandPattern(pattern, createBatchMax)
+	if err != nil {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+			i18n.Tf(ctx, "Некорректный шаблон: <code>%s</code>", html.EscapeString(err.Error())))
+		return
+	}
+
+	progressMsg, err := b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.Tf(ctx, "Создание ящиков: 0/%d...", len(names)))
+	if err != nil {
+		b.logger.Error("failed to send progress message", "error", err)
+		return
+	}
+
+	var (
+		results    []importResult
+		created    []createdMailbox
+		lastReport time.Time
+	)
+	for i, name := range names {
+		if ctx.Err() != nil {
+			break
+		}
+
+		mailbox, err := b.createBatchMailbox(ctx, msg.Chat.ID, msg.From.ID, name)
+		rec := importer.Record{Email: name + "@" + domain}
+		if mailbox != nil {
+			rec.TopicID = mailbox.topicID
+			created = append(created, *mailbox)
+		}
+		results = append(results, importResult{record: rec, err: err})
+
+		if time.Since(lastReport) >= importProgressInterval {
+			lastReport = time.Now()
+			b.editMessageText(ctx, msg.Chat.ID, progressMsg.ID, i18n.Tf(ctx, "Создание ящиков: %d/%d...", i+1, len(names)))
+		}
+	}
+
+	b.sendImportReport(ctx, msg.Chat.ID, msg.MessageThreadID, progressMsg.ID, results)
+	b.logger.Info("mailbox batch created", "chat_id", msg.Chat.ID, "pattern", parts[1], "created", len(created), "total", len(names))
+
+	if len(created) > 0 {
+		b.sendBatchCredentials(ctx, msg, created)
+	}
+}
+
+// createBatchMailbox creates a topic named after the mailbox, the mailbox in
+// Mailcow and the account connected to the topic. The topic is removed again
+// if the mailbox cannot be created.
+func (b *Bot) createBatchMailbox(ctx context.Context, chatID, userID int64, localPart string) (*createdMailbox, error) {
+	emailAddr := localPart + "@" + b.mailcow.GetDomain()
+
+	topicID, err := b.createAccountTopic(ctx, chatID, emailAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	mailbox, err := b.mailcow.CreateMailbox(ctx, localPart, localPart, "", 1024)
+	if err != nil {
+		b.deleteTopic(ctx, chatID, topicID)
+		return nil, err
+	}
+	created := &createdMailbox{email: emailAddr, password: mailbox.Password, topicID: topicID}
+
+	encryptedPassword, err := b.encryptPassword(mailbox.Password)
+	if err != nil {
+		return created, err
+	}
+
+	account := &appmodels.EmailAccount{
+		Email:       emailAddr,
+		Password:    encryptedPassword,
+		IMAPServer:  b.mailcow.GetIMAPServer(),
+		ChatID:      chatID,
+		TopicID:     topicID,
+		IsActive:    true,
+		CreatedBy:   userID,
+		BotID:       b.accountBotID(),
+		Provisioned: true,
+	}
+	if err := b.db.CreateAccount(ctx, account); err != nil {
+		return created, fmt.Errorf("mailbox created, but not connected: %w", err)
+	}
+	if err := b.emailManager.AddAccount(ctx, account); err != nil {
+		b.db.DeleteAccount(ctx, account.ID)
+		return created, fmt.Errorf("mailbox created, but not connected: %w", err)
+	}
+	b.wakeStatusBoards()
+
+	b.sendMessage(ctx, chatID, topicID,
+		i18n.Tf(ctx, "Почтовый ящик <b>%s</b> создан и подключён к этому топику.", emailAddr))
+	return created, nil
+}
+
+// sendBatchCredentials sends the created mailboxes as a CSV file that
+// /import accepts
+func (b *Bot) sendBatchCredentials(ctx context.Context, msg *models.Message, created []createdMailbox) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	imapServer := b.mailcow.GetIMAPServer()
+	w.Write([]string{"email", "password", "topic_id", "imap_server"})
+	for _, c := range created {
+		w.Write([]string{c.email, c.password, strconv.Itoa(c.topicID), imapServer})
+	}
+	w.Flush()
+
+	params := &bot.SendDocumentParams{
+		ChatID:          msg.Chat.ID,
+		MessageThreadID: msg.MessageThreadID,
+		Document: &models.InputFileUpload{
+			Filename: "mailboxes-" + time.Now().Format("20060102-150405") + ".csv",
+			Data:     &buf,
+		},
+		Caption: i18n.Tf(ctx, "🔐 Учётные данные %d ящиков (SMTP: %s). Сохраните файл и удалите это сообщение.",
+			len(created), strings.Replace(imapServer, ":993", ":587", 1)),
+	}
+	if _, err := b.sendDocument(ctx, params); err != nil {
+		b.logger.Error("failed to send mailbox credentials", "error", err)
+	}
+}
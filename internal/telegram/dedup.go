@@ -0,0 +1,78 @@
+package telegram
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mixelka/emailresend/internal/database"
+	"github.com/mixelka/emailresend/internal/email"
+	"github.com/mixelka/emailresend/pkg/models"
+)
+
+// contentHash hashes the sender, subject, body and attachment list of an
+// email with whitespace normalized. Returns "" if the body was not
+// downloaded, as such emails cannot be compared.
+func contentHash(rawEmail *email.RawEmail) string {
+	if rawEmail.BodySkipped {
+		return ""
+	}
+
+	h := sha256.New()
+	for _, part := range []string{
+		strings.ToLower(rawEmail.From.Address),
+		normalizeSpace(rawEmail.Subject),
+		normalizeSpace(rawEmail.BodyText),
+		normalizeSpace(rawEmail.BodyHTML),
+	} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	for _, att := range rawEmail.Attachments {
+		fmt.Fprintf(h, "%s\x00%d\x00", att.Filename, att.Size)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// normalizeSpace collapses runs of whitespace into single spaces
+func normalizeSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// findDuplicate returns the ID of an email of the account with the same
+// content hash received within DedupWindow, or 0 if there is none
+func (b *Bot) findDuplicate(ctx context.Context, accountID int64, hash string) int64 {
+	if b.config.DedupWindow <= 0 || hash == "" {
+		return 0
+	}
+
+	id, err := b.db.FindDuplicateMessage(ctx, accountID, hash, time.Now().Add(-b.config.DedupWindow))
+	if err != nil {
+		if !errors.Is(err, database.ErrNotFound) {
+			b.logger.Error("failed to check for duplicate email", "error", err)
+		}
+		return 0
+	}
+	return id
+}
+
+// findPosted returns the Telegram message an email with the Message-ID was
+// already posted as for the mailbox of the account, or 0 if there is none
+func (b *Bot) findPosted(ctx context.Context, account *models.EmailAccount, messageID string) int {
+	if messageID == "" {
+		return 0
+	}
+
+	tgMsgID, err := b.db.FindPostedMessage(ctx, account.ChatID, account.Email, messageID)
+	if err != nil {
+		if !errors.Is(err, database.ErrNotFound) {
+			b.logger.Error("failed to check for posted email", "error", err)
+		}
+		return 0
+	}
+	return tgMsgID
+}
@@ -0,0 +1,479 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/mixelka/emailresend/internal/formatter"
+	"github.com/mixelka/emailresend/internal/i18n"
+	appmodels "github.com/mixelka/emailresend/pkg/models"
+)
+
+const (
+	// deliveryBatchSize is the number of queued messages loaded per pass
+	deliveryBatchSize = 50
+	// deliveryIdleInterval is how often the queue is rechecked without a wake-up
+	deliveryIdleInterval = 30 * time.Second
+	// deliveryMaxAttempts is the number of failed sends after which a message
+	// becomes a dead letter
+	deliveryMaxAttempts = 10
+	// deadLetterMaxRetries is the number of automatic redeliveries of a dead
+	// letter; after that it waits for /redeliver
+	deadLetterMaxRetries = 5
+	// deadLetterRetryBase is the delay before the first redelivery, doubled
+	// for each next one
+	deadLetterRetryBase = time.Hour
+	// probeMaxInterval caps the backoff between Telegram availability probes
+	probeMaxInterval = 5 * time.Minute
+	// drainPollInterval is how often Drain checks the send queue
+	drainPollInterval = 100 * time.Millisecond
+)
+
+// errTelegramUnavailable is returned when the Telegram API cannot be reached
+var errTelegramUnavailable = errors.New("telegram api unavailable")
+
+// quietHoldError postpones a message until the quiet hours of its account end
+type quietHoldError struct {
+	until time.Time
+}
+
+func (e *quietHoldError) Error() string {
+	return "held until " + e.until.Format(time.RFC3339)
+}
+
+// wakeDelivery signals the delivery worker that new messages were queued
+func (b *Bot) wakeDelivery() {
+	select {
+	case b.deliveryWake <- struct{}{}:
+	default:
+	}
+}
+
+// runDelivery drains the persistent send queue until ctx is cancelled
+func (b *Bot) runDelivery(ctx context.Context) {
+	b.logger.Info("starting delivery worker")
+
+	for {
+		b.drainQueue(ctx)
+
+		select {
+		case <-ctx.Done():
+			b.logger.Info("delivery worker stopped")
+			return
+		case <-b.deliveryWake:
+		case <-time.After(deliveryIdleInterval):
+		}
+	}
+}
+
+// Drain lets the delivery worker post the due messages of the send queue
+// and waits for pending Telegram calls, then stops the worker. Messages left
+// when ctx ends stay queued and are delivered after a restart. It returns
+// false in that case.
+func (b *Bot) Drain(ctx context.Context) bool {
+	defer b.stopDelivery()
+
+	b.wakeDelivery()
+	for {
+		items, err := b.db.GetDueQueuedMessages(ctx, b.accountBotID(), 1)
+		if err == nil && len(items) == 0 && b.sends.idle() {
+			return true
+		}
+		if !sleepCtx(ctx, drainPollInterval) {
+			count, _ := b.db.CountQueuedMessages(context.Background())
+			b.logger.Warn("shutdown before the send queue was drained, messages stay queued", "queued", count)
+			return false
+		}
+	}
+}
+
+// drainQueue delivers all due queued messages in order
+func (b *Bot) drainQueue(ctx context.Context) {
+	for ctx.Err() == nil {
+		items, err := b.db.GetDueQueuedMessages(ctx, b.accountBotID(), deliveryBatchSize)
+		if err != nil {
+			b.logger.Error("failed to load send queue", "error", err)
+			return
+		}
+		if len(items) == 0 {
+			return
+		}
+
+		for _, item := range items {
+			if !b.deliverQueued(ctx, item) {
+				return
+			}
+		}
+	}
+}
+
+// deliverQueued sends a single queued message, retrying the same item while
+// Telegram is unreachable so that ordering is preserved.
+// Returns false if draining should stop.
+func (b *Bot) deliverQueued(ctx context.Context, item *appmodels.QueuedMessage) bool {
+	for {
+		err := b.deliverMessage(ctx, item.MessageID)
+		if err == nil {
+			if err := b.db.DeleteQueuedMessage(ctx, item.ID); err != nil {
+				b.logger.Error("failed to remove delivered message from queue", "error", err)
+			}
+			if err := b.db.ClearDeliveryFailure(ctx, item.MessageID); err != nil {
+				b.logger.Error("failed to clear delivery failure", "error", err)
+			}
+			return true
+		}
+
+		var tooMany *bot.TooManyRequestsError
+		var hold *quietHoldError
+		switch {
+		case ctx.Err() != nil:
+			return false
+		case errors.As(err, &hold):
+			// Not a failure: the message waits for the end of quiet hours
+			if err := b.db.RescheduleQueuedMessage(ctx, item.ID, item.Attempts, hold.until, ""); err != nil {
+				b.logger.Error("failed to reschedule queued message", "error", err)
+			}
+			return true
+		case errors.As(err, &tooMany):
+			b.logger.Warn("telegram rate limit hit, pausing delivery", "retry_after", tooMany.RetryAfter)
+			if !sleepCtx(ctx, time.Duration(tooMany.RetryAfter)*time.Second) {
+				return false
+			}
+		case errors.Is(err, errTelegramUnavailable):
+			b.logger.Warn("telegram unavailable, buffering messages", "error", err)
+			if !b.waitForTelegram(ctx) {
+				return false
+			}
+		default:
+			b.rescheduleQueued(ctx, item, err)
+			return true
+		}
+	}
+}
+
+// rescheduleQueued postpones a message after a non-transient send failure
+func (b *Bot) rescheduleQueued(ctx context.Context, item *appmodels.QueuedMessage, sendErr error) {
+	attempts := item.Attempts + 1
+	if attempts >= deliveryMaxAttempts {
+		b.deadLetter(ctx, item, sendErr)
+		return
+	}
+
+	delay := time.Duration(1<<attempts) * time.Second
+	b.logger.Warn("message delivery failed, rescheduling", "message_id", item.MessageID, "attempts", attempts, "delay", delay, "error", sendErr)
+	if err := b.db.RescheduleQueuedMessage(ctx, item.ID, attempts, time.Now().Add(delay), sendErr.Error()); err != nil {
+		b.logger.Error("failed to reschedule queued message", "error", err)
+	}
+}
+
+// deadLetter marks a message the queue gave up on as undelivered. It is
+// queued again after a growing delay up to deadLetterMaxRetries times, then
+// stays undelivered until /redeliver.
+func (b *Bot) deadLetter(ctx context.Context, item *appmodels.QueuedMessage, sendErr error) {
+	// The message stays queued and is given up on again in the next pass
+	if err := b.db.MarkDeliveryFailed(ctx, item.MessageID, sendErr.Error()); err != nil {
+		b.logger.Error("failed to mark delivery failed", "error", err)
+		return
+	}
+
+	msg, err := b.db.GetMessageByID(ctx, item.MessageID)
+	if err == nil && msg.DeliveryRetries <= deadLetterMaxRetries {
+		delay := deadLetterRetryBase << (msg.DeliveryRetries - 1)
+		b.logger.Warn("message delivery failed, redelivering later", "message_id", item.MessageID, "retry", msg.DeliveryRetries, "delay", delay, "error", sendErr)
+		if err := b.db.ScheduleRedelivery(ctx, item.MessageID, time.Now().Add(delay), sendErr.Error()); err != nil {
+			b.logger.Error("failed to schedule redelivery", "error", err)
+		}
+		return
+	}
+
+	b.logger.Error("giving up on message delivery", "message_id", item.MessageID, "error", sendErr)
+	if err := b.db.DeleteQueuedMessage(ctx, item.ID); err != nil {
+		b.logger.Error("failed to remove message from queue", "error", err)
+	}
+}
+
+// deliverMessage formats a stored email and sends it to its topic
+func (b *Bot) deliverMessage(ctx context.Context, messageID int64) error {
+	msg, err := b.db.GetMessageByID(ctx, messageID)
+	if err != nil {
+		return err
+	}
+
+	account, err := b.db.GetAccountByID(ctx, msg.AccountID)
+	if err != nil {
+		return err
+	}
+
+	settings, err := b.db.GetChatSettings(ctx, account.ChatID)
+	if err != nil {
+		return err
+	}
+	ctx = i18n.WithLang(ctx, settings.Language)
+
+	parseMode := models.ParseMode(settings.ParseMode)
+	codes := decodeCodes(msg.DetectedCodes)
+
+	// Emails denied by /filter rules are skipped or go to the spam topic
+	filtered := b.isFiltered(ctx, account, msg)
+	if filtered && account.SpamTopicID == 0 {
+		b.logger.Info("email filtered out", "account_id", account.ID, "message_id", msg.ID)
+		return nil
+	}
+
+	// Spam and newsletters are dropped, held for the daily digest or moved
+	// to the newsletters topic; emails with codes are always delivered
+	bulk := !filtered && msg.Bulk != "" && len(codes) == 0
+	if bulk {
+		switch account.BulkMode {
+		case appmodels.BulkDrop:
+			b.logger.Info("bulk email dropped", "account_id", account.ID, "message_id", msg.ID, "bulk", msg.Bulk)
+			return nil
+		case appmodels.BulkDigest:
+			return b.holdForDigest(ctx, account, msg)
+		}
+	}
+	bulkTopic := bulk && account.BulkMode == appmodels.BulkTopic && account.BulkTopicID != 0
+
+	// In digest mode only emails with codes or matching /priority are posted
+	// right away, the rest wait for the daily or weekly digest
+	priority := isPriorityEmail(account, msg, codes)
+	if !filtered && !bulkTopic && account.DigestMode != appmodels.DigestOff && !priority {
+		return b.holdForDigest(ctx, account, msg)
+	}
+
+	// During quiet hours the same emails are posted silently or held until
+	// the hours end
+	quietUntil, quiet := account.QuietUntil(time.Now())
+	quiet = quiet && !priority
+	if quiet && !filtered && !bulkTopic && account.QuietMode == appmodels.QuietHold {
+		b.logger.Info("email held for quiet hours", "account_id", account.ID, "message_id", msg.ID, "until", quietUntil)
+		return &quietHoldError{until: quietUntil}
+	}
+
+	// Noisy senders go to an hourly digest instead
+	if !filtered && !bulkTopic && b.throttleSender(ctx, account, msg, codes) {
+		return nil
+	}
+	avatarURL, domainEmoji := b.senderPicture(ctx, account, msg)
+	text, truncated := b.formatter.FormatEmail(msg, codes, formatter.FormatOptions{
+		ParseMode:   parseMode,
+		CustomEmoji: settings.CustomEmojiMap(),
+		CodeReused:  b.isCodeReused(ctx, account.ChatID, msg, codes),
+		Profile:     account.FormatProfile,
+		Template:    account.MessageTemplate,
+		DomainEmoji: domainEmoji,
+		Language:    i18n.Lang(ctx),
+	})
+	keyboard := emailKeyboard(ctx, account, msg, codes, truncated)
+
+	if filtered {
+		return b.deliverSilently(ctx, account, msg, account.SpamTopicID, text, keyboard, parseMode)
+	}
+	if bulkTopic {
+		return b.deliverSilently(ctx, account, msg, account.BulkTopicID, text, keyboard, parseMode)
+	}
+
+	opts := messageOptions{
+		ParseMode:           parseMode,
+		DisableNotification: quiet || account.Silent && !priority,
+		ProtectContent:      account.ProtectContent,
+	}
+
+	// Merge repeated alerts into one message if collapsing is enabled. Codes
+	// and priority emails always get a new message: edits don't notify.
+	subjectKey := ""
+	if len(codes) == 0 && !priority {
+		subjectKey = collapseKey(msg.Subject)
+	}
+	if group := b.activeCollapseGroup(ctx, account, subjectKey); group != nil {
+		done, err := b.deliverCollapsed(ctx, group, account, msg, text, keyboard, parseMode)
+		if err != nil {
+			return err
+		}
+		if done {
+			b.deliverMirrors(ctx, account, text, opts)
+			if account.UnreadBadge {
+				b.wakeUnreadBadges()
+			}
+			return nil
+		}
+	}
+
+	// Follow-ups of a conversation reply to its earlier email; mirrors get
+	// no reply, the message is in another chat
+	threadOpts := opts
+	threadOpts.ReplyTo = b.threadParent(ctx, account, msg)
+	threadOpts.Preview = avatarURL
+
+	tgMsg, err := b.sendMessageWithKeyboard(ctx, account.ChatID, account.TopicID, text, keyboard, threadOpts)
+	if err != nil {
+		if isTelegramUnavailable(err) {
+			return errors.Join(errTelegramUnavailable, err)
+		}
+		return err
+	}
+
+	if err := b.db.UpdateMessageTelegramMsgID(ctx, msg.ID, tgMsg.ID); err != nil {
+		b.logger.Error("failed to update telegram msg id", "error", err)
+	}
+	b.observeProbe(account.ID, msg.Subject, true)
+	b.startCollapseGroup(ctx, account, subjectKey, tgMsg.ID)
+	b.pinCode(ctx, account, msg, codes, tgMsg.ID)
+	b.deliverImages(ctx, account, msg, account.TopicID, tgMsg.ID, opts)
+	b.deliverMirrors(ctx, account, text, opts)
+	if account.UnreadBadge {
+		b.wakeUnreadBadges()
+	}
+
+	b.logger.Info("email sent to telegram",
+		"account_id", account.ID,
+		"telegram_msg_id", tgMsg.ID,
+		"codes_detected", len(codes),
+	)
+	return nil
+}
+
+// isFiltered reports whether the filter rules of the account deny the email
+func (b *Bot) isFiltered(ctx context.Context, account *appmodels.EmailAccount, msg *appmodels.EmailMessage) bool {
+	filters, err := b.db.GetFilters(ctx, account.ID)
+	if err != nil {
+		b.logger.Error("failed to get filters", "error", err, "account_id", account.ID)
+		return false
+	}
+	return appmodels.FilterEmail(filters, msg.FromAddr, msg.Subject)
+}
+
+// deliverSilently sends a filtered or bulk email silently to a side topic of
+// the account (spam or newsletters), without collapsing or digests
+func (b *Bot) deliverSilently(ctx context.Context, account *appmodels.EmailAccount, msg *appmodels.EmailMessage, topicID int, text string, keyboard *models.InlineKeyboardMarkup, parseMode models.ParseMode) error {
+	tgMsg, err := b.sendMessageWithKeyboard(ctx, account.ChatID, topicID, text, keyboard, messageOptions{
+		ParseMode:           parseMode,
+		DisableNotification: true,
+		ProtectContent:      account.ProtectContent,
+	})
+	if err != nil {
+		if isTelegramUnavailable(err) {
+			return errors.Join(errTelegramUnavailable, err)
+		}
+		return err
+	}
+
+	if err := b.db.UpdateMessageTelegramMsgID(ctx, msg.ID, tgMsg.ID); err != nil {
+		b.logger.Error("failed to update telegram msg id", "error", err)
+	}
+
+	b.logger.Info("email sent to side topic",
+		"account_id", account.ID,
+		"topic_id", topicID,
+		"telegram_msg_id", tgMsg.ID,
+	)
+	return nil
+}
+
+// isCodeReused reports whether any code of the email appeared in another
+// email of the same chat within the reuse window (possible phishing replay)
+func (b *Bot) isCodeReused(ctx context.Context, chatID int64, msg *appmodels.EmailMessage, codes []appmodels.DetectedCode) bool {
+	if b.config.CodeReuseWindow <= 0 || len(codes) == 0 {
+		return false
+	}
+
+	values := make([]string, 0, len(codes))
+	for _, code := range codes {
+		values = append(values, code.Value)
+	}
+
+	since := msg.CreatedAt.Add(-b.config.CodeReuseWindow)
+	count, err := b.db.CountCodeReuse(ctx, chatID, msg.ID, values, since)
+	if err != nil {
+		b.logger.Error("failed to check code reuse", "error", err)
+		return false
+	}
+	if count > 0 {
+		b.logger.Warn("code reused across emails", "chat_id", chatID, "message_id", msg.ID, "other_messages", count)
+	}
+	return count > 0
+}
+
+// isPriorityEmail reports whether an email must notify even in silent topics:
+// it contains codes or matches the account's priority pattern
+func isPriorityEmail(account *appmodels.EmailAccount, msg *appmodels.EmailMessage, codes []appmodels.DetectedCode) bool {
+	if len(codes) > 0 {
+		return true
+	}
+	if account.PriorityPattern == "" {
+		return false
+	}
+
+	re, err := regexp.Compile(account.PriorityPattern)
+	if err != nil {
+		return false
+	}
+	return re.MatchString(msg.Subject) || re.MatchString(msg.FromAddr) || re.MatchString(msg.FromName)
+}
+
+// waitForTelegram probes the Telegram API with backoff until it responds.
+// Returns false if ctx was cancelled first.
+func (b *Bot) waitForTelegram(ctx context.Context) bool {
+	interval := b.config.TelegramProbeInterval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+
+	for {
+		if !sleepCtx(ctx, interval) {
+			return false
+		}
+
+		probeCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		_, err := b.bot.GetMe(probeCtx)
+		cancel()
+		if err == nil {
+			b.logger.Info("telegram api is reachable again, resuming delivery")
+			return true
+		}
+
+		b.logger.Debug("telegram probe failed", "error", err, "next_probe", interval)
+		interval *= 2
+		if interval > probeMaxInterval {
+			interval = probeMaxInterval
+		}
+	}
+}
+
+// isTelegramUnavailable reports whether err means the API was not reached,
+// as opposed to Telegram rejecting the request
+func isTelegramUnavailable(err error) bool {
+	if bot.IsTooManyRequestsError(err) {
+		return false
+	}
+	for _, apiErr := range []error{
+		bot.ErrorForbidden,
+		bot.ErrorBadRequest,
+		bot.ErrorUnauthorized,
+		bot.ErrorNotFound,
+		bot.ErrorConflict,
+	} {
+		if errors.Is(err, apiErr) {
+			return false
+		}
+	}
+	return true
+}
+
+// sleepCtx sleeps for d or until ctx is done. Returns false if ctx is done.
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
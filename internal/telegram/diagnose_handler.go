@@ -0,0 +1,111 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/mixelka/emailresend/internal/email"
+	"github.com/mixelka/emailresend/internal/i18n"
+)
+
+// capabilityEffects explains what is degraded without each diagnosed extension
+var capabilityEffects = map[string]string{
+	"IDLE":      "без него новые письма можно получать только периодическим опросом",
+	"MOVE":      "без него перемещение писем выполняется копированием и удалением",
+	"CONDSTORE": "без него каждая проверка запрашивает состояние писем целиком, а не только изменения",
+	"QRESYNC":   "без него удалённые в ящике письма ищутся периодической проверкой, а не по счётчику изменений",
+	"QUOTA":     "без него нельзя узнать заполненность ящика",
+	"UIDPLUS":   "без него «Удалить» стирает из ящика все письма с пометкой на удаление, а не только выбранное",
+}
+
+// securityNames describe the connection security of IMAP servers
+var securityNames = map[string]string{
+	email.SecurityTLS:      "TLS",
+	email.SecuritySTARTTLS: "STARTTLS",
+	email.SecurityPlain:    "⚠️ нет, пароль и письма передаются открытым текстом",
+}
+
+// handleDiagnose handles /diagnose command
+func (b *Bot) handleDiagnose(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	account, ok := b.getTopicAccount(ctx, msg)
+	if !ok {
+		return
+	}
+
+	progress, err := b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+		i18n.Tf(ctx, "⏳ Проверяю сервер <b>%s</b>...", html.EscapeString(account.IMAPServer)))
+	if err != nil {
+		b.logger.Error("failed to send message", "error", err)
+		return
+	}
+
+	diag, err := b.emailManager.Diagnose(ctx, account)
+
+	var sb strings.Builder
+	sb.WriteString(i18n.Tf(ctx, "🩺 <b>Диагностика %s</b>\n", html.EscapeString(account.Email)))
+	sb.WriteString(i18n.Tf(ctx, "Сервер: <code>%s</code>\n", html.EscapeString(diag.Server)))
+	if name, ok := securityNames[diag.Security]; ok {
+		sb.WriteString(i18n.Tf(ctx, "Шифрование: %s\n", i18n.T(ctx, name)))
+	}
+
+	if diag.Capabilities != nil {
+		sb.WriteString(i18n.T(ctx, "\n<b>Возможности сервера:</b>\n"))
+		for _, capability := range email.DiagnosedCapabilities {
+			if diag.Supports(capability) {
+				fmt.Fprintf(&sb, "✅ %s\n", capability)
+			} else {
+				fmt.Fprintf(&sb, "❌ %s — %s\n", capability, i18n.T(ctx, capabilityEffects[capability]))
+			}
+		}
+
+		sb.WriteString(i18n.T(ctx, "\n<b>Аутентификация:</b> "))
+		if len(diag.AuthMechanisms) > 0 {
+			sb.WriteString(html.EscapeString(strings.Join(diag.AuthMechanisms, ", ")))
+		} else {
+			sb.WriteString(i18n.T(ctx, "только LOGIN"))
+		}
+		sb.WriteString("\n")
+		if diag.LoginDisabled {
+			sb.WriteString(i18n.T(ctx, "⚠️ Сервер запрещает LOGIN, который использует бот\n"))
+		}
+	}
+
+	sb.WriteString(i18n.T(ctx, "\n<b>Задержки:</b>\n"))
+	latencies := []struct {
+		name string
+		d    time.Duration
+	}{
+		{"TCP-соединение", diag.TCPConnect},
+		{"TLS-рукопожатие", diag.TLSHandshake},
+		{"Приветствие сервера", diag.Greeting},
+		{"Вход", diag.Login},
+		{"Открытие INBOX", diag.Select},
+		{"NOOP", diag.Noop},
+	}
+	for _, l := range latencies {
+		if l.d == 0 {
+			continue
+		}
+		fmt.Fprintf(&sb, "%s: %s\n", i18n.T(ctx, l.name), l.d.Round(time.Millisecond))
+	}
+	if diag.Select != 0 {
+		sb.WriteString(i18n.Tf(ctx, "\nПисем во входящих: %d\n", diag.Messages))
+	}
+
+	if err != nil {
+		b.logger.Warn("diagnosis failed", "error", err, "account_id", account.ID)
+		sb.WriteString(i18n.Tf(ctx, "\n❌ Ошибка: %s", html.EscapeString(err.Error())))
+	}
+
+	if err := b.editMessageText(ctx, msg.Chat.ID, progress.ID, sb.String()); err != nil {
+		b.logger.Error("failed to edit message", "error", err)
+	}
+}
@@ -0,0 +1,124 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mixelka/emailresend/internal/i18n"
+	appmodels "github.com/mixelka/emailresend/pkg/models"
+)
+
+const (
+	// digestInterval is how often due digests are checked
+	digestInterval = time.Minute
+	// digestMaxItems is the number of emails listed in a digest
+	digestMaxItems = 20
+	// digestWeekday is the day weekly digests are sent on
+	digestWeekday = time.Monday
+)
+
+// holdForDigest keeps an email out of the topic until the next digest
+func (b *Bot) holdForDigest(ctx context.Context, account *appmodels.EmailAccount, msg *appmodels.EmailMessage) error {
+	if err := b.db.AddDigestItem(ctx, msg); err != nil {
+		return err
+	}
+	b.logger.Info("email held for digest", "account_id", account.ID, "message_id", msg.ID, "bulk", msg.Bulk)
+	return nil
+}
+
+// lastDigestTimes returns the latest moments daily and weekly digests were
+// due at or before now
+func (b *Bot) lastDigestTimes(now time.Time) (daily, weekly time.Time) {
+	at, err := b.config.DigestTimeOfDay()
+	if err != nil {
+		at = 9 * time.Hour
+	}
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	daily = midnight.Add(at)
+	if daily.After(now) {
+		daily = midnight.AddDate(0, 0, -1).Add(at)
+	}
+	weekly = daily.AddDate(0, 0, -int((daily.Weekday()-digestWeekday+7)%7))
+	return daily, weekly
+}
+
+// digestSchedule describes when the digests of an account are sent
+func (b *Bot) digestSchedule(ctx context.Context, account *appmodels.EmailAccount) string {
+	if account.DigestMode == appmodels.DigestWeekly {
+		return i18n.Tf(ctx, "по понедельникам в %s", b.config.DigestTime)
+	}
+	return i18n.Tf(ctx, "ежедневно в %s", b.config.DigestTime)
+}
+
+// runDigests sends the digests of held emails at DIGEST_TIME, daily or on
+// Mondays for weekly accounts
+func (b *Bot) runDigests(ctx context.Context) {
+	ticker := time.NewTicker(digestInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// Emails held before the last digest time are due; later ones wait
+		// for the next digest
+		daily, weekly := b.lastDigestTimes(time.Now())
+		accounts, err := b.db.GetDueDigestAccounts(ctx, b.accountBotID(), daily, weekly)
+		if err != nil {
+			b.logger.Error("failed to load digests", "error", err)
+			continue
+		}
+
+		for _, accountID := range accounts {
+			if err := b.sendDigest(ctx, accountID, daily, weekly); err != nil {
+				b.logger.Warn("failed to send digest", "error", err, "account_id", accountID)
+				break
+			}
+		}
+	}
+}
+
+// sendDigest posts the list of emails held for an account before its due
+// time, with buttons to open them
+func (b *Bot) sendDigest(ctx context.Context, accountID int64, daily, weekly time.Time) error {
+	account, err := b.db.GetAccountByID(ctx, accountID)
+	if err != nil {
+		return err
+	}
+	ctx = b.chatContext(ctx, account.ChatID)
+
+	due, title := daily, i18n.T(ctx, "Сводка за сутки")
+	if account.DigestMode == appmodels.DigestWeekly {
+		due, title = weekly, i18n.T(ctx, "Сводка за неделю")
+	}
+
+	messages, total, err := b.db.GetDigestMessages(ctx, accountID, due, digestMaxItems)
+	if err != nil {
+		return err
+	}
+
+	if len(messages) > 0 {
+		text := i18n.Tf(ctx, "📰 <b>%s: %d писем</b>\n\n", title, total)
+		ids := make([]int64, len(messages))
+		for i, m := range messages {
+			text += fmt.Sprintf("%d. %s\n   %s\n", i+1, emailListSubject(ctx, account.ChatID, m), emailListSender(m))
+			ids[i] = m.ID
+		}
+		if total > len(messages) {
+			text += i18n.Tf(ctx, "… и ещё %d, все письма — в /history\n", total-len(messages))
+		}
+
+		if _, err := b.sendMessageWithKeyboard(ctx, account.ChatID, account.TopicID, text, openEmailKeyboard(1, ids), messageOptions{
+			DisableNotification: true,
+			ProtectContent:      account.ProtectContent,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return b.db.DeleteDigestItems(ctx, accountID, due)
+}
@@ -0,0 +1,370 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/mixelka/emailresend/internal/email"
+	"github.com/mixelka/emailresend/internal/formatter"
+	"github.com/mixelka/emailresend/internal/i18n"
+	"github.com/mixelka/emailresend/internal/mailauth"
+	"github.com/mixelka/emailresend/internal/parser"
+	appmodels "github.com/mixelka/emailresend/pkg/models"
+)
+
+// dryRunMaxFileSize limits the size of .eml files checked by /dryrun
+const dryRunMaxFileSize = 10 << 20
+
+// dryRunUsage explains the /dryrun command
+const dryRunUsage = "Отправьте письмо целиком с заголовками после команды <code>/dryrun</code>, " +
+	"файл .eml с подписью <code>/dryrun</code> или ответьте командой на сообщение с файлом.\n\n" +
+	"Бот разберёт письмо, как будто оно пришло на почту этого топика, и покажет, что было бы опубликовано и почему. Ничего не сохраняется."
+
+// matchDryRun matches documents sent with a /dryrun caption
+func (b *Bot) matchDryRun(update *models.Update) bool {
+	if update.Message == nil || update.Message.Document == nil {
+		return false
+	}
+	cmd, ok := b.parseCommand(update.Message, update.Message.Caption)
+	return ok && cmd == "dryrun"
+}
+
+// handleDryRun handles /dryrun command: it runs a raw email through parsing,
+// code detection, filters and formatting for the topic's account without
+// storing or posting it
+// Usage: /dryrun <raw email>, /dryrun as a caption of or a reply to an .eml file
+func (b *Bot) handleDryRun(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	account, ok := b.getTopicAccount(ctx, msg)
+	if !ok {
+		return
+	}
+
+	raw, ok := b.dryRunInput(ctx, msg)
+	if !ok {
+		return
+	}
+
+	rawEmail := email.ParseMessage(raw, b.logger)
+	if rawEmail.From.Address == "" && rawEmail.Subject == "" && rawEmail.BodyText == "" && rawEmail.BodyHTML == "" {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.T(ctx, "Не удалось разобрать письмо: нет ни заголовков, ни текста\n\n")+i18n.T(ctx, dryRunUsage))
+		return
+	}
+
+	settings, err := b.db.GetChatSettings(ctx, account.ChatID)
+	if err != nil {
+		b.logger.Error("failed to get chat settings", "error", err)
+		settings = appmodels.DefaultChatSettings(account.ChatID)
+	}
+
+	bodyText := b.renderBody(ctx, account, rawEmail)
+	codes, extraction := b.detectCodes(ctx, account, parser.ExtractInput{
+		FromAddr: rawEmail.From.Address,
+		FromName: rawEmail.From.Name,
+		Subject:  rawEmail.Subject,
+		Text:     bodyText,
+		HTML:     rawEmail.BodyHTML,
+	})
+
+	// Pasted emails often lack a Date header
+	if rawEmail.Date.IsZero() {
+		rawEmail.Date = time.Now()
+	}
+
+	bulk := parser.DetectBulk(raw)
+	auth := b.checkSender(ctx, account, raw, rawEmail.From.Address)
+	codesJSON, _ := json.Marshal(codes)
+	attachmentsJSON, _ := json.Marshal(rawEmail.Attachments)
+	emailMsg := &appmodels.EmailMessage{
+		AccountID:     account.ID,
+		MessageID:     rawEmail.MessageID,
+		FromAddr:      rawEmail.From.Address,
+		FromName:      rawEmail.From.Name,
+		Subject:       rawEmail.Subject,
+		BodyText:      bodyText,
+		BodyHTML:      rawEmail.BodyHTML,
+		ReceivedAt:    rawEmail.Date,
+		Size:          rawEmail.Size,
+		DetectedCodes: string(codesJSON),
+		Attachments:   string(attachmentsJSON),
+		ParserVersion: parser.Version,
+		Extracted:     encodeExtraction(extraction),
+		ReplyTo:       rawEmail.ReplyTo,
+		References:    rawEmail.ThreadReferences(),
+		ContentHash:   contentHash(rawEmail),
+		Bulk:          bulk.Class,
+		Unsubscribe:   encodeUnsubscribe(parser.ParseListUnsubscribe(raw)),
+		Auth:          auth.Verdict,
+		CreatedAt:     time.Now(),
+	}
+
+	parseMode := models.ParseMode(settings.ParseMode)
+	avatarURL, domainEmoji := b.senderPicture(ctx, account, emailMsg)
+	text, truncated := b.formatter.FormatEmail(emailMsg, codes, formatter.FormatOptions{
+		ParseMode:   parseMode,
+		CustomEmoji: settings.CustomEmojiMap(),
+		CodeReused:  b.isCodeReused(ctx, account.ChatID, emailMsg, codes),
+		Profile:     account.FormatProfile,
+		Template:    account.MessageTemplate,
+		DomainEmoji: domainEmoji,
+		Language:    i18n.Lang(ctx),
+	})
+	keyboard := emailKeyboard(ctx, account, emailMsg, codes, truncated)
+
+	var sb strings.Builder
+	sb.WriteString(i18n.T(ctx, "🧪 <b>Пробный разбор письма</b>\n\n"))
+	writeDryRunParsing(ctx, &sb, rawEmail, bodyText)
+	writeDryRunAuth(ctx, &sb, auth)
+	writeDryRunDetection(ctx, &sb, codes, extraction)
+	b.writeDryRunDelivery(ctx, &sb, account, emailMsg, rawEmail, codes, bulk)
+	sb.WriteString(i18n.Tf(ctx, "\n<b>Оформление:</b> профиль %s, разметка %s\n",
+		formatter.GetProfile(account.FormatProfile).Name, settings.ParseMode))
+	if account.MessageTemplate != "" {
+		sb.WriteString(i18n.T(ctx, "Шаблон: свой (/template)\n"))
+	}
+	if buttons := keyboardButtons(keyboard); buttons != "" {
+		sb.WriteString(i18n.T(ctx, "Кнопки: ") + html.EscapeString(buttons) + "\n")
+	}
+	sb.WriteString(i18n.T(ctx, "\nСообщение ниже — так письмо выглядело бы в топике (без кнопок)."))
+	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, sb.String())
+
+	if _, err := b.sendMessageWithKeyboard(ctx, msg.Chat.ID, msg.MessageThreadID, text, nil, messageOptions{ParseMode: parseMode, Preview: avatarURL}); err != nil {
+		b.logger.Warn("failed to send dry run preview", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+			i18n.Tf(ctx, "Telegram не принял сообщение письма: <code>%s</code>", html.EscapeString(err.Error())))
+	}
+}
+
+// dryRunInput returns the raw email of a /dryrun message: an attached or
+// replied-to file, or the text after the command
+func (b *Bot) dryRunInput(ctx context.Context, msg *models.Message) ([]byte, bool) {
+	doc := msg.Document
+	if doc == nil && msg.ReplyToMessage != nil {
+		doc = msg.ReplyToMessage.Document
+	}
+
+	if doc != nil {
+		data, err := b.downloadFile(ctx, doc.FileID, dryRunMaxFileSize)
+		if err != nil {
+			b.logger.Error("failed to download dry run file", "error", err)
+			b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.Tf(ctx, "Ошибка загрузки файла: %v", err))
+			return nil, false
+		}
+		return data, true
+	}
+
+	raw := strings.TrimLeft(msg.Text[len(strings.Fields(msg.Text)[0]):], " \t\r\n")
+	if raw == "" {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, dryRunUsage)
+		return nil, false
+	}
+	return []byte(raw), true
+}
+
+// writeDryRunParsing describes what was parsed from the email
+func writeDryRunParsing(ctx context.Context, sb *strings.Builder, rawEmail *email.RawEmail, bodyText string) {
+	sb.WriteString(i18n.T(ctx, "<b>Разбор:</b>\n"))
+	from := rawEmail.From.Address
+	if rawEmail.From.Name != "" {
+		from = fmt.Sprintf("%s <%s>", rawEmail.From.Name, rawEmail.From.Address)
+	}
+	sb.WriteString(i18n.Tf(ctx, "От: %s\n", html.EscapeString(orDash(from))))
+	sb.WriteString(i18n.Tf(ctx, "Тема: %s\n", html.EscapeString(orDash(rawEmail.Subject))))
+	if rawEmail.MessageID != "" {
+		sb.WriteString(fmt.Sprintf("Message-ID: <code>%s</code>\n", html.EscapeString(rawEmail.MessageID)))
+	}
+
+	source := i18n.T(ctx, "нет текста")
+	switch {
+	case rawEmail.BodyHTML != "":
+		source = i18n.T(ctx, "из HTML-части")
+	case rawEmail.BodyText != "":
+		source = i18n.T(ctx, "из текстовой части")
+	}
+	sb.WriteString(i18n.Tf(ctx, "Текст: %d симв., %s", len([]rune(bodyText)), source))
+	if len(rawEmail.Attachments) > 0 {
+		sb.WriteString(i18n.Tf(ctx, ", вложений: %d", len(rawEmail.Attachments)))
+	}
+	sb.WriteString("\n")
+}
+
+// writeDryRunAuth describes the DKIM/SPF verdict on the sender
+func writeDryRunAuth(ctx context.Context, sb *strings.Builder, auth mailauth.Result) {
+	switch auth.Verdict {
+	case appmodels.AuthPass:
+		sb.WriteString(i18n.T(ctx, "Отправитель: ✅ подтверждён"))
+	case appmodels.AuthFail:
+		sb.WriteString(i18n.T(ctx, "Отправитель: ⚠️ не подтверждён"))
+	default:
+		sb.WriteString(i18n.T(ctx, "Отправитель: нет подписи DKIM и результатов SPF\n"))
+		return
+	}
+	sb.WriteString(" (" + html.EscapeString(strings.Join(auth.Reasons, "; ")) + ")\n")
+}
+
+// writeDryRunDetection describes the codes and data detected in the email
+func writeDryRunDetection(ctx context.Context, sb *strings.Builder, codes []appmodels.DetectedCode, extraction *appmodels.Extraction) {
+	sb.WriteString(i18n.T(ctx, "\n<b>Распознано:</b>\n"))
+	source := i18n.T(ctx, "общие шаблоны кодов")
+	if extraction != nil && extraction.Extractor != "" && len(extraction.Codes) > 0 {
+		source = i18n.T(ctx, "экстрактор ") + extraction.Extractor
+	}
+	if len(codes) == 0 {
+		sb.WriteString(i18n.T(ctx, "Кодов нет\n"))
+	}
+	for _, code := range codes {
+		from := source
+		if code.Type == parser.CodeTypeCustom {
+			from = i18n.T(ctx, "свой шаблон (/codes)")
+		}
+		sb.WriteString(i18n.Tf(ctx, "Код <code>%s</code> (%s) — %s\n", html.EscapeString(code.Value), code.Type, from))
+	}
+
+	if extraction == nil {
+		return
+	}
+	if extraction.Extractor != "" && len(extraction.Codes) == 0 {
+		sb.WriteString(i18n.Tf(ctx, "Экстрактор %s: %d полей, %d ссылок\n", extraction.Extractor, len(extraction.Fields), len(extraction.Links)))
+	}
+	if extraction.Order != nil {
+		sb.WriteString(i18n.Tf(ctx, "Заказ <code>%s</code> — будет в /search и отчётах\n", html.EscapeString(orDash(extraction.Order.Number))))
+	}
+	for _, t := range extraction.Tracking {
+		sb.WriteString(i18n.Tf(ctx, "Трек-номер %s <code>%s</code>\n", html.EscapeString(t.Carrier), html.EscapeString(t.Number)))
+	}
+	for _, link := range extraction.Actions {
+		sb.WriteString(i18n.Tf(ctx, "Кнопка-ссылка %s: %s\n", link.Kind, html.EscapeString(orDash(link.Title))))
+	}
+}
+
+// writeDryRunDelivery explains where the email would be posted and why,
+// following the checks of onNewEmail and deliverMessage
+func (b *Bot) writeDryRunDelivery(ctx context.Context, sb *strings.Builder, account *appmodels.EmailAccount, msg *appmodels.EmailMessage, rawEmail *email.RawEmail, codes []appmodels.DetectedCode, bulk parser.BulkResult) {
+	sb.WriteString(i18n.T(ctx, "\n<b>Доставка:</b>\n"))
+	if !account.IsActive {
+		sb.WriteString(i18n.T(ctx, "⏸ Пересылка почты приостановлена (/resume)\n"))
+	}
+
+	if id := b.findDuplicate(ctx, account.ID, msg.ContentHash); id != 0 {
+		sb.WriteString(i18n.Tf(ctx, "⛔ Не будет опубликовано: такое же письмо уже пришло недавно (#%d)\n", id))
+		return
+	}
+	if tgMsgID := b.findPosted(ctx, account, rawEmail.MessageID); tgMsgID != 0 {
+		if hasMessageLinks(account.ChatID) {
+			sb.WriteString(i18n.Tf(ctx, `⛔ Не будет опубликовано: письмо с этим Message-ID <a href="%s">уже было в чате</a>`+"\n",
+				messageLink(account.ChatID, tgMsgID)))
+		} else {
+			sb.WriteString(i18n.T(ctx, "⛔ Не будет опубликовано: письмо с этим Message-ID уже было в чате\n"))
+		}
+		return
+	}
+
+	filters, err := b.db.GetFilters(ctx, account.ID)
+	if err != nil {
+		b.logger.Error("failed to get filters", "error", err, "account_id", account.ID)
+	}
+	rule, denied := appmodels.MatchFilter(filters, msg.FromAddr, msg.Subject)
+	switch {
+	case rule != nil:
+		sb.WriteString(i18n.Tf(ctx, "Фильтр <code>%d</code>: %s %s <code>%s</code>\n", rule.ID, rule.Action, rule.Field, html.EscapeString(rule.Pattern)))
+	case denied:
+		sb.WriteString(i18n.T(ctx, "Фильтр: письмо не подходит ни под одно правило allow\n"))
+	case len(filters) > 0:
+		sb.WriteString(i18n.T(ctx, "Фильтр: ни одно правило не подошло\n"))
+	}
+	if denied {
+		if account.SpamTopicID == 0 {
+			sb.WriteString(i18n.T(ctx, "⛔ Не будет опубликовано: отфильтровано\n"))
+		} else {
+			sb.WriteString(i18n.Tf(ctx, "🗂 Будет опубликовано без звука в топике отфильтрованных писем <code>%d</code>\n", account.SpamTopicID))
+		}
+		return
+	}
+
+	if bulk.Class != "" {
+		class := i18n.T(ctx, "рассылка")
+		if bulk.Class == appmodels.BulkSpam {
+			class = i18n.T(ctx, "спам")
+		}
+		sb.WriteString(i18n.Tf(ctx, "Заголовки: %s (%s)\n", class, html.EscapeString(strings.Join(bulk.Reasons, ", "))))
+		switch {
+		case len(codes) > 0:
+		case account.BulkMode == appmodels.BulkDrop:
+			sb.WriteString(i18n.T(ctx, "⛔ Не будет опубликовано: спам и рассылки отключены (/bulk)\n"))
+			return
+		case account.BulkMode == appmodels.BulkDigest:
+			sb.WriteString(i18n.Tf(ctx, "📰 Попадёт в сводку, она приходит %s (/bulk)\n", b.digestSchedule(ctx, account)))
+			return
+		case account.BulkMode == appmodels.BulkTopic && account.BulkTopicID != 0:
+			sb.WriteString(i18n.Tf(ctx, "🗂 Будет опубликовано без звука в топике рассылок <code>%d</code>\n", account.BulkTopicID))
+			return
+		}
+	}
+
+	priority := isPriorityEmail(account, msg, codes)
+	if account.DigestMode != appmodels.DigestOff && !priority {
+		sb.WriteString(i18n.Tf(ctx, "📰 Попадёт в сводку, она приходит %s (/digest)\n", b.digestSchedule(ctx, account)))
+		return
+	}
+	quietUntil, quiet := account.QuietUntil(time.Now())
+	quiet = quiet && !priority
+	if quiet && account.QuietMode == appmodels.QuietHold {
+		sb.WriteString(i18n.Tf(ctx, "🌙 Будет отложено до конца тихих часов, %s (/quiet)\n", quietUntil.Format("02.01 15:04")))
+		return
+	}
+	if limit := b.config.SenderHourlyLimit; limit > 0 && msg.FromAddr != "" && !priority {
+		count, err := b.db.CountSenderMessages(ctx, account.ID, msg.FromAddr, msg.CreatedAt.Truncate(time.Hour), math.MaxInt64)
+		if err != nil {
+			b.logger.Error("failed to count sender messages", "error", err)
+		} else if count+1 > limit {
+			sb.WriteString(i18n.Tf(ctx, "📦 Попадёт в часовую сводку: от отправителя уже %d писем за этот час при лимите %d\n", count, limit))
+			return
+		}
+	}
+
+	if b.activeCollapseGroup(ctx, account, collapseKey(msg.Subject)) != nil {
+		sb.WriteString(i18n.T(ctx, "✅ Будет объединено с недавним сообщением с той же темой (/collapse)\n"))
+	} else {
+		sb.WriteString(i18n.T(ctx, "✅ Будет опубликовано в этом топике\n"))
+	}
+	switch {
+	case quiet:
+		sb.WriteString(i18n.T(ctx, "🔕 Без звука: тихие часы (/quiet)\n"))
+	case !account.Silent:
+	case priority:
+		sb.WriteString(i18n.T(ctx, "🔔 Со звуком: в письме есть код или оно подходит под /priority\n"))
+	default:
+		sb.WriteString(i18n.T(ctx, "🔕 Без звука (/silent)\n"))
+	}
+}
+
+// keyboardButtons lists the button labels of a keyboard
+func keyboardButtons(keyboard *models.InlineKeyboardMarkup) string {
+	if keyboard == nil {
+		return ""
+	}
+	var labels []string
+	for _, row := range keyboard.InlineKeyboard {
+		for _, button := range row {
+			labels = append(labels, button.Text)
+		}
+	}
+	return strings.Join(labels, " · ")
+}
+
+// orDash returns s, or a dash if it is empty
+func orDash(s string) string {
+	if s == "" {
+		return "—"
+	}
+	return s
+}
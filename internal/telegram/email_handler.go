@@ -5,22 +5,24 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	tgmodels "github.com/go-telegram/bot/models"
 
 	"github.com/mixelka/emailresend/internal/database"
 	"github.com/mixelka/emailresend/internal/email"
 	"github.com/mixelka/emailresend/internal/formatter"
+	"github.com/mixelka/emailresend/internal/i18n"
+	"github.com/mixelka/emailresend/internal/mailauth"
+	"github.com/mixelka/emailresend/internal/parser"
 	"github.com/mixelka/emailresend/pkg/models"
 )
 
-// SetupEmailCallbacks sets up email message callbacks
-func (b *Bot) SetupEmailCallbacks() {
-	b.emailManager.SetMessageHandler(b.onNewEmail)
-	b.emailManager.SetErrorHandler(b.onEmailError)
-	b.emailManager.SetDecryptFunc(b.DecryptPasswordFunc())
-}
-
-// onNewEmail handles a new email message
-func (b *Bot) onNewEmail(accountID int64, rawEmail *email.RawEmail) {
+// onNewEmail handles a new email message. It returns an error only if the
+// email could not be stored and should be fetched again.
+func (b *Bot) onNewEmail(accountID int64, rawEmail *email.RawEmail) error {
 	ctx := context.Background()
 
 	b.logger.Info("received new email",
@@ -29,30 +31,38 @@ func (b *Bot) onNewEmail(accountID int64, rawEmail *email.RawEmail) {
 		"subject", rawEmail.Subject,
 	)
 
-	// Get account
+	b.observeProbe(accountID, rawEmail.Subject, false)
+
+	// Make sure the account still exists
 	account, err := b.db.GetAccountByID(ctx, accountID)
+	if errors.Is(err, database.ErrNotFound) {
+		b.logger.Warn("email for a removed account, skipping", "account_id", accountID)
+		return nil
+	}
 	if err != nil {
-		b.logger.Error("failed to get account", "error", err, "account_id", accountID)
-		return
+		return fmt.Errorf("failed to get account: %w", err)
 	}
 
-	// Parse HTML to text
-	bodyText := rawEmail.BodyText
-	if rawEmail.BodyHTML != "" {
-		parsed, err := b.htmlParser.Parse(rawEmail.BodyHTML)
-		if err != nil {
-			b.logger.Warn("failed to parse HTML", "error", err)
-		} else {
-			bodyText = parsed
-		}
-	}
+	bodyText := b.renderBody(ctx, account, rawEmail)
 
 	// Detect codes
-	codes := b.codeDetector.DetectCodes(bodyText)
+	codes, extraction := b.detectCodes(ctx, account, parser.ExtractInput{
+		FromAddr: rawEmail.From.Address,
+		FromName: rawEmail.From.Name,
+		Subject:  rawEmail.Subject,
+		Text:     bodyText,
+		HTML:     rawEmail.BodyHTML,
+	})
 	b.logger.Debug("detected codes", "count", len(codes), "codes", codes)
 
+	auth := b.checkSender(ctx, account, rawEmail.Raw, rawEmail.From.Address)
+	if auth.Verdict == models.AuthFail {
+		b.logger.Info("sender not authenticated", "account_id", accountID, "from", rawEmail.From.Address, "reasons", auth.Reasons)
+	}
+
 	// Create message record
 	codesJSON, _ := json.Marshal(codes)
+	attachmentsJSON, _ := json.Marshal(rawEmail.Attachments)
 	emailMsg := &models.EmailMessage{
 		AccountID:     accountID,
 		UID:           rawEmail.UID,
@@ -63,46 +73,328 @@ func (b *Bot) onNewEmail(accountID int64, rawEmail *email.RawEmail) {
 		BodyText:      bodyText,
 		BodyHTML:      rawEmail.BodyHTML,
 		ReceivedAt:    rawEmail.Date,
+		InternalDate:  rawEmail.InternalDate,
+		Size:          rawEmail.Size,
 		DetectedCodes: string(codesJSON),
+		Attachments:   string(attachmentsJSON),
+		ParserVersion: parser.Version,
+		Extracted:     encodeExtraction(extraction),
+		ReplyTo:       rawEmail.ReplyTo,
+		References:    rawEmail.ThreadReferences(),
+		ContentHash:   contentHash(rawEmail),
+		Bulk:          parser.DetectBulk(rawEmail.Raw).Class,
+		Unsubscribe:   encodeUnsubscribe(parser.ParseListUnsubscribe(rawEmail.Raw)),
+		Auth:          auth.Verdict,
+	}
+
+	// Retry storms deliver the same email under new Message-IDs; such copies
+	// are stored but not delivered again
+	emailMsg.DuplicateOf = b.findDuplicate(ctx, accountID, emailMsg.ContentHash)
+
+	// Reconnecting a mailbox fetches its history again; emails already posted
+	// to the chat are stored linked to their post instead of posted twice
+	if emailMsg.DuplicateOf == 0 {
+		emailMsg.TelegramMsgID = b.findPosted(ctx, account, rawEmail.MessageID)
 	}
 
-	// Save to database
-	if err := b.db.CreateMessage(ctx, emailMsg); err != nil {
+	// Save to database, queueing for delivery to Telegram unless the email
+	// was already posted
+	deliver := emailMsg.DuplicateOf == 0 && emailMsg.TelegramMsgID == 0
+	if err := b.db.CreateMessage(ctx, emailMsg, deliver); err != nil {
 		if errors.Is(err, database.ErrAlreadyExists) {
 			// Message already exists, skip
 			b.logger.Debug("message already exists, skipping", "uid", rawEmail.UID)
-			return
+			return nil
 		}
-		b.logger.Error("failed to save message", "error", err)
-		return
+		return fmt.Errorf("failed to save message: %w", err)
 	}
 
-	// Format for Telegram
-	text := b.formatter.FormatEmail(emailMsg, codes)
-	keyboard := formatter.BuildEmailKeyboard(emailMsg.ID, codes, false)
+	// Keep the raw message for later re-parsing and downloads
+	b.archiveRaw(ctx, emailMsg, rawEmail)
 
-	// Send to topic
-	tgMsg, err := b.sendMessageWithKeyboard(ctx, account.ChatID, account.TopicID, text, keyboard)
-	if err != nil {
-		b.logger.Error("failed to send to telegram", "error", err)
-		return
+	if !deliver {
+		b.logger.Info("duplicate email suppressed",
+			"account_id", accountID,
+			"message_id", emailMsg.ID,
+			"duplicate_of", emailMsg.DuplicateOf,
+			"telegram_msg_id", emailMsg.TelegramMsgID,
+		)
+		return nil
 	}
 
-	// Update telegram message ID
-	if err := b.db.UpdateMessageTelegramMsgID(ctx, emailMsg.ID, tgMsg.ID); err != nil {
-		b.logger.Error("failed to update telegram msg id", "error", err)
+	// Index the order for /search and spend reports
+	if extraction != nil && extraction.Order != nil {
+		if err := b.db.SaveOrder(ctx, emailMsg, account.ChatID, extraction.Order); err != nil {
+			b.logger.Error("failed to save order", "error", err)
+		}
 	}
 
-	// Update last UID
-	if err := b.db.UpdateAccountLastUID(ctx, accountID, rawEmail.UID); err != nil {
-		b.logger.Error("failed to update last uid", "error", err)
+	// Index codes for replay detection across accounts of the chat
+	if err := b.db.SaveMessageCodes(ctx, emailMsg, account.ChatID, codes); err != nil {
+		b.logger.Error("failed to save message codes", "error", err)
 	}
 
-	b.logger.Info("email sent to telegram",
+	b.wakeDelivery()
+
+	b.logger.Debug("email queued for delivery",
 		"account_id", accountID,
-		"telegram_msg_id", tgMsg.ID,
+		"message_id", emailMsg.ID,
 		"codes_detected", len(codes),
 	)
+	return nil
+}
+
+// checkSender checks the sender of a raw email, trusting the server verdicts
+// of the account's mail provider and the configured servers
+func (b *Bot) checkSender(ctx context.Context, account *models.EmailAccount, raw []byte, from string) mailauth.Result {
+	return b.mailAuth.Check(ctx, raw, from, mailauth.TrustedServers(account.IMAPServer, b.config.TrustedAuthServIDs))
+}
+
+// renderBody converts the email body to the text shown in Telegram,
+// decrypting S/MIME emails of accounts with a key
+func (b *Bot) renderBody(ctx context.Context, account *models.EmailAccount, rawEmail *email.RawEmail) string {
+	notice := b.decryptEmail(ctx, account, rawEmail)
+
+	// Parse HTML to text
+	bodyText := rawEmail.BodyText
+	if rawEmail.BodySkipped {
+		bodyText = fmt.Sprintf("Письмо слишком большое (%s), текст не загружен", formatter.FormatSize(i18n.Default, int64(rawEmail.Size)))
+	} else if rawEmail.BodyHTML != "" {
+		parsed, err := b.htmlParser.Parse(rawEmail.BodyHTML)
+		if err != nil {
+			b.logger.Warn("failed to parse HTML", "error", err)
+		} else {
+			bodyText = parsed
+		}
+	}
+
+	// Attachment- or image-only emails get a descriptive placeholder
+	if strings.TrimSpace(bodyText) == "" {
+		if notice != "" {
+			return notice
+		}
+		bodyText = emptyBodyPlaceholder(rawEmail)
+	}
+	if notice != "" {
+		bodyText = notice + "\n\n" + bodyText
+	}
+	return bodyText
+}
+
+// detectCodes finds the codes and structured data of an email. A
+// sender-specific extractor enabled for the chat takes precedence over the
+// account's custom and the generic code patterns. The extraction is nil if
+// nothing specific was found.
+func (b *Bot) detectCodes(ctx context.Context, account *models.EmailAccount, in parser.ExtractInput) ([]models.DetectedCode, *models.Extraction) {
+	var extraction *models.Extraction
+	if b.extractors != nil {
+		settings, err := b.db.GetChatSettings(ctx, account.ChatID)
+		if err != nil {
+			b.logger.Warn("failed to get chat settings", "error", err)
+			settings = models.DefaultChatSettings(account.ChatID)
+		}
+		extraction = b.extractors.Extract(in, settings.ExtractorEnabled)
+	}
+
+	// Order confirmations get a purchase card
+	if b.orderDetector != nil {
+		if order := b.orderDetector.DetectOrder(in); order != nil {
+			if extraction == nil {
+				extraction = &models.Extraction{}
+			}
+			extraction.Order = order
+		}
+	}
+
+	// Shipping notifications get tracking buttons
+	if b.trackingDetector != nil {
+		if tracking := b.trackingDetector.DetectTracking(in.Text); len(tracking) > 0 {
+			if extraction == nil {
+				extraction = &models.Extraction{}
+			}
+			extraction.Tracking = tracking
+		}
+	}
+
+	// Confirmation, sign-in and password reset links get buttons
+	if actions := parser.DetectActionLinks(in.HTML); len(actions) > 0 {
+		if extraction == nil {
+			extraction = &models.Extraction{}
+		}
+		extraction.Actions = actions
+	}
+
+	if extraction == nil || len(extraction.Codes) == 0 {
+		return b.codeDetector.DetectCodesWith(in.Text, b.codePatterns(ctx, account.ID)), extraction
+	}
+	return extraction.Codes, extraction
+}
+
+// codePatterns returns the compiled custom code patterns of an account.
+// Patterns that no longer compile are skipped.
+func (b *Bot) codePatterns(ctx context.Context, accountID int64) []*regexp.Regexp {
+	patterns, err := b.db.GetCodePatterns(ctx, accountID)
+	if err != nil {
+		b.logger.Warn("failed to get code patterns", "error", err, "account_id", accountID)
+		return nil
+	}
+
+	var compiled []*regexp.Regexp
+	for _, p := range patterns {
+		re, err := parser.CompileCustomPattern(p.Pattern)
+		if err != nil {
+			b.logger.Warn("invalid code pattern", "error", err, "pattern_id", p.ID)
+			continue
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled
+}
+
+// encodeExtraction serializes an extraction for storage ("" for nil)
+func encodeExtraction(extraction *models.Extraction) string {
+	if extraction == nil {
+		return ""
+	}
+	data, _ := json.Marshal(extraction)
+	return string(data)
+}
+
+// encodeUnsubscribe serializes the List-Unsubscribe of an email for storage
+func encodeUnsubscribe(u *models.Unsubscribe) string {
+	if u == nil {
+		return ""
+	}
+	data, _ := json.Marshal(u)
+	return string(data)
+}
+
+// decodeCodes parses the stored JSON array of detected codes
+func decodeCodes(data string) []models.DetectedCode {
+	var codes []models.DetectedCode
+	if data == "" {
+		return codes
+	}
+	if err := json.Unmarshal([]byte(data), &codes); err != nil {
+		return nil
+	}
+	return codes
+}
+
+// emailKeyboard builds the inline keyboard of a forwarded email for the
+// account's formatting profile and the language of ctx. truncated adds the
+// full text button.
+func emailKeyboard(ctx context.Context, account *models.EmailAccount, msg *models.EmailMessage, codes []models.DetectedCode, truncated bool) *tgmodels.InlineKeyboardMarkup {
+	return formatter.BuildEmailKeyboard(formatter.EmailKeyboard{
+		MsgID:       msg.ID,
+		Codes:       codes,
+		Attachments: decodeAttachments(msg.Attachments),
+		Tracking:    decodeTracking(msg),
+		Actions:     decodeActions(msg),
+		IsRead:      msg.IsRead,
+		HasHTML:     msg.BodyHTML != "",
+		Truncated:   truncated,
+		Folder:      msg.Folder,
+		Deleted:     msg.IsDeleted,
+		Unsubscribe: msg.UnsubscribeData(),
+		Profile:     account.FormatProfile,
+		Language:    i18n.Lang(ctx),
+	})
+}
+
+// hasCallbackButton reports whether a keyboard has a button with the action
+func hasCallbackButton(keyboard *tgmodels.InlineKeyboardMarkup, action models.CallbackAction) bool {
+	if keyboard == nil {
+		return false
+	}
+	for _, row := range keyboard.InlineKeyboard {
+		for _, button := range row {
+			if data, err := formatter.DecodeCallback(button.CallbackData); err == nil && data.Action == action {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// decodeTracking returns the tracking numbers stored in a message's extraction
+func decodeTracking(msg *models.EmailMessage) []models.TrackingNumber {
+	if extraction := msg.ExtractedData(); extraction != nil {
+		return extraction.Tracking
+	}
+	return nil
+}
+
+// decodeActions returns the action links stored in a message's extraction
+func decodeActions(msg *models.EmailMessage) []models.ActionLink {
+	if extraction := msg.ExtractedData(); extraction != nil {
+		return extraction.Actions
+	}
+	return nil
+}
+
+// decodeAttachments parses the stored JSON array of attachments
+func decodeAttachments(data string) []models.Attachment {
+	var attachments []models.Attachment
+	if data == "" {
+		return attachments
+	}
+	if err := json.Unmarshal([]byte(data), &attachments); err != nil {
+		return nil
+	}
+	return attachments
+}
+
+// emptyBodyPlaceholder describes an email that has no readable text
+func emptyBodyPlaceholder(rawEmail *email.RawEmail) string {
+	switch len(rawEmail.Attachments) {
+	case 0:
+		if strings.Contains(strings.ToLower(rawEmail.BodyHTML), "<img") {
+			return "Письмо содержит только изображения"
+		}
+		return ""
+	case 1:
+		att := rawEmail.Attachments[0]
+		return fmt.Sprintf("Письмо содержит только вложение: %s, %s", att.Filename, formatter.FormatSize(i18n.Default, att.Size))
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Письмо содержит только вложения:")
+	for _, att := range rawEmail.Attachments {
+		sb.WriteString(fmt.Sprintf("\n• %s, %s", att.Filename, formatter.FormatSize(i18n.Default, att.Size)))
+	}
+	return sb.String()
+}
+
+// connectionLimitHint explains how to free IMAP connections when the server
+// refuses new sessions over its per-account limit
+const connectionLimitHint = "Обычно так бывает, когда ящик одновременно открыт в почтовых программах на компьютере или телефоне (особенно у Mail.ru). Что можно сделать:\n" +
+	"• закрыть лишние почтовые программы или переключить их на POP3\n" +
+	"• завершить другие сеансы в настройках безопасности почты\n" +
+	"• подключить к боту отдельный ящик с пересылкой писем"
+
+// authHint explains why a correct account password may be rejected
+const authHint = "Многие почтовые сервисы (Gmail, Яндекс, Mail.ru, iCloud) не пускают по IMAP с основным паролем: " +
+	"нужен пароль приложения, а доступ по IMAP должен быть включён в настройках ящика."
+
+// connectErrorText explains a failed connection test to the user
+func connectErrorText(ctx context.Context, err error) string {
+	var reason string
+	switch email.Category(err) {
+	case email.ErrConnectionLimit:
+		reason = i18n.T(ctx, "Сервер отклонил подключение: превышено число одновременных IMAP-соединений.\n\n") + i18n.T(ctx, connectionLimitHint)
+	case email.ErrRateLimited:
+		reason = i18n.T(ctx, "Сервер временно ограничил подключения. Попробуйте через несколько минут.")
+	case email.ErrAuth:
+		reason = i18n.T(ctx, "Неверный адрес или пароль.\n\n") + i18n.T(ctx, authHint)
+	case email.ErrMailboxNotFound:
+		reason = i18n.T(ctx, "На сервере не найдена папка INBOX.")
+	case email.ErrNetwork:
+		reason = i18n.T(ctx, "Не удалось связаться с IMAP-сервером. Проверьте его адрес или попробуйте позже.")
+	default:
+		reason = i18n.T(ctx, "Ошибка подключения.")
+	}
+	return fmt.Sprintf("%s\n\n<code>%s</code>", reason, html.EscapeString(err.Error()))
 }
 
 // onEmailError handles an email error
@@ -117,9 +409,53 @@ func (b *Bot) onEmailError(accountID int64, err error) {
 		b.logger.Error("failed to get account for error notification", "error", errDB)
 		return
 	}
+	ctx = b.chatContext(ctx, account.ChatID)
+
+	var text string
+	var circuit *email.CircuitError
+	switch {
+	case errors.As(err, &circuit):
+		text = i18n.Tf(ctx, "⚠️ Не удаётся подключиться к почте <b>%s</b>: %d неудачных попыток подряд.\n<code>%s</code>\n\n"+
+			"Бот будет пробовать раз в %s. Состояние — в /status.", account.Email, circuit.Failures,
+			html.EscapeString(circuit.Err.Error()), shortDuration(b.config.CircuitCooldown))
+	case errors.Is(err, email.ErrConnectionLimit):
+		text = i18n.Tf(ctx, "⚠️ Сервер отклоняет подключение к почте <b>%s</b>: превышено число одновременных IMAP-соединений.\n\n"+
+			"Бот будет переподключаться реже, пока лимит не освободится.\n\n%s", account.Email, i18n.T(ctx, connectionLimitHint))
+	case errors.Is(err, email.ErrRateLimited):
+		text = i18n.Tf(ctx, "⚠️ Сервер временно ограничил подключения к почте <b>%s</b>.\n\n"+
+			"Бот будет переподключаться реже, пока ограничение не снимут.", account.Email)
+	case errors.Is(err, email.ErrAuth):
+		// Reported once the password was rejected several times in a row;
+		// the account's own client is the caller, so stop it separately
+		go b.pauseAuthFailedAccount(accountID)
+		return
+	case errors.Is(err, email.ErrMailboxNotFound):
+		text = i18n.Tf(ctx, "⚠️ На сервере почты <b>%s</b> не найдена папка INBOX.\n\n"+
+			"Проверьте ящик в веб-интерфейсе почты. Бот будет проверять его реже.", account.Email)
+	default:
+		text = i18n.Tf(ctx, "Ошибка подключения к почте <b>%s</b>:\n<code>%s</code>\n\nПопытка переподключения...",
+			account.Email, html.EscapeString(err.Error()))
+	}
 
-	// Send error notification to topic
-	text := fmt.Sprintf("Ошибка подключения к почте <b>%s</b>:\n<code>%v</code>\n\nПопытка переподключения...",
-		account.Email, err)
 	b.sendMessage(ctx, account.ChatID, account.TopicID, text)
+	b.wakeStatusBoards()
+}
+
+// archiveRaw stores the complete raw message if the archive is enabled
+func (b *Bot) archiveRaw(ctx context.Context, msg *models.EmailMessage, rawEmail *email.RawEmail) {
+	if b.archive == nil || len(rawEmail.Raw) == 0 {
+		return
+	}
+
+	key, size, err := b.archive.Save(ctx, msg.AccountID, msg.UID, rawEmail.Raw)
+	if err != nil {
+		b.logger.Error("failed to archive raw message", "error", err, "message_id", msg.ID)
+		return
+	}
+
+	if err := b.db.UpdateMessageRawKey(ctx, msg.ID, key, size); err != nil {
+		b.logger.Error("failed to save raw key", "error", err)
+		return
+	}
+	msg.RawKey, msg.RawSize = key, size
 }
@@ -0,0 +1,94 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/mixelka/emailresend/internal/credexport"
+	"github.com/mixelka/emailresend/internal/database"
+	"github.com/mixelka/emailresend/internal/i18n"
+	appmodels "github.com/mixelka/emailresend/pkg/models"
+)
+
+// exportTimeout bounds decryption of all accounts plus the age/gpg run
+const exportTimeout = time.Minute
+
+// handleExportCredentials handles /exportcreds command
+// Usage: /exportcreds <age recipient | armored PGP public key>
+func (b *Bot) handleExportCredentials(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	if !b.isOwner(msg.From.ID) {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Эта команда доступна только владельцу бота")
+		return
+	}
+	if msg.Chat.Type != "private" {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Экспорт доступен только в личном чате с ботом")
+		return
+	}
+
+	key := ""
+	if i := strings.IndexFunc(msg.Text, unicode.IsSpace); i >= 0 {
+		key = msg.Text[i:]
+	}
+	recipient, err := credexport.ParseRecipient(key)
+	if err != nil {
+		b.sendMessage(ctx, msg.Chat.ID, 0,
+			"Использование: <code>/exportcreds age1...</code> или <code>/exportcreds</code> с публичным PGP-ключем (ASCII armor) в том же сообщении\n\n"+
+				"Пароли всех аккаунтов будут зашифрованы этим ключом; расшифрованный файл можно снова загрузить через /import.")
+		return
+	}
+
+	exportCtx, cancel := context.WithTimeout(ctx, exportTimeout)
+	defer cancel()
+
+	accounts, err := b.db.GetAllAccounts(exportCtx)
+	if err != nil {
+		b.logger.Error("failed to get accounts", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, 0, "Ошибка получения аккаунтов")
+		return
+	}
+
+	oauthToken := func(accountID int64) (*appmodels.OAuthToken, error) {
+		token, err := b.db.GetOAuthToken(exportCtx, accountID)
+		if errors.Is(err, database.ErrNotFound) {
+			return nil, nil
+		}
+		return token, err
+	}
+	export, err := credexport.Build(accounts, oauthToken, b.decryptPassword)
+	if err != nil {
+		b.logger.Error("failed to build credentials export", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, 0, "Ошибка расшифровки паролей")
+		return
+	}
+
+	ciphertext, err := credexport.Encrypt(exportCtx, recipient, export)
+	if err != nil {
+		b.logger.Error("failed to encrypt credentials export", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, 0, i18n.Tf(ctx, "Ошибка шифрования: %v", err))
+		return
+	}
+
+	filename := "credentials-" + time.Now().Format("20060102-150405") + recipient.Ext()
+	if _, err := b.sendDocument(ctx, &bot.SendDocumentParams{
+		ChatID: msg.Chat.ID,
+		Document: &models.InputFileUpload{
+			Filename: filename,
+			Data:     bytes.NewReader(ciphertext),
+		},
+		Caption: i18n.Tf(ctx, "🔐 Экспорт учётных данных: %d аккаунтов, зашифровано (%s)", len(export.Accounts), recipient.Kind),
+	}); err != nil {
+		b.logger.Error("failed to send credentials export", "error", err)
+		return
+	}
+
+	b.logger.Info("credentials exported", "accounts", len(export.Accounts), "kind", recipient.Kind)
+}
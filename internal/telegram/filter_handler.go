@@ -0,0 +1,202 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/mixelka/emailresend/internal/database"
+	"github.com/mixelka/emailresend/internal/i18n"
+	appmodels "github.com/mixelka/emailresend/pkg/models"
+)
+
+// filterUsage explains the /filter command
+const filterUsage = `Использование:
+<code>/filter deny from spam@example.com</code> — адрес отправителя
+<code>/filter deny domain example.com</code> — домен отправителя и его поддомены
+<code>/filter deny subject (?i)скидк|акци</code> — регулярное выражение по теме
+<code>/filter allow ...</code> — всегда пропускать; если есть правила allow, остальные письма отфильтровываются
+<code>/filter del 3</code> — удалить правило
+<code>/filter spam 42</code> — отправлять отфильтрованные письма в топик с ID 42 (<code>/filter spam off</code> — пропускать)`
+
+// handleFilter handles /filter command
+// Usage: /filter [allow|deny from|domain|subject pattern | del id | spam topic_id|off]
+func (b *Bot) handleFilter(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	account, ok := b.getTopicAccount(ctx, msg)
+	if !ok {
+		return
+	}
+
+	parts := strings.Fields(msg.Text)
+	if len(parts) < 2 {
+		b.sendFilterList(ctx, msg, account)
+		return
+	}
+
+	if !b.checkAdmin(ctx, msg, "Только администраторы могут менять фильтры") {
+		return
+	}
+
+	switch action := strings.ToLower(parts[1]); action {
+	case appmodels.FilterAllow, appmodels.FilterDeny:
+		b.addFilter(ctx, msg, account, action)
+	case "del":
+		b.deleteFilter(ctx, msg, account, parts)
+	case "spam":
+		b.setSpamTopic(ctx, msg, account, parts)
+	default:
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, filterUsage)
+	}
+}
+
+// sendFilterList shows the rules and the spam topic of an account
+func (b *Bot) sendFilterList(ctx context.Context, msg *models.Message, account *appmodels.EmailAccount) {
+	filters, err := b.db.GetFilters(ctx, account.ID)
+	if err != nil {
+		b.logger.Error("failed to get filters", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка получения фильтров")
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(i18n.T(ctx, "<b>Фильтры писем этого топика:</b>\n\n"))
+	if len(filters) == 0 {
+		sb.WriteString(i18n.T(ctx, "Правил нет, пересылаются все письма\n"))
+	}
+	for _, f := range filters {
+		mark := "⛔"
+		if f.Action == appmodels.FilterAllow {
+			mark = "✅"
+		}
+		sb.WriteString(fmt.Sprintf("%s <code>%d</code> %s %s <code>%s</code>\n", mark, f.ID, f.Action, f.Field, html.EscapeString(f.Pattern)))
+	}
+
+	if account.SpamTopicID != 0 {
+		sb.WriteString(i18n.Tf(ctx, "\nОтфильтрованные письма: в топик <code>%d</code>\n", account.SpamTopicID))
+	} else {
+		sb.WriteString(i18n.T(ctx, "\nОтфильтрованные письма: пропускаются\n"))
+	}
+	sb.WriteString("\n" + i18n.T(ctx, filterUsage))
+	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, sb.String())
+}
+
+// addFilter adds an allow or deny rule from /filter allow|deny field pattern
+func (b *Bot) addFilter(ctx context.Context, msg *models.Message, account *appmodels.EmailAccount, action string) {
+	// The pattern is the rest of the text and may contain spaces
+	fields := strings.Fields(msg.Text)
+	if len(fields) < 4 {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, filterUsage)
+		return
+	}
+	field := strings.ToLower(fields[2])
+	pattern := msg.Text
+	for _, f := range fields[:3] {
+		pattern = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(pattern), f))
+	}
+
+	switch field {
+	case appmodels.FilterFrom:
+		if !strings.Contains(pattern, "@") {
+			b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Укажите адрес отправителя, например <code>spam@example.com</code>")
+			return
+		}
+	case appmodels.FilterDomain:
+		pattern = strings.TrimPrefix(pattern, "@")
+	case appmodels.FilterSubject:
+		if _, err := regexp.Compile(pattern); err != nil {
+			b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.Tf(ctx, "Некорректное регулярное выражение: <code>%s</code>", html.EscapeString(err.Error())))
+			return
+		}
+	default:
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Поле фильтра: <code>from</code>, <code>domain</code> или <code>subject</code>")
+		return
+	}
+
+	filter := &appmodels.Filter{
+		AccountID: account.ID,
+		Action:    action,
+		Field:     field,
+		Pattern:   pattern,
+		CreatedBy: msg.From.ID,
+	}
+	if err := b.db.CreateFilter(ctx, filter); err != nil {
+		b.logger.Error("failed to create filter", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка сохранения фильтра")
+		return
+	}
+
+	b.logger.Info("filter added", "account_id", account.ID, "filter_id", filter.ID, "action", action, "field", field, "user_id", msg.From.ID)
+	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+		i18n.Tf(ctx, "Правило <code>%d</code> добавлено: %s %s <code>%s</code>", filter.ID, action, field, html.EscapeString(pattern)))
+}
+
+// deleteFilter removes a rule from /filter del id
+func (b *Bot) deleteFilter(ctx context.Context, msg *models.Message, account *appmodels.EmailAccount, parts []string) {
+	if len(parts) < 3 {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Использование: <code>/filter del номер_правила</code>")
+		return
+	}
+	id, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Некорректный номер правила")
+		return
+	}
+
+	err = b.db.DeleteFilter(ctx, account.ID, id)
+	if errors.Is(err, database.ErrNotFound) {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Правило не найдено")
+		return
+	}
+	if err != nil {
+		b.logger.Error("failed to delete filter", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка удаления фильтра")
+		return
+	}
+
+	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.Tf(ctx, "Правило <code>%d</code> удалено", id))
+}
+
+// setSpamTopic sets where filtered emails go from /filter spam topic_id|off
+func (b *Bot) setSpamTopic(ctx context.Context, msg *models.Message, account *appmodels.EmailAccount, parts []string) {
+	if len(parts) < 3 {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Использование: <code>/filter spam ID_топика</code> или <code>/filter spam off</code>")
+		return
+	}
+
+	if strings.ToLower(parts[2]) == "off" {
+		account.SpamTopicID = 0
+	} else {
+		topicID, err := strconv.Atoi(parts[2])
+		if err != nil || topicID <= 0 {
+			b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Некорректный ID топика (его можно узнать в /status)")
+			return
+		}
+		if topicID == account.TopicID {
+			b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Топик для спама должен отличаться от топика почты")
+			return
+		}
+		account.SpamTopicID = topicID
+	}
+
+	if err := b.db.UpdateAccountSettings(ctx, account); err != nil {
+		b.logger.Error("failed to update account settings", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка сохранения настроек")
+		return
+	}
+
+	if account.SpamTopicID == 0 {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Отфильтрованные письма будут пропускаться")
+	} else {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+			i18n.Tf(ctx, "Отфильтрованные письма будут приходить без звука в топик <code>%d</code>", account.SpamTopicID))
+	}
+}
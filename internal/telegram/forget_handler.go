@@ -0,0 +1,142 @@
+package telegram
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/mixelka/emailresend/internal/formatter"
+	"github.com/mixelka/emailresend/internal/i18n"
+	appmodels "github.com/mixelka/emailresend/pkg/models"
+)
+
+// forgetConfirmTTL is how long a /forgetme confirmation stays valid
+const forgetConfirmTTL = 5 * time.Minute
+
+// handleForgetMe handles /forgetme command
+func (b *Bot) handleForgetMe(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	isOwner, err := b.isChatOwner(ctx, msg.Chat.ID, msg.From.ID)
+	if err != nil {
+		b.logger.Error("failed to check chat owner", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка проверки прав")
+		return
+	}
+	if !isOwner {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Удалить данные чата может только его владелец")
+		return
+	}
+
+	text := "⚠️ <b>Удаление всех данных чата</b>\n\n" +
+		"Будут отключены все почтовые аккаунты этого чата и удалены сохранённые письма, коды, вложения, заказы и настройки. " +
+		"Сообщения, уже отправленные в чат, останутся.\n\n" +
+		"Действие необратимо. Подтвердить может только владелец чата в течение 5 минут."
+	keyboard := formatter.BuildConfirmKeyboard(appmodels.CallbackForgetChat, 0, "🗑 Удалить всё")
+	if _, err := b.sendMessageWithKeyboard(ctx, msg.Chat.ID, msg.MessageThreadID, text, keyboard, messageOptions{}); err != nil {
+		b.logger.Error("failed to send forget confirmation", "error", err)
+	}
+}
+
+// handleForgetConfirm handles the /forgetme confirmation buttons
+func (b *Bot) handleForgetConfirm(ctx context.Context, callback *models.CallbackQuery, data appmodels.CallbackData) {
+	prompt := callback.Message.Message
+	if prompt == nil {
+		b.answerCallback(ctx, callback.ID, "Сообщение недоступно", false)
+		return
+	}
+	chatID := prompt.Chat.ID
+
+	isOwner, err := b.isChatOwner(ctx, chatID, callback.From.ID)
+	if err != nil {
+		b.logger.Error("failed to check chat owner", "error", err)
+		b.answerCallback(ctx, callback.ID, "Ошибка проверки прав", false)
+		return
+	}
+	if !isOwner {
+		b.answerCallback(ctx, callback.ID, "Подтвердить удаление может только владелец чата", true)
+		return
+	}
+
+	if data.Arg != "yes" {
+		b.editMessageText(ctx, chatID, prompt.ID, "Удаление данных отменено")
+		b.answerCallback(ctx, callback.ID, "Отменено", false)
+		return
+	}
+
+	if time.Since(time.Unix(int64(prompt.Date), 0)) > forgetConfirmTTL {
+		b.editMessageText(ctx, chatID, prompt.ID, "Подтверждение устарело, отправьте /forgetme ещё раз")
+		b.answerCallback(ctx, callback.ID, "Подтверждение устарело", true)
+		return
+	}
+
+	// Stop the IMAP clients first so no new mail is stored while erasing
+	ids, err := b.db.GetAccountIDsByChatID(ctx, chatID)
+	if err != nil {
+		b.logger.Error("failed to get chat accounts", "error", err)
+		b.answerCallback(ctx, callback.ID, "Ошибка получения аккаунтов", false)
+		return
+	}
+	for _, id := range ids {
+		if err := b.emailManager.RemoveAccount(id); err != nil {
+			b.logger.Error("failed to stop email client", "error", err, "account_id", id)
+		}
+	}
+
+	settings, err := b.db.GetChatSettings(ctx, chatID)
+	if err != nil {
+		b.logger.Error("failed to get chat settings", "error", err)
+	} else if settings.StatusMsgID != 0 {
+		b.unpinMessage(ctx, chatID, settings.StatusMsgID)
+	}
+
+	stats, err := b.db.ForgetChat(ctx, chatID)
+	if err != nil {
+		b.logger.Error("failed to erase chat data", "error", err, "chat_id", chatID)
+		b.answerCallback(ctx, callback.ID, "Ошибка удаления данных", true)
+		return
+	}
+
+	var rawFailed int
+	if b.archive != nil {
+		for _, key := range stats.RawKeys {
+			if err := b.archive.Delete(ctx, key); err != nil {
+				b.logger.Warn("failed to delete archived message", "error", err, "key", key)
+				rawFailed++
+			}
+		}
+	}
+
+	b.statusMu.Lock()
+	delete(b.statusBoards, chatID)
+	b.statusMu.Unlock()
+	b.wakeStatusBoards()
+
+	b.logger.Info("chat data erased", "chat_id", chatID, "user_id", callback.From.ID,
+		"accounts", stats.Accounts, "messages", stats.Messages)
+
+	var sb strings.Builder
+	sb.WriteString(i18n.T(ctx, "🗑 <b>Данные чата удалены</b>\n\n"))
+	sb.WriteString(i18n.Tf(ctx, "Отключено аккаунтов: %d\n", stats.Accounts))
+	sb.WriteString(i18n.Tf(ctx, "Писем: %d\n", stats.Messages))
+	sb.WriteString(i18n.Tf(ctx, "Кодов: %d\n", stats.Codes))
+	sb.WriteString(i18n.Tf(ctx, "Вложений: %d\n", stats.Attachments))
+	sb.WriteString(i18n.Tf(ctx, "Заказов: %d\n", stats.Orders))
+	if b.archive != nil {
+		sb.WriteString(i18n.Tf(ctx, "Исходных писем в архиве: %d\n", len(stats.RawKeys)-rawFailed))
+	}
+	if stats.Settings {
+		sb.WriteString(i18n.T(ctx, "Настройки чата: удалены\n"))
+	} else {
+		sb.WriteString(i18n.T(ctx, "Настройки чата: не были сохранены\n"))
+	}
+	if rawFailed > 0 {
+		sb.WriteString(i18n.Tf(ctx, "\n⚠️ Не удалось удалить из архива: %d", rawFailed))
+	}
+
+	b.editMessageText(ctx, chatID, prompt.ID, sb.String())
+	b.answerCallback(ctx, callback.ID, "Данные удалены", false)
+}
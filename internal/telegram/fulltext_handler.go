@@ -0,0 +1,91 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/mixelka/emailresend/internal/formatter"
+	"github.com/mixelka/emailresend/internal/i18n"
+	appmodels "github.com/mixelka/emailresend/pkg/models"
+)
+
+const (
+	// fullTextChunkLen is the length of one full text message, below
+	// Telegram's 4096 character limit
+	fullTextChunkLen = 4000
+	// maxFullTextMessages is how many messages the full text may take before
+	// it is sent as a .txt file instead
+	maxFullTextMessages = 5
+)
+
+// handleFullText handles the full text button of a truncated email: the
+// body is sent as plain text messages below the original, or as a file if
+// it is too long
+func (b *Bot) handleFullText(ctx context.Context, callback *models.CallbackQuery, data appmodels.CallbackData) {
+	msg, ok := b.getCallbackMessage(ctx, callback, data)
+	if !ok {
+		return
+	}
+	if strings.TrimSpace(msg.BodyText) == "" {
+		b.answerCallback(ctx, callback.ID, "У письма нет текста", false)
+		return
+	}
+
+	account, err := b.db.GetAccountByID(ctx, msg.AccountID)
+	if err != nil {
+		b.logger.Error("failed to get account", "error", err)
+		b.answerCallback(ctx, callback.ID, "Аккаунт не найден", false)
+		return
+	}
+	b.answerCallback(ctx, callback.ID, "", false)
+
+	topicID := account.TopicID
+	if callback.Message.Message != nil {
+		topicID = callback.Message.Message.MessageThreadID
+	}
+	var reply *models.ReplyParameters
+	if msg.TelegramMsgID != 0 {
+		reply = &models.ReplyParameters{
+			MessageID:                msg.TelegramMsgID,
+			AllowSendingWithoutReply: true,
+		}
+	}
+
+	chunks := formatter.SplitText(msg.BodyText, fullTextChunkLen)
+	if len(chunks) > maxFullTextMessages {
+		params := &bot.SendDocumentParams{
+			ChatID:          account.ChatID,
+			MessageThreadID: topicID,
+			Document: &models.InputFileUpload{
+				Filename: fmt.Sprintf("email-%d.txt", msg.ID),
+				Data:     strings.NewReader(msg.BodyText),
+			},
+			Caption:         i18n.T(ctx, "Полный текст письма"),
+			ProtectContent:  account.ProtectContent,
+			ReplyParameters: reply,
+		}
+		if _, err := b.sendDocument(ctx, params); err != nil {
+			b.logger.Error("failed to send full text", "error", err, "message_id", msg.ID)
+		}
+		return
+	}
+
+	// Plain text, so the body needs no escaping
+	for _, chunk := range chunks {
+		params := &bot.SendMessageParams{
+			ChatID:          account.ChatID,
+			MessageThreadID: topicID,
+			Text:            chunk,
+			ProtectContent:  account.ProtectContent,
+			ReplyParameters: reply,
+		}
+		if _, err := b.send(ctx, params); err != nil {
+			b.logger.Error("failed to send full text", "error", err, "message_id", msg.ID)
+			return
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/mixelka/emailresend/internal/database"
+	"github.com/mixelka/emailresend/internal/i18n"
+	appmodels "github.com/mixelka/emailresend/pkg/models"
+)
+
+const grantUsage = "Использование: ответьте командой <code>/grant</code> на сообщение пользователя или укажите его ID: <code>/grant 123456789</code>. <code>/revoke</code> — забрать права."
+
+// handleGrant handles /grant command: lets a member who is not a Telegram
+// admin manage the bot in the chat, or lists who was granted
+// Usage: /grant [user ID], or in reply to the user's message
+func (b *Bot) handleGrant(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+	if !b.checkGrantRights(ctx, msg) {
+		return
+	}
+
+	user, ok := b.grantTarget(ctx, msg, true)
+	if !ok {
+		b.sendBotAdmins(ctx, msg)
+		return
+	}
+	if user == nil {
+		return
+	}
+	if user.IsBot {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Права можно выдать только пользователю, не боту")
+		return
+	}
+
+	err := b.db.GrantBotAdmin(ctx, &appmodels.BotAdmin{
+		ChatID:    msg.Chat.ID,
+		UserID:    user.ID,
+		Name:      userName(user),
+		GrantedBy: msg.From.ID,
+	})
+	if err != nil {
+		b.logger.Error("failed to grant bot admin", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка сохранения прав")
+		return
+	}
+
+	b.logger.Info("bot admin granted", "chat_id", msg.Chat.ID, "user_id", user.ID, "granted_by", msg.From.ID)
+	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.Tf(ctx,
+		"✅ <b>%s</b> может управлять ботом в этом чате, как администратор. Забрать права: <code>/revoke %d</code>",
+		html.EscapeString(userName(user)), user.ID))
+}
+
+// handleRevoke handles /revoke command: takes bot management granted with
+// /grant away
+// Usage: /revoke <user ID>, or in reply to the user's message
+func (b *Bot) handleRevoke(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+	if !b.checkGrantRights(ctx, msg) {
+		return
+	}
+
+	user, ok := b.grantTarget(ctx, msg, false)
+	if !ok {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, grantUsage)
+		return
+	}
+	if user == nil {
+		return
+	}
+
+	err := b.db.RevokeBotAdmin(ctx, msg.Chat.ID, user.ID)
+	if errors.Is(err, database.ErrNotFound) {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Этому пользователю права не выдавались")
+		return
+	}
+	if err != nil {
+		b.logger.Error("failed to revoke bot admin", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка сохранения прав")
+		return
+	}
+
+	b.logger.Info("bot admin revoked", "chat_id", msg.Chat.ID, "user_id", user.ID, "revoked_by", msg.From.ID)
+	text := i18n.Tf(ctx, "Права на управление ботом отозваны у <b>%s</b>", html.EscapeString(userName(user)))
+	if isAdmin, err := b.isUserAdmin(ctx, msg.Chat.ID, user.ID); err == nil && isAdmin {
+		text += i18n.T(ctx, "\nПользователь остаётся администратором группы и по-прежнему может управлять ботом.")
+	}
+	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, text)
+}
+
+// checkGrantRights checks that /grant and /revoke are used in a group by its
+// owner or a bot operator, replying with an error otherwise. Granted users
+// cannot grant in turn.
+func (b *Bot) checkGrantRights(ctx context.Context, msg *models.Message) bool {
+	if msg.Chat.Type == "private" {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Эта команда работает только в группах")
+		return false
+	}
+	if b.isOperator(msg.From.ID) {
+		return true
+	}
+
+	isOwner, err := b.isChatOwner(ctx, msg.Chat.ID, msg.From.ID)
+	if err != nil {
+		b.logger.Error("failed to check chat owner", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка проверки прав")
+		return false
+	}
+	if !isOwner {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Выдавать и забирать права на управление ботом может только владелец чата")
+		return false
+	}
+	return true
+}
+
+// grantTarget returns the user a /grant or /revoke command is about: the
+// author of the replied message or the user with the given ID, who must be in
+// the chat if member is set. Reports false if the command names nobody; a nil
+// user means the error was already sent.
+func (b *Bot) grantTarget(ctx context.Context, msg *models.Message, member bool) (*models.User, bool) {
+	parts := strings.Fields(msg.Text)
+	if len(parts) > 1 {
+		userID, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil || userID <= 0 {
+			b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, grantUsage)
+			return nil, true
+		}
+
+		// The lookup also gives the name
+		apiCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		chatMember, err := b.bot.GetChatMember(apiCtx, &bot.GetChatMemberParams{ChatID: msg.Chat.ID, UserID: userID})
+		if err == nil {
+			if user := memberUser(chatMember); user != nil {
+				return user, true
+			}
+		}
+		if member {
+			b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+				i18n.Tf(ctx, "Пользователь <code>%d</code> не найден в этом чате", userID))
+			return nil, true
+		}
+		return &models.User{ID: userID}, true
+	}
+
+	// In forum topics a message without an explicit reply points to the topic header
+	reply := msg.ReplyToMessage
+	if reply == nil || reply.ID == msg.MessageThreadID || reply.From == nil {
+		return nil, false
+	}
+	return reply.From, true
+}
+
+// sendBotAdmins lists the users granted bot management in the chat
+func (b *Bot) sendBotAdmins(ctx context.Context, msg *models.Message) {
+	admins, err := b.db.GetBotAdmins(ctx, msg.Chat.ID)
+	if err != nil {
+		b.logger.Error("failed to get bot admins", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка получения списка")
+		return
+	}
+
+	var sb strings.Builder
+	if len(admins) == 0 {
+		sb.WriteString(i18n.T(ctx, "Права на управление ботом никому не выданы — ботом управляют администраторы группы.\n"))
+	} else {
+		sb.WriteString(i18n.T(ctx, "<b>Управляют ботом, не будучи администраторами:</b>\n"))
+		for _, admin := range admins {
+			sb.WriteString(fmt.Sprintf("• %s — <code>%d</code>, %s\n",
+				html.EscapeString(admin.Name), admin.UserID, admin.CreatedAt.Format("02.01.2006")))
+		}
+	}
+	sb.WriteString("\n" + i18n.T(ctx, grantUsage))
+	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, sb.String())
+}
+
+// memberUser returns the user of a chat member, nil if they left or were
+// banned
+func memberUser(member *models.ChatMember) *models.User {
+	switch member.Type {
+	case models.ChatMemberTypeOwner:
+		return member.Owner.User
+	case models.ChatMemberTypeAdministrator:
+		return &member.Administrator.User
+	case models.ChatMemberTypeMember:
+		return member.Member.User
+	case models.ChatMemberTypeRestricted:
+		return member.Restricted.User
+	}
+	return nil
+}
+
+// userName returns the full name of a user with the @username if there is
+// one, the user ID if neither is known
+func userName(user *models.User) string {
+	name := strings.TrimSpace(user.FirstName + " " + user.LastName)
+	if name == "" && user.Username == "" {
+		return strconv.FormatInt(user.ID, 10)
+	}
+	if user.Username != "" {
+		if name == "" {
+			return "@" + user.Username
+		}
+		name += " (@" + user.Username + ")"
+	}
+	return name
+}
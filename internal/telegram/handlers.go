@@ -1,10 +1,15 @@
 package telegram
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"html"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
@@ -12,43 +17,26 @@ import (
 	"github.com/mixelka/emailresend/internal/database"
 	"github.com/mixelka/emailresend/internal/email"
 	"github.com/mixelka/emailresend/internal/formatter"
+	"github.com/mixelka/emailresend/internal/i18n"
+	"github.com/mixelka/emailresend/internal/parser"
+	"github.com/mixelka/emailresend/internal/smtp"
 	appmodels "github.com/mixelka/emailresend/pkg/models"
 )
 
+// maxUploadSize is the largest file a bot may send (Bot API limit)
+const maxUploadSize = 50 << 20
+
 // handleConnect handles /connect command
-// Usage: /connect email password [imap_server]
+// Usage: /connect email password [imap_server [smtp_server]]
 func (b *Bot) handleConnect(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
 	msg := update.Message
 
-	// Check if it's a supergroup with topics
-	if msg.Chat.Type != "supergroup" {
-		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Эта команда работает только в супергруппах")
-		return
-	}
-
-	if !msg.Chat.IsForum {
-		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Эта команда работает только в супергруппах с топиками")
-		return
-	}
-
-	// Check if user is admin
-	isAdmin, err := b.isUserAdmin(ctx, msg.Chat.ID, msg.From.ID)
-	if err != nil {
-		b.logger.Error("failed to check admin status", "error", err)
-		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка проверки прав")
-		return
-	}
-
-	if !isAdmin {
-		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Только администраторы могут подключать почтовые аккаунты")
-		return
-	}
-
-	// Parse command: /connect email password [imap_server]
+	// Parse command: /connect email password [imap_server [smtp_server]]
 	parts := strings.Fields(msg.Text)
-	if len(parts) < 3 || len(parts) > 4 {
+	if len(parts) < 3 || len(parts) > 5 {
 		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
-			"Использование: <code>/connect email@example.com password</code>\nИли: <code>/connect email@example.com password imap.server.com:993</code>")
+			"Использование: <code>/connect email@example.com password</code>\nИли: <code>/connect email@example.com password imap.server.com:993 [smtp.server.com:465]</code>\n\n"+
+				"Без TLS на порту 993 укажите шифрование после адреса: <code>imap.server.com:143/starttls</code>, <code>127.0.0.1:1143/starttls/insecure</code> (ProtonMail Bridge, сертификат не проверяется) или <code>imap.local:143/plain</code> (без шифрования)")
 		return
 	}
 
@@ -62,44 +50,61 @@ func (b *Bot) handleConnect(ctx context.Context, tgBot *bot.Bot, update *models.
 	}
 
 	// Determine IMAP server
-	var imapServer string
-	if len(parts) == 4 {
+	var imapServer, smtpServer string
+	if len(parts) >= 4 {
 		// User specified server
 		imapServer = parts[3]
+		if _, err := email.ParseServer(imapServer); err != nil {
+			b.sendMessage(ctx, msg.Chat.ID, topicID, i18n.Tf(ctx, "Неверный адрес IMAP сервера: %s", html.EscapeString(err.Error())))
+			return
+		}
 	} else {
 		// Auto-detect
 		b.sendMessage(ctx, msg.Chat.ID, topicID, "Определяю IMAP сервер...")
-		var err error
-		imapServer, err = email.ResolveIMAPServer(emailAddr)
+		servers, err := email.ResolveServers(ctx, emailAddr)
 		if err != nil {
 			b.logger.Error("failed to resolve IMAP server", "error", err)
 			b.sendMessage(ctx, msg.Chat.ID, topicID,
-				fmt.Sprintf("Не удалось определить IMAP сервер для %s\nПопробуйте указать вручную: <code>/connect email password imap.server.com:993</code>", emailAddr))
+				i18n.Tf(ctx, "Не удалось определить IMAP сервер для %s\nПопробуйте указать вручную: <code>/connect email password imap.server.com:993</code>", emailAddr))
 			return
 		}
-		b.logger.Info("resolved IMAP server", "email", emailAddr, "server", imapServer)
+		imapServer, smtpServer = servers.IMAP, servers.SMTP
+		b.logger.Info("resolved IMAP server", "email", emailAddr, "server", imapServer, "smtp_server", smtpServer)
+	}
+
+	// Determine SMTP server for replies; auto-detection never fails the
+	// connection, the server is resolved again on the first reply
+	if len(parts) == 5 {
+		smtpServer = parts[4]
+	} else if smtpServer == "" {
+		if resolved, err := smtp.ResolveServer(emailAddr, imapServer); err == nil {
+			smtpServer = resolved
+		}
 	}
 
-	// Check if topic already has an account
-	existing, err := b.db.GetAccountByChatAndTopic(ctx, msg.Chat.ID, topicID)
+	// In General of a chat with /autotopics on the account gets a topic of
+	// its own once the connection works
+	autoTopic := b.autoTopicEnabled(ctx, msg)
+
+	// Check if topic already has an account, of this bot or another one
+	existing, err := b.db.GetTopicAccount(ctx, msg.Chat.ID, topicID)
 	if err != nil && !errors.Is(err, database.ErrNotFound) {
 		b.logger.Error("failed to check existing account", "error", err)
 		b.sendMessage(ctx, msg.Chat.ID, topicID, "Ошибка проверки существующего подключения")
 		return
 	}
 
-	if existing != nil {
-		b.sendMessage(ctx, msg.Chat.ID, topicID,
-			fmt.Sprintf("В этом топике уже подключена почта: %s\nИспользуйте /disconnect для отключения", existing.Email))
+	if existing != nil && !autoTopic {
+		b.sendMessage(ctx, msg.Chat.ID, topicID, b.alreadyConnectedText(ctx, msg, existing))
 		return
 	}
 
 	// Test connection
-	b.sendMessage(ctx, msg.Chat.ID, topicID, fmt.Sprintf("Проверяю подключение к %s...", imapServer))
+	b.sendMessage(ctx, msg.Chat.ID, topicID, i18n.Tf(ctx, "Проверяю подключение к %s...", imapServer))
 
 	if err := b.emailManager.TestConnection(ctx, emailAddr, password, imapServer); err != nil {
 		b.logger.Error("connection test failed", "error", err)
-		b.sendMessage(ctx, msg.Chat.ID, topicID, fmt.Sprintf("Ошибка подключения: %v", err))
+		b.sendMessage(ctx, msg.Chat.ID, topicID, connectErrorText(ctx, err))
 		return
 	}
 
@@ -111,18 +116,36 @@ func (b *Bot) handleConnect(ctx context.Context, tgBot *bot.Bot, update *models.
 		return
 	}
 
+	accountTopicID := topicID
+	if autoTopic {
+		if accountTopicID, err = b.createAccountTopic(ctx, msg.Chat.ID, emailAddr); err != nil {
+			b.logger.Error("failed to create account topic", "error", err)
+			b.sendMessage(ctx, msg.Chat.ID, topicID, i18n.Tf(ctx, "Не удалось создать топик для почты: %v", err))
+			return
+		}
+	}
+
 	// Create account
 	account := &appmodels.EmailAccount{
 		Email:      emailAddr,
 		Password:   encryptedPassword,
 		IMAPServer: imapServer,
+		SMTPServer: smtpServer,
 		ChatID:     msg.Chat.ID,
-		TopicID:    topicID,
+		TopicID:    accountTopicID,
 		IsActive:   true,
 		CreatedBy:  msg.From.ID,
+		BotID:      b.accountBotID(),
 	}
 
 	if err := b.db.CreateAccount(ctx, account); err != nil {
+		if autoTopic {
+			b.deleteTopic(ctx, msg.Chat.ID, accountTopicID)
+		}
+		if errors.Is(err, database.ErrAlreadyExists) {
+			b.sendMessage(ctx, msg.Chat.ID, topicID, i18n.Tf(ctx, "Почта %s уже подключена в этом чате", emailAddr))
+			return
+		}
 		b.logger.Error("failed to create account", "error", err)
 		b.sendMessage(ctx, msg.Chat.ID, topicID, "Ошибка сохранения аккаунта в базу данных")
 		return
@@ -132,12 +155,35 @@ func (b *Bot) handleConnect(ctx context.Context, tgBot *bot.Bot, update *models.
 	if err := b.emailManager.AddAccount(ctx, account); err != nil {
 		b.logger.Error("failed to start email client", "error", err)
 		b.db.DeleteAccount(ctx, account.ID)
-		b.sendMessage(ctx, msg.Chat.ID, topicID, fmt.Sprintf("Ошибка запуска подключения: %v", err))
+		if autoTopic {
+			b.deleteTopic(ctx, msg.Chat.ID, accountTopicID)
+		}
+		b.sendMessage(ctx, msg.Chat.ID, topicID, i18n.Tf(ctx, "Ошибка запуска подключения: %v", err))
 		return
 	}
+	b.wakeStatusBoards()
 
-	b.sendMessage(ctx, msg.Chat.ID, topicID,
-		fmt.Sprintf("Почта <b>%s</b> успешно подключена к этому топику!\nСервер: %s\n\nНовые письма будут автоматически пересылаться сюда.", emailAddr, imapServer))
+	connected := "Почта <b>%s</b> успешно подключена к этому топику!\nСервер: %s\nSMTP для ответов: %s\n\nНовые письма будут автоматически пересылаться сюда, а ответ на письмо администратором будет отправлен отправителю."
+	if !msg.Chat.IsForum {
+		connected = "Почта <b>%s</b> успешно подключена к этому чату!\nСервер: %s\nSMTP для ответов: %s\n\nНовые письма будут автоматически пересылаться сюда, а ответ на письмо администратором будет отправлен отправителю."
+	}
+	b.sendMessage(ctx, msg.Chat.ID, accountTopicID, i18n.Tf(ctx, connected, emailAddr, imapServer, smtpServer))
+	if autoTopic {
+		b.announceAccountTopic(ctx, msg.Chat.ID, accountTopicID, emailAddr)
+	}
+}
+
+// alreadyConnectedText tells that a mailbox is already connected where msg
+// was sent
+func (b *Bot) alreadyConnectedText(ctx context.Context, msg *models.Message, existing *appmodels.EmailAccount) string {
+	emailAddr := existing.Email
+	if existing.BotID != b.accountBotID() {
+		return i18n.Tf(ctx, "Здесь уже подключена почта %s другого бота этого чата, отключите её через него", emailAddr)
+	}
+	if !msg.Chat.IsForum {
+		return i18n.Tf(ctx, "В этом чате уже подключена почта: %s\nИспользуйте /disconnect для отключения", emailAddr)
+	}
+	return i18n.Tf(ctx, "В этом топике уже подключена почта: %s\nИспользуйте /disconnect для отключения", emailAddr)
 }
 
 // handleCreate handles /create command for Mailcow mailbox creation
@@ -151,36 +197,12 @@ func (b *Bot) handleCreate(ctx context.Context, tgBot *bot.Bot, update *models.U
 		return
 	}
 
-	// Check if it's a supergroup with topics
-	if msg.Chat.Type != "supergroup" {
-		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Эта команда работает только в супергруппах")
-		return
-	}
-
-	if !msg.Chat.IsForum {
-		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Эта команда работает только в супергруппах с топиками")
-		return
-	}
-
-	// Check if user is admin
-	isAdmin, err := b.isUserAdmin(ctx, msg.Chat.ID, msg.From.ID)
-	if err != nil {
-		b.logger.Error("failed to check admin status", "error", err)
-		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка проверки прав")
-		return
-	}
-
-	if !isAdmin {
-		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Только администраторы могут создавать почтовые ящики")
-		return
-	}
-
 	// Parse command: /create local_part [password] [name]
 	parts := strings.Fields(msg.Text)
 	if len(parts) < 2 {
 		domain := b.mailcow.GetDomain()
 		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
-			fmt.Sprintf("Использование: <code>/create username</code>\nИли: <code>/create username password</code>\nИли: <code>/create username password Имя</code>\n\nБудет создан ящик: username@%s", domain))
+			i18n.Tf(ctx, "Использование: <code>/create username</code>\nИли: <code>/create username password</code>\nИли: <code>/create username password Имя</code>\n\nБудет создан ящик: username@%s", domain))
 		return
 	}
 
@@ -204,27 +226,42 @@ func (b *Bot) handleCreate(ctx context.Context, tgBot *bot.Bot, update *models.U
 		}
 	}
 
-	// Check if topic already has an account
-	existing, err := b.db.GetAccountByChatAndTopic(ctx, msg.Chat.ID, topicID)
+	// In General of a chat with /autotopics on the mailbox gets a topic of
+	// its own
+	autoTopic := b.autoTopicEnabled(ctx, msg)
+
+	// Check if topic already has an account, of this bot or another one
+	existing, err := b.db.GetTopicAccount(ctx, msg.Chat.ID, topicID)
 	if err != nil && !errors.Is(err, database.ErrNotFound) {
 		b.logger.Error("failed to check existing account", "error", err)
 		b.sendMessage(ctx, msg.Chat.ID, topicID, "Ошибка проверки существующего подключения")
 		return
 	}
 
-	if existing != nil {
-		b.sendMessage(ctx, msg.Chat.ID, topicID,
-			fmt.Sprintf("В этом топике уже подключена почта: %s\nИспользуйте /disconnect для отключения", existing.Email))
+	if existing != nil && !autoTopic {
+		b.sendMessage(ctx, msg.Chat.ID, topicID, b.alreadyConnectedText(ctx, msg, existing))
 		return
 	}
 
+	accountTopicID := topicID
+	if autoTopic {
+		if accountTopicID, err = b.createAccountTopic(ctx, msg.Chat.ID, localPart+"@"+b.mailcow.GetDomain()); err != nil {
+			b.logger.Error("failed to create account topic", "error", err)
+			b.sendMessage(ctx, msg.Chat.ID, topicID, i18n.Tf(ctx, "Не удалось создать топик для почты: %v", err))
+			return
+		}
+	}
+
 	// Create mailbox in Mailcow
 	b.sendMessage(ctx, msg.Chat.ID, topicID, "Создаю почтовый ящик...")
 
 	mailbox, err := b.mailcow.CreateMailbox(ctx, localPart, name, password, 1024)
 	if err != nil {
 		b.logger.Error("failed to create mailbox", "error", err)
-		b.sendMessage(ctx, msg.Chat.ID, topicID, fmt.Sprintf("Ошибка создания почтового ящика: %v", err))
+		if autoTopic {
+			b.deleteTopic(ctx, msg.Chat.ID, accountTopicID)
+		}
+		b.sendMessage(ctx, msg.Chat.ID, topicID, i18n.Tf(ctx, "Ошибка создания почтового ящика: %v", err))
 		return
 	}
 
@@ -241,16 +278,22 @@ func (b *Bot) handleCreate(ctx context.Context, tgBot *bot.Bot, update *models.U
 
 	// Create account in database
 	account := &appmodels.EmailAccount{
-		Email:      emailAddr,
-		Password:   encryptedPassword,
-		IMAPServer: imapServer,
-		ChatID:     msg.Chat.ID,
-		TopicID:    topicID,
-		IsActive:   true,
-		CreatedBy:  msg.From.ID,
+		Email:       emailAddr,
+		Password:    encryptedPassword,
+		IMAPServer:  imapServer,
+		ChatID:      msg.Chat.ID,
+		TopicID:     accountTopicID,
+		IsActive:    true,
+		CreatedBy:   msg.From.ID,
+		BotID:       b.accountBotID(),
+		Provisioned: true,
 	}
 
 	if err := b.db.CreateAccount(ctx, account); err != nil {
+		if errors.Is(err, database.ErrAlreadyExists) {
+			b.sendMessage(ctx, msg.Chat.ID, topicID, i18n.Tf(ctx, "Почта %s уже подключена в этом чате", emailAddr))
+			return
+		}
 		b.logger.Error("failed to create account", "error", err)
 		b.sendMessage(ctx, msg.Chat.ID, topicID, "Ошибка сохранения аккаунта в базу данных")
 		return
@@ -260,12 +303,13 @@ func (b *Bot) handleCreate(ctx context.Context, tgBot *bot.Bot, update *models.U
 	if err := b.emailManager.AddAccount(ctx, account); err != nil {
 		b.logger.Error("failed to start email client", "error", err)
 		b.db.DeleteAccount(ctx, account.ID)
-		b.sendMessage(ctx, msg.Chat.ID, topicID, fmt.Sprintf("Ошибка запуска подключения: %v", err))
+		b.sendMessage(ctx, msg.Chat.ID, topicID, i18n.Tf(ctx, "Ошибка запуска подключения: %v", err))
 		return
 	}
+	b.wakeStatusBoards()
 
 	// Send success message with credentials
-	credentialsMsg := fmt.Sprintf(
+	credentialsMsg := i18n.Tf(ctx,
 		"Почтовый ящик успешно создан!\n\n"+
 			"<b>Email:</b> <code>%s</code>\n"+
 			"<b>Пароль:</b> <code>%s</code>\n"+
@@ -277,32 +321,25 @@ func (b *Bot) handleCreate(ctx context.Context, tgBot *bot.Bot, update *models.U
 		imapServer,
 		strings.Replace(imapServer, ":993", ":587", 1),
 	)
-	b.sendMessage(ctx, msg.Chat.ID, topicID, credentialsMsg)
+	b.sendMessage(ctx, msg.Chat.ID, accountTopicID, credentialsMsg)
+	if autoTopic {
+		b.announceAccountTopic(ctx, msg.Chat.ID, accountTopicID, emailAddr)
+	}
 }
 
-// handleDisconnect handles /disconnect command
+// handleDisconnect handles /disconnect command. With --purge the mailbox is
+// also deleted from Mailcow if the bot created it, after a confirmation.
+// Usage: /disconnect [--purge]
 func (b *Bot) handleDisconnect(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
 	msg := update.Message
-
-	// Check if user is admin
-	isAdmin, err := b.isUserAdmin(ctx, msg.Chat.ID, msg.From.ID)
-	if err != nil {
-		b.logger.Error("failed to check admin status", "error", err)
-		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка проверки прав")
-		return
-	}
-
-	if !isAdmin {
-		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Только администраторы могут отключать почтовые аккаунты")
-		return
-	}
+	parts := strings.Fields(msg.Text)
 
 	topicID := msg.MessageThreadID
 
 	// Get account
-	account, err := b.db.GetAccountByChatAndTopic(ctx, msg.Chat.ID, topicID)
+	account, err := b.db.GetAccountByChatAndTopic(ctx, msg.Chat.ID, topicID, b.accountBotID())
 	if errors.Is(err, database.ErrNotFound) {
-		b.sendMessage(ctx, msg.Chat.ID, topicID, "В этом топике нет подключенной почты")
+		b.sendMessage(ctx, msg.Chat.ID, topicID, noAccountText(msg))
 		return
 	}
 	if err != nil {
@@ -311,6 +348,21 @@ func (b *Bot) handleDisconnect(ctx context.Context, tgBot *bot.Bot, update *mode
 		return
 	}
 
+	if len(parts) > 1 && parts[1] == "--purge" {
+		b.confirmPurge(ctx, msg, account)
+		return
+	}
+
+	if err := b.disconnectAccount(ctx, account); err != nil {
+		b.sendMessage(ctx, msg.Chat.ID, topicID, "Ошибка удаления аккаунта")
+		return
+	}
+	b.sendMessage(ctx, msg.Chat.ID, topicID,
+		i18n.Tf(ctx, "Почта <b>%s</b> отключена от этого топика", account.Email))
+}
+
+// disconnectAccount stops the email client of an account and deletes it
+func (b *Bot) disconnectAccount(ctx context.Context, account *appmodels.EmailAccount) error {
 	// Stop email client
 	if err := b.emailManager.RemoveAccount(account.ID); err != nil {
 		b.logger.Error("failed to stop email client", "error", err)
@@ -319,50 +371,123 @@ func (b *Bot) handleDisconnect(ctx context.Context, tgBot *bot.Bot, update *mode
 	// Delete from database
 	if err := b.db.DeleteAccount(ctx, account.ID); err != nil {
 		b.logger.Error("failed to delete account", "error", err)
-		b.sendMessage(ctx, msg.Chat.ID, topicID, "Ошибка удаления аккаунта")
-		return
+		return err
 	}
 
-	b.logger.Info("email disconnected", "email", account.Email, "chat_id", msg.Chat.ID, "topic_id", topicID)
-	b.sendMessage(ctx, msg.Chat.ID, topicID,
-		fmt.Sprintf("Почта <b>%s</b> отключена от этого топика", account.Email))
+	b.logger.Info("email disconnected", "email", account.Email, "chat_id", account.ChatID, "topic_id", account.TopicID)
+	b.wakeStatusBoards()
+	return nil
 }
 
+// statusPageSize is the number of accounts per /status page
+const statusPageSize = 10
+
 // handleStatus handles /status command
 func (b *Bot) handleStatus(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
 	msg := update.Message
 
-	// Get all accounts for this chat
-	accounts, err := b.db.GetAccountsByChatID(ctx, msg.Chat.ID)
+	text, keyboard, err := b.renderStatus(ctx, msg.Chat.ID, 0)
 	if err != nil {
-		b.logger.Error("failed to get accounts", "error", err)
+		b.logger.Error("failed to render status", "error", err)
 		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка получения списка аккаунтов")
 		return
 	}
 
-	if len(accounts) == 0 {
-		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "В этой группе нет подключенных почтовых аккаунтов")
+	if keyboard == nil {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, text)
+		return
+	}
+	b.sendMessageWithKeyboard(ctx, msg.Chat.ID, msg.MessageThreadID, text, keyboard, messageOptions{})
+}
+
+// handleStatusPage handles /status page navigation buttons
+func (b *Bot) handleStatusPage(ctx context.Context, callback *models.CallbackQuery, data appmodels.CallbackData) {
+	if callback.Message.Message == nil {
+		b.answerCallback(ctx, callback.ID, "", false)
+		return
+	}
+	chatID := callback.Message.Message.Chat.ID
+
+	page, _ := strconv.Atoi(data.Arg)
+	text, keyboard, err := b.renderStatus(ctx, chatID, page)
+	if err != nil {
+		b.logger.Error("failed to render status", "error", err)
+		b.answerCallback(ctx, callback.ID, "Ошибка получения списка аккаунтов", false)
 		return
 	}
 
+	if err := b.editMessageWithKeyboard(ctx, chatID, callback.Message.Message.ID, text, keyboard, models.ParseModeHTML); err != nil {
+		b.logger.Warn("failed to update status page", "error", err)
+	}
+	b.answerCallback(ctx, callback.ID, "", false)
+}
+
+// renderStatus builds one page of the /status list with navigation buttons
+// (keyboard is nil when everything fits on one page)
+func (b *Bot) renderStatus(ctx context.Context, chatID int64, page int) (string, *models.InlineKeyboardMarkup, error) {
+	total, err := b.db.CountAccountsByChatID(ctx, chatID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if total == 0 {
+		return i18n.T(ctx, "В этой группе нет подключенных почтовых аккаунтов"), nil, nil
+	}
+
+	pages := (total + statusPageSize - 1) / statusPageSize
+	page = max(0, min(page, pages-1))
+
+	accounts, err := b.db.GetAccountsByChatID(ctx, chatID, database.PageN(page, statusPageSize))
+	if err != nil {
+		return "", nil, err
+	}
+
+	settings, err := b.db.GetChatSettings(ctx, chatID)
+	if err != nil {
+		b.logger.Error("failed to get chat settings", "error", err)
+		settings = appmodels.DefaultChatSettings(chatID)
+	}
+	customEmoji := settings.CustomEmojiMap()
+
 	var sb strings.Builder
-	sb.WriteString("<b>Подключенные почтовые аккаунты:</b>\n\n")
+	sb.WriteString(i18n.T(ctx, "<b>Подключенные почтовые аккаунты:</b>"))
+	if pages > 1 {
+		sb.WriteString(i18n.Tf(ctx, " (%d из %d, стр. %d/%d)", len(accounts), total, page+1, pages))
+	}
+	sb.WriteString("\n\n")
 
 	for _, acc := range accounts {
 		status := b.emailManager.GetStatus(acc.ID)
-		statusEmoji := "🔴"
+		if !acc.IsActive {
+			status = "paused"
+		}
+		statusEmoji := formatter.RenderIcon(models.ParseModeHTML, customEmoji, formatter.IconDisconnected)
 		if status == "connected" {
-			statusEmoji = "🟢"
+			statusEmoji = formatter.RenderIcon(models.ParseModeHTML, customEmoji, formatter.IconConnected)
 		} else if status == "reconnecting" {
-			statusEmoji = "🟡"
+			statusEmoji = formatter.RenderIcon(models.ParseModeHTML, customEmoji, formatter.IconReconnecting)
 		}
 
 		sb.WriteString(fmt.Sprintf("%s <b>%s</b>\n", statusEmoji, acc.Email))
-		sb.WriteString(fmt.Sprintf("   Топик ID: %d\n", acc.TopicID))
-		sb.WriteString(fmt.Sprintf("   Статус: %s\n\n", status))
+		sb.WriteString(i18n.Tf(ctx, "   Топик ID: %d\n", acc.TopicID))
+		sb.WriteString(i18n.Tf(ctx, "   Статус: %s\n", status))
+		if retry := b.emailManager.Backoff(acc.ID); retry != nil && acc.IsActive {
+			next := max(time.Until(retry.NextAttempt), 0).Round(time.Second)
+			sb.WriteString(i18n.Tf(ctx, "   Неудачных попыток: %d, следующая через %s\n", retry.Failures, shortDuration(next)))
+			if retry.CircuitOpen {
+				sb.WriteString(i18n.T(ctx, "   ⏸ Переподключения замедлены из-за повторяющихся ошибок\n"))
+			}
+		}
+		if failed, err := b.db.CountFailedMessages(ctx, acc.ID); err == nil && failed > 0 {
+			sb.WriteString(i18n.Tf(ctx, "   ⚠️ Не доставлено в Telegram: %d (/redeliver)\n", failed))
+		}
+		sb.WriteString("\n")
 	}
 
-	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, sb.String())
+	if pages <= 1 {
+		return sb.String(), nil, nil
+	}
+	return sb.String(), formatter.BuildPageKeyboard(appmodels.CallbackStatusPage, 0, page, pages), nil
 }
 
 // handleCallback handles inline button callbacks
@@ -379,6 +504,19 @@ func (b *Bot) handleCallback(ctx context.Context, tgBot *bot.Bot, update *models
 		return
 	}
 
+	// Check if user may use this button
+	allowed, err := b.canUseCallbackAction(ctx, callbackChatID(callback), callback.From.ID, data.Action)
+	if err != nil {
+		b.logger.Error("failed to check callback permissions", "error", err)
+		b.answerCallback(ctx, callback.ID, "Ошибка проверки прав", false)
+		return
+	}
+
+	if !allowed {
+		b.answerCallback(ctx, callback.ID, "Это действие доступно только администраторам группы и операторам бота", true)
+		return
+	}
+
 	switch data.Action {
 	case appmodels.CallbackMarkRead:
 		b.handleMarkRead(ctx, callback, data)
@@ -386,18 +524,68 @@ func (b *Bot) handleCallback(ctx context.Context, tgBot *bot.Bot, update *models
 		b.handleDelete(ctx, callback, data)
 	case appmodels.CallbackCopyCode:
 		b.handleCopyCode(ctx, callback, data)
+	case appmodels.CallbackFetchAtt:
+		b.handleFetchAttachment(ctx, callback, data)
+	case appmodels.CallbackOpenHTML:
+		b.handleOpenHTML(ctx, callback, data)
+	case appmodels.CallbackEML:
+		b.handleDownloadEML(ctx, callback, data)
+	case appmodels.CallbackFullText:
+		b.handleFullText(ctx, callback, data)
+	case appmodels.CallbackStatusPage:
+		b.handleStatusPage(ctx, callback, data)
+	case appmodels.CallbackHistory:
+		b.handleHistoryPage(ctx, callback, data)
+	case appmodels.CallbackOpenEmail:
+		b.handleHistoryOpen(ctx, callback, data)
+	case appmodels.CallbackForgetChat:
+		b.handleForgetConfirm(ctx, callback, data)
+	case appmodels.CallbackSendEmail:
+		b.handleSendConfirm(ctx, callback, data)
+	case appmodels.CallbackPurge:
+		b.handlePurgeConfirm(ctx, callback, data)
+	case appmodels.CallbackRotateKey:
+		b.handleRotateKeyConfirm(ctx, callback, data)
+	case appmodels.CallbackMove:
+		b.handleMoveCallback(ctx, callback, data)
+	case appmodels.CallbackUnsub:
+		b.handleUnsubscribe(ctx, callback, data)
+	case appmodels.CallbackPurgeMail:
+		b.handlePurgeMailConfirm(ctx, callback, data)
 	default:
 		b.answerCallback(ctx, callback.ID, "Неизвестное действие", false)
 	}
 }
 
-// handleMarkRead handles mark as read callback
-func (b *Bot) handleMarkRead(ctx context.Context, callback *models.CallbackQuery, data appmodels.CallbackData) {
-	// Get message from database
+// getCallbackMessage loads the email a callback refers to. If it no longer
+// exists (e.g. purged by retention), the stale keyboard is removed so the
+// message is shown as archived. Returns false if the callback was answered.
+func (b *Bot) getCallbackMessage(ctx context.Context, callback *models.CallbackQuery, data appmodels.CallbackData) (*appmodels.EmailMessage, bool) {
 	msg, err := b.db.GetMessageByID(ctx, data.MessageID)
+	if errors.Is(err, database.ErrNotFound) {
+		b.logger.Debug("callback for purged message", "message_id", data.MessageID)
+		if callback.Message.Message != nil {
+			archived := &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{}}
+			if err := b.editMessageReplyMarkup(ctx, callback.Message.Message.Chat.ID, callback.Message.Message.ID, archived); err != nil {
+				b.logger.Warn("failed to remove stale keyboard", "error", err)
+			}
+		}
+		b.answerCallback(ctx, callback.ID, "Письмо перенесено в архив и больше не хранится в боте", true)
+		return nil, false
+	}
 	if err != nil {
 		b.logger.Error("failed to get message", "error", err)
 		b.answerCallback(ctx, callback.ID, "Сообщение не найдено", false)
+		return nil, false
+	}
+	return msg, true
+}
+
+// handleMarkRead handles mark as read callback
+func (b *Bot) handleMarkRead(ctx context.Context, callback *models.CallbackQuery, data appmodels.CallbackData) {
+	// Get message from database
+	msg, ok := b.getCallbackMessage(ctx, callback, data)
+	if !ok || b.movedAway(ctx, callback, msg) {
 		return
 	}
 
@@ -420,14 +608,16 @@ func (b *Bot) handleMarkRead(ctx context.Context, callback *models.CallbackQuery
 	if err := b.db.MarkMessageAsRead(ctx, msg.ID); err != nil {
 		b.logger.Error("failed to update message", "error", err)
 	}
+	b.unpinCode(ctx, msg.ID)
+	b.wakeUnreadBadges()
 
 	// Update keyboard
-	var codes []appmodels.DetectedCode
-	if msg.DetectedCodes != "" {
-		// Parse codes (simplified, assuming JSON)
-		// In real implementation, unmarshal JSON
+	msg.IsRead = true
+	var truncated bool
+	if callback.Message.Message != nil {
+		truncated = hasCallbackButton(callback.Message.Message.ReplyMarkup, appmodels.CallbackFullText)
 	}
-	keyboard := formatter.BuildEmailKeyboard(msg.ID, codes, true)
+	keyboard := emailKeyboard(ctx, account, msg, decodeCodes(msg.DetectedCodes), truncated)
 	b.editMessageReplyMarkup(ctx, account.ChatID, msg.TelegramMsgID, keyboard)
 
 	b.answerCallback(ctx, callback.ID, "Помечено как прочитанное", false)
@@ -436,10 +626,8 @@ func (b *Bot) handleMarkRead(ctx context.Context, callback *models.CallbackQuery
 // handleDelete handles delete callback
 func (b *Bot) handleDelete(ctx context.Context, callback *models.CallbackQuery, data appmodels.CallbackData) {
 	// Get message from database
-	msg, err := b.db.GetMessageByID(ctx, data.MessageID)
-	if err != nil {
-		b.logger.Error("failed to get message", "error", err)
-		b.answerCallback(ctx, callback.ID, "Сообщение не найдено", false)
+	msg, ok := b.getCallbackMessage(ctx, callback, data)
+	if !ok || b.movedAway(ctx, callback, msg) {
 		return
 	}
 
@@ -462,6 +650,7 @@ func (b *Bot) handleDelete(ctx context.Context, callback *models.CallbackQuery,
 	if err := b.db.MarkMessageAsDeleted(ctx, msg.ID); err != nil {
 		b.logger.Error("failed to update message", "error", err)
 	}
+	b.wakeUnreadBadges()
 
 	// Delete Telegram message
 	b.deleteMessage(ctx, account.ChatID, msg.TelegramMsgID)
@@ -472,21 +661,225 @@ func (b *Bot) handleDelete(ctx context.Context, callback *models.CallbackQuery,
 // handleCopyCode handles copy code callback
 func (b *Bot) handleCopyCode(ctx context.Context, callback *models.CallbackQuery, data appmodels.CallbackData) {
 	// Get message from database
-	msg, err := b.db.GetMessageByID(ctx, data.MessageID)
-	if err != nil {
-		b.logger.Error("failed to get message", "error", err)
-		b.answerCallback(ctx, callback.ID, "Сообщение не найдено", false)
+	msg, ok := b.getCallbackMessage(ctx, callback, data)
+	if !ok {
 		return
 	}
 
-	// Parse codes
-	codes := b.codeDetector.DetectCodes(msg.BodyText)
-	if data.CodeIndex >= len(codes) {
+	// Stored codes match the keyboard the button belongs to
+	codes := decodeCodes(msg.DetectedCodes)
+	if data.CodeIndex < 0 || data.CodeIndex >= len(codes) {
 		b.answerCallback(ctx, callback.ID, "Код не найден", false)
 		return
 	}
 
 	code := codes[data.CodeIndex]
 	// Show alert with code (can be copied)
-	b.answerCallback(ctx, callback.ID, fmt.Sprintf("Код: %s", code.Value), true)
+	b.answerCallback(ctx, callback.ID, i18n.Tf(ctx, "Код: %s", code.Value), true)
+}
+
+// handleFetchAttachment downloads an attachment from IMAP and sends it as a reply
+func (b *Bot) handleFetchAttachment(ctx context.Context, callback *models.CallbackQuery, data appmodels.CallbackData) {
+	msg, ok := b.getCallbackMessage(ctx, callback, data)
+	if !ok || b.movedAway(ctx, callback, msg) {
+		return
+	}
+
+	attachments := decodeAttachments(msg.Attachments)
+	if data.CodeIndex < 0 || data.CodeIndex >= len(attachments) {
+		b.answerCallback(ctx, callback.ID, "Вложение не найдено", false)
+		return
+	}
+	att := attachments[data.CodeIndex]
+
+	if att.Size > maxUploadSize {
+		b.answerCallback(ctx, callback.ID, i18n.Tf(ctx, "Вложение слишком большое для Telegram (%s)", formatter.FormatSize(i18n.Lang(ctx), att.Size)), true)
+		return
+	}
+
+	account, err := b.db.GetAccountByID(ctx, msg.AccountID)
+	if err != nil {
+		b.logger.Error("failed to get account", "error", err)
+		b.answerCallback(ctx, callback.ID, "Аккаунт не найден", false)
+		return
+	}
+
+	// Downloading may take a while, answer right away
+	b.answerCallback(ctx, callback.ID, "Загружаю вложение...", false)
+
+	file, err := b.emailManager.FetchAttachment(account.ID, msg.UID, data.CodeIndex)
+	if err != nil {
+		b.logger.Error("failed to fetch attachment", "error", err, "message_id", msg.ID)
+		b.sendMessage(ctx, account.ChatID, account.TopicID, i18n.Tf(ctx, "Не удалось загрузить вложение %s: %v", html.EscapeString(att.Filename), err))
+		return
+	}
+
+	params := &bot.SendDocumentParams{
+		ChatID:          account.ChatID,
+		MessageThreadID: account.TopicID,
+		Document: &models.InputFileUpload{
+			Filename: file.Filename,
+			Data:     bytes.NewReader(file.Data),
+		},
+		ProtectContent: account.ProtectContent,
+	}
+	if msg.TelegramMsgID != 0 {
+		params.ReplyParameters = &models.ReplyParameters{
+			MessageID:                msg.TelegramMsgID,
+			AllowSendingWithoutReply: true,
+		}
+	}
+
+	if _, err := b.sendDocument(ctx, params); err != nil {
+		b.logger.Error("failed to send attachment", "error", err, "message_id", msg.ID)
+	}
+}
+
+// handleOpenHTML sends the original HTML body of an email as a file. The
+// body is sanitized first: the file is opened in a browser, where scripts,
+// forms and javascript: links in a received email would run with no warning.
+func (b *Bot) handleOpenHTML(ctx context.Context, callback *models.CallbackQuery, data appmodels.CallbackData) {
+	msg, ok := b.getCallbackMessage(ctx, callback, data)
+	if !ok {
+		return
+	}
+	if msg.BodyHTML == "" {
+		b.answerCallback(ctx, callback.ID, "У письма нет HTML-версии", false)
+		return
+	}
+
+	account, err := b.db.GetAccountByID(ctx, msg.AccountID)
+	if err != nil {
+		b.logger.Error("failed to get account", "error", err)
+		b.answerCallback(ctx, callback.ID, "Аккаунт не найден", false)
+		return
+	}
+
+	// Answer first, fetching inline images may take a while
+	b.answerCallback(ctx, callback.ID, "", false)
+
+	body, err := parser.SanitizeHTML(b.embedInlineImages(account, msg))
+	if err != nil {
+		b.logger.Error("failed to sanitize html", "error", err, "message_id", msg.ID)
+		b.sendMessage(ctx, account.ChatID, account.TopicID, "Не удалось подготовить оригинал письма")
+		return
+	}
+
+	params := &bot.SendDocumentParams{
+		ChatID:          account.ChatID,
+		MessageThreadID: account.TopicID,
+		Document: &models.InputFileUpload{
+			Filename: fmt.Sprintf("email-%d.html", msg.ID),
+			Data:     strings.NewReader(body),
+		},
+		Caption:        i18n.T(ctx, "Оригинал письма. Скрипты, формы и опасные ссылки удалены"),
+		ProtectContent: account.ProtectContent,
+	}
+	if callback.Message.Message != nil {
+		params.MessageThreadID = callback.Message.Message.MessageThreadID
+	}
+	if msg.TelegramMsgID != 0 {
+		params.ReplyParameters = &models.ReplyParameters{
+			MessageID:                msg.TelegramMsgID,
+			AllowSendingWithoutReply: true,
+		}
+	}
+
+	if _, err := b.sendDocument(ctx, params); err != nil {
+		b.logger.Error("failed to send html", "error", err, "message_id", msg.ID)
+	}
+}
+
+// handleDownloadEML sends the original message as an .eml file for archiving
+// or importing into a mail client. It comes from the raw message archive or,
+// for emails still in INBOX, from the IMAP server, and is archived then.
+func (b *Bot) handleDownloadEML(ctx context.Context, callback *models.CallbackQuery, data appmodels.CallbackData) {
+	msg, ok := b.getCallbackMessage(ctx, callback, data)
+	if !ok {
+		return
+	}
+	if int64(msg.Size) > maxUploadSize {
+		b.answerCallback(ctx, callback.ID, i18n.Tf(ctx, "Письмо слишком большое для Telegram (%s)", formatter.FormatSize(i18n.Lang(ctx), int64(msg.Size))), true)
+		return
+	}
+
+	account, err := b.db.GetAccountByID(ctx, msg.AccountID)
+	if err != nil {
+		b.logger.Error("failed to get account", "error", err)
+		b.answerCallback(ctx, callback.ID, "Аккаунт не найден", false)
+		return
+	}
+
+	var raw []byte
+	if msg.RawKey != "" && b.archive != nil {
+		if raw, err = b.archive.Load(ctx, msg.RawKey); err != nil {
+			b.logger.Warn("failed to load raw message, fetching from server", "error", err, "message_id", msg.ID)
+		}
+	}
+	if raw == nil {
+		if b.movedAway(ctx, callback, msg) {
+			return
+		}
+		// Downloading may take a while, answer right away
+		b.answerCallback(ctx, callback.ID, "Загружаю письмо...", false)
+		if raw, err = b.emailManager.FetchRaw(account.ID, msg.UID); err != nil {
+			b.logger.Error("failed to fetch raw message", "error", err, "message_id", msg.ID)
+			b.sendMessage(ctx, account.ChatID, account.TopicID, i18n.Tf(ctx, "Не удалось загрузить письмо с сервера: %v", err))
+			return
+		}
+		b.archiveRaw(ctx, msg, &email.RawEmail{Raw: raw})
+	} else {
+		b.answerCallback(ctx, callback.ID, "", false)
+	}
+
+	params := &bot.SendDocumentParams{
+		ChatID:          account.ChatID,
+		MessageThreadID: account.TopicID,
+		Document: &models.InputFileUpload{
+			Filename: fmt.Sprintf("email-%d.eml", msg.ID),
+			Data:     bytes.NewReader(raw),
+		},
+		ProtectContent: account.ProtectContent,
+	}
+	if callback.Message.Message != nil {
+		params.MessageThreadID = callback.Message.Message.MessageThreadID
+	}
+	if msg.TelegramMsgID != 0 {
+		params.ReplyParameters = &models.ReplyParameters{
+			MessageID:                msg.TelegramMsgID,
+			AllowSendingWithoutReply: true,
+		}
+	}
+
+	if _, err := b.sendDocument(ctx, params); err != nil {
+		b.logger.Error("failed to send eml", "error", err, "message_id", msg.ID)
+	}
+}
+
+// embedInlineImages returns the HTML body of an email with the images it
+// references by Content-ID embedded from IMAP, or the body as stored if
+// they cannot be fetched
+func (b *Bot) embedInlineImages(account *appmodels.EmailAccount, msg *appmodels.EmailMessage) string {
+	if !strings.Contains(msg.BodyHTML, "cid:") {
+		return msg.BodyHTML
+	}
+
+	images, err := b.emailManager.FetchInlineImages(account.ID, msg.UID)
+	if err != nil {
+		b.logger.Warn("failed to fetch inline images", "error", err, "message_id", msg.ID)
+		return msg.BodyHTML
+	}
+
+	body, err := parser.EmbedImages(msg.BodyHTML, func(contentID string) (string, bool) {
+		image, ok := images[contentID]
+		if !ok {
+			return "", false
+		}
+		return "data:" + image.ContentType + ";base64," + base64.StdEncoding.EncodeToString(image.Data), true
+	})
+	if err != nil {
+		b.logger.Warn("failed to embed inline images", "error", err, "message_id", msg.ID)
+		return msg.BodyHTML
+	}
+	return body
 }
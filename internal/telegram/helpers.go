@@ -2,20 +2,36 @@ package telegram
 
 import (
 	"context"
-	"crypto/aes"
-	"crypto/cipher"
-	"crypto/rand"
-	"encoding/base64"
 	"fmt"
 	"io"
+	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
+
+	"github.com/mixelka/emailresend/internal/i18n"
+	appmodels "github.com/mixelka/emailresend/pkg/models"
 )
 
-// isUserAdmin checks if a user is an admin in the chat
+// isUserAdmin checks if a user is an admin in the chat or was granted bot
+// management there with /grant
 func (b *Bot) isUserAdmin(ctx context.Context, chatID, userID int64) (bool, error) {
+	// A private chat has the ID of the user and no members to look up
+	if chatID == userID {
+		return true, nil
+	}
+
+	granted, err := b.db.IsBotAdmin(ctx, chatID, userID)
+	if err != nil {
+		b.logger.Error("failed to check granted admins", "error", err)
+	}
+	if granted {
+		return true, nil
+	}
+
 	// Use separate context with timeout to avoid blocking
 	apiCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -40,11 +56,97 @@ func (b *Bot) isUserAdmin(ctx context.Context, chatID, userID int64) (bool, erro
 	}
 }
 
+// isChatOwner checks if a user is the creator of the chat
+func (b *Bot) isChatOwner(ctx context.Context, chatID, userID int64) (bool, error) {
+	if chatID == userID {
+		return true, nil
+	}
+
+	apiCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	member, err := b.bot.GetChatMember(apiCtx, &bot.GetChatMemberParams{
+		ChatID: chatID,
+		UserID: userID,
+	})
+	if err != nil {
+		return false, err
+	}
+	return member.Type == models.ChatMemberTypeOwner, nil
+}
+
+// isOperator checks if a user is a configured bot operator
+func (b *Bot) isOperator(userID int64) bool {
+	for _, id := range b.config.OperatorIDs {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// isOwner checks if a user is the bot owner
+func (b *Bot) isOwner(userID int64) bool {
+	return b.config.OwnerID != 0 && b.config.OwnerID == userID
+}
+
+// canUseCallbackAction checks if a user may trigger an inline button action
+func (b *Bot) canUseCallbackAction(ctx context.Context, chatID, userID int64, action appmodels.CallbackAction) (bool, error) {
+	restricted := false
+	for _, a := range b.config.CallbackAdminActions {
+		if appmodels.CallbackAction(a) == action {
+			restricted = true
+			break
+		}
+	}
+	if !restricted || b.isOperator(userID) {
+		return true, nil
+	}
+
+	return b.isUserAdmin(ctx, chatID, userID)
+}
+
+// callbackChatID returns the chat ID of the message a callback belongs to
+func callbackChatID(callback *models.CallbackQuery) int64 {
+	switch {
+	case callback.Message.Message != nil:
+		return callback.Message.Message.Chat.ID
+	case callback.Message.InaccessibleMessage != nil:
+		return callback.Message.InaccessibleMessage.Chat.ID
+	default:
+		return 0
+	}
+}
+
+// topicOf returns the forum topic of a message, 0 outside forums. Match
+// functions run before withoutReplyThreads and must use it instead of
+// MessageThreadID.
+func topicOf(msg *models.Message) int {
+	if !msg.Chat.IsForum {
+		return 0
+	}
+	return msg.MessageThreadID
+}
+
+// hasMessageLinks reports whether messages of a chat can be linked to:
+// only supergroups have t.me/c links
+func hasMessageLinks(chatID int64) bool {
+	return strings.HasPrefix(strconv.FormatInt(chatID, 10), "-100")
+}
+
+// messageLink returns a t.me link to a message of a supergroup
+func messageLink(chatID int64, msgID int) string {
+	return fmt.Sprintf("https://t.me/c/%s/%d", strings.TrimPrefix(strconv.FormatInt(chatID, 10), "-100"), msgID)
+}
+
+// Messages sent by the helpers below are translated into the language of
+// the context (see withLanguage). Texts built with fmt need i18n.Tf.
+
 // sendMessage sends a message to a topic
 func (b *Bot) sendMessage(ctx context.Context, chatID int64, topicID int, text string) (*models.Message, error) {
 	params := &bot.SendMessageParams{
 		ChatID:    chatID,
-		Text:      text,
+		Text:      i18n.T(ctx, text),
 		ParseMode: models.ParseModeHTML,
 	}
 
@@ -52,23 +154,76 @@ func (b *Bot) sendMessage(ctx context.Context, chatID int64, topicID int, text s
 		params.MessageThreadID = topicID
 	}
 
-	return b.bot.SendMessage(ctx, params)
+	return b.send(ctx, params)
+}
+
+// send posts a message in the send queue of its chat
+func (b *Bot) send(ctx context.Context, params *bot.SendMessageParams) (*models.Message, error) {
+	var msg *models.Message
+	err := b.sends.do(ctx, chatIDOf(params.ChatID), func() error {
+		var err error
+		msg, err = b.bot.SendMessage(ctx, params)
+		return err
+	})
+	return msg, err
+}
+
+// sendDocument posts a file in the send queue of its chat
+func (b *Bot) sendDocument(ctx context.Context, params *bot.SendDocumentParams) (*models.Message, error) {
+	var msg *models.Message
+	err := b.sends.do(ctx, chatIDOf(params.ChatID), func() error {
+		var err error
+		msg, err = b.bot.SendDocument(ctx, params)
+		return err
+	})
+	return msg, err
+}
+
+// chatIDOf returns the numeric chat ID of a ChatID parameter, 0 for
+// @usernames (they share one send queue)
+func chatIDOf(chatID any) int64 {
+	id, _ := chatID.(int64)
+	return id
+}
+
+// messageOptions optional parameters for outgoing email messages
+type messageOptions struct {
+	ParseMode           models.ParseMode // Defaults to HTML
+	DisableNotification bool             // Deliver silently
+	ProtectContent      bool             // Forbid forwarding and saving
+	ReplyTo             int              // Message of the chat to reply to (0 = none)
+	Preview             string           // Image URL shown small above the text instead of a link preview (empty = none)
 }
 
 // sendMessageWithKeyboard sends a message with inline keyboard
-func (b *Bot) sendMessageWithKeyboard(ctx context.Context, chatID int64, topicID int, text string, keyboard *models.InlineKeyboardMarkup) (*models.Message, error) {
+func (b *Bot) sendMessageWithKeyboard(ctx context.Context, chatID int64, topicID int, text string, keyboard *models.InlineKeyboardMarkup, opts messageOptions) (*models.Message, error) {
+	parseMode := opts.ParseMode
+	if parseMode == "" {
+		parseMode = models.ParseModeHTML
+	}
+
 	params := &bot.SendMessageParams{
-		ChatID:      chatID,
-		Text:        text,
-		ParseMode:   models.ParseModeHTML,
-		ReplyMarkup: keyboard,
+		ChatID:              chatID,
+		Text:                i18n.T(ctx, text),
+		ParseMode:           parseMode,
+		DisableNotification: opts.DisableNotification,
+		ProtectContent:      opts.ProtectContent,
+	}
+	if opts.ReplyTo != 0 {
+		params.ReplyParameters = &models.ReplyParameters{MessageID: opts.ReplyTo, AllowSendingWithoutReply: true}
+	}
+	if opts.Preview != "" {
+		params.LinkPreviewOptions = imagePreview(opts.Preview)
+	}
+	if keyboard != nil {
+		params.ReplyMarkup = translateKeyboard(ctx, keyboard)
 	}
 
 	if topicID != 0 {
 		params.MessageThreadID = topicID
 	}
 
-	return b.bot.SendMessage(ctx, params)
+	return b.send(ctx, params)
 }
 
 // deleteMessage deletes a message
@@ -82,77 +237,163 @@ func (b *Bot) deleteMessage(ctx context.Context, chatID int64, msgID int) error
 
 // editMessageReplyMarkup edits the reply markup of a message
 func (b *Bot) editMessageReplyMarkup(ctx context.Context, chatID int64, msgID int, keyboard *models.InlineKeyboardMarkup) error {
-	_, err := b.bot.EditMessageReplyMarkup(ctx, &bot.EditMessageReplyMarkupParams{
-		ChatID:      chatID,
-		MessageID:   msgID,
-		ReplyMarkup: keyboard,
+	if keyboard == nil {
+		// An empty keyboard removes the buttons
+		keyboard = &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{}}
+	}
+	return b.sends.do(ctx, chatID, func() error {
+		_, err := b.bot.EditMessageReplyMarkup(ctx, &bot.EditMessageReplyMarkupParams{
+			ChatID:      chatID,
+			MessageID:   msgID,
+			ReplyMarkup: translateKeyboard(ctx, keyboard),
+		})
+		return err
 	})
-	return err
 }
 
-// answerCallback answers a callback query
-func (b *Bot) answerCallback(ctx context.Context, callbackID, text string, showAlert bool) error {
-	_, err := b.bot.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
-		CallbackQueryID: callbackID,
-		Text:            text,
-		ShowAlert:       showAlert,
+// translateKeyboard returns a copy of an inline keyboard with the button
+// labels translated into the language of ctx
+func translateKeyboard(ctx context.Context, keyboard *models.InlineKeyboardMarkup) *models.InlineKeyboardMarkup {
+	if i18n.Lang(ctx) == i18n.Default {
+		return keyboard
+	}
+	rows := make([][]models.InlineKeyboardButton, len(keyboard.InlineKeyboard))
+	for i, row := range keyboard.InlineKeyboard {
+		rows[i] = make([]models.InlineKeyboardButton, len(row))
+		for j, button := range row {
+			button.Text = i18n.T(ctx, button.Text)
+			rows[i][j] = button
+		}
+	}
+	return &models.InlineKeyboardMarkup{InlineKeyboard: rows}
+}
+
+// editMessageText edits the text of a message
+func (b *Bot) editMessageText(ctx context.Context, chatID int64, msgID int, text string) error {
+	params := &bot.EditMessageTextParams{
+		ChatID:    chatID,
+		MessageID: msgID,
+		Text:      i18n.T(ctx, text),
+		ParseMode: models.ParseModeHTML,
+	}
+	return b.sends.do(ctx, chatID, func() error {
+		_, err := b.bot.EditMessageText(ctx, params)
+		return err
 	})
-	return err
 }
 
-// encryptPassword encrypts a password using AES-256-GCM
-func (b *Bot) encryptPassword(password string) (string, error) {
-	key := []byte(b.config.EncryptionKey)
+// editMessageWithKeyboard replaces the text and inline keyboard of a message
+func (b *Bot) editMessageWithKeyboard(ctx context.Context, chatID int64, msgID int, text string, keyboard *models.InlineKeyboardMarkup, parseMode models.ParseMode) error {
+	return b.editMessageWithPreview(ctx, chatID, msgID, text, keyboard, parseMode, "")
+}
 
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return "", fmt.Errorf("failed to create cipher: %w", err)
+// editMessageWithPreview edits the text and inline keyboard of a message,
+// keeping an image sent with messageOptions.Preview (empty = none)
+func (b *Bot) editMessageWithPreview(ctx context.Context, chatID int64, msgID int, text string, keyboard *models.InlineKeyboardMarkup, parseMode models.ParseMode, preview string) error {
+	if parseMode == "" {
+		parseMode = models.ParseModeHTML
 	}
-
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return "", fmt.Errorf("failed to create GCM: %w", err)
+	params := &bot.EditMessageTextParams{
+		ChatID:    chatID,
+		MessageID: msgID,
+		Text:      i18n.T(ctx, text),
+		ParseMode: parseMode,
 	}
-
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
-		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	if preview != "" {
+		params.LinkPreviewOptions = imagePreview(preview)
+	}
+	if keyboard != nil {
+		params.ReplyMarkup = translateKeyboard(ctx, keyboard)
 	}
+	return b.sends.do(ctx, chatID, func() error {
+		_, err := b.bot.EditMessageText(ctx, params)
+		return err
+	})
+}
 
-	ciphertext := gcm.Seal(nonce, nonce, []byte(password), nil)
-	return base64.StdEncoding.EncodeToString(ciphertext), nil
+// imagePreview shows an image small above the text of a message
+func imagePreview(url string) *models.LinkPreviewOptions {
+	return &models.LinkPreviewOptions{
+		URL:              &url,
+		PreferSmallMedia: bot.True(),
+		ShowAboveText:    bot.True(),
+	}
 }
 
-// decryptPassword decrypts a password
-func (b *Bot) decryptPassword(encrypted string) (string, error) {
-	key := []byte(b.config.EncryptionKey)
+// downloadFile downloads a file sent to the bot, up to maxSize bytes
+func (b *Bot) downloadFile(ctx context.Context, fileID string, maxSize int64) ([]byte, error) {
+	file, err := b.bot.GetFile(ctx, &bot.GetFileParams{FileID: fileID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file: %w", err)
+	}
 
-	data, err := base64.StdEncoding.DecodeString(encrypted)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.bot.FileDownloadLink(file), nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to decode: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	block, err := aes.NewCipher(key)
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to create cipher: %w", err)
+		return nil, fmt.Errorf("failed to download file: %w", err)
 	}
+	defer resp.Body.Close()
 
-	gcm, err := cipher.NewGCM(block)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download file: status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
 	if err != nil {
-		return "", fmt.Errorf("failed to create GCM: %w", err)
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("file is too large (max %d bytes)", maxSize)
 	}
 
-	if len(data) < gcm.NonceSize() {
-		return "", fmt.Errorf("ciphertext too short")
+	return data, nil
+}
+
+// checkAdmin checks that the sender of a command is a chat admin or a bot
+// operator, or that /permissions opened the command to them, replying with
+// an error otherwise
+func (b *Bot) checkAdmin(ctx context.Context, msg *models.Message, denyText string) bool {
+	if permissionGranted(ctx) || b.isOperator(msg.From.ID) {
+		return true
 	}
 
-	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
-	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	isAdmin, err := b.isUserAdmin(ctx, msg.Chat.ID, msg.From.ID)
 	if err != nil {
-		return "", fmt.Errorf("failed to decrypt: %w", err)
+		b.logger.Error("failed to check admin status", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка проверки прав")
+		return false
 	}
 
-	return string(plaintext), nil
+	if !isAdmin {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, denyText)
+		return false
+	}
+
+	return true
+}
+
+// answerCallback answers a callback query
+func (b *Bot) answerCallback(ctx context.Context, callbackID, text string, showAlert bool) error {
+	_, err := b.bot.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: callbackID,
+		Text:            i18n.T(ctx, text),
+		ShowAlert:       showAlert,
+	})
+	return err
+}
+
+// encryptPassword encrypts a password with the configured secrets backend
+func (b *Bot) encryptPassword(password string) (string, error) {
+	return b.crypter.Encrypt(password)
+}
+
+// decryptPassword decrypts a password
+func (b *Bot) decryptPassword(encrypted string) (string, error) {
+	return b.crypter.Decrypt(encrypted)
 }
 
 // DecryptPasswordFunc returns a function for decrypting passwords
@@ -0,0 +1,258 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/mixelka/emailresend/internal/database"
+	"github.com/mixelka/emailresend/internal/formatter"
+	"github.com/mixelka/emailresend/internal/i18n"
+	appmodels "github.com/mixelka/emailresend/pkg/models"
+)
+
+const (
+	// historyPageSize is the number of emails per /history page
+	historyPageSize = 10
+	// historySubjectLength caps the subjects listed by /history
+	historySubjectLength = 60
+)
+
+// handleHistory handles /history command
+func (b *Bot) handleHistory(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	account, ok := b.getTopicAccount(ctx, msg)
+	if !ok {
+		return
+	}
+
+	text, keyboard, err := b.renderHistory(ctx, account, 0)
+	if err != nil {
+		b.logger.Error("failed to render history", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка получения писем")
+		return
+	}
+
+	if keyboard == nil {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, text)
+		return
+	}
+	b.sendMessageWithKeyboard(ctx, msg.Chat.ID, msg.MessageThreadID, text, keyboard, messageOptions{})
+}
+
+// handleHistoryPage handles /history page navigation buttons
+func (b *Bot) handleHistoryPage(ctx context.Context, callback *models.CallbackQuery, data appmodels.CallbackData) {
+	if callback.Message.Message == nil {
+		b.answerCallback(ctx, callback.ID, "", false)
+		return
+	}
+	chatID := callback.Message.Message.Chat.ID
+
+	account, err := b.db.GetAccountByID(ctx, data.MessageID)
+	if err != nil || account.ChatID != chatID {
+		b.answerCallback(ctx, callback.ID, "Аккаунт не найден", false)
+		return
+	}
+
+	page, _ := strconv.Atoi(data.Arg)
+	text, keyboard, err := b.renderHistory(ctx, account, page)
+	if err != nil {
+		b.logger.Error("failed to render history", "error", err)
+		b.answerCallback(ctx, callback.ID, "Ошибка получения писем", false)
+		return
+	}
+
+	if err := b.editMessageWithKeyboard(ctx, chatID, callback.Message.Message.ID, text, keyboard, models.ParseModeHTML); err != nil {
+		b.logger.Warn("failed to update history page", "error", err)
+	}
+	b.answerCallback(ctx, callback.ID, "", false)
+}
+
+// renderHistory builds one page of the stored emails of an account, newest
+// first, with a button to re-open each email and navigation buttons
+func (b *Bot) renderHistory(ctx context.Context, account *appmodels.EmailAccount, page int) (string, *models.InlineKeyboardMarkup, error) {
+	total, err := b.db.CountMessagesByAccount(ctx, account.ID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if total == 0 {
+		return i18n.Tf(ctx, "Для %s нет сохранённых писем", html.EscapeString(account.Email)), nil, nil
+	}
+
+	pages := (total + historyPageSize - 1) / historyPageSize
+	page = max(0, min(page, pages-1))
+
+	messages, err := b.db.GetMessagesPage(ctx, account.ID, database.PageN(page, historyPageSize))
+	if err != nil {
+		return "", nil, err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(i18n.Tf(ctx, "<b>Письма %s:</b>", html.EscapeString(account.Email)))
+	if pages > 1 {
+		sb.WriteString(i18n.Tf(ctx, " (%d, стр. %d/%d)", total, page+1, pages))
+	}
+	sb.WriteString("\n\n")
+
+	ids := make([]int64, len(messages))
+	for i, m := range messages {
+		status := "🔵"
+		if m.IsRead {
+			status = "⚪️"
+		}
+		sb.WriteString(fmt.Sprintf("%d. %s %s\n   %s\n", page*historyPageSize+i+1, status,
+			emailListSubject(ctx, account.ChatID, m), emailListSender(m)))
+		ids[i] = m.ID
+	}
+	sb.WriteString(i18n.T(ctx, "\n🔵 — не прочитано. Нажмите номер, чтобы открыть письмо целиком."))
+
+	keyboard := openEmailKeyboard(page*historyPageSize+1, ids)
+	if pages > 1 {
+		nav := formatter.BuildPageKeyboard(appmodels.CallbackHistory, account.ID, page, pages)
+		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, nav.InlineKeyboard...)
+	}
+	return sb.String(), keyboard, nil
+}
+
+// searchEmails lists the emails of an account matching a query with a
+// snippet of each match and buttons to open them
+func (b *Bot) searchEmails(ctx context.Context, msg *models.Message, account *appmodels.EmailAccount, query string) {
+	results, err := b.db.SearchMessages(ctx, account.ID, query, searchResultLimit)
+	if err != nil {
+		b.logger.Error("failed to search messages", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка поиска")
+		return
+	}
+	if len(results) == 0 {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Письма не найдены")
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(i18n.Tf(ctx, "<b>Письма по запросу «%s»:</b>\n\n", html.EscapeString(query)))
+	ids := make([]int64, len(results))
+	for i, r := range results {
+		sb.WriteString(fmt.Sprintf("%d. %s\n   %s\n", i+1, emailListSubject(ctx, account.ChatID, &r.EmailMessage), emailListSender(&r.EmailMessage)))
+		if r.Snippet != "" {
+			sb.WriteString("   <i>" + snippetMarkup.Replace(html.EscapeString(r.Snippet)) + "</i>\n")
+		}
+		ids[i] = r.ID
+	}
+	if len(results) == searchResultLimit {
+		sb.WriteString(i18n.Tf(ctx, "\nПоказаны последние %d совпадений, уточните запрос", searchResultLimit))
+	}
+
+	b.sendMessageWithKeyboard(ctx, msg.Chat.ID, msg.MessageThreadID, sb.String(), openEmailKeyboard(1, ids), messageOptions{})
+}
+
+// snippetMarkup highlights the matched terms of a search snippet
+var snippetMarkup = strings.NewReplacer(database.SnippetStart, "<b>", database.SnippetEnd, "</b>")
+
+// emailListSubject renders the subject of an email in a list, linked to its
+// post if it has one
+func emailListSubject(ctx context.Context, chatID int64, m *appmodels.EmailMessage) string {
+	subject := m.Subject
+	if subject == "" {
+		subject = i18n.T(ctx, "(без темы)")
+	}
+	if runes := []rune(subject); len(runes) > historySubjectLength {
+		subject = string(runes[:historySubjectLength]) + "…"
+	}
+	subject = html.EscapeString(subject)
+	if m.TelegramMsgID != 0 && hasMessageLinks(chatID) {
+		subject = fmt.Sprintf(`<a href="%s">%s</a>`, messageLink(chatID, m.TelegramMsgID), subject)
+	}
+	return subject
+}
+
+// emailListSender renders the sender and date of an email in a list
+func emailListSender(m *appmodels.EmailMessage) string {
+	sender := m.FromName
+	if sender == "" {
+		sender = m.FromAddr
+	}
+	return fmt.Sprintf("%s · %s", html.EscapeString(sender), m.CreatedAt.Format("02.01 15:04"))
+}
+
+// openEmailKeyboard numbers buttons opening the emails from first on, five
+// per row
+func openEmailKeyboard(first int, ids []int64) *models.InlineKeyboardMarkup {
+	keyboard := &models.InlineKeyboardMarkup{}
+	var row []models.InlineKeyboardButton
+	for i, id := range ids {
+		row = append(row, models.InlineKeyboardButton{
+			Text:         strconv.Itoa(first + i),
+			CallbackData: formatter.EncodeCallback(appmodels.CallbackData{Action: appmodels.CallbackOpenEmail, MessageID: id}),
+		})
+		if len(row) == 5 || i == len(ids)-1 {
+			keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, row)
+			row = nil
+		}
+	}
+	return keyboard
+}
+
+// handleHistoryOpen sends a stored email again in full, as a reply to its
+// original message if that still exists
+func (b *Bot) handleHistoryOpen(ctx context.Context, callback *models.CallbackQuery, data appmodels.CallbackData) {
+	msg, ok := b.getCallbackMessage(ctx, callback, data)
+	if !ok {
+		return
+	}
+
+	account, err := b.db.GetAccountByID(ctx, msg.AccountID)
+	if err != nil || account.ChatID != callbackChatID(callback) {
+		b.answerCallback(ctx, callback.ID, "Аккаунт не найден", false)
+		return
+	}
+
+	settings, err := b.db.GetChatSettings(ctx, account.ChatID)
+	if err != nil {
+		b.logger.Error("failed to get chat settings", "error", err)
+		settings = appmodels.DefaultChatSettings(account.ChatID)
+	}
+	if msg.BodyTrimmed {
+		b.answerCallback(ctx, callback.ID, "Текст письма удалён из-за лимита хранилища чата", false)
+	} else {
+		b.answerCallback(ctx, callback.ID, "", false)
+	}
+
+	parseMode := models.ParseMode(settings.ParseMode)
+	codes := decodeCodes(msg.DetectedCodes)
+	text, truncated := b.formatter.FormatEmail(msg, codes, formatter.FormatOptions{
+		ParseMode:   parseMode,
+		CustomEmoji: settings.CustomEmojiMap(),
+		Profile:     formatter.ProfileDetailed,
+		Language:    i18n.Lang(ctx),
+	})
+
+	topicID := account.TopicID
+	if callback.Message.Message != nil {
+		topicID = callback.Message.Message.MessageThreadID
+	}
+	params := &bot.SendMessageParams{
+		ChatID:          account.ChatID,
+		MessageThreadID: topicID,
+		Text:            text,
+		ParseMode:       parseMode,
+		ReplyMarkup:     emailKeyboard(ctx, account, msg, codes, truncated),
+		ProtectContent:  account.ProtectContent,
+	}
+	if msg.TelegramMsgID != 0 {
+		params.ReplyParameters = &models.ReplyParameters{
+			MessageID:                msg.TelegramMsgID,
+			AllowSendingWithoutReply: true,
+		}
+	}
+
+	if _, err := b.send(ctx, params); err != nil {
+		b.logger.Error("failed to send email", "error", err, "message_id", msg.ID)
+	}
+}
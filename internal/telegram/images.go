@@ -0,0 +1,219 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/mixelka/emailresend/internal/email"
+	"github.com/mixelka/emailresend/internal/i18n"
+	"github.com/mixelka/emailresend/internal/parser"
+	appmodels "github.com/mixelka/emailresend/pkg/models"
+)
+
+const (
+	// maxImageSize is the largest image downloaded from the web (Telegram
+	// takes photos up to 10 MB)
+	maxImageSize = 5 << 20
+	// imageFetchTimeout limits the download of one remote image
+	imageFetchTimeout = 10 * time.Second
+)
+
+// photoTypes are the image types Telegram accepts as photos
+var photoTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/webp": true,
+}
+
+// imageClient downloads remote images of emails. It refuses private and
+// loopback addresses so an email cannot make the bot probe the local network.
+var imageClient = &http.Client{
+	Timeout: imageFetchTimeout,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: imageFetchTimeout,
+			Control: func(network, address string, c syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				ip := net.ParseIP(host)
+				if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() || ip.IsMulticast() {
+					return fmt.Errorf("address %s is not public", host)
+				}
+				return nil
+			},
+		}).DialContext,
+		TLSHandshakeTimeout:   imageFetchTimeout,
+		ResponseHeaderTimeout: imageFetchTimeout,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 3 {
+			return errors.New("too many redirects")
+		}
+		return nil
+	},
+}
+
+// deliverImages posts the first images of an HTML email as an album replying
+// to its message. Albums cannot carry a keyboard, so the email text stays in
+// its own message and the album is captioned with the subject. Failures are
+// only logged: the email itself was delivered.
+func (b *Bot) deliverImages(ctx context.Context, account *appmodels.EmailAccount, msg *appmodels.EmailMessage, topicID, replyTo int, opts messageOptions) {
+	limit := b.config.InlineImages
+	if limit <= 0 || msg.BodyHTML == "" {
+		return
+	}
+
+	images := b.collectImages(ctx, account, msg, limit)
+	if len(images) == 0 {
+		return
+	}
+
+	caption := "🖼 " + html.EscapeString(msg.Subject)
+	if msg.Subject == "" {
+		caption = "🖼 " + i18n.T(ctx, "Изображения из письма")
+	}
+
+	reply := &models.ReplyParameters{MessageID: replyTo, AllowSendingWithoutReply: true}
+	err := b.sends.do(ctx, account.ChatID, func() error {
+		if len(images) == 1 {
+			_, err := b.bot.SendPhoto(ctx, &bot.SendPhotoParams{
+				ChatID:              account.ChatID,
+				MessageThreadID:     topicID,
+				Photo:               &models.InputFileUpload{Filename: images[0].Filename, Data: bytes.NewReader(images[0].Data)},
+				Caption:             caption,
+				ParseMode:           models.ParseModeHTML,
+				DisableNotification: true,
+				ProtectContent:      opts.ProtectContent,
+				ReplyParameters:     reply,
+			})
+			return err
+		}
+
+		media := make([]models.InputMedia, 0, len(images))
+		for i, image := range images {
+			photo := &models.InputMediaPhoto{
+				Media:           "attach://" + image.Filename,
+				MediaAttachment: bytes.NewReader(image.Data),
+			}
+			if i == 0 {
+				photo.Caption = caption
+				photo.ParseMode = models.ParseModeHTML
+			}
+			media = append(media, photo)
+		}
+		_, err := b.bot.SendMediaGroup(ctx, &bot.SendMediaGroupParams{
+			ChatID:              account.ChatID,
+			MessageThreadID:     topicID,
+			Media:               media,
+			DisableNotification: true,
+			ProtectContent:      opts.ProtectContent,
+			ReplyParameters:     reply,
+		})
+		return err
+	})
+	if err != nil {
+		b.logger.Warn("failed to send email images", "error", err, "message_id", msg.ID, "images", len(images))
+	}
+}
+
+// collectImages returns up to limit photos referenced by the HTML body of an
+// email: attached ones (cid:) from IMAP and, unless disabled, remote ones
+func (b *Bot) collectImages(ctx context.Context, account *appmodels.EmailAccount, msg *appmodels.EmailMessage, limit int) []*email.AttachmentData {
+	refs := parser.Images(msg.BodyHTML)
+	if len(refs) == 0 {
+		return nil
+	}
+
+	var inline map[string]*email.AttachmentData
+	for _, ref := range refs {
+		if ref.ContentID == "" {
+			continue
+		}
+		var err error
+		if inline, err = b.emailManager.FetchInlineImages(account.ID, msg.UID); err != nil {
+			b.logger.Warn("failed to fetch inline images", "error", err, "message_id", msg.ID)
+		}
+		break
+	}
+
+	var images []*email.AttachmentData
+	for _, ref := range refs {
+		if len(images) == limit {
+			break
+		}
+
+		var image *email.AttachmentData
+		switch {
+		case ref.ContentID != "":
+			image = inline[ref.ContentID]
+		case b.config.InlineImagesRemote:
+			var err error
+			if image, err = fetchImage(ctx, ref.URL); err != nil {
+				b.logger.Debug("failed to download email image", "error", err, "message_id", msg.ID)
+				continue
+			}
+		}
+		if image == nil || !photoTypes[image.ContentType] {
+			continue
+		}
+
+		// Attachment names must be unique within an album
+		images = append(images, &email.AttachmentData{
+			Filename:    "image" + strconv.Itoa(len(images)),
+			ContentType: image.ContentType,
+			Data:        image.Data,
+		})
+	}
+	return images
+}
+
+// fetchImage downloads a remote image of an email
+func fetchImage(ctx context.Context, url string) (*email.AttachmentData, error) {
+	ctx, cancel := context.WithTimeout(ctx, imageFetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0")
+
+	resp, err := imageClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	contentType, _, _ := strings.Cut(resp.Header.Get("Content-Type"), ";")
+	contentType = strings.ToLower(strings.TrimSpace(contentType))
+	if !photoTypes[contentType] {
+		return nil, fmt.Errorf("unsupported content type %q", contentType)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImageSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxImageSize {
+		return nil, fmt.Errorf("image is larger than %d bytes", maxImageSize)
+	}
+	return &email.AttachmentData{ContentType: contentType, Data: data}, nil
+}
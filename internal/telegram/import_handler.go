@@ -0,0 +1,302 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/mixelka/emailresend/internal/database"
+	"github.com/mixelka/emailresend/internal/email"
+	"github.com/mixelka/emailresend/internal/i18n"
+	"github.com/mixelka/emailresend/internal/importer"
+	"github.com/mixelka/emailresend/internal/oauth"
+	appmodels "github.com/mixelka/emailresend/pkg/models"
+)
+
+const (
+	// importMaxFileSize limits the size of uploaded import files
+	importMaxFileSize = 1 << 20
+	// importConcurrency is the number of connections validated in parallel
+	importConcurrency = 4
+	// importProgressInterval limits how often the progress message is edited
+	importProgressInterval = 2 * time.Second
+	// importReportMaxLen keeps the import report below Telegram's 4096
+	// character limit; longer reports are also sent as a file
+	importReportMaxLen = 4000
+)
+
+// importResult is the outcome of importing a single record
+type importResult struct {
+	record importer.Record
+	err    error
+}
+
+// matchImport matches documents sent with an /import caption
+func (b *Bot) matchImport(update *models.Update) bool {
+	if update.Message == nil || update.Message.Document == nil {
+		return false
+	}
+	cmd, ok := b.parseCommand(update.Message, update.Message.Caption)
+	return ok && cmd == "import"
+}
+
+// handleImport handles /import sent as a caption to a CSV/JSON document
+// File columns: email, password, topic_id, [imap_server]
+func (b *Bot) handleImport(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	topicID := msg.MessageThreadID
+
+	// Download before deleting the message: the file contains passwords
+	data, err := b.downloadFile(ctx, msg.Document.FileID, importMaxFileSize)
+	if errDel := b.deleteMessage(ctx, msg.Chat.ID, msg.ID); errDel != nil {
+		b.logger.Warn("failed to delete import message", "error", errDel)
+	}
+	if err != nil {
+		b.logger.Error("failed to download import file", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, topicID, i18n.Tf(ctx, "Ошибка загрузки файла: %v", err))
+		return
+	}
+
+	records, err := importer.Parse(msg.Document.FileName, data)
+	if err != nil {
+		b.sendMessage(ctx, msg.Chat.ID, topicID, i18n.Tf(ctx, "Ошибка разбора файла: %s", html.EscapeString(err.Error())))
+		return
+	}
+
+	if len(records) == 0 {
+		b.sendMessage(ctx, msg.Chat.ID, topicID,
+			"Файл не содержит аккаунтов\n\nФормат CSV: <code>email,password,topic_id,imap_server</code>\nФормат JSON: <code>[{\"email\": \"...\", \"password\": \"...\", \"topic_id\": 2}]</code>")
+		return
+	}
+
+	progressMsg, err := b.sendMessage(ctx, msg.Chat.ID, topicID, i18n.Tf(ctx, "Импорт: 0/%d...", len(records)))
+	if err != nil {
+		b.logger.Error("failed to send progress message", "error", err)
+		return
+	}
+
+	results := b.importRecords(ctx, msg.Chat.ID, msg.From.ID, records, func(done int) {
+		b.editMessageText(ctx, msg.Chat.ID, progressMsg.ID, i18n.Tf(ctx, "Импорт: %d/%d...", done, len(records)))
+	})
+
+	b.sendImportReport(ctx, msg.Chat.ID, topicID, progressMsg.ID, results)
+}
+
+// importRecords validates and creates accounts with bounded concurrency,
+// calling onProgress (throttled) as records complete
+func (b *Bot) importRecords(ctx context.Context, chatID, userID int64, records []importer.Record, onProgress func(done int)) []importResult {
+	results := make([]importResult, len(records))
+	sem := make(chan struct{}, importConcurrency)
+
+	var (
+		wg         sync.WaitGroup
+		mu         sync.Mutex
+		done       int
+		lastReport time.Time
+	)
+
+	for i, rec := range records {
+		wg.Add(1)
+		go func(i int, rec importer.Record) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			results[i] = importResult{record: rec, err: b.importRecord(ctx, chatID, userID, rec)}
+
+			mu.Lock()
+			done++
+			report := time.Since(lastReport) >= importProgressInterval
+			if report {
+				lastReport = time.Now()
+			}
+			current := done
+			mu.Unlock()
+
+			if report {
+				onProgress(current)
+			}
+		}(i, rec)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// importRecord validates the connection and creates a single account
+func (b *Bot) importRecord(ctx context.Context, chatID, userID int64, rec importer.Record) error {
+	if err := rec.Validate(); err != nil {
+		return err
+	}
+
+	// Check if topic already has an account, of this bot or another one
+	existing, err := b.db.GetTopicAccount(ctx, chatID, rec.TopicID)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		return fmt.Errorf("failed to check topic: %w", err)
+	}
+	if existing != nil {
+		return fmt.Errorf("topic %d already has %s", rec.TopicID, existing.Email)
+	}
+
+	imapServer, smtpServer := rec.IMAPServer, ""
+	if imapServer == "" {
+		servers, err := email.ResolveServers(ctx, rec.Email)
+		if err != nil {
+			return fmt.Errorf("failed to resolve IMAP server: %w", err)
+		}
+		imapServer, smtpServer = servers.IMAP, servers.SMTP
+	}
+
+	var token *oauth.Token
+	if rec.RefreshToken != "" {
+		provider, ok := b.oauthProvider(rec.OAuthProvider)
+		if !ok {
+			return fmt.Errorf("OAuth provider %s is not configured", rec.OAuthProvider)
+		}
+		if token, err = provider.Refresh(ctx, rec.RefreshToken); err != nil {
+			return err
+		}
+		if err := b.emailManager.TestOAuthConnection(ctx, rec.Email, token.AccessToken, imapServer); err != nil {
+			return err
+		}
+	} else if err := b.emailManager.TestConnection(ctx, rec.Email, rec.Password, imapServer); err != nil {
+		return err
+	}
+
+	encryptedPassword, err := b.encryptPassword(rec.Password)
+	if err != nil {
+		return err
+	}
+
+	account := &appmodels.EmailAccount{
+		Email:      rec.Email,
+		Password:   encryptedPassword,
+		IMAPServer: imapServer,
+		SMTPServer: smtpServer,
+		ChatID:     chatID,
+		TopicID:    rec.TopicID,
+		IsActive:   true,
+		CreatedBy:  userID,
+		BotID:      b.accountBotID(),
+	}
+
+	if err := b.db.CreateAccount(ctx, account); err != nil {
+		if errors.Is(err, database.ErrAlreadyExists) {
+			return fmt.Errorf("%s is already connected in this chat", rec.Email)
+		}
+		return err
+	}
+
+	if token != nil {
+		if _, err := b.saveOAuthToken(ctx, account.ID, rec.OAuthProvider, token, rec.RefreshToken); err != nil {
+			b.db.DeleteAccount(ctx, account.ID)
+			return err
+		}
+	}
+
+	if err := b.emailManager.AddAccount(ctx, account); err != nil {
+		b.db.DeleteAccount(ctx, account.ID)
+		return err
+	}
+
+	b.logger.Info("email imported", "email", rec.Email, "chat_id", chatID, "topic_id", rec.TopicID)
+	b.wakeStatusBoards()
+	return nil
+}
+
+// sendImportReport replaces the progress message with the import summary.
+// A summary too long for one message lists only the failures that fit and
+// comes with the full report as a file.
+func (b *Bot) sendImportReport(ctx context.Context, chatID int64, topicID, progressMsgID int, results []importResult) {
+	report, complete := formatImportReport(ctx, results, importReportMaxLen)
+	b.editMessageText(ctx, chatID, progressMsgID, report)
+	if complete {
+		return
+	}
+
+	var sb strings.Builder
+	for _, r := range results {
+		sb.WriteString(importResultLine(ctx, r) + "\n")
+	}
+	params := &bot.SendDocumentParams{
+		ChatID:          chatID,
+		MessageThreadID: topicID,
+		Document: &models.InputFileUpload{
+			Filename: "import-" + time.Now().Format("20060102-150405") + ".txt",
+			Data:     strings.NewReader(sb.String()),
+		},
+		Caption: i18n.T(ctx, "Полный отчёт"),
+	}
+	if _, err := b.sendDocument(ctx, params); err != nil {
+		b.logger.Error("failed to send import report", "error", err)
+	}
+}
+
+// formatImportReport builds the final import summary of at most maxLen
+// bytes. Returns false if lines were left out: successes first, then the
+// failures that do not fit.
+func formatImportReport(ctx context.Context, results []importResult, maxLen int) (string, bool) {
+	var ok, failed []string
+	for _, r := range results {
+		if r.err == nil {
+			ok = append(ok, i18n.Tf(ctx, "🟢 %s → топик %d", html.EscapeString(r.record.Email), r.record.TopicID))
+		} else {
+			failed = append(failed, fmt.Sprintf("🔴 %s: <code>%s</code>", html.EscapeString(importResultLabel(ctx, r)), html.EscapeString(r.err.Error())))
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString(i18n.Tf(ctx, "<b>Импорт завершён:</b> %d успешно, %d с ошибками\n", len(ok), len(failed)))
+	full := sb.Len() + len(strings.Join(ok, "\n")) + len(strings.Join(failed, "\n")) + 4
+	if full <= maxLen {
+		if len(ok) > 0 {
+			sb.WriteString("\n" + strings.Join(ok, "\n") + "\n")
+		}
+		if len(failed) > 0 {
+			sb.WriteString("\n" + strings.Join(failed, "\n") + "\n")
+		}
+		return sb.String(), true
+	}
+
+	// Room for the note on what was left out
+	maxLen -= 200
+	sb.WriteString("\n")
+	shown := 0
+	for _, line := range failed {
+		if sb.Len()+len(line)+1 > maxLen {
+			break
+		}
+		sb.WriteString(line + "\n")
+		shown++
+	}
+	if shown < len(failed) {
+		sb.WriteString(i18n.Tf(ctx, "…и ещё %d с ошибками\n", len(failed)-shown))
+	}
+	sb.WriteString(i18n.T(ctx, "\nПолный отчёт — в файле ниже"))
+	return sb.String(), false
+}
+
+// importResultLine describes the result of one record in plain text
+func importResultLine(ctx context.Context, r importResult) string {
+	if r.err == nil {
+		return i18n.Tf(ctx, "🟢 %s → топик %d", r.record.Email, r.record.TopicID)
+	}
+	return fmt.Sprintf("🔴 %s: %s", importResultLabel(ctx, r), r.err.Error())
+}
+
+// importResultLabel names the record of a result: its email or, if the
+// record has none, its line in the file
+func importResultLabel(ctx context.Context, r importResult) string {
+	if r.record.Email == "" {
+		return i18n.Tf(ctx, "строка %d", r.record.Line)
+	}
+	return r.record.Email
+}
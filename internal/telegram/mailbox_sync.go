@@ -0,0 +1,77 @@
+package telegram
+
+import (
+	"context"
+	"time"
+
+	"github.com/mixelka/emailresend/internal/email"
+	appmodels "github.com/mixelka/emailresend/pkg/models"
+)
+
+const (
+	// stateSyncWindow is how long after posting an email its read and
+	// deleted state in the mailbox is followed
+	stateSyncWindow = 7 * 24 * time.Hour
+	// stateSyncLimit caps the emails per account checked in one sync
+	stateSyncLimit = 200
+)
+
+// trackedMessages returns the recently posted emails of an account that are
+// still in INBOX, for the email manager to check their mailbox state
+func (b *Bot) trackedMessages(ctx context.Context, accountID int64) ([]email.TrackedMessage, error) {
+	messages, err := b.db.GetTrackedMessages(ctx, accountID, time.Now().Add(-stateSyncWindow), stateSyncLimit)
+	if err != nil {
+		return nil, err
+	}
+	tracked := make([]email.TrackedMessage, 0, len(messages))
+	for _, msg := range messages {
+		tracked = append(tracked, email.TrackedMessage{ID: msg.ID, UID: msg.UID, MessageID: msg.MessageID, Read: msg.IsRead})
+	}
+	return tracked, nil
+}
+
+// onMailboxChanges follows emails read or deleted in the mailbox by another
+// client. The database is updated right away so the next sync does not
+// report them again; their Telegram messages are edited in the background
+// to keep the IMAP worker from waiting on Telegram rate limits.
+func (b *Bot) onMailboxChanges(accountID int64, changes []email.StateChange) {
+	ctx := context.Background()
+	account, err := b.db.GetAccountByID(ctx, accountID)
+	if err != nil {
+		b.logger.Error("failed to get account", "error", err, "account_id", accountID)
+		return
+	}
+
+	ids := make([]int64, 0, len(changes))
+	for _, change := range changes {
+		mark := b.db.MarkMessageAsRead
+		if change.Deleted {
+			mark = b.db.MarkMessageAsDeleted
+		}
+		if err := mark(ctx, change.ID); err != nil {
+			b.logger.Error("failed to update message", "error", err, "message_id", change.ID)
+			continue
+		}
+		ids = append(ids, change.ID)
+	}
+	b.wakeUnreadBadges()
+
+	go b.refreshChangedEmails(b.chatContext(ctx, account.ChatID), account, ids)
+}
+
+// refreshChangedEmails redraws the Telegram messages of emails whose mailbox
+// state changed: read emails lose the read button, deleted ones are struck
+// through and lose the buttons acting on the mailbox
+func (b *Bot) refreshChangedEmails(ctx context.Context, account *appmodels.EmailAccount, ids []int64) {
+	for _, id := range ids {
+		msg, err := b.db.GetMessageByID(ctx, id)
+		if err != nil {
+			b.logger.Error("failed to get message", "error", err, "message_id", id)
+			continue
+		}
+		b.unpinCode(ctx, msg.ID)
+		if err := b.refreshEmailMessage(ctx, account, msg, decodeCodes(msg.DetectedCodes)); err != nil {
+			b.logger.Warn("failed to update email message", "error", err, "message_id", msg.ID)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/mixelka/emailresend/internal/i18n"
+	"github.com/mixelka/emailresend/internal/mailexport"
+	appmodels "github.com/mixelka/emailresend/pkg/models"
+)
+
+const (
+	// mailExportBatchSize is the number of messages loaded per step of /export
+	mailExportBatchSize = 200
+	// maxMailExportSize keeps the file below the 50 MB upload limit of bots
+	maxMailExportSize = 45 << 20
+	// mailExportTimeout bounds loading the messages and raw archive of /export
+	mailExportTimeout = 10 * time.Minute
+)
+
+// handleMailExport handles /export command: uploads the stored emails of the
+// topic's account as a file
+// Usage: /export [csv|json|mbox] [from YYYY-MM-DD] [to YYYY-MM-DD]
+func (b *Bot) handleMailExport(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	account, ok := b.getTopicAccount(ctx, msg)
+	if !ok {
+		return
+	}
+
+	if !b.checkAdmin(ctx, msg, "Только администраторы могут выгружать письма") {
+		return
+	}
+
+	format, from, to, ok := parseMailExportArgs(strings.Fields(msg.Text)[1:])
+	if !ok {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+			"Использование: <code>/export [csv|json|mbox] [с ГГГГ-ММ-ДД] [по ГГГГ-ММ-ДД]</code>\n\n"+
+				"Например: <code>/export mbox 2026-01-01 2026-03-31</code>. По умолчанию — CSV со всеми сохранёнными письмами.")
+		return
+	}
+
+	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "⏳ Готовлю выгрузку писем...")
+
+	// Loading raw messages of a large mailbox must not hold an update worker
+	go b.exportMail(b.chatContext(context.Background(), msg.Chat.ID), account, msg.MessageThreadID, format, from, to)
+}
+
+// parseMailExportArgs parses the optional format and dates of /export. The
+// end date is inclusive, so the returned to is the midnight after it.
+func parseMailExportArgs(args []string) (format mailexport.Format, from, to time.Time, ok bool) {
+	format = mailexport.FormatCSV
+	if len(args) > 0 {
+		if f, isFormat := mailexport.ParseFormat(args[0]); isFormat {
+			format = f
+			args = args[1:]
+		}
+	}
+	if len(args) > 2 {
+		return "", time.Time{}, time.Time{}, false
+	}
+
+	var dates []time.Time
+	for _, arg := range args {
+		day, err := time.ParseInLocation(time.DateOnly, arg, time.Local)
+		if err != nil {
+			return "", time.Time{}, time.Time{}, false
+		}
+		dates = append(dates, day)
+	}
+	if len(dates) > 0 {
+		from = dates[0]
+	}
+	if len(dates) > 1 {
+		to = dates[1].AddDate(0, 0, 1)
+		if !to.After(from) {
+			return "", time.Time{}, time.Time{}, false
+		}
+	}
+	return format, from, to, true
+}
+
+// exportMail writes the emails of an account to a file and uploads it to the
+// account's topic. Files stop at maxMailExportSize; the caption says so.
+func (b *Bot) exportMail(ctx context.Context, account *appmodels.EmailAccount, topicID int, format mailexport.Format, from, to time.Time) {
+	ctx, cancel := context.WithTimeout(ctx, mailExportTimeout)
+	defer cancel()
+
+	var buf bytes.Buffer
+	w, err := mailexport.NewWriter(&buf, format)
+	if err != nil {
+		b.logger.Error("failed to create mail export", "error", err)
+		return
+	}
+
+	var (
+		count     int
+		truncated bool
+		afterID   int64
+	)
+	for !truncated {
+		messages, err := b.db.GetMessagesInRange(ctx, account.ID, from, to, afterID, mailExportBatchSize)
+		if err != nil {
+			b.logger.Error("failed to get messages for export", "error", err, "account_id", account.ID)
+			b.sendMessage(ctx, account.ChatID, topicID, "Ошибка получения писем")
+			return
+		}
+		if len(messages) == 0 {
+			break
+		}
+
+		for _, m := range messages {
+			if buf.Len() >= maxMailExportSize {
+				truncated = true
+				break
+			}
+			if err := w.Write(m, b.loadRawForExport(ctx, m, format)); err != nil {
+				b.logger.Error("failed to write mail export", "error", err, "message_id", m.ID)
+				b.sendMessage(ctx, account.ChatID, topicID, "Ошибка записи выгрузки")
+				return
+			}
+			count++
+			afterID = m.ID
+		}
+	}
+	if err := w.Close(); err != nil {
+		b.logger.Error("failed to finish mail export", "error", err)
+		b.sendMessage(ctx, account.ChatID, topicID, "Ошибка записи выгрузки")
+		return
+	}
+
+	if count == 0 {
+		b.sendMessage(ctx, account.ChatID, topicID, "Нет сохранённых писем за этот период")
+		return
+	}
+
+	caption := i18n.Tf(ctx, "📦 Выгрузка <b>%s</b>: %d писем", html.EscapeString(account.Email), count)
+	if period := exportPeriod(ctx, from, to); period != "" {
+		caption += ", " + period
+	}
+	if truncated {
+		caption += "\n" + i18n.Tf(ctx, "⚠️ Файл ограничен %d МБ, выгружены не все письма — укажите более узкий период",
+			maxMailExportSize>>20)
+	}
+
+	filename := fmt.Sprintf("%s-%s%s", account.Email, time.Now().Format("20060102-150405"), format.Extension())
+	if _, err := b.sendDocument(ctx, &bot.SendDocumentParams{
+		ChatID:          account.ChatID,
+		MessageThreadID: topicID,
+		Document: &models.InputFileUpload{
+			Filename: filename,
+			Data:     bytes.NewReader(buf.Bytes()),
+		},
+		Caption:        caption,
+		ParseMode:      models.ParseModeHTML,
+		ProtectContent: account.ProtectContent,
+	}); err != nil {
+		b.logger.Error("failed to send mail export", "error", err, "account_id", account.ID)
+		b.sendMessage(ctx, account.ChatID, topicID, "Не удалось отправить файл выгрузки")
+		return
+	}
+
+	b.logger.Info("mail exported", "account_id", account.ID, "format", format, "messages", count, "bytes", buf.Len(), "truncated", truncated)
+}
+
+// loadRawForExport returns the archived original of an email for mbox
+// exports; other formats and unarchived emails get nil
+func (b *Bot) loadRawForExport(ctx context.Context, msg *appmodels.EmailMessage, format mailexport.Format) []byte {
+	if format != mailexport.FormatMbox || msg.RawKey == "" || b.archive == nil {
+		return nil
+	}
+	raw, err := b.archive.Load(ctx, msg.RawKey)
+	if err != nil {
+		b.logger.Warn("failed to load raw message, exporting stored body", "error", err, "message_id", msg.ID)
+		return nil
+	}
+	return raw
+}
+
+// exportPeriod describes the date range of an export, empty if unbounded
+func exportPeriod(ctx context.Context, from, to time.Time) string {
+	switch {
+	case from.IsZero() && to.IsZero():
+		return ""
+	case to.IsZero():
+		return i18n.Tf(ctx, "с %s", from.Format(time.DateOnly))
+	case from.IsZero():
+		return i18n.Tf(ctx, "по %s", to.AddDate(0, 0, -1).Format(time.DateOnly))
+	}
+	return from.Format(time.DateOnly) + " — " + to.AddDate(0, 0, -1).Format(time.DateOnly)
+}
@@ -0,0 +1,202 @@
+package telegram
+
+import (
+	"context"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/mixelka/emailresend/internal/i18n"
+)
+
+// Middlewares wrap bot.HandlerFunc with the checks shared by many commands.
+// Command middlewares assume update.Message is set.
+
+// chain wraps handler with middlewares, the first one being the outermost
+func chain(handler bot.HandlerFunc, middlewares ...bot.Middleware) bot.HandlerFunc {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// withLanguage puts the language of the update's chat into the context, so
+// replies are translated. Installed for all updates.
+func (b *Bot) withLanguage(next bot.HandlerFunc) bot.HandlerFunc {
+	return func(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+		var chatID int64
+		switch {
+		case update.Message != nil:
+			chatID = update.Message.Chat.ID
+		case update.CallbackQuery != nil:
+			chatID = callbackChatID(update.CallbackQuery)
+		}
+		if chatID != 0 {
+			ctx = b.chatContext(ctx, chatID)
+		}
+		next(ctx, tgBot, update)
+	}
+}
+
+// withoutReplyThreads clears the thread ID of messages outside forums, so
+// the rest of the bot sees topic 0 for private chats and plain groups.
+// Replies in supergroups without topics carry the ID of their reply thread,
+// which is not a topic. Installed for all updates.
+func (b *Bot) withoutReplyThreads(next bot.HandlerFunc) bot.HandlerFunc {
+	return func(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+		if msg := update.Message; msg != nil {
+			msg.MessageThreadID = topicOf(msg)
+		}
+		if cb := update.CallbackQuery; cb != nil && cb.Message.Message != nil {
+			cb.Message.Message.MessageThreadID = topicOf(cb.Message.Message)
+		}
+		next(ctx, tgBot, update)
+	}
+}
+
+// chatContext returns ctx carrying the language of a chat, for messages sent
+// outside of an update (notifications, background jobs)
+func (b *Bot) chatContext(ctx context.Context, chatID int64) context.Context {
+	settings, err := b.db.GetChatSettings(ctx, chatID)
+	if err != nil {
+		b.logger.Warn("failed to get chat language", "error", err, "chat_id", chatID)
+		return ctx
+	}
+	return i18n.WithLang(ctx, settings.Language)
+}
+
+// recoverPanic logs a panicking handler with its stack trace and keeps the
+// update loop alive. Installed for all updates.
+func (b *Bot) recoverPanic(next bot.HandlerFunc) bot.HandlerFunc {
+	return func(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+		defer func() {
+			if r := recover(); r != nil {
+				b.logger.Error("handler panic", "panic", r, "update_id", update.ID, "stack", string(debug.Stack()))
+				if msg := update.Message; msg != nil {
+					b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Внутренняя ошибка, попробуйте позже")
+				}
+				if cb := update.CallbackQuery; cb != nil {
+					b.answerCallback(ctx, cb.ID, "Внутренняя ошибка", false)
+				}
+			}
+		}()
+		next(ctx, tgBot, update)
+	}
+}
+
+// auditLog logs who ran which command where and how long it took
+func (b *Bot) auditLog(next bot.HandlerFunc) bot.HandlerFunc {
+	return func(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+		msg := update.Message
+		started := time.Now()
+		next(ctx, tgBot, update)
+
+		text := msg.Text
+		if text == "" {
+			text = msg.Caption
+		}
+		b.logger.Info("command",
+			"command", commandName(text),
+			"chat_id", msg.Chat.ID,
+			"topic_id", msg.MessageThreadID,
+			"user_id", msg.From.ID,
+			"duration", time.Since(started).Round(time.Millisecond),
+		)
+	}
+}
+
+// commandName returns the first word of a command without arguments, which
+// may contain credentials
+func commandName(text string) string {
+	if fields := strings.Fields(text); len(fields) > 0 {
+		return fields[0]
+	}
+	return ""
+}
+
+// requireForum rejects commands outside supergroups with topics
+func (b *Bot) requireForum(next bot.HandlerFunc) bot.HandlerFunc {
+	return func(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+		msg := update.Message
+		if msg.Chat.Type != "supergroup" || !msg.Chat.IsForum {
+			b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Эта команда работает только в супергруппах с топиками")
+			return
+		}
+		next(ctx, tgBot, update)
+	}
+}
+
+// requireAdmin rejects commands from users who are neither chat admins nor
+// bot operators, replying with denyText. Skipped for commands opened to
+// other roles with /permissions.
+func (b *Bot) requireAdmin(denyText string) bot.Middleware {
+	return func(next bot.HandlerFunc) bot.HandlerFunc {
+		return func(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+			if !b.checkAdmin(ctx, update.Message, denyText) {
+				return
+			}
+			next(ctx, tgBot, update)
+		}
+	}
+}
+
+// rateLimitSweepSize is the number of tracked users above which idle entries
+// are dropped
+const rateLimitSweepSize = 1000
+
+// rateLimitKey identifies a user in a chat
+type rateLimitKey struct {
+	chatID int64
+	userID int64
+}
+
+// rateLimit allows each user at most limit calls per window in a chat. Each
+// call creates an independent limiter, so limits are per command.
+func (b *Bot) rateLimit(limit int, window time.Duration) bot.Middleware {
+	var mu sync.Mutex
+	calls := make(map[rateLimitKey][]time.Time)
+
+	return func(next bot.HandlerFunc) bot.HandlerFunc {
+		return func(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+			msg := update.Message
+			key := rateLimitKey{chatID: msg.Chat.ID, userID: msg.From.ID}
+			now := time.Now()
+
+			mu.Lock()
+			if len(calls) > rateLimitSweepSize {
+				for k, times := range calls {
+					if now.Sub(times[len(times)-1]) >= window {
+						delete(calls, k)
+					}
+				}
+			}
+			recent := calls[key][:0]
+			for _, t := range calls[key] {
+				if now.Sub(t) < window {
+					recent = append(recent, t)
+				}
+			}
+			allowed := len(recent) < limit
+			if allowed {
+				recent = append(recent, now)
+			}
+			calls[key] = recent
+			var retry time.Duration
+			if !allowed {
+				retry = window - now.Sub(recent[0])
+			}
+			mu.Unlock()
+
+			if !allowed {
+				b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+					i18n.Tf(ctx, "Слишком часто. Повторите через %s", retry.Round(time.Second)))
+				return
+			}
+			next(ctx, tgBot, update)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package telegram
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/mixelka/emailresend/internal/database"
+	"github.com/mixelka/emailresend/internal/i18n"
+	appmodels "github.com/mixelka/emailresend/pkg/models"
+)
+
+// mirrorInviteTTL is how long a /mirror invite code can be used
+const mirrorInviteTTL = time.Hour
+
+// handleMirror handles /mirror command. In the topic of an account it lists
+// the mirrors or creates an invite code; in another topic it links the topic
+// as a mirror with such a code.
+// Usage: /mirror [invite | code]
+func (b *Bot) handleMirror(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+	parts := strings.Fields(msg.Text)
+
+	account, err := b.db.GetAccountByChatAndTopic(ctx, msg.Chat.ID, msg.MessageThreadID, b.accountBotID())
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		b.logger.Error("failed to get account", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка получения информации об аккаунте")
+		return
+	}
+
+	if account == nil {
+		if len(parts) != 2 {
+			b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+				"Чтобы получать в этом топике копии писем почты из другой группы, выполните там в топике почты <code>/mirror invite</code> и отправьте сюда полученную команду")
+			return
+		}
+		b.acceptMirror(ctx, msg, parts[1])
+		return
+	}
+
+	if len(parts) == 2 && strings.EqualFold(parts[1], "invite") {
+		b.createMirrorInvite(ctx, msg, account)
+		return
+	}
+	b.sendMirrors(ctx, msg, account)
+}
+
+// createMirrorInvite sends a one-time code for linking a mirror of an account
+func (b *Bot) createMirrorInvite(ctx context.Context, msg *models.Message, account *appmodels.EmailAccount) {
+	buf := make([]byte, 6)
+	if _, err := rand.Read(buf); err != nil {
+		b.logger.Error("failed to generate mirror invite", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка создания кода")
+		return
+	}
+
+	invite := &appmodels.MirrorInvite{
+		Code:      hex.EncodeToString(buf),
+		AccountID: account.ID,
+		CreatedBy: msg.From.ID,
+		ExpiresAt: time.Now().Add(mirrorInviteTTL),
+	}
+	if err := b.db.CreateMirrorInvite(ctx, invite); err != nil {
+		b.logger.Error("failed to create mirror invite", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка создания кода")
+		return
+	}
+
+	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.Tf(ctx,
+		"Чтобы транслировать письма <b>%s</b> в другую группу, отправьте в её топике в течение часа:\n\n<code>/mirror %s</code>\n\nБот должен быть участником группы. Код одноразовый; пароль и настройки почты остаются в этом чате.",
+		html.EscapeString(account.Email), invite.Code))
+}
+
+// acceptMirror links the topic of msg as a mirror with an invite code
+func (b *Bot) acceptMirror(ctx context.Context, msg *models.Message, code string) {
+	if _, err := b.db.GetMirrorByChatAndTopic(ctx, msg.Chat.ID, msg.MessageThreadID); err == nil {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Этот топик уже получает письма другой почты, сначала выполните /unmirror")
+		return
+	}
+
+	invite, err := b.db.UseMirrorInvite(ctx, strings.ToLower(code))
+	if errors.Is(err, database.ErrNotFound) {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Код не найден или истёк")
+		return
+	}
+	if err != nil {
+		b.logger.Error("failed to use mirror invite", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка проверки кода")
+		return
+	}
+
+	account, err := b.db.GetAccountByID(ctx, invite.AccountID)
+	if err != nil {
+		b.logger.Error("failed to get account", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Аккаунт не найден")
+		return
+	}
+	if account.BotID != b.accountBotID() {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Код выдан другим ботом: добавьте в группу его и отправьте команду ему")
+		return
+	}
+	if account.ChatID == msg.Chat.ID {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Эта почта уже подключена к этой группе")
+		return
+	}
+
+	mirror := &appmodels.AccountMirror{
+		AccountID: account.ID,
+		ChatID:    msg.Chat.ID,
+		TopicID:   msg.MessageThreadID,
+		CreatedBy: msg.From.ID,
+	}
+	err = b.db.CreateMirror(ctx, mirror)
+	if errors.Is(err, database.ErrAlreadyExists) {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Письма этой почты уже транслируются в эту группу")
+		return
+	}
+	if err != nil {
+		b.logger.Error("failed to create mirror", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка сохранения")
+		return
+	}
+
+	b.logger.Info("mirror linked", "account_id", account.ID, "chat_id", msg.Chat.ID, "topic_id", msg.MessageThreadID, "user_id", msg.From.ID)
+	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.Tf(ctx,
+		"Сюда будут приходить копии писем <b>%s</b>. Управление почтой остаётся у группы-владельца, отключить трансляцию: /unmirror",
+		html.EscapeString(account.Email)))
+	b.sendMessage(ctx, account.ChatID, account.TopicID, i18n.Tf(ctx,
+		"Письма транслируются в группу «%s»", html.EscapeString(msg.Chat.Title)))
+}
+
+// sendMirrors lists the mirrors of an account
+func (b *Bot) sendMirrors(ctx context.Context, msg *models.Message, account *appmodels.EmailAccount) {
+	mirrors, err := b.db.GetAccountMirrors(ctx, account.ID)
+	if err != nil {
+		b.logger.Error("failed to get mirrors", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка получения трансляций")
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(i18n.Tf(ctx, "<b>Трансляции писем %s:</b>\n\n", html.EscapeString(account.Email)))
+	if len(mirrors) == 0 {
+		sb.WriteString(i18n.T(ctx, "нет\n"))
+	}
+	for i, m := range mirrors {
+		chat := i18n.Tf(ctx, "группа %d", m.ChatID)
+		if m.TopicID != 0 {
+			chat = fmt.Sprintf(`<a href="%s">%s</a>`, messageLink(m.ChatID, m.TopicID), chat)
+		}
+		sb.WriteString(i18n.Tf(ctx, "%d. %s, с %s\n", i+1, chat, m.CreatedAt.Format("02.01.2006")))
+	}
+	sb.WriteString(i18n.T(ctx, "\n<code>/mirror invite</code> — код для трансляции в другую группу\n<code>/unmirror N</code> — отключить трансляцию"))
+	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, sb.String())
+}
+
+// handleUnmirror handles /unmirror command: in a mirror topic it unlinks the
+// topic, in the topic of an account the mirror with the given number
+// Usage: /unmirror [N]
+func (b *Bot) handleUnmirror(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+	parts := strings.Fields(msg.Text)
+
+	mirror, err := b.db.GetMirrorByChatAndTopic(ctx, msg.Chat.ID, msg.MessageThreadID)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		b.logger.Error("failed to get mirror", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка получения трансляций")
+		return
+	}
+
+	if mirror == nil {
+		account, ok := b.getTopicAccount(ctx, msg)
+		if !ok {
+			return
+		}
+		mirrors, err := b.db.GetAccountMirrors(ctx, account.ID)
+		if err != nil {
+			b.logger.Error("failed to get mirrors", "error", err)
+			b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка получения трансляций")
+			return
+		}
+		n := 0
+		if len(parts) == 2 {
+			n, _ = strconv.Atoi(parts[1])
+		}
+		if n < 1 || n > len(mirrors) {
+			b.sendMirrors(ctx, msg, account)
+			return
+		}
+		mirror = mirrors[n-1]
+	}
+
+	if err := b.db.DeleteMirror(ctx, mirror.ID); err != nil && !errors.Is(err, database.ErrNotFound) {
+		b.logger.Error("failed to delete mirror", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка отключения трансляции")
+		return
+	}
+
+	b.logger.Info("mirror unlinked", "account_id", mirror.AccountID, "chat_id", mirror.ChatID, "user_id", msg.From.ID)
+	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Трансляция писем отключена")
+}
+
+// deliverMirrors sends copies of a delivered email to the mirrors of its
+// account. Copies have no buttons, as those act on the account's chat, and
+// failed copies are not retried.
+func (b *Bot) deliverMirrors(ctx context.Context, account *appmodels.EmailAccount, text string, opts messageOptions) {
+	mirrors, err := b.db.GetAccountMirrors(ctx, account.ID)
+	if err != nil {
+		b.logger.Error("failed to get mirrors", "error", err, "account_id", account.ID)
+		return
+	}
+
+	for _, m := range mirrors {
+		if _, err := b.sendMessageWithKeyboard(ctx, m.ChatID, m.TopicID, text, nil, opts); err != nil {
+			b.logger.Warn("failed to send email to mirror", "error", err, "account_id", account.ID, "chat_id", m.ChatID)
+		}
+	}
+}
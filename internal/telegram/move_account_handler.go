@@ -0,0 +1,212 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"html"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/mixelka/emailresend/internal/database"
+	"github.com/mixelka/emailresend/internal/i18n"
+	appmodels "github.com/mixelka/emailresend/pkg/models"
+)
+
+// moveAccountUsage explains /move_account
+const moveAccountUsage = "Использование:\n<code>/move_account 123</code> — перенести почту этого топика в топик 123 этой группы\n<code>/move_account https://t.me/c/1234567890/123</code> — в топик по ссылке, в том числе другой группы\n<code>/move_account -1001234567890 [123]</code> — в другую группу (и её топик)\n\nПисьма, настройки и фильтры почты сохраняются. Бот должен быть участником группы, а вы — её администратором."
+
+// handleMoveAccount handles /move_account command: binds the account of the
+// topic to another topic or chat without reconnecting it
+// Usage: /move_account topic | link | chat [topic]
+func (b *Bot) handleMoveAccount(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	account, ok := b.getTopicAccount(ctx, msg)
+	if !ok {
+		return
+	}
+
+	chatID, topicID, ok := parseMoveTarget(msg.Chat.ID, strings.Fields(msg.Text)[1:])
+	if !ok {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, moveAccountUsage)
+		return
+	}
+	if chatID == account.ChatID && topicID == account.TopicID {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Почта уже подключена к этому топику")
+		return
+	}
+
+	if chatID != account.ChatID {
+		if !b.canMoveToChat(ctx, msg, chatID) {
+			return
+		}
+	}
+	if _, err := b.db.GetMirrorByChatAndTopic(ctx, chatID, topicID); err == nil {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Этот топик получает трансляцию другой почты, сначала выполните там /unmirror")
+		return
+	}
+
+	// Posting the notice checks that the topic exists and the bot may write
+	// there
+	targetCtx := b.chatContext(ctx, chatID)
+	notice, err := b.sendMessage(targetCtx, chatID, topicID, i18n.Tf(targetCtx,
+		"📬 Сюда перенесена почта <b>%s</b>", html.EscapeString(account.Email)))
+	if err != nil {
+		b.logger.Warn("failed to post to move target", "error", err, "chat_id", chatID, "topic_id", topicID)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.Tf(ctx,
+			"Бот не может писать в этот топик: %v\n\nПроверьте, что топик существует, а бот состоит в группе и может отправлять сообщения.", err))
+		return
+	}
+
+	from := *account
+	err = b.db.MoveAccount(ctx, account, chatID, topicID)
+	if err != nil {
+		b.deleteMessage(ctx, chatID, notice.ID)
+		if errors.Is(err, database.ErrAlreadyExists) {
+			b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "В этом топике уже есть почта, или эта почта уже подключена к той группе")
+			return
+		}
+		b.logger.Error("failed to move account", "error", err, "account_id", account.ID)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка переноса почты")
+		return
+	}
+
+	b.logger.Info("account moved",
+		"account_id", account.ID,
+		"from_chat_id", from.ChatID, "from_topic_id", from.TopicID,
+		"chat_id", chatID, "topic_id", topicID,
+		"user_id", msg.From.ID,
+	)
+	b.afterAccountMove(ctx, &from, account)
+
+	where := i18n.T(ctx, "другую группу")
+	if topicID != 0 && hasMessageLinks(chatID) {
+		where = messageLink(chatID, topicID)
+	} else if chatID == from.ChatID {
+		where = i18n.T(ctx, "другой топик")
+	}
+	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.Tf(ctx,
+		"Почта <b>%s</b> перенесена в %s. Письма и настройки сохранены.", html.EscapeString(account.Email), where))
+}
+
+// canMoveToChat checks that the user running /move_account may bring an
+// account into another chat: they must administer it, and the bot must be
+// a member allowed to post
+func (b *Bot) canMoveToChat(ctx context.Context, msg *models.Message, chatID int64) bool {
+	member, err := b.bot.GetChatMember(ctx, &bot.GetChatMemberParams{ChatID: chatID, UserID: b.id})
+	if err != nil {
+		b.logger.Warn("failed to check bot membership", "error", err, "chat_id", chatID)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Бот не состоит в этой группе: добавьте его и повторите команду")
+		return false
+	}
+	switch member.Type {
+	case models.ChatMemberTypeLeft, models.ChatMemberTypeBanned:
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Бот не состоит в этой группе: добавьте его и повторите команду")
+		return false
+	case models.ChatMemberTypeRestricted:
+		if !member.Restricted.CanSendMessages {
+			b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Боту запрещено писать в этой группе")
+			return false
+		}
+	}
+
+	if b.isOperator(msg.From.ID) {
+		return true
+	}
+	admin, err := b.isUserAdmin(ctx, chatID, msg.From.ID)
+	if err != nil {
+		b.logger.Warn("failed to check admin status in move target", "error", err, "chat_id", chatID)
+	}
+	if !admin {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Переносить почту можно только в группы, где вы администратор")
+		return false
+	}
+	return true
+}
+
+// afterAccountMove updates what depends on where the account is posted
+func (b *Bot) afterAccountMove(ctx context.Context, from, account *appmodels.EmailAccount) {
+	b.wakeStatusBoards()
+
+	if !account.UnreadBadge {
+		return
+	}
+	b.badgeMu.Lock()
+	delete(b.badgeNames, account.ID)
+	b.badgeMu.Unlock()
+	// The old topic loses the unread count
+	if from.TopicID != 0 {
+		if err := b.renameTopic(ctx, from.ChatID, from.TopicID, badgeTopicName(topicBaseName(from), 0)); err != nil {
+			b.logger.Warn("failed to rename topic", "error", err, "account_id", account.ID)
+		}
+	}
+	if account.TopicID == 0 {
+		account.UnreadBadge = false
+		if err := b.db.UpdateAccountSettings(ctx, account); err != nil {
+			b.logger.Error("failed to update account settings", "error", err)
+		}
+		return
+	}
+	b.wakeUnreadBadges()
+}
+
+// parseMoveTarget parses the /move_account arguments into a chat and topic:
+// a topic of the current chat, a t.me/c/ link to a topic, or a chat ID with
+// an optional topic
+func parseMoveTarget(chatID int64, args []string) (int64, int, bool) {
+	switch len(args) {
+	case 1:
+		if strings.Contains(args[0], "t.me/") {
+			return parseTopicLink(args[0])
+		}
+		n, err := strconv.ParseInt(args[0], 10, 64)
+		switch {
+		case err != nil:
+			return 0, 0, false
+		case n < 0:
+			return n, 0, true
+		default:
+			return chatID, int(n), n <= 1<<31-1
+		}
+	case 2:
+		target, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil || target >= 0 {
+			return 0, 0, false
+		}
+		topicID, err := strconv.Atoi(args[1])
+		if err != nil || topicID < 0 {
+			return 0, 0, false
+		}
+		return target, topicID, true
+	}
+	return 0, 0, false
+}
+
+// parseTopicLink parses a link to a topic or a message in it of a
+// supergroup, https://t.me/c/<chat>/<topic>[/<message>]
+func parseTopicLink(link string) (int64, int, bool) {
+	if !strings.Contains(link, "://") {
+		link = "https://" + link
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return 0, 0, false
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 3 || len(parts) > 4 || parts[0] != "c" {
+		return 0, 0, false
+	}
+	chatID, err := strconv.ParseInt("-100"+parts[1], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	topicID, err := strconv.Atoi(parts[2])
+	if err != nil || topicID <= 0 {
+		return 0, 0, false
+	}
+	return chatID, topicID, true
+}
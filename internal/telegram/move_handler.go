@@ -0,0 +1,276 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"html"
+	"strconv"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/mixelka/emailresend/internal/database"
+	"github.com/mixelka/emailresend/internal/email"
+	"github.com/mixelka/emailresend/internal/formatter"
+	"github.com/mixelka/emailresend/internal/i18n"
+	appmodels "github.com/mixelka/emailresend/pkg/models"
+)
+
+// moveUsage explains /move
+const moveUsage = `Использование (ответом на письмо):
+<code>/move</code> — выбрать папку кнопками
+<code>/move archive</code> — перенести в архив
+<code>/move Папка</code> — перенести в папку
+
+Без ответа на письмо <code>/move</code> показывает папки ящика этого топика.`
+
+// handleMoveCallback handles the archive and move buttons of an email
+func (b *Bot) handleMoveCallback(ctx context.Context, callback *models.CallbackQuery, data appmodels.CallbackData) {
+	msg, ok := b.getCallbackMessage(ctx, callback, data)
+	if !ok || b.movedAway(ctx, callback, msg) {
+		return
+	}
+
+	account, err := b.db.GetAccountByID(ctx, msg.AccountID)
+	if err != nil {
+		b.logger.Error("failed to get account", "error", err)
+		b.answerCallback(ctx, callback.ID, "Аккаунт не найден", false)
+		return
+	}
+
+	// The folder list replaces the email keyboard and remembers the Full
+	// text button in the code index
+	truncated := data.CodeIndex == 1
+	if data.Arg == "" && callback.Message.Message != nil {
+		truncated = hasCallbackButton(callback.Message.Message.ReplyMarkup, appmodels.CallbackFullText)
+	}
+
+	var folder string
+	switch data.Arg {
+	case appmodels.MoveCancel:
+		b.editMessageReplyMarkup(ctx, account.ChatID, msg.TelegramMsgID, emailKeyboard(ctx, account, msg, decodeCodes(msg.DetectedCodes), truncated))
+		b.answerCallback(ctx, callback.ID, "", false)
+		return
+	case "":
+		targets, err := b.moveTargets(ctx, account.ID)
+		if err != nil {
+			b.logger.Warn("failed to list folders", "error", err, "account_id", account.ID)
+			b.answerCallback(ctx, callback.ID, i18n.Tf(ctx, "Не удалось получить список папок: %v", err), true)
+			return
+		}
+		if len(targets) == 0 {
+			b.answerCallback(ctx, callback.ID, "В ящике нет других папок", true)
+			return
+		}
+		b.editMessageReplyMarkup(ctx, account.ChatID, msg.TelegramMsgID, formatter.BuildFolderKeyboard(msg.ID, targets, truncated, i18n.Lang(ctx)))
+		b.answerCallback(ctx, callback.ID, "Выберите папку", false)
+		return
+	case appmodels.MoveArchive:
+		folder, err = b.emailManager.ArchiveFolder(ctx, account.ID)
+		if errors.Is(err, email.ErrMailboxNotFound) {
+			b.answerCallback(ctx, callback.ID, "В ящике нет папки архива, выберите папку кнопкой «В папку»", true)
+			return
+		}
+	default:
+		var targets []string
+		if targets, err = b.moveTargets(ctx, account.ID); err != nil {
+			break
+		}
+		index, convErr := strconv.Atoi(data.Arg)
+		if convErr != nil || index < 0 || index >= len(targets) {
+			b.answerCallback(ctx, callback.ID, "Список папок изменился, откройте его заново", true)
+			return
+		}
+		folder = targets[index]
+	}
+	if err != nil {
+		b.logger.Warn("failed to find folder", "error", err, "account_id", account.ID)
+		b.answerCallback(ctx, callback.ID, "Ошибка: "+err.Error(), true)
+		return
+	}
+
+	if err := b.moveEmail(ctx, account, msg, folder, truncated); err != nil {
+		b.answerCallback(ctx, callback.ID, "Ошибка: "+err.Error(), true)
+		return
+	}
+	b.answerCallback(ctx, callback.ID, i18n.Tf(ctx, "Перемещено в %s", folder), false)
+}
+
+// handleMove handles /move command
+// Usage: /move [archive|folder], in reply to an email
+func (b *Bot) handleMove(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	// In forum topics a message without an explicit reply points to the topic header
+	reply := msg.ReplyToMessage
+	if reply == nil || reply.ID == msg.MessageThreadID {
+		b.listFolders(ctx, msg)
+		return
+	}
+
+	allowed, err := b.canUseCallbackAction(ctx, msg.Chat.ID, msg.From.ID, appmodels.CallbackMove)
+	if err != nil {
+		b.logger.Error("failed to check permissions", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка проверки прав")
+		return
+	}
+	if !allowed {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Перемещать письма могут только администраторы группы и операторы бота")
+		return
+	}
+
+	emailMsg, err := b.db.GetMessageByTelegramMsgID(ctx, msg.Chat.ID, reply.ID)
+	if errors.Is(err, database.ErrNotFound) {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Письмо не найдено")
+		return
+	}
+	if err != nil {
+		b.logger.Error("failed to get message", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка получения письма")
+		return
+	}
+	if emailMsg.Folder != "" {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.Tf(ctx, "Письмо уже перемещено в папку %s", html.EscapeString(emailMsg.Folder)))
+		return
+	}
+
+	account, err := b.db.GetAccountByID(ctx, emailMsg.AccountID)
+	if err != nil {
+		b.logger.Error("failed to get account", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Аккаунт не найден")
+		return
+	}
+
+	truncated := hasCallbackButton(reply.ReplyMarkup, appmodels.CallbackFullText)
+	targets, err := b.moveTargets(ctx, account.ID)
+	if err != nil {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.Tf(ctx, "Не удалось получить список папок: %v", err))
+		return
+	}
+
+	// Folder names may contain spaces
+	var name string
+	if _, rest, ok := strings.Cut(strings.TrimSpace(msg.Text), " "); ok {
+		name = strings.TrimSpace(rest)
+	}
+
+	var folder string
+	for _, target := range targets {
+		if strings.EqualFold(target, name) {
+			folder = target
+			break
+		}
+	}
+
+	switch {
+	case folder != "":
+	case name == "":
+		if len(targets) == 0 {
+			b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "В ящике нет других папок")
+			return
+		}
+		b.editMessageReplyMarkup(ctx, account.ChatID, emailMsg.TelegramMsgID, formatter.BuildFolderKeyboard(emailMsg.ID, targets, truncated, i18n.Lang(ctx)))
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Выберите папку кнопкой под письмом")
+		return
+	case strings.EqualFold(name, "archive") || strings.EqualFold(name, "архив"):
+		folder, err = b.emailManager.ArchiveFolder(ctx, account.ID)
+		if errors.Is(err, email.ErrMailboxNotFound) {
+			b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "В ящике нет папки архива, укажите папку: <code>/move Папка</code>")
+			return
+		}
+		if err != nil {
+			b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.Tf(ctx, "Ошибка: %v", err))
+			return
+		}
+	default:
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.Tf(ctx, "Папка <b>%s</b> не найдена. Папки ящика:\n%s",
+			html.EscapeString(name), formatFolderList(targets)))
+		return
+	}
+
+	if err := b.moveEmail(ctx, account, emailMsg, folder, truncated); err != nil {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.Tf(ctx, "Ошибка: %v", err))
+		return
+	}
+	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.Tf(ctx, "Письмо перемещено в папку %s", html.EscapeString(folder)))
+}
+
+// listFolders answers /move without a replied email with the folders of the
+// topic's mailbox, fetched anew
+func (b *Bot) listFolders(ctx context.Context, msg *models.Message) {
+	account, ok := b.getTopicAccount(ctx, msg)
+	if !ok {
+		return
+	}
+
+	folders, err := b.emailManager.Folders(ctx, account.ID, true)
+	if err != nil {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.Tf(ctx, "Не удалось получить список папок: %v", err))
+		return
+	}
+	names := make([]string, 0, len(folders))
+	for _, folder := range folders {
+		names = append(names, folder.Name)
+	}
+
+	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.Tf(ctx, "📁 <b>Папки %s</b>\n%s\n\n", html.EscapeString(account.Email), formatFolderList(names))+
+		i18n.T(ctx, moveUsage))
+}
+
+// moveEmail moves an email out of the inbox and updates its keyboard
+func (b *Bot) moveEmail(ctx context.Context, account *appmodels.EmailAccount, msg *appmodels.EmailMessage, folder string, truncated bool) error {
+	if err := b.emailManager.MoveMessage(account.ID, msg.UID, folder); err != nil {
+		b.logger.Error("failed to move message", "error", err, "message_id", msg.ID, "folder", folder)
+		return err
+	}
+
+	if err := b.db.MarkMessageAsMoved(ctx, msg.ID, folder); err != nil {
+		b.logger.Error("failed to update message", "error", err)
+	}
+	b.unpinCode(ctx, msg.ID)
+
+	msg.Folder = folder
+	b.editMessageReplyMarkup(ctx, account.ChatID, msg.TelegramMsgID, emailKeyboard(ctx, account, msg, decodeCodes(msg.DetectedCodes), truncated))
+	return nil
+}
+
+// moveTargets returns the folders an email of the inbox can be moved to; the
+// move keyboard refers to them by index
+func (b *Bot) moveTargets(ctx context.Context, accountID int64) ([]string, error) {
+	folders, err := b.emailManager.Folders(ctx, accountID, false)
+	if err != nil {
+		return nil, err
+	}
+	var targets []string
+	for _, folder := range folders {
+		if !strings.EqualFold(folder.Name, "INBOX") {
+			targets = append(targets, folder.Name)
+		}
+	}
+	return targets, nil
+}
+
+// movedAway answers a callback acting on the inbox copy of an email that was
+// moved to another folder or deleted in the mailbox. Returns true if the
+// callback was answered.
+func (b *Bot) movedAway(ctx context.Context, callback *models.CallbackQuery, msg *appmodels.EmailMessage) bool {
+	if msg.IsDeleted {
+		b.answerCallback(ctx, callback.ID, "Письмо удалено из почтового ящика", true)
+		return true
+	}
+	if msg.Folder == "" {
+		return false
+	}
+	b.answerCallback(ctx, callback.ID, i18n.Tf(ctx, "Письмо перемещено в папку %s", msg.Folder), true)
+	return true
+}
+
+// formatFolderList formats folder names one per line
+func formatFolderList(folders []string) string {
+	var sb strings.Builder
+	for _, folder := range folders {
+		sb.WriteString("• <code>" + html.EscapeString(folder) + "</code>\n")
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
@@ -0,0 +1,363 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot/models"
+
+	"github.com/mixelka/emailresend/internal/database"
+	"github.com/mixelka/emailresend/internal/email"
+	"github.com/mixelka/emailresend/internal/i18n"
+	"github.com/mixelka/emailresend/internal/oauth"
+	appmodels "github.com/mixelka/emailresend/pkg/models"
+)
+
+// oauthRefreshInterval is how often expiring access tokens are looked up
+const oauthRefreshInterval = time.Minute
+
+// oauthExpiryMargin is how close to expiry a token is renewed on login
+// instead of waiting for the refresher
+const oauthExpiryMargin = time.Minute
+
+// reauthPayloadPrefix starts the /start deep link payload of the
+// re-authorization flow, followed by the account ID
+const reauthPayloadPrefix = "reauth_"
+
+// oauthProvider returns the configured OAuth client of a provider
+func (b *Bot) oauthProvider(name string) (oauth.Provider, bool) {
+	var p oauth.Provider
+	switch name {
+	case "google":
+		p = oauth.Provider{Name: name, TokenURL: oauth.GoogleTokenURL,
+			ClientID: b.config.OAuthGoogleClientID, ClientSecret: b.config.OAuthGoogleClientSecret}
+	case "microsoft":
+		p = oauth.Provider{Name: name, TokenURL: oauth.MicrosoftTokenURL,
+			ClientID: b.config.OAuthMicrosoftClientID, ClientSecret: b.config.OAuthMicrosoftClientSecret}
+	}
+	return p, p.ClientID != ""
+}
+
+// oauthEnabled reports whether any OAuth provider is configured
+func (b *Bot) oauthEnabled() bool {
+	return b.config.OAuthGoogleClientID != "" || b.config.OAuthMicrosoftClientID != ""
+}
+
+// oauthAccessToken returns the access token of an account, renewing it if it
+// is about to expire, or "" for password accounts
+func (b *Bot) oauthAccessToken(ctx context.Context, accountID int64) (string, error) {
+	token, err := b.db.GetOAuthToken(ctx, accountID)
+	if errors.Is(err, database.ErrNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if token.Revoked {
+		return "", fmt.Errorf("%w: OAuth access revoked, re-authorization required", email.ErrAuth)
+	}
+
+	if time.Until(token.ExpiresAt) < oauthExpiryMargin {
+		if token, err = b.refreshOAuthToken(ctx, token); err != nil {
+			return "", err
+		}
+	}
+
+	accessToken, err := b.decryptPassword(token.AccessToken)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt access token: %w", err)
+	}
+	return accessToken, nil
+}
+
+// refreshOAuthToken renews the access token of an account. A revoked refresh
+// token pauses the account. Refreshes are serialized so a rotated refresh
+// token is never used twice.
+func (b *Bot) refreshOAuthToken(ctx context.Context, token *appmodels.OAuthToken) (*appmodels.OAuthToken, error) {
+	b.oauthMu.Lock()
+	defer b.oauthMu.Unlock()
+
+	// Another caller may have renewed it while we waited
+	current, err := b.db.GetOAuthToken(ctx, token.AccountID)
+	if err != nil {
+		return nil, err
+	}
+	if current.Revoked {
+		return nil, fmt.Errorf("%w: OAuth access revoked, re-authorization required", email.ErrAuth)
+	}
+	if current.ExpiresAt.After(token.ExpiresAt) {
+		return current, nil
+	}
+
+	provider, ok := b.oauthProvider(current.Provider)
+	if !ok {
+		return nil, fmt.Errorf("OAuth provider %q is not configured", current.Provider)
+	}
+
+	refreshToken, err := b.decryptPassword(current.RefreshToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt refresh token: %w", err)
+	}
+
+	renewed, err := provider.Refresh(ctx, refreshToken)
+	if errors.Is(err, oauth.ErrRevoked) {
+		if markErr := b.db.MarkOAuthTokenRevoked(ctx, current.AccountID); markErr != nil {
+			b.logger.Error("failed to mark oauth token revoked", "error", markErr, "account_id", current.AccountID)
+		}
+		// The account's own client may be the caller, so stop it separately
+		go b.pauseRevokedAccount(current.AccountID)
+		return nil, fmt.Errorf("%w: %w", email.ErrAuth, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to refresh access token: %w", err)
+	}
+
+	saved, err := b.saveOAuthToken(ctx, current.AccountID, provider.Name, renewed, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	b.logger.Debug("oauth access token renewed", "account_id", current.AccountID, "expires_at", renewed.ExpiresAt)
+	return saved, nil
+}
+
+// saveOAuthToken encrypts and stores a renewed token, keeping refreshToken
+// unless the provider rotated it
+func (b *Bot) saveOAuthToken(ctx context.Context, accountID int64, provider string, renewed *oauth.Token, refreshToken string) (*appmodels.OAuthToken, error) {
+	if renewed.RefreshToken != "" {
+		refreshToken = renewed.RefreshToken
+	}
+
+	accessEnc, err := b.encryptPassword(renewed.AccessToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt access token: %w", err)
+	}
+	refreshEnc, err := b.encryptPassword(refreshToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt refresh token: %w", err)
+	}
+
+	token := &appmodels.OAuthToken{
+		AccountID:    accountID,
+		Provider:     provider,
+		AccessToken:  accessEnc,
+		RefreshToken: refreshEnc,
+		ExpiresAt:    renewed.ExpiresAt,
+	}
+	if err := b.db.SaveOAuthToken(ctx, token); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// runOAuthRefresher renews access tokens of this bot's accounts before they
+// expire, so reconnects never wait for the token endpoint
+func (b *Bot) runOAuthRefresher(ctx context.Context) {
+	ticker := time.NewTicker(oauthRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		tokens, err := b.db.GetExpiringOAuthTokens(ctx, b.accountBotID(), time.Now().Add(b.config.OAuthRefreshBefore))
+		if err != nil {
+			b.logger.Error("failed to load expiring oauth tokens", "error", err)
+			continue
+		}
+
+		for _, token := range tokens {
+			if _, err := b.refreshOAuthToken(ctx, token); err != nil {
+				// Transient failures are retried on the next tick
+				b.logger.Warn("failed to renew oauth token", "error", err, "account_id", token.AccountID)
+			}
+		}
+	}
+}
+
+// pauseRevokedAccount stops forwarding for an account whose refresh token was
+// revoked and asks the topic to re-authorize it
+func (b *Bot) pauseRevokedAccount(accountID int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	account, err := b.db.GetAccountByID(ctx, accountID)
+	if err != nil {
+		b.logger.Error("failed to get account", "error", err, "account_id", accountID)
+		return
+	}
+	if !account.IsActive {
+		return
+	}
+
+	if err := b.db.SetAccountActive(ctx, accountID, false); err != nil {
+		b.logger.Error("failed to pause account", "error", err, "account_id", accountID)
+		return
+	}
+	if err := b.emailManager.RemoveAccount(accountID); err != nil {
+		b.logger.Warn("failed to stop email client", "error", err, "account_id", accountID)
+	}
+	b.wakeStatusBoards()
+	b.logger.Warn("oauth access revoked, account paused", "account_id", accountID, "email", account.Email)
+
+	ctx = b.chatContext(ctx, account.ChatID)
+	text := i18n.Tf(ctx, "⚠️ Доступ к <b>%s</b> отозван или истёк. Пересылка приостановлена.\n\n"+
+		"Администратор может восстановить доступ, отправив боту новый refresh token.", account.Email)
+	keyboard := &models.InlineKeyboardMarkup{
+		InlineKeyboard: [][]models.InlineKeyboardButton{{
+			{Text: "Авторизовать заново", URL: fmt.Sprintf("https://t.me/%s?start=%s%d", b.username, reauthPayloadPrefix, accountID)},
+		}},
+	}
+	if _, err := b.sendMessageWithKeyboard(ctx, account.ChatID, account.TopicID, text, keyboard, messageOptions{}); err != nil {
+		b.logger.Error("failed to send reauth request", "error", err, "account_id", accountID)
+	}
+}
+
+// parseReauthPayload returns the account ID of a /start reauth_<id> command
+func parseReauthPayload(text string) (int64, bool) {
+	fields := strings.Fields(text)
+	if len(fields) < 2 {
+		return 0, false
+	}
+	id, ok := strings.CutPrefix(fields[1], reauthPayloadPrefix)
+	if !ok {
+		return 0, false
+	}
+	accountID, err := strconv.ParseInt(id, 10, 64)
+	return accountID, err == nil
+}
+
+// startReauth opens a re-authorization session from the deep link of a
+// notice: a new refresh token for an OAuth account, a new password for an
+// account paused after its password was rejected
+func (b *Bot) startReauth(ctx context.Context, msg *models.Message, accountID int64) {
+	account, err := b.db.GetAccountByID(ctx, accountID)
+	if err != nil || account.BotID != b.accountBotID() {
+		b.sendMessage(ctx, msg.Chat.ID, 0, "Аккаунт не найден")
+		return
+	}
+
+	if !b.isOperator(msg.From.ID) {
+		isAdmin, err := b.isUserAdmin(ctx, account.ChatID, msg.From.ID)
+		if err != nil {
+			b.logger.Error("failed to check admin status", "error", err)
+			b.sendMessage(ctx, msg.Chat.ID, 0, "Ошибка проверки прав")
+			return
+		}
+		if !isAdmin {
+			b.sendMessage(ctx, msg.Chat.ID, 0, "Авторизовать аккаунт может только администратор его чата")
+			return
+		}
+	}
+
+	_, err = b.db.GetOAuthToken(ctx, accountID)
+	oauthAccount := err == nil
+
+	b.passwordMu.Lock()
+	b.passwordSessions[msg.From.ID] = passwordSession{
+		accountID: account.ID,
+		expiresAt: time.Now().Add(passwordSessionTTL),
+		oauth:     oauthAccount,
+		resume:    !oauthAccount,
+	}
+	b.passwordMu.Unlock()
+
+	if oauthAccount {
+		b.sendMessage(ctx, msg.Chat.ID, 0,
+			i18n.Tf(ctx, "Отправьте новый refresh token для <b>%s</b> следующим сообщением.\nОтмена: /cancel", account.Email))
+		return
+	}
+	b.sendMessage(ctx, msg.Chat.ID, 0,
+		i18n.Tf(ctx, "Отправьте новый пароль для <b>%s</b> следующим сообщением.\nОтмена: /cancel", account.Email))
+}
+
+// handleReauthToken validates a new refresh token, stores it and resumes the
+// paused account
+func (b *Bot) handleReauthToken(ctx context.Context, msg *models.Message, account *appmodels.EmailAccount, refreshToken string) {
+	// Do not keep the token in the chat history
+	if err := b.deleteMessage(ctx, msg.Chat.ID, msg.ID); err != nil {
+		b.logger.Warn("failed to delete token message", "error", err)
+	}
+
+	current, err := b.db.GetOAuthToken(ctx, account.ID)
+	if err != nil {
+		b.logger.Error("failed to get oauth token", "error", err, "account_id", account.ID)
+		b.clearPasswordSession(msg.From.ID)
+		b.sendMessage(ctx, msg.Chat.ID, 0, "Токен аккаунта не найден, авторизация отменена")
+		return
+	}
+	provider, ok := b.oauthProvider(current.Provider)
+	if !ok {
+		b.clearPasswordSession(msg.From.ID)
+		b.sendMessage(ctx, msg.Chat.ID, 0, i18n.Tf(ctx, "OAuth-провайдер %s не настроен", current.Provider))
+		return
+	}
+
+	renewed, err := provider.Refresh(ctx, refreshToken)
+	if err != nil {
+		b.logger.Warn("refresh token rejected", "error", err, "account_id", account.ID)
+		b.sendMessage(ctx, msg.Chat.ID, 0, "Токен не принят провайдером. Отправьте его ещё раз или /cancel")
+		return
+	}
+
+	b.sendMessage(ctx, msg.Chat.ID, 0, i18n.Tf(ctx, "Проверяю подключение к %s...", account.IMAPServer))
+	if err := b.emailManager.TestOAuthConnection(ctx, account.Email, renewed.AccessToken, account.IMAPServer); err != nil {
+		b.logger.Error("connection test failed", "error", err, "account_id", account.ID)
+		b.sendMessage(ctx, msg.Chat.ID, 0, connectErrorText(ctx, err)+"\n\nОтправьте токен ещё раз или /cancel")
+		return
+	}
+
+	if _, err := b.saveOAuthToken(ctx, account.ID, provider.Name, renewed, refreshToken); err != nil {
+		b.logger.Error("failed to save oauth token", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, 0, "Ошибка сохранения токена")
+		return
+	}
+	b.clearPasswordSession(msg.From.ID)
+
+	if err := b.db.SetAccountActive(ctx, account.ID, true); err != nil {
+		b.logger.Error("failed to resume account", "error", err, "account_id", account.ID)
+		b.sendMessage(ctx, msg.Chat.ID, 0, "Токен сохранён, но не удалось возобновить пересылку")
+		return
+	}
+
+	// Reload to pick up the latest UID and restart the client
+	account, err = b.db.GetAccountByID(ctx, account.ID)
+	if err != nil {
+		b.logger.Error("failed to reload account", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, 0, "Токен сохранён, но не удалось перезапустить подключение")
+		return
+	}
+	if err := b.emailManager.RestartAccount(ctx, account); err != nil {
+		b.logger.Error("failed to restart email client", "error", err, "account_id", account.ID)
+		b.sendMessage(ctx, msg.Chat.ID, 0, i18n.Tf(ctx, "Токен сохранён, но подключение не запущено: %v", err))
+		return
+	}
+
+	b.logger.Info("oauth account re-authorized", "account_id", account.ID, "user_id", msg.From.ID)
+	b.wakeStatusBoards()
+	b.sendMessage(ctx, msg.Chat.ID, 0, i18n.Tf(ctx, "Доступ к <b>%s</b> восстановлен, пересылка возобновлена", account.Email))
+	b.sendMessage(ctx, account.ChatID, account.TopicID, i18n.Tf(ctx, "Доступ к <b>%s</b> восстановлен, пересылка возобновлена", account.Email))
+}
+
+// handleStartPayload handles /start deep links in private chats and reports
+// whether the payload was recognized
+func (b *Bot) handleStartPayload(ctx context.Context, msg *models.Message) bool {
+	if msg.Chat.Type != "private" {
+		return false
+	}
+	if accountID, ok := parseReauthPayload(msg.Text); ok {
+		b.startReauth(ctx, msg, accountID)
+		return true
+	}
+	if fields := strings.Fields(msg.Text); len(fields) > 1 && fields[1] == dashboardPayload && b.config.WebAppEnabled() {
+		b.sendDashboardButton(ctx, msg.Chat.ID)
+		return true
+	}
+	return false
+}
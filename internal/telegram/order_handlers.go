@@ -0,0 +1,128 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/mixelka/emailresend/internal/database"
+	"github.com/mixelka/emailresend/internal/formatter"
+	"github.com/mixelka/emailresend/internal/i18n"
+)
+
+// searchResultLimit is the number of orders or emails listed by /search
+const searchResultLimit = 10
+
+// handleSearch handles /search command: in the topic of an account it
+// searches the account's emails, elsewhere the orders of the chat
+// Usage: /search <order number> | /search <words>
+func (b *Bot) handleSearch(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	account, err := b.db.GetAccountByChatAndTopic(ctx, msg.Chat.ID, msg.MessageThreadID, b.accountBotID())
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		b.logger.Error("failed to get account", "error", err)
+	}
+
+	parts := strings.Fields(msg.Text)
+	if len(parts) < 2 {
+		usage := i18n.T(ctx, "Использование: <code>/search номер_заказа</code>")
+		if account != nil {
+			usage = i18n.T(ctx, "Использование: <code>/search слова из письма</code>")
+		}
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, usage)
+		return
+	}
+	if account != nil {
+		b.searchEmails(ctx, msg, account, strings.Join(parts[1:], " "))
+		return
+	}
+	query := strings.ToUpper(parts[1])
+
+	orders, err := b.db.FindOrders(ctx, msg.Chat.ID, query, searchResultLimit)
+	if err != nil {
+		b.logger.Error("failed to find orders", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка поиска")
+		return
+	}
+	if len(orders) == 0 {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Заказы не найдены")
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(i18n.Tf(ctx, "<b>Заказы по запросу «%s»:</b>\n\n", html.EscapeString(query)))
+	for _, order := range orders {
+		line := fmt.Sprintf("%s %s", order.CreatedAt.Format("02.01.2006"), formatter.FormatOrderCard(models.ParseModeHTML, i18n.Lang(ctx), order))
+
+		// Link to the forwarded email if it was delivered
+		if emailMsg, err := b.db.GetMessageByID(ctx, order.MessageID); err == nil && emailMsg.TelegramMsgID != 0 && hasMessageLinks(msg.Chat.ID) {
+			line += i18n.Tf(ctx, ` — <a href="%s">письмо</a>`, messageLink(msg.Chat.ID, emailMsg.TelegramMsgID))
+		}
+		sb.WriteString(line + "\n")
+	}
+
+	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, sb.String())
+}
+
+// handleReport handles /report command
+// Usage: /report [YYYY-MM]
+func (b *Bot) handleReport(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	now := time.Now()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+
+	parts := strings.Fields(msg.Text)
+	if len(parts) >= 2 {
+		parsed, err := time.ParseInLocation("2006-01", parts[1], now.Location())
+		if err != nil {
+			b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Использование: <code>/report</code> или <code>/report 2024-05</code>")
+			return
+		}
+		month = parsed
+	}
+
+	totals, err := b.db.GetSpendTotals(ctx, msg.Chat.ID, month, month.AddDate(0, 1, 0))
+	if err != nil {
+		b.logger.Error("failed to get spend totals", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка построения отчёта")
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(i18n.Tf(ctx, "<b>Отчёт за %s</b>\n\n", month.Format("01.2006")))
+	if len(totals) == 0 {
+		sb.WriteString(i18n.T(ctx, "Покупок с известной суммой не найдено"))
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, sb.String())
+		return
+	}
+
+	sb.WriteString(i18n.T(ctx, "<b>Расходы по магазинам:</b>\n"))
+	sums := make(map[string]int64)
+	var currencies []string
+	for _, total := range totals {
+		sb.WriteString(fmt.Sprintf("• %s — %s (%d)\n",
+			html.EscapeString(total.Merchant), formatter.FormatMoney(total.Total, total.Currency), total.Count))
+		if _, ok := sums[total.Currency]; !ok {
+			currencies = append(currencies, total.Currency)
+		}
+		sums[total.Currency] += total.Total
+	}
+
+	sb.WriteString(i18n.T(ctx, "\n<b>Итого:</b> "))
+	for i, currency := range currencies {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(formatter.FormatMoney(sums[currency], currency))
+	}
+
+	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, sb.String())
+}
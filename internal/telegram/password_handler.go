@@ -0,0 +1,261 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/mixelka/emailresend/internal/i18n"
+	appmodels "github.com/mixelka/emailresend/pkg/models"
+)
+
+// passwordSessionTTL is how long a /setpassword request waits for the new password
+const passwordSessionTTL = 10 * time.Minute
+
+// setPasswordPayload is the /start deep link payload of the password flow
+const setPasswordPayload = "setpassword"
+
+// passwordSession is a pending /setpassword request of a user, a
+// re-authorization of an OAuth account waiting for a new refresh token or an
+// S/MIME key upload of /smime
+type passwordSession struct {
+	accountID int64
+	expiresAt time.Time
+	oauth     bool
+	smime     bool
+	resume    bool // the account was paused after its password was rejected
+}
+
+// handleSetPassword handles /setpassword command in a topic. The new
+// password is collected in a private chat so it never appears in the group.
+func (b *Bot) handleSetPassword(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	var account *appmodels.EmailAccount
+	if msg.Chat.Type == "private" {
+		// A mailbox may be connected to the private chat itself
+		var err error
+		account, err = b.db.GetAccountByChatAndTopic(ctx, msg.Chat.ID, 0, b.accountBotID())
+		if err != nil {
+			b.sendMessage(ctx, msg.Chat.ID, 0, "Используйте /setpassword в топике, к которому подключена почта")
+			return
+		}
+	} else {
+		var ok bool
+		if account, ok = b.getTopicAccount(ctx, msg); !ok {
+			return
+		}
+	}
+
+	if !b.checkAdmin(ctx, msg, "Только администраторы могут менять пароль") {
+		return
+	}
+
+	b.passwordMu.Lock()
+	b.passwordSessions[msg.From.ID] = passwordSession{
+		accountID: account.ID,
+		expiresAt: time.Now().Add(passwordSessionTTL),
+	}
+	b.passwordMu.Unlock()
+
+	if msg.Chat.Type == "private" {
+		b.sendMessage(ctx, msg.Chat.ID, 0,
+			i18n.Tf(ctx, "Смена пароля для <b>%s</b>\n\nОтправьте новый пароль следующим сообщением в течение 10 минут.", account.Email))
+		return
+	}
+
+	keyboard := &models.InlineKeyboardMarkup{
+		InlineKeyboard: [][]models.InlineKeyboardButton{{
+			{Text: "Ввести новый пароль", URL: fmt.Sprintf("https://t.me/%s?start=%s", b.username, setPasswordPayload)},
+		}},
+	}
+	b.sendMessageWithKeyboard(ctx, msg.Chat.ID, msg.MessageThreadID,
+		i18n.Tf(ctx, "Смена пароля для <b>%s</b>\n\nОтправьте новый пароль боту в личные сообщения в течение 10 минут.", account.Email),
+		keyboard, messageOptions{})
+}
+
+// matchPasswordReply matches private messages of users with a pending
+// /setpassword or /smime; S/MIME keys may also come as a file
+func (b *Bot) matchPasswordReply(update *models.Update) bool {
+	msg := update.Message
+	if msg == nil || msg.From == nil || msg.Chat.Type != "private" {
+		return false
+	}
+	session, ok := b.passwordSession(msg.From.ID)
+	return ok && (msg.Text != "" || session.smime && msg.Document != nil)
+}
+
+// passwordSession returns the pending session of a user, dropping expired ones
+func (b *Bot) passwordSession(userID int64) (passwordSession, bool) {
+	b.passwordMu.Lock()
+	defer b.passwordMu.Unlock()
+
+	session, ok := b.passwordSessions[userID]
+	if ok && time.Now().After(session.expiresAt) {
+		delete(b.passwordSessions, userID)
+		return passwordSession{}, false
+	}
+	return session, ok
+}
+
+// handlePasswordReply receives the new password in a private chat,
+// validates it and restarts the account's client with it
+func (b *Bot) handlePasswordReply(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	session, ok := b.passwordSession(msg.From.ID)
+	if !ok {
+		return
+	}
+
+	account, err := b.db.GetAccountByID(ctx, session.accountID)
+	if err != nil {
+		b.logger.Error("failed to get account", "error", err, "account_id", session.accountID)
+		b.clearPasswordSession(msg.From.ID)
+		b.sendMessage(ctx, msg.Chat.ID, 0, "Аккаунт не найден, смена пароля отменена")
+		return
+	}
+
+	if session.smime {
+		b.handleSMIMEKeyReply(ctx, msg, account)
+		return
+	}
+
+	text := strings.TrimSpace(msg.Text)
+	switch {
+	case strings.HasPrefix(text, "/start"):
+		if b.handleStartPayload(ctx, msg) {
+			return
+		}
+		if session.oauth {
+			b.sendMessage(ctx, msg.Chat.ID, 0,
+				i18n.Tf(ctx, "Отправьте новый refresh token для <b>%s</b> следующим сообщением.\nОтмена: /cancel", account.Email))
+			return
+		}
+		b.sendMessage(ctx, msg.Chat.ID, 0,
+			i18n.Tf(ctx, "Отправьте новый пароль для <b>%s</b> следующим сообщением.\nОтмена: /cancel", account.Email))
+		return
+	case strings.HasPrefix(text, "/cancel"):
+		b.clearPasswordSession(msg.From.ID)
+		if session.oauth {
+			b.sendMessage(ctx, msg.Chat.ID, 0, "Авторизация отменена")
+			return
+		}
+		b.sendMessage(ctx, msg.Chat.ID, 0, "Смена пароля отменена")
+		return
+	}
+
+	if session.oauth {
+		b.handleReauthToken(ctx, msg, account, text)
+		return
+	}
+
+	// Do not keep the password in the chat history
+	if err := b.deleteMessage(ctx, msg.Chat.ID, msg.ID); err != nil {
+		b.logger.Warn("failed to delete password message", "error", err)
+	}
+
+	b.sendMessage(ctx, msg.Chat.ID, 0, i18n.Tf(ctx, "Проверяю подключение к %s...", account.IMAPServer))
+
+	if err := b.emailManager.TestConnection(ctx, account.Email, text, account.IMAPServer); err != nil {
+		b.logger.Error("connection test failed", "error", err, "account_id", account.ID)
+		b.sendMessage(ctx, msg.Chat.ID, 0, connectErrorText(ctx, err)+"\n\nОтправьте пароль ещё раз или /cancel")
+		return
+	}
+
+	encrypted, err := b.encryptPassword(text)
+	if err != nil {
+		b.logger.Error("failed to encrypt password", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, 0, "Ошибка шифрования пароля")
+		return
+	}
+
+	if err := b.db.UpdateAccountPassword(ctx, account.ID, encrypted); err != nil {
+		b.logger.Error("failed to update password", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, 0, "Ошибка сохранения пароля")
+		return
+	}
+	b.clearPasswordSession(msg.From.ID)
+
+	if session.resume {
+		if err := b.db.SetAccountActive(ctx, account.ID, true); err != nil {
+			b.logger.Error("failed to resume account", "error", err, "account_id", account.ID)
+			b.sendMessage(ctx, msg.Chat.ID, 0, "Пароль сохранён, но не удалось возобновить пересылку")
+			return
+		}
+	}
+
+	// Reload to pick up the latest UID and restart the client in place
+	account, err = b.db.GetAccountByID(ctx, account.ID)
+	if err != nil {
+		b.logger.Error("failed to reload account", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, 0, "Пароль сохранён, но не удалось перезапустить подключение")
+		return
+	}
+	if err := b.emailManager.RestartAccount(ctx, account); err != nil {
+		b.logger.Error("failed to restart email client", "error", err, "account_id", account.ID)
+		b.sendMessage(ctx, msg.Chat.ID, 0, i18n.Tf(ctx, "Пароль сохранён, но подключение не запущено: %v", err))
+		return
+	}
+
+	b.logger.Info("email password updated", "account_id", account.ID, "user_id", msg.From.ID)
+	b.wakeStatusBoards()
+	b.sendMessage(ctx, msg.Chat.ID, 0, i18n.Tf(ctx, "Пароль для <b>%s</b> обновлён, подключение перезапущено", account.Email))
+	b.sendMessage(ctx, account.ChatID, account.TopicID, i18n.Tf(ctx, "Пароль для <b>%s</b> обновлён", account.Email))
+}
+
+// clearPasswordSession drops the pending /setpassword request of a user
+func (b *Bot) clearPasswordSession(userID int64) {
+	b.passwordMu.Lock()
+	delete(b.passwordSessions, userID)
+	b.passwordMu.Unlock()
+}
+
+// pauseAuthFailedAccount stops forwarding for an account whose server kept
+// rejecting the password, so the bot stops retrying it, and asks the topic
+// for a new one
+func (b *Bot) pauseAuthFailedAccount(accountID int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// OAuth accounts are re-authorized with a refresh token instead
+	if _, err := b.db.GetOAuthToken(ctx, accountID); err == nil {
+		b.pauseRevokedAccount(accountID)
+		return
+	}
+
+	account, err := b.db.GetAccountByID(ctx, accountID)
+	if err != nil {
+		b.logger.Error("failed to get account", "error", err, "account_id", accountID)
+		return
+	}
+	if !account.IsActive {
+		return
+	}
+
+	if err := b.db.SetAccountActive(ctx, accountID, false); err != nil {
+		b.logger.Error("failed to pause account", "error", err, "account_id", accountID)
+		return
+	}
+	if err := b.emailManager.RemoveAccount(accountID); err != nil {
+		b.logger.Warn("failed to stop email client", "error", err, "account_id", accountID)
+	}
+	b.wakeStatusBoards()
+	b.logger.Warn("password rejected repeatedly, account paused", "account_id", accountID, "email", account.Email)
+
+	ctx = b.chatContext(ctx, account.ChatID)
+	text := i18n.Tf(ctx, "⚠️ Сервер несколько раз подряд не принял пароль почты <b>%s</b>. Пересылка приостановлена, чтобы ящик не заблокировали за попытки входа.\n\n"+
+		"Администратор может переподключить почту с новым паролем кнопкой ниже. %s", account.Email, i18n.T(ctx, authHint))
+	keyboard := &models.InlineKeyboardMarkup{
+		InlineKeyboard: [][]models.InlineKeyboardButton{{
+			{Text: "Переподключить с новым паролем", URL: fmt.Sprintf("https://t.me/%s?start=%s%d", b.username, reauthPayloadPrefix, accountID)},
+		}},
+	}
+	if _, err := b.sendMessageWithKeyboard(ctx, account.ChatID, account.TopicID, text, keyboard, messageOptions{}); err != nil {
+		b.logger.Error("failed to send reconnect request", "error", err, "account_id", accountID)
+	}
+}
@@ -0,0 +1,217 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/mixelka/emailresend/internal/i18n"
+	appmodels "github.com/mixelka/emailresend/pkg/models"
+)
+
+// lockedCommands keep their built-in checks: changing who may run them would
+// allow taking over the chat or the bot
+var lockedCommands = []string{"permissions", "grant", "revoke", "forgetme", "broadcast", "exportcreds", "rotate_key", "start", "help"}
+
+// permissionGrantedKey marks a context whose command passed a chat-specific
+// permission, which then replaces the default admin check
+type permissionGrantedKey struct{}
+
+// permissionGranted reports whether the command of ctx was allowed by a
+// chat-specific permission
+func permissionGranted(ctx context.Context) bool {
+	granted, _ := ctx.Value(permissionGrantedKey{}).(bool)
+	return granted
+}
+
+// checkPermission enforces the roles set with /permissions for a command.
+// Commands without an override keep the checks of their middlewares and
+// handler; with one, only the roles decide.
+func (b *Bot) checkPermission(name string) bot.Middleware {
+	return func(next bot.HandlerFunc) bot.HandlerFunc {
+		return func(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+			msg := update.Message
+			if msg.Chat.Type == "private" || slices.Contains(lockedCommands, name) {
+				next(ctx, tgBot, update)
+				return
+			}
+
+			settings, err := b.db.GetChatSettings(ctx, msg.Chat.ID)
+			if err != nil {
+				b.logger.Error("failed to get chat settings", "error", err)
+				next(ctx, tgBot, update)
+				return
+			}
+			roles, ok := settings.CommandRoles(name)
+			if !ok {
+				next(ctx, tgBot, update)
+				return
+			}
+
+			allowed, err := b.hasRole(ctx, msg.Chat.ID, msg.From.ID, roles)
+			if err != nil {
+				b.logger.Error("failed to check roles", "error", err)
+				b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка проверки прав")
+				return
+			}
+			if !allowed {
+				b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+					i18n.Tf(ctx, "В этом чате команда доступна только: %s", formatRoles(ctx, roles)))
+				return
+			}
+			next(context.WithValue(ctx, permissionGrantedKey{}, true), tgBot, update)
+		}
+	}
+}
+
+// hasRole reports whether a user has any of the roles in a chat
+func (b *Bot) hasRole(ctx context.Context, chatID, userID int64, roles []string) (bool, error) {
+	if slices.Contains(roles, appmodels.RoleMember) {
+		return true, nil
+	}
+	if slices.Contains(roles, appmodels.RoleOperator) && b.isOperator(userID) {
+		return true, nil
+	}
+	if !slices.Contains(roles, appmodels.RoleAdmin) && !slices.Contains(roles, appmodels.RoleOwner) {
+		return false, nil
+	}
+	if slices.Contains(roles, appmodels.RoleAdmin) {
+		// Users granted with /grant count as admins
+		granted, err := b.db.IsBotAdmin(ctx, chatID, userID)
+		if err != nil {
+			return false, err
+		}
+		if granted {
+			return true, nil
+		}
+	}
+
+	member, err := b.bot.GetChatMember(ctx, &bot.GetChatMemberParams{ChatID: chatID, UserID: userID})
+	if err != nil {
+		return false, err
+	}
+	switch member.Type {
+	case models.ChatMemberTypeOwner:
+		return true, nil
+	case models.ChatMemberTypeAdministrator:
+		return slices.Contains(roles, appmodels.RoleAdmin), nil
+	default:
+		return false, nil
+	}
+}
+
+// formatRoles lists roles for users
+func formatRoles(ctx context.Context, roles []string) string {
+	names := map[string]string{
+		appmodels.RoleOwner:    "владелец чата",
+		appmodels.RoleAdmin:    "администраторы",
+		appmodels.RoleOperator: "операторы бота",
+		appmodels.RoleMember:   "все участники",
+	}
+	formatted := make([]string, len(roles))
+	for i, role := range roles {
+		formatted[i] = i18n.T(ctx, names[role])
+	}
+	return strings.Join(formatted, ", ")
+}
+
+// handlePermissions handles /permissions command
+// Usage: /permissions [command owner|admin|operator|member,... | command reset]
+func (b *Bot) handlePermissions(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	settings, err := b.db.GetChatSettings(ctx, msg.Chat.ID)
+	if err != nil {
+		b.logger.Error("failed to get chat settings", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка получения настроек")
+		return
+	}
+
+	parts := strings.Fields(msg.Text)
+	if len(parts) < 2 {
+		b.sendPermissions(ctx, msg, settings)
+		return
+	}
+	if len(parts) != 3 {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, permissionsUsage)
+		return
+	}
+
+	command := strings.TrimPrefix(strings.ToLower(parts[1]), "/")
+	command = strings.TrimPrefix(command, b.config.CommandPrefix)
+	if !slices.Contains(b.commands, command) {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+			i18n.Tf(ctx, "Неизвестная команда <code>%s</code>", html.EscapeString(parts[1])))
+		return
+	}
+	if slices.Contains(lockedCommands, command) {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+			i18n.Tf(ctx, "Права на %s изменить нельзя", b.command(command)))
+		return
+	}
+
+	var roles []string
+	if arg := strings.ToLower(parts[2]); arg != "reset" {
+		for _, role := range strings.Split(arg, ",") {
+			if !slices.Contains(appmodels.Roles, role) {
+				b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+					i18n.Tf(ctx, "Неизвестная роль <code>%s</code>\n\n%s", html.EscapeString(role), i18n.T(ctx, permissionsUsage)))
+				return
+			}
+			if !slices.Contains(roles, role) {
+				roles = append(roles, role)
+			}
+		}
+	}
+
+	settings.SetCommandRoles(command, roles)
+	if err := b.db.SaveChatSettings(ctx, settings); err != nil {
+		b.logger.Error("failed to save chat settings", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка сохранения настроек")
+		return
+	}
+
+	b.logger.Info("command permission changed", "chat_id", msg.Chat.ID, "command", command, "roles", roles, "user_id", msg.From.ID)
+	if len(roles) == 0 {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+			i18n.Tf(ctx, "Для %s восстановлены права по умолчанию", b.command(command)))
+		return
+	}
+	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+		i18n.Tf(ctx, "%s теперь доступна: %s", b.command(command), formatRoles(ctx, roles)))
+}
+
+// permissionsUsage explains the /permissions command
+const permissionsUsage = `Использование:
+<code>/permissions status member</code> — открыть команду всем участникам
+<code>/permissions disconnect owner,operator</code> — только владельцу чата и операторам бота
+<code>/permissions status reset</code> — права по умолчанию
+
+Роли: <code>owner</code>, <code>admin</code>, <code>operator</code>, <code>member</code>`
+
+// sendPermissions lists the commands with chat-specific permissions
+func (b *Bot) sendPermissions(ctx context.Context, msg *models.Message, settings *appmodels.ChatSettings) {
+	permissions := settings.PermissionMap()
+	commands := make([]string, 0, len(permissions))
+	for command := range permissions {
+		commands = append(commands, command)
+	}
+	sort.Strings(commands)
+
+	var sb strings.Builder
+	sb.WriteString(i18n.T(ctx, "<b>Права на команды в этом чате:</b>\n\n"))
+	if len(commands) == 0 {
+		sb.WriteString(i18n.T(ctx, "Для всех команд действуют права по умолчанию\n"))
+	}
+	for _, command := range commands {
+		sb.WriteString(fmt.Sprintf("%s — %s\n", b.command(command), formatRoles(ctx, permissions[command])))
+	}
+	sb.WriteString("\n" + i18n.T(ctx, permissionsUsage))
+	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, sb.String())
+}
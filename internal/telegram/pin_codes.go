@@ -0,0 +1,90 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-telegram/bot"
+
+	"github.com/mixelka/emailresend/internal/database"
+	appmodels "github.com/mixelka/emailresend/pkg/models"
+)
+
+// codeUnpinInterval is how often expired code pins are checked
+const codeUnpinInterval = time.Minute
+
+// pinCode pins a just posted email with codes if the account asks for it, so
+// the code stays easy to find until it expires or the email is read
+func (b *Bot) pinCode(ctx context.Context, account *appmodels.EmailAccount, msg *appmodels.EmailMessage, codes []appmodels.DetectedCode, telegramMsgID int) {
+	if account.PinCodes <= 0 || len(codes) == 0 || msg.IsRead {
+		return
+	}
+
+	if _, err := b.bot.PinChatMessage(ctx, &bot.PinChatMessageParams{
+		ChatID:              account.ChatID,
+		MessageID:           telegramMsgID,
+		DisableNotification: true,
+	}); err != nil {
+		b.logger.Warn("failed to pin email with codes", "error", err, "account_id", account.ID)
+		return
+	}
+
+	pin := &appmodels.PinnedCode{
+		MessageID:     msg.ID,
+		AccountID:     account.ID,
+		ChatID:        account.ChatID,
+		TelegramMsgID: telegramMsgID,
+		UnpinAt:       time.Now().Add(time.Duration(account.PinCodes) * time.Second),
+	}
+	if err := b.db.SavePinnedCode(ctx, pin); err != nil {
+		b.logger.Error("failed to save pinned code", "error", err)
+	}
+}
+
+// unpinCode unpins an email pinned by pinCode, if it is
+func (b *Bot) unpinCode(ctx context.Context, messageID int64) {
+	pin, err := b.db.GetPinnedCode(ctx, messageID)
+	if errors.Is(err, database.ErrNotFound) {
+		return
+	}
+	if err != nil {
+		b.logger.Error("failed to get pinned code", "error", err)
+		return
+	}
+	b.releasePin(ctx, pin)
+}
+
+// releasePin unpins the message of a pin and forgets the pin
+func (b *Bot) releasePin(ctx context.Context, pin *appmodels.PinnedCode) {
+	b.unpinMessage(ctx, pin.ChatID, pin.TelegramMsgID)
+	if err := b.db.DeletePinnedCode(ctx, pin.MessageID); err != nil {
+		b.logger.Error("failed to delete pinned code", "error", err)
+	}
+}
+
+// runCodeUnpins unpins emails with codes once their pin time is over
+func (b *Bot) runCodeUnpins(ctx context.Context) {
+	ticker := time.NewTicker(codeUnpinInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		pins, err := b.db.GetDuePinnedCodes(ctx, b.accountBotID(), time.Now())
+		if err != nil {
+			b.logger.Error("failed to load expired code pins", "error", err)
+			continue
+		}
+		for _, pin := range pins {
+			if ctx.Err() != nil {
+				return
+			}
+			b.releasePin(ctx, pin)
+		}
+	}
+}
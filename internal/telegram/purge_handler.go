@@ -0,0 +1,99 @@
+package telegram
+
+import (
+	"context"
+	"html"
+	"time"
+
+	"github.com/go-telegram/bot/models"
+
+	"github.com/mixelka/emailresend/internal/formatter"
+	"github.com/mixelka/emailresend/internal/i18n"
+	appmodels "github.com/mixelka/emailresend/pkg/models"
+)
+
+// purgeConfirmTTL is how long a /disconnect --purge confirmation stays valid
+const purgeConfirmTTL = 5 * time.Minute
+
+// confirmPurge asks to confirm deleting the mailbox of an account from Mailcow
+func (b *Bot) confirmPurge(ctx context.Context, msg *models.Message, account *appmodels.EmailAccount) {
+	if !account.Provisioned {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+			"Этот ящик не создавался ботом, удалить его с сервера нельзя. Чтобы только отключить почту, используйте /disconnect")
+		return
+	}
+	if b.mailcow == nil || !b.mailcow.IsConfigured() {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Mailcow не настроен, удалить ящик с сервера нельзя")
+		return
+	}
+
+	text := i18n.Tf(ctx, "⚠️ <b>Удаление ящика %s</b>\n\n"+
+		"Почта будет отключена от топика, а ящик удалён с сервера Mailcow вместе со всеми письмами. "+
+		"Сообщения, уже отправленные в чат, останутся.\n\n"+
+		"Действие необратимо. Подтвердить может администратор в течение 5 минут.", html.EscapeString(account.Email))
+	keyboard := formatter.BuildConfirmKeyboard(appmodels.CallbackPurge, account.ID, "🗑 Удалить ящик")
+	if _, err := b.sendMessageWithKeyboard(ctx, msg.Chat.ID, msg.MessageThreadID, text, keyboard, messageOptions{}); err != nil {
+		b.logger.Error("failed to send purge confirmation", "error", err)
+	}
+}
+
+// handlePurgeConfirm handles the /disconnect --purge confirmation buttons
+func (b *Bot) handlePurgeConfirm(ctx context.Context, callback *models.CallbackQuery, data appmodels.CallbackData) {
+	prompt := callback.Message.Message
+	if prompt == nil {
+		b.answerCallback(ctx, callback.ID, "Сообщение недоступно", false)
+		return
+	}
+	chatID := prompt.Chat.ID
+
+	if !b.isOperator(callback.From.ID) {
+		isAdmin, err := b.isUserAdmin(ctx, chatID, callback.From.ID)
+		if err != nil {
+			b.logger.Error("failed to check admin status", "error", err)
+			b.answerCallback(ctx, callback.ID, "Ошибка проверки прав", false)
+			return
+		}
+		if !isAdmin {
+			b.answerCallback(ctx, callback.ID, "Подтвердить удаление может только администратор", true)
+			return
+		}
+	}
+
+	if data.Arg != "yes" {
+		b.editMessageText(ctx, chatID, prompt.ID, "Удаление ящика отменено")
+		b.answerCallback(ctx, callback.ID, "Отменено", false)
+		return
+	}
+
+	if time.Since(time.Unix(int64(prompt.Date), 0)) > purgeConfirmTTL {
+		b.editMessageText(ctx, chatID, prompt.ID, "Подтверждение устарело, отправьте /disconnect --purge ещё раз")
+		b.answerCallback(ctx, callback.ID, "Подтверждение устарело", true)
+		return
+	}
+
+	account, err := b.db.GetAccountByID(ctx, data.MessageID)
+	if err != nil || account.ChatID != chatID || !account.Provisioned || b.mailcow == nil {
+		b.editMessageText(ctx, chatID, prompt.ID, "Почта уже отключена")
+		b.answerCallback(ctx, callback.ID, "Аккаунт не найден", false)
+		return
+	}
+
+	// Delete the mailbox first, so the account stays connected if that fails
+	if err := b.mailcow.DeleteMailbox(ctx, account.Email); err != nil {
+		b.logger.Error("failed to delete mailbox", "error", err, "email", account.Email)
+		b.answerCallback(ctx, callback.ID, "Ошибка удаления ящика на сервере", true)
+		return
+	}
+	b.logger.Info("mailbox deleted", "email", account.Email, "chat_id", chatID, "user_id", callback.From.ID)
+
+	if err := b.disconnectAccount(ctx, account); err != nil {
+		b.editMessageText(ctx, chatID, prompt.ID, i18n.Tf(ctx,
+			"Ящик <b>%s</b> удалён с сервера, но отключить его не удалось, выполните /disconnect", html.EscapeString(account.Email)))
+		b.answerCallback(ctx, callback.ID, "Ошибка удаления аккаунта", true)
+		return
+	}
+
+	b.editMessageText(ctx, chatID, prompt.ID, i18n.Tf(ctx,
+		"Ящик <b>%s</b> удалён с сервера и отключён от этого топика", html.EscapeString(account.Email)))
+	b.answerCallback(ctx, callback.ID, "Ящик удалён", false)
+}
@@ -0,0 +1,129 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/mixelka/emailresend/internal/database"
+	"github.com/mixelka/emailresend/internal/i18n"
+	appmodels "github.com/mixelka/emailresend/pkg/models"
+)
+
+// redeliverListLimit is the number of undelivered emails listed by /redeliver
+const redeliverListLimit = 10
+
+// handleRedeliver handles /redeliver command: lists the emails of the
+// topic's account that could not be posted and queues them again
+// Usage: /redeliver [all|<id>]
+func (b *Bot) handleRedeliver(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	account, ok := b.getTopicAccount(ctx, msg)
+	if !ok {
+		return
+	}
+
+	if !b.checkAdmin(ctx, msg, "Только администраторы могут повторять доставку писем") {
+		return
+	}
+
+	args := strings.Fields(msg.Text)[1:]
+	switch {
+	case len(args) == 0:
+		b.listUndelivered(ctx, msg, account)
+	case len(args) == 1 && strings.EqualFold(args[0], "all"):
+		b.redeliverAll(ctx, msg, account)
+	case len(args) == 1:
+		id, err := strconv.ParseInt(strings.TrimPrefix(args[0], "#"), 10, 64)
+		if err != nil {
+			b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Использование: <code>/redeliver [all|ID письма]</code>")
+			return
+		}
+		b.redeliverOne(ctx, msg, account, id)
+	default:
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Использование: <code>/redeliver [all|ID письма]</code>")
+	}
+}
+
+// listUndelivered shows the newest undelivered emails of an account with
+// their last Telegram error
+func (b *Bot) listUndelivered(ctx context.Context, msg *models.Message, account *appmodels.EmailAccount) {
+	count, err := b.db.CountFailedMessages(ctx, account.ID)
+	if err != nil {
+		b.logger.Error("failed to count failed messages", "error", err, "account_id", account.ID)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка получения писем")
+		return
+	}
+	if count == 0 {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "✅ Все письма доставлены в Telegram")
+		return
+	}
+
+	messages, err := b.db.GetFailedMessages(ctx, account.ID, redeliverListLimit)
+	if err != nil {
+		b.logger.Error("failed to get failed messages", "error", err, "account_id", account.ID)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка получения писем")
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(i18n.Tf(ctx, "⚠️ <b>Не доставлено в Telegram: %d</b>\n\n", count))
+	for _, m := range messages {
+		sb.WriteString(fmt.Sprintf("<code>%d</code> %s\n", m.ID, emailListSubject(ctx, msg.Chat.ID, m)))
+		sb.WriteString(fmt.Sprintf("   %s\n   <i>%s</i>\n", emailListSender(m), html.EscapeString(m.DeliveryError)))
+	}
+	if count > len(messages) {
+		sb.WriteString(i18n.Tf(ctx, "...и ещё %d\n", count-len(messages)))
+	}
+	sb.WriteString(i18n.T(ctx, "\n<code>/redeliver ID</code> — отправить письмо снова, <code>/redeliver all</code> — отправить все"))
+
+	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, sb.String())
+}
+
+// redeliverAll queues every undelivered email of an account again
+func (b *Bot) redeliverAll(ctx context.Context, msg *models.Message, account *appmodels.EmailAccount) {
+	queued, err := b.db.RedeliverFailedMessages(ctx, account.ID)
+	if err != nil {
+		b.logger.Error("failed to queue messages for redelivery", "error", err, "account_id", account.ID)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка постановки писем в очередь")
+		return
+	}
+	if queued == 0 {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "✅ Все письма доставлены в Telegram")
+		return
+	}
+
+	b.wakeDelivery()
+	b.logger.Info("undelivered emails queued again", "account_id", account.ID, "messages", queued)
+	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.Tf(ctx, "🔁 Писем поставлено в очередь: %d", queued))
+}
+
+// redeliverOne queues a single email of an account again, delivered or not
+func (b *Bot) redeliverOne(ctx context.Context, msg *models.Message, account *appmodels.EmailAccount, id int64) {
+	m, err := b.db.GetMessageByID(ctx, id)
+	if errors.Is(err, database.ErrNotFound) || err == nil && (m.AccountID != account.ID || m.IsDeleted) {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Письмо не найдено")
+		return
+	}
+	if err != nil {
+		b.logger.Error("failed to get message", "error", err, "message_id", id)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка получения письма")
+		return
+	}
+
+	if err := b.db.RedeliverMessage(ctx, m.ID); err != nil {
+		b.logger.Error("failed to queue message for redelivery", "error", err, "message_id", m.ID)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка постановки писем в очередь")
+		return
+	}
+	b.wakeDelivery()
+	b.logger.Info("email queued again", "account_id", account.ID, "message_id", m.ID)
+	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "🔁 Письмо поставлено в очередь")
+}
@@ -0,0 +1,99 @@
+package telegram
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/mixelka/emailresend/internal/i18n"
+)
+
+const (
+	// refetchDefaultCount is the number of emails /refetch loads without argument
+	refetchDefaultCount = 10
+	// refetchMaxCount caps the emails loaded by one /refetch
+	refetchMaxCount = 100
+	// refetchTimeout bounds a /refetch, bodies of many emails take a while
+	refetchTimeout = 5 * time.Minute
+)
+
+// refetchUsage explains /refetch
+const refetchUsage = `Использование:
+<code>/refetch 20</code> — последние 20 писем ящика
+<code>/refetch 2024-05-01</code> — письма начиная с этой даты (не больше 100)
+
+Письма, которые уже есть в боте, повторно не публикуются.`
+
+// handleRefetch handles /refetch command: loads recent emails of the topic's
+// mailbox again regardless of the last processed UID
+// Usage: /refetch [count|YYYY-MM-DD]
+func (b *Bot) handleRefetch(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	account, ok := b.getTopicAccount(ctx, msg)
+	if !ok {
+		return
+	}
+
+	if !b.checkAdmin(ctx, msg, "Только администраторы могут загружать письма заново") {
+		return
+	}
+
+	count := refetchDefaultCount
+	var since time.Time
+	if parts := strings.Fields(msg.Text); len(parts) >= 2 {
+		if n, err := strconv.Atoi(parts[1]); err == nil {
+			if n < 1 || n > refetchMaxCount {
+				b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.Tf(ctx, "Укажите от 1 до %d писем", refetchMaxCount))
+				return
+			}
+			count = n
+		} else if date, err := time.ParseInLocation("2006-01-02", parts[1], time.Local); err == nil {
+			if date.After(time.Now()) {
+				b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Дата не может быть в будущем")
+				return
+			}
+			count, since = refetchMaxCount, date
+		} else {
+			b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.T(ctx, refetchUsage))
+			return
+		}
+	}
+
+	if !account.IsActive {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Пересылка почты приостановлена, сначала выполните /resume")
+		return
+	}
+
+	before, err := b.db.CountMessagesByAccount(ctx, account.ID)
+	if err != nil {
+		b.logger.Error("failed to count messages", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка получения писем")
+		return
+	}
+
+	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Загружаю письма с сервера...")
+
+	fetchCtx, cancel := context.WithTimeout(ctx, refetchTimeout)
+	defer cancel()
+	fetched, err := b.emailManager.Refetch(fetchCtx, account.ID, count, since)
+	if err != nil {
+		b.logger.Error("failed to refetch emails", "error", err, "account_id", account.ID)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.Tf(ctx, "Не удалось загрузить письма: %v", err))
+		return
+	}
+
+	after, err := b.db.CountMessagesByAccount(ctx, account.ID)
+	if err != nil {
+		b.logger.Error("failed to count messages", "error", err)
+		after = before
+	}
+
+	b.logger.Info("emails refetched", "account_id", account.ID, "fetched", fetched, "new", after-before, "user_id", msg.From.ID)
+	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+		i18n.Tf(ctx, "Загружено писем с сервера: %d, новых: %d. Новые письма появятся в топике.", fetched, max(after-before, 0)))
+}
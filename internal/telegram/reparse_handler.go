@@ -0,0 +1,330 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/mixelka/emailresend/internal/database"
+	"github.com/mixelka/emailresend/internal/email"
+	"github.com/mixelka/emailresend/internal/formatter"
+	"github.com/mixelka/emailresend/internal/i18n"
+	"github.com/mixelka/emailresend/internal/parser"
+	appmodels "github.com/mixelka/emailresend/pkg/models"
+)
+
+const (
+	// reparseBatchSize is the number of messages loaded per step of /reparse all
+	reparseBatchSize = 50
+	// reparseEditDelay spaces Telegram edits during /reparse all to stay
+	// within the per-chat rate limit
+	reparseEditDelay = 3 * time.Second
+)
+
+// handleReparse handles /reparse command
+// Usage: /reparse <message link|id>, /reparse as a reply, /reparse all
+func (b *Bot) handleReparse(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	parts := strings.Fields(msg.Text)
+	if len(parts) >= 2 && strings.EqualFold(parts[1], "all") {
+		b.handleReparseAll(ctx, msg)
+		return
+	}
+
+	var ref string
+	if len(parts) >= 2 {
+		ref = parts[1]
+	}
+
+	emailMsg, ok := b.resolveMessageRef(ctx, msg, ref)
+	if !ok {
+		return
+	}
+
+	changed, err := b.reparseMessage(ctx, emailMsg)
+	if err != nil {
+		b.logger.Error("failed to reparse message", "error", err, "message_id", emailMsg.ID)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка повторного разбора письма")
+		return
+	}
+
+	if changed {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Письмо разобрано заново, сообщение обновлено")
+	} else {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Письмо разобрано заново, изменений нет")
+	}
+}
+
+// resolveMessageRef finds the email a /reparse argument points to: a link to
+// the Telegram message, the email ID or, without argument, the replied message
+func (b *Bot) resolveMessageRef(ctx context.Context, msg *models.Message, ref string) (*appmodels.EmailMessage, bool) {
+	usage := i18n.T(ctx, "Использование: <code>/reparse ссылка_на_сообщение</code>, <code>/reparse id</code>, ответ на письмо командой <code>/reparse</code> или <code>/reparse all</code>")
+
+	var (
+		emailMsg *appmodels.EmailMessage
+		err      error
+	)
+	switch {
+	case ref == "":
+		// In forum topics a message without an explicit reply points to the topic header
+		reply := msg.ReplyToMessage
+		if reply == nil || reply.ID == msg.MessageThreadID {
+			b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, usage)
+			return nil, false
+		}
+		emailMsg, err = b.db.GetMessageByTelegramMsgID(ctx, msg.Chat.ID, reply.ID)
+	case strings.Contains(ref, "t.me/"):
+		tgMsgID, ok := parseMessageLink(ref, msg.Chat.ID)
+		if !ok {
+			b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ссылка не ведёт на сообщение этого чата")
+			return nil, false
+		}
+		emailMsg, err = b.db.GetMessageByTelegramMsgID(ctx, msg.Chat.ID, tgMsgID)
+	default:
+		id, parseErr := strconv.ParseInt(ref, 10, 64)
+		if parseErr != nil {
+			b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, usage)
+			return nil, false
+		}
+		emailMsg, err = b.db.GetMessageByID(ctx, id)
+		if err == nil {
+			// The ID must belong to an account of this chat
+			account, accErr := b.db.GetAccountByID(ctx, emailMsg.AccountID)
+			if accErr != nil || account.ChatID != msg.Chat.ID {
+				err = database.ErrNotFound
+			}
+		}
+	}
+
+	if errors.Is(err, database.ErrNotFound) {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Письмо не найдено")
+		return nil, false
+	}
+	if err != nil {
+		b.logger.Error("failed to get message", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка получения письма")
+		return nil, false
+	}
+	return emailMsg, true
+}
+
+// parseMessageLink extracts the message ID from a t.me link. Private links
+// (t.me/c/<id>/...) must point to chatID.
+func parseMessageLink(link string, chatID int64) (int, bool) {
+	if !strings.Contains(link, "://") {
+		link = "https://" + link
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return 0, false
+	}
+
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(segments) < 2 {
+		return 0, false
+	}
+	if segments[0] == "c" {
+		if len(segments) < 3 || "-100"+segments[1] != strconv.FormatInt(chatID, 10) {
+			return 0, false
+		}
+	}
+
+	msgID, err := strconv.Atoi(segments[len(segments)-1])
+	if err != nil || msgID <= 0 {
+		return 0, false
+	}
+	return msgID, true
+}
+
+// handleReparseAll re-parses all messages of the topic's account produced by
+// an older parser version
+func (b *Bot) handleReparseAll(ctx context.Context, msg *models.Message) {
+	account, ok := b.getTopicAccount(ctx, msg)
+	if !ok {
+		return
+	}
+
+	if !b.checkAdmin(ctx, msg, "Только администраторы могут запускать массовый разбор") {
+		return
+	}
+
+	b.reparseMu.Lock()
+	if b.reparsing[account.ID] {
+		b.reparseMu.Unlock()
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Разбор писем этого аккаунта уже идёт")
+		return
+	}
+	b.reparsing[account.ID] = true
+	b.reparseMu.Unlock()
+
+	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Запущен повторный разбор писем, разобранных старой версией парсера")
+
+	go func() {
+		defer func() {
+			b.reparseMu.Lock()
+			delete(b.reparsing, account.ID)
+			b.reparseMu.Unlock()
+		}()
+
+		total, changed, err := b.reparseAccount(ctx, account.ID)
+		if err != nil {
+			b.logger.Error("failed to reparse account", "error", err, "account_id", account.ID)
+			b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+				i18n.Tf(ctx, "Разбор прерван из-за ошибки. Обработано писем: %d, обновлено: %d", total, changed))
+			return
+		}
+
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+			i18n.Tf(ctx, "Разбор завершён. Обработано писем: %d, обновлено: %d", total, changed))
+	}()
+}
+
+// reparseAccount re-parses outdated messages of an account in batches
+func (b *Bot) reparseAccount(ctx context.Context, accountID int64) (total, changed int, err error) {
+	var afterID int64
+	for {
+		messages, err := b.db.GetOutdatedMessages(ctx, accountID, parser.Version, afterID, reparseBatchSize)
+		if err != nil {
+			return total, changed, err
+		}
+		if len(messages) == 0 {
+			return total, changed, nil
+		}
+
+		for _, msg := range messages {
+			afterID = msg.ID
+
+			edited, err := b.reparseMessage(ctx, msg)
+			if err != nil {
+				return total, changed, err
+			}
+			total++
+			if edited {
+				changed++
+				if !sleepCtx(ctx, reparseEditDelay) {
+					return total, changed, ctx.Err()
+				}
+			}
+		}
+	}
+}
+
+// reparseMessage re-runs HTML parsing and code detection on the stored body
+// (the archived raw message if available) and updates the Telegram message
+// if the result changed
+func (b *Bot) reparseMessage(ctx context.Context, msg *appmodels.EmailMessage) (bool, error) {
+	// Nothing is left to parse once the body was trimmed by the storage quota
+	if msg.BodyTrimmed {
+		return false, nil
+	}
+
+	account, err := b.db.GetAccountByID(ctx, msg.AccountID)
+	if err != nil {
+		return false, err
+	}
+
+	rawEmail := &email.RawEmail{
+		BodyHTML:    msg.BodyHTML,
+		BodyText:    msg.BodyText,
+		Size:        msg.Size,
+		Attachments: decodeAttachments(msg.Attachments),
+	}
+	if msg.BodyHTML != "" {
+		// body_text holds the previous parse result, not the plain text part
+		rawEmail.BodyText = ""
+	}
+	if msg.RawKey != "" && b.archive != nil {
+		raw, err := b.archive.Load(ctx, msg.RawKey)
+		if err != nil {
+			b.logger.Warn("failed to load raw message, using stored body", "error", err, "message_id", msg.ID)
+		} else {
+			rawEmail = email.ParseRaw(raw, b.logger)
+		}
+	}
+
+	bodyText := b.renderBody(ctx, account, rawEmail)
+	codes, extraction := b.detectCodes(ctx, account, parser.ExtractInput{
+		FromAddr: msg.FromAddr,
+		FromName: msg.FromName,
+		Subject:  msg.Subject,
+		Text:     bodyText,
+		HTML:     rawEmail.BodyHTML,
+	})
+	extracted := encodeExtraction(extraction)
+	codesJSON, _ := json.Marshal(codes)
+	attachmentsJSON, _ := json.Marshal(rawEmail.Attachments)
+
+	changed := bodyText != msg.BodyText ||
+		string(codesJSON) != msg.DetectedCodes ||
+		string(attachmentsJSON) != msg.Attachments ||
+		extracted != msg.Extracted
+
+	msg.BodyText = bodyText
+	msg.BodyHTML = rawEmail.BodyHTML
+	msg.DetectedCodes = string(codesJSON)
+	msg.Attachments = string(attachmentsJSON)
+	msg.Extracted = extracted
+	msg.ParserVersion = parser.Version
+	if err := b.db.UpdateMessageContent(ctx, msg); err != nil {
+		return false, err
+	}
+	if err := b.db.SaveMessageCodes(ctx, msg, account.ChatID, codes); err != nil {
+		b.logger.Error("failed to save message codes", "error", err)
+	}
+	var order *appmodels.Order
+	if extraction != nil {
+		order = extraction.Order
+	}
+	if err := b.db.SaveOrder(ctx, msg, account.ChatID, order); err != nil {
+		b.logger.Error("failed to save order", "error", err)
+	}
+
+	if !changed || msg.TelegramMsgID == 0 {
+		return changed, nil
+	}
+	return true, b.refreshEmailMessage(ctx, account, msg, codes)
+}
+
+// refreshEmailMessage re-renders the Telegram message of an email
+func (b *Bot) refreshEmailMessage(ctx context.Context, account *appmodels.EmailAccount, msg *appmodels.EmailMessage, codes []appmodels.DetectedCode) error {
+	// A collapsed message shows the latest email of its group only
+	current, err := b.db.GetMessageByTelegramMsgID(ctx, account.ChatID, msg.TelegramMsgID)
+	if err != nil || current.ID != msg.ID {
+		return nil
+	}
+
+	settings, err := b.db.GetChatSettings(ctx, account.ChatID)
+	if err != nil {
+		return err
+	}
+
+	parseMode := models.ParseMode(settings.ParseMode)
+	avatarURL, domainEmoji := b.senderPicture(ctx, account, msg)
+	text, truncated := b.formatter.FormatEmail(msg, codes, formatter.FormatOptions{
+		ParseMode:   parseMode,
+		CustomEmoji: settings.CustomEmojiMap(),
+		CodeReused:  b.isCodeReused(ctx, account.ChatID, msg, codes),
+		Profile:     account.FormatProfile,
+		Template:    account.MessageTemplate,
+		Deleted:     msg.IsDeleted,
+		DomainEmoji: domainEmoji,
+		Language:    i18n.Lang(ctx),
+	})
+	keyboard := emailKeyboard(ctx, account, msg, codes, truncated)
+
+	if err := b.editMessageWithPreview(ctx, account.ChatID, msg.TelegramMsgID, text, keyboard, parseMode, avatarURL); err != nil {
+		if strings.Contains(err.Error(), "message is not modified") {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
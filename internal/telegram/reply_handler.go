@@ -0,0 +1,191 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"html"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/mixelka/emailresend/internal/database"
+	"github.com/mixelka/emailresend/internal/email"
+	"github.com/mixelka/emailresend/internal/i18n"
+	"github.com/mixelka/emailresend/internal/smtp"
+	appmodels "github.com/mixelka/emailresend/pkg/models"
+)
+
+// replySendTimeout bounds sending an email reply
+const replySendTimeout = time.Minute
+
+// matchEmailReply matches text replies to messages sent by this bot;
+// handleEmailReply checks that the message is a forwarded email
+func (b *Bot) matchEmailReply(update *models.Update) bool {
+	msg := update.Message
+	if msg == nil || msg.From == nil || msg.Text == "" || strings.HasPrefix(msg.Text, "/") {
+		return false
+	}
+	// In forum topics a message without an explicit reply points to the topic header
+	reply := msg.ReplyToMessage
+	return reply != nil && reply.ID != topicOf(msg) && reply.From != nil && reply.From.ID == b.id
+}
+
+// handleEmailReply sends a reply to a forwarded email from the topic's
+// account. Replies of users who are not admins are ignored, so topics can
+// still be used for discussion.
+func (b *Bot) handleEmailReply(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	emailMsg, err := b.db.GetMessageByTelegramMsgID(ctx, msg.Chat.ID, msg.ReplyToMessage.ID)
+	if errors.Is(err, database.ErrNotFound) {
+		return
+	}
+	if err != nil {
+		b.logger.Error("failed to get replied message", "error", err)
+		return
+	}
+
+	if !b.isOperator(msg.From.ID) {
+		isAdmin, err := b.isUserAdmin(ctx, msg.Chat.ID, msg.From.ID)
+		if err != nil {
+			b.logger.Error("failed to check admin status", "error", err)
+			return
+		}
+		if !isAdmin {
+			return
+		}
+	}
+
+	account, err := b.db.GetAccountByID(ctx, emailMsg.AccountID)
+	if err != nil || account.ChatID != msg.Chat.ID {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Почта, на которую пришло это письмо, больше не подключена")
+		return
+	}
+
+	to := emailMsg.ReplyTo
+	if to == "" {
+		to = (&mail.Address{Name: emailMsg.FromName, Address: emailMsg.FromAddr}).String()
+	}
+	if emailMsg.ReplyTo == "" && emailMsg.FromAddr == "" {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "У письма нет адреса отправителя, ответить нельзя")
+		return
+	}
+
+	server, err := b.accountSMTPServer(ctx, account)
+	if err != nil {
+		b.logger.Error("failed to resolve SMTP server", "error", err, "account_id", account.ID)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Не удалось определить SMTP сервер")
+		return
+	}
+
+	password, err := b.decryptPassword(account.Password)
+	if err != nil {
+		b.logger.Error("failed to decrypt password", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка расшифровки пароля")
+		return
+	}
+
+	inReplyTo := normalizeMessageID(emailMsg.MessageID)
+	sendCtx, cancel := context.WithTimeout(ctx, replySendTimeout)
+	defer cancel()
+
+	sent, err := smtp.Send(sendCtx, smtp.Config{
+		Server:      server,
+		Username:    account.Email,
+		Password:    password,
+		DialTimeout: b.config.IMAPDialTimeout,
+	}, smtp.Message{
+		From:       account.Email,
+		To:         to,
+		Subject:    smtp.ReplySubject(emailMsg.Subject),
+		Body:       msg.Text,
+		InReplyTo:  inReplyTo,
+		References: smtp.ReplyReferences(inReplyTo, emailMsg.References),
+	})
+	b.recordReply(ctx, account, emailMsg, msg, to, sent, err)
+	if err != nil {
+		b.logger.Error("failed to send email reply", "error", err, "account_id", account.ID, "server", server)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+			i18n.Tf(ctx, "Не удалось отправить ответ через %s:\n<code>%s</code>\n\n%s",
+				html.EscapeString(server), html.EscapeString(err.Error()), i18n.T(ctx, authHint)))
+		return
+	}
+
+	b.logger.Info("email reply sent", "account_id", account.ID, "message_id", emailMsg.ID, "user_id", msg.From.ID)
+	text := i18n.Tf(ctx, "✉️ Ответ отправлен: %s", html.EscapeString(to))
+
+	if !b.saveToSent(ctx, account, sent.Raw) {
+		text += i18n.T(ctx, "\n⚠️ Не удалось сохранить копию в папку «Отправленные»")
+	}
+
+	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, text)
+}
+
+// recordReply stores a sent or failed reply in the sent emails
+func (b *Bot) recordReply(ctx context.Context, account *appmodels.EmailAccount, emailMsg *appmodels.EmailMessage, msg *models.Message, to string, sent *smtp.Sent, sendErr error) {
+	record := &appmodels.SentMessage{
+		AccountID: account.ID,
+		ReplyTo:   emailMsg.ID,
+		ToAddr:    to,
+		Subject:   smtp.ReplySubject(emailMsg.Subject),
+		Body:      msg.Text,
+		Status:    appmodels.SentSent,
+		CreatedBy: msg.From.ID,
+	}
+	if sendErr != nil {
+		record.Status, record.Error = appmodels.SentFailed, sendErr.Error()
+	} else {
+		now := time.Now()
+		record.MessageID, record.SentAt = sent.MessageID, &now
+	}
+	if err := b.db.CreateSentMessage(ctx, record); err != nil {
+		b.logger.Error("failed to record reply", "error", err)
+	}
+}
+
+// accountSMTPServer returns the SMTP server of an account, resolving and
+// remembering it on first use
+func (b *Bot) accountSMTPServer(ctx context.Context, account *appmodels.EmailAccount) (string, error) {
+	if account.SMTPServer != "" {
+		return account.SMTPServer, nil
+	}
+
+	server, err := smtp.ResolveServer(account.Email, account.IMAPServer)
+	if err != nil {
+		return "", err
+	}
+	if err := b.db.UpdateAccountSMTPServer(ctx, account.ID, server); err != nil {
+		b.logger.Warn("failed to save SMTP server", "error", err, "account_id", account.ID)
+	}
+	account.SMTPServer = server
+	return server, nil
+}
+
+// saveToSent appends a sent email to the account's Sent folder unless the
+// server does so itself, keeping the mailbox consistent for users who also
+// read it in a mail client. Returns false if saving failed.
+func (b *Bot) saveToSent(ctx context.Context, account *appmodels.EmailAccount, raw []byte) bool {
+	if email.SavesSentItself(account.IMAPServer) {
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, replySendTimeout)
+	defer cancel()
+	if _, err := b.emailManager.AppendSent(ctx, account, raw); err != nil {
+		b.logger.Warn("failed to save email to Sent folder", "error", err, "account_id", account.ID)
+		return false
+	}
+	return true
+}
+
+// normalizeMessageID wraps a Message-ID in angle brackets
+func normalizeMessageID(id string) string {
+	id = strings.TrimSpace(id)
+	if id == "" || strings.HasPrefix(id, "<") {
+		return id
+	}
+	return "<" + id + ">"
+}
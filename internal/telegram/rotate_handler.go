@@ -0,0 +1,140 @@
+package telegram
+
+import (
+	"context"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/mixelka/emailresend/internal/database"
+	"github.com/mixelka/emailresend/internal/formatter"
+	"github.com/mixelka/emailresend/internal/i18n"
+	"github.com/mixelka/emailresend/internal/secret"
+	appmodels "github.com/mixelka/emailresend/pkg/models"
+)
+
+// rotateConfirmTTL is how long a /rotate_key confirmation stays valid
+const rotateConfirmTTL = 5 * time.Minute
+
+// rotateRemoteText answers /rotate_key when the key lives in Vault or KMS
+const rotateRemoteText = "Ключ шифрования хранится в Vault или AWS KMS: ротируйте его там, перешифровывать пароли не нужно"
+
+// pendingKey is a new encryption key waiting for confirmation
+type pendingKey struct {
+	key       string
+	createdAt time.Time
+}
+
+// handleRotateKey handles /rotate_key command
+// Usage: /rotate_key <new 32-byte key>
+func (b *Bot) handleRotateKey(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	if !b.isOwner(msg.From.ID) {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Эта команда доступна только владельцу бота")
+		return
+	}
+	if msg.Chat.Type != "private" {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Смена ключа доступна только в личном чате с ботом")
+		return
+	}
+	localKey, ok := b.crypter.(*secret.Key)
+	if !ok {
+		b.sendMessage(ctx, msg.Chat.ID, 0, rotateRemoteText)
+		return
+	}
+
+	parts := strings.Fields(msg.Text)
+	if len(parts) != 2 {
+		b.sendMessage(ctx, msg.Chat.ID, 0,
+			"Использование: <code>/rotate_key новый_ключ</code>\n\nКлюч — ровно 32 символа, например из <code>openssl rand -hex 16</code>. "+
+				"Все пароли и OAuth-токены будут перешифрованы новым ключом.")
+		return
+	}
+
+	// The message holds the key
+	if err := b.deleteMessage(ctx, msg.Chat.ID, msg.ID); err != nil {
+		b.logger.Warn("failed to delete rotate key message", "error", err)
+	}
+
+	newKey := parts[1]
+	if len(newKey) != 32 {
+		b.sendMessage(ctx, msg.Chat.ID, 0, i18n.Tf(ctx, "Ключ должен быть ровно 32 байта, получено %d", len(newKey)))
+		return
+	}
+	if localKey.Is(newKey) {
+		b.sendMessage(ctx, msg.Chat.ID, 0, "Новый ключ совпадает с текущим")
+		return
+	}
+
+	b.rotateMu.Lock()
+	b.rotateKeys[msg.From.ID] = pendingKey{key: newKey, createdAt: time.Now()}
+	b.rotateMu.Unlock()
+
+	text := "🔑 <b>Смена ключа шифрования</b>\n\n" +
+		"Пароли всех почтовых аккаунтов и OAuth-токены будут перешифрованы новым ключом в одной транзакции, бот сразу начнёт использовать его.\n\n" +
+		"После этого обязательно замените <code>ENCRYPTION_KEY</code> в конфигурации на новый ключ: со старым после перезапуска бот не сможет расшифровать пароли.\n\n" +
+		"Подтвердите в течение 5 минут."
+	keyboard := formatter.BuildConfirmKeyboard(appmodels.CallbackRotateKey, 0, "🔑 Сменить ключ")
+	if _, err := b.sendMessageWithKeyboard(ctx, msg.Chat.ID, 0, text, keyboard, messageOptions{}); err != nil {
+		b.logger.Error("failed to send rotate key confirmation", "error", err)
+	}
+}
+
+// handleRotateKeyConfirm handles the /rotate_key confirmation buttons
+func (b *Bot) handleRotateKeyConfirm(ctx context.Context, callback *models.CallbackQuery, data appmodels.CallbackData) {
+	prompt := callback.Message.Message
+	if prompt == nil {
+		b.answerCallback(ctx, callback.ID, "Сообщение недоступно", false)
+		return
+	}
+	if !b.isOwner(callback.From.ID) {
+		b.answerCallback(ctx, callback.ID, "Подтвердить может только владелец бота", true)
+		return
+	}
+
+	b.rotateMu.Lock()
+	pending, ok := b.rotateKeys[callback.From.ID]
+	delete(b.rotateKeys, callback.From.ID)
+	b.rotateMu.Unlock()
+
+	if data.Arg != "yes" {
+		b.editMessageText(ctx, prompt.Chat.ID, prompt.ID, "Смена ключа отменена")
+		b.answerCallback(ctx, callback.ID, "Отменено", false)
+		return
+	}
+	if !ok || time.Since(pending.createdAt) > rotateConfirmTTL {
+		b.editMessageText(ctx, prompt.Chat.ID, prompt.ID, "Подтверждение устарело, отправьте /rotate_key ещё раз")
+		b.answerCallback(ctx, callback.ID, "Подтверждение устарело", true)
+		return
+	}
+	b.answerCallback(ctx, callback.ID, "Перешифровываю...", false)
+
+	localKey, ok := b.crypter.(*secret.Key)
+	if !ok {
+		b.editMessageText(ctx, prompt.Chat.ID, prompt.ID, rotateRemoteText)
+		return
+	}
+
+	var stats *database.RotationStats
+	err := localKey.Rotate(pending.key, func(reencrypt func(string) (string, error)) error {
+		var err error
+		stats, err = b.db.RotateSecrets(ctx, reencrypt)
+		return err
+	})
+	if err != nil {
+		b.logger.Error("failed to rotate encryption key", "error", err)
+		b.editMessageText(ctx, prompt.Chat.ID, prompt.ID,
+			i18n.Tf(ctx, "Не удалось сменить ключ, данные не изменены:\n<code>%s</code>", html.EscapeString(err.Error())))
+		return
+	}
+
+	b.logger.Info("encryption key rotated", "passwords", stats.Passwords, "oauth_tokens", stats.OAuthTokens, "smime_keys", stats.SMIMEKeys, "user_id", callback.From.ID)
+	b.editMessageText(ctx, prompt.Chat.ID, prompt.ID, i18n.Tf(ctx,
+		"✅ Ключ сменён: перешифровано паролей — %d, OAuth-токенов — %d, ключей S/MIME — %d.\n\n"+
+			"⚠️ Замените <code>ENCRYPTION_KEY</code> в конфигурации на новый ключ до следующего перезапуска бота.",
+		stats.Passwords, stats.OAuthTokens, stats.SMIMEKeys))
+}
@@ -0,0 +1,238 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mixelka/emailresend/internal/database"
+	"github.com/mixelka/emailresend/internal/email"
+	"github.com/mixelka/emailresend/internal/i18n"
+	"github.com/mixelka/emailresend/pkg/models"
+)
+
+// Router dispatches email events to the bot serving each account when
+// several bots share one database and email manager
+type Router struct {
+	bots         []*Bot
+	db           *database.DB
+	emailManager *email.Manager
+	logger       *slog.Logger
+
+	// Last owner notification about a worker panic by account ID
+	panicMu       sync.Mutex
+	panicNotified map[int64]time.Time
+}
+
+// NewRouter creates a router for the given bots; the first one is primary
+func NewRouter(bots []*Bot) *Router {
+	primary := bots[0]
+	return &Router{
+		bots:         bots,
+		db:           primary.db,
+		emailManager: primary.emailManager,
+		logger:       primary.logger.With("component", "telegram_router"),
+
+		panicNotified: make(map[int64]time.Time),
+	}
+}
+
+// SetupEmailCallbacks sets up email message callbacks
+func (r *Router) SetupEmailCallbacks() {
+	r.emailManager.SetMessageHandler(r.onNewEmail)
+	r.emailManager.SetErrorHandler(r.onEmailError)
+	r.emailManager.SetPanicHandler(r.onEmailPanic)
+	r.emailManager.SetStallHandler(r.onEmailStall)
+	r.emailManager.SetDecryptFunc(r.bots[0].DecryptPasswordFunc())
+	r.emailManager.SetTokenSource(r.accessToken)
+	r.emailManager.SetUIDStore(r.db.GetAccountUIDState, r.db.UpdateAccountUIDState)
+	r.emailManager.SetStateSync(r.trackedMessages, r.onMailboxChanges)
+}
+
+// BotFor returns the bot serving accounts with the given bot_id, or nil
+func (r *Router) BotFor(botID int64) *Bot {
+	for _, b := range r.bots {
+		if b.accountBotID() == botID {
+			return b
+		}
+	}
+	return nil
+}
+
+// ServedAccounts filters out accounts bound to bots that are not configured
+func (r *Router) ServedAccounts(accounts []*models.EmailAccount) []*models.EmailAccount {
+	served := make([]*models.EmailAccount, 0, len(accounts))
+	for _, account := range accounts {
+		if r.BotFor(account.BotID) == nil {
+			r.logger.Warn("skipping account bound to unconfigured bot",
+				"account_id", account.ID,
+				"email", account.Email,
+				"bot_id", account.BotID,
+			)
+			continue
+		}
+		served = append(served, account)
+	}
+	return served
+}
+
+// Start starts all bots and blocks until ctx is cancelled
+func (r *Router) Start(ctx context.Context) {
+	var wg sync.WaitGroup
+	if cfg := r.bots[0].config; cfg.WebhookEnabled() || cfg.WebAppEnabled() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.serveHTTP(ctx)
+		}()
+	}
+	for _, b := range r.bots {
+		wg.Add(1)
+		go func(b *Bot) {
+			defer wg.Done()
+			b.Start(ctx)
+		}(b)
+	}
+	wg.Wait()
+}
+
+// Drain waits for all bots to deliver their queued messages, see Bot.Drain
+func (r *Router) Drain(ctx context.Context) bool {
+	var (
+		wg      sync.WaitGroup
+		drained atomic.Bool
+	)
+	drained.Store(true)
+	for _, b := range r.bots {
+		wg.Add(1)
+		go func(b *Bot) {
+			defer wg.Done()
+			if !b.Drain(ctx) {
+				drained.Store(false)
+			}
+		}(b)
+	}
+	wg.Wait()
+	return drained.Load()
+}
+
+// botForAccount resolves the bot serving an account
+func (r *Router) botForAccount(accountID int64) *Bot {
+	account, err := r.db.GetAccountByID(context.Background(), accountID)
+	if err != nil {
+		r.logger.Error("failed to get account", "error", err, "account_id", accountID)
+		return nil
+	}
+
+	b := r.BotFor(account.BotID)
+	if b == nil {
+		r.logger.Warn("no bot configured for account", "account_id", accountID, "bot_id", account.BotID)
+	}
+	return b
+}
+
+// accessToken returns the OAuth access token of an account through the
+// owning bot, which renews it and pauses the account if it was revoked
+func (r *Router) accessToken(ctx context.Context, accountID int64) (string, error) {
+	b := r.botForAccount(accountID)
+	if b == nil {
+		return "", fmt.Errorf("no bot configured for account %d", accountID)
+	}
+	return b.oauthAccessToken(ctx, accountID)
+}
+
+// onNewEmail routes a new email to the owning bot
+func (r *Router) onNewEmail(accountID int64, rawEmail *email.RawEmail) error {
+	if b := r.botForAccount(accountID); b != nil {
+		return b.onNewEmail(accountID, rawEmail)
+	}
+	return nil
+}
+
+// trackedMessages loads the emails to sync through the owning bot
+func (r *Router) trackedMessages(ctx context.Context, accountID int64) ([]email.TrackedMessage, error) {
+	b := r.botForAccount(accountID)
+	if b == nil {
+		return nil, nil
+	}
+	return b.trackedMessages(ctx, accountID)
+}
+
+// onMailboxChanges routes emails read or deleted in the mailbox to the
+// owning bot
+func (r *Router) onMailboxChanges(accountID int64, changes []email.StateChange) {
+	if b := r.botForAccount(accountID); b != nil {
+		b.onMailboxChanges(accountID, changes)
+	}
+}
+
+// onEmailError routes an email error to the owning bot
+func (r *Router) onEmailError(accountID int64, err error) {
+	if b := r.botForAccount(accountID); b != nil {
+		b.onEmailError(accountID, err)
+	}
+}
+
+// panicNotifyInterval limits owner notifications about panics of one account
+const panicNotifyInterval = 15 * time.Minute
+
+// onEmailPanic notifies the owner through the primary bot that an account
+// worker panicked, at most once per panicNotifyInterval per account
+func (r *Router) onEmailPanic(accountID int64, recovered any) {
+	primary := r.bots[0]
+	if primary.config.OwnerID == 0 {
+		return
+	}
+
+	r.panicMu.Lock()
+	if last, ok := r.panicNotified[accountID]; ok && time.Since(last) < panicNotifyInterval {
+		r.panicMu.Unlock()
+		return
+	}
+	r.panicNotified[accountID] = time.Now()
+	r.panicMu.Unlock()
+
+	ctx := primary.chatContext(context.Background(), primary.config.OwnerID)
+	name := fmt.Sprintf("#%d", accountID)
+	if account, err := r.db.GetAccountByID(ctx, accountID); err == nil {
+		name = account.Email
+	}
+
+	text := i18n.Tf(ctx, "⚠️ <b>Сбой обработчика почты %s</b>\n\n<code>%s</code>\n\nСбой перехвачен: письмо пропущено или подключение будет перезапущено автоматически. Подробности в логах.",
+		html.EscapeString(name), html.EscapeString(fmt.Sprint(recovered)))
+	if _, err := primary.sendMessage(ctx, primary.config.OwnerID, 0, text); err != nil {
+		r.logger.Error("failed to notify owner about panic", "error", err)
+	}
+	primary.wakeStatusBoards()
+}
+
+// onEmailStall notifies the owner through the primary bot that an account
+// stopped completing fetch cycles, or that it recovered
+func (r *Router) onEmailStall(accountID int64, stalled bool, since time.Duration) {
+	primary := r.bots[0]
+	primary.wakeStatusBoards()
+	if primary.config.OwnerID == 0 {
+		return
+	}
+
+	ctx := primary.chatContext(context.Background(), primary.config.OwnerID)
+	name := fmt.Sprintf("#%d", accountID)
+	if account, err := r.db.GetAccountByID(ctx, accountID); err == nil {
+		name = account.Email
+	}
+
+	var text string
+	if stalled {
+		text = i18n.Tf(ctx, "⏳ <b>Почта %s не проверялась %s</b>\n\nПодключение есть, но цикл получения писем не завершился вдвое дольше ожидаемого — письма могут не приходить. Зависаний с запуска: %d. Подробности в логах.",
+			html.EscapeString(name), shortDuration(since.Round(time.Minute)), r.emailManager.Stalls())
+	} else {
+		text = i18n.Tf(ctx, "✅ Получение почты %s возобновилось", html.EscapeString(name))
+	}
+	if _, err := primary.sendMessage(ctx, primary.config.OwnerID, 0, text); err != nil {
+		r.logger.Error("failed to notify owner about stalled account", "error", err)
+	}
+}
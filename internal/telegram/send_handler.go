@@ -0,0 +1,326 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/mixelka/emailresend/internal/database"
+	"github.com/mixelka/emailresend/internal/formatter"
+	"github.com/mixelka/emailresend/internal/i18n"
+	"github.com/mixelka/emailresend/internal/smtp"
+	appmodels "github.com/mixelka/emailresend/pkg/models"
+)
+
+const (
+	// composeSessionTTL is how long an interactive /send waits for the next step
+	composeSessionTTL = 10 * time.Minute
+	// sendConfirmTTL is how long a composed email can be confirmed
+	sendConfirmTTL = 10 * time.Minute
+	// sendPreviewLength is the number of body characters shown for confirmation
+	sendPreviewLength = 1000
+)
+
+// Steps of an interactive /send
+const (
+	composeTo = iota
+	composeSubject
+	composeBody
+)
+
+// composeKey identifies a user composing an email in a chat
+type composeKey struct {
+	chatID int64
+	userID int64
+}
+
+// composeSession is an interactive /send of a user waiting for the next step
+type composeSession struct {
+	accountID int64
+	topicID   int
+	step      int
+	to        string
+	subject   string
+	expiresAt time.Time
+}
+
+// handleSend handles /send command
+// Usage: /send [to@example.com Subject | body]
+func (b *Bot) handleSend(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	account, ok := b.getTopicAccount(ctx, msg)
+	if !ok {
+		return
+	}
+
+	if !b.checkAdmin(ctx, msg, "Только администраторы могут отправлять письма") {
+		return
+	}
+
+	args := strings.TrimSpace(strings.TrimPrefix(msg.Text, strings.Fields(msg.Text)[0]))
+	if args == "" {
+		b.composeMu.Lock()
+		b.composeSessions[composeKey{chatID: msg.Chat.ID, userID: msg.From.ID}] = &composeSession{
+			accountID: account.ID,
+			topicID:   msg.MessageThreadID,
+			step:      composeTo,
+			expiresAt: time.Now().Add(composeSessionTTL),
+		}
+		b.composeMu.Unlock()
+
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+			i18n.Tf(ctx, "Новое письмо от <b>%s</b>\n\nКому отправить? Напишите адрес следующим сообщением.\nОтмена: /cancel", html.EscapeString(account.Email)))
+		return
+	}
+
+	to, rest, _ := strings.Cut(args, " ")
+	subject, body, ok := strings.Cut(rest, "|")
+	if !ok || strings.TrimSpace(body) == "" {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+			"Использование: <code>/send адрес Тема | текст письма</code>\nИли просто /send — бот спросит адрес, тему и текст по очереди")
+		return
+	}
+	b.composeDraft(ctx, msg, account, to, strings.TrimSpace(subject), strings.TrimSpace(body))
+}
+
+// matchComposeReply matches messages of users with an interactive /send in
+// the topic it was started in
+func (b *Bot) matchComposeReply(update *models.Update) bool {
+	msg := update.Message
+	if msg == nil || msg.From == nil || msg.Text == "" {
+		return false
+	}
+	if strings.HasPrefix(msg.Text, "/") && !strings.HasPrefix(msg.Text, "/cancel") {
+		return false
+	}
+	session, ok := b.composeSession(composeKey{chatID: msg.Chat.ID, userID: msg.From.ID})
+	return ok && session.topicID == topicOf(msg)
+}
+
+// composeSession returns the interactive /send of a user, dropping expired ones
+func (b *Bot) composeSession(key composeKey) (*composeSession, bool) {
+	b.composeMu.Lock()
+	defer b.composeMu.Unlock()
+
+	session, ok := b.composeSessions[key]
+	if ok && time.Now().After(session.expiresAt) {
+		delete(b.composeSessions, key)
+		return nil, false
+	}
+	return session, ok
+}
+
+// handleComposeReply collects the recipient, subject and body of an
+// interactive /send one message at a time
+func (b *Bot) handleComposeReply(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+	key := composeKey{chatID: msg.Chat.ID, userID: msg.From.ID}
+	text := strings.TrimSpace(msg.Text)
+
+	b.composeMu.Lock()
+	session, ok := b.composeSessions[key]
+	if !ok {
+		b.composeMu.Unlock()
+		return
+	}
+	if strings.HasPrefix(text, "/cancel") {
+		delete(b.composeSessions, key)
+		b.composeMu.Unlock()
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Отправка письма отменена")
+		return
+	}
+
+	session.expiresAt = time.Now().Add(composeSessionTTL)
+	step := session.step
+	switch step {
+	case composeTo:
+		if _, err := mail.ParseAddress(text); err != nil {
+			b.composeMu.Unlock()
+			b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Некорректный адрес, попробуйте ещё раз.\nОтмена: /cancel")
+			return
+		}
+		session.to = text
+		session.step = composeSubject
+	case composeSubject:
+		session.subject = text
+		session.step = composeBody
+	case composeBody:
+		delete(b.composeSessions, key)
+	}
+	b.composeMu.Unlock()
+
+	switch step {
+	case composeTo:
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Тема письма?\nОтмена: /cancel")
+	case composeSubject:
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Текст письма?\nОтмена: /cancel")
+	case composeBody:
+		account, err := b.db.GetAccountByID(ctx, session.accountID)
+		if err != nil {
+			b.logger.Error("failed to get account", "error", err, "account_id", session.accountID)
+			b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Почта этого топика больше не подключена")
+			return
+		}
+		b.composeDraft(ctx, msg, account, session.to, session.subject, msg.Text)
+	}
+}
+
+// composeDraft stores a composed email as a draft and asks its author to
+// confirm sending it
+func (b *Bot) composeDraft(ctx context.Context, msg *models.Message, account *appmodels.EmailAccount, to, subject, body string) {
+	addr, err := mail.ParseAddress(to)
+	if err != nil {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.Tf(ctx, "Некорректный адрес: <code>%s</code>", html.EscapeString(to)))
+		return
+	}
+
+	draft := &appmodels.SentMessage{
+		AccountID: account.ID,
+		ToAddr:    addr.String(),
+		Subject:   subject,
+		Body:      body,
+		Status:    appmodels.SentDraft,
+		CreatedBy: msg.From.ID,
+	}
+	if err := b.db.CreateSentMessage(ctx, draft); err != nil {
+		b.logger.Error("failed to save draft", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка сохранения письма")
+		return
+	}
+
+	preview := []rune(body)
+	if len(preview) > sendPreviewLength {
+		preview = append(preview[:sendPreviewLength], '…')
+	}
+	text := i18n.Tf(ctx, "✉️ <b>Отправить письмо?</b>\n\nОт: %s\nКому: %s\nТема: %s\n\n%s\n\n<i>Подтвердить может только автор в течение 10 минут</i>",
+		html.EscapeString(account.Email), html.EscapeString(draft.ToAddr), html.EscapeString(subject), html.EscapeString(string(preview)))
+	keyboard := formatter.BuildConfirmKeyboard(appmodels.CallbackSendEmail, draft.ID, "✉️ Отправить")
+	if _, err := b.sendMessageWithKeyboard(ctx, msg.Chat.ID, msg.MessageThreadID, text, keyboard, messageOptions{}); err != nil {
+		b.logger.Error("failed to send draft confirmation", "error", err)
+	}
+}
+
+// handleSendConfirm handles the confirmation buttons of a composed email
+func (b *Bot) handleSendConfirm(ctx context.Context, callback *models.CallbackQuery, data appmodels.CallbackData) {
+	prompt := callback.Message.Message
+	if prompt == nil {
+		b.answerCallback(ctx, callback.ID, "Сообщение недоступно", false)
+		return
+	}
+
+	draft, err := b.db.GetSentMessage(ctx, data.MessageID)
+	if errors.Is(err, database.ErrNotFound) {
+		b.answerCallback(ctx, callback.ID, "Письмо не найдено", false)
+		return
+	}
+	if err != nil {
+		b.logger.Error("failed to get draft", "error", err)
+		b.answerCallback(ctx, callback.ID, "Ошибка получения письма", false)
+		return
+	}
+	if draft.CreatedBy != callback.From.ID {
+		b.answerCallback(ctx, callback.ID, "Подтвердить отправку может только автор письма", true)
+		return
+	}
+
+	account, err := b.db.GetAccountByID(ctx, draft.AccountID)
+	if err != nil || account.ChatID != prompt.Chat.ID {
+		b.answerCallback(ctx, callback.ID, "Почта этого письма больше не подключена", true)
+		return
+	}
+
+	expired := time.Since(draft.CreatedAt) > sendConfirmTTL
+	if data.Arg != "yes" || expired {
+		if draft.Status == appmodels.SentDraft {
+			draft.Status = appmodels.SentCancelled
+			if err := b.db.UpdateSentMessageStatus(ctx, draft); err != nil {
+				b.logger.Error("failed to cancel draft", "error", err)
+			}
+		}
+		if expired {
+			b.editMessageText(ctx, prompt.Chat.ID, prompt.ID, "Подтверждение устарело, составьте письмо заново: /send")
+			b.answerCallback(ctx, callback.ID, "Подтверждение устарело", true)
+			return
+		}
+		b.editMessageText(ctx, prompt.Chat.ID, prompt.ID, "Отправка письма отменена")
+		b.answerCallback(ctx, callback.ID, "Отменено", false)
+		return
+	}
+
+	claimed, err := b.db.ClaimSentDraft(ctx, draft.ID)
+	if err != nil {
+		b.logger.Error("failed to claim draft", "error", err)
+		b.answerCallback(ctx, callback.ID, "Ошибка отправки", false)
+		return
+	}
+	if !claimed {
+		b.answerCallback(ctx, callback.ID, "Письмо уже отправлено или отменено", false)
+		return
+	}
+	b.answerCallback(ctx, callback.ID, "Отправляю...", false)
+
+	recipient := html.EscapeString(draft.ToAddr)
+	sent, server, err := b.sendDraft(ctx, account, draft)
+	if err != nil {
+		b.logger.Error("failed to send email", "error", err, "account_id", account.ID, "server", server)
+		draft.Status, draft.Error = appmodels.SentFailed, err.Error()
+		if err := b.db.UpdateSentMessageStatus(ctx, draft); err != nil {
+			b.logger.Error("failed to update sent message", "error", err)
+		}
+		b.editMessageText(ctx, prompt.Chat.ID, prompt.ID,
+			i18n.Tf(ctx, "Не удалось отправить письмо для %s через %s:\n<code>%s</code>\n\n%s",
+				recipient, html.EscapeString(server), html.EscapeString(err.Error()), i18n.T(ctx, authHint)))
+		return
+	}
+
+	now := time.Now()
+	draft.Status, draft.MessageID, draft.SentAt = appmodels.SentSent, sent.MessageID, &now
+	if err := b.db.UpdateSentMessageStatus(ctx, draft); err != nil {
+		b.logger.Error("failed to update sent message", "error", err)
+	}
+	b.logger.Info("email sent", "account_id", account.ID, "sent_id", draft.ID, "user_id", callback.From.ID)
+
+	text := i18n.Tf(ctx, "✉️ Письмо отправлено: %s\nТема: %s", recipient, html.EscapeString(draft.Subject))
+	if !b.saveToSent(ctx, account, sent.Raw) {
+		text += i18n.T(ctx, "\n⚠️ Не удалось сохранить копию в папку «Отправленные»")
+	}
+	b.editMessageText(ctx, prompt.Chat.ID, prompt.ID, text)
+}
+
+// sendDraft sends a confirmed email over the account's SMTP server, which is
+// returned for error messages
+func (b *Bot) sendDraft(ctx context.Context, account *appmodels.EmailAccount, draft *appmodels.SentMessage) (*smtp.Sent, string, error) {
+	server, err := b.accountSMTPServer(ctx, account)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to resolve SMTP server: %w", err)
+	}
+
+	password, err := b.decryptPassword(account.Password)
+	if err != nil {
+		return nil, server, fmt.Errorf("failed to decrypt password: %w", err)
+	}
+
+	sendCtx, cancel := context.WithTimeout(ctx, replySendTimeout)
+	defer cancel()
+
+	sent, err := smtp.Send(sendCtx, smtp.Config{
+		Server:      server,
+		Username:    account.Email,
+		Password:    password,
+		DialTimeout: b.config.IMAPDialTimeout,
+	}, smtp.Message{
+		From:    account.Email,
+		To:      draft.ToAddr,
+		Subject: draft.Subject,
+		Body:    draft.Body,
+	})
+	return sent, server, err
+}
@@ -0,0 +1,118 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/mixelka/emailresend/internal/i18n"
+	appmodels "github.com/mixelka/emailresend/pkg/models"
+)
+
+const (
+	// senderDigestInterval is how often finished digests are checked
+	senderDigestInterval = time.Minute
+	// senderDigestMaxItems is the number of subjects listed in a digest
+	senderDigestMaxItems = 10
+)
+
+// throttleSender holds back an email if its sender exceeded the hourly limit
+// in the topic. Returns true if the email went to the sender's digest.
+func (b *Bot) throttleSender(ctx context.Context, account *appmodels.EmailAccount, msg *appmodels.EmailMessage, codes []appmodels.DetectedCode) bool {
+	limit := b.config.SenderHourlyLimit
+	if limit <= 0 || msg.FromAddr == "" || isPriorityEmail(account, msg, codes) {
+		return false
+	}
+
+	hourStart := msg.CreatedAt.Truncate(time.Hour)
+	count, err := b.db.CountSenderMessages(ctx, account.ID, msg.FromAddr, hourStart, msg.ID)
+	if err != nil {
+		b.logger.Error("failed to count sender messages", "error", err)
+		return false
+	}
+	if count <= limit {
+		return false
+	}
+
+	held, err := b.db.AddToSenderDigest(ctx, account.ID, msg.FromAddr, hourStart)
+	if err != nil {
+		b.logger.Error("failed to add message to sender digest", "error", err)
+		return false
+	}
+
+	if held == 1 {
+		b.logger.Warn("sender exceeded hourly limit, switching to digest",
+			"account_id", account.ID,
+			"from", msg.FromAddr,
+			"limit", limit,
+		)
+		b.sendMessage(ctx, account.ChatID, account.TopicID, i18n.Tf(ctx,
+			"Отправитель <b>%s</b> прислал больше %d писем за час. Остальные письма от него до %s придут одной сводкой.",
+			html.EscapeString(msg.FromAddr), limit, hourStart.Add(time.Hour).Format("15:04")))
+	}
+	return true
+}
+
+// runSenderDigests sends digests of throttled senders once their hour ends
+func (b *Bot) runSenderDigests(ctx context.Context) {
+	ticker := time.NewTicker(senderDigestInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		digests, err := b.db.GetDueSenderDigests(ctx, b.accountBotID(), time.Now().Truncate(time.Hour))
+		if err != nil {
+			b.logger.Error("failed to load sender digests", "error", err)
+			continue
+		}
+
+		for _, digest := range digests {
+			if err := b.sendSenderDigest(ctx, digest); err != nil {
+				b.logger.Warn("failed to send sender digest", "error", err, "account_id", digest.AccountID)
+				break
+			}
+		}
+	}
+}
+
+// sendSenderDigest posts the summary of a throttled sender's hour
+func (b *Bot) sendSenderDigest(ctx context.Context, digest *appmodels.SenderDigest) error {
+	account, err := b.db.GetAccountByID(ctx, digest.AccountID)
+	if err != nil {
+		return err
+	}
+	ctx = b.chatContext(ctx, account.ChatID)
+
+	hourEnd := digest.HourStart.Add(time.Hour)
+	messages, err := b.db.GetUndeliveredSenderMessages(ctx, digest.AccountID, digest.FromAddr, digest.HourStart, hourEnd, senderDigestMaxItems)
+	if err != nil {
+		return err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(i18n.Tf(ctx, "<b>Сводка: %s</b>\n", html.EscapeString(digest.FromAddr)))
+	sb.WriteString(i18n.Tf(ctx, "Не отправлено отдельно за %s–%s: %d\n\n",
+		digest.HourStart.Format("15:04"), hourEnd.Format("15:04"), digest.Count))
+	for _, msg := range messages {
+		sb.WriteString(fmt.Sprintf("• %s %s\n", msg.CreatedAt.Format("15:04"), html.EscapeString(msg.Subject)))
+	}
+	if digest.Count > len(messages) {
+		sb.WriteString(i18n.Tf(ctx, "… и ещё %d\n", digest.Count-len(messages)))
+	}
+
+	if _, err := b.sendMessageWithKeyboard(ctx, account.ChatID, account.TopicID, sb.String(), nil, messageOptions{
+		DisableNotification: true,
+		ProtectContent:      account.ProtectContent,
+	}); err != nil {
+		return err
+	}
+
+	return b.db.DeleteSenderDigest(ctx, digest)
+}
@@ -0,0 +1,1203 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/mixelka/emailresend/internal/database"
+	"github.com/mixelka/emailresend/internal/email"
+	"github.com/mixelka/emailresend/internal/formatter"
+	"github.com/mixelka/emailresend/internal/i18n"
+	appmodels "github.com/mixelka/emailresend/pkg/models"
+)
+
+// handleParseMode handles /parsemode command
+// Usage: /parsemode [html|markdown]
+func (b *Bot) handleParseMode(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	settings, err := b.db.GetChatSettings(ctx, msg.Chat.ID)
+	if err != nil {
+		b.logger.Error("failed to get chat settings", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка получения настроек")
+		return
+	}
+
+	parts := strings.Fields(msg.Text)
+	if len(parts) < 2 {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+			i18n.Tf(ctx, "Текущий режим форматирования: <b>%s</b>\n\nИспользование: <code>/parsemode html</code> или <code>/parsemode markdown</code>", settings.ParseMode))
+		return
+	}
+
+	if !b.checkAdmin(ctx, msg, "Только администраторы могут менять настройки") {
+		return
+	}
+
+	switch strings.ToLower(parts[1]) {
+	case "html":
+		settings.ParseMode = appmodels.ParseModeHTML
+	case "markdown", "markdownv2", "md":
+		settings.ParseMode = appmodels.ParseModeMarkdownV2
+	default:
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Неизвестный режим. Доступно: <code>html</code>, <code>markdown</code>")
+		return
+	}
+
+	if err := b.db.SaveChatSettings(ctx, settings); err != nil {
+		b.logger.Error("failed to save chat settings", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка сохранения настроек")
+		return
+	}
+
+	b.logger.Info("parse mode changed", "chat_id", msg.Chat.ID, "parse_mode", settings.ParseMode)
+	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+		i18n.Tf(ctx, "Режим форматирования писем: <b>%s</b>", settings.ParseMode))
+}
+
+// handleLanguage handles /language command
+// Usage: /language [ru|en]
+func (b *Bot) handleLanguage(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	settings, err := b.db.GetChatSettings(ctx, msg.Chat.ID)
+	if err != nil {
+		b.logger.Error("failed to get chat settings", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка получения настроек")
+		return
+	}
+
+	parts := strings.Fields(msg.Text)
+	if len(parts) < 2 {
+		codes := make([]string, len(i18n.Languages))
+		for i, lang := range i18n.Languages {
+			codes[i] = "<code>" + lang + "</code> — " + i18n.Name(lang)
+		}
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.Tf(ctx,
+			"Язык бота: <b>%s</b>\n\nДоступно:\n%s\n\nИспользование: <code>/language en</code>",
+			i18n.Name(settings.Language), strings.Join(codes, "\n")))
+		return
+	}
+
+	// Anyone may choose the language of their private chat
+	if msg.Chat.Type != "private" && !b.checkAdmin(ctx, msg, "Только администраторы могут менять настройки") {
+		return
+	}
+
+	lang := strings.ToLower(parts[1])
+	if !i18n.Supported(lang) {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.Tf(ctx,
+			"Неизвестный язык. Доступно: <code>%s</code>", strings.Join(i18n.Languages, "</code>, <code>")))
+		return
+	}
+
+	settings.Language = lang
+	if err := b.db.SaveChatSettings(ctx, settings); err != nil {
+		b.logger.Error("failed to save chat settings", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка сохранения настроек")
+		return
+	}
+
+	b.logger.Info("language changed", "chat_id", msg.Chat.ID, "language", lang)
+	ctx = i18n.WithLang(ctx, lang)
+	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.Tf(ctx, "Язык бота: <b>%s</b>", i18n.Name(lang)))
+}
+
+// handleAutoTopics handles /autotopics command: /connect and /create sent
+// in General create a topic named after the mailbox
+// Usage: /autotopics [on|off]
+func (b *Bot) handleAutoTopics(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	settings, err := b.db.GetChatSettings(ctx, msg.Chat.ID)
+	if err != nil {
+		b.logger.Error("failed to get chat settings", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка получения настроек")
+		return
+	}
+
+	parts := strings.Fields(msg.Text)
+	if len(parts) < 2 {
+		state := i18n.T(ctx, "выключены")
+		if settings.AutoTopics {
+			state = i18n.T(ctx, "включены")
+		}
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+			i18n.Tf(ctx, "Топики для новых ящиков: <b>%s</b>\n\nКогда включено, /connect и /create в General создают топик с именем ящика и подключают почту к нему.\n\nИспользование: <code>/autotopics on</code> или <code>/autotopics off</code>", state))
+		return
+	}
+
+	if !b.checkAdmin(ctx, msg, "Только администраторы могут менять настройки") {
+		return
+	}
+
+	switch strings.ToLower(parts[1]) {
+	case "on":
+		settings.AutoTopics = true
+	case "off":
+		settings.AutoTopics = false
+	default:
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Использование: <code>/autotopics on</code> или <code>/autotopics off</code>")
+		return
+	}
+
+	if err := b.db.SaveChatSettings(ctx, settings); err != nil {
+		b.logger.Error("failed to save chat settings", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка сохранения настроек")
+		return
+	}
+
+	b.logger.Info("auto topics changed", "chat_id", msg.Chat.ID, "enabled", settings.AutoTopics)
+	if settings.AutoTopics {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "/connect и /create в General будут создавать топик для каждого ящика")
+	} else {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "/connect и /create будут подключать почту к топику, где отправлена команда")
+	}
+}
+
+// handleEmoji handles /emoji command
+// Usage: /emoji [icon] [custom_emoji_id|reset], or /emoji icon followed by a custom emoji
+func (b *Bot) handleEmoji(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	settings, err := b.db.GetChatSettings(ctx, msg.Chat.ID)
+	if err != nil {
+		b.logger.Error("failed to get chat settings", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка получения настроек")
+		return
+	}
+
+	parts := strings.Fields(msg.Text)
+	if len(parts) < 2 {
+		customEmoji := settings.CustomEmojiMap()
+		var sb strings.Builder
+		sb.WriteString(i18n.T(ctx, "<b>Иконки:</b>\n\n"))
+		for _, name := range []string{
+			formatter.IconEmail, formatter.IconCode, formatter.IconNoReply,
+			formatter.IconConnected, formatter.IconReconnecting, formatter.IconDisconnected,
+		} {
+			sb.WriteString(fmt.Sprintf("%s <code>%s</code>\n", formatter.RenderIcon(models.ParseModeHTML, customEmoji, name), name))
+		}
+		sb.WriteString(i18n.T(ctx, "\nИспользование: <code>/emoji code 5368324170671202286</code>\nИли отправьте <code>/emoji code</code> вместе с премиум-эмодзи\nСброс: <code>/emoji code reset</code>"))
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, sb.String())
+		return
+	}
+
+	if !b.checkAdmin(ctx, msg, "Только администраторы могут менять настройки") {
+		return
+	}
+
+	name := strings.ToLower(parts[1])
+	if _, ok := formatter.DefaultIcons[name]; !ok {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.Tf(ctx, "Неизвестная иконка: <code>%s</code>", html.EscapeString(name)))
+		return
+	}
+
+	// Prefer a custom emoji entity attached to the message
+	var emojiID string
+	for _, entity := range msg.Entities {
+		if entity.Type == models.MessageEntityTypeCustomEmoji {
+			emojiID = entity.CustomEmojiID
+			break
+		}
+	}
+	if emojiID == "" && len(parts) >= 3 {
+		emojiID = parts[2]
+	}
+
+	switch {
+	case emojiID == "reset":
+		emojiID = ""
+	case emojiID == "":
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Укажите ID кастомного эмодзи или отправьте сам эмодзи")
+		return
+	case !isDigits(emojiID):
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "ID кастомного эмодзи должен состоять из цифр")
+		return
+	}
+
+	settings.SetCustomEmoji(name, emojiID)
+	if err := b.db.SaveChatSettings(ctx, settings); err != nil {
+		b.logger.Error("failed to save chat settings", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка сохранения настроек")
+		return
+	}
+
+	icon := formatter.RenderIcon(models.ParseModeHTML, settings.CustomEmojiMap(), name)
+	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.Tf(ctx, "Иконка <code>%s</code>: %s", name, icon))
+}
+
+// isDigits reports whether s is a non-empty string of ASCII digits
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// getTopicAccount returns the account connected to the message's topic,
+// replying with an error if there is none
+func (b *Bot) getTopicAccount(ctx context.Context, msg *models.Message) (*appmodels.EmailAccount, bool) {
+	account, err := b.db.GetAccountByChatAndTopic(ctx, msg.Chat.ID, msg.MessageThreadID, b.accountBotID())
+	if errors.Is(err, database.ErrNotFound) {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, noAccountText(msg))
+		return nil, false
+	}
+	if err != nil {
+		b.logger.Error("failed to get account", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка получения информации об аккаунте")
+		return nil, false
+	}
+	return account, true
+}
+
+// noAccountText tells that no mailbox is connected where msg was sent: a
+// topic of a forum or a chat without topics
+func noAccountText(msg *models.Message) string {
+	if !msg.Chat.IsForum {
+		return "В этом чате нет подключенной почты"
+	}
+	return "В этом топике нет подключенной почты"
+}
+
+// handleSilent handles /silent command
+// Usage: /silent [on|off]
+func (b *Bot) handleSilent(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	account, ok := b.getTopicAccount(ctx, msg)
+	if !ok {
+		return
+	}
+
+	parts := strings.Fields(msg.Text)
+	if len(parts) < 2 {
+		state := i18n.T(ctx, "выключен")
+		if account.Silent {
+			state = i18n.T(ctx, "включён")
+		}
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+			i18n.Tf(ctx, "Тихий режим: <b>%s</b>\n\nИспользование: <code>/silent on</code> или <code>/silent off</code>\nПисьма с кодами и приоритетные письма (/priority) всегда приходят со звуком.", state))
+		return
+	}
+
+	if !b.checkAdmin(ctx, msg, "Только администраторы могут менять настройки") {
+		return
+	}
+
+	switch strings.ToLower(parts[1]) {
+	case "on":
+		account.Silent = true
+	case "off":
+		account.Silent = false
+	default:
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Использование: <code>/silent on</code> или <code>/silent off</code>")
+		return
+	}
+
+	if err := b.db.UpdateAccountSettings(ctx, account); err != nil {
+		b.logger.Error("failed to update account settings", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка сохранения настроек")
+		return
+	}
+
+	if account.Silent {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Тихий режим включён: письма будут приходить без звука")
+	} else {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Тихий режим выключен")
+	}
+}
+
+// handlePriority handles /priority command
+// Usage: /priority [regex|off]
+func (b *Bot) handlePriority(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	account, ok := b.getTopicAccount(ctx, msg)
+	if !ok {
+		return
+	}
+
+	pattern := strings.TrimSpace(strings.TrimPrefix(msg.Text, strings.Fields(msg.Text)[0]))
+	if pattern == "" {
+		current := i18n.T(ctx, "не задан")
+		if account.PriorityPattern != "" {
+			current = "<code>" + html.EscapeString(account.PriorityPattern) + "</code>"
+		}
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+			i18n.Tf(ctx, "Приоритетный шаблон: %s\n\nПисьма, тема или отправитель которых совпадает с шаблоном, приходят со звуком даже в тихом режиме.\n\nИспользование: <code>/priority (?i)alert|critical</code>\nОтключить: <code>/priority off</code>", current))
+		return
+	}
+
+	if !b.checkAdmin(ctx, msg, "Только администраторы могут менять настройки") {
+		return
+	}
+
+	if pattern == "off" {
+		pattern = ""
+	} else if _, err := regexp.Compile(pattern); err != nil {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.Tf(ctx, "Некорректное регулярное выражение: <code>%s</code>", html.EscapeString(err.Error())))
+		return
+	}
+
+	account.PriorityPattern = pattern
+	if err := b.db.UpdateAccountSettings(ctx, account); err != nil {
+		b.logger.Error("failed to update account settings", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка сохранения настроек")
+		return
+	}
+
+	if pattern == "" {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Приоритетный шаблон отключён")
+	} else {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.Tf(ctx, "Приоритетный шаблон: <code>%s</code>", html.EscapeString(pattern)))
+	}
+}
+
+// handleProtect handles /protect command
+// Usage: /protect [on|off]
+func (b *Bot) handleProtect(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	account, ok := b.getTopicAccount(ctx, msg)
+	if !ok {
+		return
+	}
+
+	parts := strings.Fields(msg.Text)
+	if len(parts) < 2 {
+		state := i18n.T(ctx, "выключена")
+		if account.ProtectContent {
+			state = i18n.T(ctx, "включена")
+		}
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+			i18n.Tf(ctx, "Защита содержимого: <b>%s</b>\n\nИспользование: <code>/protect on</code> или <code>/protect off</code>\nЗащищённые письма нельзя переслать, скопировать или сохранить.", state))
+		return
+	}
+
+	if !b.checkAdmin(ctx, msg, "Только администраторы могут менять настройки") {
+		return
+	}
+
+	switch strings.ToLower(parts[1]) {
+	case "on":
+		account.ProtectContent = true
+	case "off":
+		account.ProtectContent = false
+	default:
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Использование: <code>/protect on</code> или <code>/protect off</code>")
+		return
+	}
+
+	if err := b.db.UpdateAccountSettings(ctx, account); err != nil {
+		b.logger.Error("failed to update account settings", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка сохранения настроек")
+		return
+	}
+
+	if account.ProtectContent {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Защита содержимого включена: новые письма нельзя будет переслать или скопировать")
+	} else {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Защита содержимого выключена")
+	}
+}
+
+// handleCollapse handles /collapse command
+// Usage: /collapse [window|off]
+func (b *Bot) handleCollapse(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	account, ok := b.getTopicAccount(ctx, msg)
+	if !ok {
+		return
+	}
+
+	parts := strings.Fields(msg.Text)
+	if len(parts) < 2 {
+		state := i18n.T(ctx, "выключена")
+		if account.CollapseWindow > 0 {
+			state = i18n.T(ctx, "окно ") + shortDuration(time.Duration(account.CollapseWindow)*time.Second)
+		}
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+			i18n.Tf(ctx, "Группировка одинаковых писем: <b>%s</b>\n\nПисьма с одинаковой темой (без учёта чисел), пришедшие в пределах окна, обновляют одно сообщение со счётчиком вместо новых сообщений.\n\nИспользование: <code>/collapse 30m</code>\nОтключить: <code>/collapse off</code>", state))
+		return
+	}
+
+	if !b.checkAdmin(ctx, msg, "Только администраторы могут менять настройки") {
+		return
+	}
+
+	if strings.ToLower(parts[1]) == "off" {
+		account.CollapseWindow = 0
+	} else {
+		window, err := time.ParseDuration(parts[1])
+		if err != nil || window < time.Minute || window > 7*24*time.Hour {
+			b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Укажите окно от 1m до 168h, например <code>/collapse 30m</code>")
+			return
+		}
+		account.CollapseWindow = int(window / time.Second)
+	}
+
+	if err := b.db.UpdateAccountSettings(ctx, account); err != nil {
+		b.logger.Error("failed to update account settings", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка сохранения настроек")
+		return
+	}
+
+	if account.CollapseWindow > 0 {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+			i18n.Tf(ctx, "Группировка включена: окно %s", shortDuration(time.Duration(account.CollapseWindow)*time.Second)))
+	} else {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Группировка одинаковых писем отключена")
+	}
+}
+
+// handlePinCodes handles /pincodes command
+// Usage: /pincodes [duration|off]
+func (b *Bot) handlePinCodes(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	account, ok := b.getTopicAccount(ctx, msg)
+	if !ok {
+		return
+	}
+
+	parts := strings.Fields(msg.Text)
+	if len(parts) < 2 {
+		state := i18n.T(ctx, "выключено")
+		if account.PinCodes > 0 {
+			state = i18n.Tf(ctx, "на %s", shortDuration(time.Duration(account.PinCodes)*time.Second))
+		}
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+			i18n.Tf(ctx, "Закрепление писем с кодами: <b>%s</b>\n\nПисьмо с найденным кодом закрепляется в топике и открепляется по истечении времени или после нажатия «Прочитано».\n\nИспользование: <code>/pincodes 10m</code>\nОтключить: <code>/pincodes off</code>", state))
+		return
+	}
+
+	if !b.checkAdmin(ctx, msg, "Только администраторы могут менять настройки") {
+		return
+	}
+
+	if strings.ToLower(parts[1]) == "off" {
+		account.PinCodes = 0
+	} else {
+		ttl, err := time.ParseDuration(parts[1])
+		if err != nil || ttl < time.Minute || ttl > 24*time.Hour {
+			b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Укажите время от 1m до 24h, например <code>/pincodes 10m</code>")
+			return
+		}
+		account.PinCodes = int(ttl / time.Second)
+	}
+
+	if err := b.db.UpdateAccountSettings(ctx, account); err != nil {
+		b.logger.Error("failed to update account settings", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка сохранения настроек")
+		return
+	}
+
+	if account.PinCodes > 0 {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+			i18n.Tf(ctx, "Письма с кодами будут закрепляться на %s. Дайте боту право закреплять сообщения", shortDuration(time.Duration(account.PinCodes)*time.Second)))
+	} else {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Закрепление писем с кодами отключено")
+	}
+}
+
+// handleBulk handles /bulk command
+// Usage: /bulk [deliver|drop|digest|topic topic_id]
+func (b *Bot) handleBulk(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	account, ok := b.getTopicAccount(ctx, msg)
+	if !ok {
+		return
+	}
+
+	parts := strings.Fields(msg.Text)
+	if len(parts) < 2 {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+			i18n.Tf(ctx, "Спам и рассылки: <b>%s</b>\n\n"+
+				"Спамом считаются письма, помеченные почтовым сервером (X-Spam-Flag, X-Spam-Status), рассылками — письма с List-Unsubscribe, List-Id или Precedence: bulk. Письма с кодами приходят всегда.\n\n"+
+				"<code>/bulk deliver</code> — публиковать как обычно\n"+
+				"<code>/bulk drop</code> — не публиковать\n"+
+				"<code>/bulk digest</code> — собирать в сводку, она приходит %s\n"+
+				"<code>/bulk topic ID_топика</code> — публиковать без звука в отдельный топик",
+				b.bulkModeText(ctx, account), b.digestSchedule(ctx, account)))
+		return
+	}
+
+	if !b.checkAdmin(ctx, msg, "Только администраторы могут менять настройки") {
+		return
+	}
+
+	switch strings.ToLower(parts[1]) {
+	case "deliver", "off":
+		account.BulkMode = appmodels.BulkDeliver
+	case "drop":
+		account.BulkMode = appmodels.BulkDrop
+	case "digest":
+		account.BulkMode = appmodels.BulkDigest
+	case "topic":
+		if len(parts) < 3 {
+			b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Использование: <code>/bulk topic ID_топика</code>")
+			return
+		}
+		topicID, err := strconv.Atoi(parts[2])
+		if err != nil || topicID <= 0 {
+			b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Некорректный ID топика (его можно узнать в /status)")
+			return
+		}
+		if topicID == account.TopicID {
+			b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Топик для рассылок должен отличаться от топика почты")
+			return
+		}
+		account.BulkMode = appmodels.BulkTopic
+		account.BulkTopicID = topicID
+	default:
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Использование: <code>/bulk deliver|drop|digest|topic ID_топика</code>")
+		return
+	}
+
+	if err := b.db.UpdateAccountSettings(ctx, account); err != nil {
+		b.logger.Error("failed to update account settings", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка сохранения настроек")
+		return
+	}
+
+	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.Tf(ctx, "Спам и рассылки: <b>%s</b>", b.bulkModeText(ctx, account)))
+}
+
+// bulkModeText describes what happens to spam and newsletters of an account
+func (b *Bot) bulkModeText(ctx context.Context, account *appmodels.EmailAccount) string {
+	switch account.BulkMode {
+	case appmodels.BulkDrop:
+		return i18n.T(ctx, "не публикуются")
+	case appmodels.BulkDigest:
+		return i18n.Tf(ctx, "в сводку, она приходит %s", b.digestSchedule(ctx, account))
+	case appmodels.BulkTopic:
+		return i18n.Tf(ctx, "без звука в топик <code>%d</code>", account.BulkTopicID)
+	default:
+		return i18n.T(ctx, "публикуются как обычно")
+	}
+}
+
+// handleDigest handles /digest command
+// Usage: /digest [off|daily|weekly]
+func (b *Bot) handleDigest(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	account, ok := b.getTopicAccount(ctx, msg)
+	if !ok {
+		return
+	}
+
+	parts := strings.Fields(msg.Text)
+	if len(parts) < 2 {
+		state := i18n.T(ctx, "выключена")
+		if account.DigestMode != appmodels.DigestOff {
+			state = b.digestSchedule(ctx, account)
+		}
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+			i18n.Tf(ctx, "Сводка писем: <b>%s</b>\n\nВ режиме сводки сразу приходят только письма с кодами и подходящие под /priority, остальные собираются в одно сообщение.\n\nИспользование: <code>/digest daily</code> или <code>/digest weekly</code>\nОтключить: <code>/digest off</code>", state))
+		return
+	}
+
+	if !b.checkAdmin(ctx, msg, "Только администраторы могут менять настройки") {
+		return
+	}
+
+	switch strings.ToLower(parts[1]) {
+	case "off":
+		account.DigestMode = appmodels.DigestOff
+	case appmodels.DigestDaily, appmodels.DigestWeekly:
+		account.DigestMode = strings.ToLower(parts[1])
+	default:
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Использование: <code>/digest daily|weekly|off</code>")
+		return
+	}
+
+	if err := b.db.UpdateAccountSettings(ctx, account); err != nil {
+		b.logger.Error("failed to update account settings", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка сохранения настроек")
+		return
+	}
+
+	if account.DigestMode != appmodels.DigestOff {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+			i18n.Tf(ctx, "Письма без кодов будут приходить сводкой %s", b.digestSchedule(ctx, account)))
+	} else {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Сводка отключена, накопленные письма придут в ближайшей сводке")
+	}
+}
+
+// handleQuiet handles /quiet command
+// Usage: /quiet [23:00-08:00 [timezone] [silent|hold]|off]
+func (b *Bot) handleQuiet(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	account, ok := b.getTopicAccount(ctx, msg)
+	if !ok {
+		return
+	}
+
+	parts := strings.Fields(msg.Text)
+	if len(parts) < 2 {
+		state := i18n.T(ctx, "выключены")
+		if account.QuietHours != "" {
+			state = quietSchedule(ctx, account)
+		}
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+			i18n.Tf(ctx, "Тихие часы: <b>%s</b>\n\nВ тихие часы письма приходят без звука (<code>silent</code>) или откладываются до их конца (<code>hold</code>). Письма с кодами и подходящие под /priority приходят как обычно.\n\nИспользование: <code>/quiet 23:00-08:00 Europe/Moscow hold</code>\nОтключить: <code>/quiet off</code>", state))
+		return
+	}
+
+	if !b.checkAdmin(ctx, msg, "Только администраторы могут менять настройки") {
+		return
+	}
+
+	if strings.EqualFold(parts[1], "off") {
+		account.QuietHours = ""
+		account.QuietTimezone = ""
+		account.QuietMode = appmodels.QuietSilent
+	} else {
+		if _, _, err := appmodels.ParseQuietHours(parts[1]); err != nil {
+			b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Использование: <code>/quiet 23:00-08:00 [часовой пояс] [silent|hold]</code> или <code>/quiet off</code>")
+			return
+		}
+		timezone, mode := "", appmodels.QuietSilent
+		for _, arg := range parts[2:] {
+			switch strings.ToLower(arg) {
+			case "silent":
+				mode = appmodels.QuietSilent
+			case appmodels.QuietHold:
+				mode = appmodels.QuietHold
+			default:
+				if _, err := time.LoadLocation(arg); err != nil || arg == "Local" {
+					b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+						i18n.Tf(ctx, "Неизвестный часовой пояс <code>%s</code>. Укажите его как <code>Europe/Moscow</code> или <code>UTC</code>", html.EscapeString(arg)))
+					return
+				}
+				timezone = arg
+			}
+		}
+		account.QuietHours = parts[1]
+		account.QuietTimezone = timezone
+		account.QuietMode = mode
+	}
+
+	if err := b.db.UpdateAccountSettings(ctx, account); err != nil {
+		b.logger.Error("failed to update account settings", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка сохранения настроек")
+		return
+	}
+
+	// Held emails are checked against the new schedule
+	if err := b.db.ReleaseHeldMessages(ctx, account.ID); err != nil {
+		b.logger.Error("failed to release held messages", "error", err)
+	}
+	b.wakeDelivery()
+
+	if account.QuietHours != "" {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.Tf(ctx, "Тихие часы: %s", quietSchedule(ctx, account)))
+	} else {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Тихие часы отключены, отложенные письма будут опубликованы")
+	}
+}
+
+// quietSchedule describes the quiet hours of an account
+func quietSchedule(ctx context.Context, account *appmodels.EmailAccount) string {
+	timezone := account.QuietTimezone
+	if timezone == "" {
+		timezone = i18n.T(ctx, "время сервера")
+	}
+	if account.QuietMode == appmodels.QuietHold {
+		return i18n.Tf(ctx, "%s (%s), письма откладываются до конца", account.QuietHours, timezone)
+	}
+	return i18n.Tf(ctx, "%s (%s), письма приходят без звука", account.QuietHours, timezone)
+}
+
+// handleProfile handles /profile command
+// Usage: /profile [detailed|compact|minimal]
+func (b *Bot) handleProfile(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	account, ok := b.getTopicAccount(ctx, msg)
+	if !ok {
+		return
+	}
+
+	current := formatter.GetProfile(account.FormatProfile)
+	parts := strings.Fields(msg.Text)
+	if len(parts) < 2 {
+		var sb strings.Builder
+		sb.WriteString(i18n.T(ctx, "<b>Оформление писем в этом топике:</b>\n\n"))
+		for _, p := range formatter.Profiles() {
+			mark := "▫️"
+			if p.Name == current.Name {
+				mark = "▪️"
+			}
+			sb.WriteString(fmt.Sprintf("%s <code>%s</code> — %s\n", mark, p.Name, i18n.T(ctx, p.Description)))
+		}
+		sb.WriteString(i18n.T(ctx, "\nИспользование: <code>/profile compact</code>"))
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, sb.String())
+		return
+	}
+
+	if !b.checkAdmin(ctx, msg, "Только администраторы могут менять настройки") {
+		return
+	}
+
+	name := strings.ToLower(parts[1])
+	if !formatter.IsProfile(name) {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Неизвестный профиль. Доступно: <code>detailed</code>, <code>compact</code>, <code>minimal</code>")
+		return
+	}
+	account.FormatProfile = name
+
+	if err := b.db.UpdateAccountSettings(ctx, account); err != nil {
+		b.logger.Error("failed to update account settings", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка сохранения настроек")
+		return
+	}
+
+	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+		i18n.Tf(ctx, "Профиль оформления: <b>%s</b> — %s", name, i18n.T(ctx, formatter.GetProfile(name).Description)))
+}
+
+// handleTemplate handles /template command: sets the layout of forwarded
+// emails of the topic's account
+// Usage: /template [text with {placeholders}|off]
+func (b *Bot) handleTemplate(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	account, ok := b.getTopicAccount(ctx, msg)
+	if !ok {
+		return
+	}
+
+	text := strings.TrimSpace(strings.TrimPrefix(msg.Text, strings.Fields(msg.Text)[0]))
+	if text == "" {
+		var sb strings.Builder
+		if account.MessageTemplate == "" {
+			sb.WriteString(i18n.Tf(ctx, "Шаблон не задан, письма оформляются по профилю <b>%s</b>.\n\n",
+				formatter.GetProfile(account.FormatProfile).Name))
+		} else {
+			sb.WriteString(i18n.T(ctx, "<b>Шаблон писем в этом топике:</b>\n"))
+			sb.WriteString("<pre>" + html.EscapeString(account.MessageTemplate) + "</pre>\n\n")
+		}
+		sb.WriteString(i18n.T(ctx, templateHelp))
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, sb.String())
+		return
+	}
+
+	if !b.checkAdmin(ctx, msg, "Только администраторы могут менять настройки") {
+		return
+	}
+
+	if strings.EqualFold(text, "off") {
+		account.MessageTemplate = ""
+	} else {
+		if _, err := formatter.ParseTemplate(text); err != nil {
+			b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.Tf(ctx, "Некорректный шаблон: <code>%s</code>", html.EscapeString(err.Error())))
+			return
+		}
+		account.MessageTemplate = text
+	}
+
+	if err := b.db.UpdateAccountSettings(ctx, account); err != nil {
+		b.logger.Error("failed to update account settings", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка сохранения настроек")
+		return
+	}
+
+	if account.MessageTemplate == "" {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+			i18n.Tf(ctx, "Шаблон отключён, письма оформляются по профилю <b>%s</b>", formatter.GetProfile(account.FormatProfile).Name))
+		return
+	}
+	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "✅ Шаблон сохранён")
+	b.previewTemplate(ctx, msg, account)
+}
+
+// templateHelp lists the placeholders of /template
+const templateHelp = `Плейсхолдеры:
+<code>{icon}</code> — значок отправителя, <code>{auth}</code> — ✅ или ⚠️ по проверке DKIM/SPF
+<code>{from}</code> — имя и адрес, <code>{sender}</code> — имя или адрес, <code>{email}</code> — адрес
+<code>{subject}</code> — тема
+<code>{date}</code> — дата, <code>{date:DD.MM HH:mm}</code> — в своём формате
+<code>{codes}</code> — коды
+<code>{attachments}</code> — имена вложений
+<code>{extras}</code> — заказ, поля и ссылки из письма
+<code>{body}</code> — текст письма, <code>{body:300}</code> — первые 300 символов
+
+<code>**текст**</code> — жирный. Строки, в которых все плейсхолдеры пустые, не выводятся; кнопки остаются как в профиле.
+
+Например:
+<code>/template {icon} **{sender}**: {subject}
+🔑 {codes}
+{body:500}</code>
+
+<code>/template off</code> — вернуть оформление профиля`
+
+// previewTemplate shows the latest email of the account in the new layout
+func (b *Bot) previewTemplate(ctx context.Context, msg *models.Message, account *appmodels.EmailAccount) {
+	messages, err := b.db.GetMessagesByAccount(ctx, account.ID, 0, 1)
+	if err != nil || len(messages) == 0 {
+		return
+	}
+	latest := messages[0]
+
+	settings, err := b.db.GetChatSettings(ctx, account.ChatID)
+	if err != nil {
+		b.logger.Error("failed to get chat settings", "error", err)
+		settings = appmodels.DefaultChatSettings(account.ChatID)
+	}
+	parseMode := models.ParseMode(settings.ParseMode)
+	avatarURL, domainEmoji := b.senderPicture(ctx, account, latest)
+	text, _ := b.formatter.FormatEmail(latest, decodeCodes(latest.DetectedCodes), formatter.FormatOptions{
+		ParseMode:   parseMode,
+		CustomEmoji: settings.CustomEmojiMap(),
+		Profile:     account.FormatProfile,
+		Template:    account.MessageTemplate,
+		DomainEmoji: domainEmoji,
+		Language:    i18n.Lang(ctx),
+	})
+
+	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Так будет выглядеть последнее письмо:")
+	if _, err := b.sendMessageWithKeyboard(ctx, msg.Chat.ID, msg.MessageThreadID, text, nil, messageOptions{ParseMode: parseMode, Preview: avatarURL}); err != nil {
+		b.logger.Warn("failed to send template preview", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+			i18n.Tf(ctx, "Telegram не принял сообщение письма: <code>%s</code>", html.EscapeString(err.Error())))
+	}
+}
+
+// handleAvatars handles /avatars command: shows the sender's picture or an
+// emoji of the sender's domain next to forwarded emails
+// Usage: /avatars [photo|emoji|off]
+func (b *Bot) handleAvatars(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	account, ok := b.getTopicAccount(ctx, msg)
+	if !ok {
+		return
+	}
+
+	parts := strings.Fields(msg.Text)
+	if len(parts) < 2 {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+			i18n.Tf(ctx, "Значки отправителей: <b>%s</b>\n\n<code>/avatars photo</code> — аватар отправителя из Gravatar над письмом, если его нет — эмодзи домена\n<code>/avatars emoji</code> — эмодзи домена отправителя рядом со значком\n<code>/avatars off</code> — выключить", avatarsModeText(ctx, account.Avatars)))
+		return
+	}
+
+	if !b.checkAdmin(ctx, msg, "Только администраторы могут менять настройки") {
+		return
+	}
+
+	switch strings.ToLower(parts[1]) {
+	case "photo":
+		account.Avatars = appmodels.AvatarsPhoto
+	case "emoji":
+		account.Avatars = appmodels.AvatarsEmoji
+	case "off":
+		account.Avatars = appmodels.AvatarsOff
+	default:
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Использование: <code>/avatars photo</code>, <code>/avatars emoji</code> или <code>/avatars off</code>")
+		return
+	}
+
+	if err := b.db.UpdateAccountSettings(ctx, account); err != nil {
+		b.logger.Error("failed to update account settings", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка сохранения настроек")
+		return
+	}
+
+	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+		i18n.Tf(ctx, "Значки отправителей: <b>%s</b>. Применяется к новым письмам.", avatarsModeText(ctx, account.Avatars)))
+}
+
+// avatarsModeText describes a sender picture mode
+func avatarsModeText(ctx context.Context, mode string) string {
+	switch mode {
+	case appmodels.AvatarsPhoto:
+		return i18n.T(ctx, "аватар отправителя")
+	case appmodels.AvatarsEmoji:
+		return i18n.T(ctx, "эмодзи домена")
+	}
+	return i18n.T(ctx, "выключены")
+}
+
+// handleIdle handles /idle command
+// Usage: /idle [auto|poll]
+func (b *Bot) handleIdle(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	account, ok := b.getTopicAccount(ctx, msg)
+	if !ok {
+		return
+	}
+
+	parts := strings.Fields(msg.Text)
+	if len(parts) < 2 {
+		mode := "auto"
+		if account.IdleMode == email.IdleModePoll {
+			mode = "poll"
+		}
+		state := i18n.Tf(ctx, "опрос раз в %s", shortDuration(b.pollInterval(account)))
+		if b.emailManager.UsingIdle(account.ID) {
+			state = i18n.T(ctx, "IMAP IDLE, письма приходят сразу")
+		}
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+			i18n.Tf(ctx, "Режим получения: <b>%s</b>\nСейчас: %s\n\nИспользование: <code>/idle auto</code> (IDLE, если сервер поддерживает) или <code>/idle poll</code> (только опрос)", mode, state))
+		return
+	}
+
+	if !b.checkAdmin(ctx, msg, "Только администраторы могут менять настройки") {
+		return
+	}
+
+	switch strings.ToLower(parts[1]) {
+	case "auto":
+		account.IdleMode = email.IdleModeAuto
+	case "poll":
+		account.IdleMode = email.IdleModePoll
+	default:
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Использование: <code>/idle auto</code> или <code>/idle poll</code>")
+		return
+	}
+
+	if err := b.db.UpdateAccountSettings(ctx, account); err != nil {
+		b.logger.Error("failed to update account settings", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка сохранения настроек")
+		return
+	}
+
+	if account.IsActive {
+		if err := b.emailManager.RestartAccount(ctx, account); err != nil {
+			b.logger.Error("failed to restart email client", "error", err, "account_id", account.ID)
+			b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.Tf(ctx, "Режим сохранён, но подключение не перезапущено: %v", err))
+			return
+		}
+	}
+
+	if account.IdleMode == email.IdleModePoll {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+			i18n.Tf(ctx, "Почта будет проверяться раз в %s", shortDuration(b.pollInterval(account))))
+	} else {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Почта будет получаться через IMAP IDLE, если сервер его поддерживает")
+	}
+}
+
+// Bounds of the mail check timings of /settings
+const (
+	minPollInterval = 10 * time.Second
+	maxPollInterval = time.Hour
+	minIdleTimeout  = time.Minute
+	// Servers may drop IDLE connections silent for 30 minutes (RFC 2177)
+	maxIdleTimeout = 29 * time.Minute
+)
+
+// handleSettings handles /settings command: how often the topic's mailbox
+// is polled and how long an IDLE lasts before it is restarted
+// Usage: /settings [poll|idle duration|default]
+func (b *Bot) handleSettings(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	account, ok := b.getTopicAccount(ctx, msg)
+	if !ok {
+		return
+	}
+
+	parts := strings.Fields(msg.Text)
+	if len(parts) < 2 {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, b.settingsText(ctx, account)+"\n\n"+i18n.T(ctx, settingsUsage))
+		return
+	}
+
+	if !b.checkAdmin(ctx, msg, "Только администраторы могут менять настройки") {
+		return
+	}
+	if len(parts) < 3 {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, settingsUsage)
+		return
+	}
+
+	var value time.Duration
+	if strings.ToLower(parts[2]) != "default" {
+		var err error
+		if value, err = time.ParseDuration(parts[2]); err != nil {
+			b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, settingsUsage)
+			return
+		}
+	}
+
+	switch strings.ToLower(parts[1]) {
+	case "poll":
+		if value != 0 && (value < minPollInterval || value > maxPollInterval) {
+			b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.Tf(ctx, "Укажите интервал опроса от %s до %s, например <code>/settings poll 30s</code>",
+				shortDuration(minPollInterval), shortDuration(maxPollInterval)))
+			return
+		}
+		account.PollInterval = int(value / time.Second)
+	case "idle":
+		if value != 0 && (value < minIdleTimeout || value > maxIdleTimeout) {
+			b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.Tf(ctx, "Укажите время IDLE от %s до %s, например <code>/settings idle 10m</code>",
+				shortDuration(minIdleTimeout), shortDuration(maxIdleTimeout)))
+			return
+		}
+		account.IdleTimeout = int(value / time.Second)
+	default:
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, settingsUsage)
+		return
+	}
+
+	if err := b.db.UpdateAccountSettings(ctx, account); err != nil {
+		b.logger.Error("failed to update account settings", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка сохранения настроек")
+		return
+	}
+
+	if account.IsActive {
+		if err := b.emailManager.RestartAccount(ctx, account); err != nil {
+			b.logger.Error("failed to restart email client", "error", err, "account_id", account.ID)
+			b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.Tf(ctx, "Настройки сохранены, но подключение не перезапущено: %v", err))
+			return
+		}
+	}
+
+	b.logger.Info("mail check timings changed", "account_id", account.ID, "poll_interval", account.PollInterval, "idle_timeout", account.IdleTimeout)
+	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.T(ctx, "✅ Настройки сохранены\n\n")+b.settingsText(ctx, account))
+}
+
+const settingsUsage = "Использование:\n" +
+	"<code>/settings poll 30s</code> — как часто проверять почту, когда IDLE не используется (от 10s до 1h)\n" +
+	"<code>/settings idle 10m</code> — через сколько перезапускать IDLE (от 1m до 29m)\n" +
+	"<code>/settings poll default</code> — вернуть значение по умолчанию"
+
+// settingsText describes the mail check timings of an account
+func (b *Bot) settingsText(ctx context.Context, account *appmodels.EmailAccount) string {
+	byDefault := func(set bool) string {
+		if set {
+			return ""
+		}
+		return i18n.T(ctx, " (по умолчанию)")
+	}
+
+	var sb strings.Builder
+	sb.WriteString(i18n.Tf(ctx, "<b>Получение почты %s</b>\n", account.Email))
+	sb.WriteString(i18n.Tf(ctx, "Опрос: раз в %s%s\n", shortDuration(b.pollInterval(account)), byDefault(account.PollInterval > 0)))
+	sb.WriteString(i18n.Tf(ctx, "IDLE: перезапуск каждые %s%s\n", shortDuration(b.idleTimeout(account)), byDefault(account.IdleTimeout > 0)))
+	state := i18n.T(ctx, "опрос")
+	if b.emailManager.UsingIdle(account.ID) {
+		state = "IMAP IDLE"
+	}
+	sb.WriteString(i18n.Tf(ctx, "Сейчас: %s (/idle)", state))
+	return sb.String()
+}
+
+// pollInterval returns how often an account is polled when it does not use IDLE
+func (b *Bot) pollInterval(account *appmodels.EmailAccount) time.Duration {
+	if account.PollInterval > 0 {
+		return time.Duration(account.PollInterval) * time.Second
+	}
+	return b.config.EmailPollInterval
+}
+
+// idleTimeout returns how long an IDLE of an account lasts before it is restarted
+func (b *Bot) idleTimeout(account *appmodels.EmailAccount) time.Duration {
+	if account.IdleTimeout > 0 {
+		return time.Duration(account.IdleTimeout) * time.Second
+	}
+	return b.config.IMAPIdleTimeout
+}
+
+// shortDuration formats a duration without trailing zero units ("30m", "1h30m")
+func shortDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+// handleExtractors handles /extractors command
+// Usage: /extractors [on|off name]
+func (b *Bot) handleExtractors(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	if b.extractors == nil {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Специальные обработчики отправителей не настроены")
+		return
+	}
+
+	settings, err := b.db.GetChatSettings(ctx, msg.Chat.ID)
+	if err != nil {
+		b.logger.Error("failed to get chat settings", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка получения настроек")
+		return
+	}
+
+	parts := strings.Fields(msg.Text)
+	if len(parts) < 3 {
+		var sb strings.Builder
+		sb.WriteString(i18n.T(ctx, "<b>Обработчики отправителей:</b>\n\n"))
+		for _, e := range b.extractors.List() {
+			state := "✅"
+			if !settings.ExtractorEnabled(e.Name()) {
+				state = "❌"
+			}
+			sb.WriteString(fmt.Sprintf("%s <code>%s</code> — %s\n", state, e.Name(), html.EscapeString(strings.Join(e.Domains(), ", "))))
+		}
+		sb.WriteString(i18n.T(ctx, "\nОни точнее находят коды, ссылки и данные в письмах этих отправителей.\n"))
+		sb.WriteString(i18n.T(ctx, "Использование: <code>/extractors off steam</code> или <code>/extractors on steam</code>"))
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, sb.String())
+		return
+	}
+
+	if !b.checkAdmin(ctx, msg, "Только администраторы могут менять настройки") {
+		return
+	}
+
+	name := strings.ToLower(parts[2])
+	if !b.extractors.Has(name) {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.Tf(ctx, "Неизвестный обработчик: <code>%s</code>", html.EscapeString(name)))
+		return
+	}
+
+	switch strings.ToLower(parts[1]) {
+	case "on":
+		settings.SetExtractorEnabled(name, true)
+	case "off":
+		settings.SetExtractorEnabled(name, false)
+	default:
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Использование: <code>/extractors on|off имя</code>")
+		return
+	}
+
+	if err := b.db.SaveChatSettings(ctx, settings); err != nil {
+		b.logger.Error("failed to save chat settings", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка сохранения настроек")
+		return
+	}
+
+	if settings.ExtractorEnabled(name) {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.Tf(ctx, "Обработчик <code>%s</code> включён", name))
+	} else {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.Tf(ctx, "Обработчик <code>%s</code> выключен, для этих писем используется общий поиск кодов", name))
+	}
+}
@@ -0,0 +1,266 @@
+package telegram
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"html"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/mixelka/emailresend/internal/database"
+	"github.com/mixelka/emailresend/internal/email"
+	"github.com/mixelka/emailresend/internal/i18n"
+	"github.com/mixelka/emailresend/internal/smime"
+	appmodels "github.com/mixelka/emailresend/pkg/models"
+)
+
+// smimePayload is the /start deep link payload of the S/MIME key upload
+const smimePayload = "smime"
+
+// smimeMaxFileSize limits uploaded key files; a key with its certificate
+// chain takes a few kilobytes
+const smimeMaxFileSize = 64 << 10
+
+// handleSMIME handles /smime command: shows the S/MIME certificate of the
+// topic's account and lets an admin upload a key in a private chat
+// Usage: /smime, /smime off
+func (b *Bot) handleSMIME(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	var account *appmodels.EmailAccount
+	if msg.Chat.Type == "private" {
+		// A mailbox may be connected to the private chat itself
+		var err error
+		account, err = b.db.GetAccountByChatAndTopic(ctx, msg.Chat.ID, 0, b.accountBotID())
+		if err != nil {
+			b.sendMessage(ctx, msg.Chat.ID, 0, "Используйте /smime в топике, к которому подключена почта")
+			return
+		}
+	} else {
+		var ok bool
+		if account, ok = b.getTopicAccount(ctx, msg); !ok {
+			return
+		}
+	}
+
+	if !b.checkAdmin(ctx, msg, "Только администраторы могут менять настройки") {
+		return
+	}
+
+	parts := strings.Fields(msg.Text)
+	if len(parts) > 1 {
+		if strings.ToLower(parts[1]) != "off" {
+			b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Использование: <code>/smime</code> — сертификат и загрузка ключа, <code>/smime off</code> — удалить ключ")
+			return
+		}
+		err := b.db.DeleteSMIMEKey(ctx, account.ID)
+		if errors.Is(err, database.ErrNotFound) {
+			b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ключ S/MIME не загружен")
+			return
+		}
+		if err != nil {
+			b.logger.Error("failed to delete smime key", "error", err)
+			b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка удаления ключа")
+			return
+		}
+		b.logger.Info("smime key deleted", "account_id", account.ID, "user_id", msg.From.ID)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+			i18n.Tf(ctx, "Ключ S/MIME для <b>%s</b> удалён: зашифрованные письма будут приходить уведомлением с вложением", account.Email))
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(i18n.Tf(ctx, "🔐 <b>S/MIME для %s</b>\n\n", account.Email))
+	key, err := b.loadSMIMEKey(ctx, account.ID)
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+		sb.WriteString(i18n.T(ctx, "Ключ не загружен: письма, зашифрованные S/MIME, приходят уведомлением с зашифрованным вложением .p7m.\n\n"))
+	case err != nil:
+		b.logger.Error("failed to load smime key", "error", err, "account_id", account.ID)
+		sb.WriteString(i18n.Tf(ctx, "Не удалось прочитать сохранённый ключ: %s\n\n", html.EscapeString(err.Error())))
+	default:
+		sb.WriteString(i18n.Tf(ctx, "Сертификат: <b>%s</b>, действует до %s", html.EscapeString(certificateName(key.Certificate)),
+			key.Certificate.NotAfter.Format("02.01.2006")))
+		if key.Expired(time.Now()) {
+			sb.WriteString(i18n.T(ctx, " — истёк, но письма, зашифрованные для него, расшифровываются"))
+		}
+		sb.WriteString("\n" + i18n.T(ctx, "Удалить ключ: <code>/smime off</code>") + "\n\n")
+	}
+	sb.WriteString(i18n.T(ctx, "Чтобы загрузить ключ, отправьте боту в личные сообщения в течение 10 минут PEM-файл с закрытым ключом RSA и сертификатом, без пароля. "+
+		"Из .p12 его можно получить командой <code>openssl pkcs12 -in cert.p12 -nodes -out key.pem</code>."))
+
+	b.passwordMu.Lock()
+	b.passwordSessions[msg.From.ID] = passwordSession{
+		accountID: account.ID,
+		expiresAt: time.Now().Add(passwordSessionTTL),
+		smime:     true,
+	}
+	b.passwordMu.Unlock()
+
+	if msg.Chat.Type == "private" {
+		b.sendMessage(ctx, msg.Chat.ID, 0, sb.String())
+		return
+	}
+
+	keyboard := &models.InlineKeyboardMarkup{
+		InlineKeyboard: [][]models.InlineKeyboardButton{{
+			{Text: "Загрузить ключ", URL: fmt.Sprintf("https://t.me/%s?start=%s", b.username, smimePayload)},
+		}},
+	}
+	b.sendMessageWithKeyboard(ctx, msg.Chat.ID, msg.MessageThreadID, sb.String(), keyboard, messageOptions{})
+}
+
+// handleSMIMEKeyReply receives the PEM key of a pending /smime upload in a
+// private chat, as a file or as text, and stores it
+func (b *Bot) handleSMIMEKeyReply(ctx context.Context, msg *models.Message, account *appmodels.EmailAccount) {
+	text := strings.TrimSpace(msg.Text)
+	switch {
+	case strings.HasPrefix(text, "/start"):
+		if b.handleStartPayload(ctx, msg) {
+			return
+		}
+		b.sendMessage(ctx, msg.Chat.ID, 0,
+			i18n.Tf(ctx, "Отправьте PEM-файл с закрытым ключом и сертификатом S/MIME для <b>%s</b> следующим сообщением.\nОтмена: /cancel", account.Email))
+		return
+	case strings.HasPrefix(text, "/cancel"):
+		b.clearPasswordSession(msg.From.ID)
+		b.sendMessage(ctx, msg.Chat.ID, 0, "Загрузка ключа отменена")
+		return
+	}
+
+	data := []byte(text)
+	if msg.Document != nil {
+		var err error
+		data, err = b.downloadFile(ctx, msg.Document.FileID, smimeMaxFileSize)
+		if err != nil {
+			b.logger.Error("failed to download smime key", "error", err)
+			b.sendMessage(ctx, msg.Chat.ID, 0, "Не удалось загрузить файл. Отправьте его ещё раз или /cancel")
+			return
+		}
+	}
+
+	// Do not keep the private key in the chat history
+	if err := b.deleteMessage(ctx, msg.Chat.ID, msg.ID); err != nil {
+		b.logger.Warn("failed to delete smime key message", "error", err)
+	}
+
+	key, err := smime.Parse(data)
+	if err != nil {
+		b.sendMessage(ctx, msg.Chat.ID, 0,
+			i18n.Tf(ctx, "Не удалось прочитать ключ: %s\n\nОтправьте PEM ещё раз или /cancel", html.EscapeString(err.Error())))
+		return
+	}
+
+	encrypted, err := b.encryptPassword(string(key.KeyPEM()))
+	if err != nil {
+		b.logger.Error("failed to encrypt smime key", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, 0, "Ошибка шифрования ключа")
+		return
+	}
+	err = b.db.SaveSMIMEKey(ctx, &appmodels.SMIMEKey{
+		AccountID:   account.ID,
+		PrivateKey:  encrypted,
+		Certificate: string(key.CertificatePEM()),
+	})
+	if err != nil {
+		b.logger.Error("failed to save smime key", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, 0, "Ошибка сохранения ключа")
+		return
+	}
+	b.clearPasswordSession(msg.From.ID)
+
+	b.logger.Info("smime key uploaded", "account_id", account.ID, "user_id", msg.From.ID)
+	name := html.EscapeString(certificateName(key.Certificate))
+	text = i18n.Tf(ctx, "Ключ S/MIME для <b>%s</b> сохранён, сертификат <b>%s</b> до %s", account.Email, name,
+		key.Certificate.NotAfter.Format("02.01.2006"))
+	if len(key.Certificate.EmailAddresses) > 0 && !slices.ContainsFunc(key.Certificate.EmailAddresses, func(address string) bool {
+		return strings.EqualFold(address, account.Email)
+	}) {
+		text += i18n.T(ctx, "\n⚠️ Сертификат выдан для другого адреса: бот расшифрует только письма, зашифрованные для него.")
+	}
+	b.sendMessage(ctx, msg.Chat.ID, 0, text)
+	b.sendMessage(ctx, account.ChatID, account.TopicID,
+		i18n.Tf(ctx, "🔐 Загружен ключ S/MIME для <b>%s</b>: зашифрованные письма будут приходить расшифрованными", account.Email))
+}
+
+// loadSMIMEKey returns the S/MIME key of an account, ErrNotFound if none
+// was uploaded
+func (b *Bot) loadSMIMEKey(ctx context.Context, accountID int64) (*smime.Key, error) {
+	stored, err := b.db.GetSMIMEKey(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	private, err := b.decryptPassword(stored.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt smime key: %w", err)
+	}
+	return smime.Parse([]byte(private + stored.Certificate))
+}
+
+// decryptEmail replaces the body of an S/MIME encrypted email with the
+// decrypted one if the account has a key. Returns a notice to show above the
+// body, empty if the email is not encrypted.
+func (b *Bot) decryptEmail(ctx context.Context, account *appmodels.EmailAccount, rawEmail *email.RawEmail) string {
+	if rawEmail.Encrypted == "" {
+		return ""
+	}
+	ctx = b.chatContext(ctx, account.ChatID)
+	if rawEmail.Encrypted == email.EncryptedPGP {
+		return i18n.T(ctx, "🔒 Письмо зашифровано PGP. Бот не расшифровывает PGP: откройте письмо в почтовой программе с вашим ключом.")
+	}
+
+	key, err := b.loadSMIMEKey(ctx, account.ID)
+	if errors.Is(err, database.ErrNotFound) {
+		return i18n.T(ctx, "🔒 Письмо зашифровано S/MIME, зашифрованное содержимое — во вложении .p7m. Чтобы бот расшифровывал такие письма, загрузите ключ командой /smime.")
+	}
+	if err != nil {
+		b.logger.Error("failed to load smime key", "error", err, "account_id", account.ID)
+		return i18n.T(ctx, "🔒 Письмо зашифровано S/MIME, но сохранённый ключ не удалось прочитать. Зашифрованное содержимое — во вложении .p7m.")
+	}
+
+	content, err := email.EncryptedContent(rawEmail.Raw)
+	var plaintext []byte
+	if err == nil {
+		plaintext, err = key.Decrypt(content)
+	}
+	if err != nil {
+		b.logger.Warn("failed to decrypt email", "error", err, "account_id", account.ID, "uid", rawEmail.UID)
+		return i18n.Tf(ctx, "🔒 Не удалось расшифровать письмо S/MIME: %v. Зашифрованное содержимое — во вложении .p7m.", err)
+	}
+
+	decrypted := email.ParseRaw(plaintext, b.logger)
+	rawEmail.BodyText = decrypted.BodyText
+	rawEmail.BodyHTML = decrypted.BodyHTML
+
+	notice := i18n.T(ctx, "🔓 Письмо было зашифровано S/MIME и расшифровано ботом")
+	var names []string
+	for _, att := range decrypted.Attachments {
+		// The signature of a signed and encrypted email is not an attachment to open
+		if strings.HasSuffix(att.ContentType, "pkcs7-signature") {
+			continue
+		}
+		names = append(names, att.Filename)
+	}
+	if len(names) > 0 {
+		notice += i18n.Tf(ctx, "\nВложения внутри зашифрованного письма (откройте его в почтовой программе): %s", strings.Join(names, ", "))
+	}
+	return notice
+}
+
+// certificateName returns the email address a certificate was issued for,
+// its common name if it has none
+func certificateName(cert *x509.Certificate) string {
+	if len(cert.EmailAddresses) > 0 {
+		return cert.EmailAddresses[0]
+	}
+	if cert.Subject.CommonName != "" {
+		return cert.Subject.CommonName
+	}
+	return cert.Subject.String()
+}
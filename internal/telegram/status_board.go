@@ -0,0 +1,254 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/mixelka/emailresend/internal/database"
+	"github.com/mixelka/emailresend/internal/formatter"
+	"github.com/mixelka/emailresend/internal/i18n"
+	appmodels "github.com/mixelka/emailresend/pkg/models"
+)
+
+// statusBoardInterval is how often connection states are checked for changes
+const statusBoardInterval = 15 * time.Second
+
+// wakeStatusBoards asks the status board worker to check for changes now
+func (b *Bot) wakeStatusBoards() {
+	select {
+	case b.statusWake <- struct{}{}:
+	default:
+	}
+}
+
+// runStatusBoards keeps pinned status boards up to date until ctx is cancelled
+func (b *Bot) runStatusBoards(ctx context.Context) {
+	ticker := time.NewTicker(statusBoardInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-b.statusWake:
+		}
+
+		chats, err := b.db.GetStatusBoardChats(ctx, b.accountBotID())
+		if err != nil {
+			b.logger.Error("failed to load status boards", "error", err)
+			continue
+		}
+		for _, settings := range chats {
+			b.refreshStatusBoard(ctx, settings, false)
+		}
+	}
+}
+
+// refreshStatusBoard edits a chat's status board if the states changed
+// since the last edit (or always if force is set)
+func (b *Bot) refreshStatusBoard(ctx context.Context, settings *appmodels.ChatSettings, force bool) {
+	ctx = i18n.WithLang(ctx, settings.Language)
+	text, err := b.renderStatusBoard(ctx, settings)
+	if err != nil {
+		b.logger.Error("failed to render status board", "error", err, "chat_id", settings.ChatID)
+		return
+	}
+
+	// The footer holds the update time, compare the states only
+	state, _, _ := strings.Cut(text, i18n.T(ctx, statusBoardFooter))
+	b.statusMu.Lock()
+	unchanged := b.statusBoards[settings.ChatID] == state
+	b.statusMu.Unlock()
+	if unchanged && !force {
+		return
+	}
+
+	if err := b.editMessageText(ctx, settings.ChatID, settings.StatusMsgID, text); err != nil {
+		if strings.Contains(err.Error(), "message to edit not found") {
+			b.logger.Warn("status board message was deleted, disabling", "chat_id", settings.ChatID)
+			settings.StatusTopicID, settings.StatusMsgID = 0, 0
+			if err := b.db.SaveChatSettings(ctx, settings); err != nil {
+				b.logger.Error("failed to save chat settings", "error", err)
+			}
+			return
+		}
+		b.logger.Warn("failed to update status board", "error", err, "chat_id", settings.ChatID)
+		return
+	}
+
+	b.statusMu.Lock()
+	b.statusBoards[settings.ChatID] = state
+	b.statusMu.Unlock()
+}
+
+// statusBoardFooter separates the update time from the board content
+const statusBoardFooter = "\n\n<i>Обновлено: "
+
+// renderStatusBoard builds the status board text for a chat
+func (b *Bot) renderStatusBoard(ctx context.Context, settings *appmodels.ChatSettings) (string, error) {
+	customEmoji := settings.CustomEmojiMap()
+	icon := func(name string) string {
+		return formatter.RenderIcon(models.ParseModeHTML, customEmoji, name)
+	}
+
+	var connected, reconnecting int
+	var problems []string
+
+	for page := 0; ; page++ {
+		accounts, err := b.db.GetAccountsByChatID(ctx, settings.ChatID, database.PageN(page, database.MaxPageSize))
+		if err != nil {
+			return "", err
+		}
+
+		for _, acc := range accounts {
+			switch b.emailManager.GetStatus(acc.ID) {
+			case "connected":
+				connected++
+			case "reconnecting":
+				reconnecting++
+				problems = append(problems, fmt.Sprintf("%s %s", icon(formatter.IconReconnecting), html.EscapeString(acc.Email)))
+			default:
+				problems = append(problems, fmt.Sprintf("%s %s", icon(formatter.IconDisconnected), html.EscapeString(acc.Email)))
+			}
+		}
+
+		if len(accounts) < database.MaxPageSize {
+			break
+		}
+	}
+	failed := len(problems) - reconnecting
+
+	var sb strings.Builder
+	sb.WriteString(i18n.T(ctx, "<b>Статус почтовых подключений</b>\n\n"))
+	sb.WriteString(i18n.Tf(ctx, "%s Подключено: <b>%d</b>\n", icon(formatter.IconConnected), connected))
+	sb.WriteString(i18n.Tf(ctx, "%s Переподключение: <b>%d</b>\n", icon(formatter.IconReconnecting), reconnecting))
+	sb.WriteString(i18n.Tf(ctx, "%s Ошибка: <b>%d</b>", icon(formatter.IconDisconnected), failed))
+
+	if len(problems) > 0 {
+		sb.WriteString(i18n.T(ctx, "\n\n<b>Проблемы:</b>\n"))
+		sb.WriteString(strings.Join(problems, "\n"))
+	}
+
+	sb.WriteString(i18n.T(ctx, statusBoardFooter) + time.Now().Format("02.01.2006 15:04") + "</i>")
+	return sb.String(), nil
+}
+
+// handleStatusBoard handles /statusboard command
+// Usage: /statusboard [on|off]
+func (b *Bot) handleStatusBoard(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	settings, err := b.db.GetChatSettings(ctx, msg.Chat.ID)
+	if err != nil {
+		b.logger.Error("failed to get chat settings", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка получения настроек")
+		return
+	}
+
+	parts := strings.Fields(msg.Text)
+	if len(parts) < 2 {
+		state := i18n.T(ctx, "выключена")
+		if settings.StatusMsgID != 0 {
+			state = i18n.Tf(ctx, "включена (топик ID %d)", settings.StatusTopicID)
+		}
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+			i18n.Tf(ctx, "Панель статуса: <b>%s</b>\n\nЗакреплённое сообщение с числом подключённых, переподключающихся и сломанных почт, обновляется автоматически.\n\nИспользование: <code>/statusboard on</code> в нужном топике\nОтключить: <code>/statusboard off</code>", state))
+		return
+	}
+
+	if !b.checkAdmin(ctx, msg, "Только администраторы могут менять настройки") {
+		return
+	}
+
+	switch strings.ToLower(parts[1]) {
+	case "on":
+		b.enableStatusBoard(ctx, msg, settings)
+	case "off":
+		b.disableStatusBoard(ctx, msg, settings)
+	default:
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Использование: <code>/statusboard on</code> или <code>/statusboard off</code>")
+	}
+}
+
+// enableStatusBoard posts and pins a status board in the current topic
+func (b *Bot) enableStatusBoard(ctx context.Context, msg *models.Message, settings *appmodels.ChatSettings) {
+	if settings.StatusMsgID != 0 {
+		b.unpinMessage(ctx, settings.ChatID, settings.StatusMsgID)
+	}
+
+	text, err := b.renderStatusBoard(ctx, settings)
+	if err != nil {
+		b.logger.Error("failed to render status board", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка получения списка аккаунтов")
+		return
+	}
+
+	board, err := b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, text)
+	if err != nil {
+		b.logger.Error("failed to send status board", "error", err)
+		return
+	}
+
+	if _, err := b.bot.PinChatMessage(ctx, &bot.PinChatMessageParams{
+		ChatID:              msg.Chat.ID,
+		MessageID:           board.ID,
+		DisableNotification: true,
+	}); err != nil {
+		b.logger.Warn("failed to pin status board", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Не удалось закрепить сообщение: дайте боту право закреплять сообщения")
+	}
+
+	settings.StatusTopicID = msg.MessageThreadID
+	settings.StatusMsgID = board.ID
+	settings.StatusBotID = b.accountBotID()
+	if err := b.db.SaveChatSettings(ctx, settings); err != nil {
+		b.logger.Error("failed to save chat settings", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка сохранения настроек")
+		return
+	}
+
+	state, _, _ := strings.Cut(text, i18n.T(ctx, statusBoardFooter))
+	b.statusMu.Lock()
+	b.statusBoards[settings.ChatID] = state
+	b.statusMu.Unlock()
+}
+
+// disableStatusBoard unpins the status board and stops updating it
+func (b *Bot) disableStatusBoard(ctx context.Context, msg *models.Message, settings *appmodels.ChatSettings) {
+	if settings.StatusMsgID == 0 {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Панель статуса не включена")
+		return
+	}
+
+	b.unpinMessage(ctx, settings.ChatID, settings.StatusMsgID)
+
+	settings.StatusTopicID, settings.StatusMsgID = 0, 0
+	if err := b.db.SaveChatSettings(ctx, settings); err != nil {
+		b.logger.Error("failed to save chat settings", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка сохранения настроек")
+		return
+	}
+
+	b.statusMu.Lock()
+	delete(b.statusBoards, settings.ChatID)
+	b.statusMu.Unlock()
+
+	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Панель статуса отключена")
+}
+
+// unpinMessage unpins a message, ignoring errors (it may already be gone)
+func (b *Bot) unpinMessage(ctx context.Context, chatID int64, msgID int) {
+	if _, err := b.bot.UnpinChatMessage(ctx, &bot.UnpinChatMessageParams{
+		ChatID:    chatID,
+		MessageID: msgID,
+	}); err != nil {
+		b.logger.Debug("failed to unpin message", "error", err)
+	}
+}
@@ -0,0 +1,145 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/mixelka/emailresend/internal/i18n"
+)
+
+const (
+	// storageQuotaInterval is how often chats are checked against the quota
+	storageQuotaInterval = time.Hour
+	// storageTrimTarget is the share of the quota a chat is trimmed down to,
+	// so trimming does not run again after every new email
+	storageTrimTarget = 0.9
+	// storageTrimBatch is the number of messages loaded per trimming step
+	storageTrimBatch = 100
+)
+
+// runStorageQuotas periodically trims the chats of this bot that store more
+// than CHAT_STORAGE_QUOTA bytes
+func (b *Bot) runStorageQuotas(ctx context.Context) {
+	ticker := time.NewTicker(storageQuotaInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		chats, err := b.db.GetStorageChats(ctx, b.accountBotID())
+		if err != nil {
+			b.logger.Error("failed to load chats for storage quota", "error", err)
+			continue
+		}
+		for _, chatID := range chats {
+			if ctx.Err() != nil {
+				return
+			}
+			b.enforceStorageQuota(ctx, chatID)
+		}
+	}
+}
+
+// enforceStorageQuota removes the bodies of the oldest emails of a chat until
+// it is below storageTrimTarget of the quota and tells the chat about it.
+// Sender, subject, codes and the Telegram message stay.
+func (b *Bot) enforceStorageQuota(ctx context.Context, chatID int64) {
+	quota := b.config.ChatStorageQuota
+	used, err := b.db.GetChatStorageUsage(ctx, chatID)
+	if err != nil {
+		b.logger.Error("failed to get chat storage usage", "error", err, "chat_id", chatID)
+		return
+	}
+	if used <= quota {
+		return
+	}
+
+	target := int64(float64(quota) * storageTrimTarget)
+	var trimmed int
+	var freed int64
+	for used-freed > target {
+		messages, err := b.db.GetTrimCandidates(ctx, chatID, storageTrimBatch)
+		if err != nil {
+			b.logger.Error("failed to get messages to trim", "error", err, "chat_id", chatID)
+			break
+		}
+		if len(messages) == 0 {
+			break
+		}
+
+		for _, msg := range messages {
+			if used-freed <= target {
+				break
+			}
+			if err := b.db.TrimMessageBody(ctx, msg.ID); err != nil {
+				b.logger.Error("failed to trim message", "error", err, "message_id", msg.ID)
+				return
+			}
+			if msg.RawKey != "" && b.archive != nil {
+				if err := b.archive.Delete(ctx, msg.RawKey); err != nil {
+					b.logger.Warn("failed to delete archived message", "error", err, "key", msg.RawKey)
+				}
+			}
+			trimmed++
+			freed += int64(len(msg.BodyText)+len(msg.BodyHTML)) + msg.RawSize
+		}
+	}
+
+	if trimmed == 0 {
+		return
+	}
+
+	b.logger.Info("chat storage trimmed",
+		"chat_id", chatID,
+		"messages", trimmed,
+		"freed_bytes", freed,
+		"quota_bytes", quota,
+	)
+	ctx = b.chatContext(ctx, chatID)
+	b.sendMessage(ctx, chatID, 0, i18n.Tf(ctx,
+		"🗄 Хранилище писем чата превысило лимит (%s из %s).\n\nУ %d самых старых писем удалены тексты и исходники (освобождено %s). Отправитель, тема и коды сохранены, сообщения в топиках не изменены.",
+		formatBytes(ctx, used), formatBytes(ctx, quota), trimmed, formatBytes(ctx, freed)))
+}
+
+// handleStorage handles /storage command
+func (b *Bot) handleStorage(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	used, err := b.db.GetChatStorageUsage(ctx, msg.Chat.ID)
+	if err != nil {
+		b.logger.Error("failed to get chat storage usage", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка получения данных")
+		return
+	}
+
+	text := i18n.Tf(ctx, "🗄 Письма чата занимают %s", formatBytes(ctx, used))
+	if quota := b.config.ChatStorageQuota; quota > 0 {
+		text += i18n.Tf(ctx, " из %s (%d%%).\nПри превышении лимита у самых старых писем удаляются тексты, отправитель, тема и коды сохраняются.",
+			formatBytes(ctx, quota), used*100/quota)
+	} else {
+		text += i18n.T(ctx, ", лимит не задан.")
+	}
+	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, text)
+}
+
+// formatBytes formats a size with a binary unit, e.g. "12.5 МБ"
+func formatBytes(ctx context.Context, n int64) string {
+	const unit = 1024
+	if n < unit {
+		return i18n.Tf(ctx, "%d Б", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 3; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %s", float64(n)/float64(div), i18n.T(ctx, []string{"КБ", "МБ", "ГБ", "ТБ"}[exp]))
+}
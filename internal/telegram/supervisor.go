@@ -0,0 +1,52 @@
+package telegram
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// pollingMinBackoff is the initial delay before restarting polling
+	pollingMinBackoff = time.Second
+	// pollingMaxBackoff caps the delay between polling restarts
+	pollingMaxBackoff = time.Minute
+	// pollingStableAfter resets the backoff once a session ran this long
+	pollingStableAfter = 5 * time.Minute
+)
+
+// runSupervised keeps the Telegram polling loop running until ctx is
+// cancelled, restarting it with backoff if it stops. The client retries
+// failing getUpdates requests with backoff itself and runs handlers in
+// goroutines of its own: handler panics are caught by the recoverPanic
+// middleware.
+func (b *Bot) runSupervised(ctx context.Context) {
+	backoff := pollingMinBackoff
+
+	for {
+		started := time.Now()
+		b.bot.Start(ctx)
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		if time.Since(started) > pollingStableAfter {
+			backoff = pollingMinBackoff
+		}
+
+		b.logger.Error("telegram polling stopped unexpectedly, restarting", "backoff", backoff)
+		if !sleepCtx(ctx, backoff) {
+			return
+		}
+
+		backoff *= 2
+		if backoff > pollingMaxBackoff {
+			backoff = pollingMaxBackoff
+		}
+	}
+}
+
+// onClientError logs errors reported by the Telegram client
+func (b *Bot) onClientError(err error) {
+	b.logger.Warn("telegram client error", "error", err)
+}
@@ -0,0 +1,313 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/mixelka/emailresend/internal/database"
+	"github.com/mixelka/emailresend/internal/i18n"
+	appmodels "github.com/mixelka/emailresend/pkg/models"
+)
+
+const (
+	// maxAccountTags limits the number of tags per account
+	maxAccountTags = 10
+	// statsPeriod is the period counted by /stats, named in its header
+	statsPeriod = 24 * time.Hour
+	// statsMaxAccounts is the number of accounts listed by /stats
+	statsMaxAccounts = 30
+)
+
+// handleTag handles /tag command
+// Usage: /tag [tag...]
+func (b *Bot) handleTag(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	account, ok := b.getTopicAccount(ctx, msg)
+	if !ok {
+		return
+	}
+
+	parts := strings.Fields(msg.Text)
+	if len(parts) < 2 {
+		b.sendAccountTags(ctx, msg, account)
+		return
+	}
+
+	if !b.checkAdmin(ctx, msg, "Только администраторы могут менять теги") {
+		return
+	}
+
+	tags := make([]string, 0, len(parts)-1)
+	for _, p := range parts[1:] {
+		tag, ok := appmodels.NormalizeTag(p)
+		if !ok {
+			b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.Tf(ctx,
+				"Некорректный тег <code>%s</code>: только буквы, цифры, _ и -, до %d символов",
+				html.EscapeString(p), appmodels.MaxTagLength))
+			return
+		}
+		tags = append(tags, tag)
+	}
+
+	existing, err := b.db.GetAccountTags(ctx, account.ID)
+	if err != nil {
+		b.logger.Error("failed to get account tags", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка получения тегов")
+		return
+	}
+	if len(existing)+len(tags) > maxAccountTags {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.Tf(ctx, "У аккаунта может быть не больше %d тегов", maxAccountTags))
+		return
+	}
+
+	if err := b.db.AddAccountTags(ctx, account.ID, tags); err != nil {
+		b.logger.Error("failed to add account tags", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка сохранения тегов")
+		return
+	}
+
+	b.logger.Info("account tagged", "account_id", account.ID, "tags", tags, "user_id", msg.From.ID)
+	b.sendAccountTags(ctx, msg, account)
+}
+
+// handleUntag handles /untag command
+// Usage: /untag tag
+func (b *Bot) handleUntag(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	account, ok := b.getTopicAccount(ctx, msg)
+	if !ok {
+		return
+	}
+
+	parts := strings.Fields(msg.Text)
+	if len(parts) != 2 {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Использование: <code>/untag тег</code>")
+		return
+	}
+
+	if !b.checkAdmin(ctx, msg, "Только администраторы могут менять теги") {
+		return
+	}
+
+	tag, _ := appmodels.NormalizeTag(parts[1])
+	err := b.db.RemoveAccountTag(ctx, account.ID, tag)
+	if errors.Is(err, database.ErrNotFound) {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "У аккаунта нет такого тега")
+		return
+	}
+	if err != nil {
+		b.logger.Error("failed to remove account tag", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка удаления тега")
+		return
+	}
+
+	b.sendAccountTags(ctx, msg, account)
+}
+
+// sendAccountTags shows the tags of an account and the tags used in the chat
+func (b *Bot) sendAccountTags(ctx context.Context, msg *models.Message, account *appmodels.EmailAccount) {
+	tags, err := b.db.GetAccountTags(ctx, account.ID)
+	if err != nil {
+		b.logger.Error("failed to get account tags", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка получения тегов")
+		return
+	}
+	chatTags, err := b.db.GetChatTags(ctx, msg.Chat.ID)
+	if err != nil {
+		b.logger.Error("failed to get chat tags", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка получения тегов")
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(i18n.Tf(ctx, "<b>Теги %s:</b> %s\n", html.EscapeString(account.Email), formatTags(ctx, tags)))
+	sb.WriteString(i18n.Tf(ctx, "Теги чата: %s\n\n", formatTags(ctx, chatTags)))
+	sb.WriteString(i18n.T(ctx, "<code>/tag qa prod</code> — добавить, <code>/untag qa</code> — убрать\n"))
+	sb.WriteString(i18n.T(ctx, "<code>/pause tag:qa</code>, <code>/resume tag:qa</code>, <code>/stats tag:qa</code> — действия со всеми аккаунтами тега"))
+	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, sb.String())
+}
+
+// formatTags renders tags as a comma-separated list
+func formatTags(ctx context.Context, tags []string) string {
+	if len(tags) == 0 {
+		return i18n.T(ctx, "нет")
+	}
+	formatted := make([]string, len(tags))
+	for i, tag := range tags {
+		formatted[i] = "<code>" + html.EscapeString(tag) + "</code>"
+	}
+	return strings.Join(formatted, ", ")
+}
+
+// resolveTargets returns the accounts a group command applies to: those
+// with the tag given as tag:name, or the account of the current topic
+func (b *Bot) resolveTargets(ctx context.Context, msg *models.Message) ([]*appmodels.EmailAccount, bool) {
+	parts := strings.Fields(msg.Text)
+	if len(parts) < 2 {
+		account, ok := b.getTopicAccount(ctx, msg)
+		if !ok {
+			return nil, false
+		}
+		return []*appmodels.EmailAccount{account}, true
+	}
+
+	tag, ok := appmodels.NormalizeTag(parts[1])
+	if !ok || !strings.HasPrefix(strings.ToLower(parts[1]), appmodels.TagPrefix) {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+			"Укажите тег в виде <code>tag:qa</code> или отправьте команду в топике с почтой")
+		return nil, false
+	}
+
+	accounts, err := b.db.GetAccountsByTag(ctx, msg.Chat.ID, tag)
+	if err != nil {
+		b.logger.Error("failed to get accounts by tag", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка получения аккаунтов")
+		return nil, false
+	}
+	if len(accounts) == 0 {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+			i18n.Tf(ctx, "Нет аккаунтов с тегом <code>%s</code>", html.EscapeString(tag)))
+		return nil, false
+	}
+	return accounts, true
+}
+
+// handlePause handles /pause command
+// Usage: /pause [tag:name]
+func (b *Bot) handlePause(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	accounts, ok := b.resolveTargets(ctx, msg)
+	if !ok {
+		return
+	}
+
+	var paused []int64
+	var lines []string
+	for _, acc := range accounts {
+		if !acc.IsActive {
+			continue
+		}
+		if err := b.db.SetAccountActive(ctx, acc.ID, false); err != nil {
+			b.logger.Error("failed to pause account", "error", err, "account_id", acc.ID)
+			lines = append(lines, i18n.Tf(ctx, "🔴 %s: ошибка сохранения", html.EscapeString(acc.Email)))
+			continue
+		}
+		paused = append(paused, acc.ID)
+		lines = append(lines, fmt.Sprintf("⏸ %s", html.EscapeString(acc.Email)))
+	}
+	b.emailManager.StopAccounts(paused)
+	if len(paused) > 0 {
+		b.wakeStatusBoards()
+	}
+
+	b.logger.Info("accounts paused", "chat_id", msg.Chat.ID, "count", len(paused), "user_id", msg.From.ID)
+	text := i18n.Tf(ctx, "<b>Пересылка приостановлена:</b> %d из %d", len(paused), len(accounts))
+	if len(lines) > 0 {
+		text += "\n\n" + strings.Join(lines, "\n")
+	}
+	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, text)
+}
+
+// handleResume handles /resume command
+// Usage: /resume [tag:name]
+func (b *Bot) handleResume(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	accounts, ok := b.resolveTargets(ctx, msg)
+	if !ok {
+		return
+	}
+
+	var resumed []*appmodels.EmailAccount
+	var lines []string
+	for _, acc := range accounts {
+		if acc.IsActive {
+			continue
+		}
+		if err := b.db.SetAccountActive(ctx, acc.ID, true); err != nil {
+			b.logger.Error("failed to resume account", "error", err, "account_id", acc.ID)
+			lines = append(lines, i18n.Tf(ctx, "🔴 %s: ошибка сохранения", html.EscapeString(acc.Email)))
+			continue
+		}
+		acc.IsActive = true
+		resumed = append(resumed, acc)
+	}
+
+	errs := b.emailManager.StartAccounts(ctx, resumed)
+	for _, acc := range resumed {
+		if err := errs[acc.ID]; err != nil {
+			lines = append(lines, fmt.Sprintf("🔴 %s: <code>%s</code>", html.EscapeString(acc.Email), html.EscapeString(err.Error())))
+		} else {
+			lines = append(lines, fmt.Sprintf("▶️ %s", html.EscapeString(acc.Email)))
+		}
+	}
+	if len(resumed) > 0 {
+		b.wakeStatusBoards()
+	}
+
+	b.logger.Info("accounts resumed", "chat_id", msg.Chat.ID, "count", len(resumed)-len(errs), "user_id", msg.From.ID)
+	text := i18n.Tf(ctx, "<b>Пересылка возобновлена:</b> %d из %d", len(resumed)-len(errs), len(accounts))
+	if len(lines) > 0 {
+		text += "\n\n" + strings.Join(lines, "\n")
+	}
+	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, text)
+}
+
+// handleStats handles /stats command
+// Usage: /stats [tag:name]
+func (b *Bot) handleStats(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	accounts, ok := b.resolveTargets(ctx, msg)
+	if !ok {
+		return
+	}
+
+	since := time.Now().Add(-statsPeriod)
+	var sb strings.Builder
+	sb.WriteString(i18n.T(ctx, "<b>Статистика за 24 часа</b>\n\n"))
+
+	var recent, codes int
+	for i, acc := range accounts {
+		stats, err := b.db.GetMessageStats(ctx, acc.ID, since)
+		if err != nil {
+			b.logger.Error("failed to get message stats", "error", err, "account_id", acc.ID)
+			b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка получения статистики")
+			return
+		}
+		recent += stats.Recent
+		codes += stats.Codes
+		if i >= statsMaxAccounts {
+			continue
+		}
+
+		status := b.emailManager.GetStatus(acc.ID)
+		if !acc.IsActive {
+			status = "paused"
+		}
+		last := i18n.T(ctx, "писем нет")
+		if stats.LastAt != nil {
+			last = i18n.T(ctx, "последнее ") + stats.LastAt.Format("02.01 15:04")
+		}
+		sb.WriteString(i18n.Tf(ctx, "<b>%s</b> (%s)\n   %d писем, %d с кодами · всего %d · %s\n",
+			html.EscapeString(acc.Email), status, stats.Recent, stats.Codes, stats.Total, last))
+	}
+	if len(accounts) > statsMaxAccounts {
+		sb.WriteString(i18n.Tf(ctx, "... и ещё %d\n", len(accounts)-statsMaxAccounts))
+	}
+	if len(accounts) > 1 {
+		sb.WriteString(i18n.Tf(ctx, "\n<b>Итого:</b> %d аккаунтов, %d писем, %d с кодами", len(accounts), recent, codes))
+	}
+
+	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, sb.String())
+}
@@ -0,0 +1,166 @@
+package telegram
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/mixelka/emailresend/internal/i18n"
+	appmodels "github.com/mixelka/emailresend/pkg/models"
+)
+
+// probeTimeout is how long /test waits for the probe email to be posted
+const probeTimeout = 3 * time.Minute
+
+// probeSubject starts the subject of probe emails, followed by their token
+const probeSubject = "emailresend test "
+
+// probe is a pending /test of an account. The channels get the times the
+// probe email was received over IMAP and posted to Telegram.
+type probe struct {
+	token    string
+	received chan time.Time
+	posted   chan time.Time
+}
+
+// handleTest handles /test command: sends an email from the topic's mailbox
+// to itself and reports how long it took to reach the topic
+func (b *Bot) handleTest(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	account, ok := b.getTopicAccount(ctx, msg)
+	if !ok {
+		return
+	}
+
+	if !b.checkAdmin(ctx, msg, "Только администраторы могут отправлять тестовые письма") {
+		return
+	}
+	if !account.IsActive {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Пересылка для этого ящика приостановлена, тестовое письмо не дойдёт до топика")
+		return
+	}
+
+	token := make([]byte, 6)
+	if _, err := rand.Read(token); err != nil {
+		b.logger.Error("failed to generate probe token", "error", err)
+		return
+	}
+	p := &probe{
+		token:    hex.EncodeToString(token),
+		received: make(chan time.Time, 1),
+		posted:   make(chan time.Time, 1),
+	}
+
+	b.probesMu.Lock()
+	if _, running := b.probes[account.ID]; running {
+		b.probesMu.Unlock()
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Тестовое письмо уже отправлено, дождитесь результата")
+		return
+	}
+	b.probes[account.ID] = p
+	b.probesMu.Unlock()
+
+	progress, err := b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID,
+		i18n.Tf(ctx, "⏳ Отправляю тестовое письмо на <b>%s</b>...", html.EscapeString(account.Email)))
+	if err != nil {
+		b.logger.Error("failed to send message", "error", err)
+		b.clearProbe(account.ID)
+		return
+	}
+
+	started := time.Now()
+	_, server, err := b.sendDraft(ctx, account, &appmodels.SentMessage{
+		ToAddr:  account.Email,
+		Subject: probeSubject + p.token,
+		Body:    i18n.T(ctx, "Это тестовое письмо отправлено командой /test, чтобы проверить пересылку почты в Telegram."),
+	})
+	if err != nil {
+		b.clearProbe(account.ID)
+		b.logger.Warn("failed to send probe email", "error", err, "account_id", account.ID)
+		b.editMessageText(ctx, msg.Chat.ID, progress.ID,
+			i18n.Tf(ctx, "❌ Не удалось отправить тестовое письмо через <code>%s</code>:\n<code>%s</code>",
+				html.EscapeString(server), html.EscapeString(err.Error())))
+		return
+	}
+	sent := time.Now()
+
+	b.editMessageText(ctx, msg.Chat.ID, progress.ID,
+		i18n.Tf(ctx, "⏳ Тестовое письмо отправлено за %s, жду его в топике (до %s)...",
+			sent.Sub(started).Round(time.Millisecond), shortDuration(probeTimeout)))
+
+	// Waiting must not hold an update worker
+	go b.awaitProbe(b.chatContext(context.Background(), msg.Chat.ID), account, p, msg.Chat.ID, progress.ID, started, sent)
+}
+
+// awaitProbe waits for a probe email to be received and posted and reports
+// the latency of each step
+func (b *Bot) awaitProbe(ctx context.Context, account *appmodels.EmailAccount, p *probe, chatID int64, progressID int, started, sent time.Time) {
+	defer b.clearProbe(account.ID)
+
+	timeout := time.NewTimer(probeTimeout)
+	defer timeout.Stop()
+
+	var received, posted time.Time
+	select {
+	case received = <-p.received:
+	case <-timeout.C:
+		b.editMessageText(ctx, chatID, progressID,
+			i18n.Tf(ctx, "❌ Тестовое письмо не пришло в ящик за %s.\n\nОно могло попасть в спам или задержаться на сервере. Состояние подключения — в /status.",
+				shortDuration(probeTimeout)))
+		return
+	}
+	select {
+	case posted = <-p.posted:
+	case <-timeout.C:
+		b.editMessageText(ctx, chatID, progressID,
+			i18n.Tf(ctx, "⚠️ Тестовое письмо пришло в ящик через %s, но не опубликовано за %s.\n\nВозможно, его задержали /filter, /digest, /quiet или очередь отправки.",
+				received.Sub(sent).Round(time.Millisecond), shortDuration(probeTimeout)))
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(i18n.Tf(ctx, "✅ <b>Пересылка %s работает</b>\n\n", html.EscapeString(account.Email)))
+	sb.WriteString(i18n.Tf(ctx, "Отправка по SMTP: %s\n", sent.Sub(started).Round(time.Millisecond)))
+	sb.WriteString(i18n.Tf(ctx, "Получение ящиком: %s\n", received.Sub(sent).Round(time.Millisecond)))
+	sb.WriteString(i18n.Tf(ctx, "Публикация в топике: %s\n", posted.Sub(received).Round(time.Millisecond)))
+	sb.WriteString(i18n.Tf(ctx, "\nВсего: <b>%s</b>", posted.Sub(started).Round(time.Millisecond)))
+	b.editMessageText(ctx, chatID, progressID, sb.String())
+}
+
+// observeProbe records that the probe email of a pending /test was received
+// or, if posted is set, posted to the topic
+func (b *Bot) observeProbe(accountID int64, subject string, posted bool) {
+	if !strings.HasPrefix(subject, probeSubject) {
+		return
+	}
+
+	b.probesMu.Lock()
+	p, ok := b.probes[accountID]
+	b.probesMu.Unlock()
+	if !ok || subject != probeSubject+p.token {
+		return
+	}
+
+	step := p.received
+	if posted {
+		step = p.posted
+	}
+	select {
+	case step <- time.Now():
+	default:
+	}
+}
+
+// clearProbe drops the pending /test of an account
+func (b *Bot) clearProbe(accountID int64) {
+	b.probesMu.Lock()
+	delete(b.probes, accountID)
+	b.probesMu.Unlock()
+}
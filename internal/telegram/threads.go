@@ -0,0 +1,49 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"regexp"
+
+	"github.com/mixelka/emailresend/internal/database"
+	appmodels "github.com/mixelka/emailresend/pkg/models"
+)
+
+// maxThreadReferences bounds the Message-IDs of a thread looked up for the
+// email a follow-up replies to
+const maxThreadReferences = 10
+
+// messageIDRegex matches a Message-ID in a References header
+var messageIDRegex = regexp.MustCompile(`<[^<>\s]+>`)
+
+// threadParent returns the Telegram message of the closest earlier email of
+// the conversation an email belongs to (its References and In-Reply-To), so
+// the email can be posted as a reply to it; 0 if none was posted. Emails
+// that went to the spam or newsletters topic are skipped, a reply would
+// land there too.
+func (b *Bot) threadParent(ctx context.Context, account *appmodels.EmailAccount, msg *appmodels.EmailMessage) int {
+	refs := messageIDRegex.FindAllString(msg.References, -1)
+	if len(refs) > maxThreadReferences {
+		refs = refs[len(refs)-maxThreadReferences:]
+	}
+
+	// The last reference is the direct parent
+	for i := len(refs) - 1; i >= 0; i-- {
+		if refs[i] == msg.MessageID {
+			continue
+		}
+		parent, err := b.db.GetDeliveredMessageByMessageID(ctx, account.ID, refs[i], msg.ID)
+		if errors.Is(err, database.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			b.logger.Warn("failed to look up thread parent", "error", err, "message_id", msg.ID)
+			return 0
+		}
+		if parent.Bulk != "" || b.isFiltered(ctx, account, parent) {
+			continue
+		}
+		return parent.TelegramMsgID
+	}
+	return 0
+}
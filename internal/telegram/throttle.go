@@ -0,0 +1,137 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-telegram/bot"
+)
+
+// Telegram's broadcasting limits, see https://core.telegram.org/bots/faq
+const (
+	// chatSendInterval is the minimum gap between messages to one chat
+	chatSendInterval = time.Second
+	// groupSendsPerMinute caps messages to one group per minute
+	groupSendsPerMinute = 20
+	// globalSendInterval spaces out messages of the bot to all chats (30 per second)
+	globalSendInterval = time.Second / 30
+	// sendMaxRetries is the number of times a call rejected with retry_after is repeated
+	sendMaxRetries = 3
+)
+
+// sendThrottle queues the API calls that post or edit messages, per chat:
+// calls to one chat run one at a time in the order they were made and are
+// spaced out to stay within Telegram's limits. A call rejected with 429 is
+// repeated after retry_after, and the chat waits as long.
+type sendThrottle struct {
+	mu         sync.Mutex
+	chats      map[int64]*chatLane
+	nextGlobal time.Time
+	pending    int // calls waiting or running, see idle
+}
+
+// chatLane is the queue of one chat
+type chatLane struct {
+	turn chan struct{} // holds a token while a call runs; blocked senders queue FIFO
+
+	// Guarded by sendThrottle.mu
+	next   time.Time   // earliest time of the next call
+	recent []time.Time // times of the calls of the last minute (groups only)
+}
+
+func newSendThrottle() *sendThrottle {
+	return &sendThrottle{chats: make(map[int64]*chatLane)}
+}
+
+// do runs fn, an API call posting to chatID, in the chat's turn. It returns
+// fn's error, or ctx's if ctx is done while waiting.
+func (t *sendThrottle) do(ctx context.Context, chatID int64, fn func() error) error {
+	lane := t.lane(chatID)
+	defer func() {
+		t.mu.Lock()
+		t.pending--
+		t.mu.Unlock()
+	}()
+
+	select {
+	case lane.turn <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-lane.turn }()
+
+	for attempt := 0; ; attempt++ {
+		if !sleepCtx(ctx, t.reserve(chatID, lane)) {
+			return ctx.Err()
+		}
+
+		err := fn()
+		var tooMany *bot.TooManyRequestsError
+		if !errors.As(err, &tooMany) || attempt == sendMaxRetries {
+			return err
+		}
+
+		retryAfter := time.Duration(tooMany.RetryAfter) * time.Second
+		t.mu.Lock()
+		lane.next = time.Now().Add(retryAfter)
+		t.mu.Unlock()
+	}
+}
+
+// lane returns the queue of a chat and counts a call as pending
+func (t *sendThrottle) lane(chatID int64) *chatLane {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	lane, ok := t.chats[chatID]
+	if !ok {
+		lane = &chatLane{turn: make(chan struct{}, 1)}
+		t.chats[chatID] = lane
+	}
+	t.pending++
+	return lane
+}
+
+// idle reports whether no call is waiting or running
+func (t *sendThrottle) idle() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.pending == 0
+}
+
+// reserve books the next slot of a chat and returns how long to wait for it
+func (t *sendThrottle) reserve(chatID int64, lane *chatLane) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	at := now
+	if lane.next.After(at) {
+		at = lane.next
+	}
+
+	// Negative IDs are groups and channels
+	if chatID < 0 {
+		cutoff := now.Add(-time.Minute)
+		for len(lane.recent) > 0 && lane.recent[0].Before(cutoff) {
+			lane.recent = lane.recent[1:]
+		}
+		if len(lane.recent) >= groupSendsPerMinute {
+			if free := lane.recent[len(lane.recent)-groupSendsPerMinute].Add(time.Minute); free.After(at) {
+				at = free
+			}
+		}
+	}
+
+	if t.nextGlobal.After(at) {
+		at = t.nextGlobal
+	}
+	t.nextGlobal = at.Add(globalSendInterval)
+	lane.next = at.Add(chatSendInterval)
+	if chatID < 0 {
+		lane.recent = append(lane.recent, at)
+	}
+	return at.Sub(now)
+}
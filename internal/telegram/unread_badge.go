@@ -0,0 +1,202 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/mixelka/emailresend/internal/i18n"
+	appmodels "github.com/mixelka/emailresend/pkg/models"
+)
+
+const (
+	// unreadBadgeInterval is how often unread counts are checked for changes
+	// besides the wakeups on new and read emails
+	unreadBadgeInterval = time.Minute
+	// maxTopicNameLength is the Telegram limit of topic names in characters
+	maxTopicNameLength = 128
+)
+
+// wakeUnreadBadges asks the unread badge worker to check for changes now
+func (b *Bot) wakeUnreadBadges() {
+	select {
+	case b.badgeWake <- struct{}{}:
+	default:
+	}
+}
+
+// runUnreadBadges keeps the unread counts in topic names up to date until
+// ctx is cancelled
+func (b *Bot) runUnreadBadges(ctx context.Context) {
+	ticker := time.NewTicker(unreadBadgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-b.badgeWake:
+		}
+
+		accounts, err := b.db.GetUnreadBadgeAccounts(ctx, b.accountBotID())
+		if err != nil {
+			b.logger.Error("failed to load unread badge accounts", "error", err)
+			continue
+		}
+		for _, account := range accounts {
+			b.refreshUnreadBadge(ctx, account)
+		}
+	}
+}
+
+// refreshUnreadBadge renames the topic of an account if its unread count
+// changed since the last rename. Emails are counted within the window their
+// read state is synced with the mailbox.
+func (b *Bot) refreshUnreadBadge(ctx context.Context, account *appmodels.EmailAccount) {
+	unread, err := b.db.CountUnreadMessages(ctx, account.ID, time.Now().Add(-stateSyncWindow))
+	if err != nil {
+		b.logger.Error("failed to count unread messages", "error", err, "account_id", account.ID)
+		return
+	}
+
+	name := badgeTopicName(topicBaseName(account), unread)
+	b.badgeMu.Lock()
+	unchanged := b.badgeNames[account.ID] == name
+	b.badgeMu.Unlock()
+	if unchanged {
+		return
+	}
+
+	// Failed renames (e.g. the bot lacks the right to manage topics) are
+	// remembered too and retried when the count changes
+	if err := b.renameTopic(ctx, account.ChatID, account.TopicID, name); err != nil {
+		b.logger.Warn("failed to rename topic", "error", err, "account_id", account.ID, "topic_id", account.TopicID)
+	}
+	b.badgeMu.Lock()
+	b.badgeNames[account.ID] = name
+	b.badgeMu.Unlock()
+}
+
+// renameTopic sets the name of a forum topic
+func (b *Bot) renameTopic(ctx context.Context, chatID int64, topicID int, name string) error {
+	return b.sends.do(ctx, chatID, func() error {
+		_, err := b.bot.EditForumTopic(ctx, &bot.EditForumTopicParams{
+			ChatID:          chatID,
+			MessageThreadID: topicID,
+			Name:            name,
+		})
+		// Telegram rejects a rename to the current name
+		if err != nil && strings.Contains(err.Error(), "TOPIC_NOT_MODIFIED") {
+			return nil
+		}
+		return err
+	})
+}
+
+// topicBaseName returns the topic name of an account without the unread count
+func topicBaseName(account *appmodels.EmailAccount) string {
+	if account.TopicName != "" {
+		return account.TopicName
+	}
+	return account.Email
+}
+
+// badgeTopicName appends the unread count to a topic name, shortening the
+// name to fit the Telegram limit. No unread emails leave the name as is.
+func badgeTopicName(base string, unread int) string {
+	if unread == 0 {
+		return truncateRunes(base, maxTopicNameLength)
+	}
+	badge := fmt.Sprintf(" (%d)", unread)
+	return truncateRunes(base, maxTopicNameLength-utf8.RuneCountInString(badge)) + badge
+}
+
+// truncateRunes cuts s to at most n characters
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// handleUnreadBadge handles /badge command: shows the number of unread emails
+// in the topic name, e.g. "support@example.com (3)"
+// Usage: /badge on [name], /badge off
+func (b *Bot) handleUnreadBadge(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+
+	account, ok := b.getTopicAccount(ctx, msg)
+	if !ok {
+		return
+	}
+
+	parts := strings.Fields(msg.Text)
+	if len(parts) < 2 {
+		state := i18n.T(ctx, "выключен")
+		if account.UnreadBadge {
+			state = i18n.Tf(ctx, "включён, название <b>%s</b>", html.EscapeString(topicBaseName(account)))
+		}
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.Tf(ctx,
+			"Счётчик непрочитанных в названии топика: %s\n\nИспользование: <code>/badge on</code> — название с числом непрочитанных писем за неделю, например «%s (3)»; <code>/badge on Поддержка</code> — своё название; <code>/badge off</code> — выключить",
+			state, html.EscapeString(account.Email)))
+		return
+	}
+
+	if !b.checkAdmin(ctx, msg, "Только администраторы могут менять настройки") {
+		return
+	}
+	if account.TopicID == 0 {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Счётчик непрочитанных работает только в топиках форума")
+		return
+	}
+
+	switch strings.ToLower(parts[1]) {
+	case "on":
+		name := strings.TrimSpace(strings.TrimPrefix(strings.SplitN(msg.Text, " ", 2)[1], parts[1]))
+		if utf8.RuneCountInString(name) > maxTopicNameLength-8 {
+			b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Название слишком длинное")
+			return
+		}
+		account.UnreadBadge = true
+		account.TopicName = name
+	case "off":
+		account.UnreadBadge = false
+	default:
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Использование: <code>/badge on [название]</code> или <code>/badge off</code>")
+		return
+	}
+
+	if err := b.db.UpdateAccountSettings(ctx, account); err != nil {
+		b.logger.Error("failed to update account settings", "error", err)
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Ошибка сохранения настроек")
+		return
+	}
+	b.logger.Info("unread badge changed", "account_id", account.ID, "enabled", account.UnreadBadge)
+
+	b.badgeMu.Lock()
+	delete(b.badgeNames, account.ID)
+	b.badgeMu.Unlock()
+
+	if !account.UnreadBadge {
+		// Drop the count from the name
+		if err := b.renameTopic(ctx, account.ChatID, account.TopicID, badgeTopicName(topicBaseName(account), 0)); err != nil {
+			b.logger.Warn("failed to rename topic", "error", err, "account_id", account.ID)
+			b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.Tf(ctx, "Счётчик выключен, но не удалось переименовать топик: %v", err))
+			return
+		}
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Счётчик непрочитанных выключен")
+		return
+	}
+
+	b.wakeUnreadBadges()
+	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, i18n.Tf(ctx,
+		"Счётчик непрочитанных включён: топик будет называться <b>%s</b> с числом непрочитанных писем. Боту нужно право управлять топиками.",
+		html.EscapeString(topicBaseName(account))))
+}
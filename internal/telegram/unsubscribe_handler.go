@@ -0,0 +1,174 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot/models"
+
+	"github.com/mixelka/emailresend/internal/formatter"
+	"github.com/mixelka/emailresend/internal/i18n"
+	"github.com/mixelka/emailresend/internal/parser"
+	"github.com/mixelka/emailresend/internal/smtp"
+	appmodels "github.com/mixelka/emailresend/pkg/models"
+)
+
+const (
+	// unsubscribeConfirmTTL is how long an unsubscribe confirmation stays valid
+	unsubscribeConfirmTTL = 5 * time.Minute
+	// unsubscribeTimeout bounds the one-click request or the unsubscribe email
+	unsubscribeTimeout = 30 * time.Second
+)
+
+// unsubscribeClient posts one-click unsubscribe requests
+var unsubscribeClient = &http.Client{Timeout: unsubscribeTimeout}
+
+// handleUnsubscribe handles the unsubscribe button of an email: the button
+// asks for a confirmation, whose buttons unsubscribe or cancel
+func (b *Bot) handleUnsubscribe(ctx context.Context, callback *models.CallbackQuery, data appmodels.CallbackData) {
+	prompt := callback.Message.Message
+	if prompt == nil {
+		b.answerCallback(ctx, callback.ID, "Сообщение недоступно", false)
+		return
+	}
+	chatID := prompt.Chat.ID
+
+	msg, err := b.db.GetMessageByID(ctx, data.MessageID)
+	if err != nil {
+		b.answerCallback(ctx, callback.ID, "Письмо не найдено", true)
+		return
+	}
+	account, err := b.db.GetAccountByID(ctx, msg.AccountID)
+	if err != nil || account.ChatID != chatID {
+		b.answerCallback(ctx, callback.ID, "Почта, на которую пришло это письмо, больше не подключена", true)
+		return
+	}
+	unsub := msg.UnsubscribeData()
+	if unsub == nil {
+		b.answerCallback(ctx, callback.ID, "В письме нет способа отписаться", true)
+		return
+	}
+
+	switch data.Arg {
+	case "":
+		b.confirmUnsubscribe(ctx, prompt, account, msg, unsub)
+		b.answerCallback(ctx, callback.ID, "", false)
+		return
+	case "no":
+		b.editMessageText(ctx, chatID, prompt.ID, "Отписка отменена")
+		b.answerCallback(ctx, callback.ID, "Отменено", false)
+		return
+	}
+
+	if time.Since(time.Unix(int64(prompt.Date), 0)) > unsubscribeConfirmTTL {
+		b.editMessageText(ctx, chatID, prompt.ID, "Подтверждение устарело, нажмите «Отписаться» под письмом ещё раз")
+		b.answerCallback(ctx, callback.ID, "Подтверждение устарело", true)
+		return
+	}
+
+	if err := b.unsubscribe(ctx, account, unsub); err != nil {
+		b.logger.Error("failed to unsubscribe", "error", err, "account_id", account.ID, "message_id", msg.ID)
+		b.editMessageText(ctx, chatID, prompt.ID,
+			i18n.Tf(ctx, "Не удалось отписаться:\n<code>%s</code>", html.EscapeString(err.Error())))
+		b.answerCallback(ctx, callback.ID, "Ошибка отписки", true)
+		return
+	}
+
+	b.logger.Info("unsubscribed from mailing list", "account_id", account.ID, "message_id", msg.ID, "one_click", unsub.OneClick, "user_id", callback.From.ID)
+	b.editMessageText(ctx, chatID, prompt.ID, i18n.Tf(ctx,
+		"✅ Запрос на отписку от <b>%s</b> отправлен. Письма рассылки могут приходить ещё несколько дней.", html.EscapeString(msg.FromAddr)))
+	b.answerCallback(ctx, callback.ID, "Запрос отправлен", false)
+}
+
+// confirmUnsubscribe asks to confirm unsubscribing, telling how it is done
+func (b *Bot) confirmUnsubscribe(ctx context.Context, prompt *models.Message, account *appmodels.EmailAccount, msg *appmodels.EmailMessage, unsub *appmodels.Unsubscribe) {
+	how := i18n.Tf(ctx, "Бот отправит письмо об отписке с адреса %s.", html.EscapeString(account.Email))
+	if unsub.OneClick {
+		host := unsub.URL
+		if u, err := url.Parse(unsub.URL); err == nil {
+			host = u.Host
+		}
+		how = i18n.Tf(ctx, "Бот отправит запрос на отписку на сайт %s.", html.EscapeString(host))
+	}
+
+	text := i18n.Tf(ctx, "🚫 <b>Отписаться от рассылки %s?</b>\n\n%s\nПодтвердить можно в течение 5 минут.",
+		html.EscapeString(msg.FromAddr), how)
+	keyboard := formatter.BuildConfirmKeyboard(appmodels.CallbackUnsub, msg.ID, "🚫 Отписаться")
+	if _, err := b.sendMessageWithKeyboard(ctx, prompt.Chat.ID, prompt.MessageThreadID, text, keyboard, messageOptions{ReplyTo: prompt.ID}); err != nil {
+		b.logger.Error("failed to send unsubscribe confirmation", "error", err)
+	}
+}
+
+// unsubscribe leaves a mailing list with a one-click request (RFC 8058) or,
+// if there is none or it fails, with an email from the account
+func (b *Bot) unsubscribe(ctx context.Context, account *appmodels.EmailAccount, unsub *appmodels.Unsubscribe) error {
+	ctx, cancel := context.WithTimeout(ctx, unsubscribeTimeout)
+	defer cancel()
+
+	var oneClickErr error
+	if unsub.OneClick {
+		if oneClickErr = oneClickUnsubscribe(ctx, unsub.URL); oneClickErr == nil || unsub.Mailto == "" {
+			return oneClickErr
+		}
+		b.logger.Warn("one-click unsubscribe failed, sending email", "error", oneClickErr)
+	}
+
+	to, subject, body, ok := parser.MailtoUnsubscribe(unsub.Mailto)
+	if !ok {
+		return errors.Join(oneClickErr, errors.New("invalid mailto unsubscribe address"))
+	}
+
+	server, err := b.accountSMTPServer(ctx, account)
+	if err != nil {
+		return fmt.Errorf("failed to resolve SMTP server: %w", err)
+	}
+	password, err := b.decryptPassword(account.Password)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt password: %w", err)
+	}
+
+	_, err = smtp.Send(ctx, smtp.Config{
+		Server:      server,
+		Username:    account.Email,
+		Password:    password,
+		DialTimeout: b.config.IMAPDialTimeout,
+	}, smtp.Message{
+		From:    account.Email,
+		To:      to,
+		Subject: subject,
+		Body:    body,
+	})
+	return err
+}
+
+// oneClickUnsubscribe posts the one-click unsubscribe request of RFC 8058
+func oneClickUnsubscribe(ctx context.Context, target string) error {
+	if u, err := url.Parse(target); err != nil || u.Scheme != "https" {
+		return errors.New("one-click unsubscribe requires an https URL")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, strings.NewReader("List-Unsubscribe=One-Click"))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := unsubscribeClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unsubscribe server responded %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/mixelka/emailresend/internal/buildinfo"
+	"github.com/mixelka/emailresend/internal/i18n"
+	"github.com/mixelka/emailresend/internal/updates"
+)
+
+// notifiedReleaseKey stores the last release tag the owner was notified about
+const notifiedReleaseKey = "update_notified_tag"
+
+// handleVersion handles /version command
+func (b *Bot) handleVersion(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+	info := buildinfo.Get()
+
+	var sb strings.Builder
+	sb.WriteString("<b>Email to Telegram Bot</b>\n\n")
+	sb.WriteString(i18n.Tf(ctx, "Версия: <code>%s</code>\n", html.EscapeString(info.Version)))
+	if info.Commit != "" {
+		commit := info.Commit
+		if info.Modified {
+			commit += i18n.T(ctx, " (изменён)")
+		}
+		sb.WriteString(i18n.Tf(ctx, "Коммит: <code>%s</code>\n", html.EscapeString(commit)))
+	}
+	if info.Date != "" {
+		sb.WriteString(i18n.Tf(ctx, "Сборка: %s\n", html.EscapeString(info.Date)))
+	}
+	fmt.Fprintf(&sb, "Go: %s\n", info.GoVersion)
+
+	b.releaseMu.Lock()
+	release := b.latestRelease
+	b.releaseMu.Unlock()
+	if release != nil && updates.Newer(release.Tag, info.Version) {
+		sb.WriteString(i18n.Tf(ctx, "\n⬆️ Доступна версия <a href=\"%s\">%s</a>", html.EscapeString(release.URL), html.EscapeString(release.Tag)))
+		if release.Security() {
+			sb.WriteString(i18n.T(ctx, " с исправлениями безопасности"))
+		}
+	}
+
+	b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, sb.String())
+}
+
+// runUpdateCheck periodically checks GitHub releases until ctx is cancelled
+func (b *Bot) runUpdateCheck(ctx context.Context) {
+	checker := updates.NewChecker(b.config.UpdateCheckRepo)
+
+	ticker := time.NewTicker(b.config.UpdateCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		b.checkForUpdates(ctx, checker)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkForUpdates fetches the latest release and notifies the owner once per
+// release if it is newer than the running build and contains security fixes
+func (b *Bot) checkForUpdates(ctx context.Context, checker *updates.Checker) {
+	release, err := checker.Latest(ctx)
+	if err != nil {
+		b.logger.Warn("failed to check for updates", "error", err)
+		return
+	}
+
+	b.releaseMu.Lock()
+	b.latestRelease = release
+	b.releaseMu.Unlock()
+
+	current := buildinfo.Get().Version
+	if !updates.Newer(release.Tag, current) || !release.Security() {
+		return
+	}
+	b.logger.Warn("security release available", "current", current, "latest", release.Tag)
+
+	if b.config.OwnerID == 0 {
+		return
+	}
+
+	notified, err := b.db.GetState(ctx, notifiedReleaseKey)
+	if err != nil {
+		b.logger.Error("failed to get notified release", "error", err)
+		return
+	}
+	if notified == release.Tag {
+		return
+	}
+
+	ctx = b.chatContext(ctx, b.config.OwnerID)
+	text := i18n.Tf(ctx, "🔒 <b>Доступно обновление безопасности</b>\n\nТекущая версия: <code>%s</code>\nНовая версия: <a href=\"%s\">%s</a>",
+		html.EscapeString(current), html.EscapeString(release.URL), html.EscapeString(release.Tag))
+	if _, err := b.sendMessage(ctx, b.config.OwnerID, 0, text); err != nil {
+		b.logger.Error("failed to notify owner about update", "error", err)
+		return
+	}
+
+	if err := b.db.SetState(ctx, notifiedReleaseKey, release.Tag); err != nil {
+		b.logger.Error("failed to save notified release", "error", err)
+	}
+}
@@ -0,0 +1,468 @@
+package telegram
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"github.com/mixelka/emailresend/internal/database"
+	"github.com/mixelka/emailresend/internal/i18n"
+	appmodels "github.com/mixelka/emailresend/pkg/models"
+)
+
+const (
+	// webAppInitDataTTL is how long the initData of an opened dashboard is
+	// accepted; Telegram renews it when the dashboard is opened again
+	webAppInitDataTTL = 24 * time.Hour
+	// webAppAdminTTL is how long admin rights checked for the dashboard are
+	// remembered, so listing accounts does not query every chat each time
+	webAppAdminTTL = 5 * time.Minute
+	// webAppMessages is the number of recent emails listed per account
+	webAppMessages = 20
+	// webAppMaxBody limits the size of dashboard API requests
+	webAppMaxBody = 4 << 10
+	// dashboardPayload is the /start deep link payload opening the dashboard
+	dashboardPayload = "dashboard"
+)
+
+// webAppPage is the dashboard, a single page calling the API below
+//
+//go:embed webapp.html
+var webAppPage []byte
+
+// webAppMember identifies a user in a chat for the admin cache
+type webAppMember struct {
+	chatID int64
+	userID int64
+}
+
+// webAppAdmin is a remembered admin check
+type webAppAdmin struct {
+	admin   bool
+	expires time.Time
+}
+
+// webAppError is an API error shown to the dashboard user
+type webAppError struct {
+	status int
+	text   string
+}
+
+func (e *webAppError) Error() string {
+	return e.text
+}
+
+// webAppAccount is an account as listed by the dashboard
+type webAppAccount struct {
+	ID      int64      `json:"id"`
+	Email   string     `json:"email"`
+	ChatID  int64      `json:"chat_id"`
+	TopicID int        `json:"topic_id"`
+	Active  bool       `json:"active"`
+	Status  string     `json:"status"` // Manager.GetStatus or "paused"
+	Idle    bool       `json:"idle"`
+	Recent  int        `json:"recent"` // Emails of the last 24 hours
+	Codes   int        `json:"codes"`  // Of them with codes
+	Total   int        `json:"total"`
+	Failed  int        `json:"failed"` // Emails Telegram did not accept, see /redeliver
+	LastAt  *time.Time `json:"last_at,omitempty"`
+}
+
+// webAppMessage is a recent email as listed by the dashboard
+type webAppMessage struct {
+	ID         int64     `json:"id"`
+	From       string    `json:"from"`
+	Subject    string    `json:"subject"`
+	ReceivedAt time.Time `json:"received_at"`
+	Read       bool      `json:"read"`
+	Codes      bool      `json:"codes"`
+	Failed     bool      `json:"failed"`
+	Link       string    `json:"link,omitempty"` // Telegram message of the email
+}
+
+// webAppKey derives the key signing the initData of the bot's mini apps
+func webAppKey(token string) []byte {
+	mac := hmac.New(sha256.New, []byte("WebAppData"))
+	mac.Write([]byte(token))
+	return mac.Sum(nil)
+}
+
+// validateInitData checks the signature and age of the initData Telegram
+// passes to a mini app and returns the user who opened it
+func validateInitData(initData string, key []byte, now time.Time) (*models.User, error) {
+	values, err := url.ParseQuery(initData)
+	if err != nil {
+		return nil, fmt.Errorf("malformed init data: %w", err)
+	}
+	hash, err := hex.DecodeString(values.Get("hash"))
+	if err != nil || len(hash) == 0 {
+		return nil, errors.New("init data is not signed")
+	}
+
+	// Fields other than hash, sorted and joined with newlines
+	names := make([]string, 0, len(values))
+	for name := range values {
+		if name != "hash" {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	lines := make([]string, len(names))
+	for i, name := range names {
+		lines[i] = name + "=" + values.Get(name)
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(strings.Join(lines, "\n")))
+	if !hmac.Equal(mac.Sum(nil), hash) {
+		return nil, errors.New("invalid init data signature")
+	}
+
+	authDate, err := strconv.ParseInt(values.Get("auth_date"), 10, 64)
+	if err != nil {
+		return nil, errors.New("init data has no auth_date")
+	}
+	if now.Sub(time.Unix(authDate, 0)) > webAppInitDataTTL {
+		return nil, errors.New("init data expired")
+	}
+
+	var user models.User
+	if err := json.Unmarshal([]byte(values.Get("user")), &user); err != nil || user.ID == 0 {
+		return nil, errors.New("init data has no user")
+	}
+	return &user, nil
+}
+
+// webAppURL returns the URL of the bot's dashboard: the configured URL with
+// the bot ID appended, so bots can share one server
+func (b *Bot) webAppURL() string {
+	return strings.TrimRight(b.config.WebAppURL, "/") + "/" + strconv.FormatInt(b.id, 10) + "/"
+}
+
+// registerWebApp adds the dashboard page and its API to the HTTP server
+func (b *Bot) registerWebApp(mux *http.ServeMux) {
+	base := "/" + strconv.FormatInt(b.id, 10)
+	if u, err := url.Parse(b.webAppURL()); err == nil {
+		base = strings.TrimSuffix(u.Path, "/")
+	}
+
+	mux.HandleFunc("GET "+base+"/{$}", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Write(webAppPage)
+	})
+	mux.Handle("GET "+base+"/api/accounts", b.webAppAPI(b.webAppListAccounts))
+	mux.Handle("GET "+base+"/api/accounts/{id}/messages", b.webAppAPI(b.webAppListMessages))
+	mux.Handle("POST "+base+"/api/accounts/{id}/{action}", b.webAppAPI(b.webAppAction))
+}
+
+// webAppAPI wraps a dashboard API call: it authenticates the initData sent
+// as "Authorization: tma <initData>" and writes the result as JSON
+func (b *Bot) webAppAPI(fn func(ctx context.Context, user *models.User, req *http.Request) (any, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		initData, _ := strings.CutPrefix(req.Header.Get("Authorization"), "tma ")
+		user, err := validateInitData(initData, b.webAppKey, time.Now())
+		if err != nil {
+			b.logger.Debug("rejected dashboard request", "error", err, "path", req.URL.Path)
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "unauthorized"})
+			return
+		}
+		req.Body = http.MaxBytesReader(w, req.Body, webAppMaxBody)
+
+		result, err := fn(req.Context(), user, req)
+		var apiErr *webAppError
+		switch {
+		case errors.As(err, &apiErr):
+			w.WriteHeader(apiErr.status)
+			result = map[string]string{"error": apiErr.text}
+		case err != nil:
+			b.logger.Error("dashboard request failed", "error", err, "path", req.URL.Path, "user_id", user.ID)
+			w.WriteHeader(http.StatusInternalServerError)
+			result = map[string]string{"error": "internal error"}
+		}
+		json.NewEncoder(w).Encode(result)
+	})
+}
+
+// webAppListAccounts lists the accounts the user may manage with their
+// connection state and the delivery stats of the last 24 hours
+func (b *Bot) webAppListAccounts(ctx context.Context, user *models.User, req *http.Request) (any, error) {
+	accounts, err := b.webAppAccounts(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	since := time.Now().Add(-statsPeriod)
+	list := make([]webAppAccount, 0, len(accounts))
+	for _, acc := range accounts {
+		stats, err := b.db.GetMessageStats(ctx, acc.ID, since)
+		if err != nil {
+			return nil, err
+		}
+		failed, err := b.db.CountFailedMessages(ctx, acc.ID)
+		if err != nil {
+			return nil, err
+		}
+		status := b.emailManager.GetStatus(acc.ID)
+		if !acc.IsActive {
+			status = "paused"
+		}
+		list = append(list, webAppAccount{
+			ID:      acc.ID,
+			Email:   acc.Email,
+			ChatID:  acc.ChatID,
+			TopicID: acc.TopicID,
+			Active:  acc.IsActive,
+			Status:  status,
+			Idle:    b.emailManager.UsingIdle(acc.ID),
+			Recent:  stats.Recent,
+			Codes:   stats.Codes,
+			Total:   stats.Total,
+			Failed:  failed,
+			LastAt:  stats.LastAt,
+		})
+	}
+	return list, nil
+}
+
+// webAppListMessages lists the latest emails of an account
+func (b *Bot) webAppListMessages(ctx context.Context, user *models.User, req *http.Request) (any, error) {
+	account, err := b.webAppAccount(ctx, user.ID, req.PathValue("id"))
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := b.db.GetMessagesPage(ctx, account.ID, database.PageN(0, webAppMessages))
+	if err != nil {
+		return nil, err
+	}
+	list := make([]webAppMessage, 0, len(messages))
+	for _, m := range messages {
+		from := m.FromAddr
+		if m.FromName != "" {
+			from = m.FromName + " <" + m.FromAddr + ">"
+		}
+		item := webAppMessage{
+			ID:         m.ID,
+			From:       from,
+			Subject:    m.Subject,
+			ReceivedAt: m.ReceivedAt,
+			Read:       m.IsRead,
+			Codes:      m.DetectedCodes != "" && m.DetectedCodes != "[]",
+			Failed:     m.DeliveryStatus == appmodels.DeliveryFailed,
+		}
+		if m.TelegramMsgID != 0 && hasMessageLinks(account.ChatID) {
+			item.Link = messageLink(account.ChatID, m.TelegramMsgID)
+		}
+		list = append(list, item)
+	}
+	return list, nil
+}
+
+// webAppAction pauses, resumes or reconnects an account and tells its topic
+// who did it
+func (b *Bot) webAppAction(ctx context.Context, user *models.User, req *http.Request) (any, error) {
+	account, err := b.webAppAccount(ctx, user.ID, req.PathValue("id"))
+	if err != nil {
+		return nil, err
+	}
+	chatCtx := b.chatContext(ctx, account.ChatID)
+	who := html.EscapeString(webAppUserName(user))
+
+	var notice string
+	switch action := req.PathValue("action"); action {
+	case "pause":
+		if !account.IsActive {
+			break
+		}
+		if err := b.db.SetAccountActive(ctx, account.ID, false); err != nil {
+			return nil, err
+		}
+		b.emailManager.StopAccounts([]int64{account.ID})
+		b.wakeStatusBoards()
+		notice = i18n.Tf(chatCtx, "⏸ Пересылка приостановлена из панели управления (%s)", who)
+	case "resume":
+		if account.IsActive {
+			break
+		}
+		if err := b.db.SetAccountActive(ctx, account.ID, true); err != nil {
+			return nil, err
+		}
+		account.IsActive = true
+		b.wakeStatusBoards()
+		if errs := b.emailManager.StartAccounts(ctx, []*appmodels.EmailAccount{account}); errs[account.ID] != nil {
+			b.logger.Warn("failed to start account from dashboard", "error", errs[account.ID], "account_id", account.ID)
+		}
+		notice = i18n.Tf(chatCtx, "▶️ Пересылка возобновлена из панели управления (%s)", who)
+	case "reconnect":
+		if !account.IsActive {
+			return nil, &webAppError{http.StatusConflict, "account is paused"}
+		}
+		if err := b.emailManager.RestartAccount(ctx, account); err != nil {
+			b.logger.Warn("failed to reconnect account from dashboard", "error", err, "account_id", account.ID)
+		}
+	default:
+		return nil, &webAppError{http.StatusNotFound, "unknown action " + action}
+	}
+
+	b.logger.Info("dashboard action", "action", req.PathValue("action"), "account_id", account.ID, "user_id", user.ID)
+	if notice != "" {
+		b.sendMessage(chatCtx, account.ChatID, account.TopicID, notice)
+	}
+
+	status := b.emailManager.GetStatus(account.ID)
+	if !account.IsActive {
+		status = "paused"
+	}
+	return map[string]any{"active": account.IsActive, "status": status}, nil
+}
+
+// webAppAccounts returns the accounts of this bot a dashboard user may
+// manage: all of them for the owner and operators, otherwise those of chats
+// the user is an admin of
+func (b *Bot) webAppAccounts(ctx context.Context, userID int64) ([]*appmodels.EmailAccount, error) {
+	all, err := b.db.GetAllAccounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	manager := b.isOwner(userID) || b.isOperator(userID)
+	var accounts []*appmodels.EmailAccount
+	for _, acc := range all {
+		if acc.BotID != b.accountBotID() {
+			continue
+		}
+		if !manager && !b.webAppIsAdmin(ctx, acc.ChatID, userID) {
+			continue
+		}
+		accounts = append(accounts, acc)
+	}
+	return accounts, nil
+}
+
+// webAppAccount returns an account by its ID in a request path if the
+// dashboard user may manage it
+func (b *Bot) webAppAccount(ctx context.Context, userID int64, id string) (*appmodels.EmailAccount, error) {
+	notFound := &webAppError{http.StatusNotFound, "account not found"}
+	accountID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, notFound
+	}
+	account, err := b.db.GetAccountByID(ctx, accountID)
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, notFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if account.BotID != b.accountBotID() {
+		return nil, notFound
+	}
+	if !b.isOwner(userID) && !b.isOperator(userID) && !b.webAppIsAdmin(ctx, account.ChatID, userID) {
+		return nil, notFound
+	}
+	return account, nil
+}
+
+// webAppIsAdmin reports whether a user is an admin of a chat, remembering
+// the answer for webAppAdminTTL. Failed checks deny access and are retried.
+func (b *Bot) webAppIsAdmin(ctx context.Context, chatID, userID int64) bool {
+	key := webAppMember{chatID: chatID, userID: userID}
+	b.webAppMu.Lock()
+	cached, ok := b.webAppAdmins[key]
+	b.webAppMu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.admin
+	}
+
+	admin, err := b.isUserAdmin(ctx, chatID, userID)
+	if err != nil {
+		b.logger.Warn("failed to check dashboard access", "error", err, "chat_id", chatID, "user_id", userID)
+		return false
+	}
+
+	b.webAppMu.Lock()
+	for k, v := range b.webAppAdmins {
+		if time.Now().After(v.expires) {
+			delete(b.webAppAdmins, k)
+		}
+	}
+	b.webAppAdmins[key] = webAppAdmin{admin: admin, expires: time.Now().Add(webAppAdminTTL)}
+	b.webAppMu.Unlock()
+	return admin
+}
+
+// webAppUserName returns how a dashboard user is named in topic notices
+func webAppUserName(user *models.User) string {
+	if user.Username != "" {
+		return "@" + user.Username
+	}
+	return strings.TrimSpace(user.FirstName + " " + user.LastName)
+}
+
+// setWebAppMenuButton makes the menu button of private chats open the
+// dashboard. The default button has one text for all users.
+func (b *Bot) setWebAppMenuButton(ctx context.Context) {
+	_, err := b.bot.SetChatMenuButton(ctx, &bot.SetChatMenuButtonParams{
+		MenuButton: models.MenuButtonWebApp{
+			Type:   models.MenuButtonTypeWebApp,
+			Text:   "Dashboard",
+			WebApp: models.WebAppInfo{URL: b.webAppURL()},
+		},
+	})
+	if err != nil {
+		b.logger.Warn("failed to set dashboard menu button", "error", err)
+	}
+}
+
+// handleDashboard handles /dashboard command: opens the dashboard in a
+// private chat or links to the private chat from groups, where Telegram
+// does not open mini apps from buttons
+func (b *Bot) handleDashboard(ctx context.Context, tgBot *bot.Bot, update *models.Update) {
+	msg := update.Message
+	if !b.config.WebAppEnabled() {
+		b.sendMessage(ctx, msg.Chat.ID, msg.MessageThreadID, "Панель управления не настроена: владельцу бота нужно указать WEBAPP_URL")
+		return
+	}
+
+	if msg.Chat.Type == "private" {
+		b.sendDashboardButton(ctx, msg.Chat.ID)
+		return
+	}
+	keyboard := &models.InlineKeyboardMarkup{
+		InlineKeyboard: [][]models.InlineKeyboardButton{{
+			{Text: "Открыть панель", URL: fmt.Sprintf("https://t.me/%s?start=%s", b.username, dashboardPayload)},
+		}},
+	}
+	b.sendMessageWithKeyboard(ctx, msg.Chat.ID, msg.MessageThreadID,
+		"Панель управления открывается в личных сообщениях с ботом: аккаунты чатов, где вы администратор, последние письма и статистика доставки",
+		keyboard, messageOptions{})
+}
+
+// sendDashboardButton sends the button opening the dashboard to a private chat
+func (b *Bot) sendDashboardButton(ctx context.Context, chatID int64) {
+	keyboard := &models.InlineKeyboardMarkup{
+		InlineKeyboard: [][]models.InlineKeyboardButton{{
+			{Text: "Открыть панель", WebApp: &models.WebAppInfo{URL: b.webAppURL()}},
+		}},
+	}
+	b.sendMessageWithKeyboard(ctx, chatID, 0,
+		"Панель управления: аккаунты чатов, где вы администратор, последние письма, статистика доставки, пауза и переподключение",
+		keyboard, messageOptions{})
+}
@@ -0,0 +1,130 @@
+package telegram
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+)
+
+const (
+	// webhookMaxBody limits the size of a webhook request
+	webhookMaxBody = 1 << 20
+	// webhookShutdownTimeout bounds the wait for running requests on shutdown
+	webhookShutdownTimeout = 10 * time.Second
+)
+
+// webhookSecret returns the configured secret token, or one derived from the
+// bot token so every bot of an instance has its own
+func webhookSecret(configured, token string) string {
+	if configured != "" {
+		return configured
+	}
+	sum := sha256.Sum256([]byte("webhook:" + token))
+	return hex.EncodeToString(sum[:20])
+}
+
+// webhookURL returns the URL Telegram sends the bot's updates to: the
+// configured URL with the bot ID appended, so bots can share one server
+func (b *Bot) webhookURL() string {
+	return strings.TrimRight(b.config.TelegramWebhookURL, "/") + "/" + strconv.FormatInt(b.id, 10)
+}
+
+// webhookPath returns the path of webhookURL served by the HTTP server
+func (b *Bot) webhookPath() string {
+	u, err := url.Parse(b.webhookURL())
+	if err != nil {
+		return "/" + strconv.FormatInt(b.id, 10)
+	}
+	return u.Path
+}
+
+// webhookHandler accepts update POSTs carrying the secret token
+func (b *Bot) webhookHandler() http.Handler {
+	handler := b.bot.WebhookHandler()
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		token := req.Header.Get("X-Telegram-Bot-Api-Secret-Token")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(b.webhookSecret)) != 1 {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		req.Body = http.MaxBytesReader(w, req.Body, webhookMaxBody)
+		handler(w, req)
+	})
+}
+
+// runWebhook registers the webhook, retrying with backoff until it succeeds,
+// and processes updates delivered to the HTTP server until ctx is cancelled
+func (b *Bot) runWebhook(ctx context.Context) {
+	backoff := pollingMinBackoff
+	for {
+		_, err := b.bot.SetWebhook(ctx, &bot.SetWebhookParams{
+			URL:         b.webhookURL(),
+			SecretToken: b.webhookSecret,
+		})
+		if err == nil {
+			break
+		}
+		b.logger.Error("failed to set telegram webhook, retrying", "error", err, "backoff", backoff)
+		if !sleepCtx(ctx, backoff) {
+			return
+		}
+		backoff = min(backoff*2, pollingMaxBackoff)
+	}
+
+	b.logger.Info("receiving telegram updates by webhook", "url", b.webhookURL())
+	b.bot.StartWebhook(ctx)
+}
+
+// deleteWebhook removes a webhook left from an earlier run in webhook mode,
+// which would make long polling fail
+func (b *Bot) deleteWebhook(ctx context.Context) {
+	if _, err := b.bot.DeleteWebhook(ctx, &bot.DeleteWebhookParams{}); err != nil {
+		b.logger.Warn("failed to delete telegram webhook", "error", err)
+	}
+}
+
+// serveHTTP runs the HTTP server receiving the updates of all bots and
+// serving their dashboards until ctx is cancelled
+func (r *Router) serveHTTP(ctx context.Context) {
+	mux := http.NewServeMux()
+	for _, b := range r.bots {
+		if b.config.WebhookEnabled() {
+			mux.Handle(b.webhookPath(), b.webhookHandler())
+		}
+		if b.config.WebAppEnabled() {
+			b.registerWebApp(mux)
+		}
+	}
+
+	addr := r.bots[0].config.ListenAddr
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), webhookShutdownTimeout)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	r.logger.Info("http server listening", "addr", addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		r.logger.Error("http server stopped", "error", err, "addr", addr)
+	}
+}
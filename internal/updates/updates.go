@@ -0,0 +1,109 @@
+// Package updates checks GitHub releases for newer versions of the bot
+package updates
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Release is a published GitHub release
+type Release struct {
+	Tag         string    `json:"tag_name"`
+	Name        string    `json:"name"`
+	Body        string    `json:"body"`
+	URL         string    `json:"html_url"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+// Security reports whether the release notes mention security fixes
+func (r *Release) Security() bool {
+	text := strings.ToLower(r.Name + "\n" + r.Body)
+	return strings.Contains(text, "security") || strings.Contains(text, "cve-") || strings.Contains(text, "уязвим")
+}
+
+// Checker fetches the latest release of a repository
+type Checker struct {
+	repo   string // owner/name
+	client *http.Client
+}
+
+// NewChecker creates a checker for a GitHub repository ("owner/name")
+func NewChecker(repo string) *Checker {
+	return &Checker{
+		repo:   repo,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Latest returns the latest published (non-draft, non-prerelease) release
+func (c *Checker) Latest(ctx context.Context) (*Release, error) {
+	url := fmt.Sprintf("https://api.github.com/repos/%s/releases/latest", c.repo)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch latest release: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("GitHub API returned %d: %s", resp.StatusCode, body)
+	}
+
+	var release Release
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return nil, fmt.Errorf("failed to decode release: %w", err)
+	}
+	return &release, nil
+}
+
+// Newer reports whether version tag is newer than current. Versions that
+// are not numeric (e.g. "dev" builds) are never considered older.
+func Newer(tag, current string) bool {
+	a, ok := parseVersion(tag)
+	if !ok {
+		return false
+	}
+	b, ok := parseVersion(current)
+	if !ok {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] > b[i]
+		}
+	}
+	return false
+}
+
+// parseVersion parses "v1.2.3" (pre-release and build suffixes ignored)
+func parseVersion(s string) ([3]int, bool) {
+	var v [3]int
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.IndexAny(s, "-+"); i >= 0 {
+		s = s[:i]
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) == 0 || len(parts) > 3 {
+		return v, false
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return v, false
+		}
+		v[i] = n
+	}
+	return v, true
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// BotAdmin is a chat member granted bot management with /grant. They pass
+// the admin checks of the bot in that chat without being a Telegram admin.
+type BotAdmin struct {
+	ChatID    int64     `db:"chat_id"`
+	UserID    int64     `db:"user_id"`
+	Name      string    `db:"name"`       // Name or @username when granted, for listing
+	GrantedBy int64     `db:"granted_by"` // Telegram User ID of who granted
+	CreatedAt time.Time `db:"created_at"`
+}
@@ -4,14 +4,35 @@ package models
 type CallbackAction string
 
 const (
-	CallbackMarkRead CallbackAction = "mr"
-	CallbackDelete   CallbackAction = "del"
-	CallbackCopyCode CallbackAction = "cc"
+	CallbackMarkRead   CallbackAction = "mr"
+	CallbackDelete     CallbackAction = "del"
+	CallbackCopyCode   CallbackAction = "cc"
+	CallbackFetchAtt   CallbackAction = "att"
+	CallbackStatusPage CallbackAction = "sp"
+	CallbackForgetChat CallbackAction = "fg"
+	CallbackOpenHTML   CallbackAction = "html"
+	CallbackFullText   CallbackAction = "ft"
+	CallbackSendEmail  CallbackAction = "send"
+	CallbackHistory    CallbackAction = "hp" // MessageID is the account, Arg the page
+	CallbackOpenEmail  CallbackAction = "ho"
+	CallbackPurge      CallbackAction = "pm" // MessageID is the account
+	CallbackRotateKey  CallbackAction = "rk"
+	CallbackMove       CallbackAction = "mv" // Arg is MoveArchive, MoveCancel or a folder index; none opens the folder list
+	CallbackUnsub      CallbackAction = "us" // Arg is "yes" or "no" on the confirmation, none asks for it
+	CallbackPurgeMail  CallbackAction = "pu" // MessageID is the account; the filters are those of the /purge command the confirmation replies to
+	CallbackEML        CallbackAction = "eml"
+)
+
+// Arguments of CallbackMove
+const (
+	MoveArchive = "a"
+	MoveCancel  = "no"
 )
 
 // CallbackData structure for inline button callback
 type CallbackData struct {
 	Action    CallbackAction `json:"a"`
 	MessageID int64          `json:"m"`
-	CodeIndex int            `json:"c,omitempty"` // Code index for copying
+	CodeIndex int            `json:"c,omitempty"` // Code index for copying, attachment index for fetching
+	Arg       string         `json:"-"`           // Extra action argument (page, nonce, ...)
 }
@@ -0,0 +1,127 @@
+package models
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Parse modes supported for forwarded emails
+const (
+	ParseModeHTML       = "HTML"
+	ParseModeMarkdownV2 = "MarkdownV2"
+)
+
+// ChatSettings represents per-chat bot settings
+type ChatSettings struct {
+	ChatID      int64     `db:"chat_id"`      // Telegram supergroup ID
+	ParseMode   string    `db:"parse_mode"`   // ParseModeHTML or ParseModeMarkdownV2
+	CustomEmoji string    `db:"custom_emoji"` // JSON object: icon name -> custom emoji ID
+	CreatedAt   time.Time `db:"created_at"`
+	UpdatedAt   time.Time `db:"updated_at"`
+
+	// Pinned status board (StatusMsgID = 0 when disabled)
+	StatusTopicID int   `db:"status_topic_id"`
+	StatusMsgID   int   `db:"status_msg_id"`
+	StatusBotID   int64 `db:"status_bot_id"` // Bot that owns the message (0 = primary bot)
+
+	DisabledExtractors string `db:"disabled_extractors"` // Comma-separated sender-specific extractors turned off
+	BroadcastOptOut    bool   `db:"broadcast_opt_out"`   // Skip owner announcements (/broadcast)
+	Permissions        string `db:"permissions"`         // JSON object: command -> roles allowed to run it, replacing the default check
+	Language           string `db:"language"`            // Language of bot messages (i18n code)
+	AutoTopics         bool   `db:"auto_topics"`         // /connect and /create in General create a topic named after the mailbox
+}
+
+// Roles of a user in a chat, used by per-command permissions
+const (
+	RoleOwner    = "owner"    // chat creator
+	RoleAdmin    = "admin"    // chat administrator (or creator), or a user granted with /grant
+	RoleOperator = "operator" // configured bot operator (OPERATOR_IDS)
+	RoleMember   = "member"   // anyone in the chat
+)
+
+// Roles lists the roles in the order they are shown
+var Roles = []string{RoleOwner, RoleAdmin, RoleOperator, RoleMember}
+
+// DefaultChatSettings returns settings used for chats without a stored row
+func DefaultChatSettings(chatID int64) *ChatSettings {
+	return &ChatSettings{
+		ChatID:      chatID,
+		ParseMode:   ParseModeHTML,
+		CustomEmoji: "{}",
+		Permissions: "{}",
+		Language:    "ru",
+	}
+}
+
+// CustomEmojiMap returns the configured custom emoji IDs by icon name
+func (s *ChatSettings) CustomEmojiMap() map[string]string {
+	emoji := make(map[string]string)
+	if s.CustomEmoji != "" {
+		_ = json.Unmarshal([]byte(s.CustomEmoji), &emoji)
+	}
+	return emoji
+}
+
+// SetCustomEmoji sets (or with an empty id removes) the custom emoji for an icon
+func (s *ChatSettings) SetCustomEmoji(name, id string) {
+	emoji := s.CustomEmojiMap()
+	if id == "" {
+		delete(emoji, name)
+	} else {
+		emoji[name] = id
+	}
+	data, _ := json.Marshal(emoji)
+	s.CustomEmoji = string(data)
+}
+
+// ExtractorEnabled reports whether a sender-specific extractor is enabled
+func (s *ChatSettings) ExtractorEnabled(name string) bool {
+	return !slices.Contains(s.disabledExtractors(), name)
+}
+
+// SetExtractorEnabled enables or disables a sender-specific extractor
+func (s *ChatSettings) SetExtractorEnabled(name string, enabled bool) {
+	disabled := slices.DeleteFunc(s.disabledExtractors(), func(n string) bool { return n == name })
+	if !enabled {
+		disabled = append(disabled, name)
+	}
+	s.DisabledExtractors = strings.Join(disabled, ",")
+}
+
+func (s *ChatSettings) disabledExtractors() []string {
+	if s.DisabledExtractors == "" {
+		return nil
+	}
+	return strings.Split(s.DisabledExtractors, ",")
+}
+
+// PermissionMap returns the overridden commands with their allowed roles
+func (s *ChatSettings) PermissionMap() map[string][]string {
+	permissions := make(map[string][]string)
+	if s.Permissions != "" {
+		_ = json.Unmarshal([]byte(s.Permissions), &permissions)
+	}
+	return permissions
+}
+
+// CommandRoles returns the roles allowed to run a command, or false if the
+// command keeps its default check
+func (s *ChatSettings) CommandRoles(command string) ([]string, bool) {
+	roles, ok := s.PermissionMap()[command]
+	return roles, ok
+}
+
+// SetCommandRoles sets (or with no roles resets) the roles allowed to run a
+// command
+func (s *ChatSettings) SetCommandRoles(command string, roles []string) {
+	permissions := s.PermissionMap()
+	if len(roles) == 0 {
+		delete(permissions, command)
+	} else {
+		permissions[command] = roles
+	}
+	data, _ := json.Marshal(permissions)
+	s.Permissions = string(data)
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// CodePattern is a per-account regular expression for verification codes,
+// used together with the built-in code patterns
+type CodePattern struct {
+	ID        int64     `db:"id"`
+	AccountID int64     `db:"account_id"` // FK to EmailAccount
+	Pattern   string    `db:"pattern"`
+	CreatedBy int64     `db:"created_by"` // Telegram User ID of admin who created
+	CreatedAt time.Time `db:"created_at"`
+}
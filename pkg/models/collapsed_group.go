@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// CollapsedGroup is a Telegram message that aggregates repeated emails with
+// the same normalized subject
+type CollapsedGroup struct {
+	AccountID     int64     `db:"account_id"`      // FK to EmailAccount
+	SubjectKey    string    `db:"subject_key"`     // Normalized subject
+	TelegramMsgID int       `db:"telegram_msg_id"` // Message being updated
+	Count         int       `db:"count"`           // Emails collapsed so far
+	FirstAt       time.Time `db:"first_at"`
+	LastAt        time.Time `db:"last_at"`
+}
@@ -2,17 +2,71 @@ package models
 
 import "time"
 
+// Handling of spam and newsletters, see EmailAccount.BulkMode
+const (
+	BulkDeliver = ""       // Post like any other email
+	BulkDrop    = "drop"   // Do not post
+	BulkDigest  = "digest" // List once a day in a digest
+	BulkTopic   = "topic"  // Post silently to BulkTopicID
+)
+
+// Batching of non-urgent emails, see EmailAccount.DigestMode
+const (
+	DigestOff    = ""       // Post every email right away
+	DigestDaily  = "daily"  // List non-urgent emails once a day
+	DigestWeekly = "weekly" // List non-urgent emails once a week
+)
+
+// Delivery during quiet hours, see EmailAccount.QuietMode
+const (
+	QuietSilent = ""     // Post without notification sound
+	QuietHold   = "hold" // Post when quiet hours end
+)
+
+// Sender pictures next to forwarded emails, see EmailAccount.Avatars
+const (
+	AvatarsOff   = ""      // No pictures
+	AvatarsEmoji = "emoji" // An emoji of the sender's domain after the sender icon
+	AvatarsPhoto = "photo" // The sender's Gravatar above the email, the domain emoji if there is none
+)
+
 // EmailAccount represents a connected email account
 type EmailAccount struct {
-	ID         int64     `db:"id"`
-	Email      string    `db:"email"`
-	Password   string    `db:"password"`    // Encrypted password
-	IMAPServer string    `db:"imap_server"` // e.g., imap.gmail.com:993
-	ChatID     int64     `db:"chat_id"`     // Telegram supergroup ID
-	TopicID    int       `db:"topic_id"`    // Telegram topic (message_thread_id)
-	IsActive   bool      `db:"is_active"`   // Is connection active
-	LastUID    uint32    `db:"last_uid"`    // Last processed email UID
-	CreatedAt  time.Time `db:"created_at"`
-	UpdatedAt  time.Time `db:"updated_at"`
-	CreatedBy  int64     `db:"created_by"` // Telegram User ID of admin who created
+	ID          int64     `db:"id"`
+	Email       string    `db:"email"`
+	Password    string    `db:"password"`     // Encrypted password
+	IMAPServer  string    `db:"imap_server"`  // e.g., imap.gmail.com:993
+	SMTPServer  string    `db:"smtp_server"`  // e.g., smtp.gmail.com:465 (empty = resolved on first reply)
+	ChatID      int64     `db:"chat_id"`      // Telegram supergroup ID
+	TopicID     int       `db:"topic_id"`     // Telegram topic (message_thread_id)
+	IsActive    bool      `db:"is_active"`    // Is connection active
+	LastUID     uint32    `db:"last_uid"`     // Last processed email UID
+	UIDValidity uint32    `db:"uid_validity"` // UIDVALIDITY of INBOX that LastUID belongs to (0 = not seen yet)
+	CreatedAt   time.Time `db:"created_at"`
+	UpdatedAt   time.Time `db:"updated_at"`
+	CreatedBy   int64     `db:"created_by"`  // Telegram User ID of admin who created
+	BotID       int64     `db:"bot_id"`      // Telegram bot that serves the account (0 = primary bot)
+	Provisioned bool      `db:"provisioned"` // Mailbox was created in Mailcow by /create or /createbatch
+
+	// Delivery settings
+	Silent          bool   `db:"silent"`           // Send without notification sound
+	PriorityPattern string `db:"priority_pattern"` // Regex on subject/sender that always notifies
+	ProtectContent  bool   `db:"protect_content"`  // Forbid forwarding/saving forwarded emails
+	CollapseWindow  int    `db:"collapse_window"`  // Seconds to collapse same-subject emails into one message (0 = off)
+	FormatProfile   string `db:"format_profile"`   // Built-in formatting profile (empty = detailed)
+	MessageTemplate string `db:"message_template"` // Custom layout of forwarded emails set by /template (empty = profile layout)
+	IdleMode        string `db:"idle_mode"`        // How to wait for new mail (empty = IDLE if supported, "poll")
+	PollInterval    int    `db:"poll_interval"`    // Seconds between mail checks when polling (0 = EMAIL_POLL_INTERVAL)
+	IdleTimeout     int    `db:"idle_timeout"`     // Seconds after which IDLE is restarted (0 = IMAP_IDLE_TIMEOUT)
+	SpamTopicID     int    `db:"spam_topic_id"`    // Topic for emails filtered out by deny rules (0 = skip them)
+	PinCodes        int    `db:"pin_codes"`        // Seconds to keep emails with codes pinned (0 = off)
+	BulkMode        string `db:"bulk_mode"`        // What to do with spam and newsletters: BulkDeliver, BulkDrop, BulkDigest or BulkTopic
+	BulkTopicID     int    `db:"bulk_topic_id"`    // Topic for spam and newsletters in BulkTopic mode
+	DigestMode      string `db:"digest_mode"`      // Batch emails without codes: DigestOff, DigestDaily or DigestWeekly
+	QuietHours      string `db:"quiet_hours"`      // Local time window like "23:00-08:00" (empty = off)
+	QuietTimezone   string `db:"quiet_tz"`         // IANA time zone of QuietHours (empty = server time)
+	QuietMode       string `db:"quiet_mode"`       // What happens to emails in quiet hours: QuietSilent or QuietHold
+	Avatars         string `db:"avatars"`          // Sender pictures: AvatarsOff, AvatarsEmoji or AvatarsPhoto
+	UnreadBadge     bool   `db:"unread_badge"`     // The topic name shows the number of unread emails
+	TopicName       string `db:"topic_name"`       // Topic name without the unread count (empty = Email)
 }
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Filter actions
+const (
+	FilterAllow = "allow" // always deliver matching emails
+	FilterDeny  = "deny"  // skip matching emails or route them to the spam topic
+)
+
+// Filter fields
+const (
+	FilterFrom    = "from"    // sender address, case-insensitive
+	FilterDomain  = "domain"  // sender domain and its subdomains
+	FilterSubject = "subject" // regular expression on the subject
+)
+
+// Filter is an allow or deny rule of an account
+type Filter struct {
+	ID        int64     `db:"id"`
+	AccountID int64     `db:"account_id"` // FK to EmailAccount
+	Action    string    `db:"action"`     // FilterAllow or FilterDeny
+	Field     string    `db:"field"`      // FilterFrom, FilterDomain or FilterSubject
+	Pattern   string    `db:"pattern"`
+	CreatedBy int64     `db:"created_by"` // Telegram User ID of admin who created
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// Matches reports whether an email with the given sender and subject matches
+// the rule. A subject rule with an invalid pattern matches nothing.
+func (f *Filter) Matches(fromAddr, subject string) bool {
+	switch f.Field {
+	case FilterFrom:
+		return strings.EqualFold(fromAddr, f.Pattern)
+	case FilterDomain:
+		_, domain, ok := strings.Cut(strings.ToLower(fromAddr), "@")
+		pattern := strings.ToLower(f.Pattern)
+		return ok && (domain == pattern || strings.HasSuffix(domain, "."+pattern))
+	case FilterSubject:
+		re, err := regexp.Compile(f.Pattern)
+		return err == nil && re.MatchString(subject)
+	default:
+		return false
+	}
+}
+
+// FilterEmail applies the rules of an account to an email: an allow rule
+// match delivers it, a deny rule match filters it out, and with allow rules
+// present an email matching none of them is filtered out too
+func FilterEmail(filters []*Filter, fromAddr, subject string) (denied bool) {
+	_, denied = MatchFilter(filters, fromAddr, subject)
+	return denied
+}
+
+// MatchFilter is FilterEmail that also returns the rule that decided, nil if
+// none matched
+func MatchFilter(filters []*Filter, fromAddr, subject string) (rule *Filter, denied bool) {
+	var hasAllow bool
+	for _, f := range filters {
+		if f.Action == FilterAllow {
+			hasAllow = true
+			if f.Matches(fromAddr, subject) {
+				return f, false
+			}
+		}
+	}
+	for _, f := range filters {
+		if f.Action == FilterDeny && f.Matches(fromAddr, subject) {
+			return f, true
+		}
+	}
+	return nil, hasAllow
+}
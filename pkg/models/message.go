@@ -1,24 +1,88 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // EmailMessage represents an email message
 type EmailMessage struct {
-	ID            int64     `db:"id"`
-	AccountID     int64     `db:"account_id"`      // FK to EmailAccount
-	UID           uint32    `db:"uid"`             // IMAP UID
-	MessageID     string    `db:"message_id"`      // Email Message-ID header
-	FromAddr      string    `db:"from_addr"`       // Sender email
-	FromName      string    `db:"from_name"`       // Sender name
-	Subject       string    `db:"subject"`         // Email subject
-	BodyText      string    `db:"body_text"`       // Parsed text body
-	BodyHTML      string    `db:"body_html"`       // Original HTML body
-	ReceivedAt    time.Time `db:"received_at"`     // When email was received
-	IsRead        bool      `db:"is_read"`         // Marked as read
-	IsDeleted     bool      `db:"is_deleted"`      // Marked as deleted
-	TelegramMsgID int       `db:"telegram_msg_id"` // Telegram message ID
-	DetectedCodes string    `db:"detected_codes"`  // JSON array of detected codes
-	CreatedAt     time.Time `db:"created_at"`
+	ID              int64     `db:"id"`
+	AccountID       int64     `db:"account_id"`        // FK to EmailAccount
+	UID             uint32    `db:"uid"`               // IMAP UID
+	MessageID       string    `db:"message_id"`        // Email Message-ID header
+	FromAddr        string    `db:"from_addr"`         // Sender email
+	FromName        string    `db:"from_name"`         // Sender name
+	Subject         string    `db:"subject"`           // Email subject
+	BodyText        string    `db:"body_text"`         // Parsed text body
+	BodyHTML        string    `db:"body_html"`         // Original HTML body
+	ReceivedAt      time.Time `db:"received_at"`       // Date header (set by the sender)
+	InternalDate    time.Time `db:"internal_date"`     // When the server received the email
+	Size            uint32    `db:"size"`              // RFC822 size in bytes
+	IsRead          bool      `db:"is_read"`           // Marked as read
+	IsDeleted       bool      `db:"is_deleted"`        // Marked as deleted
+	TelegramMsgID   int       `db:"telegram_msg_id"`   // Telegram message ID
+	DetectedCodes   string    `db:"detected_codes"`    // JSON array of detected codes
+	Attachments     string    `db:"attachments"`       // JSON array of attachments
+	RawKey          string    `db:"raw_key"`           // Key of the raw message in the archive (empty if not archived)
+	ParserVersion   int       `db:"parser_version"`    // parser.Version used for BodyText and DetectedCodes
+	Extracted       string    `db:"extracted"`         // JSON Extraction from a sender-specific extractor (empty if none)
+	ReplyTo         string    `db:"reply_to"`          // Reply-To address (empty if replies go to FromAddr)
+	References      string    `db:"references_header"` // References header plus In-Reply-To, for threading replies and conversations
+	ContentHash     string    `db:"content_hash"`      // Hash of normalized sender, subject and body for deduplication
+	DuplicateOf     int64     `db:"duplicate_of"`      // Earlier message with the same content; duplicates are not delivered (0 = original)
+	RawSize         int64     `db:"raw_size"`          // Bytes of the compressed raw message in the archive
+	BodyTrimmed     bool      `db:"body_trimmed"`      // Body and raw message removed to stay within the chat's storage quota
+	Bulk            string    `db:"bulk"`              // BulkSpam or BulkNewsletter if the headers mark the email as such (empty = personal)
+	Folder          string    `db:"folder"`            // IMAP folder the email was moved to (empty = still in INBOX)
+	Unsubscribe     string    `db:"unsubscribe"`       // JSON Unsubscribe from the List-Unsubscribe header (empty if none)
+	Auth            string    `db:"auth"`              // AuthPass or AuthFail from the DKIM, SPF and DMARC checks of the sender (empty = nothing to check)
+	DeliveryStatus  string    `db:"delivery_status"`   // DeliveryFailed after the send queue gave up on the email (empty = delivered or pending)
+	DeliveryError   string    `db:"delivery_error"`    // Last Telegram error of a failed delivery
+	DeliveryRetries int       `db:"delivery_retries"`  // Failed delivery rounds since the last success or /redeliver
+	CreatedAt       time.Time `db:"created_at"`
+}
+
+// Bulk classes of emails, detected from their headers
+const (
+	BulkSpam       = "spam" // Marked as spam by the mail server
+	BulkNewsletter = "bulk" // Newsletters and mailing lists
+)
+
+// Sender authentication verdicts, see mailauth.Checker
+const (
+	AuthPass = "pass" // A DKIM signature, SPF or DMARC confirms the domain of From
+	AuthFail = "fail" // The checks failed or confirm another domain only
+)
+
+// Unsubscribe holds the ways to leave a mailing list from the
+// List-Unsubscribe header (RFC 2369) of an email
+type Unsubscribe struct {
+	Mailto   string `json:"mailto,omitempty"`    // mailto: URI, may carry subject and body
+	URL      string `json:"url,omitempty"`       // https: URI
+	OneClick bool   `json:"one_click,omitempty"` // URL accepts a one-click POST (RFC 8058)
+}
+
+// UnsubscribeData returns the stored List-Unsubscribe or nil
+func (m *EmailMessage) UnsubscribeData() *Unsubscribe {
+	if m.Unsubscribe == "" {
+		return nil
+	}
+	var u Unsubscribe
+	if err := json.Unmarshal([]byte(m.Unsubscribe), &u); err != nil {
+		return nil
+	}
+	return &u
+}
+
+// DeliveryFailed marks emails the send queue gave up on (dead letters)
+const DeliveryFailed = "failed"
+
+// Attachment describes an email attachment (the content stays on the IMAP server)
+type Attachment struct {
+	Filename    string `json:"name"`
+	ContentType string `json:"type"`
+	Size        int64  `json:"size"`
 }
 
 // DetectedCode represents a detected verification code
@@ -26,3 +90,65 @@ type DetectedCode struct {
 	Type  string `json:"type"`  // "otp", "verification", "pin", "code"
 	Value string `json:"value"` // The code itself
 }
+
+// Extraction holds data pulled from an email by a sender-specific extractor
+type Extraction struct {
+	Extractor string           `json:"extractor"`
+	Codes     []DetectedCode   `json:"-"` // stored in EmailMessage.DetectedCodes
+	Fields    []ExtractedField `json:"fields,omitempty"`
+	Links     []ExtractedLink  `json:"links,omitempty"`
+	Order     *Order           `json:"order,omitempty"` // Set for commerce emails
+	Tracking  []TrackingNumber `json:"tracking,omitempty"`
+	Actions   []ActionLink     `json:"actions,omitempty"` // Shown as URL buttons
+}
+
+// ExtractedField is a named value, e.g. an amount or an account name
+type ExtractedField struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// ExtractedLink is an actionable link found in the email
+type ExtractedLink struct {
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+// Kinds of action links
+const (
+	ActionConfirm = "confirm" // confirm an address, verify an account or sign-in
+	ActionLogin   = "login"   // magic sign-in link
+	ActionReset   = "reset"   // password reset
+)
+
+// ActionLink is a confirmation, sign-in or password reset link of the email
+type ActionLink struct {
+	Kind  string `json:"kind"` // ActionConfirm, ActionLogin or ActionReset
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+// TrackingNumber is a parcel tracking number with its carrier's tracking page
+type TrackingNumber struct {
+	Carrier string `json:"carrier"`
+	Number  string `json:"number"`
+	URL     string `json:"url"`
+}
+
+// Empty reports whether nothing was extracted
+func (e *Extraction) Empty() bool {
+	return len(e.Codes) == 0 && len(e.Fields) == 0 && len(e.Links) == 0 && e.Order == nil && len(e.Tracking) == 0 &&
+		len(e.Actions) == 0
+}
+
+// ExtractedData returns the stored extraction or nil
+func (m *EmailMessage) ExtractedData() *Extraction {
+	if m.Extracted == "" {
+		return nil
+	}
+	var extraction Extraction
+	if err := json.Unmarshal([]byte(m.Extracted), &extraction); err != nil {
+		return nil
+	}
+	return &extraction
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// AccountMirror is a topic of another chat that receives read-only copies of
+// the emails of an account. Credentials and settings stay with the account's
+// own chat.
+type AccountMirror struct {
+	ID        int64     `db:"id"`
+	AccountID int64     `db:"account_id"` // FK to EmailAccount
+	ChatID    int64     `db:"chat_id"`    // Telegram Chat ID of the mirror
+	TopicID   int       `db:"topic_id"`   // Forum Topic ID in the mirror chat
+	CreatedBy int64     `db:"created_by"` // Telegram User ID of admin who linked the mirror
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// MirrorInvite is a one-time code that links a topic of another chat as a
+// mirror of an account
+type MirrorInvite struct {
+	Code      string    `db:"code"`
+	AccountID int64     `db:"account_id"` // FK to EmailAccount
+	CreatedBy int64     `db:"created_by"` // Telegram User ID of admin who created the invite
+	ExpiresAt time.Time `db:"expires_at"`
+}
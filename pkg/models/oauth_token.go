@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// OAuthToken holds the OAuth credentials of an account that logs in with
+// XOAUTH2 instead of a password
+type OAuthToken struct {
+	AccountID    int64     `db:"account_id"`    // FK to EmailAccount
+	Provider     string    `db:"provider"`      // "google" or "microsoft"
+	AccessToken  string    `db:"access_token"`  // Encrypted
+	RefreshToken string    `db:"refresh_token"` // Encrypted
+	ExpiresAt    time.Time `db:"expires_at"`    // Access token expiry
+	Revoked      bool      `db:"revoked"`       // Refresh token rejected; the account is paused until re-authorized
+	UpdatedAt    time.Time `db:"updated_at"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Order is a purchase extracted from a commerce email
+type Order struct {
+	ID        int64     `db:"id" json:"-"`
+	MessageID int64     `db:"message_id" json:"-"` // FK to EmailMessage
+	AccountID int64     `db:"account_id" json:"-"` // FK to EmailAccount
+	ChatID    int64     `db:"chat_id" json:"-"`
+	Number    string    `db:"order_number" json:"number"`
+	Amount    int64     `db:"amount" json:"amount"`     // In minor units (kopecks, cents); 0 if unknown
+	Currency  string    `db:"currency" json:"currency"` // ISO code, empty if unknown
+	Merchant  string    `db:"merchant" json:"merchant"`
+	CreatedAt time.Time `db:"created_at" json:"-"`
+}
+
+// SpendTotal is the sum of orders of one merchant in one currency
+type SpendTotal struct {
+	Merchant string `db:"merchant"`
+	Currency string `db:"currency"`
+	Total    int64  `db:"total"` // In minor units
+	Count    int    `db:"count"`
+}
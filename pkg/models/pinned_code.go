@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// PinnedCode is an email with codes pinned in its topic until UnpinAt
+type PinnedCode struct {
+	MessageID     int64     `db:"message_id"`      // FK to EmailMessage
+	AccountID     int64     `db:"account_id"`      // FK to EmailAccount
+	ChatID        int64     `db:"chat_id"`         // Chat the message is pinned in
+	TelegramMsgID int       `db:"telegram_msg_id"` // Pinned message
+	UnpinAt       time.Time `db:"unpin_at"`
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ParseQuietHours parses a window like "23:00-08:00" into offsets from
+// midnight. The window may wrap past midnight but must not be empty.
+func ParseQuietHours(s string) (start, end time.Duration, err error) {
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("quiet hours must look like 23:00-08:00, got %q", s)
+	}
+	if start, err = parseClock(from); err != nil {
+		return 0, 0, err
+	}
+	if end, err = parseClock(to); err != nil {
+		return 0, 0, err
+	}
+	if start == end {
+		return 0, 0, fmt.Errorf("quiet hours start and end must differ")
+	}
+	return start, end, nil
+}
+
+// QuietLocation returns the time zone of the account's quiet hours
+func (a *EmailAccount) QuietLocation() *time.Location {
+	if a.QuietTimezone == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(a.QuietTimezone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// QuietUntil reports whether t falls within the account's quiet hours and
+// returns when they end
+func (a *EmailAccount) QuietUntil(t time.Time) (time.Time, bool) {
+	if a.QuietHours == "" {
+		return time.Time{}, false
+	}
+	start, end, err := ParseQuietHours(a.QuietHours)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	local := t.In(a.QuietLocation())
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, local.Location())
+	now := local.Sub(midnight)
+
+	switch {
+	case start < end && now >= start && now < end:
+		return clockAt(midnight, end), true
+	case start > end && now >= start:
+		return clockAt(midnight.AddDate(0, 0, 1), end), true
+	case start > end && now < end:
+		return clockAt(midnight, end), true
+	}
+	return time.Time{}, false
+}
+
+// clockAt returns the wall-clock time offset from midnight of a day; unlike
+// adding a duration it stays correct across DST changes
+func clockAt(midnight time.Time, offset time.Duration) time.Time {
+	return time.Date(midnight.Year(), midnight.Month(), midnight.Day(),
+		int(offset/time.Hour), int(offset%time.Hour/time.Minute), 0, 0, midnight.Location())
+}
+
+// parseClock parses "HH:MM" into an offset from midnight
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// QueuedMessage represents an email waiting to be delivered to Telegram
+type QueuedMessage struct {
+	ID            int64     `db:"id"`
+	MessageID     int64     `db:"message_id"`      // FK to EmailMessage
+	Attempts      int       `db:"attempts"`        // Failed delivery attempts
+	LastError     string    `db:"last_error"`      // Last delivery error
+	NextAttemptAt time.Time `db:"next_attempt_at"` // Not delivered before this time
+	CreatedAt     time.Time `db:"created_at"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// SenderDigest collects emails of a noisy sender that exceeded the hourly
+// limit in a topic; they are delivered as one summary after the hour ends
+type SenderDigest struct {
+	AccountID int64     `db:"account_id"` // FK to EmailAccount
+	FromAddr  string    `db:"from_addr"`  // Throttled sender
+	HourStart time.Time `db:"hour_start"` // Start of the throttled hour
+	Count     int       `db:"count"`      // Emails held back
+	CreatedAt time.Time `db:"created_at"`
+}
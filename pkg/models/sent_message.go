@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Statuses of an outgoing email
+const (
+	SentDraft     = "draft"     // composed with /send, waiting for confirmation
+	SentSending   = "sending"   // confirmed, being sent
+	SentSent      = "sent"      // accepted by the SMTP server
+	SentFailed    = "failed"    // rejected or the server was unreachable
+	SentCancelled = "cancelled" // draft discarded
+)
+
+// SentMessage is an email sent from an account: composed with /send or a
+// reply to a forwarded email
+type SentMessage struct {
+	ID        int64      `db:"id"`
+	AccountID int64      `db:"account_id"` // FK to EmailAccount
+	ReplyTo   int64      `db:"reply_to"`   // Answered EmailMessage (0 = new email)
+	MessageID string     `db:"message_id"` // Message-ID header, set once sent
+	ToAddr    string     `db:"to_addr"`
+	Subject   string     `db:"subject"`
+	Body      string     `db:"body"`
+	Status    string     `db:"status"`
+	Error     string     `db:"error"`      // Last send error
+	CreatedBy int64      `db:"created_by"` // Telegram User ID of the author
+	CreatedAt time.Time  `db:"created_at"`
+	SentAt    *time.Time `db:"sent_at"`
+}
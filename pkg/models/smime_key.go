@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// SMIMEKey is the S/MIME private key and certificate of an account, used to
+// decrypt emails encrypted for its address
+type SMIMEKey struct {
+	AccountID   int64     `db:"account_id"`  // FK to EmailAccount
+	PrivateKey  string    `db:"private_key"` // Encrypted PEM
+	Certificate string    `db:"certificate"` // PEM, public
+	UpdatedAt   time.Time `db:"updated_at"`
+}
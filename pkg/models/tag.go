@@ -0,0 +1,27 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+)
+
+// TagPrefix selects accounts by tag in group commands, e.g. /pause tag:qa
+const TagPrefix = "tag:"
+
+// MaxTagLength is the longest accepted tag
+const MaxTagLength = 32
+
+// tagPattern matches valid tags after normalization
+var tagPattern = regexp.MustCompile(`^[\p{L}\p{N}_-]+$`)
+
+// NormalizeTag lowercases a tag and strips a leading # or the tag: prefix.
+// Returns false if the result is empty, too long or contains characters other
+// than letters, digits, _ and -.
+func NormalizeTag(tag string) (string, bool) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	tag = strings.TrimPrefix(strings.TrimPrefix(tag, "#"), TagPrefix)
+	if len([]rune(tag)) > MaxTagLength || !tagPattern.MatchString(tag) {
+		return "", false
+	}
+	return tag, true
+}